@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// minFingerprintKeySize is the minimum size of the key of argument fingerprints.
+const minFingerprintKeySize = 16
+
+// AccessLogEntry is a line of the access log, written for every GetPrompt request.
+// It allows proving which prompt text was served without recording argument values.
+type AccessLogEntry struct {
+	Time            time.Time `json:"time"`
+	Prompt          string    `json:"prompt"`
+	TemplateHash    string    `json:"template_hash"`         // SHA-256 of the template file
+	ServerVersion   string    `json:"server_version"`        // version of mcp-prompt-engine
+	ArgsFingerprint string    `json:"args_fingerprint"`      // HMAC-SHA256 of the canonicalized arguments
+	OutputHash      string    `json:"output_hash,omitempty"` // SHA-256 of the rendered prompt text
+	DurationMs      float64   `json:"duration_ms"`           // duration of the request
+	Error           string    `json:"error,omitempty"`       // why the prompt was not rendered
+}
+
+// AccessLog writes AccessLogEntry records as JSON lines. It is safe for concurrent use.
+type AccessLog struct {
+	mu  sync.Mutex
+	w   io.Writer
+	key []byte
+}
+
+// NewAccessLog creates an access log writing to w, with arguments fingerprinted by the key.
+func NewAccessLog(w io.Writer, key []byte) *AccessLog {
+	return &AccessLog{w: w, key: key}
+}
+
+// Fingerprint returns the fingerprint of the arguments. Identical arguments have the same fingerprint
+// regardless of their order, but the values cannot be recovered without guessing them and knowing the key.
+func (al *AccessLog) Fingerprint(args map[string]string) string {
+	return fingerprintArgs(al.key, args)
+}
+
+// Record writes the entry as a single JSON line.
+func (al *AccessLog) Record(entry AccessLogEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal access log entry: %w", err)
+	}
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	_, err = al.w.Write(append(line, '\n'))
+	return err
+}
+
+// fingerprintArgs computes the HMAC-SHA256 of the canonical form of the arguments:
+// a JSON object with keys in sorted order (as encoding/json writes maps).
+func fingerprintArgs(key []byte, args map[string]string) string {
+	if args == nil {
+		args = map[string]string{}
+	}
+	canonical, _ := json.Marshal(args) // a map of strings always marshals
+	mac := hmac.New(sha256.New, key)
+	mac.Write(canonical)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// hashContent returns the hex-encoded SHA-256 of the content, as used for templates and rendered prompts.
+func hashContent(content []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(content))
+}
+
+// loadFingerprintKey reads the key of argument fingerprints; surrounding whitespace is ignored.
+func loadFingerprintKey(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fingerprint key: %w", err)
+	}
+	key := bytes.TrimSpace(content)
+	if len(key) < minFingerprintKeySize {
+		return nil, fmt.Errorf("fingerprint key in %s must be at least %d bytes long", path, minFingerprintKeySize)
+	}
+	return key, nil
+}
+
+// accessVerifyOptions configures verifyAccessLogEntry.
+type accessVerifyOptions struct {
+	partialsDirs     []string
+	gitRef           string // render the templates of this git revision instead of the working tree
+	enableJSONArgs   bool
+	lineEndings      LineEndings
+	sensitivePattern *regexp.Regexp // arguments left out of fingerprints unless the frontmatter includes them
+	dateName         string         // field of the built-in date, defaultBuiltinDateName if empty
+}
+
+// verifyAccessLogEntry checks whether the logged request matches the given arguments:
+// the fingerprint is recomputed with the key, and the prompt is rendered again to compare the template and output hashes.
+// The prompt is rendered without environment variable fallbacks.
+func verifyAccessLogEntry(
+	ctx context.Context, w io.Writer, promptsDir string, logLine string, args map[string]string, key []byte,
+	opts accessVerifyOptions,
+) error {
+	var entry AccessLogEntry
+	if err := json.Unmarshal([]byte(strings.TrimSpace(logLine)), &entry); err != nil {
+		return fmt.Errorf("parse access log entry: %w", err)
+	}
+	if entry.Prompt == "" || entry.ArgsFingerprint == "" {
+		return fmt.Errorf("access log entry has no prompt or arguments fingerprint")
+	}
+
+	if opts.gitRef != "" {
+		refDir, err := os.MkdirTemp("", "mcp-prompt-engine-verify-")
+		if err != nil {
+			return fmt.Errorf("create directory for git revision: %w", err)
+		}
+		defer func() { _ = os.RemoveAll(refDir) }()
+		if err = extractDirAtGitRef(ctx, promptsDir, opts.gitRef, refDir); err != nil {
+			return err
+		}
+		promptsDir = refDir
+	}
+
+	templateHash, outputHash, err := renderForVerification(ctx, promptsDir, entry.Prompt, args, opts)
+	if err != nil {
+		return err
+	}
+	frontmatter, err := loadPromptFrontmatter(filepath.Join(promptsDir, entry.Prompt+templateExt), opts.dateName)
+	if err != nil {
+		return fmt.Errorf("load frontmatter of prompt %q: %w", entry.Prompt, err)
+	}
+	sensitive := sensitiveArgs{frontmatter: frontmatter, pattern: opts.sensitivePattern}
+
+	checks := []struct {
+		name     string
+		logged   string
+		computed string
+	}{
+		{"Arguments fingerprint", entry.ArgsFingerprint, fingerprintArgs(key, sensitive.FingerprintArgs(args))},
+		{"Template hash", entry.TemplateHash, templateHash},
+		{"Output hash", entry.OutputHash, outputHash},
+	}
+	mismatches := 0
+	for _, check := range checks {
+		if check.logged == check.computed {
+			mustFprintf(w, "%s %s - %s\n", successIcon(), check.name, successText(localize("verify.match")))
+			continue
+		}
+		mismatches++
+		mustFprintf(w, "%s %s - %s\n", errorIcon(), check.name,
+			errorText(localize("verify.mismatch", check.logged, check.computed)))
+	}
+	if mismatches > 0 {
+		return errors.New(localize("verify.checks_failed", mismatches, len(checks)))
+	}
+	return nil
+}
+
+// renderForVerification renders the prompt like the server does and returns the template and output hashes.
+func renderForVerification(
+	ctx context.Context, promptsDir string, promptName string, args map[string]string, opts accessVerifyOptions,
+) (templateHash string, outputHash string, err error) {
+	content, err := os.ReadFile(filepath.Join(promptsDir, promptName+templateExt))
+	if err != nil {
+		return "", "", fmt.Errorf("read template of prompt %q: %w", promptName, err)
+	}
+
+	promptsServer, err := NewPromptsServer(promptsDir,
+		WithJSONArgs(opts.enableJSONArgs),
+		WithArgsLimits(ArgsLimits{}),
+		WithRenderSettings(RenderSettings{DisableEnvArgs: true, LineEndings: opts.lineEndings}),
+		WithPartialsDirs(opts.partialsDirs...),
+		WithWatchMode(WatchModeOff, 0),
+		WithBuiltinDateName(opts.dateName),
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("load prompts: %w", err)
+	}
+	defer func() { _ = promptsServer.Close() }()
+
+	request, err := json.Marshal(map[string]any{
+		"jsonrpc": mcp.JSONRPC_VERSION,
+		"id":      1,
+		"method":  string(mcp.MethodPromptsGet),
+		"params":  map[string]any{"name": promptName, "arguments": args},
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("marshal prompt request: %w", err)
+	}
+	switch response := promptsServer.mcpServer.HandleMessage(ctx, request).(type) {
+	case mcp.JSONRPCResponse:
+		result, ok := response.Result.(mcp.GetPromptResult)
+		if !ok || len(result.Messages) != 1 {
+			return "", "", fmt.Errorf("unexpected result of prompt %q", promptName)
+		}
+		text, ok := result.Messages[0].Content.(mcp.TextContent)
+		if !ok {
+			return "", "", fmt.Errorf("unexpected content of prompt %q", promptName)
+		}
+		return hashContent(content), hashContent([]byte(text.Text)), nil
+	case mcp.JSONRPCError:
+		return "", "", fmt.Errorf("render prompt %q: %s", promptName, response.Error.Message)
+	default:
+		return "", "", fmt.Errorf("unexpected response to prompt %q", promptName)
+	}
+}
+
+// extractDirAtGitRef writes the files of the directory as of the git revision to destDir.
+// Subdirectories are not extracted, as prompts are only loaded from the top level.
+func extractDirAtGitRef(ctx context.Context, dir string, ref string, destDir string) error {
+	listing, err := runGit(ctx, dir, "ls-tree", "-z", ref, "--", ".")
+	if err != nil {
+		return fmt.Errorf("list files at git revision %q: %w", ref, err)
+	}
+	for _, record := range strings.Split(strings.TrimSuffix(string(listing), "\x00"), "\x00") {
+		meta, name, found := strings.Cut(record, "\t")
+		if !found || !strings.Contains(meta, " blob ") {
+			continue
+		}
+		name = filepath.Base(name)
+		content, err := runGit(ctx, dir, "show", ref+":./"+name)
+		if err != nil {
+			return fmt.Errorf("read %s at git revision %q: %w", name, ref, err)
+		}
+		if err = os.WriteFile(filepath.Join(destDir, name), content, 0644); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func runGit(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return output, nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const testFingerprintKey = "0123456789abcdef-test-key"
+
+type AccessLogTestSuite struct {
+	suite.Suite
+	promptsDir string
+	keyFile    string
+}
+
+func TestAccessLogTestSuite(t *testing.T) {
+	suite.Run(t, new(AccessLogTestSuite))
+}
+
+func (s *AccessLogTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("ticket.tmpl", "{{/* Open a ticket */}}\n[{{.project}}] {{.title}}{{range .labels}} #{{.}}{{end}}")
+	s.keyFile = filepath.Join(s.T().TempDir(), "fingerprint.key")
+	require.NoError(s.T(), os.WriteFile(s.keyFile, []byte(testFingerprintKey+"\n"), 0600))
+}
+
+func (s *AccessLogTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+// getPrompt requests the prompt from a server recording the access log and returns the logged entry
+func (s *AccessLogTestSuite) getPrompt(name string, args map[string]string) (string, AccessLogEntry) {
+	var accessLogBuf bytes.Buffer
+	promptsServer, err := NewPromptsServer(s.promptsDir,
+		WithAccessLog(NewAccessLog(&accessLogBuf, []byte(testFingerprintKey))), WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	request, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0", "id": 1, "method": "prompts/get", "params": map[string]any{"name": name, "arguments": args},
+	})
+	require.NoError(s.T(), err)
+	promptsServer.mcpServer.HandleMessage(context.Background(), request)
+
+	logLine := accessLogBuf.String()
+	require.Equal(s.T(), 1, strings.Count(logLine, "\n"), "one line per request")
+	var entry AccessLogEntry
+	require.NoError(s.T(), json.Unmarshal([]byte(logLine), &entry))
+	return logLine, entry
+}
+
+func (s *AccessLogTestSuite) verify(logLine string, argsJSON string, extraArgs ...string) (string, error) {
+	argsFile := filepath.Join(s.T().TempDir(), "args.json")
+	require.NoError(s.T(), os.WriteFile(argsFile, []byte(argsJSON), 0644))
+
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	err := app.Run(context.Background(), append([]string{app.Name, "--color", "never", "verify-access", s.promptsDir,
+		logLine, "--args-file", argsFile, "--fingerprint-key-file", s.keyFile}, extraArgs...))
+	return buf.String(), err
+}
+
+// TestFingerprintArgs tests that fingerprints are stable for a key and independent of argument order
+func (s *AccessLogTestSuite) TestFingerprintArgs() {
+	key := []byte(testFingerprintKey)
+	args := map[string]string{"project": "core", "title": "Fix login", "labels": `["bug"]`}
+	fingerprint := fingerprintArgs(key, args)
+	assert.Len(s.T(), fingerprint, 64)
+	assert.NotContains(s.T(), fingerprint, "core")
+
+	assert.Equal(s.T(), fingerprint, NewAccessLog(&bytes.Buffer{}, key).Fingerprint(map[string]string{
+		"labels": `["bug"]`, "title": "Fix login", "project": "core",
+	}), "same key and arguments in another order")
+
+	var fromJSON map[string]string
+	require.NoError(s.T(), json.Unmarshal([]byte(`{"title":"Fix login","labels":"[\"bug\"]","project":"core"}`), &fromJSON))
+	assert.Equal(s.T(), fingerprint, fingerprintArgs(key, fromJSON), "arguments decoded from JSON in another order")
+
+	assert.NotEqual(s.T(), fingerprint, fingerprintArgs([]byte("another-key-0123456789"), args), "another key")
+	assert.NotEqual(s.T(), fingerprint,
+		fingerprintArgs(key, map[string]string{"project": "core", "title": "Fix login ", "labels": `["bug"]`}),
+		"another value")
+	assert.Equal(s.T(), fingerprintArgs(key, nil), fingerprintArgs(key, map[string]string{}))
+
+	_, err := loadFingerprintKey(filepath.Join(s.T().TempDir(), "missing.key"))
+	require.Error(s.T(), err)
+	shortKeyFile := filepath.Join(s.T().TempDir(), "short.key")
+	require.NoError(s.T(), os.WriteFile(shortKeyFile, []byte("short\n"), 0600))
+	_, err = loadFingerprintKey(shortKeyFile)
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "must be at least 16 bytes long")
+}
+
+// TestAccessLogEntry tests the logged fields, which never contain argument values
+func (s *AccessLogTestSuite) TestAccessLogEntry() {
+	args := map[string]string{"project": "core", "title": "Fix login", "labels": `["bug"]`}
+	logLine, entry := s.getPrompt("ticket", args)
+	assert.NotContains(s.T(), logLine, "Fix login")
+	assert.NotContains(s.T(), logLine, "core")
+
+	content, err := os.ReadFile(filepath.Join(s.promptsDir, "ticket.tmpl"))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "ticket", entry.Prompt)
+	assert.Equal(s.T(), hashContent(content), entry.TemplateHash)
+	assert.Equal(s.T(), version, entry.ServerVersion)
+	assert.Equal(s.T(), fingerprintArgs([]byte(testFingerprintKey), args), entry.ArgsFingerprint)
+	assert.Equal(s.T(), hashContent([]byte("[core] Fix login #bug")), entry.OutputHash)
+	assert.False(s.T(), entry.Time.IsZero())
+	assert.Empty(s.T(), entry.Error)
+
+	s.writeFile("broken.tmpl", "{{/* Broken */}}\n{{index .items 5}}")
+	_, entry = s.getPrompt("broken", map[string]string{"items": "[]"})
+	assert.Empty(s.T(), entry.OutputHash)
+	assert.Contains(s.T(), entry.Error, "execute template")
+}
+
+// TestVerifyAccess tests that verify-access confirms matching arguments and detects mismatches
+func (s *AccessLogTestSuite) TestVerifyAccess() {
+	logLine, _ := s.getPrompt("ticket", map[string]string{"project": "core", "title": "Fix login", "labels": `["bug"]`})
+
+	output, err := s.verify(logLine, `{"title": "Fix login", "labels": ["bug"], "project": "core"}`)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "✓ Arguments fingerprint - Match\n✓ Template hash - Match\n✓ Output hash - Match\n", output)
+
+	output, err = s.verify(logLine, `{"title": "Fix logout", "labels": ["bug"], "project": "core"}`)
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "2 of 3 checks do not match the access log entry")
+	assert.Contains(s.T(), output, "✗ Arguments fingerprint - Mismatch")
+	assert.Contains(s.T(), output, "✓ Template hash - Match")
+	assert.Contains(s.T(), output, "✗ Output hash - Mismatch")
+
+	s.writeFile("ticket.tmpl", "{{/* Open a ticket */}}\n[{{.project}}] {{.title}}")
+	output, err = s.verify(logLine, `{"title": "Fix login", "labels": ["bug"], "project": "core"}`)
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), output, "✓ Arguments fingerprint - Match")
+	assert.Contains(s.T(), output, "✗ Template hash - Mismatch")
+
+	_, err = s.verify("not json", `{}`)
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "parse access log entry")
+}
+
+// TestVerifyAccessSensitiveArgs tests that sensitive arguments are left out of the recomputed fingerprint too
+func (s *AccessLogTestSuite) TestVerifyAccessSensitiveArgs() {
+	s.writeFile("deploy.tmpl", "---\narguments:\n  token:\n    sensitive: true\n---\n{{/* Deploy */}}\nDeploy {{.service}} with {{.token}}")
+	logLine, entry := s.getPrompt("deploy", map[string]string{"service": "api", "token": "tok-123"})
+	assert.Equal(s.T(), fingerprintArgs([]byte(testFingerprintKey), map[string]string{"service": "api"}), entry.ArgsFingerprint)
+
+	output, err := s.verify(logLine, `{"service": "api", "token": "tok-123"}`)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "✓ Arguments fingerprint - Match\n✓ Template hash - Match\n✓ Output hash - Match\n", output)
+}
+
+// TestVerifyAccessAtGitRef tests verifying an entry against the templates of a git revision
+func (s *AccessLogTestSuite) TestVerifyAccessAtGitRef() {
+	if _, err := exec.LookPath("git"); err != nil {
+		s.T().Skip("git is not installed")
+	}
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", s.promptsDir,
+			"-c", "user.name=Test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+		output, err := cmd.CombinedOutput()
+		require.NoError(s.T(), err, string(output))
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "Add ticket prompt")
+
+	logLine, _ := s.getPrompt("ticket", map[string]string{"project": "core", "title": "Fix login"})
+	s.writeFile("ticket.tmpl", "{{/* Open a ticket */}}\n{{.title}} in {{.project}}")
+
+	argsJSON := `{"project": "core", "title": "Fix login"}`
+	_, err := s.verify(logLine, argsJSON)
+	require.Error(s.T(), err, "working tree changed since the entry was logged")
+
+	output, err := s.verify(logLine, argsJSON, "--git-ref", "HEAD")
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), output, "✓ Output hash - Match")
+
+	_, err = s.verify(logLine, argsJSON, "--git-ref", "no-such-ref")
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), `list files at git revision "no-such-ref"`)
+}
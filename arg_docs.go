@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// argDocPrefix starts the inline comments documenting an argument where it is used,
+// e.g. {{.branch}}{{/* @doc branch: the git branch to review */}}.
+const argDocPrefix = "@doc "
+
+// ExtractArgumentDocs returns the inline docs of the arguments of the template and of the partials it includes,
+// by argument name. Docs of the template itself take precedence, then those of the partials in name order.
+func (pp *PromptsParser) ExtractArgumentDocs(tmpl *template.Template, templateName string) (map[string]string, error) {
+	targetTemplate := lookupPartial(tmpl, templateName)
+	if targetTemplate == nil {
+		return nil, fmt.Errorf("template %q not found", templateName)
+	}
+	_, partials, err := pp.analyzeTemplate(tmpl, targetTemplate.Name())
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[string]string)
+	collectArgDocs(targetTemplate.Root, docs)
+	for _, partial := range partials {
+		if t := lookupPartial(tmpl, partial); t != nil {
+			collectArgDocs(t.Root, docs)
+		}
+	}
+	return docs, nil
+}
+
+// collectArgDocs adds the docs of the comments under the node to docs, keeping the arguments already documented.
+func collectArgDocs(node parse.Node, docs map[string]string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectArgDocs(child, docs)
+		}
+	case *parse.IfNode:
+		collectArgDocs(n.List, docs)
+		collectArgDocs(n.ElseList, docs)
+	case *parse.RangeNode:
+		collectArgDocs(n.List, docs)
+		collectArgDocs(n.ElseList, docs)
+	case *parse.WithNode:
+		collectArgDocs(n.List, docs)
+		collectArgDocs(n.ElseList, docs)
+	case *parse.CommentNode:
+		if name, doc, ok := parseArgDoc(n.Text); ok {
+			if _, exists := docs[name]; !exists {
+				docs[name] = doc
+			}
+		}
+	}
+}
+
+// parseArgDoc parses the text of a comment like "/* @doc branch: the git branch to review */".
+// Lines of the doc are trimmed and joined with spaces, and the argument name is lowercased like argument names are.
+func parseArgDoc(comment string) (name string, doc string, ok bool) {
+	text := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(comment, "/*"), "*/"))
+	if text, ok = strings.CutPrefix(text, argDocPrefix); !ok {
+		return "", "", false
+	}
+	name, doc, ok = strings.Cut(text, ":")
+	name = strings.ToLower(strings.TrimSpace(name))
+	doc = strings.Join(strings.Fields(doc), " ")
+	if !ok || name == "" || doc == "" {
+		return "", "", false
+	}
+	return name, doc, true
+}
+
+// ArgumentDocs merges the descriptions declared in the frontmatter with the inline docs of the arguments,
+// and appends the declared examples.
+// Frontmatter descriptions win; the arguments whose inline docs they override with a different text are returned sorted.
+func (fm *PromptFrontmatter) ArgumentDocs(inline map[string]string) (docs map[string]string, overridden []string) {
+	docs = maps.Clone(inline)
+	if docs == nil {
+		docs = make(map[string]string)
+	}
+	if fm == nil {
+		return docs, nil
+	}
+	for name, spec := range fm.Arguments {
+		description := strings.TrimSpace(spec.Description)
+		if description == "" {
+			continue
+		}
+		if doc, exists := docs[name]; exists && doc != description {
+			overridden = append(overridden, name)
+		}
+		docs[name] = description
+	}
+	for name, spec := range fm.Arguments {
+		if spec.Example != "" {
+			docs[name] = withArgExample(docs[name], spec.Example)
+		}
+	}
+	slices.Sort(overridden)
+	return docs, overridden
+}
+
+// withArgExample appends the example value of an argument to its doc, e.g. `the city (e.g. "Paris")`,
+// since MCP prompt arguments have no field for examples.
+func withArgExample(doc string, example string) string {
+	if doc == "" {
+		return fmt.Sprintf("e.g. %q", example)
+	}
+	return fmt.Sprintf("%s (e.g. %q)", doc, example)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ArgDocsTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestArgDocsTestSuite(t *testing.T) {
+	suite.Run(t, new(ArgDocsTestSuite))
+}
+
+func (s *ArgDocsTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("_repo.tmpl", "{{define \"_repo\"}}Repo {{.repo}}{{/* @doc repo: the repository\n  to clone */}}{{end}}")
+	s.writeFile("review.tmpl", "{{/* Review a branch */}}\n"+
+		"{{template \"_repo\" .}}: review {{.branch}}{{/* @doc branch: the git branch to review */}}"+
+		"{{if .strict}}{{/* @doc Strict: fail on any finding */}} strictly{{end}}.")
+	s.writeFile("clone.tmpl", "---\narguments:\n  repo:\n    description: the repository URL\n---\n"+
+		"{{/* Clone a repository */}}\n{{template \"_repo\" .}}")
+}
+
+func (s *ArgDocsTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+// promptArguments returns the advertised arguments of the prompts of the server, by prompt name
+func (s *ArgDocsTestSuite) promptArguments() map[string][]mcp.PromptArgument {
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "prompts/list"})
+	require.NoError(s.T(), err)
+	response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok)
+	arguments := make(map[string][]mcp.PromptArgument)
+	for _, prompt := range response.Result.(mcp.ListPromptsResult).Prompts {
+		arguments[prompt.Name] = prompt.Arguments
+	}
+	return arguments
+}
+
+// TestInlineDocs tests docs written next to the use of the arguments, in the template and in its partials
+func (s *ArgDocsTestSuite) TestInlineDocs() {
+	arguments := s.promptArguments()
+	assert.ElementsMatch(s.T(), []mcp.PromptArgument{
+		{Name: "branch", Description: "the git branch to review"},
+		{Name: "repo", Description: "the repository to clone"},
+		{Name: "strict", Description: "fail on any finding"},
+	}, arguments["review"])
+}
+
+// TestFrontmatterOverridesInlineDocs tests that frontmatter descriptions win, with a warning of validate
+func (s *ArgDocsTestSuite) TestFrontmatterOverridesInlineDocs() {
+	assert.Equal(s.T(), []mcp.PromptArgument{{Name: "repo", Description: "the repository URL"}},
+		s.promptArguments()["clone"])
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), validateTemplates(&buf, s.promptsDir, nil, "", validateOptions{}))
+	assert.Contains(s.T(), buf.String(),
+		"⚠ clone.tmpl - the @doc comment of argument 'repo' is overridden by its frontmatter description")
+	assert.NotContains(s.T(), buf.String(), "⚠ review.tmpl")
+
+	buf.Reset()
+	require.NoError(s.T(), listTemplates(&buf, s.promptsDir, listOptions{verbose: true}))
+	assert.Contains(s.T(), buf.String(), "clone.tmpl\n  Description: Clone a repository\n  Variables: repo\n    repo: the repository URL\n")
+	assert.Contains(s.T(), buf.String(), "  Variables: branch, repo, strict\n"+
+		"    branch: the git branch to review\n    repo: the repository to clone\n    strict: fail on any finding\n")
+}
+
+// TestArgumentExamples tests that examples declared in the frontmatter are shown to clients and in list --verbose
+func (s *ArgDocsTestSuite) TestArgumentExamples() {
+	s.writeFile("weather.tmpl", "---\narguments:\n  city:\n    description: the city\n    example: Paris\n"+
+		"  days:\n    type: number\n    example: \"3\"\n---\n{{/* Forecast */}}\nWeather in {{.city}} for {{.days}} days")
+
+	assert.Equal(s.T(), []mcp.PromptArgument{
+		{Name: "city", Description: `the city (e.g. "Paris")`},
+		{Name: "days", Description: `e.g. "3"`},
+	}, s.promptArguments()["weather"])
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), listTemplates(&buf, s.promptsDir, listOptions{verbose: true}))
+	assert.Contains(s.T(), buf.String(), "  Variables: city, days\n    city: the city (e.g. \"Paris\")\n    days: e.g. \"3\"\n")
+
+	_, err := parseFrontmatter([]byte("---\narguments:\n  days:\n    type: number\n    max: 7\n    example: \"10\"\n---\n"), defaultBuiltinDateName)
+	require.EqualError(s.T(), err, `example of argument "days": argument "days" must be at most 7, got 10`)
+}
+
+// TestCommentsDoNotChangeOutput tests that keeping comments in the parse trees changes neither the rendered text
+// nor the descriptions
+func (s *ArgDocsTestSuite) TestCommentsDoNotChangeOutput() {
+	s.writeFile("trimmed.tmpl", "{{- /* Trimmed */ -}}\nA {{- /* @doc a: ignored */ -}} B {{/* plain comment */}}C")
+	s.writeFile("doc_first.tmpl", "{{/* @doc name: the name */}}\nHello {{.name}}")
+
+	parser := &PromptsParser{}
+	tmpl, err := parser.ParseDir(s.promptsDir)
+	require.NoError(s.T(), err)
+	var buf bytes.Buffer
+	require.NoError(s.T(), tmpl.ExecuteTemplate(&buf, "trimmed.tmpl", nil))
+	assert.Equal(s.T(), "AB C", buf.String())
+
+	description, err := parser.ExtractPromptDescriptionFromFile(filepath.Join(s.promptsDir, "trimmed.tmpl"))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Trimmed", description)
+	description, err = parser.ExtractPromptDescriptionFromFile(filepath.Join(s.promptsDir, "doc_first.tmpl"))
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), description, "a leading argument doc is not a description")
+
+	docs, err := parser.ExtractArgumentDocs(tmpl, "doc_first")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), map[string]string{"name": "the name"}, docs)
+
+	_, err = parser.ParseTemplates(map[string]string{"bad": "{{undefined_func .x}}"})
+	assert.ErrorContains(s.T(), err, `function "undefined_func" not defined`, "calls are still checked")
+}
+
+// TestParseArgDoc tests the syntax of the doc comments
+func (s *ArgDocsTestSuite) TestParseArgDoc() {
+	for _, tc := range []struct {
+		comment string
+		name    string
+		doc     string
+		ok      bool
+	}{
+		{comment: "/* @doc branch: the branch */", name: "branch", doc: "the branch", ok: true},
+		{comment: "/*@doc  Branch :  the\n  branch*/", name: "branch", doc: "the branch", ok: true},
+		{comment: "/* Description */"},
+		{comment: "/* @doc branch */"},
+		{comment: "/* @doc : no name */"},
+		{comment: "/* @doc branch: */"},
+	} {
+		name, doc, ok := parseArgDoc(tc.comment)
+		assert.Equal(s.T(), tc.ok, ok, tc.comment)
+		assert.Equal(s.T(), tc.name, name, tc.comment)
+		assert.Equal(s.T(), tc.doc, doc, tc.comment)
+	}
+}
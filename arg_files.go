@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// argFileStdin is the path of --arg-file and --arg-file-json that reads the argument from stdin.
+const argFileStdin = "-"
+
+// argFilesReader reads the argument files of the render command. Values of --arg-file are bound as text,
+// as is, and values of --arg-file-json are parsed as JSON, whatever the JSON argument mode.
+type argFilesReader struct {
+	stdin        io.Reader
+	maxTotalSize int // maximum combined size of the names and values of all arguments, 0 for unlimited
+
+	totalSize int
+	stdinUsed bool
+}
+
+// readArgFiles reads the name=path pairs of --arg-file and --arg-file-json. A name given again by the same flag
+// takes the later file; a name given by --arg or by both file flags is an error.
+func readArgFiles(
+	stdin io.Reader, textFiles []string, jsonFiles []string, cliArgs map[string]string, maxTotalSize int,
+) (map[string]interface{}, error) {
+	reader := &argFilesReader{stdin: stdin, maxTotalSize: maxTotalSize}
+	for name, value := range cliArgs {
+		reader.totalSize += len(name) + len(value)
+	}
+	if maxTotalSize > 0 && reader.totalSize > maxTotalSize {
+		return nil, errors.New(localize("cli.args_too_large", maxTotalSize))
+	}
+	textPaths, err := parseArgFilePairs(textFiles, "--arg-file", cliArgs, nil)
+	if err != nil {
+		return nil, err
+	}
+	jsonPaths, err := parseArgFilePairs(jsonFiles, "--arg-file-json", cliArgs, textPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{}, len(textPaths)+len(jsonPaths))
+	for _, name := range slices.Sorted(maps.Keys(textPaths)) {
+		path := textPaths[name]
+		content, err := reader.read(name, path)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = string(content)
+	}
+	for _, name := range slices.Sorted(maps.Keys(jsonPaths)) {
+		path := jsonPaths[name]
+		content, err := reader.read(name, path)
+		if err != nil {
+			return nil, err
+		}
+		var parsed interface{}
+		if err = json.Unmarshal(content, &parsed); err != nil {
+			return nil, fmt.Errorf("%s: %w", localize("cli.arg_file_invalid_json", path, name), err)
+		}
+		values[name] = parsed
+	}
+	return values, nil
+}
+
+// parseArgFilePairs parses the name=path pairs of a file flag, rejecting names given by --arg or by another file flag.
+func parseArgFilePairs(
+	pairs []string, flagName string, cliArgs map[string]string, otherPaths map[string]string,
+) (map[string]string, error) {
+	paths := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		name, path, ok := strings.Cut(pair, "=")
+		if !ok || name == "" || path == "" {
+			return nil, errors.New(localize("cli.invalid_arg_file_format", pair))
+		}
+		if _, exists := cliArgs[name]; exists {
+			return nil, errors.New(localize("cli.arg_file_conflict", name, "--arg", flagName))
+		}
+		if _, exists := otherPaths[name]; exists {
+			return nil, errors.New(localize("cli.arg_file_conflict", name, "--arg-file", flagName))
+		}
+		paths[name] = path
+	}
+	return paths, nil
+}
+
+// read reads the file of the argument, stopping as soon as the arguments exceed the size limit.
+func (r *argFilesReader) read(name string, path string) ([]byte, error) {
+	var src io.Reader
+	if path == argFileStdin {
+		if r.stdinUsed {
+			return nil, errors.New(localize("cli.arg_file_stdin_twice"))
+		}
+		r.stdinUsed = true
+		src = r.stdin
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", localize("cli.arg_file_unreadable", path, name), err)
+		}
+		defer func() { _ = file.Close() }()
+		src = file
+	}
+	r.totalSize += len(name)
+	if r.maxTotalSize > 0 {
+		src = io.LimitReader(src, int64(r.maxTotalSize-r.totalSize+1))
+	}
+	content, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", localize("cli.arg_file_unreadable", path, name), err)
+	}
+	r.totalSize += len(content)
+	if r.maxTotalSize > 0 && r.totalSize > r.maxTotalSize {
+		return nil, errors.New(localize("cli.args_too_large", r.maxTotalSize))
+	}
+	return content, nil
+}
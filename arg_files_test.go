@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ArgFilesTestSuite struct {
+	suite.Suite
+	promptsDir string
+	filesDir   string
+}
+
+func TestArgFilesTestSuite(t *testing.T) {
+	suite.Run(t, new(ArgFilesTestSuite))
+}
+
+func (s *ArgFilesTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.filesDir = s.T().TempDir()
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, "review.tmpl"),
+		[]byte("{{/* Review */}}\n[{{.diff}}]"), 0644))
+}
+
+func (s *ArgFilesTestSuite) writeFile(name, content string) string {
+	path := filepath.Join(s.filesDir, name)
+	require.NoError(s.T(), os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func (s *ArgFilesTestSuite) render(stdin string, args ...string) (string, error) {
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	app.ErrWriter = &bytes.Buffer{}
+	app.Reader = strings.NewReader(stdin)
+	err := app.Run(context.Background(), append([]string{app.Name, "render"}, args...))
+	return buf.String(), err
+}
+
+// TestArgFile tests that file contents are bound as text, never parsed as JSON
+func (s *ArgFilesTestSuite) TestArgFile() {
+	content := "a\x00b\x7f\"quoted\" 'single' $(not run)\n\xff\xfe"
+	output, err := s.render("", s.promptsDir, "review", "--arg-file", "diff="+s.writeFile("binary.diff", content))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "["+content+"]", output)
+
+	output, err = s.render("", s.promptsDir, "review", "--arg-file", "diff="+s.writeFile("list.json", `[1, 2]`))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "[[1, 2]]", output, "JSON content of --arg-file stays text")
+
+	output, err = s.render("", s.promptsDir, "review",
+		"--arg-file", "diff="+s.writeFile("first.diff", "first"), "--arg-file", "diff="+s.writeFile("second.diff", "second"))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "[second]", output, "the later file wins")
+}
+
+// TestArgFileStdin tests reading an argument file from stdin, only once
+func (s *ArgFilesTestSuite) TestArgFileStdin() {
+	output, err := s.render("from stdin", s.promptsDir, "review", "--arg-file", "diff=-")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "[from stdin]", output)
+
+	_, err = s.render(`{"a": 1}`, s.promptsDir, "review", "--arg-file", "diff=-", "--arg-file-json", "other=-")
+	assert.EqualError(s.T(), err, "only one argument file can be read from stdin ('-')")
+}
+
+// TestArgFileJSON tests that --arg-file-json binds structured values, even with JSON arguments disabled
+func (s *ArgFilesTestSuite) TestArgFileJSON() {
+	users := s.writeFile("users.json", `[{"name": "Alice", "age": 30, "role": "admin"}, {"name": "Bob", "age": 25, "role": "user"}]`)
+	output, err := s.render("", "./testdata", "range_structs",
+		"--arg-file-json", "users="+users, "--arg", "total=2", "--disable-json-args")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Users:\n  - Alice (30) - admin\n  - Bob (25) - user\n\nTotal: 2 users", output)
+
+	_, err = s.render("", "./testdata", "range_structs", "--arg-file-json", "users="+s.writeFile("bad.json", "[1,"))
+	assert.ErrorContains(s.T(), err, "file '"+filepath.Join(s.filesDir, "bad.json")+"' of argument 'users' is not valid JSON")
+}
+
+// TestArgFileErrors tests conflicts with --arg, malformed flags, unreadable files, and the size limit
+func (s *ArgFilesTestSuite) TestArgFileErrors() {
+	diff := s.writeFile("change.diff", strings.Repeat("x", 100))
+	tests := []struct {
+		name        string
+		args        []string
+		expectedErr string
+	}{
+		{
+			name:        "conflict with --arg",
+			args:        []string{"--arg", "diff=inline", "--arg-file", "diff=" + diff},
+			expectedErr: "argument 'diff' is given by both --arg and --arg-file",
+		},
+		{
+			name:        "conflict between file flags",
+			args:        []string{"--arg-file", "diff=" + diff, "--arg-file-json", "diff=" + diff},
+			expectedErr: "argument 'diff' is given by both --arg-file and --arg-file-json",
+		},
+		{
+			name:        "missing path",
+			args:        []string{"--arg-file", "diff"},
+			expectedErr: "invalid argument file 'diff', expected name=path",
+		},
+		{
+			name:        "unreadable path",
+			args:        []string{"--arg-file", "diff=" + filepath.Join(s.filesDir, "missing.diff")},
+			expectedErr: "cannot read file '" + filepath.Join(s.filesDir, "missing.diff") + "' of argument 'diff': open ",
+		},
+		{
+			name:        "directory",
+			args:        []string{"--arg-file", "diff=" + s.filesDir},
+			expectedErr: "cannot read file '" + s.filesDir + "' of argument 'diff'",
+		},
+		{
+			name:        "too large",
+			args:        []string{"--arg-file", "diff=" + diff, "--max-args-size", "103"},
+			expectedErr: "arguments exceed the limit of 103 bytes (see --max-args-size)",
+		},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			_, err := s.render("", append([]string{s.promptsDir, "review"}, tt.args...)...)
+			require.Error(s.T(), err)
+			assert.Contains(s.T(), err.Error(), tt.expectedErr)
+		})
+	}
+
+	output, err := s.render("", s.promptsDir, "review", "--arg-file", "diff="+diff, "--max-args-size", "104")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "["+strings.Repeat("x", 100)+"]", output, "the name and the content fit the limit")
+}
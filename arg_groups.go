@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ArgumentGroup is a named section of prompt arguments in listings, e.g. "auth" for user and password.
+type ArgumentGroup struct {
+	Name string
+	Args []string
+}
+
+// ArgumentGroups are the argument groups of the frontmatter, in the order they are declared:
+//
+//	groups:
+//	  auth: [user, password]
+//	  display: [theme]
+//
+// Groups only affect how arguments are listed, never the arguments of the prompt.
+type ArgumentGroups []ArgumentGroup
+
+// UnmarshalYAML decodes the groups from a mapping, keeping the order of its keys.
+func (g *ArgumentGroups) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: groups must map group names to lists of arguments", node.Line)
+	}
+	groups := make(ArgumentGroups, 0, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name := node.Content[i].Value
+		var args []string
+		if err := node.Content[i+1].Decode(&args); err != nil {
+			return fmt.Errorf("group %q: %w", name, err)
+		}
+		groups = append(groups, ArgumentGroup{Name: name, Args: args})
+	}
+	*g = groups
+	return nil
+}
+
+// validate checks that groups are named uniquely and that no argument is in several groups.
+func (g ArgumentGroups) validate() error {
+	groupOf := make(map[string]string)
+	for i, group := range g {
+		if group.Name == "" {
+			return fmt.Errorf("group %d has no name", i+1)
+		}
+		if slices.ContainsFunc(g[:i], func(other ArgumentGroup) bool { return other.Name == group.Name }) {
+			return fmt.Errorf("duplicate group %q", group.Name)
+		}
+		for _, arg := range group.Args {
+			if other, exists := groupOf[arg]; exists {
+				return fmt.Errorf("argument %q is in groups %q and %q", arg, other, group.Name)
+			}
+			groupOf[arg] = group.Name
+		}
+	}
+	return nil
+}
+
+// GroupArguments sorts the arguments into the groups of the frontmatter, in the order of the groups and
+// of their arguments, and returns nil without groups. The arguments in no group are sorted into a last group
+// without a name. Names in groups that are not arguments, e.g. data constants, are left out, as are empty groups.
+func (fm *PromptFrontmatter) GroupArguments(args []string) []ArgumentGroup {
+	if fm == nil || len(fm.Groups) == 0 {
+		return nil
+	}
+	grouped := make(map[string]bool, len(args))
+	var groups []ArgumentGroup
+	for _, group := range fm.Groups {
+		var members []string
+		for _, arg := range group.Args {
+			if slices.Contains(args, arg) {
+				members = append(members, arg)
+				grouped[arg] = true
+			}
+		}
+		if len(members) > 0 {
+			groups = append(groups, ArgumentGroup{Name: group.Name, Args: members})
+		}
+	}
+	var ungrouped []string
+	for _, arg := range args {
+		if !grouped[arg] {
+			ungrouped = append(ungrouped, arg)
+		}
+	}
+	if len(ungrouped) > 0 {
+		slices.Sort(ungrouped)
+		groups = append(groups, ArgumentGroup{Args: ungrouped})
+	}
+	return groups
+}
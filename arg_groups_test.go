@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const groupedTemplate = `---
+groups:
+  auth: [user, password]
+  display: [theme, company]
+  unused: [missing]
+data:
+  company: Acme
+arguments:
+  user:
+    description: Login name
+---
+{{/* Sign in */}}
+{{.user}} {{.password}} {{.theme}} {{.verbose}} {{.company}} {{.debug}}`
+
+type ArgGroupsTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestArgGroupsTestSuite(t *testing.T) {
+	suite.Run(t, new(ArgGroupsTestSuite))
+}
+
+func (s *ArgGroupsTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("signin.tmpl", groupedTemplate)
+}
+
+func (s *ArgGroupsTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+func (s *ArgGroupsTestSuite) promptArguments() []string {
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "prompts/list", "params": map[string]any{}})
+	require.NoError(s.T(), err)
+	response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok)
+	prompts := response.Result.(mcp.ListPromptsResult).Prompts
+	require.Len(s.T(), prompts, 1)
+	var names []string
+	for _, arg := range prompts[0].Arguments {
+		names = append(names, arg.Name)
+	}
+	return names
+}
+
+// TestGroupedListing tests that list --verbose prints the arguments under the headers of their groups,
+// with the ungrouped ones last
+func (s *ArgGroupsTestSuite) TestGroupedListing() {
+	var buf bytes.Buffer
+	require.NoError(s.T(), listTemplates(&buf, s.promptsDir, listOptions{verbose: true}))
+	assert.Equal(s.T(), "signin.tmpl\n"+
+		"  Description: Sign in\n"+
+		"  Variables:\n"+
+		"    auth: user, password\n"+
+		"      user: Login name\n"+
+		"    display: theme\n"+
+		"    Other: debug, verbose\n"+
+		"  Estimated size: ~1 tokens of static text\n", removeANSIColors(buf.String()))
+
+	buf.Reset()
+	require.NoError(s.T(), listTemplates(&buf, s.promptsDir, listOptions{verbose: true, argOrigins: true}))
+	assert.Contains(s.T(), removeANSIColors(buf.String()),
+		"    auth: user (signin.tmpl), password (signin.tmpl)\n")
+}
+
+// TestGroupsDoNotChangeArguments tests that the registered arguments are the same with and without groups
+func (s *ArgGroupsTestSuite) TestGroupsDoNotChangeArguments() {
+	grouped := s.promptArguments()
+	s.writeFile("signin.tmpl", "---\ndata:\n  company: Acme\narguments:\n  user:\n    description: Login name\n---\n"+
+		"{{/* Sign in */}}\n{{.user}} {{.password}} {{.theme}} {{.verbose}} {{.company}} {{.debug}}")
+	assert.Equal(s.T(), s.promptArguments(), grouped)
+	assert.ElementsMatch(s.T(), []string{"debug", "password", "theme", "user", "verbose"}, grouped)
+}
+
+// TestInvalidGroups tests the errors of malformed groups
+func (s *ArgGroupsTestSuite) TestInvalidGroups() {
+	for _, tc := range []struct {
+		groups   string
+		expected string
+	}{
+		{groups: "[user]", expected: "parse frontmatter: line 1: groups must map group names to lists of arguments"},
+		{groups: "{auth: user}", expected: `parse frontmatter: group "auth": yaml: unmarshal errors:`},
+		{groups: "{auth: [user], display: [user]}", expected: `groups: argument "user" is in groups "auth" and "display"`},
+		{groups: `{"": [user]}`, expected: "groups: group 1 has no name"},
+	} {
+		_, err := parseFrontmatter([]byte("---\ngroups: "+tc.groups+"\n---\nHello {{.user}}"), defaultBuiltinDateName)
+		assert.ErrorContains(s.T(), err, tc.expected, tc.groups)
+	}
+
+	frontmatter, err := parseFrontmatter([]byte("---\ndata:\n  company: Acme\n---\nHello {{.user}}"), defaultBuiltinDateName)
+	require.NoError(s.T(), err)
+	assert.Nil(s.T(), frontmatter.GroupArguments([]string{"user"}), "arguments without groups are listed as before")
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// argTransforms are the transforms that can be declared for prompt arguments in the frontmatter, by name.
+var argTransforms = map[string]func(string) string{
+	"trim":            strings.TrimSpace,
+	"lower":           strings.ToLower,
+	"upper":           strings.ToUpper,
+	"collapse_spaces": func(s string) string { return strings.Join(strings.Fields(s), " ") },
+}
+
+// argTransformNames returns the names of the available transforms in sorted order.
+func argTransformNames() []string {
+	names := make([]string, 0, len(argTransforms))
+	for name := range argTransforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyArgTransforms applies the named transforms to the value in order.
+// The names must have been validated against argTransforms.
+func applyArgTransforms(value string, names []string) string {
+	for _, name := range names {
+		value = argTransforms[name](value)
+	}
+	return value
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestArgTransforms tests the transforms of the registry and that they are applied in the declared order
+func TestArgTransforms(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		transforms []string
+		expected   string
+	}{
+		{name: "no transforms", value: " Go ", expected: " Go "},
+		{name: "trim", value: " \tGo\n", transforms: []string{"trim"}, expected: "Go"},
+		{name: "lower", value: "GoLang", transforms: []string{"lower"}, expected: "golang"},
+		{name: "upper", value: "GoLang", transforms: []string{"upper"}, expected: "GOLANG"},
+		{name: "collapse spaces", value: "  a \t b\n\nc ", transforms: []string{"collapse_spaces"}, expected: "a b c"},
+		{name: "chain", value: "  Hello   World ", transforms: []string{"collapse_spaces", "upper"}, expected: "HELLO WORLD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, applyArgTransforms(tt.value, tt.transforms))
+		})
+	}
+}
+
+// TestTransformArgs tests that only arguments with declared transforms change and the input is not modified
+func TestTransformArgs(t *testing.T) {
+	fm := &PromptFrontmatter{Arguments: map[string]ArgumentSpec{"name": {Transform: []string{"trim", "upper"}}}}
+	args := map[string]string{"name": " bob ", "city": " Paris "}
+
+	assert.Equal(t, map[string]string{"name": "BOB", "city": " Paris "}, fm.TransformArgs(args))
+	assert.Equal(t, " bob ", args["name"])
+
+	var noFrontmatter *PromptFrontmatter
+	assert.Equal(t, args, noFrontmatter.TransformArgs(args))
+	assert.Equal(t, " bob ", noFrontmatter.TransformArg("name", " bob "))
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"time"
+)
+
+// defaultBenchIterations is the number of renders of the bench command.
+const defaultBenchIterations = 100
+
+// benchOptions controls benchTemplate.
+type benchOptions struct {
+	partialsDirs   []string
+	enableJSONArgs bool
+	dateName       string // field of the built-in date, defaultBuiltinDateName if empty
+	iterations     int
+}
+
+// benchResult holds the latency and allocation statistics of repeated renders.
+type benchResult struct {
+	Iterations  int
+	Min         time.Duration
+	Avg         time.Duration
+	Max         time.Duration
+	P99         time.Duration
+	AllocsPerOp uint64
+	BytesPerOp  uint64
+}
+
+// benchTemplate renders the template the given number of times like the render command does,
+// including parsing the prompts directory, and returns the latency and allocation statistics.
+func benchTemplate(promptsDir string, templateName string, args map[string]string, opts benchOptions) (benchResult, error) {
+	dateName := opts.dateName
+	if dateName == "" {
+		dateName = defaultBuiltinDateName
+	}
+	return measureRenders(opts.iterations, func() error {
+		return renderTemplateWithWarnings(
+			io.Discard, io.Discard, promptsDir, opts.partialsDirs, templateName, args, nil, nil, opts.enableJSONArgs, dateName, nil,
+		)
+	})
+}
+
+// measureRenders calls render the given number of times after a warm-up call, which also reports errors early.
+func measureRenders(iterations int, render func() error) (benchResult, error) {
+	if iterations < 1 {
+		return benchResult{}, fmt.Errorf("iterations must be positive, got %d", iterations)
+	}
+	if err := render(); err != nil {
+		return benchResult{}, err
+	}
+
+	durations := make([]time.Duration, iterations)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for i := range durations {
+		start := time.Now()
+		if err := render(); err != nil {
+			return benchResult{}, err
+		}
+		durations[i] = time.Since(start)
+	}
+	runtime.ReadMemStats(&after)
+
+	slices.Sort(durations)
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	return benchResult{
+		Iterations:  iterations,
+		Min:         durations[0],
+		Avg:         total / time.Duration(iterations),
+		Max:         durations[iterations-1],
+		P99:         durations[(iterations*99+99)/100-1], // nearest rank
+		AllocsPerOp: (after.Mallocs - before.Mallocs) / uint64(iterations),
+		BytesPerOp:  (after.TotalAlloc - before.TotalAlloc) / uint64(iterations),
+	}, nil
+}
+
+// printBenchResult writes the statistics of benchTemplate.
+func printBenchResult(w io.Writer, templateName string, result benchResult) {
+	mustFprintf(w, "%s\n", localize("bench.iterations", templateText(templateName), result.Iterations))
+	mustFprintf(w, "%s: %s\n", infoText(localize("bench.latency")),
+		localize("bench.latency_value", result.Min, result.Avg, result.Max, result.P99))
+	mustFprintf(w, "%s: %s\n", infoText(localize("bench.allocations")),
+		localize("bench.allocations_value", result.AllocsPerOp, result.BytesPerOp))
+}
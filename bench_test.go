@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type BenchTestSuite struct {
+	suite.Suite
+}
+
+func TestBenchTestSuite(t *testing.T) {
+	suite.Run(t, new(BenchTestSuite))
+}
+
+// TestMeasureRenders tests that every iteration is measured after the warm-up render
+func (s *BenchTestSuite) TestMeasureRenders() {
+	calls := 0
+	result, err := measureRenders(20, func() error {
+		calls++
+		return nil
+	})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 21, calls, "20 iterations and the warm-up render")
+	assert.Equal(s.T(), 20, result.Iterations)
+	assert.LessOrEqual(s.T(), result.Min, result.Avg)
+	assert.LessOrEqual(s.T(), result.Avg, result.Max)
+	assert.LessOrEqual(s.T(), result.P99, result.Max)
+
+	_, err = measureRenders(0, func() error { return nil })
+	assert.EqualError(s.T(), err, "iterations must be positive, got 0")
+
+	calls = 0
+	_, err = measureRenders(5, func() error {
+		calls++
+		return errors.New("boom")
+	})
+	assert.EqualError(s.T(), err, "boom")
+	assert.Equal(s.T(), 1, calls, "a failing warm-up render stops the benchmark")
+}
+
+// TestBenchCommand tests that the hidden bench command reports the timing and allocation fields
+func (s *BenchTestSuite) TestBenchCommand() {
+	var stdout bytes.Buffer
+	app := newApp()
+	app.Writer = &stdout
+	err := app.Run(context.Background(), []string{
+		app.Name, "bench", "./testdata", "greeting", "--arg", "name=Alice", "-n", "7",
+	})
+	require.NoError(s.T(), err)
+	output := removeANSIColors(stdout.String())
+	assert.Contains(s.T(), output, "Rendered greeting 7 times\n")
+	assert.Regexp(s.T(), `Latency: min \S+, avg \S+, max \S+, p99 \S+\n`, output)
+	assert.Regexp(s.T(), `Allocations: \d+ allocs, \d+ bytes per render\n`, output)
+
+	err = app.Run(context.Background(), []string{app.Name, "bench", "./testdata", "greeting", "-n", "0"})
+	assert.ErrorContains(s.T(), err, "iterations must be positive")
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"text/template"
+)
+
+// maxUntilCount bounds the list built by until, so a huge count argument cannot exhaust memory.
+const maxUntilCount = 10000
+
+// Enumerated is an element of the result of enumerate.
+type Enumerated struct {
+	Index  int // zero-based position in the list
+	Number int // one-based position, for numbered lists in prompts
+	Value  interface{}
+}
+
+// collectionFuncs returns the helpers for building and iterating lists and maps.
+// They never modify their inputs and treat a nil (e.g., missing) collection as an empty one.
+func collectionFuncs() template.FuncMap {
+	return template.FuncMap{
+		"list":      list,
+		"append":    appendList,
+		"sortAlpha": sortAlpha,
+		"keys":      keys,
+		"values":    values,
+		"hasKey":    hasKey,
+		"until":     until,
+		"enumerate": enumerate,
+	}
+}
+
+// list returns its arguments as a list, e.g. {{range list "a" "b"}}.
+func list(items ...interface{}) []interface{} {
+	return append([]interface{}{}, items...)
+}
+
+// appendList returns a copy of the list with the items added at the end.
+func appendList(collection interface{}, items ...interface{}) ([]interface{}, error) {
+	result, err := toList(collection)
+	if err != nil {
+		return nil, err
+	}
+	return append(result, items...), nil
+}
+
+// sortAlpha returns the elements of the list as strings in alphabetical order.
+func sortAlpha(collection interface{}) ([]string, error) {
+	items, err := toList(collection)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		result = append(result, stringify(item))
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// keys returns the keys of the map in sorted order.
+func keys(collection interface{}) ([]string, error) {
+	m, err := toMap(collection)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(m))
+	for key := range m {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// values returns the values of the map in the order of their sorted keys.
+func values(collection interface{}) ([]interface{}, error) {
+	m, err := toMap(collection)
+	if err != nil {
+		return nil, err
+	}
+	sortedKeys, _ := keys(m)
+	result := make([]interface{}, 0, len(m))
+	for _, key := range sortedKeys {
+		result = append(result, m[key])
+	}
+	return result, nil
+}
+
+// hasKey reports whether the map contains the key, even if its value is empty.
+func hasKey(collection interface{}, key interface{}) (bool, error) {
+	m, err := toMap(collection)
+	if err != nil {
+		return false, err
+	}
+	_, ok := m[stringify(key)]
+	return ok, nil
+}
+
+// until returns the numbers from 0 to count-1 for counted loops, e.g. {{range until 3}}.
+// The count may be given as a number of any type (JSON arguments are float64) or as a numeric string;
+// nil is a count of 0.
+func until(count interface{}) ([]int, error) {
+	n, err := toInt(count)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxUntilCount {
+		return nil, fmt.Errorf("count %d exceeds the maximum of %d", n, maxUntilCount)
+	}
+	result := make([]int, 0, max(n, 0))
+	for i := 0; i < n; i++ {
+		result = append(result, i)
+	}
+	return result, nil
+}
+
+// enumerate pairs the elements of the list with their indexes,
+// e.g. {{range $step := enumerate .steps}}{{$step.Number}}. {{$step.Value}}{{end}}.
+func enumerate(collection interface{}) ([]Enumerated, error) {
+	items, err := toList(collection)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Enumerated, 0, len(items))
+	for i, item := range items {
+		result = append(result, Enumerated{Index: i, Number: i + 1, Value: item})
+	}
+	return result, nil
+}
+
+// toList copies the elements of a slice or an array; nil is an empty list.
+func toList(collection interface{}) ([]interface{}, error) {
+	if collection == nil {
+		return []interface{}{}, nil
+	}
+	v := reflect.ValueOf(collection)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a list, got %T", collection)
+	}
+	result := make([]interface{}, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		result = append(result, v.Index(i).Interface())
+	}
+	return result, nil
+}
+
+// toMap returns the entries of a map with string keys without modifying it; nil is an empty map.
+func toMap(collection interface{}) (map[string]interface{}, error) {
+	if m, ok := collection.(map[string]interface{}); ok {
+		return m, nil
+	}
+	if collection == nil {
+		return map[string]interface{}{}, nil
+	}
+	v := reflect.ValueOf(collection)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("expected a map with string keys, got %T", collection)
+	}
+	result := make(map[string]interface{}, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		result[iter.Key().String()] = iter.Value().Interface()
+	}
+	return result, nil
+}
+
+func toInt(value interface{}) (int, error) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Invalid:
+		return 0, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); f == float64(int(f)) {
+			return int(f), nil
+		}
+	case reflect.String:
+		if n, err := strconv.Atoi(v.String()); err == nil {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("expected a whole number, got %v (%T)", value, value)
+}
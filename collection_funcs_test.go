@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type CollectionFuncsTestSuite struct {
+	suite.Suite
+}
+
+func TestCollectionFuncsTestSuite(t *testing.T) {
+	suite.Run(t, new(CollectionFuncsTestSuite))
+}
+
+// jsonValue decodes a JSON value the way JSON arguments are parsed
+func (s *CollectionFuncsTestSuite) jsonValue(text string) interface{} {
+	var value interface{}
+	require.NoError(s.T(), json.Unmarshal([]byte(text), &value))
+	return value
+}
+
+// TestList tests building lists
+func (s *CollectionFuncsTestSuite) TestList() {
+	assert.Equal(s.T(), []interface{}{"a", 1, nil}, list("a", 1, nil))
+	assert.Equal(s.T(), []interface{}{}, list())
+}
+
+// TestAppend tests that append copies the list and accepts JSON and typed lists
+func (s *CollectionFuncsTestSuite) TestAppend() {
+	original := s.jsonValue(`["a", "b"]`)
+	result, err := appendList(original, "c", 4)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []interface{}{"a", "b", "c", 4}, result)
+	assert.Equal(s.T(), []interface{}{"a", "b"}, original, "input must not be modified")
+
+	result, err = appendList([]string{"x"}, "y")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []interface{}{"x", "y"}, result)
+
+	result, err = appendList(nil, "a")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []interface{}{"a"}, result)
+
+	_, err = appendList("abc", "d")
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "expected a list, got string")
+}
+
+// TestSortAlpha tests sorting elements of any type as strings
+func (s *CollectionFuncsTestSuite) TestSortAlpha() {
+	original := s.jsonValue(`["pear", "apple", 10, 2]`)
+	result, err := sortAlpha(original)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"10", "2", "apple", "pear"}, result)
+	assert.Equal(s.T(), "pear", original.([]interface{})[0], "input must not be modified")
+
+	result, err = sortAlpha(nil)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{}, result)
+
+	_, err = sortAlpha(map[string]interface{}{})
+	require.Error(s.T(), err)
+}
+
+// TestKeysAndValues tests that keys and values are returned in the order of sorted keys
+func (s *CollectionFuncsTestSuite) TestKeysAndValues() {
+	m := s.jsonValue(`{"tier": 2, "region": "eu", "active": true}`)
+	keysResult, err := keys(m)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"active", "region", "tier"}, keysResult)
+	valuesResult, err := values(m)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []interface{}{true, "eu", float64(2)}, valuesResult)
+
+	keysResult, err = keys(map[string]int{"b": 1, "a": 2})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"a", "b"}, keysResult)
+	valuesResult, err = values(map[string]int{"b": 1, "a": 2})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []interface{}{2, 1}, valuesResult)
+
+	keysResult, err = keys(nil)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{}, keysResult)
+	valuesResult, err = values(nil)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []interface{}{}, valuesResult)
+
+	_, err = keys([]interface{}{"a"})
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "expected a map with string keys")
+	_, err = values(map[int]string{1: "a"})
+	require.Error(s.T(), err)
+}
+
+// TestHasKey tests key presence regardless of the value
+func (s *CollectionFuncsTestSuite) TestHasKey() {
+	m := s.jsonValue(`{"owner": null, "title": "Fix"}`)
+	for key, expected := range map[string]bool{"owner": true, "title": true, "due": false} {
+		found, err := hasKey(m, key)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), expected, found, key)
+	}
+
+	found, err := hasKey(nil, "owner")
+	require.NoError(s.T(), err)
+	assert.False(s.T(), found)
+
+	_, err = hasKey("owner", "owner")
+	require.Error(s.T(), err)
+}
+
+// TestUntil tests counted loops with counts of any numeric type
+func (s *CollectionFuncsTestSuite) TestUntil() {
+	tests := []struct {
+		name     string
+		count    interface{}
+		expected []int
+	}{
+		{name: "int", count: 3, expected: []int{0, 1, 2}},
+		{name: "JSON number", count: s.jsonValue(`2`), expected: []int{0, 1}},
+		{name: "numeric string", count: "2", expected: []int{0, 1}},
+		{name: "zero", count: 0, expected: []int{}},
+		{name: "negative", count: -2, expected: []int{}},
+		{name: "nil", count: nil, expected: []int{}},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			result, err := until(tt.count)
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), tt.expected, result)
+		})
+	}
+
+	for _, count := range []interface{}{2.5, "many", []interface{}{}, maxUntilCount + 1} {
+		_, err := until(count)
+		assert.Error(s.T(), err, "%v", count)
+	}
+}
+
+// TestEnumerate tests pairing list elements with their positions
+func (s *CollectionFuncsTestSuite) TestEnumerate() {
+	result, err := enumerate(s.jsonValue(`["a", {"b": 1}]`))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []Enumerated{
+		{Index: 0, Number: 1, Value: "a"},
+		{Index: 1, Number: 2, Value: map[string]interface{}{"b": float64(1)}},
+	}, result)
+
+	result, err = enumerate(nil)
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), result)
+
+	_, err = enumerate(42)
+	require.Error(s.T(), err)
+}
+
+// TestCollectionsFixture renders a template combining the helpers with JSON arguments
+// and checks that the arguments passed into the helpers are still extracted
+func (s *CollectionFuncsTestSuite) TestCollectionsFixture() {
+	promptsDir := "./testdata/collections"
+	var buf bytes.Buffer
+	require.NoError(s.T(), runFixtureTests(&buf, promptsDir, nil, "team_digest",
+		defaultFixturesDir(promptsDir, "team_digest"), nil, true, defaultBuiltinDateName))
+	assert.Equal(s.T(), "✓ basic - Passed\n", removeANSIColors(buf.String()))
+
+	parser := &PromptsParser{}
+	tmpl, err := parser.ParseDir(promptsDir)
+	require.NoError(s.T(), err)
+	args, err := parser.ExtractPromptArgumentsFromTemplate(tmpl, "team_digest.tmpl")
+	require.NoError(s.T(), err)
+	assert.ElementsMatch(s.T(),
+		[]string{"team", "members", "tasks", "settings", "label", "checkpoints", "archived"}, args)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	collectionsFileName = "collections.yaml"
+	otherCollectionName = "Other"
+)
+
+// PromptCollections is the optional collections.yaml manifest that groups prompts into named,
+// ordered collections for presentation in MCP clients.
+type PromptCollections struct {
+	Collections []PromptCollection `yaml:"collections"`
+}
+
+// PromptCollection is a named group of prompts listed in declaration order.
+type PromptCollection struct {
+	Name    string            `yaml:"name"`
+	Icon    string            `yaml:"icon"` // default icon of the prompts that set none with @icon
+	Prompts []CollectionEntry `yaml:"prompts"`
+}
+
+// CollectionEntry references a prompt by name and optionally gives it a short human-readable label.
+// In YAML it may be written either as a plain prompt name or as a mapping with "name" and "label" keys.
+type CollectionEntry struct {
+	Name  string `yaml:"name"`
+	Label string `yaml:"label"`
+}
+
+// UnmarshalYAML allows an entry to be specified as a scalar prompt name.
+func (e *CollectionEntry) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		e.Name = value.Value
+		return nil
+	}
+	type plain CollectionEntry
+	return value.Decode((*plain)(e))
+}
+
+// collectionGroup is a resolved collection with the names of prompts that belong to it.
+type collectionGroup struct {
+	Name    string
+	Prompts []string
+}
+
+// loadPromptCollections reads the collections manifest from the prompts directory.
+// It returns nil without an error if the manifest does not exist.
+func loadPromptCollections(promptsDir string) (*PromptCollections, error) {
+	content, err := os.ReadFile(filepath.Join(promptsDir, collectionsFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read collections manifest: %w", err)
+	}
+
+	var collections PromptCollections
+	if err = yaml.Unmarshal(content, &collections); err != nil {
+		return nil, fmt.Errorf("parse collections manifest: %w", err)
+	}
+
+	seen := make(map[string]string)
+	for i := range collections.Collections {
+		collection := &collections.Collections[i]
+		collection.Name = strings.TrimSpace(collection.Name)
+		if collection.Name == "" {
+			return nil, fmt.Errorf("collection #%d has no name", i+1)
+		}
+		collection.Icon = strings.TrimSpace(collection.Icon)
+		for j := range collection.Prompts {
+			entry := &collection.Prompts[j]
+			entry.Name = strings.TrimSuffix(strings.TrimSpace(entry.Name), templateExt)
+			if entry.Name == "" {
+				return nil, fmt.Errorf("collection %q has an entry with no prompt name", collection.Name)
+			}
+			if prev, ok := seen[entry.Name]; ok {
+				return nil, fmt.Errorf("prompt %q is listed in both %q and %q collections", entry.Name, prev, collection.Name)
+			}
+			seen[entry.Name] = collection.Name
+		}
+	}
+
+	return &collections, nil
+}
+
+// Lookup returns the collection name and manifest entry for the given prompt.
+func (pc *PromptCollections) Lookup(promptName string) (collectionName string, entry CollectionEntry, ok bool) {
+	if pc == nil {
+		return "", CollectionEntry{}, false
+	}
+	for _, collection := range pc.Collections {
+		for _, e := range collection.Prompts {
+			if e.Name == promptName {
+				return collection.Name, e, true
+			}
+		}
+	}
+	return "", CollectionEntry{}, false
+}
+
+// Group distributes prompt names into collections in manifest order.
+// Prompts absent from the manifest are placed, sorted by name, into a trailing "Other" group.
+// Manifest entries that reference unknown prompts and empty collections are omitted.
+func (pc *PromptCollections) Group(promptNames []string) []collectionGroup {
+	var groups []collectionGroup
+	grouped := make(map[string]bool, len(promptNames))
+	if pc != nil {
+		for _, collection := range pc.Collections {
+			group := collectionGroup{Name: collection.Name}
+			for _, entry := range collection.Prompts {
+				if slices.Contains(promptNames, entry.Name) {
+					group.Prompts = append(group.Prompts, entry.Name)
+					grouped[entry.Name] = true
+				}
+			}
+			if len(group.Prompts) > 0 {
+				groups = append(groups, group)
+			}
+		}
+	}
+
+	other := collectionGroup{Name: otherCollectionName}
+	for _, name := range promptNames {
+		if !grouped[name] {
+			other.Prompts = append(other.Prompts, name)
+		}
+	}
+	if len(other.Prompts) > 0 {
+		sort.Strings(other.Prompts)
+		groups = append(groups, other)
+	}
+	return groups
+}
+
+// Order returns the position of each prompt name in the flattened collection grouping.
+func (pc *PromptCollections) Order(promptNames []string) map[string]int {
+	order := make(map[string]int, len(promptNames))
+	for _, group := range pc.Group(promptNames) {
+		for _, name := range group.Prompts {
+			order[name] = len(order)
+		}
+	}
+	return order
+}
+
+// StaleEntries returns "collection/prompt" references from the manifest to prompts that do not exist.
+func (pc *PromptCollections) StaleEntries(promptNames []string) []string {
+	if pc == nil {
+		return nil
+	}
+	var stale []string
+	for _, collection := range pc.Collections {
+		for _, entry := range collection.Prompts {
+			if !slices.Contains(promptNames, entry.Name) {
+				stale = append(stale, collection.Name+"/"+entry.Name)
+			}
+		}
+	}
+	return stale
+}
+
+// WithNameStyle returns a copy of the manifest with the prompt names of its entries in the name style,
+// so entries match the names of the prompts registered in that style.
+func (pc *PromptCollections) WithNameStyle(style NameStyle) *PromptCollections {
+	if pc == nil {
+		return nil
+	}
+	styled := &PromptCollections{Collections: make([]PromptCollection, len(pc.Collections))}
+	for i, collection := range pc.Collections {
+		styled.Collections[i] = PromptCollection{
+			Name: collection.Name, Icon: collection.Icon, Prompts: slices.Clone(collection.Prompts),
+		}
+		for j := range styled.Collections[i].Prompts {
+			styled.Collections[i].Prompts[j].Name = style.PromptName(styled.Collections[i].Prompts[j].Name)
+		}
+	}
+	return styled
+}
+
+// Title returns the client-facing title of a prompt, prefixed with its collection name.
+// Prompts absent from the manifest have no title.
+func (pc *PromptCollections) Title(promptName string) string {
+	collectionName, entry, ok := pc.Lookup(promptName)
+	if !ok {
+		return ""
+	}
+	label := entry.Label
+	if label == "" {
+		label = promptName
+	}
+	return collectionName + ": " + label
+}
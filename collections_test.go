@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type PromptCollectionsTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+func TestPromptCollectionsTestSuite(t *testing.T) {
+	suite.Run(t, new(PromptCollectionsTestSuite))
+}
+
+func (s *PromptCollectionsTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+}
+
+func (s *PromptCollectionsTestSuite) writeManifest(content string) {
+	err := os.WriteFile(filepath.Join(s.tempDir, collectionsFileName), []byte(content), 0644)
+	require.NoError(s.T(), err, "Failed to write collections manifest")
+}
+
+// TestLoadPromptCollections tests parsing of the collections manifest
+func (s *PromptCollectionsTestSuite) TestLoadPromptCollections() {
+	s.writeManifest(`
+collections:
+  - name: Git
+    prompts:
+      - name: git_commit
+        label: Commit message
+      - git_pr_description.tmpl
+  - name: Reviews
+    prompts:
+      - code_review
+`)
+	collections, err := loadPromptCollections(s.tempDir)
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), collections)
+
+	assert.Equal(s.T(), []PromptCollection{
+		{Name: "Git", Prompts: []CollectionEntry{
+			{Name: "git_commit", Label: "Commit message"},
+			{Name: "git_pr_description"},
+		}},
+		{Name: "Reviews", Prompts: []CollectionEntry{{Name: "code_review"}}},
+	}, collections.Collections)
+}
+
+// TestLoadPromptCollectionsMissing tests that a missing manifest is not an error
+func (s *PromptCollectionsTestSuite) TestLoadPromptCollectionsMissing() {
+	collections, err := loadPromptCollections(s.tempDir)
+	require.NoError(s.T(), err)
+	assert.Nil(s.T(), collections)
+
+	// A nil manifest puts everything into the Other group
+	assert.Equal(s.T(), []collectionGroup{{Name: otherCollectionName, Prompts: []string{"a", "b"}}},
+		collections.Group([]string{"b", "a"}))
+	assert.Empty(s.T(), collections.Title("a"))
+	assert.Empty(s.T(), collections.StaleEntries([]string{"a"}))
+}
+
+// TestLoadPromptCollectionsErrorCases tests invalid manifests
+func (s *PromptCollectionsTestSuite) TestLoadPromptCollectionsErrorCases() {
+	tests := []struct {
+		name        string
+		content     string
+		expectedErr string
+	}{
+		{
+			name:        "invalid YAML",
+			content:     "collections: [",
+			expectedErr: "parse collections manifest",
+		},
+		{
+			name:        "collection without name",
+			content:     "collections:\n  - prompts: [a]\n",
+			expectedErr: "collection #1 has no name",
+		},
+		{
+			name:        "entry without name",
+			content:     "collections:\n  - name: Git\n    prompts:\n      - label: Commit\n",
+			expectedErr: `collection "Git" has an entry with no prompt name`,
+		},
+		{
+			name:        "prompt listed twice",
+			content:     "collections:\n  - name: Git\n    prompts: [a]\n  - name: Reviews\n    prompts: [a]\n",
+			expectedErr: `prompt "a" is listed in both "Git" and "Reviews" collections`,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.writeManifest(tt.content)
+			_, err := loadPromptCollections(s.tempDir)
+			require.Error(s.T(), err)
+			assert.Contains(s.T(), err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+// TestGroupAndOrder tests collection ordering and the trailing Other group
+func (s *PromptCollectionsTestSuite) TestGroupAndOrder() {
+	collections := &PromptCollections{Collections: []PromptCollection{
+		{Name: "Reviews", Prompts: []CollectionEntry{{Name: "review_security"}, {Name: "review_style"}}},
+		{Name: "Git", Prompts: []CollectionEntry{{Name: "git_pr"}, {Name: "git_commit"}, {Name: "git_missing"}}},
+		{Name: "Empty", Prompts: []CollectionEntry{{Name: "missing"}}},
+	}}
+	promptNames := []string{"git_commit", "git_pr", "greeting", "review_security", "review_style", "apology"}
+
+	assert.Equal(s.T(), []collectionGroup{
+		{Name: "Reviews", Prompts: []string{"review_security", "review_style"}},
+		{Name: "Git", Prompts: []string{"git_pr", "git_commit"}},
+		{Name: otherCollectionName, Prompts: []string{"apology", "greeting"}},
+	}, collections.Group(promptNames))
+
+	assert.Equal(s.T(), map[string]int{
+		"review_security": 0,
+		"review_style":    1,
+		"git_pr":          2,
+		"git_commit":      3,
+		"apology":         4,
+		"greeting":        5,
+	}, collections.Order(promptNames))
+
+	assert.Equal(s.T(), []string{"Git/git_missing", "Empty/missing"}, collections.StaleEntries(promptNames))
+}
+
+// TestTitle tests collection-prefixed prompt titles
+func (s *PromptCollectionsTestSuite) TestTitle() {
+	collections := &PromptCollections{Collections: []PromptCollection{
+		{Name: "Git", Prompts: []CollectionEntry{{Name: "git_commit", Label: "Commit message"}, {Name: "git_pr"}}},
+	}}
+	assert.Equal(s.T(), "Git: Commit message", collections.Title("git_commit"))
+	assert.Equal(s.T(), "Git: git_pr", collections.Title("git_pr"))
+	assert.Empty(s.T(), collections.Title("greeting"))
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/fatih/color"
 )
@@ -16,6 +17,24 @@ const (
 
 var colorModesCommaSeparatedList = fmt.Sprintf("%s, %s, %s", colorModeAuto, colorModeAlways, colorModeNever)
 
+// OutputStyle selects how statuses are marked in the CLI output.
+type OutputStyle string
+
+const (
+	// outputStyleDefault marks statuses with unicode icons.
+	outputStyleDefault OutputStyle = "default"
+	// outputStylePlain marks statuses with words at the start of lines, e.g. "ERROR:", without icons and colors,
+	// so that screen readers read the output naturally.
+	outputStylePlain OutputStyle = "plain"
+	// outputStyleASCII marks statuses with ASCII icons, e.g. "[x]", for terminals and log viewers without unicode.
+	outputStyleASCII OutputStyle = "ascii"
+)
+
+var outputStylesCommaSeparatedList = fmt.Sprintf("%s, %s, %s", outputStyleDefault, outputStylePlain, outputStyleASCII)
+
+// outputStyle is the style of the CLI output, set by initializeColors.
+var outputStyle = outputStyleDefault
+
 // Color utility functions for consistent styling
 var (
 	// Status indicators
@@ -34,8 +53,10 @@ var (
 	pathText     func(...interface{}) string
 )
 
-// initializeColors sets up color functions based on color mode
-func initializeColors(colorMode ColorMode) {
+// initializeColors sets up color functions based on color mode and output style.
+// The plain style disables colors whatever the color mode.
+func initializeColors(colorMode ColorMode, style OutputStyle) {
+	outputStyle = style
 	switch colorMode {
 	case colorModeNever:
 		color.NoColor = true
@@ -47,6 +68,9 @@ func initializeColors(colorMode ColorMode) {
 	default:
 		// Default to auto
 	}
+	if style == outputStylePlain {
+		color.NoColor = true
+	}
 
 	// Initialize color functions
 	successIcon = color.New(color.FgGreen, color.Bold).SprintFunc()
@@ -62,17 +86,50 @@ func initializeColors(colorMode ColorMode) {
 	pathText = color.New(color.FgBlue).SprintFunc()
 
 	// Apply icons with color
-	successIcon = func(args ...interface{}) string {
-		return color.New(color.FgGreen, color.Bold).Sprint("✓")
+	successIcon = styledIcon(style, color.New(color.FgGreen, color.Bold), "✓", "[ok]",
+		func() string { return localize("status.ok_label") })
+	errorIcon = styledIcon(style, color.New(color.FgRed, color.Bold), "✗", "[x]",
+		func() string { return localize("status.error_label") })
+	warningIcon = styledIcon(style, color.New(color.FgYellow, color.Bold), "⚠", "[!]",
+		func() string { return localize("status.warning_label") })
+}
+
+// styledIcon returns the function of a status icon in the style: the glyph, its ASCII replacement,
+// or the word of the status, localized when the icon is printed.
+func styledIcon(style OutputStyle, c *color.Color, glyph string, ascii string, label func() string) func(...interface{}) string {
+	return func(args ...interface{}) string {
+		switch style {
+		case outputStylePlain:
+			return label()
+		case outputStyleASCII:
+			return c.Sprint(ascii)
+		default:
+			return c.Sprint(glyph)
+		}
 	}
-	errorIcon = func(args ...interface{}) string {
-		return color.New(color.FgRed, color.Bold).Sprint("✗")
+}
+
+// displayIcon returns the @icon of a prompt as shown in the CLI output. The styles other than the default
+// leave icons out, as they are emoji that screen readers spell out and that some log viewers mangle.
+func displayIcon(icon string) string {
+	if outputStyle != outputStyleDefault {
+		return ""
 	}
-	warningIcon = func(args ...interface{}) string {
-		return color.New(color.FgYellow, color.Bold).Sprint("⚠")
+	return icon
+}
+
+// ParseOutputStyle parses the value of --output-style; the empty string is the default style.
+func ParseOutputStyle(value string) (OutputStyle, error) {
+	switch style := OutputStyle(strings.ToLower(strings.TrimSpace(value))); style {
+	case "":
+		return outputStyleDefault, nil
+	case outputStyleDefault, outputStylePlain, outputStyleASCII:
+		return style, nil
+	default:
+		return "", fmt.Errorf("invalid output style %q, must be one of: %s", value, outputStylesCommaSeparatedList)
 	}
 }
 
 func init() {
-	initializeColors(colorModeAuto)
+	initializeColors(colorModeAuto, outputStyleDefault)
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type OutputStyleTestSuite struct {
+	suite.Suite
+	noColor bool
+}
+
+func TestOutputStyleTestSuite(t *testing.T) {
+	suite.Run(t, new(OutputStyleTestSuite))
+}
+
+func (s *OutputStyleTestSuite) SetupTest() {
+	s.noColor = color.NoColor
+}
+
+func (s *OutputStyleTestSuite) TearDownTest() {
+	initializeColors(colorModeAuto, outputStyleDefault)
+	color.NoColor = s.noColor
+}
+
+// TestPlainValidate tests the exact output of validate in the plain style, which has no icons and no colors
+func (s *OutputStyleTestSuite) TestPlainValidate() {
+	initializeColors(colorModeAlways, outputStylePlain)
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), validateTemplates(&buf, "./testdata", nil, "", validateOptions{}))
+	assert.Equal(s.T(), "OK: conditional_greeting.tmpl - Valid\n"+
+		"OK: greeting.tmpl - Valid\n"+
+		"OK: greeting_with_partials.tmpl - Valid\n"+
+		"OK: logical_operators.tmpl - Valid\n"+
+		"OK: multiple_partials.tmpl - Valid\n"+
+		"OK: range_scalars.tmpl - Valid\n"+
+		"OK: range_structs.tmpl - Valid\n"+
+		"OK: with_object.tmpl - Valid\n", buf.String())
+
+	promptsDir := s.T().TempDir()
+	require.NoError(s.T(), os.WriteFile(filepath.Join(promptsDir, "broken.tmpl"), []byte("Hello {{.name | nofunc}}"), 0644))
+	buf.Reset()
+	require.Error(s.T(), validateTemplates(&buf, promptsDir, nil, "", validateOptions{}))
+	assert.Regexp(s.T(), `^ERROR: `, buf.String())
+}
+
+// TestPlainList tests the exact output of list in the plain style
+func (s *OutputStyleTestSuite) TestPlainList() {
+	initializeColors(colorModeAlways, outputStylePlain)
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), listTemplates(&buf, "./testdata", listOptions{}))
+	assert.Equal(s.T(), "conditional_greeting.tmpl\n"+
+		"greeting.tmpl\n"+
+		"greeting_with_partials.tmpl\n"+
+		"logical_operators.tmpl\n"+
+		"multiple_partials.tmpl\n"+
+		"range_scalars.tmpl\n"+
+		"range_structs.tmpl\n"+
+		"with_object.tmpl\n", buf.String())
+
+	buf.Reset()
+	require.NoError(s.T(), listTemplates(&buf, "./testdata", listOptions{verbose: true}))
+	assert.Contains(s.T(), buf.String(), "conditional_greeting.tmpl\n"+
+		"  Description: Conditional greeting template\n"+
+		"  Variables: name, show_extra_message\n")
+	assert.NotContains(s.T(), buf.String(), "\x1b[", "the plain style has no colors")
+
+	promptsDir := s.T().TempDir()
+	require.NoError(s.T(), os.WriteFile(filepath.Join(promptsDir, "experiment.tmpl"),
+		[]byte("{{/* Run an experiment\n@icon 🧪\n*/}}\nExperiment {{.name}}"), 0644))
+	buf.Reset()
+	require.NoError(s.T(), listTemplates(&buf, promptsDir, listOptions{}))
+	assert.Equal(s.T(), "experiment.tmpl\n", buf.String(), "prompt icons are left out")
+}
+
+// TestASCII tests that the ascii style keeps the layout with ASCII icons only
+func (s *OutputStyleTestSuite) TestASCII() {
+	initializeColors(colorModeNever, outputStyleASCII)
+
+	brokenDir, iconDir := s.T().TempDir(), s.T().TempDir()
+	require.NoError(s.T(), os.WriteFile(filepath.Join(brokenDir, "broken.tmpl"), []byte("Hello {{.name | nofunc}}"), 0644))
+	require.NoError(s.T(), os.WriteFile(filepath.Join(iconDir, "experiment.tmpl"),
+		[]byte("{{/* Run an experiment\n@icon 🧪\n*/}}\nExperiment {{.name}}"), 0644))
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), validateTemplates(&buf, "./testdata", nil, "", validateOptions{}))
+	assert.Contains(s.T(), buf.String(), "[ok] greeting.tmpl - Valid\n")
+	require.Error(s.T(), validateTemplates(&buf, brokenDir, nil, "", validateOptions{}))
+	assert.Contains(s.T(), buf.String(), "[x] ")
+	require.NoError(s.T(), listTemplates(&buf, "./testdata", listOptions{verbose: true}))
+	require.NoError(s.T(), listTemplates(&buf, iconDir, listOptions{verbose: true}))
+	printErrorLines(&buf, assert.AnError)
+
+	for i, r := range buf.String() {
+		if !assert.LessOrEqual(s.T(), r, rune(0x7F), "non-ASCII character at %d of:\n%s", i, buf.String()) {
+			break
+		}
+	}
+}
+
+// TestParseOutputStyle tests the values of --output-style
+func (s *OutputStyleTestSuite) TestParseOutputStyle() {
+	for value, expected := range map[string]OutputStyle{
+		"": outputStyleDefault, "default": outputStyleDefault, " Plain ": outputStylePlain, "ascii": outputStyleASCII,
+	} {
+		style, err := ParseOutputStyle(value)
+		require.NoError(s.T(), err, value)
+		assert.Equal(s.T(), expected, style, value)
+	}
+	_, err := ParseOutputStyle("fancy")
+	assert.EqualError(s.T(), err, `invalid output style "fancy", must be one of: default, plain, ascii`)
+}
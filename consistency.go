@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// consistencyFileName is the optional configuration of validate --consistency in the prompts directory.
+	consistencyFileName = "consistency.yaml"
+
+	// widelyUsedArgTemplates is the number of templates from which an argument is widely used, so that
+	// a close name used by a single template is reported as an outlier.
+	widelyUsedArgTemplates = 3
+)
+
+// Output formats of validate --consistency.
+const (
+	consistencyFormatText = "text"
+	consistencyFormatJSON = "json"
+)
+
+// defaultArgSynonyms are groups of argument names that mean the same, clustered without configuration.
+var defaultArgSynonyms = [][]string{
+	{"lang", "language"},
+	{"repo", "repository"},
+	{"desc", "description"},
+	{"msg", "message"},
+	{"dir", "directory"},
+	{"env", "environment"},
+}
+
+// ConsistencyConfig is the consistency.yaml file: synonyms extend the default groups of names meaning the same,
+// and ignored names are left out of the check, e.g. a repository argument that is a URL and not a repo name.
+type ConsistencyConfig struct {
+	Synonyms [][]string `yaml:"synonyms"`
+	Ignore   []string   `yaml:"ignore"`
+}
+
+// ArgUsage is an argument name with the templates using it.
+type ArgUsage struct {
+	Name      string   `json:"name"`
+	Templates []string `json:"templates"`
+}
+
+// ArgCluster is a group of argument names that likely mean the same, the most used first.
+type ArgCluster struct {
+	Args []ArgUsage `json:"args"`
+}
+
+// ArgOutlier is an argument used by a single template whose name closely matches a widely used argument.
+type ArgOutlier struct {
+	Name       string `json:"name"`
+	Template   string `json:"template"`
+	Suggestion string `json:"suggestion"`
+	Templates  int    `json:"suggestion_templates"` // number of templates using the suggestion
+}
+
+// ConsistencyReport is the result of the consistency check of the argument names of all templates.
+type ConsistencyReport struct {
+	Arguments int          `json:"arguments"`
+	Templates int          `json:"templates"`
+	Clusters  []ArgCluster `json:"clusters"`
+	Outliers  []ArgOutlier `json:"outliers"`
+	Ignored   []string     `json:"ignored,omitempty"`
+}
+
+// loadConsistencyConfig reads the consistency configuration from the prompts directory.
+// It returns an empty configuration if the file does not exist.
+func loadConsistencyConfig(promptsDir string) (*ConsistencyConfig, error) {
+	var cfg ConsistencyConfig
+	content, err := os.ReadFile(filepath.Join(promptsDir, consistencyFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &cfg, nil
+		}
+		return nil, fmt.Errorf("read consistency config: %w", err)
+	}
+	if err = yaml.Unmarshal(content, &cfg); err != nil {
+		return nil, fmt.Errorf("parse consistency config: %w", err)
+	}
+	for i, group := range cfg.Synonyms {
+		if len(group) < 2 {
+			return nil, fmt.Errorf("synonym group #%d of %s needs at least two names", i+1, consistencyFileName)
+		}
+		for j := range group {
+			group[j] = strings.ToLower(strings.TrimSpace(group[j]))
+		}
+	}
+	for i := range cfg.Ignore {
+		cfg.Ignore[i] = strings.ToLower(strings.TrimSpace(cfg.Ignore[i]))
+	}
+	return &cfg, nil
+}
+
+// checkArgConsistency builds the inventory of the argument names of the templates and reports the names
+// that are likely spelled differently for the same meaning.
+func checkArgConsistency(
+	parser *PromptsParser, promptsDir string, templateNames []string, cfg *ConsistencyConfig,
+) (*ConsistencyReport, error) {
+	tmpl, err := parser.ParseDir(promptsDir)
+	if err != nil {
+		return nil, fmt.Errorf("parse prompts directory: %w", err)
+	}
+	inventory := make(map[string][]string) // templates by argument name
+	for _, name := range templateNames {
+		args, err := parser.ExtractPromptArgumentsFromTemplate(tmpl, name)
+		if err != nil {
+			return nil, fmt.Errorf("template %q: %w", name, err)
+		}
+		frontmatter, err := loadPromptFrontmatter(filepath.Join(promptsDir, name), parser.dateName())
+		if err != nil {
+			return nil, fmt.Errorf("template %q: frontmatter: %w", name, err)
+		}
+		for _, arg := range frontmatter.ExcludeConstants(args) {
+			inventory[arg] = append(inventory[arg], name)
+		}
+	}
+
+	report := &ConsistencyReport{Templates: len(templateNames), Clusters: []ArgCluster{}, Outliers: []ArgOutlier{}}
+	for _, name := range cfg.Ignore {
+		if _, ok := inventory[name]; ok {
+			report.Ignored = append(report.Ignored, name)
+			delete(inventory, name)
+		}
+	}
+	slices.Sort(report.Ignored)
+	report.Arguments = len(inventory)
+
+	synonyms := slices.Concat(defaultArgSynonyms, cfg.Synonyms)
+	for _, group := range clusterArgNames(slices.Sorted(maps.Keys(inventory)), synonyms) {
+		cluster := ArgCluster{}
+		for _, name := range group {
+			templates := slices.Clone(inventory[name])
+			slices.Sort(templates)
+			cluster.Args = append(cluster.Args, ArgUsage{Name: name, Templates: templates})
+		}
+		slices.SortStableFunc(cluster.Args, func(a, b ArgUsage) int { return len(b.Templates) - len(a.Templates) })
+		report.Clusters = append(report.Clusters, cluster)
+
+		widelyUsed := cluster.Args[0]
+		if len(widelyUsed.Templates) < widelyUsedArgTemplates {
+			continue
+		}
+		for _, usage := range cluster.Args[1:] {
+			if len(usage.Templates) == 1 {
+				report.Outliers = append(report.Outliers, ArgOutlier{
+					Name: usage.Name, Template: usage.Templates[0],
+					Suggestion: widelyUsed.Name, Templates: len(widelyUsed.Templates),
+				})
+			}
+		}
+	}
+	slices.SortStableFunc(report.Clusters, func(a, b ArgCluster) int {
+		return len(b.Args[0].Templates) - len(a.Args[0].Templates)
+	})
+	return report, nil
+}
+
+// clusterArgNames groups the sorted names that are synonyms or close by edit distance, transitively.
+// Only groups of at least two names are returned, in the order of their first name.
+func clusterArgNames(names []string, synonyms [][]string) [][]string {
+	parent := make(map[string]string, len(names))
+	var find func(string) string
+	find = func(name string) string {
+		if parent[name] != name {
+			parent[name] = find(parent[name])
+		}
+		return parent[name]
+	}
+	union := func(a, b string) {
+		if rootA, rootB := find(a), find(b); rootA != rootB {
+			parent[max(rootA, rootB)] = min(rootA, rootB)
+		}
+	}
+	for _, name := range names {
+		parent[name] = name
+	}
+
+	for i, a := range names {
+		for _, b := range names[i+1:] {
+			if closeArgNames(a, b) {
+				union(a, b)
+			}
+		}
+	}
+	for _, group := range synonyms {
+		var present []string
+		for _, name := range group {
+			if _, ok := parent[name]; ok {
+				present = append(present, name)
+			}
+		}
+		for _, name := range present[min(1, len(present)):] {
+			union(present[0], name)
+		}
+	}
+
+	groups := make(map[string][]string)
+	for _, name := range names {
+		root := find(name)
+		groups[root] = append(groups[root], name)
+	}
+	var clusters [][]string
+	for _, name := range names {
+		if group := groups[name]; len(group) > 1 {
+			clusters = append(clusters, group)
+		}
+	}
+	return clusters
+}
+
+// closeArgNames reports whether two argument names likely are spellings of the same name: they are equal
+// without separators, or within an edit distance of 1, or 2 for names of at least 8 characters.
+// Names shorter than 5 characters, like name and same, are too short to tell.
+func closeArgNames(a, b string) bool {
+	strip := strings.NewReplacer("_", "", "-", "")
+	if strip.Replace(a) == strip.Replace(b) {
+		return true
+	}
+	shortest := min(utf8.RuneCountInString(a), utf8.RuneCountInString(b))
+	if shortest < 5 {
+		return false
+	}
+	maxDistance := 1
+	if shortest >= 8 {
+		maxDistance = 2
+	}
+	return editDistance(a, b) <= maxDistance
+}
+
+// printConsistencyReport writes the report in the format, text or json.
+func printConsistencyReport(w io.Writer, report *ConsistencyReport, format string) error {
+	switch format {
+	case "", consistencyFormatText:
+	case consistencyFormatJSON:
+		encoded, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal consistency report: %w", err)
+		}
+		mustFprintf(w, "%s\n", encoded)
+		return nil
+	default:
+		return fmt.Errorf("invalid format %q, must be one of: %s, %s", format, consistencyFormatText, consistencyFormatJSON)
+	}
+
+	for _, cluster := range report.Clusters {
+		usages := make([]string, 0, len(cluster.Args))
+		for _, usage := range cluster.Args {
+			usages = append(usages, highlightText(usage.Name)+" ("+pluralize(len(usage.Templates), "template", "templates")+")")
+		}
+		mustFprintf(w, "%s %s - %s\n", warningIcon(), strings.Join(usages, ", "), localize("consistency.consider_unifying"))
+	}
+	for _, outlier := range report.Outliers {
+		mustFprintf(w, "%s %s\n", warningIcon(), localize("consistency.outlier",
+			outlier.Name, templateText(outlier.Template), outlier.Suggestion, outlier.Templates))
+	}
+	if len(report.Ignored) > 0 {
+		mustFprintf(w, "%s\n", infoText(localize("consistency.ignored", strings.Join(report.Ignored, ", "))))
+	}
+	if len(report.Clusters) == 0 {
+		mustFprintf(w, "%s %s\n", successIcon(), successText(localize("consistency.clean", report.Arguments, report.Templates)))
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConsistencyTestSuite struct {
+	suite.Suite
+}
+
+func TestConsistencyTestSuite(t *testing.T) {
+	suite.Run(t, new(ConsistencyTestSuite))
+}
+
+func (s *ConsistencyTestSuite) validate(args ...string) (string, error) {
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	app.ErrWriter = &bytes.Buffer{}
+	err := app.Run(context.Background(), append([]string{app.Name, "validate"}, args...))
+	return buf.String(), err
+}
+
+// TestValidateConsistency tests the text report of the fixture, with two clusters and one ignored argument
+func (s *ConsistencyTestSuite) TestValidateConsistency() {
+	output, err := s.validate("./testdata/consistency", "--consistency")
+	assert.ErrorContains(s.T(), err, "found 2 group(s) of argument names to unify")
+	assert.Equal(s.T(), strings.Join([]string{
+		"⚠ branch (3 templates), brnch (1 template) - consider unifying",
+		"⚠ language (3 templates), lang (1 template) - consider unifying",
+		"⚠ brnch is only used by summarize.tmpl and closely matches branch (3 templates)",
+		"⚠ lang is only used by summarize.tmpl and closely matches language (3 templates)",
+		"Ignored arguments: repository",
+		"",
+	}, "\n"), output)
+}
+
+// TestValidateConsistencyJSON tests the JSON report
+func (s *ConsistencyTestSuite) TestValidateConsistencyJSON() {
+	output, err := s.validate("./testdata/consistency", "--consistency", "--format", "json")
+	require.Error(s.T(), err)
+
+	var report ConsistencyReport
+	require.NoError(s.T(), json.Unmarshal([]byte(output), &report))
+	assert.Equal(s.T(), 4, report.Templates)
+	assert.Equal(s.T(), 5, report.Arguments)
+	assert.Equal(s.T(), []string{"repository"}, report.Ignored)
+	require.Len(s.T(), report.Clusters, 2)
+	assert.Equal(s.T(), []ArgUsage{
+		{Name: "branch", Templates: []string{"explain.tmpl", "review.tmpl", "tests.tmpl"}},
+		{Name: "brnch", Templates: []string{"summarize.tmpl"}},
+	}, report.Clusters[0].Args)
+	assert.Equal(s.T(), []ArgOutlier{
+		{Name: "brnch", Template: "summarize.tmpl", Suggestion: "branch", Templates: 3},
+		{Name: "lang", Template: "summarize.tmpl", Suggestion: "language", Templates: 3},
+	}, report.Outliers)
+}
+
+// TestValidateConsistencyClean tests a consistent directory and the invalid flag combinations
+func (s *ConsistencyTestSuite) TestValidateConsistencyClean() {
+	dir := s.T().TempDir()
+	require.NoError(s.T(), os.WriteFile(filepath.Join(dir, "a.tmpl"), []byte("{{/* A */}}\n{{.repo}} {{.id}}"), 0644))
+	require.NoError(s.T(), os.WriteFile(filepath.Join(dir, "b.tmpl"), []byte("{{/* B */}}\n{{.repo}} {{.ids}}"), 0644))
+
+	output, err := s.validate(dir, "--consistency")
+	require.NoError(s.T(), err, "names shorter than 5 characters are not compared by edit distance")
+	assert.Equal(s.T(), "✓ Argument names are consistent (3 arguments in 2 templates)\n", output)
+
+	_, err = s.validate(dir, "a", "--consistency")
+	assert.EqualError(s.T(), err, "--consistency checks all templates and cannot be given a template name")
+	_, err = s.validate(dir, "--format", "json")
+	assert.EqualError(s.T(), err, "--format applies only to the --consistency report")
+	_, err = s.validate(dir, "--consistency", "--format", "xml")
+	assert.ErrorContains(s.T(), err, `invalid format "xml"`)
+}
+
+// TestConsistencyConfig tests custom synonyms and invalid configurations
+func (s *ConsistencyTestSuite) TestConsistencyConfig() {
+	dir := s.T().TempDir()
+	require.NoError(s.T(), os.WriteFile(filepath.Join(dir, "a.tmpl"), []byte("{{/* A */}}\n{{.diff}}"), 0644))
+	require.NoError(s.T(), os.WriteFile(filepath.Join(dir, "b.tmpl"), []byte("{{/* B */}}\n{{.patch}}"), 0644))
+
+	_, err := s.validate(dir, "--consistency")
+	require.NoError(s.T(), err)
+
+	configPath := filepath.Join(dir, consistencyFileName)
+	require.NoError(s.T(), os.WriteFile(configPath, []byte("synonyms:\n  - [Diff, patch]\n"), 0644))
+	output, err := s.validate(dir, "--consistency")
+	require.Error(s.T(), err)
+	assert.Equal(s.T(), "⚠ diff (1 template), patch (1 template) - consider unifying\n", output)
+
+	require.NoError(s.T(), os.WriteFile(configPath, []byte("synonyms:\n  - [diff]\n"), 0644))
+	_, err = s.validate(dir, "--consistency")
+	assert.ErrorContains(s.T(), err, "synonym group #1 of consistency.yaml needs at least two names")
+
+	require.NoError(s.T(), os.WriteFile(configPath, []byte("ignore: {"), 0644))
+	_, err = s.validate(dir, "--consistency")
+	assert.ErrorContains(s.T(), err, "parse consistency config")
+}
+
+// TestClusterArgNames tests the clustering of names by separators, edit distance, and synonyms, transitively
+func (s *ConsistencyTestSuite) TestClusterArgNames() {
+	names := []string{"desc", "description", "descriptions", "file_path", "filepath", "id", "ids", "name", "user"}
+	assert.Equal(s.T(), [][]string{
+		{"desc", "description", "descriptions"},
+		{"file_path", "filepath"},
+	}, clusterArgNames(names, defaultArgSynonyms))
+
+	assert.True(s.T(), closeArgNames("repository", "repsitory"))
+	assert.True(s.T(), closeArgNames("description", "descriptoin"))
+	assert.False(s.T(), closeArgNames("user", "users_list"))
+	assert.False(s.T(), closeArgNames("name", "same"), "one edit apart but too short to tell")
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Contract directives, written in template comments one per line,
+// e.g. {{/* @assert-not-contains "<no value>" */}}. They are only evaluated by the check command
+// and validate --check-contracts; the server ignores them like any other comment.
+const (
+	assertContainsDirective    = "@assert-contains"
+	assertNotContainsDirective = "@assert-not-contains"
+	assertMaxLinesDirective    = "@assert-max-lines"
+	// exampleDirective sets an argument of the render the assertions are evaluated against,
+	// e.g. @example branch: main. Arguments without an example are empty.
+	exampleDirective = "@example"
+)
+
+// contractAssertion is an assertion directive of a template.
+type contractAssertion struct {
+	directive string // one of the assert directives
+	text      string // expected text of the contains directives
+	maxLines  int    // limit of assertMaxLinesDirective
+}
+
+func (a contractAssertion) String() string {
+	if a.directive == assertMaxLinesDirective {
+		return fmt.Sprintf("%s %d", a.directive, a.maxLines)
+	}
+	return fmt.Sprintf("%s %q", a.directive, a.text)
+}
+
+// promptContract is the assertions of a template and the example arguments of the render they check.
+type promptContract struct {
+	assertions []contractAssertion
+	example    map[string]string
+}
+
+// ExtractContract returns the contract declared by the comments of the template, without those of its partials.
+func (pp *PromptsParser) ExtractContract(tmpl *template.Template, templateName string) (*promptContract, error) {
+	targetTemplate := lookupPartial(tmpl, templateName)
+	if targetTemplate == nil {
+		return nil, fmt.Errorf("template %q not found", templateName)
+	}
+	contract := &promptContract{example: make(map[string]string)}
+	var err error
+	walkComments(targetTemplate.Root, func(comment *parse.CommentNode) {
+		text := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(comment.Text, "/*"), "*/"))
+		for _, line := range strings.Split(text, "\n") {
+			if parseErr := contract.parseDirective(strings.TrimSpace(line)); parseErr != nil && err == nil {
+				file, lineNum := nodeLocation(comment)
+				err = fmt.Errorf("%s:%d: %w", file, lineNum, parseErr)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return contract, nil
+}
+
+// parseDirective adds the directive of a comment line to the contract. Other lines are ignored.
+func (c *promptContract) parseDirective(line string) error {
+	directive, value, _ := strings.Cut(line, " ")
+	value = strings.TrimSpace(value)
+	switch directive {
+	case assertContainsDirective, assertNotContainsDirective:
+		text, err := strconv.Unquote(value)
+		if err != nil || text == "" {
+			return fmt.Errorf("%s needs a non-empty quoted text, got %q", directive, value)
+		}
+		c.assertions = append(c.assertions, contractAssertion{directive: directive, text: text})
+	case assertMaxLinesDirective:
+		maxLines, err := strconv.Atoi(value)
+		if err != nil || maxLines <= 0 {
+			return fmt.Errorf("%s needs a positive number of lines, got %q", directive, value)
+		}
+		c.assertions = append(c.assertions, contractAssertion{directive: directive, maxLines: maxLines})
+	case exampleDirective:
+		name, example, ok := strings.Cut(value, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || name == "" {
+			return fmt.Errorf("%s needs an argument like \"name: value\", got %q", directive, value)
+		}
+		c.example[name] = strings.TrimSpace(example)
+	}
+	return nil
+}
+
+// walkComments calls fn for every comment under the node.
+func walkComments(node parse.Node, fn func(*parse.CommentNode)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkComments(child, fn)
+		}
+	case *parse.IfNode:
+		walkComments(n.List, fn)
+		walkComments(n.ElseList, fn)
+	case *parse.RangeNode:
+		walkComments(n.List, fn)
+		walkComments(n.ElseList, fn)
+	case *parse.WithNode:
+		walkComments(n.List, fn)
+		walkComments(n.ElseList, fn)
+	case *parse.CommentNode:
+		fn(n)
+	}
+}
+
+// check evaluates the assertion against the rendered output. Failures quote the offending part of the output.
+func (a contractAssertion) check(output string) error {
+	lines := strings.Split(output, "\n")
+	switch a.directive {
+	case assertContainsDirective:
+		if !strings.Contains(output, a.text) {
+			return fmt.Errorf("not found in the %d line(s) of the output, which starts with %q", len(lines), lines[0])
+		}
+	case assertNotContainsDirective:
+		for i, line := range lines {
+			if strings.Contains(line, a.text) {
+				return fmt.Errorf("found at line %d: %q", i+1, line)
+			}
+		}
+		if strings.Contains(output, a.text) {
+			return errors.New("found spanning several lines of the output")
+		}
+	case assertMaxLinesDirective:
+		if len(lines) > a.maxLines {
+			return fmt.Errorf("the output has %d lines, line %d is %q", len(lines), a.maxLines+1, lines[a.maxLines])
+		}
+	}
+	return nil
+}
+
+// checkContracts renders every template declaring assertions with its example arguments and reports
+// the result of each assertion. It returns the number of failed assertions and of all assertions.
+func checkContracts(
+	w io.Writer, parser *PromptsParser, tmpl *template.Template, promptsDir string, templateNames []string,
+	enableJSONArgs bool,
+) (failed int, total int) {
+	for _, templateName := range templateNames {
+		contract, err := parser.ExtractContract(tmpl, templateName)
+		if err != nil {
+			mustFprintf(w, "%s %s - %s\n", errorIcon(), templateText(templateName), errorText(localize("status.error", err)))
+			failed++
+			total++
+			continue
+		}
+		if len(contract.assertions) == 0 {
+			continue
+		}
+		var rendered bytes.Buffer
+		if err = renderTemplateWithWarnings(
+			&rendered, io.Discard, promptsDir, parser.partialsDirs, templateName, contract.example, nil, nil, enableJSONArgs,
+			parser.dateName(), nil,
+		); err != nil {
+			mustFprintf(w, "%s %s - %s\n", errorIcon(), templateText(templateName),
+				errorText(localize("status.failed", fmt.Errorf("render: %w", err))))
+			failed += len(contract.assertions)
+			total += len(contract.assertions)
+			continue
+		}
+		for _, assertion := range contract.assertions {
+			total++
+			if err = assertion.check(rendered.String()); err != nil {
+				mustFprintf(w, "%s %s %s - %s\n", errorIcon(), templateText(templateName), assertion,
+					errorText(localize("status.failed", err)))
+				failed++
+				continue
+			}
+			mustFprintf(w, "%s %s %s - %s\n", successIcon(), templateText(templateName), assertion,
+				successText(localize("status.passed")))
+		}
+	}
+	return failed, total
+}
+
+// checkContractsOfDir checks the contracts of the templates of the prompts directory, or of one of them.
+func checkContractsOfDir(
+	w io.Writer, promptsDir string, partialsDirs []string, templateName string, enableJSONArgs bool, dateName string,
+) error {
+	templateNames, err := getAvailableTemplates(promptsDir)
+	if err != nil {
+		return err
+	}
+	if templateName != "" {
+		if !strings.HasSuffix(templateName, templateExt) {
+			templateName += templateExt
+		}
+		if !slices.Contains(templateNames, templateName) {
+			return errors.New(localize("validate.template_not_found", templateName, promptsDir))
+		}
+		templateNames = []string{templateName}
+	}
+	if len(templateNames) == 0 {
+		mustFprintf(w, "%s %s\n", warningIcon(), localize("list.no_templates", pathText(promptsDir)))
+		return nil
+	}
+	parser := &PromptsParser{partialsDirs: partialsDirs, extractor: defaultExtractorOptions, builtinDateName: dateName}
+	tmpl, err := parser.ParseDir(promptsDir)
+	if err != nil {
+		return fmt.Errorf("parse prompts directory: %w", err)
+	}
+	failed, total := checkContracts(w, parser, tmpl, promptsDir, templateNames, enableJSONArgs)
+	if total == 0 {
+		mustFprintf(w, "%s\n", localize("check.none"))
+		return nil
+	}
+	if failed > 0 {
+		return errors.New(localize("check.assertions_failed", failed, total))
+	}
+	return nil
+}
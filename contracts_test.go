@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ContractsTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestContractsTestSuite(t *testing.T) {
+	suite.Run(t, new(ContractsTestSuite))
+}
+
+func (s *ContractsTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("summary.tmpl", "{{/* Summarize a branch */}}\n"+
+		"{{/*\n@example branch: main\n@assert-contains \"## Summary\"\n@assert-not-contains \"<no value>\"\n"+
+		"@assert-max-lines 3\n*/}}\n## Summary\nBranch: {{.branch}}")
+	s.writeFile("plain.tmpl", "{{/* Plain */}}\nHello {{.name}}")
+}
+
+func (s *ContractsTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+func (s *ContractsTestSuite) runCheck(args ...string) (string, error) {
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	err := app.Run(context.Background(), append([]string{app.Name, "--color", "never", "check", s.promptsDir}, args...))
+	return buf.String(), err
+}
+
+// TestAssertionsPass tests each assertion type against a render with the example arguments
+func (s *ContractsTestSuite) TestAssertionsPass() {
+	output, err := s.runCheck()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "✓ summary.tmpl @assert-contains \"## Summary\" - Passed\n"+
+		"✓ summary.tmpl @assert-not-contains \"<no value>\" - Passed\n"+
+		"✓ summary.tmpl @assert-max-lines 3 - Passed\n", removeANSIColors(output))
+
+	s.Require().NoError(os.Remove(filepath.Join(s.promptsDir, "summary.tmpl")))
+	output, err = s.runCheck()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "No templates declare contract assertions\n", output)
+}
+
+// TestFailuresShowContext tests that failed assertions quote the offending output and fail the command
+func (s *ContractsTestSuite) TestFailuresShowContext() {
+	s.writeFile("summary.tmpl", "{{/* Summarize a branch */}}\n"+
+		"{{/* @assert-contains \"## Summary\" */}}{{/* @assert-not-contains \"<no value>\" */}}\n"+
+		"{{/* @assert-max-lines 1 */}}\n## Overview\nBranch: {{.branch}}\nAuthor: {{.author}}")
+
+	output, err := s.runCheck("summary")
+	require.Error(s.T(), err)
+	assert.EqualError(s.T(), err, "contract check failed: 3 of 3 contract assertions failed")
+	output = removeANSIColors(output)
+	assert.Contains(s.T(), output, `✗ summary.tmpl @assert-contains "## Summary" - Failed: `+
+		`not found in the 3 line(s) of the output, which starts with "## Overview"`)
+	assert.Contains(s.T(), output, `✗ summary.tmpl @assert-not-contains "<no value>" - Failed: `+
+		`found at line 2: "Branch: <no value>"`, "templates without examples render with empty arguments")
+	assert.Contains(s.T(), output, `✗ summary.tmpl @assert-max-lines 1 - Failed: the output has 3 lines, line 2 is "Branch: <no value>"`)
+
+	var buf bytes.Buffer
+	err = validateTemplates(&buf, s.promptsDir, nil, "", validateOptions{checkContracts: true})
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), removeANSIColors(buf.String()), "✓ summary.tmpl - Valid\n")
+	assert.Contains(s.T(), removeANSIColors(buf.String()), `✗ summary.tmpl @assert-max-lines 1 - Failed`)
+
+	buf.Reset()
+	require.NoError(s.T(), validateTemplates(&buf, s.promptsDir, nil, "", validateOptions{}),
+		"contracts are only checked with --check-contracts")
+}
+
+// TestContractsDoNotAffectServing tests that the server renders templates with assertions as usual
+func (s *ContractsTestSuite) TestContractsDoNotAffectServing() {
+	var buf bytes.Buffer
+	require.NoError(s.T(), renderTemplate(&buf, s.promptsDir, nil, "summary", map[string]string{"branch": "dev"}, true))
+	assert.Equal(s.T(), "## Summary\nBranch: dev", buf.String())
+
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+	assert.Contains(s.T(), promptsServer.promptChecksums, "summary")
+}
+
+// TestInvalidDirectives tests the errors of malformed directives
+func (s *ContractsTestSuite) TestInvalidDirectives() {
+	parser := &PromptsParser{}
+	for _, tc := range []struct {
+		directive string
+		err       string
+	}{
+		{directive: "@assert-contains Summary", err: "@assert-contains needs a non-empty quoted text"},
+		{directive: `@assert-not-contains ""`, err: "@assert-not-contains needs a non-empty quoted text"},
+		{directive: "@assert-max-lines many", err: "@assert-max-lines needs a positive number of lines"},
+		{directive: "@example branch", err: "@example needs an argument"},
+	} {
+		tmpl, err := parser.ParseTemplates(map[string]string{"t": "{{/* " + tc.directive + " */}}"})
+		require.NoError(s.T(), err)
+		_, err = parser.ExtractContract(tmpl, "t")
+		assert.ErrorContains(s.T(), err, tc.err, tc.directive)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// controlCommandReload is the command of the control socket that reloads the prompts.
+	controlCommandReload = "reload"
+	// controlCommandReloadConfig reloads the runtime config file; the reply holds the new config version.
+	controlCommandReloadConfig = "reload-config"
+	// controlCommandStatus replies with the number of served prompts and the config version.
+	controlCommandStatus = "status"
+	// controlCommandOverrideSet installs a template override, given as a JSON controlOverride after the command;
+	// the reply holds the prompt and when the override expires. It needs a server started with --enable-overrides.
+	controlCommandOverrideSet = "override-set"
+	// controlCommandOverrideDelete deletes the template override of the prompt named after the command.
+	controlCommandOverrideDelete = "override-delete"
+
+	// controlTimeout bounds connecting, reading the command, and writing the reply of a control connection.
+	controlTimeout = 5 * time.Second
+	// controlReplyTimeout bounds waiting for the reply to a command, which is sent once the command is done.
+	controlReplyTimeout = time.Minute
+	// maxControlCommandSize bounds the command line read from a control connection.
+	maxControlCommandSize = 64
+	// maxControlOverrideSize bounds the command line of servers started with --enable-overrides, which holds
+	// the source of template overrides.
+	maxControlOverrideSize = 1 << 20
+)
+
+// controlOverride is the template override of an override-set command of the control socket.
+type controlOverride struct {
+	Prompt         string `json:"prompt"`
+	TemplateSource string `json:"template_source"`
+	TTL            string `json:"ttl"` // duration, e.g. "10m"
+}
+
+// listenPrivateSocket creates a Unix socket at the path that only the current user can use, for the control
+// and event sockets. A socket left at the path by a server that did not stop cleanly is replaced;
+// any other file is an error. The socket is created in a new directory that only the current user can enter,
+// restricted, and then moved to the path, so that it is never accessible to other users, whatever the umask.
+func listenPrivateSocket(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != os.ModeSocket {
+			return nil, fmt.Errorf("socket path %q exists and is not a socket", path)
+		}
+		if err = os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".sock-")
+	if err != nil {
+		return nil, fmt.Errorf("listen on socket: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	privatePath := filepath.Join(dir, "s")
+	listener, err := net.Listen("unix", privatePath)
+	if err != nil {
+		return nil, fmt.Errorf("listen on socket: %w", err)
+	}
+	unixListener := listener.(*net.UnixListener)
+	unixListener.SetUnlinkOnClose(false)
+	if err = os.Chmod(privatePath, 0600); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("restrict socket permissions: %w", err)
+	}
+	if err = os.Rename(privatePath, path); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("listen on socket: %w", err)
+	}
+	return &privateSocketListener{UnixListener: unixListener, path: path, unlink: true}, nil
+}
+
+// privateSocketListener is the listener of a socket moved to its path after it was created,
+// which removes the socket at the path when it is closed, like net.UnixListener does for the path it created.
+type privateSocketListener struct {
+	*net.UnixListener
+	path   string
+	unlink bool
+}
+
+// SetUnlinkOnClose sets whether closing the listener removes the socket.
+func (l *privateSocketListener) SetUnlinkOnClose(unlink bool) {
+	l.unlink = unlink
+}
+
+func (l *privateSocketListener) Close() error {
+	err := l.UnixListener.Close()
+	if err == nil && l.unlink {
+		_ = os.Remove(l.path)
+	}
+	return err
+}
+
+// ServeControl answers the commands of the control socket until the context is done.
+// Every connection sends a single command line and receives "ok", followed by "key=value" fields
+// for some commands, or "error: <message>".
+func (ps *PromptsServer) ServeControl(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		ps.handleControlConn(conn)
+	}
+}
+
+func (ps *PromptsServer) handleControlConn(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetReadDeadline(time.Now().Add(controlTimeout))
+
+	maxSize := int64(maxControlCommandSize)
+	if ps.enableOverrides {
+		maxSize = maxControlOverrideSize
+	}
+	line, err := bufio.NewReader(io.LimitReader(conn, maxSize)).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		ps.logger.Warn("Failed to read control command", "error", err)
+		return
+	}
+	var reply string
+	command, payload, _ := strings.Cut(strings.TrimSpace(line), " ")
+	switch command {
+	case controlCommandReload:
+		ps.logger.Info("Reload requested through the control socket")
+		if err = ps.Reload(); err != nil {
+			ps.logger.Error("Failed to reload prompts", "error", err)
+			reply = controlErrorReply(err)
+		} else {
+			reply = "ok"
+		}
+	case controlCommandReloadConfig:
+		ps.logger.Info("Config reload requested through the control socket")
+		if configVersion, reloadErr := ps.ReloadConfig(); reloadErr != nil {
+			ps.logger.Error("Failed to reload config, keeping the current settings", "error", reloadErr)
+			reply = controlErrorReply(reloadErr)
+		} else {
+			reply = fmt.Sprintf("ok config_version=%d", configVersion)
+		}
+	case controlCommandStatus:
+		ps.reloadMu.Lock()
+		prompts := len(ps.promptChecksums)
+		ps.reloadMu.Unlock()
+		reply = fmt.Sprintf("ok prompts=%d config_version=%d", prompts, ps.ConfigVersion())
+	case controlCommandOverrideSet:
+		reply = ps.setControlOverride(payload)
+	case controlCommandOverrideDelete:
+		if err = ps.DeleteTemplateOverride(payload); err != nil {
+			reply = controlErrorReply(err)
+		} else {
+			reply = "ok prompt=" + payload
+		}
+	default:
+		ps.logger.Warn("Unknown control command", "command", command)
+		reply = fmt.Sprintf("error: unknown command %q", command)
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(controlTimeout))
+	if _, err = fmt.Fprintln(conn, reply); err != nil {
+		ps.logger.Warn("Failed to reply to control command", "error", err)
+	}
+}
+
+// setControlOverride installs the template override of an override-set command and returns the reply.
+func (ps *PromptsServer) setControlOverride(payload string) string {
+	var override controlOverride
+	if err := json.Unmarshal([]byte(payload), &override); err != nil {
+		return "error: invalid override: " + err.Error()
+	}
+	ttl, err := time.ParseDuration(override.TTL)
+	if err != nil {
+		return "error: invalid override ttl: " + err.Error()
+	}
+	expiresAt, err := ps.SetTemplateOverride(override.Prompt, override.TemplateSource, ttl)
+	if err != nil {
+		ps.logger.Warn("Rejected template override", "prompt", override.Prompt, "error", err)
+		return controlErrorReply(err)
+	}
+	return fmt.Sprintf("ok prompt=%s expires_at=%s", override.Prompt, expiresAt.Format(time.RFC3339))
+}
+
+// controlErrorReply returns the error reply of a command. Replies are a single line, so the lines of
+// joined errors, e.g. of every template failing to parse, are put on one line.
+func controlErrorReply(err error) string {
+	return "error: " + strings.ReplaceAll(err.Error(), "\n", " ")
+}
+
+// sendControlCommand sends the command to the control socket of a running server and returns the fields
+// of the reply, or an error if the server could not be reached or failed to execute the command.
+func sendControlCommand(path string, command string) (map[string]string, error) {
+	conn, err := net.DialTimeout("unix", path, controlTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connect to control socket: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(controlReplyTimeout))
+
+	if _, err = fmt.Fprintln(conn, command); err != nil {
+		return nil, fmt.Errorf("send control command: %w", err)
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("read control reply: %w", err)
+	}
+	reply = strings.TrimSpace(reply)
+	fieldsText, ok := strings.CutPrefix(reply, "ok")
+	if !ok || (fieldsText != "" && fieldsText[0] != ' ') {
+		return nil, errors.New(strings.TrimPrefix(reply, "error: "))
+	}
+	fields := make(map[string]string)
+	for _, field := range strings.Fields(fieldsText) {
+		key, value, _ := strings.Cut(field, "=")
+		fields[key] = value
+	}
+	return fields, nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ControlTestSuite struct {
+	suite.Suite
+	promptsDir string
+	socketPath string
+}
+
+func TestControlTestSuite(t *testing.T) {
+	suite.Run(t, new(ControlTestSuite))
+}
+
+func (s *ControlTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello {{.name}}")
+
+	// Unix socket paths are limited to about 100 bytes, which test temp dirs may exceed
+	socketDir, err := os.MkdirTemp("", "mpe-control-")
+	require.NoError(s.T(), err)
+	s.T().Cleanup(func() { _ = os.RemoveAll(socketDir) })
+	s.socketPath = filepath.Join(socketDir, "control.sock")
+}
+
+func (s *ControlTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+// serveControl starts a server answering control commands on the socket until the test ends
+func (s *ControlTestSuite) serveControl(opts ...Option) *PromptsServer {
+	promptsServer, err := NewPromptsServer(s.promptsDir, append([]Option{WithWatchMode(WatchModeOff, 0)}, opts...)...)
+	require.NoError(s.T(), err)
+	listener, err := listenPrivateSocket(s.socketPath)
+	require.NoError(s.T(), err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.NoError(promptsServer.ServeControl(ctx, listener))
+	}()
+	s.T().Cleanup(func() {
+		cancel()
+		wg.Wait()
+		s.NoError(promptsServer.Close())
+	})
+	return promptsServer
+}
+
+func (s *ControlTestSuite) reload(args ...string) (string, error) {
+	return s.run(append([]string{"reload", "--control-socket", s.socketPath}, args...)...)
+}
+
+func (s *ControlTestSuite) run(args ...string) (string, error) {
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	err := app.Run(context.Background(), append([]string{app.Name, "--color", "never"}, args...))
+	return buf.String(), err
+}
+
+func (s *ControlTestSuite) promptNames(promptsServer *PromptsServer) []string {
+	listPrompts := []byte(`{"jsonrpc":"2.0","id":1,"method":"prompts/list"}`)
+	response, err := json.Marshal(promptsServer.mcpServer.HandleMessage(context.Background(), listPrompts))
+	require.NoError(s.T(), err)
+	var decoded struct {
+		Result struct {
+			Prompts []struct {
+				Name string `json:"name"`
+			} `json:"prompts"`
+		} `json:"result"`
+	}
+	require.NoError(s.T(), json.Unmarshal(response, &decoded))
+	var names []string
+	for _, prompt := range decoded.Result.Prompts {
+		names = append(names, prompt.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// TestReloadCommand tests that the reload command makes a running server pick up new prompts
+func (s *ControlTestSuite) TestReloadCommand() {
+	promptsServer := s.serveControl()
+	assert.Equal(s.T(), []string{"greet"}, s.promptNames(promptsServer))
+
+	s.writeFile("farewell.tmpl", "{{/* Farewell */}}\nBye {{.name}}")
+	assert.Equal(s.T(), []string{"greet"}, s.promptNames(promptsServer), "the directory is not watched")
+
+	output, err := s.reload()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "✓ Prompts reloaded\n", output)
+	assert.Equal(s.T(), []string{"farewell", "greet"}, s.promptNames(promptsServer))
+
+	// A failed reload is reported by the command and keeps the registered prompts
+	s.writeFile("broken.tmpl", "{{/* Broken */}}\n{{.name")
+	_, err = s.reload()
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "failed to reload prompts: load server prompts")
+	assert.Contains(s.T(), err.Error(), "broken.tmpl:2: unclosed action")
+	assert.Equal(s.T(), []string{"farewell", "greet"}, s.promptNames(promptsServer))
+
+	// The errors of every broken template reach the command, although they span several lines
+	s.writeFile("broken2.tmpl", "{{/* Broken */}}\n\n{{.name")
+	_, err = s.reload()
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "broken.tmpl:2: unclosed action")
+	assert.Contains(s.T(), err.Error(), "broken2.tmpl:3: unclosed action")
+}
+
+// TestStatusAndConfigReload tests the status command and the reload of the runtime config
+func (s *ControlTestSuite) TestStatusAndConfigReload() {
+	configPath := filepath.Join(s.T().TempDir(), "config.yaml")
+	require.NoError(s.T(), os.WriteFile(configPath, []byte("max_output_size: 1000\n"), 0644))
+	promptsServer := s.serveControl(WithRuntimeConfig(configPath, nil))
+
+	output, err := s.run("status", "--control-socket", s.socketPath)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Prompts: 1\nConfig version: 1\n", output)
+
+	require.NoError(s.T(), os.WriteFile(configPath, []byte("max_output_size: 10\n"), 0644))
+	output, err = s.reload("--config")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "✓ Config reloaded (version 2)\n", output)
+	assert.Equal(s.T(), 10, promptsServer.renderSettings().MaxOutputSize)
+
+	require.NoError(s.T(), os.WriteFile(configPath, []byte("max_output_size: -1\n"), 0644))
+	_, err = s.reload("--config")
+	assert.ErrorContains(s.T(), err, "failed to reload config: config")
+	output, err = s.run("status", "--control-socket", s.socketPath)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Prompts: 1\nConfig version: 2\n", output)
+}
+
+// TestControlSocket tests the permissions of the socket and the handling of unknown commands and stale sockets
+func (s *ControlTestSuite) TestControlSocket() {
+	s.serveControl()
+
+	info, err := os.Stat(s.socketPath)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), os.FileMode(0600), info.Mode().Perm())
+
+	_, err = sendControlCommand(s.socketPath, "shutdown")
+	assert.EqualError(s.T(), err, `unknown command "shutdown"`)
+
+	_, err = listenPrivateSocket(filepath.Join(s.T().TempDir(), "missing", "control.sock"))
+	assert.ErrorContains(s.T(), err, "listen on socket")
+
+	notSocket := filepath.Join(filepath.Dir(s.socketPath), "file")
+	require.NoError(s.T(), os.WriteFile(notSocket, nil, 0600))
+	_, err = listenPrivateSocket(notSocket)
+	assert.ErrorContains(s.T(), err, "exists and is not a socket")
+
+	_, err = sendControlCommand(filepath.Join(filepath.Dir(s.socketPath), "none.sock"), controlCommandReload)
+	assert.ErrorContains(s.T(), err, "connect to control socket")
+}
+
+// TestPrivateSocket tests that the socket is moved to its path restricted, leaving nothing else behind,
+// and is removed when the listener closes
+func (s *ControlTestSuite) TestPrivateSocket() {
+	listener, err := listenPrivateSocket(s.socketPath)
+	require.NoError(s.T(), err)
+	info, err := os.Lstat(s.socketPath)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), os.ModeSocket, info.Mode().Type())
+	assert.Equal(s.T(), os.FileMode(0600), info.Mode().Perm())
+	entries, err := os.ReadDir(filepath.Dir(s.socketPath))
+	require.NoError(s.T(), err)
+	require.Len(s.T(), entries, 1)
+	assert.Equal(s.T(), "control.sock", entries[0].Name())
+
+	connection, err := net.Dial("unix", s.socketPath)
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), connection.Close())
+
+	require.NoError(s.T(), listener.Close())
+	assert.NoFileExists(s.T(), s.socketPath)
+	assert.Error(s.T(), listener.Close())
+}
+
+// TestStaleSocketIsReplaced tests that a socket left behind by a stopped server does not prevent starting
+func (s *ControlTestSuite) TestStaleSocketIsReplaced() {
+	listener, err := listenPrivateSocket(s.socketPath)
+	require.NoError(s.T(), err)
+	// Keep the file, as a crashed server would
+	unixListener, ok := listener.(interface{ SetUnlinkOnClose(bool) })
+	require.True(s.T(), ok)
+	unixListener.SetUnlinkOnClose(false)
+	require.NoError(s.T(), listener.Close())
+	require.FileExists(s.T(), s.socketPath)
+
+	s.serveControl()
+	_, err = s.reload()
+	assert.NoError(s.T(), err)
+}
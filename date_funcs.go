@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// builtinDateLayout is the layout of the built-in .date variable.
+const builtinDateLayout = "2006-01-02 15:04:05"
+
+// dateInputLayouts are the layouts of date strings accepted by the date helpers, tried in order.
+var dateInputLayouts = []string{time.RFC3339Nano, builtinDateLayout, time.DateOnly}
+
+var dateOffsetPartRegex = regexp.MustCompile(`(\d+(?:\.\d+)?)([a-zµ]+)`)
+
+// Date is a point in time produced by the date helpers. It renders in the layout of the built-in .date,
+// and its time.Time methods (e.g., .Year) are available in templates.
+type Date struct {
+	time.Time
+}
+
+func (d Date) String() string {
+	return d.Format(builtinDateLayout)
+}
+
+// agoFuncName is the helper describing a date relative to the time of the render.
+const agoFuncName = "ago"
+
+// dateFuncs returns the helpers for date math. They accept the built-in .date, RFC3339 or "2006-01-02" strings,
+// and the values returned by each other; strings without a time zone are in the local time zone.
+func dateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"dateAdd":     dateAdd,
+		"dateFormat":  dateFormat,
+		"weekday":     weekday,
+		"startOfWeek": startOfWeek,
+		"parseDate":   parseDate,
+		"duration":    formatDuration,
+		agoFuncName:   ago,
+	}
+}
+
+// dateAdd shifts the date by an offset such as "72h", "-2d", or "1w12h".
+// Besides the units of time.ParseDuration, "d" (calendar days) and "w" (weeks) are supported.
+func dateAdd(value interface{}, offset string) (Date, error) {
+	t, err := toTime(value)
+	if err != nil {
+		return Date{}, err
+	}
+	if t, err = addDateOffset(t, offset); err != nil {
+		return Date{}, err
+	}
+	return Date{t}, nil
+}
+
+// dateFormat formats the date with a Go layout, e.g. {{dateFormat .date "Monday, Jan 2"}}.
+func dateFormat(value interface{}, layout string) (string, error) {
+	t, err := toTime(value)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(layout), nil
+}
+
+// weekday returns the English name of the day of the week of the date.
+func weekday(value interface{}) (string, error) {
+	t, err := toTime(value)
+	if err != nil {
+		return "", err
+	}
+	return t.Weekday().String(), nil
+}
+
+// startOfWeek returns the midnight of the Monday of the date's week.
+func startOfWeek(value interface{}) (Date, error) {
+	t, err := toTime(value)
+	if err != nil {
+		return Date{}, err
+	}
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return Date{time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, t.Location())}, nil
+}
+
+// parseDate parses the string with a Go layout, e.g. {{parseDate .due "02.01.2006"}}.
+func parseDate(value interface{}, layout string) (Date, error) {
+	s := strings.TrimSpace(stringify(value))
+	t, err := time.ParseInLocation(layout, s, time.Local)
+	if err != nil {
+		return Date{}, fmt.Errorf("cannot parse %q with layout %q", s, layout)
+	}
+	return Date{t}, nil
+}
+
+// durationUnits are the units of formatted durations, largest first. Months and years are approximated.
+var durationUnits = []struct {
+	name string
+	size time.Duration
+}{
+	{"year", 365 * 24 * time.Hour},
+	{"month", 30 * 24 * time.Hour},
+	{"day", 24 * time.Hour},
+	{"hour", time.Hour},
+	{"minute", time.Minute},
+	{"second", time.Second},
+}
+
+// formatDuration formats a duration in words with its two largest units, e.g. {{duration "90m"}} renders
+// "1 hour 30 minutes". It accepts duration strings ("72h", "2d"), numbers of seconds, and durations.
+// Values it cannot parse are returned unchanged.
+func formatDuration(value interface{}) string {
+	var d time.Duration
+	switch v := value.(type) {
+	case time.Duration:
+		d = v
+	case float64:
+		d = time.Duration(v * float64(time.Second))
+	case int:
+		d = time.Duration(v) * time.Second
+	default:
+		s := strings.TrimSpace(stringify(value))
+		if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+			d = time.Duration(seconds * float64(time.Second))
+			break
+		}
+		// Offsets of dateAdd, including days and weeks, are durations from the zero time
+		end, err := addDateOffset(time.Time{}, s)
+		if err != nil {
+			return stringify(value)
+		}
+		d = end.Sub(time.Time{})
+	}
+	return durationWords(d)
+}
+
+// ago describes the time from the date to now, e.g. {{ago .created_at}} renders "3 days ago",
+// or "in 2 hours" for dates in the future. Values it cannot parse as a date are returned unchanged.
+func ago(value interface{}) string {
+	return relativeTime(value, time.Now())
+}
+
+// relativeTime describes the time from the date to now with its largest unit.
+func relativeTime(value interface{}, now time.Time) string {
+	t, err := toTime(value)
+	if err != nil {
+		return stringify(value)
+	}
+	d := now.Sub(t)
+	switch {
+	case d.Abs() < time.Minute:
+		return "just now"
+	case d > 0:
+		return durationWordsN(d, 1) + " ago"
+	default:
+		return "in " + durationWordsN(-d, 1)
+	}
+}
+
+// durationWords spells out the duration with its two largest units; negative durations get a minus sign.
+func durationWords(d time.Duration) string {
+	if d < 0 {
+		return "-" + durationWordsN(-d, 2)
+	}
+	return durationWordsN(d, 2)
+}
+
+// durationWordsN spells out the non-negative duration with at most n units, truncating the rest.
+func durationWordsN(d time.Duration, n int) string {
+	var parts []string
+	for _, unit := range durationUnits {
+		if len(parts) == n {
+			break
+		}
+		count := d / unit.size
+		if count == 0 {
+			if len(parts) > 0 {
+				break // "1 hour 5 seconds" would skip the minutes
+			}
+			continue
+		}
+		d -= count * unit.size
+		name := unit.name
+		if count != 1 {
+			name += "s"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", count, name))
+	}
+	if len(parts) == 0 {
+		return "0 seconds"
+	}
+	return strings.Join(parts, " ")
+}
+
+// toTime converts a value accepted by the date helpers to a time.
+// A missing value is an error, so a typo in an argument name cannot silently produce the zero time.
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case Date:
+		return v.Time, nil
+	case time.Time:
+		return v, nil
+	case string:
+		s := strings.TrimSpace(v)
+		for _, layout := range dateInputLayouts {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid date %q, expected RFC3339, %q, or %q", v, builtinDateLayout, time.DateOnly)
+	case nil:
+		return time.Time{}, fmt.Errorf("missing date")
+	default:
+		return time.Time{}, fmt.Errorf("invalid date %v, expected a string or a date, got %T", value, value)
+	}
+}
+
+// addDateOffset adds an offset made of one or more number-unit pairs with an optional sign, e.g. "-1w2d".
+func addDateOffset(t time.Time, offset string) (time.Time, error) {
+	invalid := fmt.Errorf(`invalid offset %q, expected a number with a unit (w, d, h, m, s), e.g. "-2d" or "1w12h"`, offset)
+	body := strings.TrimSpace(offset)
+	sign := 1
+	if rest, negative := strings.CutPrefix(body, "-"); negative {
+		sign, body = -1, rest
+	} else {
+		body = strings.TrimPrefix(body, "+")
+	}
+	// The parts must cover the whole offset, without anything between them
+	parts := dateOffsetPartRegex.FindAllStringSubmatch(body, -1)
+	covered := 0
+	for _, part := range parts {
+		covered += len(part[0])
+	}
+	if body == "" || covered != len(body) {
+		return time.Time{}, invalid
+	}
+
+	var days int
+	var duration time.Duration
+	for _, part := range parts {
+		number, unit := part[1], part[2]
+		switch unit {
+		case "d", "w":
+			n, err := strconv.Atoi(number)
+			if err != nil {
+				return time.Time{}, invalid
+			}
+			if unit == "w" {
+				n *= 7
+			}
+			days += n
+		default:
+			d, err := time.ParseDuration(number + unit)
+			if err != nil {
+				return time.Time{}, invalid
+			}
+			duration += d
+		}
+	}
+	return t.AddDate(0, 0, sign*days).Add(time.Duration(sign) * duration), nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type DateFuncsTestSuite struct {
+	suite.Suite
+}
+
+func TestDateFuncsTestSuite(t *testing.T) {
+	suite.Run(t, new(DateFuncsTestSuite))
+}
+
+// render executes the template text with the built-in helpers and the data
+func (s *DateFuncsTestSuite) render(text string, data map[string]interface{}) (string, error) {
+	tmpl, err := template.New("test").Funcs(builtinFuncs()).Parse(text)
+	require.NoError(s.T(), err)
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	return buf.String(), err
+}
+
+// TestDateAddUnits tests offsets with duration units, days, weeks, and signs
+func (s *DateFuncsTestSuite) TestDateAddUnits() {
+	start := "2026-03-02 09:30:00" // a Monday
+	tests := []struct {
+		offset   string
+		expected string
+	}{
+		{offset: "72h", expected: "2026-03-05 09:30:00"},
+		{offset: "-2d", expected: "2026-02-28 09:30:00"},
+		{offset: "+1w", expected: "2026-03-09 09:30:00"},
+		{offset: "2w", expected: "2026-03-16 09:30:00"},
+		{offset: "1d12h", expected: "2026-03-03 21:30:00"},
+		{offset: "-1w2d", expected: "2026-02-21 09:30:00"},
+		{offset: "90m", expected: "2026-03-02 11:00:00"},
+		{offset: "-1.5h", expected: "2026-03-02 08:00:00"},
+		{offset: " 0d ", expected: "2026-03-02 09:30:00"},
+	}
+	for _, tt := range tests {
+		s.Run(tt.offset, func() {
+			result, err := dateAdd(start, tt.offset)
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), tt.expected, result.String())
+		})
+	}
+
+	for _, offset := range []string{"", "-", "2", "d", "1.5d", "2 days", "2y", "1d-2h", "+-1d", "3dd"} {
+		_, err := dateAdd(start, offset)
+		require.Error(s.T(), err, offset)
+		assert.Contains(s.T(), err.Error(), "invalid offset")
+	}
+}
+
+// TestDateInputs tests the accepted date values, including values produced by other helpers
+func (s *DateFuncsTestSuite) TestDateInputs() {
+	for _, value := range []interface{}{
+		"2026-03-04 10:00:00",
+		"2026-03-04",
+		"2026-03-04T10:00:00+02:00",
+		time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC),
+		Date{time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)},
+	} {
+		day, err := weekday(value)
+		require.NoError(s.T(), err, "%v", value)
+		assert.Equal(s.T(), "Wednesday", day)
+	}
+
+	_, err := weekday("next tuesday")
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), `invalid date "next tuesday"`)
+	_, err = weekday(nil)
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "missing date")
+	_, err = startOfWeek(42.0)
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "got float64")
+}
+
+// TestStartOfWeek tests that weeks start on Monday at midnight
+func (s *DateFuncsTestSuite) TestStartOfWeek() {
+	for value, expected := range map[string]string{
+		"2026-03-02 09:30:00": "2026-03-02 00:00:00", // Monday
+		"2026-03-04":          "2026-03-02 00:00:00", // Wednesday
+		"2026-03-08 23:59:59": "2026-03-02 00:00:00", // Sunday
+		"2026-03-01 12:00:00": "2026-02-23 00:00:00", // Sunday across a month boundary
+	} {
+		result, err := startOfWeek(value)
+		require.NoError(s.T(), err, value)
+		assert.Equal(s.T(), expected, result.String(), value)
+	}
+}
+
+// TestParseAndFormatRoundTrip tests that parsing and formatting with the same layout keeps the value
+func (s *DateFuncsTestSuite) TestParseAndFormatRoundTrip() {
+	for _, tt := range []struct{ value, layout string }{
+		{value: "04.03.2026", layout: "02.01.2006"},
+		{value: "Mar 4, 2026 at 3:04pm", layout: "Jan 2, 2006 at 3:04pm"},
+		{value: "2026-03-04T15:04:05Z", layout: time.RFC3339},
+	} {
+		parsed, err := parseDate(tt.value, tt.layout)
+		require.NoError(s.T(), err, tt.value)
+		formatted, err := dateFormat(parsed, tt.layout)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), tt.value, formatted)
+	}
+
+	_, err := parseDate("2026/03/04", "02.01.2006")
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), `cannot parse "2026/03/04" with layout "02.01.2006"`)
+}
+
+// TestTemplateUsage tests composing the helpers in templates and the errors they produce there
+func (s *DateFuncsTestSuite) TestTemplateUsage() {
+	data := map[string]interface{}{"date": "2026-03-04 10:00:00", "start_date": "2026-03-10"}
+
+	output, err := s.render(`Yesterday: {{dateFormat (dateAdd .date "-1d") "Monday"}}; `+
+		`next Monday: {{dateFormat (dateAdd (startOfWeek .date) "1w") "Jan 2"}}; `+
+		`two weeks from start: {{dateAdd .start_date "2w"}}; `+
+		`due: {{weekday (parseDate "05/03/2026" "02/01/2006")}}; year: {{(dateAdd .date "1d").Year}}`, data)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Yesterday: Tuesday; next Monday: Mar 9; two weeks from start: 2026-03-24 00:00:00; "+
+		"due: Thursday; year: 2026", output)
+
+	_, err = s.render(`{{dateAdd .date "soon"}}`, data)
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), `error calling dateAdd: invalid offset "soon"`)
+
+	_, err = s.render(`{{weekday .missing}}`, data)
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "error calling weekday: missing date")
+
+	_, err = s.render(`{{dateFormat .start_date_typo "Jan 2"}}`, map[string]interface{}{"start_date_typo": "10.03.2026"})
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), `error calling dateFormat: invalid date "10.03.2026"`)
+}
+
+// TestFormatDuration tests spelling out durations given as strings, seconds, and durations
+func (s *DateFuncsTestSuite) TestFormatDuration() {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{value: "90m", expected: "1 hour 30 minutes"},
+		{value: "72h", expected: "3 days"},
+		{value: "1w2d", expected: "9 days"},
+		{value: "-2d12h", expected: "-2 days 12 hours"},
+		{value: "1h0m5s", expected: "1 hour"},
+		{value: "45s", expected: "45 seconds"},
+		{value: "0s", expected: "0 seconds"},
+		{value: float64(3661), expected: "1 hour 1 minute"},
+		{value: "86400", expected: "1 day"},
+		{value: 400 * 24 * time.Hour, expected: "1 year 1 month"},
+		{value: "soon", expected: "soon"},
+		{value: nil, expected: "<nil>"},
+	}
+	for _, tt := range tests {
+		assert.Equal(s.T(), tt.expected, formatDuration(tt.value), "value %v", tt.value)
+	}
+}
+
+// TestRelativeTime tests describing dates in the past and the future relative to now
+func (s *DateFuncsTestSuite) TestRelativeTime() {
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{value: "2026-03-01T09:00:00Z", expected: "3 days ago"},
+		{value: "2026-03-04T09:59:30Z", expected: "just now"},
+		{value: "2026-03-04T08:59:00Z", expected: "1 hour ago"},
+		{value: "2026-03-04T12:30:00Z", expected: "in 2 hours"},
+		{value: "2025-01-04T10:00:00Z", expected: "1 year ago"},
+		{value: Date{now.Add(-45 * time.Minute)}, expected: "45 minutes ago"},
+		{value: "last tuesday", expected: "last tuesday"},
+		{value: "", expected: ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(s.T(), tt.expected, relativeTime(tt.value, now), "value %v", tt.value)
+	}
+
+	output, err := s.render(`Opened {{ago .created_at}}, closed {{ago .closed_at}}, took {{duration .took}}`,
+		map[string]interface{}{
+			"created_at": time.Now().Add(-50 * time.Hour).Format(time.RFC3339),
+			"closed_at":  "not yet",
+			"took":       "150m",
+		})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Opened 2 days ago, closed not yet, took 2 hours 30 minutes", output)
+}
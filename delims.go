@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// delimsDirective sets the action delimiters of a template file in its leading comment, e.g. {{/* @delims <% %> */}},
+// for prompts whose text shows template syntax itself. The comment is written with the default delimiters and
+// the rest of the file with the ones it sets, so "{{.name}}" passes through as text.
+const delimsDirective = "@delims"
+
+// templateDelims are the left and right action delimiters of a template file.
+type templateDelims struct {
+	left, right string
+}
+
+// defaultDelims are the delimiters of text/template, used by template files without a delims directive.
+var defaultDelims = templateDelims{left: "{{", right: "}}"}
+
+// leadingComment locates the comment that starts the template body after any whitespace, written with the default
+// delimiters, e.g. {{/* Description */}} or {{- /* Description */ -}}. It returns the positions of the comment
+// and of its text between "/*" and "*/".
+func leadingComment(body string) (start, end, textStart, textEnd int, ok bool) {
+	start = len(body) - len(strings.TrimLeft(body, " \t\r\n"))
+	rest := body[start:]
+	var opening string
+	for _, candidate := range []string{"{{/*", "{{- /*"} {
+		if strings.HasPrefix(rest, candidate) {
+			opening = candidate
+			break
+		}
+	}
+	if opening == "" {
+		return 0, 0, 0, 0, false
+	}
+	textStart = start + len(opening)
+	closing := strings.Index(body[textStart:], "*/")
+	if closing < 0 {
+		return 0, 0, 0, 0, false
+	}
+	textEnd = textStart + closing
+	afterComment := body[textEnd+len("*/"):]
+	switch {
+	case strings.HasPrefix(afterComment, "}}"):
+		end = textEnd + len("*/}}")
+	case strings.HasPrefix(afterComment, " -}}"):
+		end = textEnd + len("*/ -}}")
+	default:
+		return 0, 0, 0, 0, false
+	}
+	return start, end, textStart, textEnd, true
+}
+
+// findDelimsDirective returns the delimiters set by the leading comment of the template body and the end of
+// that comment, or the default delimiters and 0 if the body sets none.
+func findDelimsDirective(body string) (templateDelims, int, error) {
+	_, end, textStart, textEnd, ok := leadingComment(body)
+	if !ok {
+		return defaultDelims, 0, nil
+	}
+	for _, line := range strings.Split(body[textStart:textEnd], "\n") {
+		value, found := strings.CutPrefix(strings.TrimSpace(line), delimsDirective)
+		if !found || (value != "" && value[0] != ' ' && value[0] != '\t') {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) != 2 {
+			return templateDelims{}, 0, fmt.Errorf("%s needs a left and a right delimiter, e.g. %s <%% %%>", delimsDirective, delimsDirective)
+		}
+		if fields[0] == fields[1] {
+			return templateDelims{}, 0, fmt.Errorf("%s left and right delimiters must differ", delimsDirective)
+		}
+		return templateDelims{left: fields[0], right: fields[1]}, end, nil
+	}
+	return defaultDelims, 0, nil
+}
+
+// withDelimsDirective returns the template body with its leading comment rewritten in the delimiters it sets,
+// so that the whole body parses with them, and those delimiters. Bodies without the directive are returned as they are.
+// The comment keeps its trim markers and lines, so the output and the lines of parse errors do not change.
+func withDelimsDirective(body string) (string, templateDelims, error) {
+	delims, _, err := findDelimsDirective(body)
+	if err != nil || delims == defaultDelims {
+		return body, delims, err
+	}
+	start, end, textStart, textEnd, _ := leadingComment(body)
+	opening := strings.Replace(body[start:textStart], defaultDelims.left, delims.left, 1)
+	closing := strings.Replace(body[textEnd:end], defaultDelims.right, delims.right, 1)
+	return body[:start] + opening + body[textStart:textEnd] + closing + body[end:], delims, nil
+}
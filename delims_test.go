@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const delimsTestdataDir = "./testdata/delims"
+
+// teachRangeOutput is the render of testdata/delims/teach_range.tmpl, whose Go template examples pass through as text
+const teachRangeOutput = `You are teaching Go newcomers how to loop in Go templates.
+
+Loop over a list with range:
+
+    {{range .items}}
+    - {{.}}
+    {{- end}}
+
+Stray markers like {{ or }} and {{/* comments */}} are plain text here.
+Inside range, {{$}} is the root data and {{.}} the current element.
+-- Ann`
+
+type DelimsTestSuite struct {
+	suite.Suite
+}
+
+func TestDelimsTestSuite(t *testing.T) {
+	suite.Run(t, new(DelimsTestSuite))
+}
+
+func (s *DelimsTestSuite) getPrompt(promptsServer *PromptsServer, name string, args map[string]any) string {
+	request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "prompts/get",
+		"params": map[string]any{"name": name, "arguments": args}})
+	require.NoError(s.T(), err)
+	response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok, "%s must render", name)
+	return response.Result.(mcp.GetPromptResult).Messages[0].Content.(mcp.TextContent).Text
+}
+
+// TestFindDelimsDirective tests which leading comments set delimiters
+func (s *DelimsTestSuite) TestFindDelimsDirective() {
+	for body, expected := range map[string]templateDelims{
+		"{{/* @delims <% %> */}}\nHi":                    {left: "<%", right: "%>"},
+		"\n\n{{- /* Teach\n  @delims [[ ]]\n*/ -}}\nHi":  {left: "[[", right: "]]"},
+		"{{/* @icon 🧪\n@delims <<< >>> */}}":             {left: "<<<", right: ">>>"},
+		"{{/* Description */}}\n{{/* @delims <% %> */}}": defaultDelims,
+		"Hi {{/* @delims <% %> */}}":                     defaultDelims,
+		"{{/* @delimsx <% %> */}}":                       defaultDelims,
+		"{{/* @delims <% %> */}} Hi":                     {left: "<%", right: "%>"},
+	} {
+		delims, _, err := findDelimsDirective(body)
+		require.NoError(s.T(), err, body)
+		assert.Equal(s.T(), expected, delims, body)
+	}
+	for _, body := range []string{"{{/* @delims <% */}}", "{{/* @delims */}}", "{{/* @delims <% %> >> */}}", "{{/* @delims %% %% */}}"} {
+		_, _, err := findDelimsDirective(body)
+		assert.Error(s.T(), err, body)
+	}
+}
+
+// TestPassthrough tests that the default delimiters are text in a file with its own, byte for byte,
+// and that partials with the default delimiters are called from it
+func (s *DelimsTestSuite) TestPassthrough() {
+	args := map[string]string{"audience": "Go newcomers", "advanced": "true", "author": "Ann"}
+	var buf bytes.Buffer
+	require.NoError(s.T(), renderTemplate(&buf, delimsTestdataDir, nil, "teach_range", args, true))
+	assert.Equal(s.T(), teachRangeOutput, buf.String())
+
+	buf.Reset()
+	require.NoError(s.T(), renderTemplate(&buf, delimsTestdataDir, nil, "greeting", map[string]string{"name": "Bob"}, true))
+	assert.Equal(s.T(), "Hello Bob!", buf.String(), "the other files keep the default delimiters")
+}
+
+// TestServe tests that the server extracts the description and the arguments of a file with its own delimiters
+// and renders it like the CLI
+func (s *DelimsTestSuite) TestServe() {
+	promptsServer, err := NewPromptsServer(delimsTestdataDir, WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	s.T().Cleanup(func() { s.Require().NoError(promptsServer.Close()) })
+
+	request := []byte(`{"jsonrpc":"2.0","id":1,"method":"prompts/list"}`)
+	response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok)
+	prompts := response.Result.(mcp.ListPromptsResult).Prompts
+	require.Len(s.T(), prompts, 2)
+	teachRange := prompts[0]
+	if teachRange.Name != "teach_range" {
+		teachRange = prompts[1]
+	}
+	assert.Equal(s.T(), "Teach the range action of Go templates", teachRange.Description, "the directive is not part of the description")
+	assert.Equal(s.T(), []string{"advanced", "audience", "author"}, argNames(teachRange), "the examples are no arguments")
+
+	assert.Equal(s.T(), teachRangeOutput, s.getPrompt(promptsServer, "teach_range",
+		map[string]any{"audience": "Go newcomers", "advanced": "true", "author": "Ann"}))
+}
+
+// TestValidateAndFormat tests that validate accepts the fixture and that format indents by the custom delimiters,
+// leaving the examples alone
+func (s *DelimsTestSuite) TestValidateAndFormat() {
+	var buf bytes.Buffer
+	require.NoError(s.T(), validateTemplates(&buf, delimsTestdataDir, nil, "", validateOptions{}))
+
+	content, err := os.ReadFile(filepath.Join(delimsTestdataDir, "teach_range.tmpl"))
+	require.NoError(s.T(), err)
+	formatted, err := formatTemplate("teach_range.tmpl", content)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), string(content), string(formatted))
+
+	formatted, err = formatTemplate("loop.tmpl", []byte("{{/*@delims <% %>   */}}\n"+
+		"<%- range .items %>   \n       <%- . %>\n  {{- end}}\n<%- end %>"))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "{{/* @delims <% %> */}}\n"+
+		"<%- range .items %>\n  <%- . %>\n  {{- end}}\n<%- end %>", string(formatted))
+}
+
+// TestReload tests that a reload picks up delimiters added to a file, and that a file whose directive
+// is invalid fails to load with its error
+func (s *DelimsTestSuite) TestReload() {
+	promptsDir := s.T().TempDir()
+	writeFile := func(content string) {
+		require.NoError(s.T(), os.WriteFile(filepath.Join(promptsDir, "example.tmpl"), []byte(content), 0644))
+	}
+	writeFile("{{/* Example */}}\nUse {{.name}}")
+	promptsServer, err := NewPromptsServer(promptsDir, WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	s.T().Cleanup(func() { s.Require().NoError(promptsServer.Close()) })
+	assert.Equal(s.T(), "Use x", s.getPrompt(promptsServer, "example", map[string]any{"name": "x"}))
+
+	writeFile("{{/* Example\n@delims [[ ]] */}}\nUse {{.name}} for [[ .name ]]")
+	require.NoError(s.T(), promptsServer.Reload())
+	assert.Equal(s.T(), "Use {{.name}} for x", s.getPrompt(promptsServer, "example", map[string]any{"name": "x"}))
+
+	writeFile("{{/* @delims [[ */}}\nUse {{.name}}")
+	assert.ErrorContains(s.T(), promptsServer.Reload(), "@delims needs a left and a right delimiter")
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"text/template"
+)
+
+// varsField is the built-in field holding the deployment variables of the --var flags, e.g. {{.vars.base_url}}.
+// Unlike context values, the variables are not arguments of their own, so they never collide with prompt arguments.
+const varsField = "vars"
+
+// parseVars parses the name=value pairs of the --var flags. Names must be identifiers, so that templates
+// can reference them as fields of .vars; the last value of a name wins.
+func parseVars(pairs []string) (map[string]string, error) {
+	vars := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		name, value, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !found || !funcAliasNameRegex.MatchString(name) {
+			return nil, errors.New(localize("cli.invalid_var", pair))
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// templateReadsVars reports whether the template or the partials it references read the deployment variables.
+func templateReadsVars(tmpl *template.Template, templateName string, partials []string) bool {
+	for _, name := range append([]string{templateName}, partials...) {
+		if t := lookupPartial(tmpl, name); t != nil && !nodeIsPure(t.Root, []string{varsField}, nil) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type DeploymentVarsTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestDeploymentVarsTestSuite(t *testing.T) {
+	suite.Run(t, new(DeploymentVarsTestSuite))
+}
+
+func (s *DeploymentVarsTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, "call_api.tmpl"),
+		[]byte("{{/* Call the API */}}\nPOST {{.vars.base_url}}/v1/{{.endpoint}}"), 0644))
+}
+
+func (s *DeploymentVarsTestSuite) newServer(opts ...Option) *PromptsServer {
+	promptsServer, err := NewPromptsServer(s.promptsDir, append([]Option{WithWatchMode(WatchModeOff, 0)}, opts...)...)
+	require.NoError(s.T(), err)
+	s.T().Cleanup(func() { s.Require().NoError(promptsServer.Close()) })
+	return promptsServer
+}
+
+func (s *DeploymentVarsTestSuite) getPrompt(promptsServer *PromptsServer, args map[string]any) string {
+	request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "prompts/get",
+		"params": map[string]any{"name": "call_api", "arguments": args}})
+	require.NoError(s.T(), err)
+	response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok)
+	return response.Result.(mcp.GetPromptResult).Messages[0].Content.(mcp.TextContent).Text
+}
+
+// TestParseVars tests the name=value pairs of the --var flags
+func (s *DeploymentVarsTestSuite) TestParseVars() {
+	vars, err := parseVars([]string{"base_url=https://api.example.com/?a=b", " region =eu", "empty=", "region=us"})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), map[string]string{"base_url": "https://api.example.com/?a=b", "region": "us", "empty": ""}, vars)
+
+	for _, pair := range []string{"base_url", "=x", "base-url=x", "1st=x", "a.b=x"} {
+		_, err = parseVars([]string{pair})
+		assert.ErrorContains(s.T(), err, "invalid variable '"+pair+"'", pair)
+	}
+
+	_, err = parseContextValues([]string{"vars=x"}, defaultBuiltinDateName)
+	assert.ErrorContains(s.T(), err, "vars is a built-in variable")
+	assert.ErrorContains(s.T(), validateBuiltinDateName(varsField), "reserved for the deployment variables")
+}
+
+// TestServe tests that the variables render in every prompt and are not advertised as arguments,
+// and that clients cannot replace them
+func (s *DeploymentVarsTestSuite) TestServe() {
+	promptsServer := s.newServer(WithVars(map[string]string{"base_url": "https://api.example.com"}))
+
+	request := []byte(`{"jsonrpc":"2.0","id":1,"method":"prompts/list"}`)
+	response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok)
+	prompts := response.Result.(mcp.ListPromptsResult).Prompts
+	require.Len(s.T(), prompts, 1)
+	assert.Equal(s.T(), []string{"endpoint"}, argNames(prompts[0]))
+
+	assert.Equal(s.T(), "POST https://api.example.com/v1/users", s.getPrompt(promptsServer, map[string]any{"endpoint": "users"}))
+	assert.Equal(s.T(), "POST https://api.example.com/v1/users",
+		s.getPrompt(promptsServer, map[string]any{"endpoint": "users", "vars": `{"base_url":"https://evil.example.com"}`}))
+
+	assert.Equal(s.T(), "POST <no value>/v1/users", s.getPrompt(s.newServer(), map[string]any{"endpoint": "users"}),
+		"unset variables render like missing arguments")
+}
+
+// TestRenderCache tests that the variables are part of the cache key of the prompts using them
+func (s *DeploymentVarsTestSuite) TestRenderCache() {
+	renderCache, err := OpenRenderCache(s.T().TempDir(), defaultRenderCacheMaxSize)
+	require.NoError(s.T(), err)
+	s.T().Cleanup(func() { s.NoError(renderCache.Close()) })
+
+	for _, baseURL := range []string{"https://a.example.com", "https://b.example.com", "https://a.example.com"} {
+		promptsServer := s.newServer(WithRenderCache(renderCache), WithVars(map[string]string{"base_url": baseURL}))
+		assert.Equal(s.T(), "POST "+baseURL+"/v1/users", s.getPrompt(promptsServer, map[string]any{"endpoint": "users"}))
+	}
+	stats, err := renderCache.Stats()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 2, stats.Entries)
+	assert.Equal(s.T(), int64(1), stats.Hits)
+}
+
+func (s *DeploymentVarsTestSuite) runCLI(args ...string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	app := newApp()
+	app.Writer = &stdout
+	app.ErrWriter = &stderr
+	err := app.Run(context.Background(), append([]string{app.Name, "--color", "never"}, args...))
+	return stdout.String(), stderr.String(), err
+}
+
+// TestCLI tests the variables of render and test, and the warning of render about missing variables,
+// also when a partial reads them
+func (s *DeploymentVarsTestSuite) TestCLI() {
+	stdout, stderr, err := s.runCLI("render", s.promptsDir, "call_api", "--arg", "endpoint=users",
+		"--var", "base_url=https://api.example.com")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "POST https://api.example.com/v1/users", stdout)
+	assert.Empty(s.T(), stderr)
+
+	stdout, stderr, err = s.runCLI("render", s.promptsDir, "call_api", "--arg", "endpoint=users")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "POST <no value>/v1/users", stdout)
+	assert.Equal(s.T(), "⚠ The template reads deployment variables (.vars), but no --var was given\n", stderr)
+
+	stdout, _, err = s.runCLI("render", s.promptsDir, "call_api", "--mcp", "--arg", "endpoint=users",
+		"--var", "base_url=https://api.example.com")
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), stdout, `"text":"POST https://api.example.com/v1/users"`)
+
+	_, _, err = s.runCLI("render", s.promptsDir, "call_api", "--var", "base-url=x")
+	assert.ErrorContains(s.T(), err, "invalid variable 'base-url=x'")
+
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, "_endpoint.tmpl"),
+		[]byte(`{{define "_endpoint"}}{{$.vars.base_url}}/v1{{end}}`), 0644))
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, "health.tmpl"),
+		[]byte("{{/* Check health */}}\nGET {{template \"_endpoint\" .}}/health"), 0644))
+	_, stderr, err = s.runCLI("render", s.promptsDir, "health")
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), stderr, "no --var was given")
+
+	fixturesDir := filepath.Join(s.promptsDir, "fixtures", "call_api")
+	require.NoError(s.T(), os.MkdirAll(fixturesDir, 0755))
+	require.NoError(s.T(), os.WriteFile(filepath.Join(fixturesDir, "users.args.json"), []byte(`{"endpoint": "users"}`), 0644))
+	require.NoError(s.T(), os.WriteFile(filepath.Join(fixturesDir, "users.expected.txt"),
+		[]byte("POST https://api.example.com/v1/users\n"), 0644))
+	stdout, _, err = s.runCLI("test", s.promptsDir, "call_api", "--var", "base_url=https://api.example.com")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "✓ users - Passed\n", stdout)
+}
+
+// TestList tests that list and validate do not report vars as an argument
+func (s *DeploymentVarsTestSuite) TestList() {
+	var buf bytes.Buffer
+	require.NoError(s.T(), listTemplates(&buf, s.promptsDir, listOptions{verbose: true}))
+	assert.Contains(s.T(), removeANSIColors(buf.String()), "  Variables: endpoint\n")
+
+	buf.Reset()
+	require.NoError(s.T(), validateTemplates(&buf, s.promptsDir, nil, "", validateOptions{}))
+}
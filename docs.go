@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Formats of generated prompt documentation.
+const (
+	docsFormatMarkdown = "markdown"
+	docsFormatHTML     = "html"
+)
+
+// promptDoc documents a prompt. Everything is derived from the template, its frontmatter,
+// the collections manifest, and the fixtures, so the documentation cannot drift from the prompts.
+type promptDoc struct {
+	Name        string
+	Title       string // label from the collections manifest, empty if the prompt is in no collection
+	Collection  string
+	Description string
+	Deprecated  string
+	Arguments   []argumentDoc
+	Partials    []string
+	Schema      string // file name of the reply schema, empty if there is none
+	Example     *promptExample
+}
+
+// argumentDoc documents a prompt argument with the handling declared in the frontmatter.
+type argumentDoc struct {
+	Name       string
+	EnvVar     string // environment variable used as a fallback when the argument is missing
+	Transforms string
+	Pattern    string
+}
+
+// promptExample is the first fixture case of a prompt and the prompt rendered with its arguments.
+type promptExample struct {
+	File   string // path of the arguments file, relative to the prompts directory
+	Args   string
+	Sample string // empty if the prompt renders differently on every call, e.g. because it uses the date
+}
+
+// docsOptions controls generatePromptDocs.
+type docsOptions struct {
+	partialsDirs []string
+	format       string
+	stamp        time.Time // time in the generation notice; no notice if zero
+	dateName     string    // field of the built-in date, defaultBuiltinDateName if empty
+}
+
+// generatePromptDocs returns the documentation of all prompts in the directory, sorted by prompt name.
+func generatePromptDocs(promptsDir string, opts docsOptions) ([]byte, error) {
+	docs, err := loadPromptDocs(promptsDir, opts.partialsDirs, opts.dateName)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	switch opts.format {
+	case "", docsFormatMarkdown:
+		writeMarkdownDocs(&buf, docs, opts.stamp)
+	case docsFormatHTML:
+		if err = htmlDocsTemplate.Execute(&buf, map[string]any{"Prompts": docs, "Stamp": docsStamp(opts.stamp)}); err != nil {
+			return nil, fmt.Errorf("execute HTML template: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid docs format %q, must be one of: %s, %s", opts.format, docsFormatMarkdown, docsFormatHTML)
+	}
+	return buf.Bytes(), nil
+}
+
+func loadPromptDocs(promptsDir string, partialsDirs []string, dateName string) ([]promptDoc, error) {
+	availableTemplates, err := getAvailableTemplates(promptsDir)
+	if err != nil {
+		return nil, err
+	}
+	collections, err := loadPromptCollections(promptsDir)
+	if err != nil {
+		return nil, err
+	}
+	parser := &PromptsParser{partialsDirs: partialsDirs, extractor: defaultExtractorOptions, builtinDateName: dateName}
+	tmpl, err := parser.ParseDir(promptsDir)
+	if err != nil {
+		return nil, fmt.Errorf("parse all prompts: %w", err)
+	}
+
+	docs := make([]promptDoc, 0, len(availableTemplates))
+	for _, templateName := range availableTemplates {
+		filePath := filepath.Join(promptsDir, templateName)
+		doc := promptDoc{Name: strings.TrimSuffix(templateName, templateExt)}
+		if collectionName, entry, ok := collections.Lookup(doc.Name); ok {
+			doc.Collection, doc.Title = collectionName, entry.Label
+		}
+		if doc.Description, err = parser.ExtractPromptDescriptionFromFile(filePath); err != nil {
+			return nil, fmt.Errorf("extract description of %q: %w", templateName, err)
+		}
+		var frontmatter *PromptFrontmatter
+		if frontmatter, err = loadPromptFrontmatter(filePath, parser.dateName()); err != nil {
+			return nil, fmt.Errorf("load frontmatter of %q: %w", templateName, err)
+		}
+		doc.Deprecated = frontmatter.DeprecationNotice()
+
+		var args, partials []string
+		if args, partials, err = parser.analyzeTemplate(tmpl, templateName); err != nil {
+			return nil, fmt.Errorf("analyze %q: %w", templateName, err)
+		}
+		args = frontmatter.ExcludeConstants(args)
+		slices.Sort(args)
+		for _, arg := range args {
+			argDoc := argumentDoc{Name: arg, EnvVar: strings.ToUpper(arg)}
+			if frontmatter != nil {
+				argDoc.Transforms = strings.Join(frontmatter.Arguments[arg].Transform, ", ")
+				argDoc.Pattern = frontmatter.Arguments[arg].Pattern
+			}
+			doc.Arguments = append(doc.Arguments, argDoc)
+		}
+		for _, partial := range partials {
+			doc.Partials = append(doc.Partials, strings.TrimSuffix(partial, templateExt))
+		}
+		if _, err = os.Stat(schemaFilePath(promptsDir, templateName)); err == nil {
+			doc.Schema = filepath.Base(schemaFilePath(promptsDir, templateName))
+		}
+		if doc.Example, err = loadPromptExample(parser, promptsDir, tmpl, templateName, partials); err != nil {
+			return nil, fmt.Errorf("example of %q: %w", templateName, err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// loadPromptExample returns the first fixture case of the prompt, or nil if it has none.
+func loadPromptExample(
+	parser *PromptsParser, promptsDir string, tmpl *template.Template, templateName string, partials []string,
+) (*promptExample, error) {
+	argsFiles, err := filepath.Glob(filepath.Join(defaultFixturesDir(promptsDir, templateName), "*"+fixtureArgsSuffix))
+	if err != nil || len(argsFiles) == 0 {
+		return nil, err
+	}
+	slices.Sort(argsFiles)
+	content, err := os.ReadFile(argsFiles[0])
+	if err != nil {
+		return nil, fmt.Errorf("read arguments: %w", err)
+	}
+	example := &promptExample{Args: strings.TrimSpace(string(content))}
+	if example.File, err = filepath.Rel(promptsDir, argsFiles[0]); err != nil {
+		return nil, err
+	}
+	example.File = filepath.ToSlash(example.File)
+	if !templateIsCacheable(tmpl, templateName, partials, parser.impureFields()) {
+		return example, nil
+	}
+	args, err := loadFixtureArgs(argsFiles[0])
+	if err != nil {
+		return nil, err
+	}
+	var rendered bytes.Buffer
+	if err = renderTemplateWithWarnings(
+		&rendered, io.Discard, promptsDir, parser.partialsDirs, templateName, args, nil, nil, true, parser.dateName(), nil,
+	); err != nil {
+		return nil, fmt.Errorf("render: %w", err)
+	}
+	example.Sample = strings.TrimSpace(rendered.String())
+	return example, nil
+}
+
+func docsStamp(stamp time.Time) string {
+	if stamp.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("Generated by mcp-prompt-engine %s on %s.", version, stamp.UTC().Format(time.RFC3339))
+}
+
+func writeMarkdownDocs(buf *bytes.Buffer, docs []promptDoc, stamp time.Time) {
+	mustFprintf(buf, "# Prompts\n\n")
+	if notice := docsStamp(stamp); notice != "" {
+		mustFprintf(buf, "_%s_\n\n", notice)
+	}
+	for _, doc := range docs {
+		mustFprintf(buf, "- [%s](#%s)\n", doc.Name, markdownAnchor(doc.Name))
+	}
+	for _, doc := range docs {
+		mustFprintf(buf, "\n## %s\n\n", doc.Name)
+		if doc.Title != "" {
+			mustFprintf(buf, "**%s**\n\n", markdownEscape(doc.Title))
+		}
+		if doc.Description != "" {
+			mustFprintf(buf, "%s\n\n", doc.Description)
+		}
+		if doc.Deprecated != "" {
+			mustFprintf(buf, "> **Deprecated:** %s\n\n", markdownEscape(doc.Deprecated))
+		}
+		if doc.Collection != "" {
+			mustFprintf(buf, "- Collection: %s\n", markdownEscape(doc.Collection))
+		}
+		if len(doc.Partials) > 0 {
+			mustFprintf(buf, "- Partials: `%s`\n", strings.Join(doc.Partials, "`, `"))
+		}
+		if doc.Schema != "" {
+			mustFprintf(buf, "- Reply schema: `%s`\n", doc.Schema)
+		}
+		if doc.Collection != "" || len(doc.Partials) > 0 || doc.Schema != "" {
+			mustFprintf(buf, "\n")
+		}
+
+		if len(doc.Arguments) == 0 {
+			mustFprintf(buf, "No arguments.\n")
+		} else {
+			mustFprintf(buf, "| Argument | Environment fallback | Transforms | Pattern |\n")
+			mustFprintf(buf, "| --- | --- | --- | --- |\n")
+			for _, arg := range doc.Arguments {
+				mustFprintf(buf, "| `%s` | `%s` | %s | %s |\n",
+					arg.Name, arg.EnvVar, markdownCode(arg.Transforms), markdownCode(arg.Pattern))
+			}
+		}
+
+		if doc.Example != nil {
+			mustFprintf(buf, "\n### Example\n\nArguments (`%s`):\n\n```json\n%s\n```\n", doc.Example.File, doc.Example.Args)
+			if doc.Example.Sample != "" {
+				mustFprintf(buf, "\nOutput:\n\n```text\n%s\n```\n", doc.Example.Sample)
+			}
+		}
+	}
+}
+
+// markdownAnchor returns the anchor GitHub generates for a heading of the prompt name.
+func markdownAnchor(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, " ", "-"))
+}
+
+// markdownEscape escapes the characters that would be taken as formatting or would end a table cell.
+func markdownEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "|", `\|`).Replace(s)
+}
+
+// markdownCode formats a table cell as code, or leaves it empty.
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
+
+var htmlDocsTemplate = htmltemplate.Must(htmltemplate.New("docs").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Prompts</title>
+</head>
+<body>
+<h1>Prompts</h1>
+{{- with .Stamp}}
+<p><em>{{.}}</em></p>
+{{- end}}
+<ul>
+{{- range .Prompts}}
+<li><a href="#{{.Name}}">{{.Name}}</a></li>
+{{- end}}
+</ul>
+{{- range .Prompts}}
+<h2 id="{{.Name}}">{{.Name}}</h2>
+{{- with .Title}}
+<p><strong>{{.}}</strong></p>
+{{- end}}
+{{- with .Description}}
+<p>{{.}}</p>
+{{- end}}
+{{- with .Deprecated}}
+<blockquote><strong>Deprecated:</strong> {{.}}</blockquote>
+{{- end}}
+{{- if or .Collection .Partials .Schema}}
+<ul>
+{{- with .Collection}}
+<li>Collection: {{.}}</li>
+{{- end}}
+{{- with .Partials}}
+<li>Partials: {{range $i, $partial := .}}{{if $i}}, {{end}}<code>{{$partial}}</code>{{end}}</li>
+{{- end}}
+{{- with .Schema}}
+<li>Reply schema: <code>{{.}}</code></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Arguments}}
+<table>
+<tr><th>Argument</th><th>Environment fallback</th><th>Transforms</th><th>Pattern</th></tr>
+{{- range .Arguments}}
+<tr><td><code>{{.Name}}</code></td><td><code>{{.EnvVar}}</code></td><td>{{with .Transforms}}<code>{{.}}</code>{{end}}</td><td>{{with .Pattern}}<code>{{.}}</code>{{end}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No arguments.</p>
+{{- end}}
+{{- with .Example}}
+<h3>Example</h3>
+<p>Arguments (<code>{{.File}}</code>):</p>
+<pre><code>{{.Args}}</code></pre>
+{{- with .Sample}}
+<p>Output:</p>
+<pre><code>{{.}}</code></pre>
+{{- end}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
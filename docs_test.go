@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type DocsTestSuite struct {
+	suite.Suite
+}
+
+func TestDocsTestSuite(t *testing.T) {
+	suite.Run(t, new(DocsTestSuite))
+}
+
+func (s *DocsTestSuite) runDocs(args ...string) (string, error) {
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	err := app.Run(context.Background(), append([]string{app.Name, "--color", "never", "docs", "generate"}, args...))
+	return buf.String(), err
+}
+
+// TestGolden tests the Markdown documentation of the testdata directory against testdata/PROMPTS.md
+func (s *DocsTestSuite) TestGolden() {
+	expected, err := os.ReadFile(filepath.Join("testdata", "PROMPTS.md"))
+	require.NoError(s.T(), err)
+
+	output, err := s.runDocs("--prompts", "./testdata")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), string(expected), output,
+		"regenerate with: mcp-prompt-engine --prompts testdata docs generate -o testdata/PROMPTS.md")
+}
+
+// TestMetadata tests that collections, frontmatter, and impure prompts are documented
+func (s *DocsTestSuite) TestMetadata() {
+	promptsDir := s.T().TempDir()
+	for name, content := range map[string]string{
+		"collections.yaml": "collections:\n  - name: Git\n    prompts:\n      - name: commit\n        label: Commit | message\n",
+		"commit.tmpl": "---\ndeprecated: use git_commit\narguments:\n  email:\n    transform: [trim, lower]\n" +
+			"    pattern: \"^[^@]+@[^@|]+$\"\n---\n{{/* Write a commit message */}}\n{{.type}} by {{.email}} on {{.date}}",
+		"fixtures/commit/a.args.json": `{"type": "fix", "email": "a@b.c"}`,
+	} {
+		require.NoError(s.T(), os.MkdirAll(filepath.Dir(filepath.Join(promptsDir, name)), 0755))
+		require.NoError(s.T(), os.WriteFile(filepath.Join(promptsDir, name), []byte(content), 0644))
+	}
+
+	content, err := generatePromptDocs(promptsDir, docsOptions{})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "# Prompts\n\n- [commit](#commit)\n\n## commit\n\n"+
+		"**Commit \\| message**\n\nWrite a commit message\n\n> **Deprecated:** use git\\_commit\n\n- Collection: Git\n\n"+
+		"| Argument | Environment fallback | Transforms | Pattern |\n| --- | --- | --- | --- |\n"+
+		"| `email` | `EMAIL` | `trim, lower` | `^[^@]+@[^@\\|]+$` |\n| `type` | `TYPE` |  |  |\n\n"+
+		"### Example\n\nArguments (`fixtures/commit/a.args.json`):\n\n```json\n{\"type\": \"fix\", \"email\": \"a@b.c\"}\n```\n",
+		string(content), "prompts using the date have no sample output")
+
+	stamp := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	content, err = generatePromptDocs(promptsDir, docsOptions{format: docsFormatHTML, stamp: stamp})
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), string(content), "on 2025-01-02T03:04:05Z.</em>")
+	assert.Contains(s.T(), string(content), "<td><code>^[^@]&#43;@[^@|]&#43;$</code></td>")
+	assert.Contains(s.T(), string(content), "<blockquote><strong>Deprecated:</strong> use git_commit</blockquote>")
+
+	_, err = generatePromptDocs(promptsDir, docsOptions{format: "pdf"})
+	require.ErrorContains(s.T(), err, `invalid docs format "pdf"`)
+}
+
+// TestCheck tests that --check fails once a prompt changes after the document was generated
+func (s *DocsTestSuite) TestCheck() {
+	promptsDir := s.T().TempDir()
+	greetingFixturesDir := filepath.Join(promptsDir, "fixtures", "greeting")
+	require.NoError(s.T(), os.MkdirAll(greetingFixturesDir, 0755))
+	for _, name := range []string{"greeting.tmpl", "fixtures/greeting/world.args.json"} {
+		content, err := os.ReadFile(filepath.Join("testdata", name))
+		require.NoError(s.T(), err)
+		require.NoError(s.T(), os.WriteFile(filepath.Join(promptsDir, name), content, 0644))
+	}
+	output := filepath.Join(s.T().TempDir(), "PROMPTS.md")
+
+	_, err := s.runDocs("--prompts", promptsDir, "--check", "-o", output)
+	require.EqualError(s.T(), err, output+" is out of date, run docs generate to update it", "a missing file is out of date")
+
+	out, err := s.runDocs("--prompts", promptsDir, "-o", output)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "✓ Documentation written to "+output+"\n", out)
+	out, err = s.runDocs("--prompts", promptsDir, "--check", "-o", output)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "✓ "+output+" is up to date\n", out)
+
+	require.NoError(s.T(), os.WriteFile(filepath.Join(greetingFixturesDir, "world.args.json"), []byte(`{"name": "Docs"}`), 0644))
+	_, err = s.runDocs("--prompts", promptsDir, "--check", "-o", output)
+	require.EqualError(s.T(), err, output+" is out of date, run docs generate to update it")
+
+	_, err = s.runDocs("--prompts", promptsDir, "--check")
+	require.EqualError(s.T(), err, "--check requires --output")
+	_, err = s.runDocs("--prompts", promptsDir, "--check", "--stamp", "-o", output)
+	require.EqualError(s.T(), err, "--check cannot be combined with --stamp")
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io"
+	"strings"
+)
+
+// errorLines returns the messages of the errors joined in err, one per line, each with the context of
+// the errors wrapping it, e.g. "new prompts server: reload prompts: parse a.tmpl: ..." for every failing file.
+func errorLines(err error) []string {
+	if err == nil {
+		return nil
+	}
+	return appendErrorLines(nil, "", err)
+}
+
+func appendErrorLines(lines []string, prefix string, err error) []string {
+	switch wrapped := err.(type) {
+	case interface{ Unwrap() []error }:
+		// Only flatten joined errors, as the messages of other errors wrapping several ones
+		// are more than their parts, e.g. fmt.Errorf("%w: %w", ...)
+		errs := wrapped.Unwrap()
+		parts := make([]string, 0, len(errs))
+		for _, e := range errs {
+			parts = append(parts, e.Error())
+		}
+		if err.Error() == strings.Join(parts, "\n") {
+			for _, e := range errs {
+				lines = appendErrorLines(lines, prefix, e)
+			}
+			return lines
+		}
+	case interface{ Unwrap() error }:
+		if inner := wrapped.Unwrap(); inner != nil {
+			if context, ok := strings.CutSuffix(err.Error(), inner.Error()); ok {
+				return appendErrorLines(lines, prefix+context, inner)
+			}
+		}
+	}
+	return append(lines, prefix+err.Error())
+}
+
+// printErrorLines writes every error joined in err on its own line, marked with the error icon.
+func printErrorLines(w io.Writer, err error) {
+	for _, line := range errorLines(err) {
+		mustFprintf(w, "%s %s\n", errorIcon(), line)
+	}
+}
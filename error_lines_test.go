@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ErrorLinesTestSuite struct {
+	suite.Suite
+}
+
+func TestErrorLinesTestSuite(t *testing.T) {
+	suite.Run(t, new(ErrorLinesTestSuite))
+}
+
+// TestErrorLines tests that joined errors are printed one per line, each with the context wrapping it
+func (s *ErrorLinesTestSuite) TestErrorLines() {
+	errA, errB := errors.New("parse a.tmpl: unexpected EOF"), errors.New("parse b.tmpl: unexpected {{end}}")
+	for _, tc := range []struct {
+		name     string
+		err      error
+		expected []string
+	}{
+		{name: "nil", err: nil, expected: nil},
+		{name: "single", err: fmt.Errorf("serve: %w", errA), expected: []string{"serve: parse a.tmpl: unexpected EOF"}},
+		{
+			name: "joined and wrapped",
+			err: fmt.Errorf("serve: %w", errors.Join(
+				errors.New("watch prompts directory: no such file or directory"),
+				fmt.Errorf("reload prompts: %w", errors.Join(errA, errB)),
+			)),
+			expected: []string{
+				"serve: watch prompts directory: no such file or directory",
+				"serve: reload prompts: parse a.tmpl: unexpected EOF",
+				"serve: reload prompts: parse b.tmpl: unexpected {{end}}",
+			},
+		},
+		{
+			name:     "several wrapped errors in one message",
+			err:      fmt.Errorf("%w, then %w", errA, errB),
+			expected: []string{"parse a.tmpl: unexpected EOF, then parse b.tmpl: unexpected {{end}}"},
+		},
+	} {
+		assert.Equal(s.T(), tc.expected, errorLines(tc.err), tc.name)
+	}
+
+	var buf bytes.Buffer
+	printErrorLines(&buf, errors.Join(errA, errB))
+	assert.Equal(s.T(), "✗ parse a.tmpl: unexpected EOF\n✗ parse b.tmpl: unexpected {{end}}\n", removeANSIColors(buf.String()))
+}
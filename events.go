@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Types of the change events.
+const (
+	EventServerStarted   = "server_started"
+	EventReloadSucceeded = "reload_succeeded"
+	EventReloadFailed    = "reload_failed"
+	EventShutdown        = "shutdown"
+)
+
+// eventClientBuffer is the number of events kept for a socket client that reads slower than they are emitted.
+// A client falling further behind is disconnected.
+const eventClientBuffer = 64
+
+// Event is a change event of the server, written as a JSON line to the events file and socket.
+// Added, removed, and changed prompts are given as "name@checksum" like in the reload log,
+// with "name@old->new" for changed prompts.
+type Event struct {
+	Seq     uint64           `json:"seq"` // increases by one with every event, also across restarts with the same file
+	Time    time.Time        `json:"time"`
+	Type    string           `json:"type"`
+	Prompts []string         `json:"prompts,omitempty"` // served prompts, for server_started
+	Added   []string         `json:"added,omitempty"`
+	Removed []string         `json:"removed,omitempty"`
+	Changed []string         `json:"changed,omitempty"`
+	Errors  []EventFileError `json:"errors,omitempty"` // why the prompts were not reloaded, for reload_failed
+}
+
+// EventFileError is an error of a template file. File is empty if the error cannot be attributed to a file.
+type EventFileError struct {
+	File  string `json:"file,omitempty"`
+	Error string `json:"error"`
+}
+
+// EventStream writes events to an append-only JSON lines file and broadcasts them to the clients
+// of a Unix socket. It is safe for concurrent use.
+type EventStream struct {
+	mu       sync.Mutex
+	seq      uint64
+	file     *os.File
+	listener net.Listener
+	clients  map[chan []byte]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// OpenEventStream opens the events file, continuing the sequence numbers of the events it already holds,
+// and listens on the events socket. Either path may be empty.
+func OpenEventStream(filePath string, socketPath string) (*EventStream, error) {
+	es := &EventStream{clients: make(map[chan []byte]struct{})}
+	if filePath != "" {
+		seq, err := lastEventSeq(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if es.file, err = os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600); err != nil {
+			return nil, fmt.Errorf("open events file: %w", err)
+		}
+		es.seq = seq
+	}
+	if socketPath != "" {
+		listener, err := listenPrivateSocket(socketPath)
+		if err != nil {
+			if es.file != nil {
+				_ = es.file.Close()
+			}
+			return nil, fmt.Errorf("events socket: %w", err)
+		}
+		es.listener = listener
+		es.wg.Add(1)
+		go es.acceptClients()
+	}
+	return es, nil
+}
+
+// lastEventSeq returns the sequence number of the last event of the file, or 0 if there is none.
+func lastEventSeq(filePath string) (uint64, error) {
+	content, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read events file: %w", err)
+	}
+	content = bytes.TrimRight(content, "\n")
+	if len(content) == 0 {
+		return 0, nil
+	}
+	var last Event
+	if err = json.Unmarshal(content[bytes.LastIndexByte(content, '\n')+1:], &last); err != nil {
+		return 0, fmt.Errorf("read last event of %q: %w", filePath, err)
+	}
+	return last.Seq, nil
+}
+
+// Emit numbers and timestamps the event, then writes it to the file and to the connected socket clients.
+func (es *EventStream) Emit(event Event) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.closed {
+		return errors.New("event stream is closed")
+	}
+	es.seq++
+	event.Seq = es.seq
+	event.Time = time.Now().UTC()
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	line = append(line, '\n')
+	for client := range es.clients {
+		select {
+		case client <- line:
+		default:
+			delete(es.clients, client)
+			close(client)
+		}
+	}
+	if es.file != nil {
+		if _, err = es.file.Write(line); err != nil {
+			return fmt.Errorf("write event: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close disconnects the socket clients, once they received the emitted events, and closes the file and socket.
+func (es *EventStream) Close() error {
+	es.mu.Lock()
+	if es.closed {
+		es.mu.Unlock()
+		return nil
+	}
+	es.closed = true
+	for client := range es.clients {
+		delete(es.clients, client)
+		close(client)
+	}
+	es.mu.Unlock()
+
+	var errs []error
+	if es.listener != nil {
+		errs = append(errs, es.listener.Close())
+	}
+	es.wg.Wait()
+	if es.file != nil {
+		errs = append(errs, es.file.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (es *EventStream) acceptClients() {
+	defer es.wg.Done()
+	for {
+		conn, err := es.listener.Accept()
+		if err != nil {
+			return
+		}
+		client := make(chan []byte, eventClientBuffer)
+		es.mu.Lock()
+		if es.closed {
+			es.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		es.clients[client] = struct{}{}
+		es.mu.Unlock()
+
+		es.wg.Add(1)
+		go func() {
+			defer es.wg.Done()
+			defer func() { _ = conn.Close() }()
+			for line := range client {
+				_ = conn.SetWriteDeadline(time.Now().Add(controlTimeout))
+				if _, err := conn.Write(line); err != nil {
+					es.removeClient(client)
+					return
+				}
+			}
+		}()
+	}
+}
+
+func (es *EventStream) removeClient(client chan []byte) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if _, ok := es.clients[client]; ok {
+		delete(es.clients, client)
+		close(client)
+	}
+}
+
+// clientCount returns the number of connected socket clients.
+func (es *EventStream) clientCount() int {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	return len(es.clients)
+}
+
+// emitEvent emits the event if the server has an event stream, logging failures.
+func (ps *PromptsServer) emitEvent(event Event) {
+	if ps.events == nil {
+		return
+	}
+	if err := ps.events.Emit(event); err != nil {
+		ps.logger.Error("Failed to emit event", "type", event.Type, "error", err)
+	}
+}
+
+// templateFileErrors attributes the error of a failed reload to the template files of the prompts directory
+// that do not parse on their own. Errors of other causes, like missing partials, are not attributed to a file.
+func (ps *PromptsServer) templateFileErrors(reloadErr error) []EventFileError {
+	var fileErrors []EventFileError
+	filePaths, _ := filepath.Glob(filepath.Join(ps.promptsDir, "*"+templateExt))
+	funcs, err := ps.parser.funcMap(ps.promptsDir)
+	if err == nil {
+		for _, filePath := range filePaths {
+			if !ps.parser.includes(filepath.Base(filePath)) {
+				continue
+			}
+			if err = parseTemplateFile(template.New("base").Funcs(funcs), funcs, filePath); err != nil {
+				fileErrors = append(fileErrors, EventFileError{File: filepath.Base(filePath), Error: err.Error()})
+			}
+		}
+	}
+	if len(fileErrors) == 0 {
+		fileErrors = []EventFileError{{Error: reloadErr.Error()}}
+	}
+	return fileErrors
+}
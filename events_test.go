@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type EventsTestSuite struct {
+	suite.Suite
+	promptsDir string
+	eventsFile string
+}
+
+func TestEventsTestSuite(t *testing.T) {
+	suite.Run(t, new(EventsTestSuite))
+}
+
+func (s *EventsTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.eventsFile = filepath.Join(s.T().TempDir(), "events.jsonl")
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello {{.name}}")
+	s.writeFile("farewell.tmpl", "{{/* Farewell */}}\nBye {{.name}}")
+}
+
+func (s *EventsTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+func (s *EventsTestSuite) readEvents() []Event {
+	content, err := os.ReadFile(s.eventsFile)
+	require.NoError(s.T(), err)
+	var events []Event
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var event Event
+		require.NoError(s.T(), json.Unmarshal([]byte(line), &event), "line %q", line)
+		events = append(events, event)
+	}
+	return events
+}
+
+// promptNamesOf strips the checksums of the prompts of reload events
+func promptNamesOf(entries []string) []string {
+	names := []string{}
+	for _, entry := range entries {
+		name, _, _ := strings.Cut(entry, "@")
+		names = append(names, name)
+	}
+	return names
+}
+
+// TestServerEvents tests the events of a server whose prompts are added, removed, changed, and broken
+func (s *EventsTestSuite) TestServerEvents() {
+	stream, err := OpenEventStream(s.eventsFile, "")
+	require.NoError(s.T(), err)
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithEvents(stream), WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+
+	s.writeFile("review.tmpl", "{{/* Review */}}\nReview {{.diff}}")
+	require.NoError(s.T(), os.Remove(filepath.Join(s.promptsDir, "farewell.tmpl")))
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHi {{.name}}")
+	require.NoError(s.T(), promptsServer.reloadPrompts())
+
+	require.NoError(s.T(), promptsServer.reloadPrompts())
+
+	s.writeFile("broken.tmpl", "{{/* Broken */}}\n{{.name")
+	require.Error(s.T(), promptsServer.reloadPrompts())
+
+	require.NoError(s.T(), promptsServer.Close())
+	require.NoError(s.T(), promptsServer.Close(), "closing again emits nothing")
+	require.NoError(s.T(), stream.Close())
+
+	events := s.readEvents()
+	types := make([]string, 0, len(events))
+	for i, event := range events {
+		types = append(types, event.Type)
+		assert.Equal(s.T(), uint64(i+1), event.Seq)
+		assert.False(s.T(), event.Time.IsZero())
+	}
+	require.Equal(s.T(), []string{
+		EventServerStarted, EventReloadSucceeded, EventReloadSucceeded, EventReloadFailed, EventShutdown,
+	}, types)
+
+	assert.Equal(s.T(), []string{"farewell", "greet"}, events[0].Prompts)
+
+	assert.Equal(s.T(), []string{"review"}, promptNamesOf(events[1].Added))
+	assert.Equal(s.T(), []string{"farewell"}, promptNamesOf(events[1].Removed))
+	assert.Equal(s.T(), []string{"greet"}, promptNamesOf(events[1].Changed))
+	assert.Regexp(s.T(), `^greet@[0-9a-f]{12}->[0-9a-f]{12}$`, events[1].Changed[0])
+
+	assert.Empty(s.T(), events[2].Added)
+	assert.Empty(s.T(), events[2].Removed)
+	assert.Empty(s.T(), events[2].Changed)
+
+	require.Len(s.T(), events[3].Errors, 1)
+	assert.Equal(s.T(), "broken.tmpl", events[3].Errors[0].File)
+	assert.Contains(s.T(), events[3].Errors[0].Error, "unclosed action")
+}
+
+// TestReloadFailedWithoutFile tests that errors not caused by the syntax of a file are reported as is
+func (s *EventsTestSuite) TestReloadFailedWithoutFile() {
+	stream, err := OpenEventStream(s.eventsFile, "")
+	require.NoError(s.T(), err)
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithEvents(stream), WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello {{template \"_missing\" .}}")
+	reloadErr := promptsServer.reloadPrompts()
+	require.Error(s.T(), reloadErr)
+	require.NoError(s.T(), stream.Close())
+
+	events := s.readEvents()
+	require.Len(s.T(), events, 2)
+	assert.Equal(s.T(), EventReloadFailed, events[1].Type)
+	assert.Equal(s.T(), []EventFileError{{Error: reloadErr.Error()}}, events[1].Errors)
+	assert.Error(s.T(), stream.Emit(Event{Type: EventShutdown}), "a closed stream emits nothing")
+}
+
+// TestSeqContinuesInExistingFile tests that the sequence numbers continue those of the events file
+func (s *EventsTestSuite) TestSeqContinuesInExistingFile() {
+	for range 2 {
+		stream, err := OpenEventStream(s.eventsFile, "")
+		require.NoError(s.T(), err)
+		require.NoError(s.T(), stream.Emit(Event{Type: EventServerStarted}))
+		require.NoError(s.T(), stream.Emit(Event{Type: EventShutdown}))
+		require.NoError(s.T(), stream.Close())
+	}
+	var seqs []uint64
+	for _, event := range s.readEvents() {
+		seqs = append(seqs, event.Seq)
+	}
+	assert.Equal(s.T(), []uint64{1, 2, 3, 4}, seqs)
+
+	require.NoError(s.T(), os.WriteFile(s.eventsFile, []byte("not json\n"), 0600))
+	_, err := OpenEventStream(s.eventsFile, "")
+	assert.ErrorContains(s.T(), err, "read last event")
+}
+
+// TestEventsSocket tests that socket clients receive the events emitted while they are connected
+func (s *EventsTestSuite) TestEventsSocket() {
+	// Unix socket paths are limited to about 100 bytes, which test temp dirs may exceed
+	socketDir, err := os.MkdirTemp("", "mpe-events-")
+	require.NoError(s.T(), err)
+	defer func() { _ = os.RemoveAll(socketDir) }()
+	socketPath := filepath.Join(socketDir, "events.sock")
+
+	stream, err := OpenEventStream("", socketPath)
+	require.NoError(s.T(), err)
+	info, err := os.Stat(socketPath)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), os.FileMode(0600), info.Mode().Perm())
+
+	require.NoError(s.T(), stream.Emit(Event{Type: EventServerStarted}), "events without clients are dropped")
+
+	conn, err := net.Dial("unix", socketPath)
+	require.NoError(s.T(), err)
+	defer func() { _ = conn.Close() }()
+	require.Eventually(s.T(), func() bool { return stream.clientCount() == 1 }, time.Second, time.Millisecond)
+
+	require.NoError(s.T(), stream.Emit(Event{Type: EventReloadSucceeded, Added: []string{"review@0123456789ab"}}))
+	require.NoError(s.T(), stream.Emit(Event{Type: EventShutdown}))
+	require.NoError(s.T(), stream.Close())
+
+	var received []Event
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var event Event
+		require.NoError(s.T(), json.Unmarshal(scanner.Bytes(), &event))
+		received = append(received, event)
+	}
+	require.Len(s.T(), received, 2, "the stream closes the connection after the last event")
+	assert.Equal(s.T(), uint64(2), received[0].Seq)
+	assert.Equal(s.T(), []string{"review@0123456789ab"}, received[0].Added)
+	assert.Equal(s.T(), uint64(3), received[1].Seq)
+	assert.Equal(s.T(), EventShutdown, received[1].Type)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"text/template"
+	"text/template/parse"
+
+	"gopkg.in/yaml.v3"
+)
+
+// flagFuncName is the helper that tells templates whether a feature flag is on, e.g. {{if flag "new_review_rubric"}}.
+const flagFuncName = "flag"
+
+// FeatureFlags are the values of the flags file given with --flags-file, a YAML mapping of flag names
+// to booleans, e.g. "new_review_rubric: true". Flags missing from the file are off.
+type FeatureFlags struct {
+	values map[string]bool
+	// unknown holds the missing flags that templates asked for, so that each is only logged once
+	unknown sync.Map
+}
+
+// loadFeatureFlags reads the flags file. An empty path returns flags that are all off.
+func loadFeatureFlags(path string) (*FeatureFlags, error) {
+	flags := &FeatureFlags{values: make(map[string]bool)}
+	if path == "" {
+		return flags, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read flags file: %w", err)
+	}
+	if err = yaml.Unmarshal(content, &flags.values); err != nil {
+		return nil, fmt.Errorf("parse flags file %q: %w", path, err)
+	}
+	for name := range flags.values {
+		if !funcAliasNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid flag name %q in flags file %q", name, path)
+		}
+	}
+	return flags, nil
+}
+
+// Value returns the value of the flag and whether the flags file defines it.
+func (ff *FeatureFlags) Value(name string) (value bool, defined bool) {
+	value, defined = ff.values[name]
+	return value, defined
+}
+
+// Names returns the names of the flags defined by the flags file, sorted.
+func (ff *FeatureFlags) Names() []string {
+	return slices.Sorted(maps.Keys(ff.values))
+}
+
+// templateFunc returns the flag helper of the flags. The first use of every flag missing from the file is logged.
+func (ff *FeatureFlags) templateFunc(logger *slog.Logger) func(name string) bool {
+	return func(name string) bool {
+		value, defined := ff.Value(name)
+		if !defined {
+			if _, logged := ff.unknown.LoadOrStore(name, struct{}{}); !logged {
+				logger.Warn("Feature flag is not defined in the flags file, treating it as off", "flag", name)
+			}
+		}
+		return value
+	}
+}
+
+// ExtractFeatureFlags returns the sorted names of the feature flags that the template and the partials it includes
+// pass literally to the flag helper.
+func (pp *PromptsParser) ExtractFeatureFlags(tmpl *template.Template, templateName string) ([]string, error) {
+	targetTemplate := lookupPartial(tmpl, templateName)
+	if targetTemplate == nil {
+		return nil, fmt.Errorf("template %q not found", templateName)
+	}
+	_, partials, err := pp.analyzeTemplate(tmpl, targetTemplate.Name())
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]struct{})
+	collectFlagNames(targetTemplate.Root, names)
+	for _, partial := range partials {
+		if t := lookupPartial(tmpl, partial); t != nil {
+			collectFlagNames(t.Root, names)
+		}
+	}
+	return slices.Sorted(maps.Keys(names)), nil
+}
+
+// collectFlagNames adds the names of the flags under the node to names.
+func collectFlagNames(node parse.Node, names map[string]struct{}) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectFlagNames(child, names)
+		}
+	case *parse.ActionNode:
+		collectFlagNames(n.Pipe, names)
+	case *parse.IfNode:
+		collectFlagNames(n.Pipe, names)
+		collectFlagNames(n.List, names)
+		collectFlagNames(n.ElseList, names)
+	case *parse.RangeNode:
+		collectFlagNames(n.Pipe, names)
+		collectFlagNames(n.List, names)
+		collectFlagNames(n.ElseList, names)
+	case *parse.WithNode:
+		collectFlagNames(n.Pipe, names)
+		collectFlagNames(n.List, names)
+		collectFlagNames(n.ElseList, names)
+	case *parse.TemplateNode:
+		collectFlagNames(n.Pipe, names)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectFlagNames(cmd, names)
+		}
+	case *parse.CommandNode:
+		if len(n.Args) >= 2 {
+			if ident, ok := n.Args[0].(*parse.IdentifierNode); ok && ident.Ident == flagFuncName {
+				if name, ok := n.Args[1].(*parse.StringNode); ok {
+					names[name.Text] = struct{}{}
+				}
+			}
+		}
+		for _, arg := range n.Args {
+			collectFlagNames(arg, names)
+		}
+	}
+}
+
+// featureFlagUsages returns the templates using each flag, by flag name.
+func featureFlagUsages(parser *PromptsParser, tmpl *template.Template, templateNames []string) (map[string][]string, error) {
+	usages := make(map[string][]string)
+	for _, templateName := range templateNames {
+		flags, err := parser.ExtractFeatureFlags(tmpl, templateName)
+		if err != nil {
+			return nil, fmt.Errorf("template %q: %w", templateName, err)
+		}
+		for _, flag := range flags {
+			usages[flag] = append(usages[flag], templateName)
+		}
+	}
+	return usages, nil
+}
+
+// printFeatureFlags writes every flag used by the templates or defined by the flags file with its value,
+// followed by the templates using it, one per indented line.
+func printFeatureFlags(w io.Writer, flags *FeatureFlags, usages map[string][]string) {
+	names := slices.Sorted(maps.Keys(usages))
+	for _, name := range flags.Names() {
+		if _, used := usages[name]; !used {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		mustFprintf(w, "%s\n", localize("flags.none"))
+		return
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		value, defined := flags.Value(name)
+		if defined {
+			mustFprintf(w, "%s: %t\n", highlightText(name), value)
+		} else {
+			mustFprintf(w, "%s: %t %s\n", highlightText(name), value, infoText(localize("flags.undefined")))
+		}
+		if len(usages[name]) == 0 {
+			mustFprintf(w, "  %s\n", infoText(localize("flags.unused")))
+		}
+		for _, templateName := range usages[name] {
+			mustFprintf(w, "  %s\n", templateText(templateName))
+		}
+	}
+}
+
+// printFeatureFlagsReport parses the prompts directory and prints the feature flags of its templates.
+func printFeatureFlagsReport(w io.Writer, promptsDir string, partialsDirs []string, flagsFile string) error {
+	flags, err := loadFeatureFlags(flagsFile)
+	if err != nil {
+		return err
+	}
+	templateNames, err := getAvailableTemplates(promptsDir)
+	if err != nil {
+		return err
+	}
+	usages := make(map[string][]string)
+	if len(templateNames) > 0 {
+		parser := &PromptsParser{partialsDirs: partialsDirs}
+		tmpl, err := parser.ParseDir(promptsDir)
+		if err != nil {
+			return fmt.Errorf("parse all prompts: %w", err)
+		}
+		if usages, err = featureFlagUsages(parser, tmpl, templateNames); err != nil {
+			return err
+		}
+	}
+	printFeatureFlags(w, flags, usages)
+	return nil
+}
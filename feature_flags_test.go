@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type FeatureFlagsTestSuite struct {
+	suite.Suite
+	promptsDir string
+	flagsFile  string
+}
+
+func TestFeatureFlagsTestSuite(t *testing.T) {
+	suite.Run(t, new(FeatureFlagsTestSuite))
+}
+
+func (s *FeatureFlagsTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.flagsFile = filepath.Join(s.T().TempDir(), "flags.yaml")
+	s.writeFile("_rubric.tmpl", `{{define "_rubric"}}{{if flag "new_review_rubric"}}new rubric{{else}}old rubric{{end}}{{end}}`)
+	s.writeFile("review.tmpl", "{{/* Review */}}\nReview {{.branch}} with the {{template \"_rubric\" .}}"+
+		`{{if and (flag "strict_mode") .strict}}, strictly{{end}}.`)
+	s.writeFile("summarize.tmpl", "{{/* Summarize */}}\nSummarize {{.text}}")
+	s.writeFlags("new_review_rubric: true\nstrict_mode: true\nlegacy_footer: false\n")
+}
+
+func (s *FeatureFlagsTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+func (s *FeatureFlagsTestSuite) writeFlags(content string) {
+	require.NoError(s.T(), os.WriteFile(s.flagsFile, []byte(content), 0644))
+}
+
+func (s *FeatureFlagsTestSuite) getPrompt(promptsServer *PromptsServer, name string, args map[string]any) string {
+	request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "prompts/get",
+		"params": map[string]any{"name": name, "arguments": args}})
+	require.NoError(s.T(), err)
+	response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok, "%s must render", name)
+	return response.Result.(mcp.GetPromptResult).Messages[0].Content.(mcp.TextContent).Text
+}
+
+// TestFlagFlipOnReload tests that editing the flags file changes the rendered prompts after a reload
+func (s *FeatureFlagsTestSuite) TestFlagFlipOnReload() {
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithFeatureFlags(s.flagsFile), WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	args := map[string]any{"branch": "main", "strict": "true"}
+	assert.Equal(s.T(), "Review main with the new rubric, strictly.", s.getPrompt(promptsServer, "review", args))
+
+	s.writeFlags("new_review_rubric: false\nstrict_mode: true\n")
+	require.NoError(s.T(), promptsServer.Reload())
+	assert.Equal(s.T(), "Review main with the old rubric, strictly.", s.getPrompt(promptsServer, "review", args))
+
+	s.writeFlags("new_review_rubric: [true]\n")
+	assert.ErrorContains(s.T(), promptsServer.Reload(), "parse flags file")
+	assert.Equal(s.T(), "Review main with the old rubric, strictly.", s.getPrompt(promptsServer, "review", args),
+		"a failed reload keeps the previous flags")
+
+	assert.Contains(s.T(), promptsServer.watchedFiles(), filepath.Clean(s.flagsFile))
+}
+
+// TestUndefinedFlagsAreOff tests that flags missing from the file, or without a file, are off and logged once
+func (s *FeatureFlagsTestSuite) TestUndefinedFlagsAreOff() {
+	var logs bytes.Buffer
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithWatchMode(WatchModeOff, 0),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	args := map[string]any{"branch": "main", "strict": "true"}
+	for range 2 {
+		assert.Equal(s.T(), "Review main with the old rubric.", s.getPrompt(promptsServer, "review", args))
+	}
+	assert.Equal(s.T(), 1, strings.Count(logs.String(), "flag=new_review_rubric"))
+	assert.Equal(s.T(), 1, strings.Count(logs.String(), "flag=strict_mode"))
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), renderTemplate(&buf, s.promptsDir, nil, "review", map[string]string{"branch": "main"}, true))
+	assert.Equal(s.T(), "Review main with the old rubric.", buf.String(), "the render command has no flags file")
+
+	_, err = NewPromptsServer(s.promptsDir, WithFeatureFlags(filepath.Join(s.T().TempDir(), "none.yaml")))
+	assert.ErrorContains(s.T(), err, "read flags file")
+}
+
+// TestUsageReport tests the flags command, list --verbose, and the warnings of validate
+func (s *FeatureFlagsTestSuite) TestUsageReport() {
+	s.writeFlags("new_review_rubric: true\nlegacy_footer: false\n")
+
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	require.NoError(s.T(), app.Run(context.Background(),
+		[]string{app.Name, "--color", "never", "flags", s.promptsDir, "--flags-file", s.flagsFile}))
+	assert.Equal(s.T(), strings.Join([]string{
+		"legacy_footer: false",
+		"  (not used by any template)",
+		"new_review_rubric: true",
+		"  review.tmpl",
+		"strict_mode: false (not in the flags file)",
+		"  review.tmpl",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	require.NoError(s.T(), listTemplates(&buf, s.promptsDir, listOptions{verbose: true, flagsFile: s.flagsFile}))
+	assert.Contains(s.T(), buf.String(), "  Flags: new_review_rubric=true, strict_mode=false\n")
+	assert.NotContains(s.T(), buf.String(), "summarize.tmpl\n  Description: Summarize\n  Variables: text\n  Flags")
+
+	buf.Reset()
+	require.NoError(s.T(), validateTemplates(&buf, s.promptsDir, nil, "", validateOptions{flagsFile: s.flagsFile}))
+	assert.Contains(s.T(), buf.String(),
+		"⚠ review.tmpl - feature flag 'strict_mode' is not defined in the flags file and is off\n")
+	assert.Contains(s.T(), buf.String(), "⚠ "+s.flagsFile+" - feature flag 'legacy_footer' is not used by any template\n")
+
+	buf.Reset()
+	require.NoError(s.T(), validateTemplates(&buf, s.promptsDir, nil, "summarize", validateOptions{flagsFile: s.flagsFile}))
+	assert.NotContains(s.T(), buf.String(), "legacy_footer", "unused flags are only reported for all templates")
+
+	buf.Reset()
+	require.NoError(s.T(), printFeatureFlagsReport(&buf, s.T().TempDir(), nil, ""))
+	assert.Equal(s.T(), "No feature flags are used or defined\n", buf.String())
+}
+
+// TestExtractFeatureFlags tests finding the flags in nested pipelines and ignoring non-literal names
+func (s *FeatureFlagsTestSuite) TestExtractFeatureFlags() {
+	parser := &PromptsParser{}
+	tmpl, err := parser.ParseTemplates(map[string]string{
+		"nested":  `{{range .items}}{{with flag "a"}}{{end}}{{end}}{{if not (flag "b")}}{{flag .name}}{{end}}{{flag "a"}}`,
+		"partial": `{{template "nested" .}}{{if flag "c" | not}}{{end}}`,
+	})
+	require.NoError(s.T(), err)
+
+	flags, err := parser.ExtractFeatureFlags(tmpl, "nested")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"a", "b"}, flags)
+	flags, err = parser.ExtractFeatureFlags(tmpl, "partial")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"a", "b", "c"}, flags)
+
+	s.writeFlags("bad-name: true\n")
+	_, err = loadFeatureFlags(s.flagsFile)
+	assert.ErrorContains(s.T(), err, `invalid flag name "bad-name"`)
+}
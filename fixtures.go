@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Fixture file suffixes: each "<case>.args.json" is rendered and compared to "<case>.expected.txt".
+const (
+	fixtureArgsSuffix     = ".args.json"
+	fixtureExpectedSuffix = ".expected.txt"
+)
+
+// defaultFixturesDir returns the fixtures directory used when none is given: "fixtures/<template>" in the prompts directory.
+func defaultFixturesDir(promptsDir string, templateName string) string {
+	return filepath.Join(promptsDir, "fixtures", strings.TrimSuffix(templateName, templateExt))
+}
+
+// runFixtureTests renders the template with the arguments of every fixture case in fixturesDir
+// and compares the output to the expected one, reporting the result of each case.
+// Outputs are compared with leading and trailing whitespace trimmed.
+func runFixtureTests(
+	w io.Writer, promptsDir string, partialsDirs []string, templateName string, fixturesDir string,
+	vars map[string]string, enableJSONArgs bool, dateName string,
+) error {
+	argsFiles, err := filepath.Glob(filepath.Join(fixturesDir, "*"+fixtureArgsSuffix))
+	if err != nil {
+		return fmt.Errorf("find fixtures: %w", err)
+	}
+	if len(argsFiles) == 0 {
+		return fmt.Errorf("no %s fixtures found in %s", fixtureArgsSuffix, fixturesDir)
+	}
+	sort.Strings(argsFiles)
+
+	failed := 0
+	for _, argsFile := range argsFiles {
+		caseName := strings.TrimSuffix(filepath.Base(argsFile), fixtureArgsSuffix)
+		if err = runFixtureCase(promptsDir, partialsDirs, templateName, argsFile, vars, enableJSONArgs, dateName); err != nil {
+			mustFprintf(w, "%s %s - %s\n", errorIcon(), templateText(caseName), errorText(localize("status.failed", err)))
+			failed++
+			continue
+		}
+		mustFprintf(w, "%s %s - %s\n", successIcon(), templateText(caseName), successText(localize("status.passed")))
+	}
+
+	if failed > 0 {
+		return errors.New(localize("test.cases_failed", failed, len(argsFiles)))
+	}
+	return nil
+}
+
+func runFixtureCase(
+	promptsDir string, partialsDirs []string, templateName string, argsFile string, vars map[string]string,
+	enableJSONArgs bool, dateName string,
+) error {
+	args, err := loadFixtureArgs(argsFile)
+	if err != nil {
+		return err
+	}
+	expectedFile := strings.TrimSuffix(argsFile, fixtureArgsSuffix) + fixtureExpectedSuffix
+	expected, err := os.ReadFile(expectedFile)
+	if err != nil {
+		return fmt.Errorf("read expected output: %w", err)
+	}
+
+	var rendered bytes.Buffer
+	if err = renderTemplateWithWarnings(
+		&rendered, io.Discard, promptsDir, partialsDirs, templateName, args, nil, vars, enableJSONArgs, dateName, nil,
+	); err != nil {
+		return fmt.Errorf("render: %w", err)
+	}
+	return compareFixtureOutput(string(bytes.TrimSpace(expected)), rendered.String())
+}
+
+// loadFixtureArgs reads the arguments of a fixture case. String values are passed as is,
+// other JSON values are passed as their JSON text, like JSON arguments of the render command.
+func loadFixtureArgs(argsFile string) (map[string]string, error) {
+	content, err := os.ReadFile(argsFile)
+	if err != nil {
+		return nil, fmt.Errorf("read arguments: %w", err)
+	}
+	var rawArgs map[string]json.RawMessage
+	if err = json.Unmarshal(content, &rawArgs); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filepath.Base(argsFile), err)
+	}
+	args := make(map[string]string, len(rawArgs))
+	for name, raw := range rawArgs {
+		var str string
+		if json.Unmarshal(raw, &str) == nil {
+			args[name] = str
+			continue
+		}
+		args[name] = string(raw)
+	}
+	return args, nil
+}
+
+// compareFixtureOutput reports the first line where the rendered output differs from the expected one.
+func compareFixtureOutput(expected string, actual string) error {
+	if expected == actual {
+		return nil
+	}
+	expectedLines := strings.Split(expected, "\n")
+	actualLines := strings.Split(actual, "\n")
+	for i := 0; ; i++ {
+		switch {
+		case i >= len(expectedLines):
+			return fmt.Errorf("line %d: unexpected extra output %q", i+1, actualLines[i])
+		case i >= len(actualLines):
+			return fmt.Errorf("line %d: missing expected output %q", i+1, expectedLines[i])
+		case expectedLines[i] != actualLines[i]:
+			return fmt.Errorf("line %d: expected %q, got %q", i+1, expectedLines[i], actualLines[i])
+		}
+	}
+}
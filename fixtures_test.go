@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type FixturesTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+func TestFixturesTestSuite(t *testing.T) {
+	suite.Run(t, new(FixturesTestSuite))
+}
+
+func (s *FixturesTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+	s.writeFile("greeting.tmpl", "{{/* Greeting */}}\nHello {{.name}}!\nTags: {{range .tags}}#{{.}} {{end}}")
+}
+
+func (s *FixturesTestSuite) writeFile(name, content string) {
+	path := filepath.Join(s.tempDir, name)
+	require.NoError(s.T(), os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(s.T(), os.WriteFile(path, []byte(content), 0644))
+}
+
+func (s *FixturesTestSuite) runTestCommand(args ...string) (string, error) {
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	err := app.Run(context.Background(), append([]string{app.Name, "test", s.tempDir}, args...))
+	return removeANSIColors(buf.String()), err
+}
+
+// TestPassingFixture tests that a matching fixture passes, with JSON values passed to the template
+func (s *FixturesTestSuite) TestPassingFixture() {
+	s.writeFile("fixtures/greeting/alice.args.json", `{"name": "Alice", "tags": ["a", "b"]}`)
+	s.writeFile("fixtures/greeting/alice.expected.txt", "Hello Alice!\nTags: #a #b\n")
+
+	output, err := s.runTestCommand("greeting")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "✓ alice - Passed\n", output)
+}
+
+// TestFailingFixture tests that a mismatching fixture is reported with the first differing line
+func (s *FixturesTestSuite) TestFailingFixture() {
+	s.writeFile("fixtures/greeting/alice.args.json", `{"name": "Alice", "tags": ["a"]}`)
+	s.writeFile("fixtures/greeting/alice.expected.txt", "Hello Alice!\nTags: #a")
+	s.writeFile("fixtures/greeting/bob.args.json", `{"name": "Bob", "tags": []}`)
+	s.writeFile("fixtures/greeting/bob.expected.txt", "Hello Robert!\nTags:")
+
+	output, err := s.runTestCommand("greeting")
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "1 of 2 fixture cases failed")
+	assert.Equal(s.T(),
+		"✓ alice - Passed\n"+
+			"✗ bob - Failed: line 1: expected \"Hello Robert!\", got \"Hello Bob!\"\n",
+		output)
+}
+
+// TestFixtureErrors tests cases that cannot be compared and missing fixtures
+func (s *FixturesTestSuite) TestFixtureErrors() {
+	s.writeFile("cases/no_expected.args.json", `{"name": "Alice"}`)
+	s.writeFile("cases/invalid_args.args.json", `["Alice"]`)
+	s.writeFile("cases/invalid_args.expected.txt", "Hello Alice!")
+
+	output, err := s.runTestCommand("greeting", "--fixtures", filepath.Join(s.tempDir, "cases"))
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "2 of 2 fixture cases failed")
+	assert.Contains(s.T(), output, "✗ invalid_args - Failed: parse invalid_args.args.json:")
+	assert.Contains(s.T(), output, "✗ no_expected - Failed: read expected output:")
+
+	_, err = s.runTestCommand("greeting", "--fixtures", filepath.Join(s.tempDir, "empty"))
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "no .args.json fixtures found")
+}
+
+// TestCompareFixtureOutput tests reporting of the first difference between expected and rendered output
+func (s *FixturesTestSuite) TestCompareFixtureOutput() {
+	tests := []struct {
+		name        string
+		expected    string
+		actual      string
+		expectedErr string
+	}{
+		{name: "equal", expected: "a\nb", actual: "a\nb"},
+		{name: "different line", expected: "a\nb", actual: "a\nc", expectedErr: `line 2: expected "b", got "c"`},
+		{name: "extra output", expected: "a", actual: "a\nb", expectedErr: `line 2: unexpected extra output "b"`},
+		{name: "missing output", expected: "a\nb", actual: "a", expectedErr: `line 2: missing expected output "b"`},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			err := compareFixtureOutput(tt.expected, tt.actual)
+			if tt.expectedErr == "" {
+				assert.NoError(s.T(), err)
+				return
+			}
+			assert.EqualError(s.T(), err, tt.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template/parse"
+)
+
+// formatIndent indents the lines of a block once per level of nesting.
+const formatIndent = "  "
+
+// templateToken is a piece of a template body: text, an action, or a comment.
+type templateToken struct {
+	text      string
+	action    bool   // an action or a comment, text otherwise
+	comment   bool   // a comment, e.g. {{/* Description */}}
+	leftTrim  bool   // starts with "{{- ", which trims the whitespace before it
+	rightTrim bool   // ends with " -}}", which trims the whitespace after it
+	keyword   string // first word of an action, e.g. "if" or "end"
+	delims    templateDelims
+}
+
+// formatTemplate rewrites the template file content in the canonical style: the leading comment has single spaces
+// inside its delimiters, and whitespace that trim markers remove from the output is normalized, so lines starting
+// with "{{-" or following a "-}}" are indented by the nesting of their blocks and have no trailing whitespace.
+// Whitespace that reaches the output is never changed, so the formatted template renders exactly as before;
+// the parse trees of both versions are compared to make sure of that.
+func formatTemplate(name string, content []byte) ([]byte, error) {
+	frontmatter, body, err := splitFrontmatter(content)
+	if err != nil {
+		return nil, err
+	}
+	header, padding := "", ""
+	if frontmatter != nil {
+		// The body has an empty line for each line of the frontmatter block, which is kept as it is;
+		// the body is parsed after as many empty lines, so that errors have the lines of the file
+		lines := bytes.Count(content[:bytes.IndexByte(content, '\n')+1+len(frontmatter)], []byte("\n")) + 1
+		body = body[lines:]
+		header, padding = string(content[:len(content)-len(body)]), strings.Repeat("\n", lines)
+	}
+
+	source := string(body)
+	crlf := strings.Contains(source, "\r\n")
+	if crlf {
+		source = strings.ReplaceAll(source, "\r\n", "\n")
+	}
+	before, err := parseTreeText(name, padding+source)
+	if err != nil {
+		return nil, err
+	}
+	// The leading comment of a delims directive is written with the default delimiters, the rest with its own
+	delims, directiveEnd, err := findDelimsDirective(source)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := scanTemplate(source[:directiveEnd], defaultDelims)
+	if err != nil {
+		return nil, err
+	}
+	bodyTokens, err := scanTemplate(source[directiveEnd:], delims)
+	if err != nil {
+		return nil, err
+	}
+	tokens = append(tokens, bodyTokens...)
+
+	formatted := formatTokens(tokens)
+	after, err := parseTreeText(name, padding+formatted)
+	if err != nil || after != before {
+		return nil, errors.New("formatting would change the output of the template")
+	}
+	if crlf {
+		formatted = strings.ReplaceAll(formatted, "\n", "\r\n")
+	}
+	return []byte(header + formatted), nil
+}
+
+// formatTokens joins the tokens of a template body in the canonical style described by formatTemplate.
+func formatTokens(tokens []templateToken) string {
+	var sb strings.Builder
+	depth := 0
+	leading := true // no token but whitespace was written yet
+	for i, token := range tokens {
+		if token.action {
+			text := token.text
+			if token.comment && leading {
+				text = normalizeLeadingComment(token)
+			}
+			if token.comment || !strings.Contains(text, "`") {
+				// Whitespace at the end of the lines of a comment or an action is not part of the output,
+				// unless it is in a raw string
+				text = trimTrailingSpacePerLine(text)
+			}
+			sb.WriteString(text)
+			leading = false
+			switch token.keyword {
+			case "if", "range", "with", "define", "block":
+				depth++
+			case "end":
+				depth = max(depth-1, 0)
+			}
+			continue
+		}
+
+		text := token.text
+		trimmedBefore := i > 0 && tokens[i-1].rightTrim
+		trimmedAfter := i+1 < len(tokens) && tokens[i+1].leftTrim
+		nextDepth := depth
+		if i+1 < len(tokens) {
+			nextDepth = tokenDepth(tokens[i+1], depth)
+		}
+		if strings.TrimSpace(text) == "" {
+			if trimmedBefore || trimmedAfter {
+				text = normalizeTrimmedSpace(text, nextDepth)
+			}
+			sb.WriteString(text)
+			continue
+		}
+		leading = false
+		body := strings.TrimLeft(text, " \t\r\n")
+		before := text[:len(text)-len(body)]
+		if trimmedBefore {
+			before = normalizeTrimmedSpace(before, depth)
+		}
+		trimmed := strings.TrimRight(body, " \t\r\n")
+		after := body[len(trimmed):]
+		if trimmedAfter {
+			after = normalizeTrimmedSpace(after, nextDepth)
+		}
+		sb.WriteString(before + trimmed + after)
+	}
+	return sb.String()
+}
+
+// tokenDepth returns the nesting depth of the line starting with the token, which is one less for
+// the actions closing or continuing a block, like {{end}} and {{else}}.
+func tokenDepth(token templateToken, depth int) int {
+	if token.keyword == "end" || token.keyword == "else" {
+		return max(depth-1, 0)
+	}
+	return depth
+}
+
+// normalizeTrimmedSpace rewrites whitespace that a trim marker removes from the output: its line breaks are kept
+// and the last line is indented to the depth. Whitespace within a line is kept as it is.
+func normalizeTrimmedSpace(space string, depth int) string {
+	lineBreaks := strings.Count(space, "\n")
+	if lineBreaks == 0 {
+		return space
+	}
+	return strings.Repeat("\n", lineBreaks) + strings.Repeat(formatIndent, depth)
+}
+
+// normalizeLeadingComment returns the comment with single spaces inside its delimiters if it is on one line,
+// e.g. {{/* Description */}} for {{/*Description   */}}, and with trailing whitespace trimmed otherwise.
+func normalizeLeadingComment(token templateToken) string {
+	opening, closing := token.delims.left+"/*", "*/"+token.delims.right
+	if token.leftTrim {
+		opening = token.delims.left + "- /*"
+	}
+	if token.rightTrim {
+		closing = "*/ -" + token.delims.right
+	}
+	if !strings.HasPrefix(token.text, opening) || !strings.HasSuffix(token.text, closing) {
+		return token.text
+	}
+	inner := token.text[len(opening) : len(token.text)-len(closing)]
+	if strings.Contains(inner, "\n") {
+		return token.text
+	}
+	if inner = strings.TrimSpace(inner); inner == "" {
+		return opening + " " + closing
+	}
+	return opening + " " + inner + " " + closing
+}
+
+// trimTrailingSpacePerLine removes the spaces and tabs at the end of every line but the last one.
+func trimTrailingSpacePerLine(text string) string {
+	lines := strings.Split(text, "\n")
+	for i := range lines[:len(lines)-1] {
+		lines[i] = strings.TrimRight(lines[i], " \t")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// scanTemplate splits a template body with the delimiters into text, actions, and comments, following the rules
+// of text/template for delimiters, trim markers, comments, and quoted strings in actions.
+func scanTemplate(source string, delims templateDelims) ([]templateToken, error) {
+	var tokens []templateToken
+	pos := 0
+	for pos < len(source) {
+		start := strings.Index(source[pos:], delims.left)
+		if start < 0 {
+			tokens = append(tokens, templateToken{text: source[pos:]})
+			break
+		}
+		if start > 0 {
+			tokens = append(tokens, templateToken{text: source[pos : pos+start]})
+		}
+		start += pos
+		token := templateToken{action: true, delims: delims}
+		inside := start + len(delims.left)
+		if len(source) > inside+1 && source[inside] == '-' && isTemplateSpace(source[inside+1]) {
+			token.leftTrim = true
+			inside += 2
+		}
+
+		var end int
+		if strings.HasPrefix(source[inside:], "/*") {
+			token.comment = true
+			closing := strings.Index(source[inside+2:], "*/")
+			if closing < 0 {
+				return nil, errors.New("unclosed comment")
+			}
+			end = inside + 2 + closing + len("*/")
+			if strings.HasPrefix(source[end:], " -"+delims.right) {
+				token.rightTrim = true
+				end += len(" -" + delims.right)
+			} else if strings.HasPrefix(source[end:], delims.right) {
+				end += len(delims.right)
+			} else {
+				return nil, errors.New("comment ends before closing delimiter")
+			}
+		} else {
+			contentEnd, err := scanActionEnd(source, inside, delims.right)
+			if err != nil {
+				return nil, err
+			}
+			end = contentEnd + len(delims.right)
+			if contentEnd-1 > inside && source[contentEnd-1] == '-' && isTemplateSpace(source[contentEnd-2]) {
+				token.rightTrim = true
+				contentEnd -= 2
+			}
+			if fields := strings.Fields(source[inside:contentEnd]); len(fields) > 0 {
+				token.keyword = fields[0]
+			}
+		}
+		token.text = source[start:end]
+		tokens = append(tokens, token)
+		pos = end
+	}
+	return tokens, nil
+}
+
+// scanActionEnd returns the position of the right delimiter closing the action whose content starts at pos,
+// skipping delimiters in quoted strings and character constants.
+func scanActionEnd(source string, pos int, right string) (int, error) {
+	for pos < len(source) {
+		if strings.HasPrefix(source[pos:], right) {
+			return pos, nil
+		}
+		switch c := source[pos]; c {
+		case '"', '\'', '`':
+			closing := pos + 1
+			for closing < len(source) && source[closing] != c {
+				if c != '`' && source[closing] == '\\' {
+					closing++
+				}
+				closing++
+			}
+			if closing >= len(source) {
+				return 0, errors.New("unterminated quoted string in action")
+			}
+			pos = closing + 1
+		default:
+			pos++
+		}
+	}
+	return 0, errors.New("unclosed action")
+}
+
+// isTemplateSpace reports whether the byte is whitespace that separates a trim marker from its delimiter.
+func isTemplateSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// parseTreeText parses a template body without checking its functions and returns the text of its parse trees,
+// which differs between two bodies whenever they render differently.
+func parseTreeText(name string, source string) (string, error) {
+	source, delims, err := withDelimsDirective(source)
+	if err != nil {
+		return "", err
+	}
+	tree := parse.New(name)
+	tree.Mode = parse.SkipFuncCheck
+	trees := make(map[string]*parse.Tree)
+	if _, err = tree.Parse(source, delims.left, delims.right, trees); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(trees)) {
+		sb.WriteString(name + "\x00" + trees[name].Root.String() + "\x00")
+	}
+	return sb.String(), nil
+}
+
+// formatTemplates formats the template files of the prompts directory, partials included, or only the named one.
+// The formatted templates are written to w, or back to their files if write is set, which leaves unchanged files alone.
+func formatTemplates(w io.Writer, promptsDir string, templateName string, write bool) error {
+	var files []string
+	if templateName != "" {
+		if !strings.HasSuffix(templateName, templateExt) {
+			templateName += templateExt
+		}
+		files = []string{templateName}
+	} else {
+		entries, err := os.ReadDir(promptsDir)
+		if err != nil {
+			return fmt.Errorf("read prompts directory: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), templateExt) {
+				files = append(files, entry.Name())
+			}
+		}
+	}
+
+	var errs []error
+	for _, file := range files {
+		filePath := filepath.Join(promptsDir, file)
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("read %s: %w", file, err))
+			continue
+		}
+		formatted, err := formatTemplate(file, content)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("format %s: %w", file, err))
+			continue
+		}
+		if !write {
+			if _, err = w.Write(formatted); err != nil {
+				return err
+			}
+			continue
+		}
+		if bytes.Equal(formatted, content) {
+			continue
+		}
+		info, err := os.Stat(filePath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stat %s: %w", file, err))
+			continue
+		}
+		if err = os.WriteFile(filePath, formatted, info.Mode().Perm()); err != nil {
+			errs = append(errs, fmt.Errorf("write %s: %w", file, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const unformattedTemplate = `---
+arguments:
+  items:
+    description: Things to list
+---
+{{/*Review   code  */}}
+Hello {{.name}}!
+{{- if .items}}
+        {{- range .items}}
+ - {{.}}   {{/* kept */}}
+      {{- end}}
+{{- else -}}
+          none
+   {{- end}}
+{{if .urgent -}}
+     Hurry!
+{{end}}
+{{- $note := ` + "`raw  \n  text`" + ` -}}
+{{$note}}`
+
+const formattedTemplate = `---
+arguments:
+  items:
+    description: Things to list
+---
+{{/* Review   code */}}
+Hello {{.name}}!
+{{- if .items}}
+  {{- range .items}}
+ - {{.}}   {{/* kept */}}
+  {{- end}}
+{{- else -}}
+  none
+{{- end}}
+{{if .urgent -}}
+  Hurry!
+{{end}}
+{{- $note := ` + "`raw  \n  text`" + ` -}}
+{{$note}}`
+
+type FormatTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestFormatTestSuite(t *testing.T) {
+	suite.Run(t, new(FormatTestSuite))
+}
+
+func (s *FormatTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+}
+
+func (s *FormatTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+func (s *FormatTestSuite) readFile(name string) string {
+	content, err := os.ReadFile(filepath.Join(s.promptsDir, name))
+	require.NoError(s.T(), err)
+	return string(content)
+}
+
+// TestFormat tests the canonical style: the leading comment, the indentation of the lines whose leading whitespace
+// is trimmed, and trailing whitespace that is trimmed from the output
+func (s *FormatTestSuite) TestFormat() {
+	formatted, err := formatTemplate("review.tmpl", []byte(unformattedTemplate))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), formattedTemplate, string(formatted))
+
+	crlf, err := formatTemplate("crlf.tmpl", bytes.ReplaceAll([]byte("{{/*Hi*/}}\n{{- if .a}}  \n    {{- .a}}\n{{- end}}"), []byte("\n"), []byte("\r\n")))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "{{/* Hi */}}\r\n{{- if .a}}\r\n  {{- .a}}\r\n{{- end}}", string(crlf), "Windows line endings are kept")
+}
+
+// TestIdempotent tests that formatting formatted templates changes nothing
+func (s *FormatTestSuite) TestIdempotent() {
+	sources := []string{unformattedTemplate, formattedTemplate}
+	for _, dir := range []string{"./testdata", "./prompts"} {
+		files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+		require.NoError(s.T(), err)
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			require.NoError(s.T(), err)
+			sources = append(sources, string(content))
+		}
+	}
+	for _, source := range sources {
+		once, err := formatTemplate("source.tmpl", []byte(source))
+		require.NoError(s.T(), err, source)
+		twice, err := formatTemplate("source.tmpl", once)
+		require.NoError(s.T(), err, source)
+		assert.Equal(s.T(), string(once), string(twice), source)
+	}
+}
+
+// TestPreservesRender tests that the templates render the same before and after format --write,
+// which only rewrites the files that are not formatted
+func (s *FormatTestSuite) TestPreservesRender() {
+	s.writeFile("review.tmpl", unformattedTemplate)
+	s.writeFile("_footer.tmpl", "{{- if .name -}}   \n    Bye {{.name}}\n{{- end}}")
+	s.writeFile("greeting.tmpl", "{{/* Greeting */}}\nHi {{.name}}{{template \"_footer.tmpl\" .}}")
+
+	render := func(templateName string, args map[string]string) string {
+		var buf bytes.Buffer
+		require.NoError(s.T(), renderTemplate(&buf, s.promptsDir, nil, templateName, args, true))
+		return buf.String()
+	}
+	cases := []struct {
+		templateName string
+		args         map[string]string
+	}{
+		{templateName: "review", args: map[string]string{"name": "Ann", "items": `["a","b"]`, "urgent": "true"}},
+		{templateName: "review", args: map[string]string{"name": "Ann", "items": "[]"}},
+		{templateName: "greeting", args: map[string]string{"name": "Bob"}},
+	}
+	var before []string
+	for _, tc := range cases {
+		before = append(before, render(tc.templateName, tc.args))
+	}
+	greetingInfo, err := os.Stat(filepath.Join(s.promptsDir, "greeting.tmpl"))
+	require.NoError(s.T(), err)
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), formatTemplates(&buf, s.promptsDir, "review", false))
+	assert.Equal(s.T(), formattedTemplate, buf.String(), "without --write the template is printed")
+	assert.Equal(s.T(), unformattedTemplate, s.readFile("review.tmpl"))
+
+	buf.Reset()
+	require.NoError(s.T(), formatTemplates(&buf, s.promptsDir, "", true))
+	assert.Empty(s.T(), buf.String())
+	assert.Equal(s.T(), formattedTemplate, s.readFile("review.tmpl"))
+	assert.Equal(s.T(), "{{- if .name -}}\n  Bye {{.name}}\n{{- end}}", s.readFile("_footer.tmpl"), "partials are formatted too")
+	info, err := os.Stat(filepath.Join(s.promptsDir, "greeting.tmpl"))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), greetingInfo.ModTime(), info.ModTime(), "formatted files are not rewritten")
+
+	for i, tc := range cases {
+		assert.Equal(s.T(), before[i], render(tc.templateName, tc.args), tc.templateName)
+	}
+}
+
+// TestInvalidTemplates tests that templates which do not parse are reported and left as they are
+func (s *FormatTestSuite) TestInvalidTemplates() {
+	s.writeFile("broken.tmpl", "{{if .a}}   \n   {{- .a}}")
+	s.writeFile("valid.tmpl", "{{- if .a}}   \n   {{- .a}}{{end}}")
+
+	err := formatTemplates(&bytes.Buffer{}, s.promptsDir, "", true)
+	require.ErrorContains(s.T(), err, "format broken.tmpl: template: broken.tmpl:2: unexpected EOF")
+	assert.Equal(s.T(), "{{if .a}}   \n   {{- .a}}", s.readFile("broken.tmpl"))
+	assert.Equal(s.T(), "{{- if .a}}\n  {{- .a}}{{end}}", s.readFile("valid.tmpl"), "the other templates are still formatted")
+
+	_, err = formatTemplate("broken.tmpl", []byte("---\ndata:\n  a: 1\n---\n{{if .a}}"))
+	assert.ErrorContains(s.T(), err, "template: broken.tmpl:5: unexpected EOF", "errors have the lines of the file")
+}
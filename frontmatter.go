@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const frontmatterDelimiter = "---"
+
+// Types of prompt arguments that can be declared in the frontmatter.
+const (
+	argTypeString = "string" // the default, values are not checked
+	argTypeNumber = "number"
+)
+
+// PromptFrontmatter is the optional YAML block at the very top of a template file, delimited by "---" lines:
+//
+//	---
+//	data:
+//	  company: Acme
+//	---
+//	{{/* Description */}}
+type PromptFrontmatter struct {
+	// Data holds constants merged into the template data under their keys.
+	// They are always available to the template and are never exposed as prompt arguments.
+	Data map[string]interface{} `yaml:"data"`
+	// Arguments declares how values of prompt arguments are handled, keyed by argument name.
+	Arguments map[string]ArgumentSpec `yaml:"arguments"`
+	// Groups sorts the arguments into named sections of listings, e.g. "groups: {auth: [user, password]}".
+	Groups ArgumentGroups `yaml:"groups"`
+	// Deprecated is the deprecation notice of the prompt, e.g. "use code_review instead".
+	Deprecated string `yaml:"deprecated"`
+	// MinProtocol is the oldest MCP protocol version the prompt works with, e.g. "2025-03-26".
+	MinProtocol string `yaml:"min_protocol"`
+	// Builtins opts the prompt out of built-in fields, e.g. "builtins: {date: false}".
+	Builtins BuiltinsSpec `yaml:"builtins"`
+}
+
+// BuiltinsSpec selects the built-in fields set for a prompt. Unset fields keep their default.
+type BuiltinsSpec struct {
+	// Date sets the built-in date (see --builtin-date-name); true if unset.
+	Date *bool `yaml:"date"`
+}
+
+// ArgumentSpec declares the handling of a single prompt argument.
+type ArgumentSpec struct {
+	// Transform lists the transforms (e.g., trim, upper) applied in order to the raw value before JSON parsing.
+	Transform []string `yaml:"transform"`
+	// Pattern is a regular expression that values must match after the transforms, e.g. "^[^@]+@[^@]+$".
+	Pattern string `yaml:"pattern"`
+	// Description documents the argument for clients; it overrides an inline @doc comment of the template.
+	Description string `yaml:"description"`
+	// Example is a value shown to clients with the description to hint at the expected input, e.g. "Paris".
+	// It must pass the declared transforms, pattern, type, and bounds.
+	Example string `yaml:"example"`
+	// Type is the type of the values, "string" (default) or "number".
+	Type string `yaml:"type"`
+	// Min and Max bound the values of number arguments, inclusive; nil means unbounded.
+	Min *float64 `yaml:"min"`
+	Max *float64 `yaml:"max"`
+	// Sensitive marks values that must never be logged or stored, e.g. an API token. They are still rendered.
+	Sensitive bool `yaml:"sensitive"`
+	// Fingerprint includes the value of a sensitive argument in access log fingerprints, which leave it out otherwise.
+	Fingerprint bool `yaml:"fingerprint"`
+	// Env marks an argument that deployments provide through its environment variable (the name in upper case),
+	// so servers started with WithRequireEnv refuse to start while the variable is unset.
+	Env bool `yaml:"env"`
+
+	pattern *regexp.Regexp // compiled Pattern, nil if none is declared
+}
+
+// splitFrontmatter separates the frontmatter block from the template body.
+// In the returned body, the frontmatter lines are replaced by empty lines, so positions in template errors
+// still match the file. It returns a nil frontmatter if the content does not start with one.
+func splitFrontmatter(content []byte) (frontmatter []byte, body []byte, err error) {
+	firstLine, _, found := bytes.Cut(content, []byte("\n"))
+	if !found || string(bytes.TrimRight(firstLine, " \t\r")) != frontmatterDelimiter {
+		return nil, content, nil
+	}
+	start := len(firstLine) + 1
+	lines := 1
+	for pos := start; pos < len(content); {
+		lineEnd, next := len(content), len(content)
+		if i := bytes.IndexByte(content[pos:], '\n'); i >= 0 {
+			lineEnd, next = pos+i, pos+i+1
+		}
+		lines++
+		if string(bytes.TrimRight(content[pos:lineEnd], " \t\r")) == frontmatterDelimiter {
+			return content[start:pos], append(bytes.Repeat([]byte("\n"), lines), content[next:]...), nil
+		}
+		pos = next
+	}
+	return nil, nil, fmt.Errorf("frontmatter is not closed with %q", frontmatterDelimiter)
+}
+
+// parseFrontmatter parses the frontmatter of the template file content, with the built-in date in the dateName field
+// (defaultBuiltinDateName if empty). It returns nil without an error if the content has no frontmatter.
+func parseFrontmatter(content []byte, dateName string) (*PromptFrontmatter, error) {
+	block, _, err := splitFrontmatter(content)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	if dateName == "" {
+		dateName = defaultBuiltinDateName
+	}
+	var fm PromptFrontmatter
+	if err = yaml.Unmarshal(block, &fm); err != nil {
+		return nil, fmt.Errorf("parse frontmatter: %w", err)
+	}
+	if fm.MinProtocol != "" {
+		if _, err = parseProtocolVersion(fm.MinProtocol); err != nil {
+			return nil, fmt.Errorf("min_protocol: %w", err)
+		}
+	}
+	for key := range fm.Data {
+		if !funcAliasNameRegex.MatchString(key) {
+			return nil, fmt.Errorf("invalid data key %q", key)
+		}
+		if slices.Contains(builtinFieldNames(dateName), key) {
+			return nil, fmt.Errorf("data key %q is reserved for a built-in field", key)
+		}
+	}
+	for name, spec := range fm.Arguments {
+		if !funcAliasNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid argument name %q", name)
+		}
+		if _, isConstant := fm.Data[name]; isConstant {
+			return nil, fmt.Errorf("argument %q is declared as a data constant", name)
+		}
+		for _, transform := range spec.Transform {
+			if _, ok := argTransforms[transform]; !ok {
+				return nil, fmt.Errorf("unknown transform %q for argument %q (available: %s)",
+					transform, name, strings.Join(argTransformNames(), ", "))
+			}
+		}
+		if spec.Fingerprint && !spec.Sensitive {
+			return nil, fmt.Errorf("argument %q sets fingerprint but is not sensitive", name)
+		}
+		if err = spec.validateType(name); err != nil {
+			return nil, err
+		}
+		if spec.Pattern != "" {
+			if spec.pattern, err = regexp.Compile(spec.Pattern); err != nil {
+				return nil, fmt.Errorf("invalid pattern for argument %q: %w", name, err)
+			}
+			fm.Arguments[name] = spec
+		}
+	}
+	if err = fm.Groups.validate(); err != nil {
+		return nil, fmt.Errorf("groups: %w", err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(fm.Arguments)) {
+		example := fm.Arguments[name].Example
+		if example == "" {
+			continue
+		}
+		if err = fm.ValidateArgs(map[string]string{name: fm.TransformArg(name, example)}); err != nil {
+			return nil, fmt.Errorf("example of argument %q: %w", name, err)
+		}
+	}
+	return &fm, nil
+}
+
+// loadPromptFrontmatter reads the frontmatter of the template file, see parseFrontmatter.
+func loadPromptFrontmatter(filePath string, dateName string) (*PromptFrontmatter, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+	return parseFrontmatter(content, dateName)
+}
+
+// ExcludeConstants removes the names of data constants from the template arguments.
+func (fm *PromptFrontmatter) ExcludeConstants(args []string) []string {
+	if fm == nil || len(fm.Data) == 0 {
+		return args
+	}
+	return slices.DeleteFunc(slices.Clone(args), func(arg string) bool {
+		_, isConstant := fm.Data[arg]
+		return isConstant
+	})
+}
+
+// MergeConstants stores the data constants in the template data, overriding arguments of the same name.
+func (fm *PromptFrontmatter) MergeConstants(data map[string]interface{}) {
+	if fm == nil {
+		return
+	}
+	for key, value := range fm.Data {
+		data[key] = value
+	}
+}
+
+// IncludesDate reports whether the built-in date is set for the prompt.
+func (fm *PromptFrontmatter) IncludesDate() bool {
+	return fm == nil || fm.Builtins.Date == nil || *fm.Builtins.Date
+}
+
+// DeprecationNotice returns the deprecation notice of the prompt, or an empty string if it is not deprecated.
+func (fm *PromptFrontmatter) DeprecationNotice() string {
+	if fm == nil {
+		return ""
+	}
+	return strings.TrimSpace(fm.Deprecated)
+}
+
+// validateType checks the declared type of the argument and that bounds are only set for numbers.
+func (spec ArgumentSpec) validateType(name string) error {
+	switch spec.Type {
+	case "", argTypeString:
+		if spec.Min != nil || spec.Max != nil {
+			return fmt.Errorf("argument %q sets min or max but is not of type %s", name, argTypeNumber)
+		}
+	case argTypeNumber:
+		if spec.Min != nil && spec.Max != nil && *spec.Min > *spec.Max {
+			return fmt.Errorf("argument %q has min %g greater than max %g", name, *spec.Min, *spec.Max)
+		}
+	default:
+		return fmt.Errorf("unknown type %q for argument %q (available: %s, %s)", spec.Type, name, argTypeNumber, argTypeString)
+	}
+	return nil
+}
+
+// MinProtocolVersion returns the oldest MCP protocol version the prompt works with, or an empty string if any works.
+func (fm *PromptFrontmatter) MinProtocolVersion() string {
+	if fm == nil {
+		return ""
+	}
+	return fm.MinProtocol
+}
+
+// EnvArgs returns the names of the arguments declared with env, sorted.
+func (fm *PromptFrontmatter) EnvArgs() []string {
+	if fm == nil {
+		return nil
+	}
+	var names []string
+	for name, spec := range fm.Arguments {
+		if spec.Env {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ValidateArgs checks the argument values against the patterns, types, and bounds declared for them.
+// Values should be validated after the transforms are applied; arguments without declarations are not checked.
+func (fm *PromptFrontmatter) ValidateArgs(args map[string]string) error {
+	if fm == nil {
+		return nil
+	}
+	for _, name := range slices.Sorted(maps.Keys(args)) {
+		spec := fm.Arguments[name]
+		if spec.pattern != nil && !spec.pattern.MatchString(args[name]) {
+			return fmt.Errorf("argument %q does not match the pattern %q", name, spec.pattern.String())
+		}
+		if spec.Type != argTypeNumber {
+			continue
+		}
+		number, ok := parseNumberArg(args[name])
+		if !ok {
+			return fmt.Errorf("argument %q must be a number, got %q", name, args[name])
+		}
+		if spec.Min != nil && number < *spec.Min {
+			return fmt.Errorf("argument %q must be at least %g, got %g", name, *spec.Min, number)
+		}
+		if spec.Max != nil && number > *spec.Max {
+			return fmt.Errorf("argument %q must be at most %g, got %g", name, *spec.Max, number)
+		}
+	}
+	return nil
+}
+
+// parseNumberArg parses the value of a number argument, given either as a JSON number (e.g. 42)
+// or as a string holding one (e.g. "42"), as clients without typed arguments send it.
+func parseNumberArg(value string) (float64, bool) {
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(value), &parsed); err == nil {
+		if s, isString := parsed.(string); isString {
+			value = s
+		}
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, false
+	}
+	return number, true
+}
+
+// TransformArg applies the transforms declared for the argument to its value.
+func (fm *PromptFrontmatter) TransformArg(name string, value string) string {
+	if fm == nil {
+		return value
+	}
+	return applyArgTransforms(value, fm.Arguments[name].Transform)
+}
+
+// TransformArgs returns the argument values with the declared transforms applied.
+// The given map is not modified.
+func (fm *PromptFrontmatter) TransformArgs(args map[string]string) map[string]string {
+	if fm == nil || len(fm.Arguments) == 0 || len(args) == 0 {
+		return args
+	}
+	transformed := maps.Clone(args)
+	for name, value := range transformed {
+		transformed[name] = fm.TransformArg(name, value)
+	}
+	return transformed
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type FrontmatterTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+func TestFrontmatterTestSuite(t *testing.T) {
+	suite.Run(t, new(FrontmatterTestSuite))
+}
+
+func (s *FrontmatterTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+}
+
+func (s *FrontmatterTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, name), []byte(content), 0644))
+}
+
+// TestSplitFrontmatter tests separating the frontmatter block from the template body
+func (s *FrontmatterTestSuite) TestSplitFrontmatter() {
+	tests := []struct {
+		name                string
+		content             string
+		expectedFrontmatter string
+		expectedBody        string
+		expectedErr         string
+	}{
+		{
+			name:         "no frontmatter",
+			content:      "{{/* Greeting */}}\nHello",
+			expectedBody: "{{/* Greeting */}}\nHello",
+		},
+		{
+			name:         "delimiter not on first line",
+			content:      "Hello\n---\ndata: {}\n---\n",
+			expectedBody: "Hello\n---\ndata: {}\n---\n",
+		},
+		{
+			name:                "frontmatter replaced by empty lines",
+			content:             "---\ndata:\n  company: Acme\n---\n{{/* Greeting */}}\nHello",
+			expectedFrontmatter: "data:\n  company: Acme\n",
+			expectedBody:        "\n\n\n\n{{/* Greeting */}}\nHello",
+		},
+		{
+			name:                "CRLF line endings",
+			content:             "---\r\ndata: {}\r\n---\r\nHello",
+			expectedFrontmatter: "data: {}\r\n",
+			expectedBody:        "\n\n\nHello",
+		},
+		{
+			name:        "unclosed frontmatter",
+			content:     "---\ndata: {}\nHello",
+			expectedErr: `frontmatter is not closed with "---"`,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			frontmatter, body, err := splitFrontmatter([]byte(tt.content))
+			if tt.expectedErr != "" {
+				require.Error(s.T(), err)
+				assert.Contains(s.T(), err.Error(), tt.expectedErr)
+				return
+			}
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), tt.expectedFrontmatter, string(frontmatter))
+			assert.Equal(s.T(), tt.expectedBody, string(body))
+		})
+	}
+}
+
+// TestParseFrontmatterErrorCases tests invalid data constants
+func (s *FrontmatterTestSuite) TestParseFrontmatterErrorCases() {
+	tests := []struct {
+		name        string
+		content     string
+		expectedErr string
+	}{
+		{
+			name:        "invalid YAML",
+			content:     "---\ndata: [\n---\nHello",
+			expectedErr: "parse frontmatter",
+		},
+		{
+			name:        "invalid key",
+			content:     "---\ndata:\n  company-name: Acme\n---\nHello",
+			expectedErr: `invalid data key "company-name"`,
+		},
+		{
+			name:        "reserved key",
+			content:     "---\ndata:\n  date: today\n---\nHello",
+			expectedErr: `data key "date" is reserved`,
+		},
+		{
+			name:        "reserved key of the deployment variables",
+			content:     "---\ndata:\n  vars: x\n---\nHello",
+			expectedErr: `data key "vars" is reserved`,
+		},
+		{
+			name:        "reserved key of the session history",
+			content:     "---\ndata:\n  session_history: x\n---\nHello",
+			expectedErr: `data key "session_history" is reserved`,
+		},
+		{
+			name:        "reserved key of the model",
+			content:     "---\ndata:\n  _model: x\n---\nHello",
+			expectedErr: `data key "_model" is reserved`,
+		},
+		{
+			name:        "invalid argument name",
+			content:     "---\narguments:\n  ticket-id:\n    transform: [trim]\n---\nHello",
+			expectedErr: `invalid argument name "ticket-id"`,
+		},
+		{
+			name:        "unknown transform",
+			content:     "---\narguments:\n  name:\n    transform: [trim, reverse]\n---\nHello",
+			expectedErr: `unknown transform "reverse" for argument "name" (available: collapse_spaces, lower, trim, upper)`,
+		},
+		{
+			name:        "transform of a constant",
+			content:     "---\ndata:\n  name: Acme\narguments:\n  name:\n    transform: [upper]\n---\nHello",
+			expectedErr: `argument "name" is declared as a data constant`,
+		},
+		{
+			name:        "invalid pattern",
+			content:     "---\narguments:\n  email:\n    pattern: \"^[^@+$\"\n---\nHello",
+			expectedErr: `invalid pattern for argument "email"`,
+		},
+		{
+			name:        "unknown type",
+			content:     "---\narguments:\n  count:\n    type: integer\n---\nHello",
+			expectedErr: `unknown type "integer" for argument "count" (available: number, string)`,
+		},
+		{
+			name:        "bounds of a string",
+			content:     "---\narguments:\n  count:\n    min: 1\n---\nHello",
+			expectedErr: `argument "count" sets min or max but is not of type number`,
+		},
+		{
+			name:        "min greater than max",
+			content:     "---\narguments:\n  count:\n    type: number\n    min: 10\n    max: 1\n---\nHello",
+			expectedErr: `argument "count" has min 10 greater than max 1`,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			_, err := parseFrontmatter([]byte(tt.content), defaultBuiltinDateName)
+			require.Error(s.T(), err)
+			assert.Contains(s.T(), err.Error(), tt.expectedErr)
+		})
+	}
+	_, err := parseFrontmatter([]byte("---\ndata:\n  today: x\n---\nHello"), "today")
+	assert.ErrorContains(s.T(), err, `data key "today" is reserved`, "the renamed built-in date")
+	fm, err := parseFrontmatter([]byte("---\ndata:\n  date: x\n---\nHello"), "today")
+	require.NoError(s.T(), err, "date is free once the built-in date is renamed")
+	assert.Equal(s.T(), "x", fm.Data["date"])
+}
+
+// TestDataConstants tests that data constants render, are excluded from arguments, and cannot be overridden
+func (s *FrontmatterTestSuite) TestDataConstants() {
+	s.writeFile("policy.tmpl", "---\ndata:\n  company: Acme\n  max: 5\n---\n"+
+		"{{/* Policy reminder */}}\n{{.company}} allows at most {{.max}} items for {{.name}}.")
+
+	parser := &PromptsParser{}
+	description, err := parser.ExtractPromptDescriptionFromFile(filepath.Join(s.tempDir, "policy.tmpl"))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Policy reminder", description)
+
+	var buf bytes.Buffer
+	err = renderTemplate(&buf, s.tempDir, nil, "policy", map[string]string{"name": "Bob", "company": "Evil"}, true)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Acme allows at most 5 items for Bob.", buf.String())
+
+	buf.Reset()
+	require.NoError(s.T(), listTemplates(&buf, s.tempDir, listOptions{verbose: true}))
+	assert.Contains(s.T(), buf.String(), "Variables: name\n")
+}
+
+// TestArgumentTransforms tests that the render command applies transforms to arguments and environment fallbacks
+func (s *FrontmatterTestSuite) TestArgumentTransforms() {
+	s.writeFile("greet.tmpl", "---\narguments:\n  name:\n    transform: [trim, lower]\n"+
+		"  team:\n    transform: [upper]\n---\n{{/* Greet */}}\nHello, {{.name}} from {{.team}}!")
+	s.T().Setenv("TEAM", "platform")
+
+	var buf bytes.Buffer
+	err := renderTemplate(&buf, s.tempDir, nil, "greet", map[string]string{"name": " BOB "}, true)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Hello, bob from PLATFORM!", buf.String())
+}
+
+// TestArgumentPatterns tests validating argument values against declared patterns, after the transforms
+func (s *FrontmatterTestSuite) TestArgumentPatterns() {
+	fm, err := parseFrontmatter([]byte("---\narguments:\n  email:\n    transform: [trim]\n    pattern: \"^[^@]+@[^@]+$\"\n"+
+		"  ticket:\n    pattern: \"[A-Z]+-[0-9]+\"\n---\n{{/* Notify */}}"), defaultBuiltinDateName)
+	require.NoError(s.T(), err)
+
+	require.NoError(s.T(), fm.ValidateArgs(map[string]string{"email": "bob@example.com", "ticket": "see CORE-12", "other": ""}))
+	require.NoError(s.T(), fm.ValidateArgs(map[string]string{}), "missing arguments are not validated")
+
+	err = fm.ValidateArgs(map[string]string{"email": "bob@@example.com", "ticket": "CORE-12"})
+	require.Error(s.T(), err)
+	assert.Equal(s.T(), `argument "email" does not match the pattern "^[^@]+@[^@]+$"`, err.Error())
+	err = fm.ValidateArgs(map[string]string{"ticket": "core-12"})
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), `argument "ticket"`)
+
+	var noFrontmatter *PromptFrontmatter
+	require.NoError(s.T(), noFrontmatter.ValidateArgs(map[string]string{"email": "x"}))
+
+	s.writeFile("notify.tmpl", "---\narguments:\n  email:\n    transform: [trim]\n    pattern: \"^[^@ ]+@[^@ ]+$\"\n---\n"+
+		"{{/* Notify */}}\nNotify {{.email}}")
+	var buf bytes.Buffer
+	require.NoError(s.T(), renderTemplate(&buf, s.tempDir, nil, "notify", map[string]string{"email": " bob@example.com "}, true))
+	assert.Equal(s.T(), "Notify bob@example.com", buf.String())
+	err = renderTemplate(&bytes.Buffer{}, s.tempDir, nil, "notify", map[string]string{"email": "bob"}, true)
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), `argument "email" does not match the pattern`)
+}
+
+// TestNumberArguments tests validating number arguments against their bounds, given as JSON numbers or strings
+func (s *FrontmatterTestSuite) TestNumberArguments() {
+	fm, err := parseFrontmatter([]byte("---\narguments:\n  count:\n    type: number\n    min: 1\n    max: 10\n"+
+		"  ratio:\n    type: number\n    max: 0.5\n---\n{{/* Sample */}}"), defaultBuiltinDateName)
+	require.NoError(s.T(), err)
+
+	tests := []struct {
+		name        string
+		args        map[string]string
+		expectedErr string
+	}{
+		{name: "in range", args: map[string]string{"count": "1", "ratio": "-3"}},
+		{name: "upper bound is inclusive", args: map[string]string{"count": "10", "ratio": "0.5"}},
+		{name: "string holding a number", args: map[string]string{"count": `"7"`, "ratio": " 0.25 "}},
+		{name: "below min", args: map[string]string{"count": "0"}, expectedErr: `argument "count" must be at least 1, got 0`},
+		{name: "above max", args: map[string]string{"count": "11"}, expectedErr: `argument "count" must be at most 10, got 11`},
+		{name: "string above max", args: map[string]string{"ratio": `"0.75"`}, expectedErr: `argument "ratio" must be at most 0.5, got 0.75`},
+		{name: "not a number", args: map[string]string{"count": "many"}, expectedErr: `argument "count" must be a number, got "many"`},
+		{name: "not finite", args: map[string]string{"count": "NaN"}, expectedErr: `argument "count" must be a number, got "NaN"`},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			err := fm.ValidateArgs(tt.args)
+			if tt.expectedErr != "" {
+				assert.EqualError(s.T(), err, tt.expectedErr)
+				return
+			}
+			assert.NoError(s.T(), err)
+		})
+	}
+
+	s.writeFile("sample.tmpl", "---\narguments:\n  count:\n    type: number\n    min: 1\n    max: 10\n---\n"+
+		"{{/* Sample */}}\nPick {{.count}} items")
+	var buf bytes.Buffer
+	require.NoError(s.T(), renderTemplate(&buf, s.tempDir, nil, "sample", map[string]string{"count": "3"}, true))
+	assert.Equal(s.T(), "Pick 3 items", buf.String())
+	err = renderTemplate(&bytes.Buffer{}, s.tempDir, nil, "sample", map[string]string{"count": "12"}, true)
+	assert.ErrorContains(s.T(), err, `argument "count" must be at most 10, got 12`)
+
+	promptsServer, err := NewPromptsServer(s.tempDir, WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+	for value, expectedErr := range map[string]string{"5": "", "0": `argument "count" must be at least 1, got 0`} {
+		request, err := json.Marshal(map[string]any{
+			"jsonrpc": "2.0", "id": 1, "method": "prompts/get",
+			"params": map[string]any{"name": "sample", "arguments": map[string]string{"count": value}},
+		})
+		require.NoError(s.T(), err)
+		response := promptsServer.mcpServer.HandleMessage(context.Background(), request)
+		if expectedErr == "" {
+			assert.IsType(s.T(), mcp.JSONRPCResponse{}, response)
+			continue
+		}
+		require.IsType(s.T(), mcp.JSONRPCError{}, response)
+		assert.Contains(s.T(), response.(mcp.JSONRPCError).Error.Message, expectedErr)
+	}
+}
+
+// TestDeprecationNotice tests reading the deprecation notice of a prompt
+func (s *FrontmatterTestSuite) TestDeprecationNotice() {
+	fm, err := parseFrontmatter([]byte("---\ndeprecated: \" use code_review instead \"\n---\n{{/* Review */}}"), defaultBuiltinDateName)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "use code_review instead", fm.DeprecationNotice())
+
+	fm, err = parseFrontmatter([]byte("---\ndata:\n  team: core\n---\n{{/* Review */}}"), defaultBuiltinDateName)
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), fm.DeprecationNotice())
+
+	var noFrontmatter *PromptFrontmatter
+	assert.Empty(s.T(), noFrontmatter.DeprecationNotice())
+}
+
+// TestTemplateErrorLinesWithFrontmatter tests that template error positions still match the file
+func (s *FrontmatterTestSuite) TestTemplateErrorLinesWithFrontmatter() {
+	s.writeFile("broken.tmpl", "---\ndata:\n  company: Acme\n---\n{{/* Broken */}}\n{{.unclosed")
+
+	_, err := (&PromptsParser{}).ParseDir(s.tempDir)
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "broken.tmpl:6:")
+}
+
+// TestBuiltinDateOptOut tests that prompts opting out of the built-in date render without it, and others keep it
+func (s *FrontmatterTestSuite) TestBuiltinDateOptOut() {
+	s.writeFile("dated.tmpl", "{{/* Dated */}}\n{{if .date}}Dated{{else}}Undated{{end}} {{.name}}")
+	s.writeFile("undated.tmpl", "---\nbuiltins:\n  date: false\n---\n"+
+		"{{/* Undated */}}\n{{if .date}}Dated{{else}}Undated{{end}} {{.name}}")
+
+	promptsServer, err := NewPromptsServer(s.tempDir, WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+	for name, expected := range map[string]string{"dated": "Dated Ada", "undated": "Undated Ada"} {
+		request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "prompts/get",
+			"params": map[string]any{"name": name, "arguments": map[string]any{"name": "Ada"}}})
+		require.NoError(s.T(), err)
+		response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+		require.True(s.T(), ok, name)
+		assert.Equal(s.T(), expected,
+			response.Result.(mcp.GetPromptResult).Messages[0].Content.(mcp.TextContent).Text, name)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), renderTemplate(&buf, s.tempDir, nil, "undated", map[string]string{"name": "Ada"}, true))
+	assert.Equal(s.T(), "Undated Ada", buf.String())
+
+	fm, err := parseFrontmatter([]byte("---\nbuiltins:\n  date: true\n---\n{{/* Dated */}}"), defaultBuiltinDateName)
+	require.NoError(s.T(), err)
+	assert.True(s.T(), fm.IncludesDate())
+	var noFrontmatter *PromptFrontmatter
+	assert.True(s.T(), noFrontmatter.IncludesDate())
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"text/template"
+)
+
+// templateFuncPanic is a panic raised by a template function, captured with the stack of the panicking goroutine.
+// text/template turns panics of functions into errors and drops their stacks,
+// so functions are wrapped to keep the stack when panic recovery is disabled for debugging.
+type templateFuncPanic struct {
+	funcName string
+	value    interface{}
+	stack    []byte
+}
+
+func (p *templateFuncPanic) Error() string {
+	return fmt.Sprintf("panic in template function %q: %v\n\n%s", p.funcName, p.value, p.stack)
+}
+
+// capturePanicsInFuncs returns a copy of the functions in which every function re-panics with a *templateFuncPanic.
+// The panic still becomes an execution error, which wraps the *templateFuncPanic.
+func capturePanicsInFuncs(funcs template.FuncMap) template.FuncMap {
+	wrapped := make(template.FuncMap, len(funcs))
+	for name, fn := range funcs {
+		wrapped[name] = capturePanics(name, fn)
+	}
+	return wrapped
+}
+
+func capturePanics(funcName string, fn interface{}) interface{} {
+	fnValue := reflect.ValueOf(fn)
+	return reflect.MakeFunc(fnValue.Type(), func(args []reflect.Value) []reflect.Value {
+		defer func() {
+			if r := recover(); r != nil {
+				panic(&templateFuncPanic{funcName: funcName, value: r, stack: debug.Stack()})
+			}
+		}()
+		if fnValue.Type().IsVariadic() {
+			return fnValue.CallSlice(args)
+		}
+		return fnValue.Call(args)
+	}).Interface()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCapturePanicsInFuncs tests that wrapped functions behave like the originals and keep the stack of panics
+func TestCapturePanicsInFuncs(t *testing.T) {
+	funcs := capturePanicsInFuncs(template.FuncMap{
+		"join": func(sep string, parts ...string) string { return strings.Join(parts, sep) },
+		"boom": func() (string, error) { panic("exploded") },
+	})
+	tmpl := template.Must(template.New("t").Funcs(funcs).Parse(`{{join "-" "a" "b" "c"}}{{if .boom}}{{boom}}{{end}}`))
+
+	var buf bytes.Buffer
+	require.NoError(t, tmpl.Execute(&buf, map[string]interface{}{"boom": false}))
+	assert.Equal(t, "a-b-c", buf.String())
+
+	err := tmpl.Execute(&buf, map[string]interface{}{"boom": true})
+	require.Error(t, err)
+	var funcPanic *templateFuncPanic
+	require.True(t, errors.As(err, &funcPanic))
+	assert.Equal(t, "boom", funcPanic.funcName)
+	assert.Equal(t, "exploded", funcPanic.value)
+	assert.Contains(t, string(funcPanic.stack), "TestCapturePanicsInFuncs")
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+const funcsFileName = "funcs.yaml"
+
+// stringTransforms are the built-in safe string transformations available to templates.
+// They are also the only building blocks that function aliases may compose.
+var stringTransforms = map[string]func(string) string{
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"trim":    strings.TrimSpace,
+	"quote":   strconv.Quote,
+	"oneline": func(s string) string { return strings.Join(strings.Fields(s), " ") },
+	"slugify": slugify,
+}
+
+var funcAliasNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// templateBuiltinFuncs are the functions predefined by text/template that aliases must not shadow.
+var templateBuiltinFuncs = []string{
+	"and", "call", "html", "index", "slice", "js", "len", "not", "or", "print", "printf", "println", "urlquery",
+	"eq", "ge", "gt", "le", "lt", "ne",
+}
+
+// FuncsConfig is the optional funcs.yaml file in the prompts directory that defines
+// named aliases composed of built-in string transformations, e.g. "shout: [trim, upper]".
+// Transformations of an alias are applied in the declared order.
+type FuncsConfig struct {
+	Aliases map[string][]string `yaml:"aliases"`
+}
+
+// loadFuncsConfig reads the functions config from the prompts directory.
+// It returns nil without an error if the config does not exist.
+func loadFuncsConfig(promptsDir string) (*FuncsConfig, error) {
+	content, err := os.ReadFile(filepath.Join(promptsDir, funcsFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read funcs config: %w", err)
+	}
+	var cfg FuncsConfig
+	if err = yaml.Unmarshal(content, &cfg); err != nil {
+		return nil, fmt.Errorf("parse funcs config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// FuncMap builds template functions for all configured aliases.
+// Aliases may not shadow already registered functions and may only compose built-in string transformations.
+func (cfg *FuncsConfig) FuncMap(registered template.FuncMap) (template.FuncMap, error) {
+	funcs := template.FuncMap{}
+	if cfg == nil {
+		return funcs, nil
+	}
+
+	names := make([]string, 0, len(cfg.Aliases))
+	for name := range cfg.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !funcAliasNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid alias name %q", name)
+		}
+		if _, exists := registered[name]; exists || slices.Contains(templateBuiltinFuncs, name) {
+			return nil, fmt.Errorf("alias %q shadows an existing function", name)
+		}
+		steps := cfg.Aliases[name]
+		if len(steps) == 0 {
+			return nil, fmt.Errorf("alias %q has no transformations", name)
+		}
+		transforms := make([]func(string) string, 0, len(steps))
+		for _, step := range steps {
+			transform, ok := stringTransforms[step]
+			if !ok {
+				return nil, fmt.Errorf("alias %q uses unknown transformation %q", name, step)
+			}
+			transforms = append(transforms, transform)
+		}
+		funcs[name] = func(value interface{}) string {
+			s := stringify(value)
+			for _, transform := range transforms {
+				s = transform(s)
+			}
+			return s
+		}
+	}
+	return funcs, nil
+}
+
+// stringTransformFuncs returns the built-in string transformations wrapped for template usage.
+func stringTransformFuncs() template.FuncMap {
+	funcs := make(template.FuncMap, len(stringTransforms))
+	for name, transform := range stringTransforms {
+		funcs[name] = func(value interface{}) string {
+			return transform(stringify(value))
+		}
+	}
+	return funcs
+}
+
+func stringify(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// slugify lowercases the text and joins its runs of letters and digits with hyphens,
+// e.g. "Fix: Login bug!" becomes "fix-login-bug".
+func slugify(s string) string {
+	var b strings.Builder
+	separate := false
+	for _, r := range strings.ToLower(s) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			separate = true
+			continue
+		}
+		if separate && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		separate = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type FuncsTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+func TestFuncsTestSuite(t *testing.T) {
+	suite.Run(t, new(FuncsTestSuite))
+}
+
+func (s *FuncsTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+}
+
+func (s *FuncsTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, name), []byte(content), 0644))
+}
+
+// TestComposedAliasInTemplate tests that a composed alias from funcs.yaml is usable in templates
+func (s *FuncsTestSuite) TestComposedAliasInTemplate() {
+	s.writeFile(funcsFileName, "aliases:\n  shout: [trim, upper]\n  flat_quote: [oneline, quote]\n")
+	s.writeFile("greeting.tmpl", "{{/* Greeting */}}\nHello {{shout .name}}! {{flat_quote .note}} {{lower .count}}")
+
+	var buf bytes.Buffer
+	err := renderTemplate(&buf, s.tempDir, nil, "greeting", map[string]string{
+		"name":  "  alice  ",
+		"note":  "line one\n  line two",
+		"count": "42",
+	}, true)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), `Hello ALICE! "line one line two" 42`, buf.String())
+}
+
+// TestBuiltinTransformsWithoutConfig tests that built-in transformations are available without funcs.yaml
+func (s *FuncsTestSuite) TestBuiltinTransformsWithoutConfig() {
+	s.writeFile("greeting.tmpl", "{{upper .name}} {{trim .padded}}")
+
+	var buf bytes.Buffer
+	err := renderTemplate(&buf, s.tempDir, nil, "greeting", map[string]string{"name": "bob", "padded": " x "}, true)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "BOB x", buf.String())
+}
+
+// TestSlugify tests the slugify transformation
+func (s *FuncsTestSuite) TestSlugify() {
+	tests := map[string]string{
+		"Fix: Login bug!":         "fix-login-bug",
+		"  leading and trailing ": "leading-and-trailing",
+		"Größe über_alles 2":      "größe-über-alles-2",
+		"---":                     "",
+		"":                        "",
+	}
+	for input, expected := range tests {
+		assert.Equal(s.T(), expected, slugify(input), "input %q", input)
+	}
+}
+
+// TestFuncsConfigErrorCases tests invalid alias definitions
+func (s *FuncsTestSuite) TestFuncsConfigErrorCases() {
+	tests := []struct {
+		name        string
+		content     string
+		expectedErr string
+	}{
+		{
+			name:        "invalid YAML",
+			content:     "aliases: [",
+			expectedErr: "parse funcs config",
+		},
+		{
+			name:        "unknown transformation",
+			content:     "aliases:\n  shout: [upper, exec]\n",
+			expectedErr: `alias "shout" uses unknown transformation "exec"`,
+		},
+		{
+			name:        "shadows helper",
+			content:     "aliases:\n  dict: [upper]\n",
+			expectedErr: `alias "dict" shadows an existing function`,
+		},
+		{
+			name:        "shadows template builtin",
+			content:     "aliases:\n  printf: [upper]\n",
+			expectedErr: `alias "printf" shadows an existing function`,
+		},
+		{
+			name:        "empty composition",
+			content:     "aliases:\n  noop: []\n",
+			expectedErr: `alias "noop" has no transformations`,
+		},
+		{
+			name:        "invalid name",
+			content:     "aliases:\n  my-alias: [upper]\n",
+			expectedErr: `invalid alias name "my-alias"`,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.writeFile(funcsFileName, tt.content)
+			s.writeFile("greeting.tmpl", "Hello")
+			_, err := (&PromptsParser{}).ParseDir(s.tempDir)
+			require.Error(s.T(), err)
+			assert.Contains(s.T(), err.Error(), tt.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// gitCommandTimeout bounds every git invocation of --git-tracked-only.
+const gitCommandTimeout = 10 * time.Second
+
+// errNotGitWorktree is returned for prompts directories outside a git worktree.
+var errNotGitWorktree = errors.New("not inside a git worktree")
+
+// templateFilter reports whether a template file of the prompts directory is loaded, by file name.
+type templateFilter func(fileName string) bool
+
+// runGitInWorktree runs git in the directory like runGit, with a timeout, and returns errNotGitWorktree
+// if the directory is not inside a git repository.
+func runGitInWorktree(dir string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitCommandTimeout)
+	defer cancel()
+	out, err := runGit(ctx, dir, args...)
+	if err != nil && strings.Contains(err.Error(), "not a git repository") {
+		return nil, errNotGitWorktree
+	}
+	return out, err
+}
+
+// gitIndexPath returns the index file of the git worktree containing the directory.
+// Git rewrites the index on every git add and git rm, so its changes signal changes of the tracked files.
+func gitIndexPath(dir string) (string, error) {
+	out, err := runGitInWorktree(dir, "rev-parse", "--is-inside-work-tree", "--absolute-git-dir")
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 || lines[0] != "true" {
+		return "", errNotGitWorktree
+	}
+	return filepath.Join(lines[1], "index"), nil
+}
+
+// gitTrackedFiles returns the names of the files directly in the directory that are tracked by git.
+func gitTrackedFiles(dir string) (map[string]bool, error) {
+	out, err := runGitInWorktree(dir, "ls-files", "-z", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	tracked := make(map[string]bool)
+	for _, path := range strings.Split(string(out), "\x00") {
+		// Paths are relative to the directory; files of subdirectories are never templates
+		if path != "" && !strings.Contains(path, "/") {
+			tracked[path] = true
+		}
+	}
+	return tracked, nil
+}
+
+// untrackedTemplates returns the template files of the directory, partials included, that are not tracked.
+func untrackedTemplates(dir string, tracked map[string]bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read prompts directory: %w", err)
+	}
+	var untracked []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), templateExt) && !tracked[entry.Name()] {
+			untracked = append(untracked, entry.Name())
+		}
+	}
+	slices.Sort(untracked)
+	return untracked, nil
+}
+
+// gitTrackedFilter returns the template filter of --git-tracked-only for the CLI commands and reports
+// the skipped untracked templates to w. Outside a git worktree, it warns and returns a nil filter.
+func gitTrackedFilter(w io.Writer, promptsDir string) (templateFilter, error) {
+	tracked, err := gitTrackedFiles(promptsDir)
+	if errors.Is(err, errNotGitWorktree) {
+		mustFprintf(w, "%s %s\n", warningIcon(), localize("git.not_worktree", pathText(promptsDir)))
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := untrackedTemplates(promptsDir, tracked)
+	if err != nil {
+		return nil, err
+	}
+	if len(untracked) > 0 {
+		mustFprintf(w, "%s %s\n", warningIcon(), localize("git.untracked_skipped", strings.Join(untracked, ", ")))
+	}
+	return func(fileName string) bool { return tracked[fileName] }, nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type GitTrackedTestSuite struct {
+	suite.Suite
+	repoDir    string
+	promptsDir string
+}
+
+func TestGitTrackedTestSuite(t *testing.T) {
+	suite.Run(t, new(GitTrackedTestSuite))
+}
+
+// SetupTest creates a git repository with a prompts directory holding tracked and untracked templates
+func (s *GitTrackedTestSuite) SetupTest() {
+	if _, err := exec.LookPath("git"); err != nil {
+		s.T().Skip("git is not installed")
+	}
+	s.repoDir = s.T().TempDir()
+	s.promptsDir = filepath.Join(s.repoDir, "prompts")
+	require.NoError(s.T(), os.Mkdir(s.promptsDir, 0755))
+	s.git("init", "--quiet")
+
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello {{.name}}{{template \"_sign\" .}}")
+	s.writeFile("_sign.tmpl", `{{define "_sign"}}!{{end}}`)
+	s.writeFile("wip_试验.tmpl", "{{/* Scratch */}}\nNot ready")
+	require.NoError(s.T(), os.Mkdir(filepath.Join(s.promptsDir, "drafts"), 0755))
+	s.writeFile("drafts/old.tmpl", "{{/* Old */}}\nOld")
+	s.git("add", "prompts/greet.tmpl", "prompts/_sign.tmpl", "prompts/drafts/old.tmpl")
+}
+
+func (s *GitTrackedTestSuite) git(args ...string) {
+	output, err := exec.Command("git", append([]string{"-C", s.repoDir}, args...)...).CombinedOutput()
+	require.NoError(s.T(), err, "git %s: %s", strings.Join(args, " "), output)
+}
+
+func (s *GitTrackedTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+func (s *GitTrackedTestSuite) promptNames(promptsServer *PromptsServer) []string {
+	listPrompts := []byte(`{"jsonrpc":"2.0","id":1,"method":"prompts/list"}`)
+	response, err := json.Marshal(promptsServer.mcpServer.HandleMessage(context.Background(), listPrompts))
+	require.NoError(s.T(), err)
+	var decoded struct {
+		Result struct {
+			Prompts []struct {
+				Name string `json:"name"`
+			} `json:"prompts"`
+		} `json:"result"`
+	}
+	require.NoError(s.T(), json.Unmarshal(response, &decoded))
+	var names []string
+	for _, prompt := range decoded.Result.Prompts {
+		names = append(names, prompt.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// TestGitTrackedFiles tests listing tracked files of the directory and the worktree index
+func (s *GitTrackedTestSuite) TestGitTrackedFiles() {
+	tracked, err := gitTrackedFiles(s.promptsDir)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), map[string]bool{"greet.tmpl": true, "_sign.tmpl": true}, tracked)
+
+	untracked, err := untrackedTemplates(s.promptsDir, tracked)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"wip_试验.tmpl"}, untracked)
+
+	indexPath, err := gitIndexPath(s.promptsDir)
+	require.NoError(s.T(), err)
+	assert.FileExists(s.T(), indexPath)
+	assert.Equal(s.T(), "index", filepath.Base(indexPath))
+
+	outside := s.T().TempDir()
+	_, err = gitIndexPath(outside)
+	assert.ErrorIs(s.T(), err, errNotGitWorktree)
+	_, err = gitTrackedFiles(outside)
+	assert.ErrorIs(s.T(), err, errNotGitWorktree)
+}
+
+// TestServerServesTrackedOnly tests that untracked templates are logged and not served,
+// and that changes of tracking are picked up on reload
+func (s *GitTrackedTestSuite) TestServerServesTrackedOnly() {
+	var logs syncBuffer
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithGitTrackedOnly(true), WithWatchMode(WatchModeOff, 0),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	assert.Equal(s.T(), []string{"greet"}, s.promptNames(promptsServer))
+	assert.Contains(s.T(), strings.Join(logs.Lines(), "\n"), `msg="Untracked templates are not served" templates=[wip_试验.tmpl]`)
+
+	s.git("add", "prompts/wip_试验.tmpl")
+	s.git("rm", "--cached", "--quiet", "prompts/greet.tmpl")
+	require.NoError(s.T(), promptsServer.reloadPrompts())
+	assert.Equal(s.T(), []string{"wip_试验"}, s.promptNames(promptsServer))
+
+	// An untracked partial is not loaded either, so templates using it fail to load
+	s.git("add", "prompts/greet.tmpl")
+	s.git("rm", "--cached", "--quiet", "prompts/_sign.tmpl")
+	err = promptsServer.reloadPrompts()
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), `referenced template "_sign" not found`)
+}
+
+// TestServerReloadsOnIndexChange tests that git add triggers a reload through the git index
+func (s *GitTrackedTestSuite) TestServerReloadsOnIndexChange() {
+	for _, mode := range []WatchMode{WatchModeFSNotify, WatchModePoll} {
+		s.Run(string(mode), func() {
+			s.git("rm", "--cached", "--quiet", "--ignore-unmatch", "prompts/wip_试验.tmpl")
+			var logs syncBuffer
+			promptsServer, err := NewPromptsServer(s.promptsDir, WithGitTrackedOnly(true),
+				WithWatchMode(mode, 10*time.Millisecond), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+			require.NoError(s.T(), err)
+			defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				promptsServer.startWatcher(ctx)
+			}()
+			defer func() {
+				cancel()
+				wg.Wait()
+			}()
+			require.Eventually(s.T(), func() bool {
+				return strings.Contains(strings.Join(logs.Lines(), "\n"), "Started watching prompts directory")
+			}, time.Second, time.Millisecond)
+			assert.Equal(s.T(), []string{"greet"}, s.promptNames(promptsServer))
+
+			s.git("add", "prompts/wip_试验.tmpl")
+			require.Eventually(s.T(), func() bool {
+				return slices.Equal([]string{"greet", "wip_试验"}, s.promptNames(promptsServer))
+			}, 2*time.Second, 10*time.Millisecond, "server should reload after git add")
+		})
+	}
+}
+
+// TestServerOutsideGitWorktree tests that the option is a no-op with a warning outside a git worktree
+func (s *GitTrackedTestSuite) TestServerOutsideGitWorktree() {
+	promptsDir := s.T().TempDir()
+	require.NoError(s.T(), os.WriteFile(filepath.Join(promptsDir, "greet.tmpl"), []byte("{{/* Greet */}}\nHello"), 0644))
+	var logs syncBuffer
+	promptsServer, err := NewPromptsServer(promptsDir, WithGitTrackedOnly(true), WithWatchMode(WatchModeOff, 0),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	assert.Equal(s.T(), []string{"greet"}, s.promptNames(promptsServer))
+	assert.Contains(s.T(), strings.Join(logs.Lines(), "\n"),
+		`level=WARN msg="Prompts directory is not inside a git worktree, serving untracked templates too"`)
+}
+
+// TestListAndValidateTrackedOnly tests the --git-tracked-only flag of list and validate
+func (s *GitTrackedTestSuite) TestListAndValidateTrackedOnly() {
+	var buf bytes.Buffer
+	require.NoError(s.T(), listTemplates(&buf, s.promptsDir, listOptions{gitTrackedOnly: true}))
+	assert.Equal(s.T(), "⚠ Skipping untracked templates: wip_试验.tmpl\ngreet.tmpl\n", removeANSIColors(buf.String()))
+
+	buf.Reset()
+	require.NoError(s.T(), validateTemplates(&buf, s.promptsDir, nil, "", validateOptions{gitTrackedOnly: true}))
+	output := removeANSIColors(buf.String())
+	assert.Contains(s.T(), output, "⚠ Skipping untracked templates: wip_试验.tmpl\n")
+	assert.Contains(s.T(), output, "greet.tmpl")
+	assert.NotContains(s.T(), output, "✓ wip_试验.tmpl")
+
+	err := validateTemplates(&bytes.Buffer{}, s.promptsDir, nil, "wip_试验", validateOptions{gitTrackedOnly: true})
+	assert.ErrorContains(s.T(), err, `template "wip_试验.tmpl" not found`)
+
+	outside := s.T().TempDir()
+	require.NoError(s.T(), os.WriteFile(filepath.Join(outside, "greet.tmpl"), []byte("{{/* Greet */}}\nHello"), 0644))
+	buf.Reset()
+	require.NoError(s.T(), listTemplates(&buf, outside, listOptions{gitTrackedOnly: true}))
+	assert.Equal(s.T(), "⚠ "+outside+" is not inside a git worktree, --git-tracked-only has no effect\ngreet.tmpl\n",
+		removeANSIColors(buf.String()))
+}
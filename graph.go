@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// promptDependencies are the partials a prompt depends on.
+type promptDependencies struct {
+	prompt   string
+	partials []string // direct and transitive dependencies, sorted
+	err      error    // why the dependencies cannot be determined, e.g. a cyclic reference
+}
+
+// loadPromptDependencies parses the prompts directory and returns the dependencies of every prompt, sorted by prompt name.
+func loadPromptDependencies(promptsDir string, partialsDirs []string) (*template.Template, []promptDependencies, error) {
+	availableTemplates, err := getAvailableTemplates(promptsDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	parser := &PromptsParser{partialsDirs: partialsDirs}
+	tmpl, err := parser.ParseDir(promptsDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse all prompts: %w", err)
+	}
+	deps := make([]promptDependencies, 0, len(availableTemplates))
+	for _, templateName := range availableTemplates {
+		partials, err := parser.ExtractPromptPartialsFromTemplate(tmpl, templateName)
+		for i := range partials {
+			partials[i] = strings.TrimSuffix(partials[i], templateExt)
+		}
+		deps = append(deps, promptDependencies{
+			prompt: strings.TrimSuffix(templateName, templateExt), partials: partials, err: err,
+		})
+	}
+	return tmpl, deps, nil
+}
+
+// printPromptDependencies writes every prompt followed by the partials it depends on, one per indented line.
+func printPromptDependencies(w io.Writer, deps []promptDependencies) {
+	for _, dep := range deps {
+		mustFprintf(w, "%s\n", templateText(dep.prompt))
+		switch {
+		case dep.err != nil:
+			mustFprintf(w, "  %s\n", errorText(localize("status.error", dep.err)))
+		case len(dep.partials) == 0:
+			mustFprintf(w, "  %s\n", infoText(localize("graph.no_partials")))
+		default:
+			for _, partial := range dep.partials {
+				mustFprintf(w, "  %s\n", partial)
+			}
+		}
+	}
+}
+
+// printPromptDependenciesDOT writes the dependencies as a Graphviz DOT digraph, with an edge from every
+// template to each partial it references directly. Prompts are drawn as boxes, partials as ellipses.
+func printPromptDependenciesDOT(w io.Writer, tmpl *template.Template, deps []promptDependencies) error {
+	mustFprintf(w, "digraph prompts {\n")
+	edges := make(map[string]bool)
+	for _, dep := range deps {
+		if dep.err != nil {
+			return fmt.Errorf("prompt %q: %w", dep.prompt, dep.err)
+		}
+		mustFprintf(w, "  %q [shape=box];\n", dep.prompt)
+		for _, from := range append([]string{dep.prompt}, dep.partials...) {
+			for _, to := range directPartials(tmpl, from) {
+				if edge := fmt.Sprintf("  %q -> %q;\n", from, to); !edges[edge] {
+					edges[edge] = true
+					mustFprintf(w, "%s", edge)
+				}
+			}
+		}
+	}
+	mustFprintf(w, "}\n")
+	return nil
+}
+
+// directPartials returns the sorted names (without the template extension) of the templates
+// that the template references itself, not through other partials.
+func directPartials(tmpl *template.Template, name string) []string {
+	t := lookupPartial(tmpl, name)
+	if t == nil {
+		return nil
+	}
+	names := make(map[string]struct{})
+	collectTemplateRefs(t.Root, names)
+	partials := make([]string, 0, len(names))
+	for partial := range names {
+		partials = append(partials, partial)
+	}
+	sort.Strings(partials)
+	return partials
+}
+
+// collectTemplateRefs adds the names of the templates invoked within the node to names.
+func collectTemplateRefs(node parse.Node, names map[string]struct{}) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectTemplateRefs(child, names)
+		}
+	case *parse.IfNode:
+		collectTemplateRefs(n.List, names)
+		collectTemplateRefs(n.ElseList, names)
+	case *parse.RangeNode:
+		collectTemplateRefs(n.List, names)
+		collectTemplateRefs(n.ElseList, names)
+	case *parse.WithNode:
+		collectTemplateRefs(n.List, names)
+		collectTemplateRefs(n.ElseList, names)
+	case *parse.TemplateNode:
+		names[strings.TrimSuffix(n.Name, templateExt)] = struct{}{}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type GraphTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestGraphTestSuite(t *testing.T) {
+	suite.Run(t, new(GraphTestSuite))
+}
+
+func (s *GraphTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("_inner.tmpl", `{{define "_inner.tmpl"}}Inner{{end}}`)
+	s.writeFile("_outer.tmpl", `{{define "_outer.tmpl"}}{{if .x}}{{template "_inner.tmpl" .}}{{end}}{{end}}`)
+	s.writeFile("_footer.tmpl", `{{define "_footer.tmpl"}}Footer{{end}}`)
+	s.writeFile("report.tmpl", "{{/* Report */}}\n{{template \"_outer.tmpl\" .}}\n{{template \"_footer.tmpl\" .}}")
+	s.writeFile("plain.tmpl", "{{/* Plain */}}\nHello {{.name}}")
+}
+
+func (s *GraphTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+func (s *GraphTestSuite) runGraph(args ...string) (string, error) {
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	err := app.Run(context.Background(),
+		append([]string{app.Name, "--color", "never", "--prompts", s.promptsDir, "graph"}, args...))
+	return buf.String(), err
+}
+
+// TestNestedPartials tests that prompts list the partials they depend on through other partials
+func (s *GraphTestSuite) TestNestedPartials() {
+	_, deps, err := loadPromptDependencies(s.promptsDir, nil)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []promptDependencies{
+		{prompt: "plain", partials: []string{}},
+		{prompt: "report", partials: []string{"_footer", "_inner", "_outer"}},
+	}, deps)
+
+	output, err := s.runGraph()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "plain\n  (no partials)\nreport\n  _footer\n  _inner\n  _outer\n", output)
+}
+
+// TestDOT tests that the DOT output has an edge for every direct reference only
+func (s *GraphTestSuite) TestDOT() {
+	output, err := s.runGraph("--dot")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), `digraph prompts {
+  "plain" [shape=box];
+  "report" [shape=box];
+  "report" -> "_footer";
+  "report" -> "_outer";
+  "_outer" -> "_inner";
+}
+`, output)
+}
+
+// TestCycle tests that a prompt with a cyclic partial reference is reported, without hiding the other prompts
+func (s *GraphTestSuite) TestCycle() {
+	s.writeFile("_inner.tmpl", `{{define "_inner.tmpl"}}{{template "_outer.tmpl" .}}{{end}}`)
+
+	output, err := s.runGraph()
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), output, "plain\n  (no partials)\n")
+	assert.Contains(s.T(), output,
+		"report\n  Error: cyclic partial reference detected: _outer.tmpl -> _inner.tmpl -> _outer.tmpl\n")
+
+	_, err = s.runGraph("--dot")
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), `failed to build the dependency graph: prompt "report": cyclic partial reference detected`)
+}
@@ -0,0 +1,126 @@
+package main
+
+import "unicode"
+
+// graphemeCount returns the number of extended grapheme clusters of s, i.e. the characters a user perceives,
+// following the Unicode segmentation rules (UAX #29) that matter for icons: combining marks, emoji modifiers,
+// variation selectors, keycaps, tag sequences, ZWJ emoji sequences, flags, and Hangul syllables.
+// For example, "👩‍👩‍👧" (woman, ZWJ, woman, ZWJ, girl) and "🇩🇪" are one cluster each.
+func graphemeCount(s string) int {
+	count := 0
+	var prev rune
+	clusterHasPictographic := false // the cluster has an Extended_Pictographic character, for ZWJ sequences
+	regionalIndicators := 0         // regional indicators at the end of the cluster, paired into flags
+	for i, r := range s {
+		if i > 0 && !graphemeJoins(prev, r, clusterHasPictographic, regionalIndicators) {
+			clusterHasPictographic, regionalIndicators = false, 0
+			count++
+		} else if i == 0 {
+			count++
+		}
+		if isExtendedPictographic(r) {
+			clusterHasPictographic = true
+		}
+		if isRegionalIndicator(r) {
+			regionalIndicators++
+		} else {
+			regionalIndicators = 0
+		}
+		prev = r
+	}
+	return count
+}
+
+// graphemeJoins reports whether there is no grapheme cluster boundary between prev and r.
+func graphemeJoins(prev, r rune, clusterHasPictographic bool, regionalIndicators int) bool {
+	switch {
+	case prev == '\r' && r == '\n': // GB3
+		return true
+	case isControlRune(prev) || isControlRune(r): // GB4, GB5
+		return false
+	case hangulJoins(prev, r): // GB6-GB8
+		return true
+	case isGraphemeExtend(r) || r == zeroWidthJoiner || unicode.Is(unicode.Mc, r): // GB9, GB9a
+		return true
+	case prev == zeroWidthJoiner && clusterHasPictographic && isExtendedPictographic(r): // GB11
+		return true
+	case isRegionalIndicator(prev) && isRegionalIndicator(r): // GB12, GB13
+		return regionalIndicators%2 == 1
+	}
+	return false
+}
+
+const zeroWidthJoiner = '\u200d'
+
+func isControlRune(r rune) bool {
+	return unicode.IsControl(r) || r == '\u2028' || r == '\u2029' // line and paragraph separators
+}
+
+// isGraphemeExtend approximates the Grapheme_Extend property, plus the emoji modifiers that extend their base.
+func isGraphemeExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me) ||
+		r >= 0xfe00 && r <= 0xfe0f || // variation selectors, e.g. the emoji presentation selector
+		r >= 0x1f3fb && r <= 0x1f3ff || // skin tone modifiers
+		r >= 0xe0020 && r <= 0xe007f || // tags, e.g. of subdivision flags
+		r >= 0xe0100 && r <= 0xe01ef // variation selectors supplement
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// isExtendedPictographic approximates the Extended_Pictographic property with the blocks holding emoji.
+func isExtendedPictographic(r rune) bool {
+	switch r {
+	case 0xa9, 0xae, 0x203c, 0x2049, 0x2122, 0x2139, 0x24c2, 0x3030, 0x303d, 0x3297, 0x3299:
+		return true
+	}
+	return r >= 0x2194 && r <= 0x21aa ||
+		r >= 0x2300 && r <= 0x23ff ||
+		r >= 0x25aa && r <= 0x25fe ||
+		r >= 0x2600 && r <= 0x27bf ||
+		r >= 0x2934 && r <= 0x2935 ||
+		r >= 0x2b05 && r <= 0x2b55 ||
+		r >= 0x1f000 && r <= 0x1faff && !isRegionalIndicator(r) && !(r >= 0x1f3fb && r <= 0x1f3ff) ||
+		r >= 0x1fc00 && r <= 0x1fffd
+}
+
+// Hangul jamo and syllable types of the segmentation rules.
+const (
+	hangulNone = iota
+	hangulL
+	hangulV
+	hangulT
+	hangulLV
+	hangulLVT
+)
+
+func hangulType(r rune) int {
+	switch {
+	case r >= 0x1100 && r <= 0x115f, r >= 0xa960 && r <= 0xa97c:
+		return hangulL
+	case r >= 0x1160 && r <= 0x11a7, r >= 0xd7b0 && r <= 0xd7c6:
+		return hangulV
+	case r >= 0x11a8 && r <= 0x11ff, r >= 0xd7cb && r <= 0xd7fb:
+		return hangulT
+	case r >= 0xac00 && r <= 0xd7a3:
+		if (r-0xac00)%28 == 0 {
+			return hangulLV
+		}
+		return hangulLVT
+	}
+	return hangulNone
+}
+
+func hangulJoins(prev, r rune) bool {
+	next := hangulType(r)
+	switch hangulType(prev) {
+	case hangulL:
+		return next == hangulL || next == hangulV || next == hangulLV || next == hangulLVT
+	case hangulLV, hangulV:
+		return next == hangulV || next == hangulT
+	case hangulLVT, hangulT:
+		return next == hangulT
+	}
+	return false
+}
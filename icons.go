@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// iconDirective sets the icon of a prompt in a comment of its template, e.g. {{/* @icon 🧪 */}}:
+// a single emoji or a short named icon token such as "beaker".
+const iconDirective = "@icon"
+
+// iconTokenRegex matches named icon tokens, which clients may map to icons of their own.
+var iconTokenRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// IconMode selects where the icon of a prompt is shown, since MCP prompts have no icon field.
+type IconMode string
+
+const (
+	// IconModeTitle prepends the icon to the title of the prompt (the default).
+	IconModeTitle IconMode = "title"
+	// IconModeDescription prepends the icon to the description of the prompt.
+	IconModeDescription IconMode = "description"
+	// IconModeOff does not show icons.
+	IconModeOff IconMode = "off"
+)
+
+// ParseIconMode parses an icon mode as accepted by the --icon-mode flag.
+func ParseIconMode(s string) (IconMode, error) {
+	switch mode := IconMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case IconModeTitle, IconModeDescription, IconModeOff:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown icon mode %q (expected %s, %s, or %s)", s, IconModeTitle, IconModeDescription, IconModeOff)
+}
+
+// ExtractPromptIcon returns the icon set by the comments of the template, without those of its partials,
+// or an empty string if it sets none. The last @icon directive wins.
+func (pp *PromptsParser) ExtractPromptIcon(tmpl *template.Template, templateName string) (string, error) {
+	targetTemplate := lookupPartial(tmpl, templateName)
+	if targetTemplate == nil {
+		return "", fmt.Errorf("template %q not found", templateName)
+	}
+	return treeIcon(targetTemplate.Root), nil
+}
+
+// readPromptIcon returns the icon set by a template file, parsing it on its own. Files that do not parse have none.
+func readPromptIcon(filePath string) string {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return ""
+	}
+	if _, content, err = splitFrontmatter(content); err != nil {
+		return ""
+	}
+	body, delims, err := withDelimsDirective(string(content))
+	if err != nil {
+		return ""
+	}
+	tree := parse.New(filepath.Base(filePath))
+	tree.Mode = parse.ParseComments | parse.SkipFuncCheck
+	if _, err = tree.Parse(body, delims.left, delims.right, make(map[string]*parse.Tree)); err != nil {
+		return ""
+	}
+	return treeIcon(tree.Root)
+}
+
+// treeIcon returns the value of the last @icon directive in the comments under the node.
+func treeIcon(root *parse.ListNode) string {
+	var icon string
+	walkComments(root, func(comment *parse.CommentNode) {
+		text := strings.TrimSuffix(strings.TrimPrefix(comment.Text, "/*"), "*/")
+		for _, line := range strings.Split(text, "\n") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(line), iconDirective+" "); ok {
+				icon = strings.TrimSpace(value)
+			}
+		}
+	})
+	return icon
+}
+
+// checkIcon returns why an icon may break the layout of clients, or nil. Icons other than named tokens
+// must be a single grapheme cluster, so that e.g. a family emoji joined by ZWJs passes but two emoji do not.
+func checkIcon(icon string) error {
+	if iconTokenRegex.MatchString(icon) {
+		return nil
+	}
+	if count := graphemeCount(icon); count != 1 {
+		return fmt.Errorf("icon %q has %d characters instead of a single emoji or a named icon token", icon, count)
+	}
+	return nil
+}
+
+// Icon returns the default icon of the collection of the prompt, or an empty string.
+func (pc *PromptCollections) Icon(promptName string) string {
+	for _, collection := range pc.all() {
+		for _, entry := range collection.Prompts {
+			if entry.Name == promptName {
+				return collection.Icon
+			}
+		}
+	}
+	return ""
+}
+
+// all returns the collections of the manifest, none if there is no manifest.
+func (pc *PromptCollections) all() []PromptCollection {
+	if pc == nil {
+		return nil
+	}
+	return pc.Collections
+}
+
+// withIcon prepends the icon to a title or description.
+func withIcon(icon string, text string) string {
+	if icon == "" {
+		return text
+	}
+	if text == "" {
+		return icon
+	}
+	return icon + " " + text
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type IconsTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestIconsTestSuite(t *testing.T) {
+	suite.Run(t, new(IconsTestSuite))
+}
+
+func (s *IconsTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("experiment.tmpl", "{{/* Run an experiment\n@icon 🧪\n*/}}\nExperiment {{.name}}")
+	s.writeFile("commit.tmpl", "{{/* Write a commit message */}}\nCommit {{.type}}")
+	s.writeFile("greeting.tmpl", "{{/* Greet someone */}}\nHello {{.name}}")
+	s.writeFile(collectionsFileName, "collections:\n  - name: Git\n    icon: 🔀\n    prompts:\n      - commit\n      - experiment\n")
+}
+
+func (s *IconsTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+func (s *IconsTestSuite) listPrompts(mode IconMode) map[string]mcp.Prompt {
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithIconMode(mode), WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "prompts/list", "params": map[string]any{}})
+	require.NoError(s.T(), err)
+	response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok)
+	prompts := make(map[string]mcp.Prompt)
+	for _, prompt := range response.Result.(mcp.ListPromptsResult).Prompts {
+		prompts[prompt.Name] = prompt
+	}
+	return prompts
+}
+
+func promptTitle(prompt mcp.Prompt) string {
+	if prompt.Meta == nil {
+		return ""
+	}
+	title, _ := prompt.Meta.AdditionalFields["title"].(string)
+	return title
+}
+
+// TestGraphemeCount tests that multi-codepoint emoji are counted as a single character
+func (s *IconsTestSuite) TestGraphemeCount() {
+	for _, tc := range []struct {
+		text     string
+		expected int
+	}{
+		{text: "", expected: 0},
+		{text: "🧪", expected: 1},
+		{text: "👩‍👩‍👧", expected: 1}, // family, joined by ZWJs
+		{text: "🧑🏽‍💻", expected: 1},  // technologist with a skin tone
+		{text: "🏳️‍🌈", expected: 1},  // rainbow flag, with a variation selector
+		{text: "🇩🇪", expected: 1},    // flag of regional indicators
+		{text: "🇩🇪🇫🇷", expected: 2},  // two flags
+		{text: "1️⃣", expected: 1},   // keycap
+		{text: "é", expected: 1},     // e with a combining acute accent
+		{text: "한", expected: 1},     // Hangul syllable of jamo
+		{text: "🧪🔬", expected: 2},    // two emoji
+		{text: "👩‍", expected: 1},    // dangling ZWJ
+		{text: "ab", expected: 2},
+	} {
+		assert.Equal(s.T(), tc.expected, graphemeCount(tc.text), "%q", tc.text)
+	}
+
+	assert.NoError(s.T(), checkIcon("👩‍👩‍👧"))
+	assert.NoError(s.T(), checkIcon("beaker"))
+	assert.EqualError(s.T(), checkIcon("🧪🔬"), `icon "🧪🔬" has 2 characters instead of a single emoji or a named icon token`)
+}
+
+// TestIconModes tests where the icons are shown in each mode, and that they are not part of the description
+func (s *IconsTestSuite) TestIconModes() {
+	prompts := s.listPrompts(IconModeTitle)
+	assert.Equal(s.T(), "🧪 Git: experiment", promptTitle(prompts["experiment"]))
+	assert.Equal(s.T(), "Git: experiment - Run an experiment", prompts["experiment"].Description)
+	assert.Equal(s.T(), "greeting", prompts["greeting"].Name)
+	assert.Empty(s.T(), promptTitle(prompts["greeting"]), "prompts without an icon must not get a title")
+
+	prompts = s.listPrompts(IconModeDescription)
+	assert.Equal(s.T(), "Git: experiment", promptTitle(prompts["experiment"]))
+	assert.Equal(s.T(), "🧪 Git: experiment - Run an experiment", prompts["experiment"].Description)
+	assert.Equal(s.T(), "Greet someone", prompts["greeting"].Description)
+
+	prompts = s.listPrompts(IconModeOff)
+	assert.Equal(s.T(), "Git: experiment", promptTitle(prompts["experiment"]))
+	assert.Equal(s.T(), "Git: experiment - Run an experiment", prompts["experiment"].Description)
+
+	mode, err := ParseIconMode(" Description ")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), IconModeDescription, mode)
+	_, err = ParseIconMode("badge")
+	require.EqualError(s.T(), err, `unknown icon mode "badge" (expected title, description, or off)`)
+}
+
+// TestCollectionIcon tests that prompts without an @icon inherit the icon of their collection
+func (s *IconsTestSuite) TestCollectionIcon() {
+	prompts := s.listPrompts(IconModeTitle)
+	assert.Equal(s.T(), "🔀 Git: commit", promptTitle(prompts["commit"]))
+	assert.Equal(s.T(), "🧪 Git: experiment", promptTitle(prompts["experiment"]), "@icon must win over the collection")
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), listTemplates(&buf, s.promptsDir, listOptions{}))
+	assert.Equal(s.T(), "🔀 commit.tmpl\n🧪 experiment.tmpl\ngreeting.tmpl\n", removeANSIColors(buf.String()))
+}
+
+// TestValidateWarnsAboutIcons tests that icons of several characters are reported without failing validation
+func (s *IconsTestSuite) TestValidateWarnsAboutIcons() {
+	s.writeFile("experiment.tmpl", "{{/* Run an experiment\n@icon 🧪🔬\n*/}}\nExperiment {{.name}}")
+	s.writeFile("family.tmpl", "{{/* Family\n@icon 👩‍👩‍👧\n*/}}\nFamily {{.name}}")
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), validateTemplates(&buf, s.promptsDir, nil, "", validateOptions{}))
+	output := removeANSIColors(buf.String())
+	assert.Contains(s.T(), output, `experiment.tmpl - icon "🧪🔬" has 2 characters instead of a single emoji`)
+	assert.NotContains(s.T(), output, "family.tmpl - icon")
+}
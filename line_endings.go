@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LineEndings selects the line endings of rendered prompts.
+type LineEndings string
+
+const (
+	LineEndingsKeep LineEndings = "keep" // line endings as written in the templates and arguments
+	LineEndingsLF   LineEndings = "lf"   // "\n" only
+	LineEndingsCRLF LineEndings = "crlf" // "\r\n" only
+)
+
+// ParseLineEndings parses a line endings name as accepted by the --line-endings flag.
+func ParseLineEndings(s string) (LineEndings, error) {
+	switch lineEndings := LineEndings(strings.ToLower(strings.TrimSpace(s))); lineEndings {
+	case LineEndingsKeep, LineEndingsLF, LineEndingsCRLF:
+		return lineEndings, nil
+	}
+	return "", fmt.Errorf("unknown line endings %q (expected lf, crlf, or keep)", s)
+}
+
+// normalizeLineEndings converts the line endings of the rendered output.
+// The zero value keeps them, like LineEndingsKeep.
+func normalizeLineEndings(s string, lineEndings LineEndings) string {
+	switch lineEndings {
+	case LineEndingsLF:
+		return strings.ReplaceAll(s, "\r\n", "\n")
+	case LineEndingsCRLF:
+		return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+	}
+	return s
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNormalizeLineEndings tests the conversion between line endings
+func TestNormalizeLineEndings(t *testing.T) {
+	tests := []struct {
+		name        string
+		lineEndings LineEndings
+		expected    string
+	}{
+		{name: "keep", lineEndings: LineEndingsKeep, expected: "a\r\nb\nc\r\n"},
+		{name: "zero value", expected: "a\r\nb\nc\r\n"},
+		{name: "lf", lineEndings: LineEndingsLF, expected: "a\nb\nc\n"},
+		{name: "crlf", lineEndings: LineEndingsCRLF, expected: "a\r\nb\r\nc\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, normalizeLineEndings("a\r\nb\nc\r\n", tt.lineEndings))
+		})
+	}
+
+	_, err := ParseLineEndings("cr")
+	require.Error(t, err)
+	lineEndings, err := ParseLineEndings(" CRLF ")
+	require.NoError(t, err)
+	assert.Equal(t, LineEndingsCRLF, lineEndings)
+}
+
+// TestRenderCRLFTemplate tests that a template authored with CRLF line endings renders with LF line endings
+func TestRenderCRLFTemplate(t *testing.T) {
+	promptsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(promptsDir, "greet.tmpl"),
+		[]byte("---\r\ndata:\r\n  team: Platform\r\n---\r\n{{/* Greet */}}\r\nHello {{.name}}!\r\nWelcome to {{.team}}.\r\n"), 0644))
+
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	require.NoError(t, app.Run(context.Background(), []string{
+		app.Name, "--line-endings", "lf", "render", promptsDir, "greet", "--arg", "name=Alice",
+	}))
+	assert.Equal(t, "Hello Alice!\nWelcome to Platform.", buf.String())
+
+	promptsServer, err := NewPromptsServer(promptsDir, WithRenderSettings(RenderSettings{LineEndings: LineEndingsLF}))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, promptsServer.Close()) }()
+	response, err := json.Marshal(promptsServer.mcpServer.HandleMessage(context.Background(),
+		[]byte(`{"jsonrpc":"2.0","id":1,"method":"prompts/get","params":{"name":"greet","arguments":{"name":"Bob"}}}`)))
+	require.NoError(t, err)
+	assert.Contains(t, string(response), `"text":"Hello Bob!\nWelcome to Platform."`)
+
+	err = newApp().Run(context.Background(), []string{"mcp-prompt-engine", "--line-endings", "cr", "render", promptsDir, "greet"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown line endings "cr"`)
+}
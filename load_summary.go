@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// LoadSummary describes the last successful load of the prompts directory, for tests and embedders
+// that need the result of the startup without parsing the log.
+type LoadSummary struct {
+	Prompts  []string // names of the registered prompts, sorted
+	Skipped  []string // template files left out for calling undefined functions
+	Warnings []string // problems that did not fail the load, e.g. prompts advertising only some of their arguments
+}
+
+// Event returns the summary as a lifecycle event, a warning if the load had any.
+func (s LoadSummary) Event() ServerEvent {
+	level := slog.LevelInfo
+	if len(s.Warnings) > 0 {
+		level = slog.LevelWarn
+	}
+	return ServerEvent{Level: level, Message: "Prompts loaded", Attrs: []any{
+		"count", len(s.Prompts), "prompts", s.Prompts, "skipped", s.Skipped, "warnings", len(s.Warnings),
+	}}
+}
+
+// formatLoadWarning formats a warning of the load like the text log does, e.g. `Message key=value`.
+func formatLoadWarning(msg string, args ...any) string {
+	var sb strings.Builder
+	sb.WriteString(msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		_, _ = fmt.Fprintf(&sb, " %v=%v", args[i], args[i+1])
+	}
+	return sb.String()
+}
+
+// LoadSummary returns the summary of the last successful load of the prompts.
+func (ps *PromptsServer) LoadSummary() LoadSummary {
+	return *ps.loadSummary.Load()
+}
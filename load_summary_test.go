@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type LoadSummaryTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestLoadSummaryTestSuite(t *testing.T) {
+	suite.Run(t, new(LoadSummaryTestSuite))
+}
+
+func (s *LoadSummaryTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello {{.name}}")
+	s.writeFile("review.tmpl", "{{/* Review */}}\n{{.branch}} {{.author}} {{.focus}}")
+	s.writeFile("snippet.tmpl", "{{/* Snippet */}}\n{{codefence .code}}")
+	s.writeFile("_footer.tmpl", `{{define "_footer"}}Thanks{{end}}`)
+}
+
+func (s *LoadSummaryTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+// TestSummaryReflectsLoadedPrompts tests the summary of the startup and that reloads replace it
+func (s *LoadSummaryTestSuite) TestSummaryReflectsLoadedPrompts() {
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithWatchMode(WatchModeOff, 0), WithMaxAdvertisedArgs(2))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	summary := promptsServer.LoadSummary()
+	assert.Equal(s.T(), []string{"greet", "review"}, summary.Prompts)
+	assert.Equal(s.T(), []string{"snippet.tmpl"}, summary.Skipped)
+	require.Len(s.T(), summary.Warnings, 2)
+	assert.Contains(s.T(), summary.Warnings[0],
+		"Skipping template file that calls an undefined function file=snippet.tmpl function=codefence")
+	assert.Equal(s.T(), "Prompt has too many arguments, advertising only the first ones name=review args=3 advertised=2",
+		summary.Warnings[1])
+
+	event := summary.Event()
+	assert.Equal(s.T(), "WARN Prompts loaded", event.Level.String()+" "+event.Message)
+
+	require.NoError(s.T(), os.Remove(filepath.Join(s.promptsDir, "snippet.tmpl")))
+	s.writeFile("summary.tmpl", "{{/* Summary */}}\n{{.text}}")
+	require.NoError(s.T(), promptsServer.Reload())
+	summary = promptsServer.LoadSummary()
+	assert.Equal(s.T(), []string{"greet", "review", "summary"}, summary.Prompts)
+	assert.Empty(s.T(), summary.Skipped)
+
+	s.writeFile("broken.tmpl", "{{/* Broken */}}\n{{if .x}}")
+	require.Error(s.T(), promptsServer.Reload())
+	assert.Equal(s.T(), summary, promptsServer.LoadSummary(), "a failed reload keeps the summary")
+}
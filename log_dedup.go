@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log deduplication defaults.
+const (
+	defaultLogDedupWindow        = 10 * time.Second
+	defaultReloadErrorsPerMinute = 10
+)
+
+// logSourceKey marks records by the code path that logged them. Error records carrying
+// logSourceReload are rate-limited by LogDedupHandler in addition to being deduplicated.
+const (
+	logSourceKey    = "source"
+	logSourceReload = "reload"
+)
+
+// repeatedAttrKey is the attribute holding the number of records collapsed into a summary record.
+const repeatedAttrKey = "repeated"
+
+// LogDedupHandler is a slog.Handler that protects log outputs from event storms, such as a broken template
+// being reloaded every time an external tool touches the prompts directory.
+//
+// The first record of a kind is passed through, and identical records (same level, message, and attributes)
+// that follow within the window are suppressed. When the window closes, the last suppressed record is emitted
+// once with a "repeated" attribute holding the number of suppressed records.
+// In addition, at most reloadErrorsPerMinute error records from the reload path are passed through per minute.
+type LogDedupHandler struct {
+	next        slog.Handler
+	attrsPrefix string // fingerprint of the attributes and groups added via WithAttrs and WithGroup
+	isReload    bool   // the handler carries the reload source attribute
+	state       *logDedupState
+}
+
+// logDedupState is shared by a handler and all handlers derived from it.
+type logDedupState struct {
+	mu                    sync.Mutex
+	next                  slog.Handler // the wrapped handler without derived attributes, for reports of the state
+	window                time.Duration
+	reloadErrorsPerMinute int
+	pending               map[string]*logDedupEntry
+	reloadErrors          int
+	reloadDropped         int
+	reloadTimer           *time.Timer
+	closed                bool
+}
+
+// logDedupEntry tracks the repetitions of a record within the open window.
+type logDedupEntry struct {
+	next     slog.Handler
+	last     slog.Record
+	repeated int
+	timer    *time.Timer
+}
+
+// NewLogDedupHandler wraps the handler with deduplication of identical records within the window
+// and a per-minute cap on reload error records (0 disables the cap).
+func NewLogDedupHandler(next slog.Handler, window time.Duration, reloadErrorsPerMinute int) *LogDedupHandler {
+	return &LogDedupHandler{
+		next: next,
+		state: &logDedupState{
+			next:                  next,
+			window:                window,
+			reloadErrorsPerMinute: reloadErrorsPerMinute,
+			pending:               make(map[string]*logDedupEntry),
+		},
+	}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *LogDedupHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle passes the record to the wrapped handler unless it repeats a record of the open window
+// or exceeds the reload error cap.
+func (h *LogDedupHandler) Handle(ctx context.Context, r slog.Record) error {
+	key := h.fingerprint(r)
+	st := h.state
+
+	st.mu.Lock()
+	if st.closed {
+		st.mu.Unlock()
+		return h.next.Handle(ctx, r)
+	}
+	if entry, ok := st.pending[key]; ok {
+		entry.repeated++
+		entry.last = r.Clone()
+		st.mu.Unlock()
+		return nil
+	}
+	if r.Level >= slog.LevelError && h.isReloadRecord(r) && !st.allowReloadError() {
+		st.mu.Unlock()
+		return nil
+	}
+	entry := &logDedupEntry{next: h.next}
+	entry.timer = time.AfterFunc(st.window, func() { st.flushEntry(key) })
+	st.pending[key] = entry
+	st.mu.Unlock()
+
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a handler sharing the deduplication state whose records also carry the attributes.
+func (h *LogDedupHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	derived := *h
+	derived.next = h.next.WithAttrs(attrs)
+	var sb strings.Builder
+	sb.WriteString(h.attrsPrefix)
+	for _, attr := range attrs {
+		writeAttrFingerprint(&sb, attr)
+		if attr.Key == logSourceKey && attr.Value.String() == logSourceReload {
+			derived.isReload = true
+		}
+	}
+	derived.attrsPrefix = sb.String()
+	return &derived
+}
+
+// WithGroup returns a handler sharing the deduplication state whose attributes are nested in the group.
+func (h *LogDedupHandler) WithGroup(name string) slog.Handler {
+	derived := *h
+	derived.next = h.next.WithGroup(name)
+	derived.attrsPrefix = h.attrsPrefix + "\x00group:" + name
+	return &derived
+}
+
+// Close emits the summaries of all open windows and passes further records through unchanged.
+func (h *LogDedupHandler) Close() {
+	st := h.state
+	st.mu.Lock()
+	st.closed = true
+	keys := make([]string, 0, len(st.pending))
+	for key, entry := range st.pending {
+		entry.timer.Stop()
+		keys = append(keys, key)
+	}
+	if st.reloadTimer != nil {
+		st.reloadTimer.Stop()
+	}
+	st.mu.Unlock()
+
+	for _, key := range keys {
+		st.flushEntry(key)
+	}
+	st.flushReloadErrors()
+}
+
+func (h *LogDedupHandler) fingerprint(r slog.Record) string {
+	var sb strings.Builder
+	sb.WriteString(r.Level.String())
+	sb.WriteString("\x00")
+	sb.WriteString(r.Message)
+	sb.WriteString(h.attrsPrefix)
+	r.Attrs(func(attr slog.Attr) bool {
+		writeAttrFingerprint(&sb, attr)
+		return true
+	})
+	return sb.String()
+}
+
+func writeAttrFingerprint(sb *strings.Builder, attr slog.Attr) {
+	sb.WriteString("\x00")
+	sb.WriteString(attr.Key)
+	sb.WriteString("=")
+	sb.WriteString(attr.Value.Resolve().String())
+}
+
+func (h *LogDedupHandler) isReloadRecord(r slog.Record) bool {
+	isReload := h.isReload
+	r.Attrs(func(attr slog.Attr) bool {
+		if attr.Key == logSourceKey && attr.Value.String() == logSourceReload {
+			isReload = true
+			return false
+		}
+		return true
+	})
+	return isReload
+}
+
+// allowReloadError counts a reload error record against the cap of the current minute.
+// It must be called with the state locked.
+func (st *logDedupState) allowReloadError() bool {
+	if st.reloadErrorsPerMinute <= 0 {
+		return true
+	}
+	if st.reloadTimer == nil {
+		st.reloadTimer = time.AfterFunc(time.Minute, st.flushReloadErrors)
+	}
+	st.reloadErrors++
+	if st.reloadErrors <= st.reloadErrorsPerMinute {
+		return true
+	}
+	st.reloadDropped++
+	return false
+}
+
+// flushEntry closes the window of the record and emits its summary if any repetitions were suppressed.
+func (st *logDedupState) flushEntry(key string) {
+	st.mu.Lock()
+	entry, ok := st.pending[key]
+	delete(st.pending, key)
+	st.mu.Unlock()
+	if !ok || entry.repeated == 0 {
+		return
+	}
+	summary := entry.last.Clone()
+	summary.AddAttrs(slog.Int(repeatedAttrKey, entry.repeated))
+	_ = entry.next.Handle(context.Background(), summary)
+}
+
+// flushReloadErrors starts a new minute of the reload error cap and reports the records dropped in the last one.
+func (st *logDedupState) flushReloadErrors() {
+	st.mu.Lock()
+	dropped := st.reloadDropped
+	st.reloadErrors, st.reloadDropped, st.reloadTimer = 0, 0, nil
+	st.mu.Unlock()
+	if dropped == 0 {
+		return
+	}
+	summary := slog.NewRecord(time.Now(), slog.LevelWarn, "Reload errors suppressed by rate limit", 0)
+	summary.AddAttrs(slog.Int("dropped", dropped))
+	_ = st.next.Handle(context.Background(), summary)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type LogDedupTestSuite struct {
+	suite.Suite
+}
+
+func TestLogDedupTestSuite(t *testing.T) {
+	suite.Run(t, new(LogDedupTestSuite))
+}
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of window timers.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.Split(strings.TrimSuffix(b.buf.String(), "\n"), "\n")
+}
+
+func newTestLogDedupHandler(window time.Duration, reloadErrorsPerMinute int) (*LogDedupHandler, *syncBuffer) {
+	out := &syncBuffer{}
+	textHandler := slog.NewTextHandler(out, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	return NewLogDedupHandler(textHandler, window, reloadErrorsPerMinute), out
+}
+
+// TestDistinctRecordsPassThrough tests that records differing in level, message, or attributes are not collapsed
+func (s *LogDedupTestSuite) TestDistinctRecordsPassThrough() {
+	handler, out := newTestLogDedupHandler(time.Hour, 0)
+	logger := slog.New(handler)
+
+	logger.Info("Prompt template file changed", "file", "a.tmpl")
+	logger.Info("Prompt template file changed", "file", "b.tmpl")
+	logger.Warn("Prompt template file changed", "file", "a.tmpl")
+	logger.Info("Prompts registered", "count", 2)
+	logger.With("request", 1).Info("Prompts registered", "count", 2)
+	handler.Close()
+
+	assert.Equal(s.T(), []string{
+		`level=INFO msg="Prompt template file changed" file=a.tmpl`,
+		`level=INFO msg="Prompt template file changed" file=b.tmpl`,
+		`level=WARN msg="Prompt template file changed" file=a.tmpl`,
+		`level=INFO msg="Prompts registered" count=2`,
+		`level=INFO msg="Prompts registered" request=1 count=2`,
+	}, out.Lines())
+}
+
+// TestRepeatedRecordsCollapsed tests that identical records are collapsed and counted when the window closes
+func (s *LogDedupTestSuite) TestRepeatedRecordsCollapsed() {
+	handler, out := newTestLogDedupHandler(200*time.Millisecond, 0)
+	logger := slog.New(handler)
+
+	for i := 0; i < 5; i++ {
+		logger.Error("Failed to reload prompts", "error", errors.New("parse all prompts: bad.tmpl:1: unexpected EOF"))
+	}
+	logger.Info("Prompts registered", "count", 1)
+	require.Equal(s.T(), []string{
+		`level=ERROR msg="Failed to reload prompts" error="parse all prompts: bad.tmpl:1: unexpected EOF"`,
+		`level=INFO msg="Prompts registered" count=1`,
+	}, out.Lines())
+
+	require.Eventually(s.T(), func() bool { return len(out.Lines()) == 3 }, time.Second, 10*time.Millisecond)
+	assert.Equal(s.T(),
+		`level=ERROR msg="Failed to reload prompts" error="parse all prompts: bad.tmpl:1: unexpected EOF" repeated=4`,
+		out.Lines()[2])
+
+	// A new window starts after the previous one has closed.
+	logger.Error("Failed to reload prompts", "error", errors.New("parse all prompts: bad.tmpl:1: unexpected EOF"))
+	require.Len(s.T(), out.Lines(), 4)
+	assert.Equal(s.T(), out.Lines()[0], out.Lines()[3])
+	handler.Close()
+	assert.Len(s.T(), out.Lines(), 4, "a window without repetitions must not emit a summary")
+}
+
+// TestCloseFlushesOpenWindows tests that closing the handler emits the summaries of windows still open
+func (s *LogDedupTestSuite) TestCloseFlushesOpenWindows() {
+	handler, out := newTestLogDedupHandler(time.Hour, 0)
+	logger := slog.New(handler).WithGroup("watcher")
+
+	logger.Error("File watcher error", "error", "overflow")
+	logger.Error("File watcher error", "error", "overflow")
+	logger.Error("File watcher error", "error", "overflow")
+	require.Len(s.T(), out.Lines(), 1)
+
+	handler.Close()
+	assert.Equal(s.T(), []string{
+		`level=ERROR msg="File watcher error" watcher.error=overflow`,
+		`level=ERROR msg="File watcher error" watcher.error=overflow watcher.repeated=2`,
+	}, out.Lines())
+
+	logger.Error("File watcher error", "error", "overflow")
+	assert.Len(s.T(), out.Lines(), 3, "records after Close must pass through")
+}
+
+// TestReloadErrorsCapped tests the per-minute cap on error records from the reload path
+func (s *LogDedupTestSuite) TestReloadErrorsCapped() {
+	handler, out := newTestLogDedupHandler(time.Hour, 2)
+	logger := slog.New(handler)
+
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		logger.Error("Failed to reload prompts", "error", name+".tmpl: unexpected EOF", logSourceKey, logSourceReload)
+	}
+	logger.With(logSourceKey, logSourceReload).Error("Failed to reload prompts", "error", "f.tmpl: unexpected EOF")
+	logger.Warn("Failed to reload prompts", "error", "g.tmpl: unexpected EOF", logSourceKey, logSourceReload)
+	logger.Error("File watcher error", "error", "overflow")
+	require.Equal(s.T(), []string{
+		`level=ERROR msg="Failed to reload prompts" error="a.tmpl: unexpected EOF" source=reload`,
+		`level=ERROR msg="Failed to reload prompts" error="b.tmpl: unexpected EOF" source=reload`,
+		`level=WARN msg="Failed to reload prompts" error="g.tmpl: unexpected EOF" source=reload`,
+		`level=ERROR msg="File watcher error" error=overflow`,
+	}, out.Lines())
+
+	handler.Close()
+	assert.Equal(s.T(), `level=WARN msg="Reload errors suppressed by rate limit" dropped=4`, out.Lines()[4])
+}
+
+// TestEnabled tests that the handler follows the level of the wrapped handler
+func (s *LogDedupTestSuite) TestEnabled() {
+	textHandler := slog.NewTextHandler(&bytes.Buffer{}, &slog.HandlerOptions{Level: slog.LevelWarn})
+	handler := NewLogDedupHandler(textHandler, time.Second, 0)
+	assert.False(s.T(), handler.Enabled(context.Background(), slog.LevelInfo))
+	assert.True(s.T(), handler.Enabled(context.Background(), slog.LevelError))
+}
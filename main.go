@@ -3,19 +3,27 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"log/slog"
+	"maps"
+	"net"
 	"os"
+	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"slices"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"text/template"
 	"time"
+	"unicode/utf8"
 
 	"github.com/urfave/cli/v3"
 )
@@ -29,7 +37,15 @@ var (
 const templateExt = ".tmpl"
 
 func main() {
-	cmd := &cli.Command{
+	if err := newApp().Run(context.Background(), os.Args); err != nil {
+		printErrorLines(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// newApp builds the command-line application
+func newApp() *cli.Command {
+	return &cli.Command{
 		Name:    "mcp-prompt-engine",
 		Usage:   "A Model Control Protocol server for dynamic prompt templates",
 		Version: fmt.Sprintf("%s (commit: %s, go: %s)", version, commit, goVersion),
@@ -41,6 +57,42 @@ func main() {
 				Usage:   "Directory containing prompt template files",
 				Sources: cli.EnvVars("MCP_PROMPTS_DIR"),
 			},
+			&cli.StringSliceFlag{
+				Name:  "partials-dir",
+				Usage: "Shared directory of partial templates (_*.tmpl) searched after the prompts directory (repeatable)",
+			},
+			&cli.StringFlag{
+				Name: "partials-path",
+				Usage: "Search path of shared partials directories, separated like PATH and searched in order " +
+					"after the --partials-dir directories",
+				Sources: cli.EnvVars("MCP_PARTIALS_PATH"),
+			},
+			&cli.StringFlag{
+				Name:  "line-endings",
+				Value: string(LineEndingsKeep),
+				Usage: "Line endings of rendered prompts: lf, crlf, or keep (as written in the templates)",
+				Action: func(ctx context.Context, cmd *cli.Command, value string) error {
+					_, err := ParseLineEndings(value)
+					return err
+				},
+			},
+			&cli.StringFlag{
+				Name:  "name-style",
+				Value: string(NameStyleAsIs),
+				Usage: "How prompt names are derived from template file names: as-is, kebab (git-commit), or snake (git_commit)",
+				Action: func(ctx context.Context, cmd *cli.Command, value string) error {
+					_, err := ParseNameStyle(value)
+					return err
+				},
+			},
+			&cli.StringFlag{
+				Name:  "builtin-date-name",
+				Value: defaultBuiltinDateName,
+				Usage: "Field of the built-in date, e.g. now_date to free \"date\" for a prompt argument",
+				Action: func(ctx context.Context, cmd *cli.Command, value string) error {
+					return validateBuiltinDateName(value)
+				},
+			},
 			&cli.StringFlag{
 				Name:    "color",
 				Value:   "auto",
@@ -49,43 +101,138 @@ func main() {
 				Action: func(ctx context.Context, cmd *cli.Command, value string) error {
 					colorMode := ColorMode(value)
 					if colorMode != colorModeAuto && colorMode != colorModeAlways && colorMode != colorModeNever {
-						return fmt.Errorf("invalid color value %q, must be one of: "+colorModesCommaSeparatedList, value)
+						return errors.New(localize("cli.invalid_color", value, colorModesCommaSeparatedList))
 					}
 					return nil
 				},
 			},
+			&cli.StringFlag{
+				Name:    "output-style",
+				Value:   string(outputStyleDefault),
+				Usage:   "Status marks of the output: " + outputStylesCommaSeparatedList + " (plain: words instead of icons, without colors)",
+				Sources: cli.EnvVars("MCP_OUTPUT_STYLE"),
+				Action: func(ctx context.Context, cmd *cli.Command, value string) error {
+					_, err := ParseOutputStyle(value)
+					return err
+				},
+			},
+			&cli.StringFlag{
+				Name:  "lang",
+				Usage: "Language of CLI messages, e.g. en or de (defaults to the LC_ALL, LC_MESSAGES, or LANG locale)",
+			},
 		},
 		Commands: []*cli.Command{
 			{
-				Name:   "serve",
-				Usage:  "Start the MCP server",
-				Action: serveCommand,
-				Flags: []cli.Flag{
+				Name:      "serve",
+				Usage:     "Start the MCP server",
+				ArgsUsage: "[prompts_dir]",
+				Action:    serveCommand,
+				Flags:     serveFlags(),
+			},
+			{
+				Name:      "selftest",
+				Usage:     "Serve the prompts in-process and check the MCP round trip with a built-in client",
+				ArgsUsage: "[prompts_dir]",
+				Action:    selfTestCommand,
+				Flags: append(serveFlags(),
 					&cli.StringFlag{
-						Name:  "log-file",
-						Usage: "Path to log file (if not specified, logs to stdout)",
+						Name:  "prompt",
+						Usage: "Only request this prompt in the GetPrompt phase",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print the report as JSON",
+					},
+				),
+			},
+			{
+				Name:      "render",
+				Usage:     "Render a template to stdout",
+				ArgsUsage: "[prompts_dir] <template_name>",
+				Action:    renderCommand,
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:    "arg",
+						Aliases: []string{"a"},
+						Usage:   "Template argument in name=value format (repeatable)",
+					},
+					&cli.StringSliceFlag{
+						Name: "arg-file",
+						Usage: "Template argument in name=path format whose value is the content of the file, " +
+							"never parsed as JSON; - reads stdin (repeatable)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "arg-file-json",
+						Usage: "Template argument in name=path format whose value is the JSON content of the file (repeatable)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "var",
+						Usage: "Deployment variable in name=value format, available as {{.vars.name}} like in serve (repeatable)",
+					},
+					&cli.IntFlag{
+						Name:  flagMaxArgsSize,
+						Value: DefaultArgsLimits().MaxTotalSize,
+						Usage: "Maximum combined size in bytes of all arguments, files included (0 disables the limit)",
 					},
 					&cli.BoolFlag{
 						Name:  "disable-json-args",
 						Usage: "Disable JSON parsing for arguments (use string-only mode)",
 					},
+					&cli.StringFlag{
+						Name:  "post-processor",
+						Usage: "External command the rendered output is piped through (stdin to stdout) before writing",
+					},
+					&cli.DurationFlag{
+						Name:  "post-processor-timeout",
+						Value: 10 * time.Second,
+						Usage: "Maximum duration of the post-processor command (0 disables the limit)",
+					},
+					&cli.BoolFlag{
+						Name:  "check-schema",
+						Usage: "Verify that every property of the template's " + schemaFileExt + " sidecar appears in the output",
+					},
+					&cli.BoolFlag{
+						Name:  "show-whitespace",
+						Usage: "Print the output with visible markers for spaces, tabs, and line ends, followed by a summary",
+					},
+					&cli.BoolFlag{
+						Name:    "measure",
+						Aliases: []string{"stats"},
+						Usage:   "Print character, byte, line, and estimated token counts of the output to stderr",
+					},
 					&cli.BoolFlag{
-						Name:  "quiet",
-						Usage: "Suppress non-essential output",
+						Name:  "dump-data",
+						Usage: "Print the data the template receives as JSON to stderr, with sensitive arguments redacted",
+					},
+					&cli.StringFlag{
+						Name:  "sensitive-pattern",
+						Usage: "Regular expression of argument names redacted by --dump-data, besides those marked sensitive",
+					},
+					&cli.BoolFlag{
+						Name: "mcp",
+						Usage: "Print the JSON-RPC response of a prompts/get request instead, exactly as the server " +
+							"returns it to clients",
 					},
 				},
 			},
 			{
-				Name:      "render",
-				Usage:     "Render a template to stdout",
-				ArgsUsage: "<template_name>",
-				Action:    renderCommand,
+				Name:      "bench",
+				Usage:     "Render a template repeatedly and report latency and allocations",
+				ArgsUsage: "[prompts_dir] <template_name>",
+				Action:    benchCommand,
+				Hidden:    true,
 				Flags: []cli.Flag{
 					&cli.StringSliceFlag{
 						Name:    "arg",
 						Aliases: []string{"a"},
 						Usage:   "Template argument in name=value format (repeatable)",
 					},
+					&cli.IntFlag{
+						Name:    "iterations",
+						Aliases: []string{"n"},
+						Value:   defaultBenchIterations,
+						Usage:   "Number of renders to measure",
+					},
 					&cli.BoolFlag{
 						Name:  "disable-json-args",
 						Usage: "Disable JSON parsing for arguments (use string-only mode)",
@@ -93,21 +240,327 @@ func main() {
 				},
 			},
 			{
-				Name:   "list",
-				Usage:  "List available templates",
-				Action: listCommand,
+				Name:      "list",
+				Usage:     "List available templates",
+				ArgsUsage: "[prompts_dir]",
+				Action:    listCommand,
 				Flags: []cli.Flag{
 					&cli.BoolFlag{
 						Name:  "verbose",
 						Usage: "Show detailed information about templates",
 					},
+					&cli.BoolFlag{
+						Name:  "by-collection",
+						Usage: "Group templates by the collections defined in " + collectionsFileName,
+					},
+					&cli.StringFlag{
+						Name:  "sort",
+						Value: listSortName,
+						Usage: "Sort templates by: " + listSortName + ", " + listSortModified +
+							" (least recently modified first), " + listSortArgs + " (most arguments first)",
+					},
+					&cli.BoolFlag{
+						Name:  "modified",
+						Usage: "Show when each template was last modified and how many partials it references",
+					},
+					&cli.BoolFlag{
+						Name:  "arg-origins",
+						Usage: "With --verbose, show after each variable the template or partials that reference it",
+					},
+					&cli.BoolFlag{
+						Name:  "git-tracked-only",
+						Usage: "Only include templates tracked by git when the prompts directory is inside a git worktree",
+					},
+					&cli.StringFlag{
+						Name:  "flags-file",
+						Usage: "With --verbose, show the values of the feature flags of every template from this file",
+					},
+				},
+			},
+			{
+				Name:      "flags",
+				Usage:     "Show the feature flags used by the templates, their values, and the templates using them",
+				ArgsUsage: "[prompts_dir]",
+				Action:    flagsCommand,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "flags-file",
+						Usage: "YAML file of the feature flag values (name: true/false); flags missing from it are off",
+					},
+				},
+			},
+			{
+				Name:      "graph",
+				Usage:     "Show the partials every prompt depends on, directly or through other partials",
+				ArgsUsage: "[prompts_dir]",
+				Action:    graphCommand,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "dot",
+						Usage: "Print the graph in Graphviz DOT format, with an edge for every direct partial reference",
+					},
 				},
 			},
 			{
 				Name:      "validate",
 				Usage:     "Validate template syntax",
-				ArgsUsage: "[template_name]",
+				ArgsUsage: "[prompts_dir] [template_name]",
 				Action:    validateCommand,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "whitespace",
+						Usage: "Also report templates containing trailing whitespace or Windows line endings",
+					},
+					&cli.BoolFlag{
+						Name:  "fail-fast",
+						Usage: "Stop at the first template with errors",
+					},
+					&cli.IntFlag{
+						Name:  "jobs",
+						Usage: "Number of templates checked concurrently (default: GOMAXPROCS)",
+					},
+					&cli.BoolFlag{
+						Name:  "strict-partials",
+						Usage: "Also report partials of the prompts directory that no prompt references",
+					},
+					&cli.StringFlag{
+						Name:  "flags-file",
+						Usage: "Also warn about feature flags used by templates but missing from this file, and defined but unused",
+					},
+					&cli.BoolFlag{
+						Name:  "check-contracts",
+						Usage: "Also render the templates declaring @assert directives and check the assertions, like the check command",
+					},
+					&cli.BoolFlag{
+						Name: "consistency",
+						Usage: "Check instead that arguments meaning the same are named the same across all templates, " +
+							"with synonyms and exceptions configured in " + consistencyFileName,
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Value: consistencyFormatText,
+						Usage: "Format of the --consistency report: " + consistencyFormatText + " or " + consistencyFormatJSON,
+					},
+					&cli.BoolFlag{
+						Name:  "git-tracked-only",
+						Usage: "Only include templates tracked by git when the prompts directory is inside a git worktree",
+					},
+				},
+			},
+			{
+				Name:      "format",
+				Usage:     "Rewrite templates in the canonical style without changing what they render",
+				ArgsUsage: "[prompts_dir] [template_name]",
+				Action:    formatCommand,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "write",
+						Aliases: []string{"w"},
+						Usage:   "Write the formatted templates back to their files instead of printing them",
+					},
+				},
+			},
+			{
+				Name:      "test",
+				Usage:     "Render a template with fixture arguments and compare the output to the expected files",
+				ArgsUsage: "[prompts_dir] <template_name>",
+				Action:    testCommand,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "fixtures",
+						Usage: "Directory with <case>.args.json and <case>.expected.txt files (default: <prompts_dir>/fixtures/<template_name>)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "var",
+						Usage: "Deployment variable in name=value format, available as {{.vars.name}} like in serve (repeatable)",
+					},
+					&cli.BoolFlag{
+						Name:  "disable-json-args",
+						Usage: "Disable JSON parsing for arguments (use string-only mode)",
+					},
+				},
+			},
+			{
+				Name:      "check",
+				Usage:     "Render the templates declaring @assert directives with their @example arguments and check the assertions",
+				ArgsUsage: "[prompts_dir] [template_name]",
+				Action:    checkCommand,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "disable-json-args",
+						Usage: "Disable JSON parsing for arguments (use string-only mode)",
+					},
+				},
+			},
+			{
+				Name:      "migrate",
+				Usage:     "Convert prompt files of another prompt manager into templates",
+				ArgsUsage: "<src_dir>",
+				Action:    migrateCommand,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "from",
+						Usage:    "Format of the source files: " + migrateFromMustache + " ({{variable}} placeholders) or " + migrateFromFrontmatter + " (the same with a YAML front matter description)",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "into",
+						Usage: "Directory the templates are written to (default: the prompts directory)",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Overwrite existing templates",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print the converted templates instead of writing them",
+					},
+				},
+			},
+			{
+				Name:      "verify-access",
+				Usage:     "Check whether an access log entry matches the given arguments and the current templates",
+				ArgsUsage: "[prompts_dir] <log_line>",
+				Action:    verifyAccessCommand,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "args-file",
+						Usage:    "JSON object with the prompt arguments (string values are passed as is)",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "fingerprint-key-file",
+						Usage:    "File with the secret key the server used for argument fingerprints",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "git-ref",
+						Usage: "Render the templates as of this git revision instead of the working tree",
+					},
+					&cli.BoolFlag{
+						Name:  "disable-json-args",
+						Usage: "Disable JSON parsing for arguments (use string-only mode)",
+					},
+					&cli.StringFlag{
+						Name:  "sensitive-pattern",
+						Usage: "The --sensitive-pattern of the server, whose matching arguments are left out of fingerprints",
+					},
+				},
+			},
+			{
+				Name:  "docs",
+				Usage: "Generate documentation of the prompts",
+				Commands: []*cli.Command{
+					{
+						Name:      "generate",
+						Usage:     "Generate a document with the description, arguments, partials, and example of every prompt",
+						ArgsUsage: "[prompts_dir]",
+						Action:    docsGenerateCommand,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "output",
+								Aliases: []string{"o"},
+								Usage:   "File to write the document to (if not specified, prints to stdout)",
+							},
+							&cli.StringFlag{
+								Name:  "format",
+								Usage: "Format of the document: markdown or html",
+								Value: docsFormatMarkdown,
+							},
+							&cli.BoolFlag{
+								Name:  "check",
+								Usage: "Do not write the output file, but fail if it differs from the generated document",
+							},
+							&cli.BoolFlag{
+								Name:  "stamp",
+								Usage: "Add the generation time and the version to the document",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:  "cache",
+				Usage: "Inspect or empty the render cache of the serve command",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "render-cache-dir",
+						Usage:    "Directory of the render cache",
+						Required: true,
+					},
+				},
+				Commands: []*cli.Command{
+					{
+						Name:   "stats",
+						Usage:  "Show the number and size of cached prompts and the hit and miss counts",
+						Action: cacheStatsCommand,
+					},
+					{
+						Name:   "clear",
+						Usage:  "Remove all cached prompts and reset the hit and miss counts",
+						Action: cacheClearCommand,
+					},
+				},
+			},
+			{
+				Name:   "reload",
+				Usage:  "Make a server started with --control-socket reload its prompts",
+				Action: reloadCommand,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "control-socket",
+						Usage:    "Control socket of the running server",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "config",
+						Usage: "Reload the --config file of the server instead of its prompts",
+					},
+				},
+			},
+			{
+				Name:   "status",
+				Usage:  "Show the number of prompts and the config version of a server started with --control-socket",
+				Action: statusCommand,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "control-socket",
+						Usage:    "Control socket of the running server",
+						Required: true,
+					},
+				},
+			},
+			{
+				Name:  "override",
+				Usage: "Serve a template source instead of the file of a prompt of a server started with --enable-overrides",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "control-socket",
+						Usage:    "Control socket of the running server",
+						Required: true,
+					},
+				},
+				Commands: []*cli.Command{
+					{
+						Name:      "set",
+						Usage:     "Serve the template file for the prompt until the TTL expires or its own file changes",
+						ArgsUsage: "<prompt> <template_file>",
+						Action:    overrideSetCommand,
+						Flags: []cli.Flag{
+							&cli.DurationFlag{
+								Name:  "ttl",
+								Value: 10 * time.Minute,
+								Usage: "How long the override is served, at most 24h",
+							},
+						},
+					},
+					{
+						Name:      "delete",
+						Usage:     "Serve the prompt from its own file again",
+						ArgsUsage: "<prompt>",
+						Action:    overrideDeleteCommand,
+					},
+				},
 			},
 			{
 				Name:   "version",
@@ -117,121 +570,1252 @@ func main() {
 		},
 		Before: func(ctx context.Context, cmd *cli.Command) (context.Context, error) {
 			colorMode := ColorMode(cmd.String("color"))
-			initializeColors(colorMode)
-
-			// Skip validation for version command
-			if cmd.Name == "version" {
-				return ctx, nil
+			style, err := ParseOutputStyle(cmd.String("output-style"))
+			if err != nil {
+				return ctx, err
 			}
-			// Validate prompts directory exists
-			promptsDir := cmd.String("prompts")
-			if _, err := os.Stat(promptsDir); os.IsNotExist(err) {
-				return ctx, fmt.Errorf("prompts directory '%s' does not exist", promptsDir)
+			initializeColors(colorMode, style)
+
+			locale := cmd.String("lang")
+			if locale == "" {
+				locale = localeFromEnv()
 			}
+			setLanguage(locale)
+
 			return ctx, nil
 		},
 	}
-
-	if err := cmd.Run(context.Background(), os.Args); err != nil {
-		log.Fatal(err)
-	}
 }
 
-// serveCommand starts the MCP server
-func serveCommand(ctx context.Context, cmd *cli.Command) error {
-	promptsDir := cmd.String("prompts")
-	logFile := cmd.String("log-file")
-	enableJSONArgs := !cmd.Bool("disable-json-args")
-	quiet := cmd.Bool("quiet")
-
-	if err := runStdioMCPServer(os.Stdout, promptsDir, logFile, enableJSONArgs, quiet); err != nil {
-		return fmt.Errorf("%s: %w", errorText("failed to start MCP server"), err)
+// partialsSearchPath returns the shared partials directories in search order:
+// the --partials-dir directories, then those of --partials-path.
+func partialsSearchPath(cmd *cli.Command) []string {
+	dirs := slices.Clone(cmd.StringSlice("partials-dir"))
+	for _, dir := range filepath.SplitList(cmd.String("partials-path")) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
 	}
-	return nil
+	return dirs
 }
 
-// renderCommand renders a template to stdout
-func renderCommand(ctx context.Context, cmd *cli.Command) error {
-	if cmd.Args().Len() < 1 {
-		return fmt.Errorf("template name is required\n\nUsage: %s render <template_name>", cmd.Root().Name)
-	}
-
+// resolvePromptsDir determines the prompts directory for a subcommand and returns the remaining positional arguments.
+// The directory may be given as the first positional argument: it is recognized when more arguments than the command
+// accepts are passed, or when the command's optional argument is filled by an existing directory.
+// The --prompts flag (or its environment variable) takes precedence over the positional directory.
+func resolvePromptsDir(cmd *cli.Command, requiredArgs int, optionalArgs int) (string, []string, error) {
 	promptsDir := cmd.String("prompts")
-	templateName := cmd.Args().First()
-	args := cmd.StringSlice("arg")
-	enableJSONArgs := !cmd.Bool("disable-json-args")
+	args := cmd.Args().Slice()
+	maxOtherArgs := requiredArgs + optionalArgs
 
-	// Parse args into a map
-	argMap := make(map[string]string)
-	for _, arg := range args {
-		parts := strings.SplitN(arg, "=", 2)
-		if len(parts) != 2 {
-			return fmt.Errorf("invalid argument format '%s', expected name=value", arg)
+	hasPositionalDir := len(args) > maxOtherArgs
+	if !hasPositionalDir && optionalArgs > 0 && len(args) > requiredArgs {
+		if info, err := os.Stat(args[0]); err == nil && info.IsDir() {
+			hasPositionalDir = true
 		}
-		argMap[parts[0]] = parts[1]
+	}
+	if hasPositionalDir {
+		if !cmd.IsSet("prompts") {
+			promptsDir = args[0]
+		}
+		args = args[1:]
+	}
+	if len(args) > maxOtherArgs {
+		return "", nil, errors.New(localize("cli.too_many_args", strings.Join(args, " ")))
 	}
 
-	if err := renderTemplate(os.Stdout, promptsDir, templateName, argMap, enableJSONArgs); err != nil {
-		return fmt.Errorf("%s '%s': %w", errorText("failed to render template"), templateText(templateName), err)
+	if _, err := os.Stat(promptsDir); os.IsNotExist(err) {
+		return "", nil, errors.New(localize("cli.prompts_dir_not_found", promptsDir))
 	}
-	return nil
+	return promptsDir, args, nil
 }
 
-// listCommand lists available templates
-func listCommand(ctx context.Context, cmd *cli.Command) error {
-	promptsDir := cmd.String("prompts")
-	verbose := cmd.Bool("verbose")
-
-	if err := listTemplates(os.Stdout, promptsDir, verbose); err != nil {
-		return fmt.Errorf("failed to list templates: %w", err)
+// serveFlags returns the flags of the serve command, which selftest accepts too.
+func serveFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "log-file",
+			Usage: "Path to log file (if not specified, logs to stdout)",
+		},
+		&cli.BoolFlag{
+			Name:  "disable-json-args",
+			Usage: "Disable JSON parsing for arguments (use string-only mode)",
+		},
+		&cli.BoolFlag{
+			Name:  "quiet",
+			Usage: "Suppress non-essential output",
+		},
+		&cli.BoolFlag{
+			Name:  "no-recovery",
+			Usage: "Let a panicking template function stop the server with a full stack trace (for debugging)",
+		},
+		&cli.BoolFlag{
+			Name:  "no-log-dedup",
+			Usage: "Log every record, without collapsing repeated records or capping reload errors",
+		},
+		&cli.BoolFlag{
+			Name:  "debug",
+			Usage: "Log debug records too, e.g. the number of file watches and the rate of file system notifications",
+		},
+		&cli.DurationFlag{
+			Name:  "log-dedup-window",
+			Value: defaultLogDedupWindow,
+			Usage: "Window in which identical log records are collapsed into one record with a repeat count",
+		},
+		&cli.DurationFlag{
+			Name:  "stdin-timeout",
+			Usage: "Exit with an error if no client sends a message on stdin within this duration (0 waits forever)",
+		},
+		&cli.StringFlag{
+			Name:  "access-log",
+			Usage: "Path to a file receiving one JSON line per prompt request, with hashes instead of argument values",
+		},
+		&cli.StringFlag{
+			Name:  "fingerprint-key-file",
+			Usage: "File with the secret key of the argument fingerprints in the access log (required with --access-log)",
+		},
+		&cli.StringFlag{
+			Name:  "watch-mode",
+			Value: string(WatchModeAuto),
+			Usage: "How to detect changes of prompt files: auto (notifications, falling back to polling), fsnotify, poll, or off",
+			Action: func(ctx context.Context, cmd *cli.Command, value string) error {
+				_, err := ParseWatchMode(value)
+				return err
+			},
+		},
+		&cli.DurationFlag{
+			Name:  "watch-poll-interval",
+			Value: defaultWatchPollInterval,
+			Usage: "How often to scan prompt directories for changes when polling",
+		},
+		&cli.StringSliceFlag{
+			Name:  "watch-extensions",
+			Usage: "Also reload when files with these extensions (e.g. txt,md) change in the watched directories",
+			Action: func(ctx context.Context, cmd *cli.Command, value []string) error {
+				_, err := ParseWatchExtensions(value)
+				return err
+			},
+		},
+		&cli.IntFlag{
+			Name:  flagMaxArgKeyLength,
+			Value: DefaultArgsLimits().MaxKeyLength,
+			Usage: "Maximum length of a prompt argument name (0 disables the limit)",
+		},
+		&cli.IntFlag{
+			Name:  flagMaxArgsSize,
+			Value: DefaultArgsLimits().MaxTotalSize,
+			Usage: "Maximum combined size in bytes of all prompt arguments (0 disables the limit)",
+		},
+		&cli.IntFlag{
+			Name:  flagMaxJSONDepth,
+			Value: DefaultArgsLimits().MaxJSONDepth,
+			Usage: "Maximum nesting depth of JSON argument values (0 disables the limit)",
+		},
+		&cli.BoolFlag{
+			Name:  "disable-env-args",
+			Usage: "Do not fill prompt arguments from environment variables",
+		},
+		&cli.IntFlag{
+			Name:  flagMaxOutputSize,
+			Usage: "Maximum size in bytes of a rendered prompt (0 disables the limit)",
+		},
+		&cli.IntFlag{
+			Name: flagMaxRenderCost,
+			Usage: "Maximum estimated iterations of nested ranges over the arguments of a prompt, " +
+				"checked before rendering (0 disables the limit)",
+		},
+		&cli.DurationFlag{
+			Name:  flagRenderTimeout,
+			Usage: "Maximum duration of a single prompt render (0 disables the limit)",
+		},
+		&cli.BoolFlag{
+			Name:  "provenance",
+			Usage: "Attach the source template file and its SHA-256 hash to rendered prompts",
+		},
+		&cli.BoolFlag{
+			Name:  "expose-args",
+			Usage: "Attach the arguments received from the client to rendered prompts (environment variable values are never included)",
+		},
+		&cli.BoolFlag{
+			Name:  "errors-as-content",
+			Usage: "Return prompts that fail to render as their error message marked with isError in _meta, instead of a protocol error",
+		},
+		&cli.IntFlag{
+			Name:  "session-history",
+			Usage: "Number of prompts rendered earlier in the session available to templates as .session_history (0 disables it)",
+		},
+		&cli.BoolFlag{
+			Name:  "hide-deprecated",
+			Usage: "Leave prompts marked as deprecated out of prompt listings (they can still be requested by name)",
+		},
+		&cli.IntFlag{
+			Name:  "max-advertised-args",
+			Value: defaultMaxAdvertisedArgs,
+			Usage: "Maximum number of arguments advertised per prompt; prompts with more advertise the first ones " +
+				"in the --arg-order order but accept all of them (0 for unlimited)",
+		},
+		&cli.StringFlag{
+			Name:  "arg-order",
+			Value: string(ArgOrderAlphabetical),
+			Usage: "Order of the advertised arguments of each prompt: alphabetical, or template (first use in the template)",
+			Action: func(ctx context.Context, cmd *cli.Command, value string) error {
+				_, err := ParseArgOrder(value)
+				return err
+			},
+		},
+		&cli.StringFlag{
+			Name:  "icon-mode",
+			Value: string(IconModeTitle),
+			Usage: "Where the @icon of each prompt is shown, as MCP prompts have no icon field: title, description, or off",
+			Action: func(ctx context.Context, cmd *cli.Command, value string) error {
+				_, err := ParseIconMode(value)
+				return err
+			},
+		},
+		&cli.StringFlag{
+			Name:  "incompatible-prompts",
+			Value: string(IncompatiblePromptsHide),
+			Usage: "How prompt listings treat prompts whose min_protocol is newer than the protocol version of the client: " +
+				"hide, or mark (the required version is added to the description)",
+			Action: func(ctx context.Context, cmd *cli.Command, value string) error {
+				_, err := ParseIncompatiblePrompts(value)
+				return err
+			},
+		},
+		&cli.BoolFlag{
+			Name: "git-tracked-only",
+			Usage: "Only serve templates tracked by git when the prompts directory is inside a git worktree " +
+				"(changes of the git index trigger a reload)",
+		},
+		&cli.BoolFlag{
+			Name:  "require-env",
+			Usage: "Refuse to start if environment variables of arguments declared with env in the frontmatter are unset",
+		},
+		&cli.StringFlag{
+			Name:  "render-cache-dir",
+			Usage: "Directory of a render cache persisted across restarts (prompts using .date, the session history, sampling, or schemas are never cached)",
+		},
+		&cli.IntFlag{
+			Name:  "render-cache-max-size",
+			Value: defaultRenderCacheMaxSize,
+			Usage: "Maximum size in bytes of the render cache; least recently used entries are evicted above it",
+		},
+		&cli.StringSliceFlag{
+			Name:  "context",
+			Usage: "Value available to every prompt in key=value format, below the arguments of the client (repeatable)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "var",
+			Usage: "Deployment variable in name=value format, available to every prompt as {{.vars.name}} and never a prompt argument (repeatable)",
+		},
+		&cli.BoolFlag{
+			Name:  "strict-unknown-args",
+			Usage: "Reject prompt requests with arguments the template does not use instead of logging a warning",
+		},
+		&cli.StringFlag{
+			Name:  "default-model",
+			Usage: "Model that selects the model overrides and the modelIs helper for prompt requests without the _model argument",
+		},
+		&cli.BoolFlag{
+			Name:  "enable-overrides",
+			Usage: "Let the override command serve template sources instead of prompt files for a while, through --control-socket",
+		},
+		&cli.BoolFlag{
+			Name:  flagAllowSampling,
+			Usage: "Let the summarize template helper request sampling from clients that support it",
+		},
+		&cli.StringFlag{
+			Name: "prompts-url",
+			Usage: "URL of a JSON manifest listing prompt files and their SHA-256 hashes, or of a .tar, .tar.gz, .tgz, " +
+				"or .zip bundle of prompt files, to serve instead of a local directory (bearer token read from " +
+				promptsTokenEnvVar + ")",
+		},
+		&cli.DurationFlag{
+			Name:    "poll-interval",
+			Aliases: []string{"refresh-interval"},
+			Value:   time.Minute,
+			Usage:   "How often to re-fetch the --prompts-url manifest or bundle and reload changed prompts (0 disables polling)",
+		},
+		&cli.StringFlag{
+			Name:  "sensitive-pattern",
+			Usage: "Regular expression of argument names whose values are never logged or stored, in addition to those marked sensitive in the frontmatter",
+		},
+		&cli.StringFlag{
+			Name: "preview-addr",
+			Usage: "Address of a web preview for browsing and test-rendering the prompts, e.g. 127.0.0.1:8090 " +
+				"(a missing host means the loopback interface)",
+		},
+		&cli.StringFlag{
+			Name: "control-socket",
+			Usage: "Path of a Unix socket, usable by the current user only, through which the reload command " +
+				"makes the server reload its prompts",
+		},
+		&cli.StringFlag{
+			Name: "config",
+			Usage: "YAML file with settings that can change while the server runs (log level, argument and render limits), " +
+				"overriding their flags; reloaded on SIGHUP or with reload --config",
+		},
+		&cli.StringFlag{
+			Name:  "flags-file",
+			Usage: "YAML file of feature flags (name: true/false) returned by the flag helper of templates, e.g. {{if flag \"new_rubric\"}}",
+		},
+		&cli.StringFlag{
+			Name:  "events-file",
+			Usage: "Append change events of the server (start, reloads, shutdown) as JSON lines to the file",
+		},
+		&cli.StringFlag{
+			Name: "events-socket",
+			Usage: "Broadcast change events of the server as JSON lines to the clients of a Unix socket, " +
+				"usable by the current user only",
+		},
+		&cli.BoolFlag{
+			Name: "safe",
+			Usage: "Serve untrusted prompt directories with the strictest settings " +
+				"(no environment variable fallback, enforced limits, provenance metadata)",
+		},
+	}
+}
+
+// renderConfigFromFlags reads the serve flags that control how arguments are accepted and prompts are rendered,
+// with safe mode applied if requested. It returns the settings disabled by safe mode.
+func renderConfigFromFlags(cmd *cli.Command) (ArgsLimits, RenderSettings, []string, error) {
+	argsLimits := ArgsLimits{
+		MaxKeyLength: cmd.Int(flagMaxArgKeyLength),
+		MaxTotalSize: cmd.Int(flagMaxArgsSize),
+		MaxJSONDepth: cmd.Int(flagMaxJSONDepth),
+	}
+	renderSettings := RenderSettings{
+		DisableEnvArgs:  cmd.Bool("disable-env-args"),
+		MaxOutputSize:   cmd.Int(flagMaxOutputSize),
+		MaxRenderCost:   cmd.Int(flagMaxRenderCost),
+		Timeout:         cmd.Duration(flagRenderTimeout),
+		Provenance:      cmd.Bool("provenance"),
+		AllowSampling:   cmd.Bool(flagAllowSampling),
+		ExposeArgs:      cmd.Bool("expose-args"),
+		ErrorsAsContent: cmd.Bool("errors-as-content"),
+	}
+	var err error
+	if renderSettings.LineEndings, err = ParseLineEndings(cmd.Root().String("line-endings")); err != nil {
+		return ArgsLimits{}, RenderSettings{}, nil, err
+	}
+
+	var safeModeDisabled []string
+	if cmd.Bool("safe") {
+		if argsLimits, renderSettings, safeModeDisabled, err = applySafeMode(argsLimits, renderSettings, cmd.IsSet); err != nil {
+			return ArgsLimits{}, RenderSettings{}, nil,
+				fmt.Errorf("%s: %w", errorText(localize("serve.invalid_safe_mode")), err)
+		}
+	}
+	return argsLimits, renderSettings, safeModeDisabled, nil
+}
+
+// serveCommand starts the MCP server
+func serveCommand(ctx context.Context, cmd *cli.Command) error {
+	var promptsDir string
+	var err error
+	promptsURL := cmd.String("prompts-url")
+	if promptsURL == "" {
+		if promptsDir, _, err = resolvePromptsDir(cmd, 0, 0); err != nil {
+			return err
+		}
+	} else if cmd.Args().Len() > 0 {
+		return errors.New(localize("serve.dir_with_prompts_url"))
+	}
+	logFile := cmd.String("log-file")
+	enableJSONArgs := !cmd.Bool("disable-json-args")
+	quiet := cmd.Bool("quiet")
+	logDedupWindow := cmd.Duration("log-dedup-window")
+	if cmd.Bool("no-log-dedup") {
+		logDedupWindow = 0
+	}
+	argsLimits, renderSettings, safeModeDisabled, err := renderConfigFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	dateName := cmd.Root().String("builtin-date-name")
+	contextValues, err := parseContextValues(cmd.StringSlice("context"), dateName)
+	if err != nil {
+		return err
+	}
+	vars, err := parseVars(cmd.StringSlice("var"))
+	if err != nil {
+		return err
+	}
+	nameStyle, err := ParseNameStyle(cmd.Root().String("name-style"))
+	if err != nil {
+		return err
+	}
+	sensitivePattern, err := parseSensitivePattern(cmd.String("sensitive-pattern"))
+	if err != nil {
+		return err
+	}
+	incompatiblePrompts, err := ParseIncompatiblePrompts(cmd.String("incompatible-prompts"))
+	if err != nil {
+		return err
+	}
+	argOrder, err := ParseArgOrder(cmd.String("arg-order"))
+	if err != nil {
+		return err
+	}
+	iconMode, err := ParseIconMode(cmd.String("icon-mode"))
+	if err != nil {
+		return err
+	}
+
+	var renderCache *RenderCache
+	if renderCacheDir := cmd.String("render-cache-dir"); renderCacheDir != "" {
+		if renderCache, err = OpenRenderCache(renderCacheDir, int64(cmd.Int("render-cache-max-size"))); err != nil {
+			return fmt.Errorf("open render cache: %w", err)
+		}
+		defer func() { _ = renderCache.Close() }()
+	}
+
+	recovery := !cmd.Bool("no-recovery")
+	watchMode, err := ParseWatchMode(cmd.String("watch-mode"))
+	if err != nil {
+		return err
+	}
+	watchExtensions, err := ParseWatchExtensions(cmd.StringSlice("watch-extensions"))
+	if err != nil {
+		return err
+	}
+
+	var accessLog *AccessLog
+	if accessLogFile := cmd.String("access-log"); accessLogFile != "" {
+		keyFile := cmd.String("fingerprint-key-file")
+		if keyFile == "" {
+			return errors.New(localize("serve.access_log_without_key"))
+		}
+		key, err := loadFingerprintKey(keyFile)
+		if err != nil {
+			return err
+		}
+		file, err := os.OpenFile(accessLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("open access log: %w", err)
+		}
+		defer func() { _ = file.Close() }()
+		accessLog = NewAccessLog(file, key)
+	}
+
+	var events *EventStream
+	if eventsFile, eventsSocket := cmd.String("events-file"), cmd.String("events-socket"); eventsFile != "" || eventsSocket != "" {
+		if events, err = OpenEventStream(eventsFile, eventsSocket); err != nil {
+			return fmt.Errorf("open event stream: %w", err)
+		}
+		defer func() { _ = events.Close() }()
+	}
+
+	logLevel := new(slog.LevelVar)
+	logger, closeLogger, err := newServerLogger(os.Stdout, serverLogConfig{
+		file: logFile, dedupWindow: logDedupWindow, quiet: quiet, debug: cmd.Bool("debug"), level: logLevel,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", errorText(localize("serve.failed")), err)
+	}
+	defer closeLogger()
+
+	if err = runStdioMCPServer(logger, logServerReporter{logger: logger}, os.Stdin, os.Stdout, serveConfig{
+		promptsDir:       promptsDir,
+		promptsURL:       promptsURL,
+		pollInterval:     cmd.Duration("poll-interval"),
+		recovery:         recovery,
+		safeModeDisabled: safeModeDisabled,
+		previewAddr:      cmd.String("preview-addr"),
+		controlSocket:    cmd.String("control-socket"),
+		configPath:       cmd.String("config"),
+		logLevel:         logLevel,
+		options: []Option{
+			WithJSONArgs(enableJSONArgs),
+			WithArgsLimits(argsLimits),
+			WithRenderSettings(renderSettings),
+			WithPartialsDirs(partialsSearchPath(cmd)...),
+			WithWatchMode(watchMode, cmd.Duration("watch-poll-interval")),
+			WithWatchExtensions(watchExtensions...),
+			WithAccessLog(accessLog),
+			WithEvents(events),
+			WithRenderCache(renderCache),
+			WithStdinTimeout(cmd.Duration("stdin-timeout")),
+			WithSessionHistory(cmd.Int("session-history")),
+			WithHideDeprecated(cmd.Bool("hide-deprecated")),
+			WithGitTrackedOnly(cmd.Bool("git-tracked-only")),
+			WithRequireEnv(cmd.Bool("require-env")),
+			WithIncompatiblePrompts(incompatiblePrompts),
+			WithMaxAdvertisedArgs(cmd.Int("max-advertised-args")),
+			WithArgOrder(argOrder),
+			WithIconMode(iconMode),
+			WithStrictUnknownArgs(cmd.Bool("strict-unknown-args")),
+			WithDefaultModel(cmd.String("default-model")),
+			WithTemplateOverrides(cmd.Bool("enable-overrides")),
+			WithContextValues(contextValues),
+			WithVars(vars),
+			WithNameStyle(nameStyle),
+			WithSensitivePattern(sensitivePattern),
+			WithBuiltinDateName(dateName),
+			WithFeatureFlags(cmd.String("flags-file")),
+		},
+	}); err != nil {
+		return fmt.Errorf("%s: %w", errorText(localize("serve.failed")), err)
+	}
+	return nil
+}
+
+// parseSensitivePattern compiles the --sensitive-pattern flag; an empty pattern returns nil.
+func parseSensitivePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", localize("cli.invalid_sensitive_pattern"), err)
+	}
+	return re, nil
+}
+
+// parseContextValues parses the key=value pairs of the --context flags
+func parseContextValues(pairs []string, dateName string) (map[string]string, error) {
+	values := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, errors.New(localize("cli.invalid_context_value", pair))
+		}
+		if slices.Contains(builtinFieldNames(dateName), key) {
+			return nil, errors.New(localize("cli.builtin_context_value", pair, key))
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// selfTestCommand runs the self-test with the configuration the serve command would use
+func selfTestCommand(ctx context.Context, cmd *cli.Command) error {
+	if cmd.String("prompts-url") != "" {
+		return errors.New(localize("selftest.prompts_url"))
+	}
+	promptsDir, _, err := resolvePromptsDir(cmd, 0, 0)
+	if err != nil {
+		return err
+	}
+	argsLimits, renderSettings, _, err := renderConfigFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	dateName := cmd.Root().String("builtin-date-name")
+	contextValues, err := parseContextValues(cmd.StringSlice("context"), dateName)
+	if err != nil {
+		return err
+	}
+	vars, err := parseVars(cmd.StringSlice("var"))
+	if err != nil {
+		return err
+	}
+	nameStyle, err := ParseNameStyle(cmd.Root().String("name-style"))
+	if err != nil {
+		return err
+	}
+	sensitivePattern, err := parseSensitivePattern(cmd.String("sensitive-pattern"))
+	if err != nil {
+		return err
+	}
+	incompatiblePrompts, err := ParseIncompatiblePrompts(cmd.String("incompatible-prompts"))
+	if err != nil {
+		return err
+	}
+	argOrder, err := ParseArgOrder(cmd.String("arg-order"))
+	if err != nil {
+		return err
+	}
+	iconMode, err := ParseIconMode(cmd.String("icon-mode"))
+	if err != nil {
+		return err
+	}
+
+	report := runSelfTest(ctx, promptsDir, selfTestOptions{
+		promptName: cmd.String("prompt"),
+		serverOpts: []Option{
+			WithJSONArgs(!cmd.Bool("disable-json-args")),
+			WithArgsLimits(argsLimits),
+			WithRenderSettings(renderSettings),
+			WithPartialsDirs(partialsSearchPath(cmd)...),
+			WithRecovery(!cmd.Bool("no-recovery")),
+			WithSessionHistory(cmd.Int("session-history")),
+			WithHideDeprecated(cmd.Bool("hide-deprecated")),
+			WithGitTrackedOnly(cmd.Bool("git-tracked-only")),
+			WithRequireEnv(cmd.Bool("require-env")),
+			WithIncompatiblePrompts(incompatiblePrompts),
+			WithMaxAdvertisedArgs(cmd.Int("max-advertised-args")),
+			WithArgOrder(argOrder),
+			WithIconMode(iconMode),
+			WithStrictUnknownArgs(cmd.Bool("strict-unknown-args")),
+			WithDefaultModel(cmd.String("default-model")),
+			WithContextValues(contextValues),
+			WithVars(vars),
+			WithNameStyle(nameStyle),
+			WithSensitivePattern(sensitivePattern),
+			WithBuiltinDateName(dateName),
+			WithFeatureFlags(cmd.String("flags-file")),
+		},
+		nameStyle: nameStyle,
+	})
+	if cmd.Bool("json") {
+		if err = printSelfTestReportJSON(cmd.Root().Writer, report); err != nil {
+			return err
+		}
+	} else {
+		printSelfTestReport(cmd.Root().Writer, report)
+	}
+	if failed := report.Failed(); failed > 0 {
+		return errors.New(localize("selftest.steps_failed", failed, len(report.Steps)))
+	}
+	return nil
+}
+
+// renderCommand renders a template to stdout
+func renderCommand(ctx context.Context, cmd *cli.Command) error {
+	promptsDir, positionalArgs, err := resolvePromptsDir(cmd, 1, 0)
+	if err != nil {
+		return err
+	}
+	if len(positionalArgs) < 1 {
+		return errors.New(localize("cli.template_name_required", cmd.Root().Name, cmd.Name))
+	}
+	args := cmd.StringSlice("arg")
+	enableJSONArgs := !cmd.Bool("disable-json-args")
+	lineEndings, err := ParseLineEndings(cmd.Root().String("line-endings"))
+	if err != nil {
+		return err
+	}
+	nameStyle, err := ParseNameStyle(cmd.Root().String("name-style"))
+	if err != nil {
+		return err
+	}
+	templateName, err := resolveTemplateName(promptsDir, positionalArgs[0], nameStyle)
+	if err != nil {
+		return err
+	}
+
+	argMap, err := parseArgFlags(args)
+	if err != nil {
+		return err
+	}
+	fileArgs, err := readArgFiles(
+		cmd.Root().Reader, cmd.StringSlice("arg-file"), cmd.StringSlice("arg-file-json"), argMap, cmd.Int(flagMaxArgsSize),
+	)
+	if err != nil {
+		return err
+	}
+	vars, err := parseVars(cmd.StringSlice("var"))
+	if err != nil {
+		return err
+	}
+
+	if cmd.Bool("mcp") {
+		for _, flag := range []string{"post-processor", "check-schema", "show-whitespace", "measure", "dump-data"} {
+			if cmd.IsSet(flag) {
+				return errors.New(localize("render.mcp_with_output_flag", flag))
+			}
+		}
+		if err = writeMCPEnvelope(ctx, cmd.Root().Writer, promptsDir, templateName, argMap, fileArgs, mcpEnvelopeOptions{
+			partialsDirs:   partialsSearchPath(cmd),
+			enableJSONArgs: enableJSONArgs,
+			dateName:       cmd.Root().String("builtin-date-name"),
+			lineEndings:    lineEndings,
+			nameStyle:      nameStyle,
+			vars:           vars,
+		}); err != nil {
+			return fmt.Errorf("%s '%s': %w", errorText(localize("render.failed")), templateText(templateName), err)
+		}
+		return nil
+	}
+
+	var dump *dataDump
+	if cmd.Bool("dump-data") {
+		sensitivePattern, patternErr := parseSensitivePattern(cmd.String("sensitive-pattern"))
+		if patternErr != nil {
+			return patternErr
+		}
+		dump = &dataDump{w: cmd.Root().ErrWriter, sensitivePattern: sensitivePattern}
+	}
+
+	var rendered bytes.Buffer
+	if err = renderTemplateWithWarnings(
+		&rendered, cmd.Root().ErrWriter, promptsDir, partialsSearchPath(cmd), templateName, argMap, fileArgs, vars,
+		enableJSONArgs, cmd.Root().String("builtin-date-name"), dump,
+	); err != nil {
+		return fmt.Errorf("%s '%s': %w", errorText(localize("render.failed")), templateText(templateName), err)
+	}
+	output := []byte(normalizeLineEndings(rendered.String(), lineEndings))
+	if cmd.Bool("check-schema") {
+		if err = checkRenderedSchema(promptsDir, templateName, string(output)); err != nil {
+			return fmt.Errorf("%s '%s': %w", errorText(localize("render.schema_check_failed")), templateText(templateName), err)
+		}
+	}
+	if postProcessor := cmd.String("post-processor"); postProcessor != "" {
+		if output, err = postProcess(ctx, postProcessor, cmd.Duration("post-processor-timeout"), output); err != nil {
+			return fmt.Errorf("%s '%s': %w", errorText(localize("render.post_process_failed")), templateText(templateName), err)
+		}
+	}
+	if cmd.Bool("show-whitespace") {
+		writeVisibleWhitespace(cmd.Root().Writer, string(output))
+	} else if _, err = cmd.Root().Writer.Write(output); err != nil {
+		return err
+	}
+	if cmd.Bool("measure") {
+		writeMeasurement(cmd.Root().ErrWriter, string(output))
+	}
+	return nil
+}
+
+// parseArgFlags parses the name=value pairs of the --arg flags into a map
+func parseArgFlags(args []string) (map[string]string, error) {
+	argMap := make(map[string]string, len(args))
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.New(localize("cli.invalid_arg_format", arg))
+		}
+		argMap[parts[0]] = parts[1]
+	}
+	return argMap, nil
+}
+
+// benchCommand renders a template repeatedly and reports latency and allocation statistics
+func benchCommand(ctx context.Context, cmd *cli.Command) error {
+	promptsDir, positionalArgs, err := resolvePromptsDir(cmd, 1, 0)
+	if err != nil {
+		return err
+	}
+	if len(positionalArgs) < 1 {
+		return errors.New(localize("cli.template_name_required", cmd.Root().Name, cmd.Name))
+	}
+	nameStyle, err := ParseNameStyle(cmd.Root().String("name-style"))
+	if err != nil {
+		return err
+	}
+	templateName, err := resolveTemplateName(promptsDir, positionalArgs[0], nameStyle)
+	if err != nil {
+		return err
+	}
+	argMap, err := parseArgFlags(cmd.StringSlice("arg"))
+	if err != nil {
+		return err
+	}
+
+	result, err := benchTemplate(promptsDir, templateName, argMap, benchOptions{
+		partialsDirs:   partialsSearchPath(cmd),
+		enableJSONArgs: !cmd.Bool("disable-json-args"),
+		dateName:       cmd.Root().String("builtin-date-name"),
+		iterations:     cmd.Int("iterations"),
+	})
+	if err != nil {
+		return fmt.Errorf("%s '%s': %w", errorText(localize("bench.failed")), templateText(templateName), err)
+	}
+	printBenchResult(cmd.Root().Writer, templateName, result)
+	return nil
+}
+
+// writeMeasurement prints the character, byte, and line counts and the estimated token count of the output.
+func writeMeasurement(w io.Writer, output string) {
+	chars := utf8.RuneCountInString(output)
+	lines := 0
+	if output != "" {
+		lines = strings.Count(strings.TrimSuffix(output, "\n"), "\n") + 1
+	}
+	tokens := defaultTokenEstimator.EstimateTokens(output)
+	_, _ = fmt.Fprintf(w, "%s: %s\n", infoText(localize("render.measurement")),
+		localize("render.measurement_value", chars, len(output), lines, tokens))
+}
+
+// postProcess pipes the rendered output through an external command via stdin/stdout.
+// The command line is split on whitespace and executed directly, without a shell.
+func postProcess(ctx context.Context, command string, timeout time.Duration, input []byte) ([]byte, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, errors.New(localize("render.post_processor_empty"))
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	proc := exec.CommandContext(ctx, fields[0], fields[1:]...)
+	proc.Stdin = bytes.NewReader(input)
+	proc.Stdout = &stdout
+	proc.Stderr = &stderr
+	if err := proc.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, errors.New(localize("render.post_processor_timeout", fields[0], timeout))
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", localize("render.post_processor_failed", fields[0]), err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", localize("render.post_processor_failed", fields[0]), err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// listCommand lists available templates
+func listCommand(ctx context.Context, cmd *cli.Command) error {
+	promptsDir, _, err := resolvePromptsDir(cmd, 0, 0)
+	if err != nil {
+		return err
+	}
+	opts := listOptions{
+		verbose:        cmd.Bool("verbose"),
+		byCollection:   cmd.Bool("by-collection"),
+		sortBy:         cmd.String("sort"),
+		showModified:   cmd.Bool("modified"),
+		partialsDirs:   partialsSearchPath(cmd),
+		dateName:       cmd.Root().String("builtin-date-name"),
+		gitTrackedOnly: cmd.Bool("git-tracked-only"),
+		argOrigins:     cmd.Bool("arg-origins"),
+		flagsFile:      cmd.String("flags-file"),
+	}
+
+	if err = listTemplates(cmd.Root().Writer, promptsDir, opts); err != nil {
+		return fmt.Errorf("%s: %w", localize("list.failed"), err)
+	}
+	return nil
+}
+
+// graphCommand prints the partials every prompt depends on
+func graphCommand(ctx context.Context, cmd *cli.Command) error {
+	promptsDir, _, err := resolvePromptsDir(cmd, 0, 0)
+	if err != nil {
+		return err
+	}
+	tmpl, deps, err := loadPromptDependencies(promptsDir, partialsSearchPath(cmd))
+	if err != nil {
+		return fmt.Errorf("%s: %w", localize("graph.failed"), err)
+	}
+	if cmd.Bool("dot") {
+		if err = printPromptDependenciesDOT(cmd.Root().Writer, tmpl, deps); err != nil {
+			return fmt.Errorf("%s: %w", localize("graph.failed"), err)
+		}
+		return nil
+	}
+	printPromptDependencies(cmd.Root().Writer, deps)
+	return nil
+}
+
+// flagsCommand prints the feature flags used by the templates or defined by the flags file
+func flagsCommand(ctx context.Context, cmd *cli.Command) error {
+	promptsDir, _, err := resolvePromptsDir(cmd, 0, 0)
+	if err != nil {
+		return err
+	}
+	if err = printFeatureFlagsReport(cmd.Root().Writer, promptsDir, partialsSearchPath(cmd), cmd.String("flags-file")); err != nil {
+		return fmt.Errorf("%s: %w", localize("flags.failed"), err)
+	}
+	return nil
+}
+
+// validateCommand validates template syntax
+func validateCommand(ctx context.Context, cmd *cli.Command) error {
+	promptsDir, positionalArgs, err := resolvePromptsDir(cmd, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	nameStyle, err := ParseNameStyle(cmd.Root().String("name-style"))
+	if err != nil {
+		return err
+	}
+	var templateName string
+	if len(positionalArgs) > 0 {
+		if templateName, err = resolveTemplateName(promptsDir, strings.TrimSpace(positionalArgs[0]), nameStyle); err != nil {
+			return err
+		}
+	}
+
+	opts := validateOptions{
+		checkWhitespace: cmd.Bool("whitespace"),
+		failFast:        cmd.Bool("fail-fast"),
+		jobs:            cmd.Int("jobs"),
+		nameStyle:       nameStyle,
+		strictPartials:  cmd.Bool("strict-partials"),
+		dateName:        cmd.Root().String("builtin-date-name"),
+		gitTrackedOnly:  cmd.Bool("git-tracked-only"),
+		consistency:     cmd.Bool("consistency"),
+		format:          cmd.String("format"),
+		flagsFile:       cmd.String("flags-file"),
+		checkContracts:  cmd.Bool("check-contracts"),
+	}
+	if opts.consistency && templateName != "" {
+		return errors.New(localize("consistency.with_template"))
+	}
+	if !opts.consistency && cmd.IsSet("format") {
+		return errors.New(localize("consistency.format_without_consistency"))
+	}
+	if err = validateTemplates(cmd.Root().Writer, promptsDir, partialsSearchPath(cmd), templateName, opts); err != nil {
+		return fmt.Errorf("%s: %w", localize("validate.failed"), err)
+	}
+	return nil
+}
+
+// testCommand runs a template against its fixture cases
+func testCommand(ctx context.Context, cmd *cli.Command) error {
+	promptsDir, positionalArgs, err := resolvePromptsDir(cmd, 1, 0)
+	if err != nil {
+		return err
+	}
+	if len(positionalArgs) < 1 {
+		return errors.New(localize("cli.template_name_required", cmd.Root().Name, cmd.Name))
+	}
+	templateName := positionalArgs[0]
+	fixturesDir := cmd.String("fixtures")
+	if fixturesDir == "" {
+		fixturesDir = defaultFixturesDir(promptsDir, templateName)
+	}
+	vars, err := parseVars(cmd.StringSlice("var"))
+	if err != nil {
+		return err
+	}
+
+	if err = runFixtureTests(
+		cmd.Root().Writer, promptsDir, partialsSearchPath(cmd), templateName, fixturesDir, vars,
+		!cmd.Bool("disable-json-args"), cmd.Root().String("builtin-date-name"),
+	); err != nil {
+		return fmt.Errorf("%s '%s': %w", errorText(localize("test.failed")), templateText(templateName), err)
+	}
+	return nil
+}
+
+// checkCommand checks the contract assertions of the templates
+func checkCommand(ctx context.Context, cmd *cli.Command) error {
+	promptsDir, positionalArgs, err := resolvePromptsDir(cmd, 0, 1)
+	if err != nil {
+		return err
+	}
+	var templateName string
+	if len(positionalArgs) > 0 {
+		templateName = strings.TrimSpace(positionalArgs[0])
+	}
+	if err = checkContractsOfDir(
+		cmd.Root().Writer, promptsDir, partialsSearchPath(cmd), templateName,
+		!cmd.Bool("disable-json-args"), cmd.Root().String("builtin-date-name"),
+	); err != nil {
+		return fmt.Errorf("%s: %w", localize("check.failed"), err)
+	}
+	return nil
+}
+
+// migrateCommand converts the files of another prompt manager into templates
+func migrateCommand(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return errors.New(localize("migrate.src_required", cmd.Root().Name))
+	}
+	promptsDir := cmd.String("into")
+	if promptsDir == "" {
+		promptsDir = cmd.Root().String("prompts")
+	}
+	if err := migratePrompts(cmd.Root().Writer, cmd.Args().First(), promptsDir, migrateOptions{
+		from:   cmd.String("from"),
+		force:  cmd.Bool("force"),
+		dryRun: cmd.Bool("dry-run"),
+	}); err != nil {
+		return fmt.Errorf("%s: %w", localize("migrate.failed"), err)
+	}
+	return nil
+}
+
+// formatCommand prints the templates in the canonical style, or rewrites their files with --write
+func formatCommand(ctx context.Context, cmd *cli.Command) error {
+	promptsDir, positionalArgs, err := resolvePromptsDir(cmd, 0, 1)
+	if err != nil {
+		return err
+	}
+	var templateName string
+	if len(positionalArgs) > 0 {
+		templateName = strings.TrimSpace(positionalArgs[0])
+	}
+	if err = formatTemplates(cmd.Root().Writer, promptsDir, templateName, cmd.Bool("write")); err != nil {
+		return fmt.Errorf("%s: %w", errorText(localize("format.failed")), err)
+	}
+	return nil
+}
+
+// verifyAccessCommand checks an access log entry against the given arguments
+func verifyAccessCommand(ctx context.Context, cmd *cli.Command) error {
+	promptsDir, positionalArgs, err := resolvePromptsDir(cmd, 1, 0)
+	if err != nil {
+		return err
+	}
+	if len(positionalArgs) < 1 {
+		return errors.New(localize("cli.log_line_required", cmd.Root().Name))
+	}
+	args, err := loadFixtureArgs(cmd.String("args-file"))
+	if err != nil {
+		return err
+	}
+	key, err := loadFingerprintKey(cmd.String("fingerprint-key-file"))
+	if err != nil {
+		return err
+	}
+	lineEndings, err := ParseLineEndings(cmd.Root().String("line-endings"))
+	if err != nil {
+		return err
+	}
+	sensitivePattern, err := parseSensitivePattern(cmd.String("sensitive-pattern"))
+	if err != nil {
+		return err
+	}
+
+	if err = verifyAccessLogEntry(ctx, cmd.Root().Writer, promptsDir, positionalArgs[0], args, key, accessVerifyOptions{
+		partialsDirs:     partialsSearchPath(cmd),
+		gitRef:           cmd.String("git-ref"),
+		enableJSONArgs:   !cmd.Bool("disable-json-args"),
+		lineEndings:      lineEndings,
+		sensitivePattern: sensitivePattern,
+		dateName:         cmd.Root().String("builtin-date-name"),
+	}); err != nil {
+		return fmt.Errorf("%s: %w", errorText(localize("verify.failed")), err)
+	}
+	return nil
+}
+
+// docsGenerateCommand writes the documentation of the prompts, or checks that the written one is up to date
+func docsGenerateCommand(ctx context.Context, cmd *cli.Command) error {
+	promptsDir, _, err := resolvePromptsDir(cmd, 0, 0)
+	if err != nil {
+		return err
+	}
+	output := cmd.String("output")
+	check := cmd.Bool("check")
+	if check && output == "" {
+		return errors.New(localize("docs.check_without_output"))
+	}
+	if check && cmd.Bool("stamp") {
+		return errors.New(localize("docs.check_with_stamp"))
+	}
+
+	opts := docsOptions{
+		partialsDirs: partialsSearchPath(cmd),
+		format:       cmd.String("format"),
+		dateName:     cmd.Root().String("builtin-date-name"),
+	}
+	if cmd.Bool("stamp") {
+		opts.stamp = time.Now()
+	}
+	content, err := generatePromptDocs(promptsDir, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", localize("docs.failed"), err)
+	}
+
+	w := cmd.Root().Writer
+	switch {
+	case output == "":
+		_, err = w.Write(content)
+		return err
+	case check:
+		existing, readErr := os.ReadFile(output)
+		if readErr != nil && !errors.Is(readErr, os.ErrNotExist) {
+			return fmt.Errorf("%s: %w", localize("docs.failed"), readErr)
+		}
+		if !bytes.Equal(existing, content) {
+			return errors.New(localize("docs.outdated", output))
+		}
+		mustFprintf(w, "%s %s\n", successIcon(), localize("docs.up_to_date", pathText(output)))
+	default:
+		if err = os.WriteFile(output, content, 0644); err != nil {
+			return fmt.Errorf("%s: %w", localize("docs.failed"), err)
+		}
+		mustFprintf(w, "%s %s\n", successIcon(), localize("docs.written", pathText(output)))
+	}
+	return nil
+}
+
+// cacheStatsCommand prints the content and the use of the render cache
+func cacheStatsCommand(ctx context.Context, cmd *cli.Command) error {
+	renderCache, err := OpenRenderCache(cmd.String("render-cache-dir"), defaultRenderCacheMaxSize)
+	if err != nil {
+		return err
+	}
+	stats, err := renderCache.Stats()
+	if err != nil {
+		return err
+	}
+	w := cmd.Root().Writer
+	hitRate := 0.0
+	if requests := stats.Hits + stats.Misses; requests > 0 {
+		hitRate = float64(stats.Hits) / float64(requests) * 100
+	}
+	mustFprintf(w, "%-10s%s\n", localize("cache.entries")+":",
+		localize("cache.entries_value", highlightText(fmt.Sprint(stats.Entries)), stats.Size))
+	mustFprintf(w, "%-10s%d\n", localize("cache.hits")+":", stats.Hits)
+	mustFprintf(w, "%-10s%d\n", localize("cache.misses")+":", stats.Misses)
+	mustFprintf(w, "%-10s%.1f%%\n", localize("cache.hit_rate")+":", hitRate)
+	return nil
+}
+
+// cacheClearCommand empties the render cache
+func cacheClearCommand(ctx context.Context, cmd *cli.Command) error {
+	renderCache, err := OpenRenderCache(cmd.String("render-cache-dir"), defaultRenderCacheMaxSize)
+	if err != nil {
+		return err
+	}
+	if err = renderCache.Clear(); err != nil {
+		return fmt.Errorf("%s: %w", errorText(localize("cache.clear_failed")), err)
+	}
+	mustFprintf(cmd.Root().Writer, "%s %s\n", successIcon(), localize("cache.cleared"))
+	return nil
+}
+
+// reloadCommand makes a running server reload its prompts through its control socket
+func reloadCommand(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Bool("config") {
+		fields, err := sendControlCommand(cmd.String("control-socket"), controlCommandReloadConfig)
+		if err != nil {
+			return fmt.Errorf("%s: %w", errorText(localize("reload.config_failed")), err)
+		}
+		mustFprintf(cmd.Root().Writer, "%s %s\n", successIcon(), localize("reload.config_done", fields["config_version"]))
+		return nil
+	}
+	if _, err := sendControlCommand(cmd.String("control-socket"), controlCommandReload); err != nil {
+		return fmt.Errorf("%s: %w", errorText(localize("reload.failed")), err)
+	}
+	mustFprintf(cmd.Root().Writer, "%s %s\n", successIcon(), localize("reload.done"))
+	return nil
+}
+
+// statusCommand shows the status of a running server, as replied by its control socket
+func statusCommand(ctx context.Context, cmd *cli.Command) error {
+	fields, err := sendControlCommand(cmd.String("control-socket"), controlCommandStatus)
+	if err != nil {
+		return fmt.Errorf("%s: %w", errorText(localize("server_status.failed")), err)
+	}
+	w := cmd.Root().Writer
+	mustFprintf(w, "%s: %s\n", localize("server_status.prompts"), fields["prompts"])
+	mustFprintf(w, "%s: %s\n", localize("server_status.config_version"), fields["config_version"])
+	return nil
+}
+
+// overrideSetCommand makes a running server serve a template file instead of the file of a prompt for a while
+func overrideSetCommand(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 2 {
+		return errors.New(localize("override.set_args_required", cmd.Root().Name))
+	}
+	promptName, sourcePath := cmd.Args().Get(0), cmd.Args().Get(1)
+	source, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", errorText(localize("override.set_failed", promptName)), err)
 	}
+	payload, err := json.Marshal(controlOverride{Prompt: promptName, TemplateSource: string(source), TTL: cmd.Duration("ttl").String()})
+	if err != nil {
+		return err
+	}
+	fields, err := sendControlCommand(cmd.String("control-socket"), controlCommandOverrideSet+" "+string(payload))
+	if err != nil {
+		return fmt.Errorf("%s: %w", errorText(localize("override.set_failed", promptName)), err)
+	}
+	mustFprintf(cmd.Root().Writer, "%s %s\n", successIcon(), localize("override.set_done", promptName, fields["expires_at"]))
 	return nil
 }
 
-// validateCommand validates template syntax
-func validateCommand(ctx context.Context, cmd *cli.Command) error {
-	promptsDir := cmd.String("prompts")
-
-	var templateName string
-	if cmd.Args().Len() > 0 {
-		templateName = cmd.Args().First()
+// overrideDeleteCommand makes a running server serve a prompt from its own file again
+func overrideDeleteCommand(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return errors.New(localize("override.delete_args_required", cmd.Root().Name))
 	}
-
-	if err := validateTemplates(os.Stdout, promptsDir, templateName); err != nil {
-		return fmt.Errorf("validation failed: %w", err)
+	promptName := cmd.Args().First()
+	if _, err := sendControlCommand(cmd.String("control-socket"), controlCommandOverrideDelete+" "+promptName); err != nil {
+		return fmt.Errorf("%s: %w", errorText(localize("override.delete_failed", promptName)), err)
 	}
+	mustFprintf(cmd.Root().Writer, "%s %s\n", successIcon(), localize("override.delete_done", promptName))
 	return nil
 }
 
 // versionCommand shows detailed version information
 func versionCommand(ctx context.Context, cmd *cli.Command) error {
-	mustFprintf(os.Stdout, "Version:    %s\n", version)
-	mustFprintf(os.Stdout, "Commit:     %s\n", commit)
-	mustFprintf(os.Stdout, "Go Version: %s\n", goVersion)
+	w := cmd.Root().Writer
+	mustFprintf(w, "Version:    %s\n", version)
+	mustFprintf(w, "Commit:     %s\n", commit)
+	mustFprintf(w, "Go Version: %s\n", goVersion)
 	return nil
 }
 
-func runStdioMCPServer(w io.Writer, promptsDir string, logFile string, enableJSONArgs bool, quiet bool) error {
-	// Configure logger
-	logWriter := w
-	if quiet {
-		logWriter = io.Discard
+// serveConfig configures runStdioMCPServer: the prompts to serve, the settings of the serve command around
+// the MCP session, and the options of the PromptsServer.
+type serveConfig struct {
+	promptsDir       string
+	promptsURL       string        // bundle served instead of promptsDir, empty if none
+	pollInterval     time.Duration // interval of the checks for changes of the bundle, 0 to never check
+	recovery         bool
+	safeModeDisabled []string // settings disabled by safe mode, nil without safe mode
+	previewAddr      string   // empty if the web preview is off
+	controlSocket    string   // empty if there is no control socket
+	configPath       string   // runtime config file reloaded on SIGHUP, empty if none
+	logLevel         *slog.LevelVar
+	options          []Option // further options of the PromptsServer
+}
+
+// runStdioMCPServer serves the prompts over stdin and stdout until the client disconnects or a shutdown signal
+// is received. Lifecycle events go to the reporter and everything else to the logger.
+func runStdioMCPServer(
+	logger *slog.Logger, reporter ServerReporter, stdin io.Reader, stdout io.Writer, cfg serveConfig,
+) error {
+	if cfg.safeModeDisabled != nil {
+		reporter.Report(ServerEvent{Level: slog.LevelWarn, Message: "Safe mode is active", Attrs: []any{"disabled", cfg.safeModeDisabled}})
+	}
+	if !cfg.recovery {
+		reporter.Report(ServerEvent{
+			Level: slog.LevelWarn, Message: "Panic recovery is disabled, a panicking template function stops the server",
+		})
 	}
-	if logFile != "" {
-		file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigChan)
+	go func() {
+		select {
+		case <-sigChan:
+			reporter.Report(ServerEvent{Level: slog.LevelInfo, Message: "Received shutdown signal, stopping server"})
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	promptsDir := cfg.promptsDir
+	if cfg.promptsURL != "" {
+		cacheDir, err := os.MkdirTemp("", "mcp-prompt-engine-")
 		if err != nil {
-			return fmt.Errorf("open log file: %w", err)
+			return fmt.Errorf("create remote prompts cache: %w", err)
 		}
-		defer func() { _ = file.Close() }()
-		logWriter = file
+		defer func() { _ = os.RemoveAll(cacheDir) }()
+		remote, err := NewRemotePrompts(cfg.promptsURL, os.Getenv(promptsTokenEnvVar), cacheDir, logger)
+		if err != nil {
+			return err
+		}
+		if _, err = remote.Sync(ctx); err != nil {
+			return fmt.Errorf("fetch remote prompts: %w", err)
+		}
+		if cfg.pollInterval > 0 {
+			go remote.Poll(ctx, cfg.pollInterval)
+		}
+		reporter.Report(ServerEvent{Level: slog.LevelInfo, Message: "Serving remote prompts",
+			Attrs: []any{"url", cfg.promptsURL, "cache_dir", cacheDir, "poll_interval", cfg.pollInterval}})
+		promptsDir = cacheDir
 	}
-	logger := slog.New(slog.NewTextHandler(logWriter, nil))
 
 	// Create PromptsServer instance
-	promptsSrv, err := NewPromptsServer(promptsDir, enableJSONArgs, logger)
+	promptsSrv, err := NewPromptsServer(promptsDir, append(slices.Clip(cfg.options),
+		WithRecovery(cfg.recovery),
+		WithRuntimeConfig(cfg.configPath, cfg.logLevel),
+		WithSafeMode(cfg.safeModeDisabled != nil),
+		WithLogger(logger),
+		WithReporter(reporter),
+	)...)
 	if err != nil {
 		return fmt.Errorf("new prompts server: %w", err)
 	}
+	reporter.Report(promptsSrv.LoadSummary().Event())
 
 	defer func() {
 		if closeErr := promptsSrv.Close(); closeErr != nil {
@@ -239,24 +1823,92 @@ func runStdioMCPServer(w io.Writer, promptsDir string, logFile string, enableJSO
 		}
 	}()
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
-	go func() {
-		<-sigChan
-		logger.Info("Received shutdown signal, stopping server")
-		cancel()
-	}()
+	if cfg.configPath != "" {
+		hupChan := make(chan os.Signal, 1)
+		signal.Notify(hupChan, syscall.SIGHUP)
+		defer signal.Stop(hupChan)
+		go func() {
+			for {
+				select {
+				case <-hupChan:
+					logger.Info("Received SIGHUP, reloading config", "config", cfg.configPath)
+					if _, reloadErr := promptsSrv.ReloadConfig(); reloadErr != nil {
+						logger.Error("Failed to reload config, keeping the current settings", "error", reloadErr)
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	if cfg.previewAddr != "" {
+		addr, loopback, err := previewListenAddr(cfg.previewAddr)
+		if err != nil {
+			return err
+		}
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("listen for web preview: %w", err)
+		}
+		reporter.Report(ServerEvent{Level: slog.LevelInfo, Message: "Serving web preview",
+			Attrs: []any{"url", "http://" + listener.Addr().String()}})
+		if !loopback {
+			reporter.Report(ServerEvent{Level: slog.LevelWarn, Message: "Web preview is reachable from other hosts",
+				Attrs: []any{"addr", listener.Addr().String()}})
+		}
+		go func() {
+			if err := promptsSrv.ServePreview(ctx, listener, addr); err != nil {
+				logger.Error("Web preview server error", "error", err)
+			}
+		}()
+	}
+
+	if cfg.controlSocket != "" {
+		listener, err := listenPrivateSocket(cfg.controlSocket)
+		if err != nil {
+			return fmt.Errorf("control socket: %w", err)
+		}
+		defer func() { _ = os.Remove(cfg.controlSocket) }()
+		reporter.Report(ServerEvent{Level: slog.LevelInfo, Message: "Listening for control commands",
+			Attrs: []any{"socket", cfg.controlSocket}})
+		go func() {
+			if err := promptsSrv.ServeControl(ctx, listener); err != nil {
+				logger.Error("Control socket error", "error", err)
+			}
+		}()
+	}
 
-	return promptsSrv.ServeStdio(ctx, os.Stdin, os.Stdout)
+	return promptsSrv.ServeStdio(ctx, stdin, stdout)
 }
 
 // renderTemplate renders a specified template to stdout with resolved partials and environment variables
-func renderTemplate(w io.Writer, promptsDir string, templateName string, cliArgs map[string]string, enableJSONArgs bool) error {
+func renderTemplate(
+	w io.Writer, promptsDir string, partialsDirs []string, templateName string, cliArgs map[string]string, enableJSONArgs bool,
+) error {
+	return renderTemplateWithWarnings(
+		w, io.Discard, promptsDir, partialsDirs, templateName, cliArgs, nil, nil, enableJSONArgs, defaultBuiltinDateName, nil,
+	)
+}
+
+// dataDump is where render --dump-data prints the data of the template, right before it executes.
+type dataDump struct {
+	w                io.Writer
+	sensitivePattern *regexp.Regexp // names of arguments redacted besides those of the frontmatter, nil if none
+}
+
+// renderTemplateWithWarnings renders a template like renderTemplate, with the built-in date in the dateName field
+// and the deployment variables in .vars, and writes warnings about the arguments and variables to warnW.
+// Values of fileArgs are bound as they are: text values are transformed and validated like cliArgs
+// but never parsed as JSON. Given a dump, the data is printed to it.
+func renderTemplateWithWarnings(
+	w io.Writer, warnW io.Writer, promptsDir string, partialsDirs []string, templateName string,
+	cliArgs map[string]string, fileArgs map[string]interface{}, vars map[string]string, enableJSONArgs bool,
+	dateName string, dump *dataDump,
+) error {
 	templateName = strings.TrimSpace(templateName)
 	if templateName == "" {
-		return fmt.Errorf("template name is required")
+		return errors.New(localize("render.template_name_required"))
 	}
 	if !strings.HasSuffix(templateName, templateExt) {
 		templateName += templateExt
@@ -266,28 +1918,82 @@ func renderTemplate(w io.Writer, promptsDir string, templateName string, cliArgs
 		return err
 	}
 	if !slices.Contains(availableTemplates, templateName) {
-		return fmt.Errorf("template %s not found\n\n%s:\n  %s",
-			errorText(templateName),
-			infoText("Available templates"), strings.Join(availableTemplates, "\n  "))
+		return fmt.Errorf("%s\n\n%s:\n  %s",
+			localize("render.template_not_found", errorText(templateName)),
+			infoText(localize("render.available_templates")), strings.Join(availableTemplates, "\n  "))
 	}
 
-	parser := &PromptsParser{}
+	parser := &PromptsParser{partialsDirs: partialsDirs, extractor: defaultExtractorOptions, builtinDateName: dateName}
 
 	tmpl, err := parser.ParseDir(promptsDir)
 	if err != nil {
 		return fmt.Errorf("parse all prompts: %w", err)
 	}
 
-	args, err := parser.ExtractPromptArgumentsFromTemplate(tmpl, templateName)
+	frontmatter, err := loadPromptFrontmatter(filepath.Join(promptsDir, templateName), parser.dateName())
+	if err != nil {
+		return fmt.Errorf("load frontmatter: %w", err)
+	}
+
+	modelOverrides, err := readModelOverrides(promptsDir)
+	if err != nil {
+		return fmt.Errorf("read model overrides: %w", err)
+	}
+	args, err := parser.extractVariantArguments(tmpl, templateName, modelOverrides[templateName])
 	if err != nil {
 		return fmt.Errorf("extract template arguments: %w", err)
 	}
+	args = frontmatter.ExcludeConstants(args)
+	givenArgs := make(map[string]string, len(cliArgs)+len(fileArgs))
+	maps.Copy(givenArgs, cliArgs)
+	for name := range fileArgs {
+		givenArgs[name] = ""
+	}
+	if unused := unusedArgNames(givenArgs, args, parser.builtinFields()); len(unused) > 0 {
+		mustFprintf(warnW, "%s %s\n", warningIcon(), localize("render.unused_args", strings.Join(unused, ", ")))
+	}
+	if len(vars) == 0 {
+		partials, partialsErr := parser.ExtractPromptPartialsFromTemplate(tmpl, templateName)
+		if partialsErr == nil && templateReadsVars(tmpl, templateName, partials) {
+			mustFprintf(warnW, "%s %s\n", warningIcon(), localize("render.vars_missing"))
+		}
+	}
 
 	data := make(map[string]interface{})
-	data["date"] = time.Now().Format("2006-01-02 15:04:05")
+	if frontmatter.IncludesDate() {
+		data[parser.dateName()] = time.Now().Format(builtinDateLayout)
+	}
 
-	// Parse CLI args with JSON support if enabled
-	parseMCPArgs(cliArgs, enableJSONArgs, data)
+	// Parse CLI args with JSON support if enabled; text from files is never parsed
+	textArgs := make(map[string]string, len(cliArgs)+len(fileArgs))
+	maps.Copy(textArgs, cliArgs)
+	for name, value := range fileArgs {
+		if text, ok := value.(string); ok {
+			textArgs[name] = text
+		}
+	}
+	appliedArgs := frontmatter.TransformArgs(textArgs)
+	if err = frontmatter.ValidateArgs(appliedArgs); err != nil {
+		return err
+	}
+	for name, value := range appliedArgs {
+		if _, fromFile := fileArgs[name]; fromFile {
+			data[name] = value
+			delete(appliedArgs, name)
+		}
+	}
+	parseMCPArgs(appliedArgs, enableJSONArgs, data)
+	for name, value := range fileArgs {
+		if _, isText := value.(string); !isText {
+			data[name] = value
+		}
+	}
+	frontmatter.MergeConstants(data)
+	data[varsField] = vars
+	model := cliArgs[modelArgName]
+	if model != "" {
+		data[modelArgName] = model
+	}
 
 	// Resolve variables from CLI args and environment variables
 	for _, arg := range args {
@@ -296,70 +2002,307 @@ func renderTemplate(w io.Writer, promptsDir string, templateName string, cliArgs
 			// Fall back to environment variables
 			envVarName := strings.ToUpper(arg)
 			if envValue, envExists := os.LookupEnv(envVarName); envExists {
-				data[arg] = envValue
+				data[arg] = frontmatter.TransformArg(arg, envValue)
 			}
 		}
 	}
 
+	if tmpl, err = bindScratchpad(tmpl); err != nil {
+		return err
+	}
+	if dump != nil {
+		sensitive := sensitiveArgs{frontmatter: frontmatter, pattern: dump.sensitivePattern}
+		dumped, dumpErr := json.MarshalIndent(sensitive.ScrubData(data), "", "  ")
+		if dumpErr != nil {
+			return fmt.Errorf("dump template data: %w", dumpErr)
+		}
+		mustFprintf(dump.w, "%s\n", dumped)
+	}
+	tmpl = tmpl.Funcs(template.FuncMap{modelIsFuncName: makeModelIsFunc(model)})
 	var result bytes.Buffer
-	if err = tmpl.ExecuteTemplate(&result, templateName, data); err != nil {
+	if err = tmpl.ExecuteTemplate(&result, selectModelVariant(templateName, modelOverrides[templateName], model), data); err != nil {
 		return fmt.Errorf("execute template: %w", err)
 	}
 	_, err = w.Write(bytes.TrimSpace(result.Bytes()))
 	return err
 }
 
+// Sort orders supported by the list command.
+const (
+	listSortName     = "name"
+	listSortModified = "modified"
+	listSortArgs     = "args"
+)
+
+// listOptions controls the output of listTemplates.
+type listOptions struct {
+	verbose      bool     // show descriptions and variables
+	byCollection bool     // group templates by the collections manifest
+	sortBy       string   // one of listSortName (default), listSortModified, listSortArgs
+	showModified bool     // append the relative modification time and partial count
+	partialsDirs []string // shared partials directories, whose partials are attributed in verbose output
+	dateName     string   // field of the built-in date, defaultBuiltinDateName if empty
+	// gitTrackedOnly skips templates not tracked by git, if the prompts directory is inside a git worktree
+	gitTrackedOnly bool
+	// argOrigins annotates the verbose variables with the templates referencing them
+	argOrigins bool
+	// flagsFile holds the values of the feature flags shown in verbose output, which are all off if empty
+	flagsFile string
+}
+
+// listedTemplate holds the per-template data needed for sorting and the extra list columns.
+type listedTemplate struct {
+	modTime  time.Time
+	args     []string
+	argDocs  map[string]string   // merged docs of the arguments, only with verbose
+	groups   []ArgumentGroup     // arguments sorted into the groups of the frontmatter, only with verbose
+	origins  map[string][]string // templates referencing each argument, only with argOrigins
+	flags    []string            // feature flags used by the template, only with verbose
+	models   []string            // model patterns of the model overrides of the template, only with verbose
+	partials []string
+	err      error
+}
+
 // listTemplates lists all available templates in the prompts directory
-func listTemplates(w io.Writer, promptsDir string, verbose bool) error {
+func listTemplates(w io.Writer, promptsDir string, opts listOptions) error {
 	availableTemplates, err := getAvailableTemplates(promptsDir)
 	if err != nil {
 		return err
 	}
+	var filter templateFilter
+	if opts.gitTrackedOnly {
+		if filter, err = gitTrackedFilter(w, promptsDir); err != nil {
+			return err
+		}
+		availableTemplates = filterTemplates(availableTemplates, filter)
+	}
 	if len(availableTemplates) == 0 {
-		if verbose {
-			mustFprintf(w, "No templates found in %s\n", pathText(promptsDir))
+		if opts.verbose {
+			mustFprintf(w, "%s\n", localize("list.no_templates", pathText(promptsDir)))
 		}
 		return nil
 	}
 
-	parser := &PromptsParser{}
+	groups := []collectionGroup{{Prompts: availableTemplates}}
+	if opts.byCollection {
+		var collections *PromptCollections
+		if collections, err = loadPromptCollections(promptsDir); err != nil {
+			return err
+		}
+		promptNames := make([]string, 0, len(availableTemplates))
+		for _, templateName := range availableTemplates {
+			promptNames = append(promptNames, strings.TrimSuffix(templateName, templateExt))
+		}
+		groups = collections.Group(promptNames)
+		for i := range groups {
+			for j := range groups[i].Prompts {
+				groups[i].Prompts[j] += templateExt
+			}
+		}
+	}
+
+	parser := &PromptsParser{
+		partialsDirs: opts.partialsDirs, extractor: defaultExtractorOptions, builtinDateName: opts.dateName, filter: filter,
+	}
 	var tmpl *template.Template
+	var libraryPartials []libraryPartial
+	if opts.verbose || opts.showModified || opts.sortBy == listSortArgs {
+		if tmpl, err = parser.ParseDir(promptsDir); err != nil {
+			return fmt.Errorf("parse all prompts: %w", err)
+		}
+		if libraryPartials, err = findLibraryPartials(promptsDir, opts.partialsDirs); err != nil {
+			return err
+		}
+	}
+	var modelOverrides map[string][]modelOverride
+	if tmpl != nil {
+		if modelOverrides, err = readModelOverrides(promptsDir); err != nil {
+			return fmt.Errorf("read model overrides: %w", err)
+		}
+	}
+	var estimates *staticTokenEstimates
+	var featureFlags *FeatureFlags
+	if opts.verbose {
+		estimates = newStaticTokenEstimates(tmpl, promptsDir, parser.dateName(), defaultTokenEstimator)
+		if featureFlags, err = loadFeatureFlags(opts.flagsFile); err != nil {
+			return err
+		}
+	}
+
+	infos := make(map[string]*listedTemplate, len(availableTemplates))
+	if tmpl != nil || opts.sortBy == listSortModified {
+		for _, templateName := range availableTemplates {
+			info := &listedTemplate{}
+			var fileInfo os.FileInfo
+			if fileInfo, err = os.Stat(filepath.Join(promptsDir, templateName)); err != nil {
+				return fmt.Errorf("stat template file: %w", err)
+			}
+			info.modTime = fileInfo.ModTime()
+			if tmpl != nil {
+				info.args, info.partials, info.err = parser.analyzeTemplate(tmpl, templateName)
+				if overrides := modelOverrides[templateName]; info.err == nil && len(overrides) > 0 {
+					info.args, info.err = parser.extractVariantArguments(tmpl, templateName, overrides)
+					if opts.verbose {
+						info.models = modelPatterns(overrides)
+					}
+				}
+				var frontmatter *PromptFrontmatter
+				if info.err == nil {
+					frontmatter, info.err = loadPromptFrontmatter(filepath.Join(promptsDir, templateName), parser.dateName())
+					info.args = frontmatter.ExcludeConstants(info.args)
+				}
+				if info.err == nil && opts.verbose {
+					var inlineDocs map[string]string
+					if inlineDocs, info.err = parser.ExtractArgumentDocs(tmpl, templateName); info.err == nil {
+						info.argDocs, _ = frontmatter.ArgumentDocs(inlineDocs)
+					}
+					info.groups = frontmatter.GroupArguments(info.args)
+				}
+				if info.err == nil && opts.verbose {
+					info.flags, info.err = parser.ExtractFeatureFlags(tmpl, templateName)
+				}
+				if info.err == nil && opts.verbose && opts.argOrigins {
+					info.origins, info.err = parser.ExtractArgumentOrigins(tmpl, templateName)
+				}
+			}
+			infos[templateName] = info
+		}
+	}
+
+	// Templates with invalid frontmatter are listed without a marker; validate and list --verbose report the error
+	deprecations := make(map[string]string)
 	for _, templateName := range availableTemplates {
-		if !verbose {
-			// Simple list without description and variables
-			mustFprintf(w, "%s\n", templateText(templateName))
-			continue
+		frontmatter, fmErr := loadPromptFrontmatter(filepath.Join(promptsDir, templateName), parser.dateName())
+		if notice := frontmatter.DeprecationNotice(); fmErr == nil && notice != "" {
+			deprecations[templateName] = notice
 		}
+	}
 
-		mustFprintf(w, "%s\n", templateText(templateName))
+	switch opts.sortBy {
+	case "", listSortName:
+	case listSortModified:
+		for _, group := range groups {
+			sort.SliceStable(group.Prompts, func(i, j int) bool {
+				return infos[group.Prompts[i]].modTime.Before(infos[group.Prompts[j]].modTime)
+			})
+		}
+	case listSortArgs:
+		for _, group := range groups {
+			sort.SliceStable(group.Prompts, func(i, j int) bool {
+				return len(infos[group.Prompts[i]].args) > len(infos[group.Prompts[j]].args)
+			})
+		}
+	default:
+		return errors.New(localize("list.invalid_sort", opts.sortBy,
+			strings.Join([]string{listSortName, listSortModified, listSortArgs}, ", ")))
+	}
 
-		var description string
-		if description, err = parser.ExtractPromptDescriptionFromFile(
-			filepath.Join(promptsDir, templateName),
-		); err != nil {
-			mustFprintf(w, "%s\n", errorText(fmt.Sprintf("Error: %v", err)))
+	// Collections are only needed for the default icons here; a broken manifest is reported by validate
+	collections, _ := loadPromptCollections(promptsDir)
+	icons := make(map[string]string, len(availableTemplates))
+	for _, templateName := range availableTemplates {
+		if icon := readPromptIcon(filepath.Join(promptsDir, templateName)); icon != "" {
+			icons[templateName] = icon
 		} else {
-			if description != "" {
-				mustFprintf(w, "  Description: %s\n", description)
-			} else {
-				mustFprintf(w, "  Description:\n")
-			}
+			icons[templateName] = collections.Icon(strings.TrimSuffix(templateName, templateExt))
 		}
+	}
 
-		if tmpl == nil {
-			if tmpl, err = parser.ParseDir(promptsDir); err != nil {
-				return fmt.Errorf("parse all prompts: %w", err)
-			}
+	now := time.Now()
+	for _, group := range groups {
+		indent := ""
+		if opts.byCollection {
+			mustFprintf(w, "%s\n", infoText(group.Name))
+			indent = "  "
 		}
-		var args []string
-		if args, err = parser.ExtractPromptArgumentsFromTemplate(tmpl, templateName); err != nil {
-			mustFprintf(w, "%s\n", errorText(fmt.Sprintf("Error: %v", err)))
-		} else {
-			if len(args) > 0 {
-				sort.Strings(args)
-				mustFprintf(w, "  Variables: %s\n", highlightText(strings.Join(args, ", ")))
+		for _, templateName := range group.Prompts {
+			suffix := ""
+			if opts.showModified {
+				info := infos[templateName]
+				suffix = fmt.Sprintf(" (modified %s, %s)",
+					formatRelativeTime(info.modTime, now), pluralize(len(info.partials), "partial", "partials"))
+			}
+			if notice, ok := deprecations[templateName]; ok {
+				suffix += fmt.Sprintf(" %s deprecated: %s", warningIcon(), notice)
+			}
+
+			name := withIcon(displayIcon(icons[templateName]), templateText(templateName))
+			if !opts.verbose {
+				// Simple list without description and variables
+				mustFprintf(w, "%s%s%s\n", indent, name, suffix)
+				continue
+			}
+
+			mustFprintf(w, "%s%s%s\n", indent, name, suffix)
+
+			var description string
+			if description, err = parser.ExtractPromptDescriptionFromFile(
+				filepath.Join(promptsDir, templateName),
+			); err != nil {
+				mustFprintf(w, "%s%s\n", indent, errorText(localize("status.error", err)))
+			} else {
+				if description != "" {
+					mustFprintf(w, "%s  %s: %s\n", indent, localize("list.description"), description)
+				} else {
+					mustFprintf(w, "%s  %s:\n", indent, localize("list.description"))
+				}
+			}
+
+			if info := infos[templateName]; info.err != nil {
+				mustFprintf(w, "%s%s\n", indent, errorText(localize("status.error", info.err)))
 			} else {
-				mustFprintf(w, "  Variables:\n")
+				args := slices.Clone(info.args)
+				sort.Strings(args)
+				if len(info.groups) > 0 {
+					// Each group gets a header line, with the docs of its arguments below it
+					mustFprintf(w, "%s  %s:\n", indent, localize("list.variables"))
+					for _, group := range info.groups {
+						name := group.Name
+						if name == "" {
+							name = localize("list.other_arguments")
+						}
+						mustFprintf(w, "%s    %s: %s\n", indent, name, formatListedArgs(group.Args, info.origins))
+						for _, arg := range group.Args {
+							if doc, ok := info.argDocs[arg]; ok {
+								mustFprintf(w, "%s      %s: %s\n", indent, highlightText(arg), doc)
+							}
+						}
+					}
+				} else if len(args) > 0 {
+					mustFprintf(w, "%s  %s: %s\n", indent, localize("list.variables"), formatListedArgs(args, info.origins))
+					for _, arg := range args {
+						if doc, ok := info.argDocs[arg]; ok {
+							mustFprintf(w, "%s    %s: %s\n", indent, highlightText(arg), doc)
+						}
+					}
+				} else {
+					mustFprintf(w, "%s  %s: %s\n", indent, localize("list.variables"), localize("list.no_arguments"))
+				}
+				if len(info.flags) > 0 {
+					values := make([]string, 0, len(info.flags))
+					for _, flag := range info.flags {
+						value, _ := featureFlags.Value(flag)
+						values = append(values, fmt.Sprintf("%s=%t", highlightText(flag), value))
+					}
+					mustFprintf(w, "%s  %s: %s\n", indent, localize("list.flags"), strings.Join(values, ", "))
+				}
+				if len(info.models) > 0 {
+					mustFprintf(w, "%s  %s: %s\n", indent, localize("list.model_overrides"),
+						highlightText(strings.Join(info.models, ", ")))
+				}
+				if len(opts.partialsDirs) > 0 && len(info.partials) > 0 {
+					mustFprintf(w, "%s  %s: %s\n", indent, localize("list.partials"),
+						formatPartialSources(tmpl, info.partials, promptsDir, libraryPartials))
+				}
+				// Templates that cannot render without arguments get no estimate
+				if tokens, estimateErr := estimates.Get(templateName); estimateErr == nil {
+					mustFprintf(w, "%s  %s: %s\n", indent, localize("list.estimated_size"),
+						localize("list.estimated_size_value", tokens))
+				}
+			}
+
+			if _, err = os.Stat(schemaFilePath(promptsDir, templateName)); err == nil {
+				mustFprintf(w, "%s  %s: %s\n", indent, localize("list.schema"), pathText(filepath.Base(schemaFilePath(promptsDir, templateName))))
 			}
 		}
 	}
@@ -367,8 +2310,62 @@ func listTemplates(w io.Writer, promptsDir string, verbose bool) error {
 	return nil
 }
 
+// formatListedArgs joins the arguments for list, each annotated with the templates referencing it if origins are given.
+func formatListedArgs(args []string, origins map[string][]string) string {
+	if origins == nil {
+		return highlightText(strings.Join(args, ", "))
+	}
+	annotated := make([]string, 0, len(args))
+	for _, arg := range args {
+		annotated = append(annotated, highlightText(arg)+" ("+strings.Join(origins[arg], ", ")+")")
+	}
+	return strings.Join(annotated, ", ")
+}
+
+// formatRelativeTime describes how long ago t was relative to now, e.g. "3 days ago"
+func formatRelativeTime(t time.Time, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return pluralize(int(d/time.Minute), "minute", "minutes") + " ago"
+	case d < 24*time.Hour:
+		return pluralize(int(d/time.Hour), "hour", "hours") + " ago"
+	case d < 30*24*time.Hour:
+		return pluralize(int(d/(24*time.Hour)), "day", "days") + " ago"
+	case d < 365*24*time.Hour:
+		return pluralize(int(d/(30*24*time.Hour)), "month", "months") + " ago"
+	default:
+		return pluralize(int(d/(365*24*time.Hour)), "year", "years") + " ago"
+	}
+}
+
+// pluralize formats a count with the singular or plural form of a noun
+func pluralize(n int, singular string, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// validateOptions controls validateTemplates.
+type validateOptions struct {
+	checkWhitespace bool      // also report trailing whitespace and Windows line endings
+	failFast        bool      // stop at the first template with errors
+	jobs            int       // number of templates checked concurrently, GOMAXPROCS if not positive
+	nameStyle       NameStyle // name style of the prompt names in the collections manifest
+	strictPartials  bool      // report partials of the prompts directory that no prompt references
+	dateName        string    // field of the built-in date, defaultBuiltinDateName if empty
+	gitTrackedOnly  bool      // skip templates not tracked by git, if the prompts directory is inside a git worktree
+	consistency     bool      // check the consistency of the argument names across templates instead
+	format          string    // format of the consistency report, consistencyFormatText or consistencyFormatJSON
+	flagsFile       string    // feature flags file checked against the flags used by the templates, if set
+	checkContracts  bool      // also check the contract assertions of the templates
+}
+
 // validateTemplates validates template syntax
-func validateTemplates(w io.Writer, promptsDir string, templateName string) error {
+func validateTemplates(w io.Writer, promptsDir string, partialsDirs []string, templateName string, opts validateOptions) error {
 	templateName = strings.TrimSpace(templateName)
 	if templateName != "" && !strings.HasSuffix(templateName, templateExt) {
 		templateName += templateExt
@@ -378,44 +2375,309 @@ func validateTemplates(w io.Writer, promptsDir string, templateName string) erro
 	if err != nil {
 		return err
 	}
+	var filter templateFilter
+	if opts.gitTrackedOnly {
+		if filter, err = gitTrackedFilter(w, promptsDir); err != nil {
+			return err
+		}
+		availableTemplates = filterTemplates(availableTemplates, filter)
+	}
 	if templateName != "" {
 		if !slices.Contains(availableTemplates, templateName) {
-			return fmt.Errorf("template %q not found in %s", templateName, promptsDir)
+			return errors.New(localize("validate.template_not_found", templateName, promptsDir))
 		}
 	}
 	if len(availableTemplates) == 0 {
-		mustFprintf(w, "%s No templates found in %s\n", warningIcon(), pathText(promptsDir))
+		mustFprintf(w, "%s %s\n", warningIcon(), localize("list.no_templates", pathText(promptsDir)))
 		return nil
 	}
 
-	parser := &PromptsParser{}
+	parser := &PromptsParser{
+		partialsDirs: partialsDirs, extractor: defaultExtractorOptions, builtinDateName: opts.dateName, filter: filter,
+	}
+	if opts.consistency {
+		return validateArgConsistency(w, parser, promptsDir, availableTemplates, opts.format)
+	}
 
-	tmpl, err := parser.ParseDir(promptsDir)
+	// Templates calling undefined functions are reported as errors of their own, like the server skips them
+	tmpl, skipped, err := parser.ParseDirSkippingUnknownFuncs(promptsDir)
 	if err != nil {
 		return fmt.Errorf("parse prompts directory: %w", err)
 	}
+	skippedErrs := make(map[string]error, len(skipped))
+	for _, unknownFunc := range skipped {
+		skippedErrs[unknownFunc.file] = unknownFunc
+	}
 
-	hasErrors := false
-	for _, name := range availableTemplates {
-		if templateName != "" && name != templateName {
-			continue // Skip if not validating this template
+	names := availableTemplates
+	if templateName != "" {
+		names = []string{templateName}
+	}
+	results := checkTemplatesConcurrently(names, opts, func(name string) error {
+		if skippedErr := skippedErrs[name]; skippedErr != nil {
+			return skippedErr
 		}
-		// Try to extract arguments (this validates basic syntax)
-		if _, err = parser.ExtractPromptArgumentsFromTemplate(tmpl, name); err != nil {
-			mustFprintf(w, "%s %s - %s\n", errorIcon(), templateText(name), errorText(fmt.Sprintf("Error: %v", err)))
+		return validateTemplate(parser, tmpl, promptsDir, name, opts.checkWhitespace)
+	})
+
+	hasErrors := false
+	var missingPartials []*missingPartialError
+	for i, name := range names {
+		result := <-results[i]
+		if result != nil {
+			var missingPartial *missingPartialError
+			if errors.As(result, &missingPartial) && !opts.failFast {
+				// Reported below, grouped with the other calls of the same missing partial
+				missingPartials = append(missingPartials, missingPartial)
+				result = errors.New(localize("validate.missing_partial_ref", missingPartial.name))
+			}
+			mustFprintf(w, "%s %s - %s\n", errorIcon(), templateText(name), errorText(localize("status.error", result)))
+			if opts.failFast {
+				return errors.New(localize("validate.template_invalid", name))
+			}
 			hasErrors = true
 			continue
 		}
-		mustFprintf(w, "%s %s - %s\n", successIcon(), templateText(name), successText("Valid"))
+		mustFprintf(w, "%s %s - %s\n", successIcon(), templateText(name), successText(localize("status.valid")))
+		for _, arg := range overriddenArgDocs(parser, tmpl, promptsDir, name) {
+			mustFprintf(w, "%s %s - %s\n", warningIcon(), templateText(name), infoText(localize("validate.doc_overridden", arg)))
+		}
+		if icon, iconErr := parser.ExtractPromptIcon(tmpl, name); iconErr == nil && icon != "" && checkIcon(icon) != nil {
+			mustFprintf(w, "%s %s - %s\n", warningIcon(), templateText(name),
+				infoText(localize("validate.icon_graphemes", icon, graphemeCount(icon))))
+		}
+	}
+	printMissingPartials(w, groupMissingPartials(missingPartials))
+
+	if opts.flagsFile != "" {
+		parsedNames := slices.DeleteFunc(slices.Clone(names), func(name string) bool { return skippedErrs[name] != nil })
+		if flagsErr := checkFeatureFlags(w, parser, tmpl, parsedNames, opts.flagsFile, templateName == ""); flagsErr != nil {
+			mustFprintf(w, "%s %s - %s\n", errorIcon(), pathText(opts.flagsFile), errorText(localize("status.error", flagsErr)))
+			hasErrors = true
+		}
+	}
+
+	if opts.checkContracts {
+		parsedNames := slices.DeleteFunc(slices.Clone(names), func(name string) bool { return skippedErrs[name] != nil })
+		if failed, _ := checkContracts(w, parser, tmpl, promptsDir, parsedNames, true); failed > 0 {
+			hasErrors = true
+		}
+	}
+
+	if templateName == "" && opts.strictPartials {
+		unusedPartials, unusedErr := findUnusedPartials(parser, tmpl, promptsDir, availableTemplates)
+		if unusedErr != nil {
+			return unusedErr
+		}
+		for _, partial := range unusedPartials {
+			mustFprintf(w, "%s %s - %s\n", errorIcon(), templateText(partial),
+				errorText(localize("status.error", localize("validate.unused_partial"))))
+			hasErrors = true
+		}
+	}
+
+	if templateName == "" {
+		libraryPartials, partialsErr := findLibraryPartials(promptsDir, partialsDirs)
+		if partialsErr != nil {
+			return partialsErr
+		}
+		for _, partial := range libraryPartials {
+			if partial.overridden {
+				mustFprintf(w, "%s %s - %s\n", warningIcon(), pathText(partial.path()),
+					infoText(localize("validate.overridden_by", filepath.Join(promptsDir, partial.fileName))))
+				continue
+			}
+			if partial.shadowedBy != "" {
+				mustFprintf(w, "%s %s - %s\n", warningIcon(), pathText(partial.path()),
+					infoText(localize("validate.overridden_by", filepath.Join(partial.shadowedBy, partial.fileName))))
+				continue
+			}
+			mustFprintf(w, "%s %s - %s\n", successIcon(), pathText(partial.path()), successText(localize("status.valid")))
+		}
+
+		collections, collectionsErr := loadPromptCollections(promptsDir)
+		if collectionsErr != nil {
+			mustFprintf(w, "%s %s - %s\n", errorIcon(), pathText(collectionsFileName), errorText(localize("status.error", collectionsErr)))
+			hasErrors = true
+		}
+		promptNames := make([]string, 0, len(availableTemplates))
+		for _, name := range availableTemplates {
+			promptNames = append(promptNames, opts.nameStyle.PromptName(name))
+		}
+		for _, stale := range collections.WithNameStyle(opts.nameStyle).StaleEntries(promptNames) {
+			mustFprintf(w, "%s %s - %s\n", errorIcon(), pathText(collectionsFileName),
+				errorText(localize("validate.stale_collection", stale)))
+			hasErrors = true
+		}
+		for _, collection := range collections.all() {
+			if collection.Icon != "" && checkIcon(collection.Icon) != nil {
+				mustFprintf(w, "%s %s - %s\n", warningIcon(), pathText(collectionsFileName),
+					infoText(localize("validate.icon_graphemes", collection.Icon, graphemeCount(collection.Icon))))
+			}
+		}
 	}
 
 	if hasErrors {
-		return fmt.Errorf("some templates have validation errors")
+		return errors.New(localize("validate.templates_invalid"))
+	}
+
+	return nil
+}
+
+// checkFeatureFlags warns about the flags used by the templates but missing from the flags file and,
+// if checkUnused, about the flags of the file that no template uses. Templates that do not parse are skipped.
+func checkFeatureFlags(
+	w io.Writer, parser *PromptsParser, tmpl *template.Template, templateNames []string, flagsFile string, checkUnused bool,
+) error {
+	flags, err := loadFeatureFlags(flagsFile)
+	if err != nil {
+		return err
+	}
+	used := make(map[string]bool)
+	for _, name := range templateNames {
+		templateFlags, extractErr := parser.ExtractFeatureFlags(tmpl, name)
+		if extractErr != nil {
+			continue
+		}
+		for _, flag := range templateFlags {
+			used[flag] = true
+			if _, defined := flags.Value(flag); !defined {
+				mustFprintf(w, "%s %s - %s\n", warningIcon(), templateText(name), infoText(localize("validate.flag_undefined", flag)))
+			}
+		}
+	}
+	if checkUnused {
+		for _, flag := range flags.Names() {
+			if !used[flag] {
+				mustFprintf(w, "%s %s - %s\n", warningIcon(), pathText(flagsFile), infoText(localize("validate.flag_unused", flag)))
+			}
+		}
+	}
+	return nil
+}
+
+// overriddenArgDocs returns the arguments of a valid template whose inline docs are overridden
+// by a different description in its frontmatter.
+func overriddenArgDocs(parser *PromptsParser, tmpl *template.Template, promptsDir string, templateName string) []string {
+	inlineDocs, err := parser.ExtractArgumentDocs(tmpl, templateName)
+	if err != nil {
+		return nil
+	}
+	frontmatter, err := loadPromptFrontmatter(filepath.Join(promptsDir, templateName), parser.dateName())
+	if err != nil {
+		return nil
+	}
+	_, overridden := frontmatter.ArgumentDocs(inlineDocs)
+	return overridden
+}
+
+// validateArgConsistency reports argument names that likely mean the same across the templates,
+// and fails if there are any that are not ignored in the consistency config.
+func validateArgConsistency(
+	w io.Writer, parser *PromptsParser, promptsDir string, templateNames []string, format string,
+) error {
+	cfg, err := loadConsistencyConfig(promptsDir)
+	if err != nil {
+		return err
+	}
+	report, err := checkArgConsistency(parser, promptsDir, templateNames, cfg)
+	if err != nil {
+		return err
+	}
+	if err = printConsistencyReport(w, report, format); err != nil {
+		return err
 	}
+	if len(report.Clusters) > 0 {
+		return errors.New(localize("consistency.inconsistent", len(report.Clusters)))
+	}
+	return nil
+}
+
+// printMissingPartials writes one finding per missing partial with the places that call it.
+func printMissingPartials(w io.Writer, groups []missingPartialGroup) {
+	for _, group := range groups {
+		finding := localize("validate.missing_partial", group.name, len(group.locations))
+		if group.suggestion != "" {
+			finding += " - " + localize("validate.did_you_mean", group.suggestion)
+		}
+		mustFprintf(w, "%s %s\n", errorIcon(), errorText(finding))
+		for _, location := range group.locations {
+			mustFprintf(w, "    %s\n", pathText(location))
+		}
+	}
+}
 
+// validateTemplate runs the checks of a single template after the prompts directory was parsed.
+func validateTemplate(
+	parser *PromptsParser, tmpl *template.Template, promptsDir string, name string, checkWhitespace bool,
+) error {
+	// Extracting arguments validates basic syntax
+	if _, err := parser.ExtractPromptArgumentsFromTemplate(tmpl, name); err != nil {
+		return err
+	}
+	if err := parser.CheckDictCalls(tmpl, name); err != nil {
+		return err
+	}
+	if _, err := loadPromptFrontmatter(filepath.Join(promptsDir, name), parser.dateName()); err != nil {
+		return fmt.Errorf("frontmatter: %w", err)
+	}
+	if _, err := loadPromptSchema(promptsDir, name); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(schemaFilePath(promptsDir, name)), err)
+	}
+	if checkWhitespace {
+		source, err := os.ReadFile(filepath.Join(promptsDir, name))
+		if err != nil {
+			return fmt.Errorf("read template: %w", err)
+		}
+		if issues := whitespaceIssues(string(source)); len(issues) > 0 {
+			return errors.New(strings.Join(issues, "; "))
+		}
+	}
 	return nil
 }
 
+// checkTemplatesConcurrently runs check for every template on a pool of opts.jobs workers.
+// It returns a channel per template, in the order of names, that receives the result of its check,
+// so callers can report results in a deterministic order while later templates are still being checked.
+// The check only reads the shared parsed templates: lookups of *template.Template are synchronized,
+// and parse trees are never modified after parsing.
+// With opts.failFast, templates after the first failed one in the order of names may be skipped;
+// their channels never receive a result, so callers must stop at the first error.
+func checkTemplatesConcurrently(names []string, opts validateOptions, check func(name string) error) []chan error {
+	jobs := opts.jobs
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	results := make([]chan error, len(names))
+	for i := range results {
+		results[i] = make(chan error, 1)
+	}
+
+	var failed atomic.Bool
+	indexes := make(chan int)
+	go func() {
+		defer close(indexes)
+		for i := range names {
+			if opts.failFast && failed.Load() {
+				return
+			}
+			indexes <- i
+		}
+	}()
+	for range min(jobs, len(names)) {
+		go func() {
+			for i := range indexes {
+				err := check(names[i])
+				if err != nil {
+					failed.Store(true)
+				}
+				results[i] <- err
+			}
+		}()
+	}
+	return results
+}
+
 func getAvailableTemplates(promptsDir string) ([]string, error) {
 	files, err := os.ReadDir(promptsDir)
 	if err != nil {
@@ -432,6 +2694,14 @@ func getAvailableTemplates(promptsDir string) ([]string, error) {
 	return templateFiles, nil
 }
 
+// filterTemplates returns the template names that pass the filter; a nil filter passes all of them.
+func filterTemplates(templateNames []string, filter templateFilter) []string {
+	if filter == nil {
+		return templateNames
+	}
+	return slices.DeleteFunc(templateNames, func(templateName string) bool { return !filter(templateName) })
+}
+
 func mustFprintf(w io.Writer, format string, a ...interface{}) {
 	if _, err := fmt.Fprintf(w, format, a...); err != nil {
 		panic(fmt.Sprintf("Failed to write output: %v", err))
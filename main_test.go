@@ -2,11 +2,16 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -31,7 +36,7 @@ func (s *MainTestSuite) TestRenderTemplateErrorCases() {
 	var buf bytes.Buffer
 
 	// Test non-existent directory
-	err := renderTemplate(&buf, "/non/existent/directory", "template_name", nil, true)
+	err := renderTemplate(&buf, "/non/existent/directory", nil, "template_name", nil, true)
 	assert.Error(s.T(), err, "renderTemplate() expected error for non-existent directory")
 
 	// Test template execution error with missing template
@@ -41,12 +46,12 @@ func (s *MainTestSuite) TestRenderTemplateErrorCases() {
 	require.NoError(s.T(), err, "Failed to write test file")
 
 	var errorBuf bytes.Buffer
-	err = renderTemplate(&errorBuf, s.tempDir, "error", nil, true)
+	err = renderTemplate(&errorBuf, s.tempDir, nil, "error", nil, true)
 	assert.Error(s.T(), err, "renderTemplate() expected execution error for missing template")
 
 	// Test error with non-existent template in renderTemplate
 	var nonExistentBuf bytes.Buffer
-	err = renderTemplate(&nonExistentBuf, s.tempDir, "does_not_exist", nil, true)
+	err = renderTemplate(&nonExistentBuf, s.tempDir, nil, "does_not_exist", nil, true)
 	assert.Error(s.T(), err, "renderTemplate() expected error for non-existent template")
 }
 
@@ -281,7 +286,7 @@ func (s *MainTestSuite) TestRenderTemplate() {
 			}
 
 			var buf bytes.Buffer
-			err := renderTemplate(&buf, "./testdata", tt.templateName, tt.cliArgs, tt.enableJSONArgs)
+			err := renderTemplate(&buf, "./testdata", nil, tt.templateName, tt.cliArgs, tt.enableJSONArgs)
 
 			if tt.shouldError {
 				assert.Error(s.T(), err, "expected error but got none")
@@ -295,6 +300,304 @@ func (s *MainTestSuite) TestRenderTemplate() {
 	}
 }
 
+// TestPositionalPromptsDir tests passing the prompts directory positionally to subcommands
+func (s *MainTestSuite) TestPositionalPromptsDir() {
+	tests := []struct {
+		name           string
+		args           []string
+		expectedOutput []string
+		expectedErr    string
+	}{
+		{
+			name:           "render with positional directory",
+			args:           []string{"render", "./testdata", "greeting", "--arg", "name=Alice"},
+			expectedOutput: []string{"Hello Alice!\nHave a great day!"},
+		},
+		{
+			name:           "render without positional directory uses flag",
+			args:           []string{"--prompts", "./testdata", "render", "greeting", "--arg", "name=Bob"},
+			expectedOutput: []string{"Hello Bob!"},
+		},
+		{
+			name:           "render flag wins over positional directory",
+			args:           []string{"--prompts", "./testdata", "render", "/non/existent/directory", "greeting"},
+			expectedOutput: []string{"Hello <no value>!"},
+		},
+		{
+			name:        "render with nonexistent positional directory",
+			args:        []string{"render", "/non/existent/directory", "greeting"},
+			expectedErr: "prompts directory '/non/existent/directory' does not exist",
+		},
+		{
+			name:        "render with too many arguments",
+			args:        []string{"render", "./testdata", "greeting", "extra"},
+			expectedErr: "too many arguments: greeting extra",
+		},
+		{
+			name:           "list with positional directory",
+			args:           []string{"list", "./testdata"},
+			expectedOutput: []string{"greeting.tmpl", "with_object.tmpl"},
+		},
+		{
+			name:           "validate with positional directory",
+			args:           []string{"validate", "./testdata"},
+			expectedOutput: []string{"greeting.tmpl - Valid", "with_object.tmpl - Valid"},
+		},
+		{
+			name:           "validate with positional directory and template",
+			args:           []string{"validate", "./testdata", "greeting"},
+			expectedOutput: []string{"greeting.tmpl - Valid"},
+		},
+		{
+			name:           "validate template name only uses flag",
+			args:           []string{"--prompts", "./testdata", "validate", "greeting"},
+			expectedOutput: []string{"greeting.tmpl - Valid"},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			var buf bytes.Buffer
+			app := newApp()
+			app.Writer = &buf
+			err := app.Run(context.Background(), append([]string{app.Name}, tt.args...))
+			if tt.expectedErr != "" {
+				require.Error(s.T(), err)
+				assert.Contains(s.T(), err.Error(), tt.expectedErr)
+				return
+			}
+			require.NoError(s.T(), err)
+			output := removeANSIColors(buf.String())
+			for _, expected := range tt.expectedOutput {
+				assert.Contains(s.T(), output, expected)
+			}
+		})
+	}
+
+	// Validating a single template with a positional directory only reports that template
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	require.NoError(s.T(), app.Run(context.Background(), []string{app.Name, "validate", "./testdata", "greeting"}))
+	assert.NotContains(s.T(), buf.String(), "with_object.tmpl")
+}
+
+// TestRenderPostProcessor tests piping rendered output through an external command
+func (s *MainTestSuite) TestRenderPostProcessor() {
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	err := app.Run(context.Background(), []string{
+		app.Name, "render", "./testdata", "greeting", "--arg", "name=Alice", "--post-processor", "tr a-z A-Z",
+	})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "HELLO ALICE!\nHAVE A GREAT DAY!", buf.String())
+
+	tests := []struct {
+		name        string
+		command     string
+		timeout     time.Duration
+		expectedErr string
+	}{
+		{
+			name:        "empty command",
+			command:     "  ",
+			expectedErr: "post-processor command is empty",
+		},
+		{
+			name:        "command not found",
+			command:     "/non/existent/command",
+			expectedErr: `post-processor "/non/existent/command"`,
+		},
+		{
+			name:        "command fails with stderr",
+			command:     "sh -c 'echo",
+			expectedErr: `post-processor "sh": exit status`,
+		},
+		{
+			name:        "command times out",
+			command:     "sleep 5",
+			timeout:     50 * time.Millisecond,
+			expectedErr: `post-processor "sleep" timed out after 50ms`,
+		},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			_, err := postProcess(context.Background(), tt.command, tt.timeout, []byte("input"))
+			require.Error(s.T(), err)
+			assert.Contains(s.T(), err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+// TestRenderMeasure tests that --measure reports output size to stderr and keeps stdout clean
+func (s *MainTestSuite) TestRenderMeasure() {
+	var stdout, stderr bytes.Buffer
+	app := newApp()
+	app.Writer = &stdout
+	app.ErrWriter = &stderr
+	err := app.Run(context.Background(), []string{
+		app.Name, "render", "./testdata", "greeting", "--arg", "name=Alice", "--measure",
+	})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Hello Alice!\nHave a great day!", stdout.String())
+	assert.Contains(s.T(), stderr.String(), "30 characters, 30 bytes, 2 lines, ~8 tokens")
+
+	var buf bytes.Buffer
+	writeMeasurement(&buf, "")
+	assert.Contains(s.T(), buf.String(), "0 characters, 0 bytes, 0 lines, ~0 tokens")
+}
+
+// TestRenderDumpData tests that --dump-data prints the resolved data to stderr, with sensitive arguments redacted
+func (s *MainTestSuite) TestRenderDumpData() {
+	dir := s.T().TempDir()
+	require.NoError(s.T(), os.WriteFile(filepath.Join(dir, "deploy.tmpl"), []byte("---\narguments:\n  token:\n    sensitive: true\n"+
+		"  service:\n    transform: [upper]\ndata:\n  team: core\n---\n{{/* Deploy */}}\n"+
+		"Deploy {{.service}} ({{.replicas}}) for {{.team}} in {{.region}} with {{.token}} and {{.api_key}}"), 0644))
+	s.T().Setenv("REGION", "eu-west-1")
+
+	var stdout, stderr bytes.Buffer
+	app := newApp()
+	app.Writer = &stdout
+	app.ErrWriter = &stderr
+	err := app.Run(context.Background(), []string{
+		app.Name, "render", dir, "deploy", "--arg", "service=api", "--arg", "replicas=3", "--arg", "token=s3cret",
+		"--arg", "api_key=k3y", "--dump-data", "--sensitive-pattern", "_key$",
+	})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Deploy API (3) for core in eu-west-1 with s3cret and k3y", stdout.String())
+
+	var data map[string]interface{}
+	require.NoError(s.T(), json.Unmarshal(stderr.Bytes(), &data))
+	assert.Regexp(s.T(), `^\d{4}-\d{2}-\d{2} `, data["date"])
+	delete(data, "date")
+	assert.Equal(s.T(), map[string]interface{}{
+		"service": "API", "replicas": float64(3), "team": "core", "region": "eu-west-1",
+		"token": redactedValue, "api_key": redactedValue, "vars": map[string]interface{}{},
+	}, data)
+	assert.NotContains(s.T(), stderr.String(), "s3cret")
+}
+
+// TestParseContextValues tests parsing the key=value pairs of the --context flags
+func (s *MainTestSuite) TestParseContextValues() {
+	values, err := parseContextValues([]string{"environment=staging", " team =core=platform", "empty="}, defaultBuiltinDateName)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), map[string]string{"environment": "staging", "team": "core=platform", "empty": ""}, values)
+
+	for _, pair := range []string{"environment", "=staging", "date=today"} {
+		_, err = parseContextValues([]string{pair}, defaultBuiltinDateName)
+		require.Error(s.T(), err, pair)
+		assert.Contains(s.T(), err.Error(), fmt.Sprintf("invalid context value '%s'", pair))
+	}
+}
+
+// TestRenderUnusedArgs tests that render warns on stderr about arguments the template does not use
+func (s *MainTestSuite) TestRenderUnusedArgs() {
+	var stdout, stderr bytes.Buffer
+	app := newApp()
+	app.Writer = &stdout
+	app.ErrWriter = &stderr
+	err := app.Run(context.Background(), []string{
+		app.Name, "--color", "never", "render", "./testdata", "greeting", "--arg", "name=Alice", "--arg", "tone=warm",
+		"--arg", "date=today",
+	})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Hello Alice!\nHave a great day!", stdout.String())
+	assert.Equal(s.T(), "⚠ Arguments not used by the template: tone\n", stderr.String())
+}
+
+// TestRenderDictMisuse tests that a misused dict call fails render with a clear message
+func (s *MainTestSuite) TestRenderDictMisuse() {
+	tempDir := s.T().TempDir()
+	files := map[string]string{
+		"_header.tmpl": `{{define "_header"}}{{.role}} doing {{.task}}{{end}}`,
+		"broken.tmpl":  "{{/* Broken partial data */}}\n{{template \"_header\" dict \"role\" .role \"task\"}}",
+	}
+	for name, content := range files {
+		require.NoError(s.T(), os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644))
+	}
+
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	err := app.Run(context.Background(), []string{app.Name, "render", tempDir, "broken", "--arg", "role=reviewer"})
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "broken.tmpl:2:")
+	assert.Contains(s.T(), err.Error(), "odd number of arguments (3)")
+	assert.Empty(s.T(), buf.String())
+}
+
+// TestValidateTemplatesConcurrency tests that parallel validation reports the same findings in the same order
+// as serial validation, and that --fail-fast stops at the first template with errors
+func (s *MainTestSuite) TestValidateTemplatesConcurrency() {
+	tempDir := s.T().TempDir()
+	writeValidationFixtures(s.T(), tempDir, 40)
+
+	validate := func(opts validateOptions) (string, error) {
+		var buf bytes.Buffer
+		err := validateTemplates(&buf, tempDir, nil, "", opts)
+		return removeANSIColors(buf.String()), err
+	}
+
+	serialOutput, serialErr := validate(validateOptions{jobs: 1, checkWhitespace: true})
+	require.Error(s.T(), serialErr)
+	assert.Equal(s.T(), 40, strings.Count(serialOutput, "\n"))
+	assert.Equal(s.T(), 12, strings.Count(serialOutput, "✗"))
+	for _, jobs := range []int{0, 4, 64} {
+		parallelOutput, parallelErr := validate(validateOptions{jobs: jobs, checkWhitespace: true})
+		require.Error(s.T(), parallelErr)
+		assert.Equal(s.T(), serialOutput, parallelOutput, "jobs=%d", jobs)
+	}
+
+	for _, jobs := range []int{1, 8} {
+		output, err := validate(validateOptions{jobs: jobs, failFast: true})
+		require.Error(s.T(), err)
+		assert.Contains(s.T(), err.Error(), "template prompt_004.tmpl has validation errors")
+		lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+		require.Len(s.T(), lines, 5, "jobs=%d", jobs)
+		assert.True(s.T(), strings.HasPrefix(lines[4], "✗ prompt_004.tmpl - Error:"), lines[4])
+	}
+}
+
+// writeValidationFixtures writes n prompts sharing a partial; every fifth prompt misuses dict
+// and every tenth, starting from the third, has trailing whitespace.
+func writeValidationFixtures(tb testing.TB, dir string, n int) {
+	tb.Helper()
+	partial := `{{define "_role"}}You are a {{.role}} working on {{.project}}.{{end}}`
+	require.NoError(tb, os.WriteFile(filepath.Join(dir, "_role.tmpl"), []byte(partial), 0644))
+	for i := 0; i < n; i++ {
+		body := fmt.Sprintf("{{/* Prompt %d */}}\n{{template \"_role\" .}}\n"+
+			"{{range .items}}- {{.}}\n{{end}}{{if .urgent}}Hurry!{{else}}Take your time.{{end}}\n", i)
+		if i%5 == 4 {
+			body += `{{template "_role" dict "role" .role "project"}}` + "\n"
+		}
+		if i%10 == 2 {
+			body += "Trailing space \n"
+		}
+		name := fmt.Sprintf("prompt_%03d.tmpl", i)
+		require.NoError(tb, os.WriteFile(filepath.Join(dir, name), []byte(body), 0644))
+	}
+}
+
+// BenchmarkValidateTemplates compares serial and parallel validation of a large prompts directory.
+func BenchmarkValidateTemplates(b *testing.B) {
+	dir := b.TempDir()
+	writeValidationFixtures(b, dir, 900)
+	for _, bm := range []struct {
+		name string
+		jobs int
+	}{
+		{name: "serial", jobs: 1},
+		{name: "parallel", jobs: 0},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_ = validateTemplates(io.Discard, dir, nil, "", validateOptions{checkWhitespace: true, jobs: bm.jobs})
+			}
+		})
+	}
+}
+
 // normalizeNewlines is a helper function to normalize newlines in strings
 func normalizeNewlines(s string) string {
 	// Replace multiple consecutive newlines with single newlines
@@ -340,27 +643,35 @@ func (s *MainTestSuite) TestListTemplates() {
 				templateText("conditional_greeting.tmpl"),
 				"  Description: Conditional greeting template",
 				"  Variables: name, show_extra_message",
+				"  Estimated size: ~7 tokens of static text",
 				templateText("greeting.tmpl"),
 				"  Description: Greeting standalone template with no partials",
 				"  Variables: name",
+				"  Estimated size: ~7 tokens of static text",
 				templateText("greeting_with_partials.tmpl"),
 				"  Description: Greeting template with partial",
 				"  Variables: name",
+				"  Estimated size: ~12 tokens of static text",
 				templateText("logical_operators.tmpl"),
 				"  Description: Template with logical operators (and/or) in if blocks",
 				"  Variables: feature_enabled, feature_name, has_permission, is_admin, is_premium, is_trial, message, resource, show_error, show_warning, username",
+				"  Estimated size: ~2 tokens of static text",
 				templateText("multiple_partials.tmpl"),
 				"  Description: Template with multiple partials",
 				"  Variables: author, description, name, title, version",
+				"  Estimated size: ~29 tokens of static text",
 				templateText("range_scalars.tmpl"),
 				"  Description: Template for testing range with JSON array of scalars",
 				"  Variables: numbers, result, tags",
+				"  Estimated size: ~6 tokens of static text",
 				templateText("range_structs.tmpl"),
 				"  Description: Template for testing range with JSON array of structs",
 				"  Variables: age, name, role, total, users",
+				"  Estimated size: ~6 tokens of static text",
 				templateText("with_object.tmpl"),
 				"  Description: Template for testing with + JSON object",
 				"  Variables: config, debug, environment, name, version",
+				"  Estimated size: ~3 tokens of static text",
 			},
 			shouldError: false,
 		},
@@ -369,7 +680,7 @@ func (s *MainTestSuite) TestListTemplates() {
 	for _, tt := range tests {
 		s.Run(tt.name, func() {
 			var buf bytes.Buffer
-			err := listTemplates(&buf, "./testdata", tt.detailed)
+			err := listTemplates(&buf, "./testdata", listOptions{verbose: tt.detailed})
 
 			if tt.shouldError {
 				assert.Error(s.T(), err, "expected error but got none")
@@ -416,22 +727,178 @@ func (s *MainTestSuite) TestListTemplatesErrorCases() {
 	var buf bytes.Buffer
 
 	// Test non-existent directory
-	err := listTemplates(&buf, "/non/existent/directory", false)
+	err := listTemplates(&buf, "/non/existent/directory", listOptions{})
 	assert.Error(s.T(), err, "listTemplates() expected error for non-existent directory")
 
 	// Test empty directory
 	emptyDir := s.T().TempDir()
 	var emptyBuf bytes.Buffer
-	err = listTemplates(&emptyBuf, emptyDir, true)
+	err = listTemplates(&emptyBuf, emptyDir, listOptions{verbose: true})
 	require.NoError(s.T(), err, "listTemplates() should not error for empty directory")
 	output := emptyBuf.String()
 	assert.Contains(s.T(), output, "No templates found", "should indicate no templates found")
 	emptyBuf.Reset()
-	err = listTemplates(&emptyBuf, emptyDir, false)
+	err = listTemplates(&emptyBuf, emptyDir, listOptions{})
 	require.NoError(s.T(), err, "listTemplates() should not error for empty directory")
 	require.Empty(s.T(), emptyBuf.String())
 }
 
+// TestListTemplatesByCollection tests grouping of the template list by collections manifest
+func (s *MainTestSuite) TestListTemplatesByCollection() {
+	tempDir := s.T().TempDir()
+	files := map[string]string{
+		"git_commit.tmpl":   "{{/* Commit changes */}}\nCommit {{.type}}",
+		"git_pr.tmpl":       "{{/* Describe PR */}}\nPR",
+		"greeting.tmpl":     "{{/* Greeting */}}\nHello {{.name}}!",
+		"review_style.tmpl": "{{/* Review style */}}\nReview",
+		collectionsFileName: "collections:\n  - name: Reviews\n    prompts: [review_style]\n  - name: Git\n    prompts: [git_pr, git_commit]\n",
+	}
+	for name, content := range files {
+		require.NoError(s.T(), os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), listTemplates(&buf, tempDir, listOptions{byCollection: true}))
+	assert.Equal(s.T(), "Reviews\n  review_style.tmpl\nGit\n  git_pr.tmpl\n  git_commit.tmpl\nOther\n  greeting.tmpl\n",
+		removeANSIColors(buf.String()))
+
+	buf.Reset()
+	require.NoError(s.T(), listTemplates(&buf, tempDir, listOptions{verbose: true, byCollection: true}))
+	assert.Contains(s.T(), removeANSIColors(buf.String()),
+		"Git\n  git_pr.tmpl\n    Description: Describe PR\n    Variables: no arguments (static)\n    Estimated size: ~1 tokens of static text\n"+
+			"  git_commit.tmpl\n    Description: Commit changes\n    Variables: type\n    Estimated size: ~2 tokens of static text\n")
+}
+
+// TestListTemplatesDeprecated tests the deprecation marker in both list modes
+func (s *MainTestSuite) TestListTemplatesDeprecated() {
+	tempDir := s.T().TempDir()
+	files := map[string]string{
+		"code_review.tmpl": "{{/* Review code */}}\nReview {{.diff}}",
+		"review.tmpl":      "---\ndeprecated: use code_review instead\n---\n{{/* Review */}}\nReview {{.diff}}",
+	}
+	for name, content := range files {
+		require.NoError(s.T(), os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), listTemplates(&buf, tempDir, listOptions{}))
+	assert.Equal(s.T(), "code_review.tmpl\nreview.tmpl ⚠ deprecated: use code_review instead\n", removeANSIColors(buf.String()))
+
+	buf.Reset()
+	require.NoError(s.T(), listTemplates(&buf, tempDir, listOptions{verbose: true}))
+	assert.Contains(s.T(), removeANSIColors(buf.String()),
+		"review.tmpl ⚠ deprecated: use code_review instead\n  Description: Review\n  Variables: diff\n")
+}
+
+// TestListTemplatesArgOrigins tests that --arg-origins annotates the verbose variables with their templates
+func (s *MainTestSuite) TestListTemplatesArgOrigins() {
+	var buf bytes.Buffer
+	require.NoError(s.T(), listTemplates(&buf, "./testdata", listOptions{verbose: true, argOrigins: true}))
+	output := removeANSIColors(buf.String())
+	assert.Contains(s.T(), output, "multiple_partials.tmpl\n  Description: Template with multiple partials\n"+
+		"  Variables: author (multiple_partials.tmpl), description (multiple_partials.tmpl), name (multiple_partials.tmpl), "+
+		"title (multiple_partials.tmpl), version (_footer)\n")
+	assert.Contains(s.T(), output, "greeting_with_partials.tmpl\n  Description: Greeting template with partial\n"+
+		"  Variables: name (greeting_with_partials.tmpl)\n")
+
+	buf.Reset()
+	require.NoError(s.T(), listTemplates(&buf, "./testdata", listOptions{argOrigins: true}))
+	assert.NotContains(s.T(), buf.String(), "Variables", "origins only annotate the verbose output")
+}
+
+// TestListTemplatesSortAndModified tests sort orders and the modification time column
+func (s *MainTestSuite) TestListTemplatesSortAndModified() {
+	tempDir := s.T().TempDir()
+	now := time.Now()
+	files := []struct {
+		name    string
+		content string
+		age     time.Duration
+	}{
+		{"_partial.tmpl", `{{define "_partial"}}{{.p}}{{template "_nested" .}}{{end}}`, time.Hour},
+		{"_nested.tmpl", `{{define "_nested"}}{{.n}}{{end}}`, time.Hour},
+		{"alpha.tmpl", "{{.a}}", 3 * 24 * time.Hour},
+		{"beta.tmpl", `{{.b}} {{.c}} {{template "_partial" .}}`, 10 * time.Minute},
+		{"gamma.tmpl", "{{.a}} {{.b}}", 400 * 24 * time.Hour},
+	}
+	for _, f := range files {
+		path := filepath.Join(tempDir, f.name)
+		require.NoError(s.T(), os.WriteFile(path, []byte(f.content), 0644))
+		require.NoError(s.T(), os.Chtimes(path, now.Add(-f.age), now.Add(-f.age)))
+	}
+
+	tests := []struct {
+		name          string
+		opts          listOptions
+		expectedLines []string
+		expectedErr   string
+	}{
+		{
+			name:          "default name order",
+			opts:          listOptions{},
+			expectedLines: []string{"alpha.tmpl", "beta.tmpl", "gamma.tmpl"},
+		},
+		{
+			name:          "sort by modified",
+			opts:          listOptions{sortBy: listSortModified},
+			expectedLines: []string{"gamma.tmpl", "alpha.tmpl", "beta.tmpl"},
+		},
+		{
+			name:          "sort by args",
+			opts:          listOptions{sortBy: listSortArgs},
+			expectedLines: []string{"beta.tmpl", "gamma.tmpl", "alpha.tmpl"},
+		},
+		{
+			name: "modified column",
+			opts: listOptions{showModified: true},
+			expectedLines: []string{
+				"alpha.tmpl (modified 3 days ago, 0 partials)",
+				"beta.tmpl (modified 10 minutes ago, 2 partials)",
+				"gamma.tmpl (modified 1 year ago, 0 partials)",
+			},
+		},
+		{
+			name:        "invalid sort order",
+			opts:        listOptions{sortBy: "size"},
+			expectedErr: `invalid sort order "size"`,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			var buf bytes.Buffer
+			err := listTemplates(&buf, tempDir, tt.opts)
+			if tt.expectedErr != "" {
+				require.Error(s.T(), err)
+				assert.Contains(s.T(), err.Error(), tt.expectedErr)
+				return
+			}
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), tt.expectedLines, strings.Split(strings.TrimSpace(removeANSIColors(buf.String())), "\n"))
+		})
+	}
+}
+
+// TestFormatRelativeTime tests human-readable relative times
+func (s *MainTestSuite) TestFormatRelativeTime() {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		ago      time.Duration
+		expected string
+	}{
+		{10 * time.Second, "just now"},
+		{time.Minute, "1 minute ago"},
+		{45 * time.Minute, "45 minutes ago"},
+		{2 * time.Hour, "2 hours ago"},
+		{24 * time.Hour, "1 day ago"},
+		{3 * 24 * time.Hour, "3 days ago"},
+		{60 * 24 * time.Hour, "2 months ago"},
+		{800 * 24 * time.Hour, "2 years ago"},
+	} {
+		assert.Equal(s.T(), tt.expected, formatRelativeTime(now.Add(-tt.ago), now))
+	}
+}
+
 // TestListTemplatesWithPartials tests that partials are excluded from listing
 func (s *MainTestSuite) TestListTemplatesWithPartials() {
 	// Create a temp directory with templates and partials
@@ -446,7 +913,7 @@ func (s *MainTestSuite) TestListTemplatesWithPartials() {
 	require.NoError(s.T(), err)
 
 	var buf bytes.Buffer
-	err = listTemplates(&buf, tempDir, false)
+	err = listTemplates(&buf, tempDir, listOptions{})
 	require.NoError(s.T(), err)
 
 	output := buf.String()
@@ -525,6 +992,19 @@ func (s *MainTestSuite) TestValidateTemplates() {
 			},
 			shouldError: true,
 		},
+		{
+			name:         "validate collections manifest with stale entry",
+			templateName: "",
+			templates: map[string]string{
+				"valid.tmpl":       "{{/* Valid template */}}\nHello {{.name}}!",
+				"collections.yaml": "collections:\n  - name: Git\n    prompts: [valid, removed]\n",
+			},
+			expectedOutput: []string{
+				"✓ valid.tmpl - Valid",
+				`✗ collections.yaml - Error: entry "Git/removed" references a nonexistent template`,
+			},
+			shouldError: true,
+		},
 		{
 			name:         "validate template with partials",
 			templateName: "",
@@ -550,7 +1030,7 @@ func (s *MainTestSuite) TestValidateTemplates() {
 
 			// Run validateTemplates and capture output from buffer
 			var buf bytes.Buffer
-			err := validateTemplates(&buf, tempDir, tt.templateName)
+			err := validateTemplates(&buf, tempDir, nil, tt.templateName, validateOptions{})
 
 			if tt.shouldError {
 				assert.Error(s.T(), err, "expected error but got none")
@@ -634,7 +1114,7 @@ func (s *MainTestSuite) TestValidateTemplatesErrorCases() {
 			}
 
 			var buf bytes.Buffer
-			err := validateTemplates(&buf, tempDir, tt.templateName)
+			err := validateTemplates(&buf, tempDir, nil, tt.templateName, validateOptions{})
 
 			if tt.expectedError != "" {
 				assert.Error(s.T(), err)
@@ -660,7 +1140,7 @@ func (s *MainTestSuite) TestValidateTemplatesOutput() {
 	require.NoError(s.T(), err)
 
 	var buf bytes.Buffer
-	err = validateTemplates(&buf, tempDir, "")
+	err = validateTemplates(&buf, tempDir, nil, "", validateOptions{})
 	assert.Error(s.T(), err)
 	assert.Contains(s.T(), err.Error(), "parse prompts directory")
 
@@ -674,7 +1154,7 @@ func (s *MainTestSuite) TestValidateTemplatesOutput() {
 
 	// Run validateTemplates and capture output from buffer
 	var buf2 bytes.Buffer
-	err = validateTemplates(&buf2, tempDir2, "")
+	err = validateTemplates(&buf2, tempDir2, nil, "", validateOptions{})
 	require.NoError(s.T(), err)
 
 	output := buf2.String()
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Languages with a message catalog.
+const (
+	languageEnglish = "en"
+	languageGerman  = "de"
+)
+
+// localeEnvVars are the environment variables selecting the language when --lang is not given, in order of precedence.
+var localeEnvVars = []string{"LC_ALL", "LC_MESSAGES", "LANG"}
+
+// messageCatalogs holds the user-facing messages of the CLI by language and message ID.
+// Messages are fmt format strings. English is complete and is the fallback for IDs missing in other catalogs.
+// Log records and MCP protocol errors are not localized, so they stay greppable. Neither is the context added
+// when wrapping lower-level errors, e.g. "parse all prompts: ...": the wrapped errors of the standard library
+// and the template parser are English, and a translated prefix would only mix the languages within a line.
+var messageCatalogs = map[string]map[string]string{
+	languageEnglish: {
+		"cli.too_many_args":                      "too many arguments: %s",
+		"cli.prompts_dir_not_found":              "prompts directory '%s' does not exist",
+		"cli.template_name_required":             "template name is required\n\nUsage: %s %s [prompts_dir] <template_name>",
+		"cli.log_line_required":                  "access log line is required\n\nUsage: %s verify-access [prompts_dir] <log_line>",
+		"cli.invalid_arg_format":                 "invalid argument format '%s', expected name=value",
+		"cli.invalid_arg_file_format":            "invalid argument file '%s', expected name=path",
+		"cli.arg_file_conflict":                  "argument '%s' is given by both %s and %s",
+		"cli.arg_file_stdin_twice":               "only one argument file can be read from stdin ('-')",
+		"cli.arg_file_unreadable":                "cannot read file '%s' of argument '%s'",
+		"cli.arg_file_invalid_json":              "file '%s' of argument '%s' is not valid JSON",
+		"cli.args_too_large":                     "arguments exceed the limit of %d bytes (see --max-args-size)",
+		"cli.invalid_context_value":              "invalid context value '%s', expected key=value",
+		"cli.builtin_context_value":              "invalid context value '%s', %s is a built-in variable",
+		"cli.invalid_var":                        "invalid variable '%s', expected name=value with a name of letters, digits, and underscores",
+		"cli.invalid_color":                      "invalid color value %q, must be one of: %s",
+		"cli.invalid_sensitive_pattern":          "invalid --sensitive-pattern",
+		"status.passed":                          "Passed",
+		"status.failed":                          "Failed: %v",
+		"status.valid":                           "Valid",
+		"status.error":                           "Error: %v",
+		"status.ok_label":                        "OK:",
+		"status.error_label":                     "ERROR:",
+		"status.warning_label":                   "WARNING:",
+		"serve.dir_with_prompts_url":             "prompts directory argument cannot be combined with --prompts-url",
+		"serve.access_log_without_key":           "--access-log requires --fingerprint-key-file",
+		"serve.invalid_safe_mode":                "invalid safe mode configuration",
+		"serve.failed":                           "failed to start MCP server",
+		"selftest.prompts_url":                   "selftest does not support --prompts-url, run it on a local prompts directory",
+		"selftest.steps_failed":                  "%d of %d self-test steps failed",
+		"render.failed":                          "failed to render template",
+		"render.mcp_with_output_flag":            "--mcp cannot be combined with --%s",
+		"render.schema_check_failed":             "schema check failed for template",
+		"render.post_process_failed":             "failed to post-process template",
+		"render.post_processor_empty":            "post-processor command is empty",
+		"render.post_processor_failed":           "post-processor %q",
+		"render.post_processor_timeout":          "post-processor %q timed out after %s",
+		"render.template_name_required":          "template name is required",
+		"render.template_not_found":              "template %s not found",
+		"render.available_templates":             "Available templates",
+		"render.unused_args":                     "Arguments not used by the template: %s",
+		"render.vars_missing":                    "The template reads deployment variables (.vars), but no --var was given",
+		"render.measurement":                     "Measurement",
+		"render.measurement_value":               "%d characters, %d bytes, %d lines, ~%d tokens",
+		"bench.failed":                           "failed to benchmark template",
+		"bench.iterations":                       "Rendered %s %d times",
+		"bench.latency":                          "Latency",
+		"bench.latency_value":                    "min %s, avg %s, max %s, p99 %s",
+		"bench.allocations":                      "Allocations",
+		"bench.allocations_value":                "%d allocs, %d bytes per render",
+		"format.failed":                          "failed to format templates",
+		"migrate.failed":                         "failed to migrate prompts",
+		"migrate.src_required":                   "source directory is required: %s migrate --from <format> [--into <prompts_dir>] <src_dir>",
+		"migrate.no_files":                       "No files found in %s",
+		"migrate.target_exists":                  "%s already exists, use --force to overwrite it",
+		"migrate.files_failed":                   "%d of %d files could not be migrated",
+		"migrate.manual_constructs":              "manual migration needed: %d",
+		"migrate.issue":                          "line %d: %s %s",
+		"migrate.issue_partial":                  "partial",
+		"migrate.issue_lambda":                   "lambda or helper section",
+		"migrate.issue_implicit_iterator":        "implicit iterator",
+		"migrate.issue_invalid_name":             "name that is not a valid field",
+		"migrate.issue_frontmatter_key":          "front matter key",
+		"list.failed":                            "failed to list templates",
+		"list.invalid_sort":                      "invalid sort order %q, must be one of: %s",
+		"git.not_worktree":                       "%s is not inside a git worktree, --git-tracked-only has no effect",
+		"git.untracked_skipped":                  "Skipping untracked templates: %s",
+		"list.no_templates":                      "No templates found in %s",
+		"list.description":                       "Description",
+		"list.variables":                         "Variables",
+		"list.no_arguments":                      "no arguments (static)",
+		"list.other_arguments":                   "Other",
+		"list.partials":                          "Partials",
+		"list.schema":                            "Schema",
+		"list.estimated_size":                    "Estimated size",
+		"list.estimated_size_value":              "~%d tokens of static text",
+		"graph.failed":                           "failed to build the dependency graph",
+		"graph.no_partials":                      "(no partials)",
+		"validate.failed":                        "validation failed",
+		"validate.template_not_found":            "template %q not found in %s",
+		"validate.template_invalid":              "template %s has validation errors",
+		"validate.templates_invalid":             "some templates have validation errors",
+		"validate.overridden_by":                 "Overridden by %s",
+		"validate.stale_collection":              "Error: entry %q references a nonexistent template",
+		"validate.missing_partial_ref":           "missing partial %q, see below",
+		"validate.missing_partial":               "Missing partial %q, called in %d place(s)",
+		"validate.did_you_mean":                  "did you mean %q?",
+		"validate.unused_partial":                "not referenced by any prompt",
+		"validate.doc_overridden":                "the @doc comment of argument '%s' is overridden by its frontmatter description",
+		"consistency.consider_unifying":          "consider unifying",
+		"consistency.outlier":                    "%s is only used by %s and closely matches %s (%d templates)",
+		"consistency.ignored":                    "Ignored arguments: %s",
+		"consistency.clean":                      "Argument names are consistent (%d arguments in %d templates)",
+		"consistency.inconsistent":               "found %d group(s) of argument names to unify",
+		"consistency.with_template":              "--consistency checks all templates and cannot be given a template name",
+		"consistency.format_without_consistency": "--format applies only to the --consistency report",
+		"test.failed":                            "fixture tests failed for template",
+		"test.cases_failed":                      "%d of %d fixture cases failed",
+		"verify.failed":                          "access log entry not verified",
+		"verify.match":                           "Match",
+		"verify.mismatch":                        "Mismatch: logged %q, computed %q",
+		"verify.checks_failed":                   "%d of %d checks do not match the access log entry",
+		"docs.failed":                            "failed to generate documentation",
+		"docs.check_without_output":              "--check requires --output",
+		"docs.check_with_stamp":                  "--check cannot be combined with --stamp",
+		"docs.outdated":                          "%s is out of date, run docs generate to update it",
+		"docs.up_to_date":                        "%s is up to date",
+		"docs.written":                           "Documentation written to %s",
+		"cache.entries":                          "Entries",
+		"cache.entries_value":                    "%s (%d bytes)",
+		"cache.hits":                             "Hits",
+		"cache.misses":                           "Misses",
+		"cache.hit_rate":                         "Hit rate",
+		"cache.clear_failed":                     "failed to clear render cache",
+		"cache.cleared":                          "Render cache cleared",
+		"reload.done":                            "Prompts reloaded",
+		"check.failed":                           "contract check failed",
+		"check.none":                             "No templates declare contract assertions",
+		"check.assertions_failed":                "%d of %d contract assertions failed",
+		"validate.icon_graphemes":                "icon %q has %d characters instead of a single emoji or a named icon token; clients may break their layout",
+		"reload.failed":                          "failed to reload prompts",
+		"flags.failed":                           "failed to report feature flags",
+		"flags.none":                             "No feature flags are used or defined",
+		"flags.undefined":                        "(not in the flags file)",
+		"flags.unused":                           "(not used by any template)",
+		"list.flags":                             "Flags",
+		"list.model_overrides":                   "Model overrides",
+		"validate.flag_undefined":                "feature flag '%s' is not defined in the flags file and is off",
+		"validate.flag_unused":                   "feature flag '%s' is not used by any template",
+		"reload.config_done":                     "Config reloaded (version %s)",
+		"reload.config_failed":                   "failed to reload config",
+		"server_status.failed":                   "failed to get server status",
+		"server_status.prompts":                  "Prompts",
+		"server_status.config_version":           "Config version",
+		"override.set_args_required":             "prompt and template file are required: %s override --control-socket <socket> set [--ttl <duration>] <prompt> <template_file>",
+		"override.set_failed":                    "failed to override prompt '%s'",
+		"override.set_done":                      "Prompt '%s' is served from the override until %s",
+		"override.delete_args_required":          "prompt is required: %s override --control-socket <socket> delete <prompt>",
+		"override.delete_failed":                 "failed to delete the override of prompt '%s'",
+		"override.delete_done":                   "Prompt '%s' is served from its file again",
+	},
+	languageGerman: {
+		"cli.too_many_args":                      "zu viele Argumente: %s",
+		"cli.prompts_dir_not_found":              "Prompt-Verzeichnis '%s' existiert nicht",
+		"cli.template_name_required":             "Vorlagenname fehlt\n\nVerwendung: %s %s [prompts_dir] <template_name>",
+		"cli.log_line_required":                  "Zeile des Zugriffsprotokolls fehlt\n\nVerwendung: %s verify-access [prompts_dir] <log_line>",
+		"cli.invalid_arg_format":                 "ungültiges Argumentformat '%s', erwartet wird name=wert",
+		"cli.invalid_arg_file_format":            "ungültige Argumentdatei '%s', erwartet wird name=pfad",
+		"cli.arg_file_conflict":                  "Argument '%s' ist sowohl mit %s als auch mit %s angegeben",
+		"cli.arg_file_stdin_twice":               "nur eine Argumentdatei kann von stdin ('-') gelesen werden",
+		"cli.arg_file_unreadable":                "Datei '%s' des Arguments '%s' kann nicht gelesen werden",
+		"cli.arg_file_invalid_json":              "Datei '%s' des Arguments '%s' ist kein gültiges JSON",
+		"cli.args_too_large":                     "Argumente überschreiten die Grenze von %d Bytes (siehe --max-args-size)",
+		"cli.invalid_context_value":              "ungültiger Kontextwert '%s', erwartet wird schlüssel=wert",
+		"cli.builtin_context_value":              "ungültiger Kontextwert '%s', %s ist eine eingebaute Variable",
+		"cli.invalid_var":                        "ungültige Variable '%s', erwartet name=wert mit einem Namen aus Buchstaben, Ziffern und Unterstrichen",
+		"cli.invalid_color":                      "ungültiger Farbwert %q, erlaubt sind: %s",
+		"cli.invalid_sensitive_pattern":          "ungültiges --sensitive-pattern",
+		"status.passed":                          "Bestanden",
+		"status.failed":                          "Fehlgeschlagen: %v",
+		"status.valid":                           "Gültig",
+		"status.error":                           "Fehler: %v",
+		"status.ok_label":                        "OK:",
+		"status.error_label":                     "FEHLER:",
+		"status.warning_label":                   "WARNUNG:",
+		"serve.dir_with_prompts_url":             "das Prompt-Verzeichnis kann nicht zusammen mit --prompts-url angegeben werden",
+		"serve.access_log_without_key":           "--access-log erfordert --fingerprint-key-file",
+		"serve.invalid_safe_mode":                "ungültige Konfiguration des abgesicherten Modus",
+		"serve.failed":                           "MCP-Server konnte nicht gestartet werden",
+		"selftest.prompts_url":                   "selftest unterstützt --prompts-url nicht, bitte mit einem lokalen Prompt-Verzeichnis ausführen",
+		"selftest.steps_failed":                  "%d von %d Selbsttest-Schritten fehlgeschlagen",
+		"render.failed":                          "Vorlage konnte nicht gerendert werden",
+		"render.mcp_with_output_flag":            "--mcp kann nicht zusammen mit --%s angegeben werden",
+		"render.schema_check_failed":             "Schemaprüfung fehlgeschlagen für Vorlage",
+		"render.post_process_failed":             "Nachbearbeitung fehlgeschlagen für Vorlage",
+		"render.post_processor_empty":            "Befehl des Nachbearbeiters ist leer",
+		"render.post_processor_failed":           "Nachbearbeiter %q",
+		"render.post_processor_timeout":          "Zeitüberschreitung des Nachbearbeiters %q nach %s",
+		"render.template_name_required":          "Vorlagenname fehlt",
+		"render.template_not_found":              "Vorlage %s nicht gefunden",
+		"render.available_templates":             "Verfügbare Vorlagen",
+		"render.unused_args":                     "Von der Vorlage nicht verwendete Argumente: %s",
+		"render.vars_missing":                    "Die Vorlage liest Bereitstellungsvariablen (.vars), aber es wurde kein --var angegeben",
+		"render.measurement":                     "Messung",
+		"render.measurement_value":               "%d Zeichen, %d Bytes, %d Zeilen, ~%d Tokens",
+		"bench.failed":                           "Benchmark der Vorlage fehlgeschlagen",
+		"bench.iterations":                       "%s %d-mal gerendert",
+		"bench.latency":                          "Latenz",
+		"bench.latency_value":                    "min %s, Durchschnitt %s, max %s, p99 %s",
+		"bench.allocations":                      "Allokationen",
+		"bench.allocations_value":                "%d Allokationen, %d Bytes pro Rendern",
+		"format.failed":                          "Templates konnten nicht formatiert werden",
+		"migrate.failed":                         "Prompts konnten nicht migriert werden",
+		"migrate.src_required":                   "Quellverzeichnis ist erforderlich: %s migrate --from <format> [--into <prompts_dir>] <src_dir>",
+		"migrate.no_files":                       "Keine Dateien gefunden in %s",
+		"migrate.target_exists":                  "%s existiert bereits, mit --force überschreiben",
+		"migrate.files_failed":                   "%d von %d Dateien konnten nicht migriert werden",
+		"migrate.manual_constructs":              "manuelle Migration nötig: %d",
+		"migrate.issue":                          "Zeile %d: %s %s",
+		"migrate.issue_partial":                  "Teilvorlage",
+		"migrate.issue_lambda":                   "Lambda- oder Hilfsfunktionsabschnitt",
+		"migrate.issue_implicit_iterator":        "impliziter Iterator",
+		"migrate.issue_invalid_name":             "Name, der kein gültiges Feld ist",
+		"migrate.issue_frontmatter_key":          "Front-Matter-Schlüssel",
+		"list.failed":                            "Vorlagen konnten nicht aufgelistet werden",
+		"list.invalid_sort":                      "ungültige Sortierung %q, erlaubt sind: %s",
+		"git.not_worktree":                       "%s liegt in keinem Git-Arbeitsverzeichnis, --git-tracked-only hat keine Wirkung",
+		"git.untracked_skipped":                  "Nicht versionierte Vorlagen werden übersprungen: %s",
+		"list.no_templates":                      "Keine Vorlagen gefunden in %s",
+		"list.description":                       "Beschreibung",
+		"list.variables":                         "Variablen",
+		"list.no_arguments":                      "keine Argumente (statisch)",
+		"list.other_arguments":                   "Weitere",
+		"list.partials":                          "Teilvorlagen",
+		"list.schema":                            "Schema",
+		"list.estimated_size":                    "Geschätzte Größe",
+		"list.estimated_size_value":              "~%d Tokens statischer Text",
+		"graph.failed":                           "Abhängigkeitsgraph konnte nicht erstellt werden",
+		"graph.no_partials":                      "(keine Teilvorlagen)",
+		"validate.failed":                        "Validierung fehlgeschlagen",
+		"validate.template_not_found":            "Vorlage %q nicht gefunden in %s",
+		"validate.template_invalid":              "Vorlage %s enthält Validierungsfehler",
+		"validate.templates_invalid":             "einige Vorlagen enthalten Validierungsfehler",
+		"validate.overridden_by":                 "Überschrieben durch %s",
+		"validate.stale_collection":              "Fehler: Eintrag %q verweist auf eine nicht vorhandene Vorlage",
+		"validate.missing_partial_ref":           "fehlende Teilvorlage %q, siehe unten",
+		"validate.missing_partial":               "Fehlende Teilvorlage %q, aufgerufen an %d Stelle(n)",
+		"validate.did_you_mean":                  "meinten Sie %q?",
+		"validate.unused_partial":                "wird von keinem Prompt verwendet",
+		"validate.doc_overridden":                "der @doc-Kommentar des Arguments '%s' wird von seiner Beschreibung im Frontmatter überschrieben",
+		"consistency.consider_unifying":          "Vereinheitlichung empfohlen",
+		"consistency.outlier":                    "%s wird nur von %s verwendet und ähnelt %s (%d Vorlagen)",
+		"consistency.ignored":                    "Ignorierte Argumente: %s",
+		"consistency.clean":                      "Argumentnamen sind einheitlich (%d Argumente in %d Vorlagen)",
+		"consistency.inconsistent":               "%d Gruppe(n) zu vereinheitlichender Argumentnamen gefunden",
+		"consistency.with_template":              "--consistency prüft alle Vorlagen und akzeptiert keinen Vorlagennamen",
+		"consistency.format_without_consistency": "--format gilt nur für den Bericht von --consistency",
+		"test.failed":                            "Fixture-Tests fehlgeschlagen für Vorlage",
+		"test.cases_failed":                      "%d von %d Fixture-Fällen fehlgeschlagen",
+		"verify.failed":                          "Eintrag des Zugriffsprotokolls nicht bestätigt",
+		"verify.match":                           "Übereinstimmung",
+		"verify.mismatch":                        "Abweichung: protokolliert %q, berechnet %q",
+		"verify.checks_failed":                   "%d von %d Prüfungen stimmen nicht mit dem Eintrag des Zugriffsprotokolls überein",
+		"docs.failed":                            "Dokumentation konnte nicht erstellt werden",
+		"docs.check_without_output":              "--check erfordert --output",
+		"docs.check_with_stamp":                  "--check kann nicht zusammen mit --stamp angegeben werden",
+		"docs.outdated":                          "%s ist veraltet, bitte mit docs generate aktualisieren",
+		"docs.up_to_date":                        "%s ist aktuell",
+		"docs.written":                           "Dokumentation geschrieben nach %s",
+		"cache.entries":                          "Einträge",
+		"cache.entries_value":                    "%s (%d Bytes)",
+		"cache.hits":                             "Treffer",
+		"cache.misses":                           "Fehlzugriffe",
+		"cache.hit_rate":                         "Trefferquote",
+		"cache.clear_failed":                     "Render-Cache konnte nicht geleert werden",
+		"cache.cleared":                          "Render-Cache geleert",
+		"reload.done":                            "Prompts neu geladen",
+		"check.failed":                           "Vertragsprüfung fehlgeschlagen",
+		"check.none":                             "Keine Vorlagen deklarieren Vertragszusicherungen",
+		"check.assertions_failed":                "%d von %d Vertragszusicherungen fehlgeschlagen",
+		"validate.icon_graphemes":                "Icon %q besteht aus %d Zeichen statt aus einem einzelnen Emoji oder einem benannten Icon; Clients könnten das Layout verlieren",
+		"reload.failed":                          "Prompts konnten nicht neu geladen werden",
+		"flags.failed":                           "Feature-Flags konnten nicht ermittelt werden",
+		"flags.none":                             "Es werden keine Feature-Flags verwendet oder definiert",
+		"flags.undefined":                        "(nicht in der Flag-Datei)",
+		"flags.unused":                           "(von keinem Template verwendet)",
+		"list.flags":                             "Flags",
+		"list.model_overrides":                   "Modellvarianten",
+		"validate.flag_undefined":                "Feature-Flag '%s' ist nicht in der Flag-Datei definiert und ist aus",
+		"validate.flag_unused":                   "Feature-Flag '%s' wird von keinem Template verwendet",
+		"reload.config_done":                     "Konfiguration neu geladen (Version %s)",
+		"reload.config_failed":                   "Konfiguration konnte nicht neu geladen werden",
+		"server_status.failed":                   "Serverstatus konnte nicht abgefragt werden",
+		"server_status.prompts":                  "Prompts",
+		"server_status.config_version":           "Konfigurationsversion",
+		"override.set_args_required":             "Prompt und Template-Datei sind erforderlich: %s override --control-socket <socket> set [--ttl <dauer>] <prompt> <template_datei>",
+		"override.set_failed":                    "Prompt '%s' konnte nicht überschrieben werden",
+		"override.set_done":                      "Prompt '%s' wird bis %s aus der Überschreibung bereitgestellt",
+		"override.delete_args_required":          "Prompt ist erforderlich: %s override --control-socket <socket> delete <prompt>",
+		"override.delete_failed":                 "Überschreibung von Prompt '%s' konnte nicht gelöscht werden",
+		"override.delete_done":                   "Prompt '%s' wird wieder aus seiner Datei bereitgestellt",
+	},
+}
+
+// currentLanguage is the language of the CLI messages, set by setLanguage.
+var currentLanguage = languageEnglish
+
+// setLanguage selects the catalog of the locale's language, e.g. "de" or "de_DE.UTF-8".
+// Locales of other languages, "C", and "POSIX" select English.
+func setLanguage(locale string) {
+	currentLanguage = languageOfLocale(locale)
+}
+
+// languageOfLocale returns the language with a catalog for the locale, or English.
+func languageOfLocale(locale string) string {
+	language := strings.ToLower(locale)
+	if i := strings.IndexAny(language, "_-.@"); i >= 0 {
+		language = language[:i]
+	}
+	if _, ok := messageCatalogs[language]; ok {
+		return language
+	}
+	return languageEnglish
+}
+
+// localeFromEnv returns the first non-empty locale environment variable (see localeEnvVars).
+func localeFromEnv() string {
+	for _, name := range localeEnvVars {
+		if locale := os.Getenv(name); locale != "" {
+			return locale
+		}
+	}
+	return ""
+}
+
+// localize returns the message in the current language, formatted with the arguments as by fmt.Sprintf.
+func localize(id string, args ...interface{}) string {
+	format, ok := messageCatalogs[currentLanguage][id]
+	if !ok {
+		if format, ok = messageCatalogs[languageEnglish][id]; !ok {
+			format = id
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
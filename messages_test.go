@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type MessagesTestSuite struct {
+	suite.Suite
+}
+
+func TestMessagesTestSuite(t *testing.T) {
+	suite.Run(t, new(MessagesTestSuite))
+}
+
+func (s *MessagesTestSuite) TearDownTest() {
+	setLanguage(languageEnglish)
+}
+
+var formatVerbRegex = regexp.MustCompile(`%[-+# 0-9.*]*[a-zA-Z%]`)
+
+// TestCatalogs tests that every message ID used in the code is in the English catalog,
+// and that translations only have known IDs with the same format verbs as the English messages
+func (s *MessagesTestSuite) TestCatalogs() {
+	files, err := filepath.Glob("*.go")
+	require.NoError(s.T(), err)
+	used := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		parsed, err := parser.ParseFile(fset, file, nil, 0)
+		require.NoError(s.T(), err)
+		ast.Inspect(parsed, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if fn, ok := call.Fun.(*ast.Ident); !ok || fn.Name != "localize" {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !assert.True(s.T(), ok && lit.Kind == token.STRING, "%s: the message ID must be a string literal",
+				fset.Position(call.Pos())) {
+				return true
+			}
+			id, err := strconv.Unquote(lit.Value)
+			require.NoError(s.T(), err)
+			used[id] = true
+			assert.Contains(s.T(), messageCatalogs[languageEnglish], id, "%s: unknown message ID", fset.Position(call.Pos()))
+			return true
+		})
+	}
+	require.NotEmpty(s.T(), used)
+	for id := range messageCatalogs[languageEnglish] {
+		assert.True(s.T(), used[id], "message %q is not used", id)
+	}
+
+	for language, catalog := range messageCatalogs {
+		for id, message := range catalog {
+			english, ok := messageCatalogs[languageEnglish][id]
+			if !assert.True(s.T(), ok, "%s: message %q is not in the English catalog", language, id) {
+				continue
+			}
+			assert.Equal(s.T(), formatVerbRegex.FindAllString(english, -1), formatVerbRegex.FindAllString(message, -1),
+				"%s: format verbs of message %q", language, id)
+		}
+	}
+}
+
+// TestLanguageSelection tests choosing the catalog from locales and the environment
+func (s *MessagesTestSuite) TestLanguageSelection() {
+	for locale, expected := range map[string]string{
+		"de":          languageGerman,
+		"de_DE.UTF-8": languageGerman,
+		"DE-at":       languageGerman,
+		"de@euro":     languageGerman,
+		"en_US.UTF-8": languageEnglish,
+		"fr_FR":       languageEnglish,
+		"C":           languageEnglish,
+		"POSIX":       languageEnglish,
+		"":            languageEnglish,
+	} {
+		assert.Equal(s.T(), expected, languageOfLocale(locale), locale)
+	}
+
+	s.T().Setenv("LC_ALL", "")
+	s.T().Setenv("LC_MESSAGES", "")
+	s.T().Setenv("LANG", "en_US.UTF-8")
+	assert.Equal(s.T(), "en_US.UTF-8", localeFromEnv())
+	s.T().Setenv("LC_MESSAGES", "de_DE.UTF-8")
+	assert.Equal(s.T(), "de_DE.UTF-8", localeFromEnv(), "LC_MESSAGES takes precedence over LANG")
+	s.T().Setenv("LC_ALL", "C")
+	assert.Equal(s.T(), "C", localeFromEnv(), "LC_ALL takes precedence over all")
+}
+
+// TestFallback tests that IDs missing in a catalog fall back to English
+func (s *MessagesTestSuite) TestFallback() {
+	messageCatalogs[languageEnglish]["test.only_english"] = "%d only in English"
+	defer delete(messageCatalogs[languageEnglish], "test.only_english")
+
+	setLanguage("de_DE.UTF-8")
+	assert.Equal(s.T(), "Gültig", localize("status.valid"))
+	assert.Equal(s.T(), "2 only in English", localize("test.only_english", 2))
+	assert.Equal(s.T(), "test.unknown", localize("test.unknown"))
+}
+
+// TestCLILanguage tests that CLI errors follow --lang and the locale environment, which --lang overrides
+func (s *MessagesTestSuite) TestCLILanguage() {
+	run := func(env string, args ...string) error {
+		s.T().Setenv("LC_ALL", env)
+		app := newApp()
+		app.Writer = &bytes.Buffer{}
+		return app.Run(context.Background(), append([]string{app.Name, "--color", "never"}, args...))
+	}
+
+	err := run("de_DE.UTF-8", "--prompts", "./testdata", "render")
+	require.Error(s.T(), err)
+	assert.Equal(s.T(), "Vorlagenname fehlt\n\nVerwendung: mcp-prompt-engine render [prompts_dir] <template_name>", err.Error())
+
+	err = run("de_DE.UTF-8", "--lang", "en", "--prompts", "./testdata", "render")
+	require.Error(s.T(), err)
+	assert.Equal(s.T(), "template name is required\n\nUsage: mcp-prompt-engine render [prompts_dir] <template_name>", err.Error())
+
+	err = run("", "--lang", "de", "render", "./testdata", "greeting", "--arg", "name")
+	require.Error(s.T(), err)
+	assert.Equal(s.T(), "ungültiges Argumentformat 'name', erwartet wird name=wert", err.Error())
+
+	err = run("de_DE.UTF-8", "render", "./testdata", "greeting", "--arg", "name=Alice", "--post-processor", " ")
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "Befehl des Nachbearbeiters ist leer")
+
+	err = run("de_DE.UTF-8", "list", "./testdata", "--sort", "size")
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), `ungültige Sortierung "size", erlaubt sind: name, modified, args`)
+
+	err = run("fr_FR.UTF-8", "--prompts", "/non/existent", "list")
+	require.Error(s.T(), err)
+	assert.Equal(s.T(), "prompts directory '/non/existent' does not exist", err.Error())
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of prompt files the migrate command converts.
+const (
+	migrateFromMustache    = "mustache"    // Mustache placeholders and sections
+	migrateFromFrontmatter = "frontmatter" // Mustache with a YAML front matter holding the description
+)
+
+// Kinds of constructs the migration leaves to humans.
+const (
+	migrationIssuePartial          = "partial"
+	migrationIssueLambda           = "lambda"
+	migrationIssueImplicitIterator = "implicit_iterator"
+	migrationIssueInvalidName      = "invalid_name"
+	migrationIssueFrontmatterKey   = "frontmatter_key"
+)
+
+// mustacheNameRegex matches Mustache names that translate to template fields, e.g. "user.name".
+var mustacheNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// migrationIssue is a construct that could not be translated automatically. It is kept in the converted
+// template inside a comment, so the template stays valid and the construct is not lost.
+type migrationIssue struct {
+	Line int
+	Kind string
+	Text string // the construct as written in the source file
+}
+
+// kindText describes the kind of the construct.
+func (issue migrationIssue) kindText() string {
+	switch issue.Kind {
+	case migrationIssuePartial:
+		return localize("migrate.issue_partial")
+	case migrationIssueLambda:
+		return localize("migrate.issue_lambda")
+	case migrationIssueImplicitIterator:
+		return localize("migrate.issue_implicit_iterator")
+	case migrationIssueInvalidName:
+		return localize("migrate.issue_invalid_name")
+	case migrationIssueFrontmatterKey:
+		return localize("migrate.issue_frontmatter_key")
+	}
+	return issue.Kind
+}
+
+// migratedFile is the result of converting one source file.
+type migratedFile struct {
+	Source   string // file name in the source directory
+	Target   string // template file name in the prompts directory
+	Content  string
+	Issues   []migrationIssue
+	Err      error
+	Existing bool // the target exists and was not overwritten
+}
+
+// migrateOptions controls migratePrompts.
+type migrateOptions struct {
+	from   string
+	force  bool // overwrite existing templates
+	dryRun bool // print the converted templates instead of writing them
+}
+
+// migratePrompts converts the files of srcDir into templates in promptsDir and reports every file to w.
+// Converted templates are validated by parsing them before they are written.
+func migratePrompts(w io.Writer, srcDir string, promptsDir string, opts migrateOptions) error {
+	if opts.from != migrateFromMustache && opts.from != migrateFromFrontmatter {
+		return fmt.Errorf("invalid format %q, must be one of: %s, %s", opts.from, migrateFromMustache, migrateFromFrontmatter)
+	}
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return fmt.Errorf("read source directory: %w", err)
+	}
+	var files []migratedFile
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		file := migratedFile{
+			Source: entry.Name(),
+			Target: strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())) + templateExt,
+		}
+		file.Content, file.Issues, file.Err = migrateFile(filepath.Join(srcDir, entry.Name()), opts.from)
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		mustFprintf(w, "%s %s\n", warningIcon(), localize("migrate.no_files", pathText(srcDir)))
+		return nil
+	}
+	if !opts.dryRun {
+		if err = os.MkdirAll(promptsDir, 0755); err != nil {
+			return fmt.Errorf("create prompts directory: %w", err)
+		}
+	}
+
+	failed := 0
+	for i := range files {
+		file := &files[i]
+		if file.Err == nil && !opts.force {
+			if _, statErr := os.Stat(filepath.Join(promptsDir, file.Target)); statErr == nil {
+				file.Existing = true
+			}
+		}
+		if file.Err == nil && !file.Existing && !opts.dryRun {
+			file.Err = os.WriteFile(filepath.Join(promptsDir, file.Target), []byte(file.Content), 0644)
+		}
+		if file.Err != nil || file.Existing {
+			failed++
+		}
+		printMigratedFile(w, *file, opts.dryRun)
+	}
+	if failed > 0 {
+		return errors.New(localize("migrate.files_failed", failed, len(files)))
+	}
+	return nil
+}
+
+// migrateFile converts the source file and validates the result.
+func migrateFile(filePath string, from string) (string, []migrationIssue, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", nil, err
+	}
+	var description string
+	var issues []migrationIssue
+	if from == migrateFromFrontmatter {
+		var block []byte
+		// The body keeps the lines of the front matter blank, so lines of issues match the source file
+		if block, content, err = splitFrontmatter(content); err != nil {
+			return "", nil, err
+		}
+		if description, issues, err = convertLegacyFrontmatter(block); err != nil {
+			return "", nil, err
+		}
+	}
+	body, bodyIssues, err := convertMustache(string(content))
+	if err != nil {
+		return "", nil, err
+	}
+	issues = append(issues, bodyIssues...)
+	body = strings.TrimLeft(body, "\n")
+	if description != "" {
+		body = "{{/* " + commentSafe(description) + " */}}\n" + body
+	}
+	if err = validateMigratedTemplate(filepath.Base(filePath), body); err != nil {
+		return "", nil, err
+	}
+	return body, issues, nil
+}
+
+// convertLegacyFrontmatter returns the description of the front matter and reports its other keys,
+// which have no equivalent in templates.
+func convertLegacyFrontmatter(block []byte) (string, []migrationIssue, error) {
+	if block == nil {
+		return "", nil, nil
+	}
+	var fields map[string]any
+	if err := yaml.Unmarshal(block, &fields); err != nil {
+		return "", nil, fmt.Errorf("parse front matter: %w", err)
+	}
+	var issues []migrationIssue
+	for key := range fields {
+		if key != "description" {
+			issues = append(issues, migrationIssue{Line: 1, Kind: migrationIssueFrontmatterKey, Text: key})
+		}
+	}
+	slices.SortFunc(issues, func(a, b migrationIssue) int { return strings.Compare(a.Text, b.Text) })
+	description, _ := fields["description"].(string)
+	return strings.Join(strings.Fields(description), " "), issues, nil
+}
+
+// convertMustache translates Mustache tags to template actions: variables become fields, sections become
+// conditionals, and comments stay comments. Partials, lambdas, and other constructs without an equivalent
+// are kept in comments and reported. Delimiter changes and unbalanced sections are errors.
+func convertMustache(content string) (string, []migrationIssue, error) {
+	type section struct {
+		name       string
+		translated bool
+	}
+	var out []byte
+	var issues []migrationIssue
+	var sections []section
+	line := 1
+	rest := content
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			out = append(out, rest...)
+			break
+		}
+		out = append(out, rest[:start]...)
+		line += strings.Count(rest[:start], "\n")
+		rest = rest[start:]
+
+		closing := "}}"
+		if strings.HasPrefix(rest, "{{{") {
+			closing = "}}}"
+		}
+		end := strings.Index(rest, closing)
+		if end < 0 {
+			return "", nil, fmt.Errorf("line %d: tag is not closed", line)
+		}
+		tag := rest[:end+len(closing)]
+		rest = rest[end+len(closing):]
+		tagLine := line
+		line += strings.Count(tag, "\n")
+
+		untranslated := func(kind string) string {
+			issues = append(issues, migrationIssue{Line: tagLine, Kind: kind, Text: tag})
+			return "{{/* migrate: " + commentSafe(tag) + " */}}"
+		}
+		variable := func(name string) string {
+			if name == "." {
+				return untranslated(migrationIssueImplicitIterator)
+			}
+			if !mustacheNameRegex.MatchString(name) {
+				return untranslated(migrationIssueInvalidName)
+			}
+			return "{{." + name + "}}"
+		}
+
+		inner := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(tag, "{{"), "}}"))
+		var action string
+		standalone := false
+		switch {
+		case closing == "}}}":
+			action = variable(strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(tag, "{{{"), "}}}")))
+		case strings.HasPrefix(inner, "="):
+			return "", nil, fmt.Errorf("line %d: delimiter changes are not supported", tagLine)
+		case strings.HasPrefix(inner, "!"):
+			action, standalone = "{{/* "+commentSafe(strings.TrimSpace(inner[1:]))+" */}}", true
+		case strings.HasPrefix(inner, "#"), strings.HasPrefix(inner, "^"):
+			name := strings.TrimSpace(inner[1:])
+			s := section{name: name, translated: mustacheNameRegex.MatchString(name)}
+			sections = append(sections, s)
+			switch {
+			case !s.translated:
+				// Tags such as {{#upper name}} call lambdas or helpers, which templates cannot
+				action = untranslated(migrationIssueLambda)
+			case inner[0] == '#':
+				action = "{{if ." + name + "}}"
+			default:
+				action = "{{if not ." + name + "}}"
+			}
+			standalone = true
+		case strings.HasPrefix(inner, "/"):
+			name := strings.TrimSpace(inner[1:])
+			if len(sections) == 0 || sections[len(sections)-1].name != name {
+				return "", nil, fmt.Errorf("line %d: closing tag %q does not match an open section", tagLine, name)
+			}
+			action = "{{end}}"
+			if !sections[len(sections)-1].translated {
+				action = untranslated(migrationIssueLambda)
+			}
+			sections = sections[:len(sections)-1]
+			standalone = true
+		case strings.HasPrefix(inner, ">"):
+			action = untranslated(migrationIssuePartial)
+		case strings.HasPrefix(inner, "&"):
+			action = variable(strings.TrimSpace(inner[1:]))
+		default:
+			action = variable(inner)
+		}
+
+		// Like Mustache, drop the line of a tag that stands alone on it, so no blank lines are left behind
+		lineStart := bytes.LastIndexByte(out, '\n') + 1
+		lineEnd := strings.IndexByte(rest, '\n')
+		if standalone && lineEnd >= 0 && strings.TrimSpace(string(out[lineStart:])) == "" &&
+			strings.TrimSpace(rest[:lineEnd]) == "" {
+			out = out[:lineStart]
+			rest = rest[lineEnd+1:]
+			line++
+		}
+		out = append(out, action...)
+	}
+	if len(sections) > 0 {
+		return "", nil, fmt.Errorf("section %q is not closed", sections[len(sections)-1].name)
+	}
+	return string(out), issues, nil
+}
+
+// commentSafe keeps text from ending the template comment it is put in.
+func commentSafe(text string) string {
+	return strings.ReplaceAll(text, "*/", "* /")
+}
+
+// validateMigratedTemplate parses the converted template on its own, as the server would.
+func validateMigratedTemplate(sourceName string, content string) error {
+	dir, err := os.MkdirTemp("", "mcp-prompt-engine-migrate-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	templateName := strings.TrimSuffix(sourceName, filepath.Ext(sourceName)) + templateExt
+	if err = os.WriteFile(filepath.Join(dir, templateName), []byte(content), 0644); err != nil {
+		return err
+	}
+	if _, err = (&PromptsParser{}).ParseDir(dir); err != nil {
+		return fmt.Errorf("converted template is invalid: %w", err)
+	}
+	return nil
+}
+
+func printMigratedFile(w io.Writer, file migratedFile, dryRun bool) {
+	switch {
+	case file.Err != nil:
+		mustFprintf(w, "%s %s - %s\n", errorIcon(), templateText(file.Source), errorText(localize("status.error", file.Err)))
+		return
+	case file.Existing:
+		mustFprintf(w, "%s %s - %s\n", errorIcon(), templateText(file.Source),
+			errorText(localize("migrate.target_exists", file.Target)))
+		return
+	case len(file.Issues) > 0:
+		mustFprintf(w, "%s %s → %s - %s\n", warningIcon(), templateText(file.Source), templateText(file.Target),
+			localize("migrate.manual_constructs", len(file.Issues)))
+		for _, issue := range file.Issues {
+			mustFprintf(w, "    %s\n", localize("migrate.issue", issue.Line, issue.kindText(), issue.Text))
+		}
+	default:
+		mustFprintf(w, "%s %s → %s\n", successIcon(), templateText(file.Source), templateText(file.Target))
+	}
+	if dryRun {
+		mustFprintf(w, "%s\n", strings.TrimRight(file.Content, "\n"))
+	}
+}
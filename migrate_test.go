@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type MigrateTestSuite struct {
+	suite.Suite
+	srcDir     string
+	promptsDir string
+}
+
+func TestMigrateTestSuite(t *testing.T) {
+	suite.Run(t, new(MigrateTestSuite))
+}
+
+func (s *MigrateTestSuite) SetupTest() {
+	s.srcDir = s.T().TempDir()
+	s.promptsDir = filepath.Join(s.T().TempDir(), "prompts")
+}
+
+func (s *MigrateTestSuite) writeSource(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.srcDir, name), []byte(content), 0644))
+}
+
+func (s *MigrateTestSuite) migrate(opts migrateOptions) (string, error) {
+	var buf bytes.Buffer
+	err := migratePrompts(&buf, s.srcDir, s.promptsDir, opts)
+	return removeANSIColors(buf.String()), err
+}
+
+// TestConvertMustache tests the translation of every Mustache construct
+func (s *MigrateTestSuite) TestConvertMustache() {
+	tests := []struct {
+		name           string
+		content        string
+		expected       string
+		expectedIssues []migrationIssue
+		expectedErr    string
+	}{
+		{name: "variable", content: "Hello {{name}} from {{ user.team }}!", expected: "Hello {{.name}} from {{.user.team}}!"},
+		{name: "unescaped variables", content: "{{{code}}} and {{& diff}}", expected: "{{.code}} and {{.diff}}"},
+		{name: "section", content: "{{#urgent}}Hurry!{{/urgent}}", expected: "{{if .urgent}}Hurry!{{end}}"},
+		{name: "inverted section", content: "{{^urgent}}Take your time.{{/urgent}}", expected: "{{if not .urgent}}Take your time.{{end}}"},
+		{name: "comment", content: "{{! keep it short }}Summarize", expected: "{{/* keep it short */}}Summarize"},
+		{
+			name:     "standalone tags leave no blank lines",
+			content:  "Review:\n  {{#strict}}\nBe strict.\n  {{/strict}}\nDone",
+			expected: "Review:\n{{if .strict}}Be strict.\n{{end}}Done",
+		},
+		{
+			name:     "partial",
+			content:  "{{> header}}\nBody {{name}}",
+			expected: "{{/* migrate: {{> header}} */}}\nBody {{.name}}",
+			expectedIssues: []migrationIssue{
+				{Line: 1, Kind: migrationIssuePartial, Text: "{{> header}}"},
+			},
+		},
+		{
+			name:     "lambda",
+			content:  "Intro\n{{#upper}}{{name}}{{/upper}} and {{#wrap name}}text{{/wrap name}}",
+			expected: "Intro\n{{if .upper}}{{.name}}{{end}} and {{/* migrate: {{#wrap name}} */}}text{{/* migrate: {{/wrap name}} */}}",
+			expectedIssues: []migrationIssue{
+				{Line: 2, Kind: migrationIssueLambda, Text: "{{#wrap name}}"},
+				{Line: 2, Kind: migrationIssueLambda, Text: "{{/wrap name}}"},
+			},
+		},
+		{
+			name:     "implicit iterator and invalid names",
+			content:  "{{#files}}{{.}}{{/files}} by {{first-name}}",
+			expected: "{{if .files}}{{/* migrate: {{.}} */}}{{end}} by {{/* migrate: {{first-name}} */}}",
+			expectedIssues: []migrationIssue{
+				{Line: 1, Kind: migrationIssueImplicitIterator, Text: "{{.}}"},
+				{Line: 1, Kind: migrationIssueInvalidName, Text: "{{first-name}}"},
+			},
+		},
+		{name: "delimiter change", content: "a\n{{=<% %>=}}", expectedErr: "line 2: delimiter changes are not supported"},
+		{name: "unclosed section", content: "{{#a}}text", expectedErr: `section "a" is not closed`},
+		{name: "mismatched section", content: "{{#a}}{{/b}}", expectedErr: `line 1: closing tag "b" does not match an open section`},
+		{name: "unclosed tag", content: "a\nb {{name", expectedErr: "line 2: tag is not closed"},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			converted, issues, err := convertMustache(tt.content)
+			if tt.expectedErr != "" {
+				assert.EqualError(s.T(), err, tt.expectedErr)
+				return
+			}
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), tt.expected, converted)
+			assert.Equal(s.T(), tt.expectedIssues, issues)
+		})
+	}
+}
+
+// TestMigratePrompts tests converting a directory, including front matter, reports, and existing templates
+func (s *MigrateTestSuite) TestMigratePrompts() {
+	s.writeSource("review.md", "---\ndescription: >\n  Review a\n  change\nmodel: gpt-4\n---\n{{#strict}}\nBe strict.\n{{/strict}}\nReview {{diff}}")
+	s.writeSource("intro.mustache", "---\ndescription: Introduce someone\n---\n{{> header}}\nHello {{name}}")
+
+	output, err := s.migrate(migrateOptions{from: migrateFromFrontmatter})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "⚠ intro.mustache → intro.tmpl - manual migration needed: 1\n"+
+		"    line 4: partial {{> header}}\n"+
+		"⚠ review.md → review.tmpl - manual migration needed: 1\n"+
+		"    line 1: front matter key model\n", output)
+
+	content, err := os.ReadFile(filepath.Join(s.promptsDir, "review.tmpl"))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "{{/* Review a change */}}\n{{if .strict}}Be strict.\n{{end}}Review {{.diff}}", string(content))
+	description, err := (&PromptsParser{}).ExtractPromptDescriptionFromFile(filepath.Join(s.promptsDir, "intro.tmpl"))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Introduce someone", description)
+
+	// Existing templates are only overwritten with --force
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, "review.tmpl"), []byte("edited"), 0644))
+	output, err = s.migrate(migrateOptions{from: migrateFromFrontmatter})
+	assert.EqualError(s.T(), err, "2 of 2 files could not be migrated")
+	assert.Contains(s.T(), output, "✗ review.md - review.tmpl already exists, use --force to overwrite it\n")
+	content, err = os.ReadFile(filepath.Join(s.promptsDir, "review.tmpl"))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "edited", string(content))
+
+	_, err = s.migrate(migrateOptions{from: migrateFromFrontmatter, force: true})
+	require.NoError(s.T(), err)
+	content, err = os.ReadFile(filepath.Join(s.promptsDir, "review.tmpl"))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "{{/* Review a change */}}\n{{if .strict}}Be strict.\n{{end}}Review {{.diff}}", string(content))
+}
+
+// TestMigrateDryRun tests that a dry run prints the templates without writing them and reports failing files
+func (s *MigrateTestSuite) TestMigrateDryRun() {
+	s.writeSource("greet.txt", "Hello {{name}}")
+	s.writeSource("broken.txt", "{{#open}}never closed")
+
+	output, err := s.migrate(migrateOptions{from: migrateFromMustache, dryRun: true})
+	assert.EqualError(s.T(), err, "1 of 2 files could not be migrated")
+	assert.Equal(s.T(), "✗ broken.txt - Error: section \"open\" is not closed\n"+
+		"✓ greet.txt → greet.tmpl\nHello {{.name}}\n", output)
+	assert.NoDirExists(s.T(), s.promptsDir)
+
+	_, err = s.migrate(migrateOptions{from: "handlebars"})
+	assert.EqualError(s.T(), err, "invalid format \"handlebars\", must be one of: mustache, frontmatter")
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode/utf8"
+)
+
+// missingPartialError is a {{template}} call of a template that does not exist, e.g. after a partial was renamed.
+type missingPartialError struct {
+	name       string // name used in the call
+	file       string // file containing the call
+	line       int
+	suggestion string // closest existing template name, empty if none is close
+}
+
+func (e *missingPartialError) Error() string {
+	msg := fmt.Sprintf("referenced template %q not found at %s", e.name, e.location())
+	if e.suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %q?", e.suggestion)
+	}
+	return msg
+}
+
+func (e *missingPartialError) location() string {
+	return e.file + ":" + strconv.Itoa(e.line)
+}
+
+// newMissingPartialError describes the call of a missing template, suggesting the closest name defined in tmpl.
+func newMissingPartialError(tmpl *template.Template, node *parse.TemplateNode) *missingPartialError {
+	file, line := nodeLocation(node)
+	return &missingPartialError{
+		name: node.Name, file: file, line: line, suggestion: closestTemplateName(tmpl, node.Name),
+	}
+}
+
+// nodeLocation returns the file and the line of a parsed node.
+func nodeLocation(node parse.Node) (file string, line int) {
+	// Parsed nodes know their tree, so ErrorContext does not use its receiver
+	location, _ := (*parse.Tree)(nil).ErrorContext(node)
+	location = location[:strings.LastIndex(location, ":")] // drop the column
+	i := strings.LastIndex(location, ":")
+	line, _ = strconv.Atoi(location[i+1:])
+	return location[:i], line
+}
+
+// closestTemplateName returns the template defined in tmpl whose name is closest to name by edit distance,
+// ignoring the template extension, or an empty string if no name is within a third of the length of name.
+// The suggestion has the extension only if name has it, so it can replace name in the call.
+func closestTemplateName(tmpl *template.Template, name string) string {
+	trimmed := strings.TrimSuffix(name, templateExt)
+	candidates := make([]string, 0, len(tmpl.Templates()))
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			candidates = append(candidates, strings.TrimSuffix(t.Name(), templateExt))
+		}
+	}
+	sort.Strings(candidates)
+
+	best, bestDistance := "", max(1, utf8.RuneCountInString(trimmed)/3)+1
+	for _, candidate := range candidates {
+		if distance := editDistance(trimmed, candidate); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	if best != "" && strings.HasSuffix(name, templateExt) {
+		best += templateExt
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b in runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// missingPartialGroup is a missing template with all the calls of it.
+type missingPartialGroup struct {
+	name       string
+	suggestion string
+	locations  []string // "file:line" of the calls, sorted by file and line
+}
+
+// groupMissingPartials groups the errors by the missing name, so a renamed partial is reported once
+// with every place that still uses the old name. Groups are sorted by name.
+func groupMissingPartials(errs []*missingPartialError) []missingPartialGroup {
+	calls := make(map[string][]*missingPartialError)
+	for _, err := range errs {
+		if !slices.ContainsFunc(calls[err.name], func(call *missingPartialError) bool {
+			return call.location() == err.location()
+		}) {
+			calls[err.name] = append(calls[err.name], err)
+		}
+	}
+	groups := make([]missingPartialGroup, 0, len(calls))
+	for _, name := range slices.Sorted(maps.Keys(calls)) {
+		sort.Slice(calls[name], func(i, j int) bool {
+			if calls[name][i].file != calls[name][j].file {
+				return calls[name][i].file < calls[name][j].file
+			}
+			return calls[name][i].line < calls[name][j].line
+		})
+		group := missingPartialGroup{name: name, suggestion: calls[name][0].suggestion}
+		for _, call := range calls[name] {
+			group.locations = append(group.locations, call.location())
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type MissingPartialsTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestMissingPartialsTestSuite(t *testing.T) {
+	suite.Run(t, new(MissingPartialsTestSuite))
+}
+
+func (s *MissingPartialsTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	// _header.tmpl was renamed to _headers.tmpl, but its three dependents still call the old name
+	s.writeFile("_headers.tmpl", `{{define "_headers.tmpl"}}Header{{end}}`)
+	s.writeFile("commit.tmpl", "{{/* Commit */}}\n{{template \"_header.tmpl\" .}}\nCommit {{.type}}")
+	s.writeFile("review.tmpl", "---\ndescription: Review\n---\nReview {{.path}}\n\n{{template \"_header.tmpl\" .}}")
+	s.writeFile("summary.tmpl", "{{/* Summary */}}\n{{if .short}}\n  {{template \"_header.tmpl\" .}}\n{{end}}")
+	s.writeFile("plain.tmpl", "{{/* Plain */}}\nHello {{.name}}")
+}
+
+func (s *MissingPartialsTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+// TestValidateGroupsMissingPartials tests that a renamed partial is reported once, with the places calling it
+// and the closest existing name
+func (s *MissingPartialsTestSuite) TestValidateGroupsMissingPartials() {
+	var buf bytes.Buffer
+	err := validateTemplates(&buf, s.promptsDir, nil, "", validateOptions{})
+	require.Error(s.T(), err)
+	assert.Equal(s.T(), `✗ commit.tmpl - Error: missing partial "_header.tmpl", see below
+✓ plain.tmpl - Valid
+✗ review.tmpl - Error: missing partial "_header.tmpl", see below
+✗ summary.tmpl - Error: missing partial "_header.tmpl", see below
+✗ Missing partial "_header.tmpl", called in 3 place(s) - did you mean "_headers.tmpl"?
+    commit.tmpl:2
+    review.tmpl:6
+    summary.tmpl:3
+`, removeANSIColors(buf.String()))
+
+	buf.Reset()
+	err = validateTemplates(&buf, s.promptsDir, nil, "", validateOptions{failFast: true, jobs: 1})
+	require.Error(s.T(), err)
+	assert.Equal(s.T(), `✗ commit.tmpl - Error: referenced template "_header.tmpl" not found at commit.tmpl:2, `+
+		`did you mean "_headers.tmpl"?`+"\n", removeANSIColors(buf.String()))
+}
+
+// TestReloadError tests that the server reports the located missing partial with the suggestion
+func (s *MissingPartialsTestSuite) TestReloadError() {
+	_, err := NewPromptsServer(s.promptsDir, WithWatchMode(WatchModeOff, 0))
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(),
+		`referenced template "_header.tmpl" not found at commit.tmpl:2, did you mean "_headers.tmpl"?`)
+}
+
+// TestClosestTemplateName tests the suggestions for missing template names
+func (s *MissingPartialsTestSuite) TestClosestTemplateName() {
+	tmpl, err := (&PromptsParser{}).ParseDir(s.promptsDir)
+	require.NoError(s.T(), err)
+	for name, expected := range map[string]string{
+		"_header.tmpl": "_headers.tmpl",
+		"_heade":       "_headers",
+		"_Headers":     "_headers",
+		"revew.tmpl":   "review.tmpl",
+		"footer":       "",
+		"x":            "",
+	} {
+		assert.Equal(s.T(), expected, closestTemplateName(tmpl, name), name)
+	}
+	assert.Equal(s.T(), 3, editDistance("kitten", "sitting"))
+	assert.Equal(s.T(), 2, editDistance("straße", "strasse"), "distances count runes")
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"cmp"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// modelArgName is the reserved argument with the model the prompt is rendered for, e.g. "claude-sonnet-4".
+// It is never advertised; without it, the default model of the server is used.
+const modelArgName = "_model"
+
+// modelIsFuncName is the helper that tells templates whether the model matches a glob, e.g. {{if modelIs "claude*"}}.
+const modelIsFuncName = "modelIs"
+
+// modelOverrideMarker separates the name of the base template from the model pattern of an override file,
+// e.g. review.model-gpt.tmpl overrides review.tmpl for the gpt models.
+const modelOverrideMarker = ".model-"
+
+// modelOverride is a template file that replaces its base template for the models matching its pattern.
+type modelOverride struct {
+	pattern      string
+	templateName string
+}
+
+// modelOverrideOf returns the base template and the model pattern of an override file name,
+// and false if the file is not an override.
+func modelOverrideOf(fileName string) (base string, pattern string, ok bool) {
+	if strings.HasPrefix(fileName, "_") || !strings.HasSuffix(fileName, templateExt) {
+		return "", "", false
+	}
+	stem := strings.TrimSuffix(fileName, templateExt)
+	i := strings.LastIndex(stem, modelOverrideMarker)
+	if i <= 0 || i+len(modelOverrideMarker) == len(stem) {
+		return "", "", false
+	}
+	return stem[:i] + templateExt, stem[i+len(modelOverrideMarker):], true
+}
+
+// isModelOverrideFile reports whether the file name is of a model override, which is no prompt of its own.
+func isModelOverrideFile(fileName string) bool {
+	_, _, ok := modelOverrideOf(fileName)
+	return ok
+}
+
+// findModelOverrides returns the model overrides among the files by the name of their base template,
+// the most specific, i.e. longest, pattern first.
+func findModelOverrides(files []os.DirEntry) map[string][]modelOverride {
+	overrides := make(map[string][]modelOverride)
+	for _, file := range files {
+		if !file.Type().IsRegular() {
+			continue
+		}
+		if base, pattern, ok := modelOverrideOf(file.Name()); ok {
+			overrides[base] = append(overrides[base], modelOverride{pattern: pattern, templateName: file.Name()})
+		}
+	}
+	for _, baseOverrides := range overrides {
+		slices.SortFunc(baseOverrides, func(a, b modelOverride) int {
+			if n := cmp.Compare(len(b.pattern), len(a.pattern)); n != 0 {
+				return n
+			}
+			return strings.Compare(a.pattern, b.pattern)
+		})
+	}
+	return overrides
+}
+
+// readModelOverrides returns the model overrides of the prompts directory, like findModelOverrides.
+func readModelOverrides(promptsDir string) (map[string][]modelOverride, error) {
+	files, err := os.ReadDir(promptsDir)
+	if err != nil {
+		return nil, err
+	}
+	return findModelOverrides(files), nil
+}
+
+// selectModelVariant returns the template to render for the model: the first override whose pattern matches
+// the start of the model, e.g. "gpt" for "gpt-4o", or the base template if none does or the model is unknown.
+func selectModelVariant(templateName string, overrides []modelOverride, model string) string {
+	if model == "" {
+		return templateName
+	}
+	for _, override := range overrides {
+		if matchModel(override.pattern+"*", model) {
+			return override.templateName
+		}
+	}
+	return templateName
+}
+
+// modelPatterns returns the patterns of the overrides in the order they are tried.
+func modelPatterns(overrides []modelOverride) []string {
+	patterns := make([]string, 0, len(overrides))
+	for _, override := range overrides {
+		patterns = append(patterns, override.pattern)
+	}
+	return patterns
+}
+
+// variantTemplates returns the template and its model overrides, which are all the templates the prompt may render.
+func variantTemplates(templateName string, overrides []modelOverride) []string {
+	templates := []string{templateName}
+	for _, override := range overrides {
+		templates = append(templates, override.templateName)
+	}
+	return templates
+}
+
+// matchModel reports whether the model name matches the glob, ignoring case. A "*" matches any text,
+// including the "/" of provider prefixes like "openai/gpt-4o", and a "?" matches a single character.
+func matchModel(pattern, model string) bool {
+	pattern, model = strings.ToLower(pattern), strings.ToLower(model)
+	// On a mismatch after a "*", the star is retried matching one more character of the model
+	star, starModel := -1, 0
+	p, m := 0, 0
+	for m < len(model) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				star, starModel = p, m
+				p++
+				continue
+			case '?':
+				_, size := utf8.DecodeRuneInString(model[m:])
+				p, m = p+1, m+size
+				continue
+			default:
+				if pattern[p] == model[m] {
+					p, m = p+1, m+1
+					continue
+				}
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		_, size := utf8.DecodeRuneInString(model[starModel:])
+		starModel += size
+		p, m = star+1, starModel
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// makeModelIsFunc returns the modelIs helper for the model, which is true if the model matches any of the globs.
+// It is always false without a model.
+func makeModelIsFunc(model string) func(patterns ...string) bool {
+	return func(patterns ...string) bool {
+		return model != "" && slices.ContainsFunc(patterns, func(pattern string) bool {
+			return matchModel(pattern, model)
+		})
+	}
+}
+
+// extractVariantArguments returns the arguments of the template and of its model overrides, in the order
+// of their first use in the template and then in the overrides, so that every variant can be given its arguments.
+func (pp *PromptsParser) extractVariantArguments(
+	tmpl *template.Template, templateName string, overrides []modelOverride,
+) ([]string, error) {
+	var args []string
+	for _, variant := range variantTemplates(templateName, overrides) {
+		variantArgs, err := pp.ExtractPromptArgumentsFromTemplate(tmpl, variant)
+		if err != nil {
+			return nil, err
+		}
+		for _, arg := range variantArgs {
+			if !slices.Contains(args, arg) {
+				args = append(args, arg)
+			}
+		}
+	}
+	return args, nil
+}
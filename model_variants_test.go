@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ModelVariantsTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestModelVariantsTestSuite(t *testing.T) {
+	suite.Run(t, new(ModelVariantsTestSuite))
+}
+
+func (s *ModelVariantsTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("review.tmpl", "{{/* Review code */}}\n"+
+		`{{if modelIs "claude*" "*/claude*"}}<code>{{.code}}</code>{{else}}Code: {{.code}}{{end}}`)
+	s.writeFile("review.model-gpt.tmpl", "GPT review of {{.code}} in {{.language}}")
+	s.writeFile("review.model-gpt-4o-mini.tmpl", "Mini review of {{.code}}")
+}
+
+func (s *ModelVariantsTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+func (s *ModelVariantsTestSuite) newServer(opts ...Option) *PromptsServer {
+	promptsServer, err := NewPromptsServer(s.promptsDir, append(opts, WithWatchMode(WatchModeOff, 0))...)
+	require.NoError(s.T(), err)
+	s.T().Cleanup(func() { s.Require().NoError(promptsServer.Close()) })
+	return promptsServer
+}
+
+func (s *ModelVariantsTestSuite) getPrompt(promptsServer *PromptsServer, args map[string]any) string {
+	request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "prompts/get",
+		"params": map[string]any{"name": "review", "arguments": args}})
+	require.NoError(s.T(), err)
+	response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok, "review must render with %v", args)
+	return response.Result.(mcp.GetPromptResult).Messages[0].Content.(mcp.TextContent).Text
+}
+
+// TestMatchModel tests the globs of modelIs against the shapes of model names of several providers
+func (s *ModelVariantsTestSuite) TestMatchModel() {
+	for _, tc := range []struct {
+		pattern  string
+		model    string
+		expected bool
+	}{
+		{pattern: "claude*", model: "claude-sonnet-4-20250514", expected: true},
+		{pattern: "claude*", model: "Claude-3.5-Sonnet", expected: true},
+		{pattern: "claude*", model: "anthropic/claude-3-opus", expected: false},
+		{pattern: "*/claude*", model: "anthropic/claude-3-opus", expected: true},
+		{pattern: "*claude*sonnet*", model: "us.anthropic.claude-3-7-sonnet-20250219-v1:0", expected: true},
+		{pattern: "claude-*@*", model: "claude-opus-4@20250514", expected: true},
+		{pattern: "gpt-4o", model: "gpt-4o-mini", expected: false},
+		{pattern: "gpt-4?", model: "gpt-4o", expected: true},
+		{pattern: "gpt-4?", model: "gpt-4", expected: false},
+		{pattern: "gpt-*-mini", model: "gpt-4o-mini", expected: true},
+		{pattern: "models/gemini-*-pro", model: "models/gemini-1.5-pro", expected: true},
+		{pattern: "models/gemini-*-pro", model: "models/gemini-1.5-pro-002", expected: false},
+		{pattern: "llama*instruct", model: "meta-llama/Llama-3.1-8B-Instruct", expected: false},
+		{pattern: "*llama*instruct", model: "meta-llama/Llama-3.1-8B-Instruct", expected: true},
+		{pattern: "*", model: "o3", expected: true},
+		{pattern: "", model: "o3", expected: false},
+	} {
+		assert.Equal(s.T(), tc.expected, matchModel(tc.pattern, tc.model), "%q against %q", tc.pattern, tc.model)
+	}
+
+	modelIs := makeModelIsFunc("")
+	assert.False(s.T(), modelIs("*"), "no pattern matches an unknown model")
+}
+
+// TestModelOverrideOf tests which file names are model overrides
+func (s *ModelVariantsTestSuite) TestModelOverrideOf() {
+	for name, expected := range map[string][2]string{
+		"review.model-gpt.tmpl":     {"review.tmpl", "gpt"},
+		"review.model-gpt-4.1.tmpl": {"review.tmpl", "gpt-4.1"},
+		"a.b.model-claude*.tmpl":    {"a.b.tmpl", "claude*"},
+	} {
+		base, pattern, ok := modelOverrideOf(name)
+		require.True(s.T(), ok, name)
+		assert.Equal(s.T(), expected, [2]string{base, pattern}, name)
+	}
+	for _, name := range []string{"review.tmpl", "review.model-.tmpl", ".model-gpt.tmpl", "_footer.model-gpt.tmpl", "review.model-gpt.txt"} {
+		_, _, ok := modelOverrideOf(name)
+		assert.False(s.T(), ok, name)
+	}
+}
+
+// TestServeVariants tests that the model of the request selects the most specific override, and that
+// the base template is rendered for models without an override
+func (s *ModelVariantsTestSuite) TestServeVariants() {
+	promptsServer := s.newServer(WithStrictUnknownArgs(true))
+	assert.Equal(s.T(), []string{"review"}, promptsServer.LoadSummary().Prompts, "overrides are no prompts of their own")
+
+	request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "prompts/list", "params": map[string]any{}})
+	require.NoError(s.T(), err)
+	response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok)
+	var names []string
+	for _, arg := range response.Result.(mcp.ListPromptsResult).Prompts[0].Arguments {
+		names = append(names, arg.Name)
+	}
+	assert.Equal(s.T(), []string{"code", "language"}, names, "the arguments of the overrides are advertised, _model is not")
+
+	for model, expected := range map[string]string{
+		"":                         "Code: x",
+		"claude-sonnet-4":          "<code>x</code>",
+		"openrouter/claude-3-opus": "<code>x</code>",
+		"gpt-4o":                   "GPT review of x in go",
+		"GPT-4O-MINI-2024-07-18":   "Mini review of x",
+		"llama-3":                  "Code: x",
+	} {
+		args := map[string]any{"code": "x", "language": "go"}
+		if model != "" {
+			args[modelArgName] = model
+		}
+		assert.Equal(s.T(), expected, s.getPrompt(promptsServer, args), model)
+	}
+}
+
+// TestDefaultModel tests that the default model applies to requests without _model only
+func (s *ModelVariantsTestSuite) TestDefaultModel() {
+	promptsServer := s.newServer(WithDefaultModel("gpt-5"))
+	assert.Equal(s.T(), "GPT review of x in go", s.getPrompt(promptsServer, map[string]any{"code": "x", "language": "go"}))
+	assert.Equal(s.T(), "<code>x</code>", s.getPrompt(promptsServer, map[string]any{"code": "x", modelArgName: "claude-opus-4"}))
+}
+
+// TestOrphanOverride tests that an override without a base template is ignored with a warning
+func (s *ModelVariantsTestSuite) TestOrphanOverride() {
+	s.writeFile("summary.model-gpt.tmpl", "Summary of {{.text}}")
+	summary := s.newServer().LoadSummary()
+	assert.Equal(s.T(), []string{"review"}, summary.Prompts)
+	assert.Contains(s.T(), summary.Warnings, "Ignoring model overrides without a base template template=summary.tmpl models=[gpt]")
+}
+
+// TestListAndRender tests that list --verbose shows the overrides and that render selects them by _model
+func (s *ModelVariantsTestSuite) TestListAndRender() {
+	var buf bytes.Buffer
+	require.NoError(s.T(), listTemplates(&buf, s.promptsDir, listOptions{}))
+	assert.Equal(s.T(), "review.tmpl\n", removeANSIColors(buf.String()))
+
+	buf.Reset()
+	require.NoError(s.T(), listTemplates(&buf, s.promptsDir, listOptions{verbose: true}))
+	assert.Contains(s.T(), removeANSIColors(buf.String()), "review.tmpl\n"+
+		"  Description: Review code\n"+
+		"  Variables: code, language\n"+
+		"  Model overrides: gpt-4o-mini, gpt\n")
+
+	for model, expected := range map[string]string{"": "Code: x", "gpt-4.1": "GPT review of x in go", "claude-3-haiku": "<code>x</code>"} {
+		buf.Reset()
+		args := map[string]string{"code": "x", "language": "go"}
+		if model != "" {
+			args[modelArgName] = model
+		}
+		require.NoError(s.T(), renderTemplate(&buf, s.promptsDir, nil, "review", args, true))
+		assert.Equal(s.T(), expected, buf.String(), model)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// NameStyle selects how prompt names are derived from the names of the template files.
+type NameStyle string
+
+const (
+	NameStyleAsIs  NameStyle = "as-is" // the file name without the extension
+	NameStyleKebab NameStyle = "kebab" // lowercase, with underscores replaced by hyphens
+	NameStyleSnake NameStyle = "snake" // lowercase, with hyphens replaced by underscores
+)
+
+// ParseNameStyle parses a name style as accepted by the --name-style flag.
+func ParseNameStyle(s string) (NameStyle, error) {
+	switch style := NameStyle(strings.ToLower(strings.TrimSpace(s))); style {
+	case NameStyleAsIs, NameStyleKebab, NameStyleSnake:
+		return style, nil
+	}
+	return "", fmt.Errorf("unknown name style %q (expected as-is, kebab, or snake)", s)
+}
+
+// PromptName returns the name of the prompt of the template file, given with or without the extension.
+// The zero value keeps the file name, like NameStyleAsIs.
+func (ns NameStyle) PromptName(templateName string) string {
+	name := strings.TrimSuffix(templateName, templateExt)
+	switch ns {
+	case NameStyleKebab:
+		return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+	case NameStyleSnake:
+		return strings.ReplaceAll(strings.ToLower(name), "-", "_")
+	}
+	return name
+}
+
+// resolveTemplateName returns the template file of the prompt named in the name style,
+// e.g. "git_commit.tmpl" for "git-commit" in the kebab style. File names, with or without the extension,
+// are returned unchanged, as are names of no prompt, so callers report them as not found.
+func resolveTemplateName(promptsDir string, name string, style NameStyle) (string, error) {
+	availableTemplates, err := getAvailableTemplates(promptsDir)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasSuffix(name, templateExt) || slices.Contains(availableTemplates, name+templateExt) {
+		return name, nil
+	}
+	for _, templateName := range availableTemplates {
+		if style.PromptName(templateName) == name {
+			return templateName, nil
+		}
+	}
+	return name, nil
+}
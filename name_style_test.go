@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type NameStyleTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestNameStyleTestSuite(t *testing.T) {
+	suite.Run(t, new(NameStyleTestSuite))
+}
+
+func (s *NameStyleTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("git_commit.tmpl", "{{/* Commit */}}\nCommit {{.type}}")
+	s.writeFile("Review-Code.tmpl", "{{/* Review */}}\nReview {{.path}}")
+	s.writeFile("collections.yaml", "collections:\n  - name: Git\n    prompts:\n      - Review-Code\n      - git_commit\n")
+}
+
+func (s *NameStyleTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+func (s *NameStyleTestSuite) handle(promptsServer *PromptsServer, method string, params map[string]any) mcp.JSONRPCMessage {
+	request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	require.NoError(s.T(), err)
+	return promptsServer.mcpServer.HandleMessage(context.Background(), request)
+}
+
+// TestPromptName tests the names derived from template file names in every style
+func (s *NameStyleTestSuite) TestPromptName() {
+	for _, tc := range []struct {
+		style    NameStyle
+		file     string
+		expected string
+	}{
+		{style: "", file: "Git_Commit.tmpl", expected: "Git_Commit"},
+		{style: NameStyleAsIs, file: "Git_Commit.tmpl", expected: "Git_Commit"},
+		{style: NameStyleKebab, file: "Git_Commit.tmpl", expected: "git-commit"},
+		{style: NameStyleKebab, file: "code-review_v2", expected: "code-review-v2"},
+		{style: NameStyleSnake, file: "Code-Review.tmpl", expected: "code_review"},
+	} {
+		assert.Equal(s.T(), tc.expected, tc.style.PromptName(tc.file), "%s %s", tc.style, tc.file)
+	}
+
+	style, err := ParseNameStyle(" Kebab ")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), NameStyleKebab, style)
+	_, err = ParseNameStyle("camel")
+	require.EqualError(s.T(), err, `unknown name style "camel" (expected as-is, kebab, or snake)`)
+}
+
+// TestServerNames tests that prompts are registered, ordered by collection, and resolvable with the styled names
+func (s *NameStyleTestSuite) TestServerNames() {
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithNameStyle(NameStyleKebab), WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	response, ok := s.handle(promptsServer, "prompts/list", map[string]any{}).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok)
+	var names, descriptions []string
+	for _, prompt := range response.Result.(mcp.ListPromptsResult).Prompts {
+		names = append(names, prompt.Name)
+		descriptions = append(descriptions, prompt.Description)
+	}
+	assert.Equal(s.T(), []string{"review-code", "git-commit"}, names, "collection entries must match the styled names")
+	assert.Equal(s.T(), []string{"Git: review-code - Review", "Git: git-commit - Commit"}, descriptions)
+
+	response, ok = s.handle(promptsServer, "prompts/get",
+		map[string]any{"name": "git-commit", "arguments": map[string]string{"type": "fix"}}).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), "Commit fix",
+		response.Result.(mcp.GetPromptResult).Messages[0].Content.(mcp.TextContent).Text)
+
+	_, ok = s.handle(promptsServer, "prompts/get", map[string]any{"name": "git_commit"}).(mcp.JSONRPCError)
+	assert.True(s.T(), ok, "the file name is not a prompt name in the kebab style")
+
+	s.writeFile("git-commit.tmpl", "{{/* Another commit */}}\nCommit")
+	_, err = NewPromptsServer(s.promptsDir, WithNameStyle(NameStyleKebab), WithWatchMode(WatchModeOff, 0))
+	require.ErrorContains(s.T(), err, `templates "git-commit.tmpl" and "git_commit.tmpl" have the same prompt name "git-commit"`)
+}
+
+// TestCLIResolvesNames tests that render and validate accept both the styled names and the file names
+func (s *NameStyleTestSuite) TestCLIResolvesNames() {
+	run := func(args ...string) (string, error) {
+		var buf bytes.Buffer
+		app := newApp()
+		app.Writer = &buf
+		err := app.Run(context.Background(),
+			append([]string{app.Name, "--color", "never", "--prompts", s.promptsDir, "--name-style", "snake"}, args...))
+		return buf.String(), err
+	}
+
+	output, err := run("render", "review_code", "--arg", "path=main.go")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Review main.go", output)
+	output, err = run("render", "Review-Code", "--arg", "path=main.go")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Review main.go", output)
+
+	output, err = run("validate", "review_code")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "✓ Review-Code.tmpl - Valid\n", output)
+
+	s.writeFile("collections.yaml", "collections:\n  - name: Git\n    prompts: [review_code, git-commit, git_push]\n")
+	output, err = run("validate")
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), output, "✓ Review-Code.tmpl - Valid\n✓ git_commit.tmpl - Valid\n")
+	assert.Contains(s.T(), output, `✗ collections.yaml - Error: entry "Git/git_push" references a nonexistent template`,
+		"entries are matched in the name style")
+	assert.NotContains(s.T(), output, "review_code")
+
+	_, err = run("render", "review-code")
+	require.ErrorContains(s.T(), err, "template review-code.tmpl not found")
+
+	_, err = run("--name-style", "camel", "list")
+	require.Error(s.T(), err)
+}
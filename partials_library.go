@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// libraryPartial is a partial template found in one of the shared partials directories.
+type libraryPartial struct {
+	fileName   string
+	dir        string
+	overridden bool // a partial with the same file name in the prompts directory takes precedence
+	// shadowedBy is the earlier partials directory with the same file name, which takes precedence, empty if none
+	shadowedBy string
+}
+
+// isPartialFile reports whether the directory entry is a partial template file ("_*.tmpl").
+func isPartialFile(file os.DirEntry) bool {
+	return file.Type().IsRegular() && strings.HasSuffix(file.Name(), templateExt) && strings.HasPrefix(file.Name(), "_")
+}
+
+// findLibraryPartials lists the partial templates of the shared partials directories, in the order of the directories.
+// Partials of the prompts directory override library partials of the same file name,
+// and of the same file name in two partials directories, the first match wins.
+func findLibraryPartials(promptsDir string, partialsDirs []string) ([]libraryPartial, error) {
+	if len(partialsDirs) == 0 {
+		return nil, nil
+	}
+	localFiles, err := os.ReadDir(promptsDir)
+	if err != nil {
+		return nil, fmt.Errorf("read prompts directory: %w", err)
+	}
+	localPartials := make(map[string]struct{})
+	for _, file := range localFiles {
+		if isPartialFile(file) {
+			localPartials[file.Name()] = struct{}{}
+		}
+	}
+
+	var partials []libraryPartial
+	partialDirs := make(map[string]string)
+	for _, dir := range partialsDirs {
+		var files []os.DirEntry
+		if files, err = os.ReadDir(dir); err != nil {
+			return nil, fmt.Errorf("read partials directory: %w", err)
+		}
+		for _, file := range files {
+			if !isPartialFile(file) {
+				continue
+			}
+			_, overridden := localPartials[file.Name()]
+			partial := libraryPartial{fileName: file.Name(), dir: dir, overridden: overridden}
+			if firstDir, exists := partialDirs[file.Name()]; exists {
+				partial.shadowedBy = firstDir
+			} else {
+				partialDirs[file.Name()] = dir
+			}
+			partials = append(partials, partial)
+		}
+	}
+	return partials, nil
+}
+
+// partialSourceDir returns the directory of the file that defines the partial: a partials directory
+// for library partials, the prompts directory otherwise.
+func partialSourceDir(tmpl *template.Template, partialName string, promptsDir string, partials []libraryPartial) string {
+	partialTmpl := tmpl.Lookup(partialName)
+	if partialTmpl == nil || partialTmpl.Tree == nil {
+		return promptsDir
+	}
+	for _, partial := range partials {
+		if !partial.overridden && partial.shadowedBy == "" && partial.fileName == partialTmpl.Tree.ParseName {
+			return partial.dir
+		}
+	}
+	return promptsDir
+}
+
+// formatPartialSources lists the partials with the directories they come from, e.g. "_footer (prompts), _header (lib)".
+func formatPartialSources(tmpl *template.Template, partialNames []string, promptsDir string, partials []libraryPartial) string {
+	sources := make([]string, 0, len(partialNames))
+	for _, name := range partialNames {
+		sources = append(sources, fmt.Sprintf("%s (%s)", name, partialSourceDir(tmpl, name, promptsDir, partials)))
+	}
+	return strings.Join(sources, ", ")
+}
+
+// path returns the path of the partial file.
+func (p libraryPartial) path() string {
+	return filepath.Join(p.dir, p.fileName)
+}
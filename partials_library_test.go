@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type PartialsLibraryTestSuite struct {
+	suite.Suite
+	promptsDir string
+	libDir     string
+	extraDir   string
+}
+
+func TestPartialsLibraryTestSuite(t *testing.T) {
+	suite.Run(t, new(PartialsLibraryTestSuite))
+}
+
+func (s *PartialsLibraryTestSuite) SetupTest() {
+	tempDir := s.T().TempDir()
+	s.promptsDir = filepath.Join(tempDir, "prompts")
+	s.libDir = filepath.Join(tempDir, "lib")
+	s.extraDir = filepath.Join(tempDir, "extra")
+	for _, dir := range []string{s.promptsDir, s.libDir, s.extraDir} {
+		require.NoError(s.T(), os.Mkdir(dir, 0755))
+	}
+
+	s.writeFile(s.libDir, "_role.tmpl", `{{define "_role"}}You are a {{.role}}.{{end}}`)
+	s.writeFile(s.libDir, "_footer.tmpl", `{{define "_footer"}}Library footer.{{end}}`)
+	s.writeFile(s.libDir, "review.tmpl", "{{/* Not a partial */}}\nIgnored")
+	s.writeFile(s.extraDir, "_signature.tmpl", `{{define "_signature"}}-- {{.team}}{{end}}`)
+	s.writeFile(s.promptsDir, "_footer.tmpl", `{{define "_footer"}}Local footer.{{end}}`)
+	s.writeFile(s.promptsDir, "review.tmpl",
+		"{{/* Review code */}}\n{{template \"_role\" .}}\n{{template \"_footer\" .}}\n{{template \"_signature\" .}}")
+}
+
+func (s *PartialsLibraryTestSuite) writeFile(dir, name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
+
+// TestRenderWithLibraryPartials tests that library partials are resolved and local partials override them
+func (s *PartialsLibraryTestSuite) TestRenderWithLibraryPartials() {
+	var buf bytes.Buffer
+	err := renderTemplate(&buf, s.promptsDir, []string{s.libDir, s.extraDir}, "review",
+		map[string]string{"role": "reviewer", "team": "Platform"}, true)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "You are a reviewer.\nLocal footer.\n-- Platform", buf.String())
+
+	err = renderTemplate(&bytes.Buffer{}, s.promptsDir, nil, "review", nil, true)
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), `referenced template "_role" not found`)
+}
+
+// TestFindLibraryPartials tests discovery, override detection, and collisions between library directories
+func (s *PartialsLibraryTestSuite) TestFindLibraryPartials() {
+	partials, err := findLibraryPartials(s.promptsDir, []string{s.libDir, s.extraDir})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []libraryPartial{
+		{fileName: "_footer.tmpl", dir: s.libDir, overridden: true},
+		{fileName: "_role.tmpl", dir: s.libDir},
+		{fileName: "_signature.tmpl", dir: s.extraDir},
+	}, partials)
+
+	s.writeFile(s.extraDir, "_role.tmpl", `{{define "_role"}}Other role{{end}}`)
+	partials, err = findLibraryPartials(s.promptsDir, []string{s.libDir, s.extraDir})
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), partials, libraryPartial{fileName: "_role.tmpl", dir: s.extraDir, shadowedBy: s.libDir})
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), renderTemplate(&buf, s.promptsDir, []string{s.libDir, s.extraDir}, "review",
+		map[string]string{"role": "reviewer", "team": "Platform"}, true))
+	assert.Contains(s.T(), buf.String(), "You are a reviewer.", "the first directory wins")
+	buf.Reset()
+	require.NoError(s.T(), renderTemplate(&buf, s.promptsDir, []string{s.extraDir, s.libDir}, "review", nil, true))
+	assert.Contains(s.T(), buf.String(), "Other role")
+
+	_, err = findLibraryPartials(s.promptsDir, []string{filepath.Join(s.libDir, "missing")})
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "read partials directory")
+}
+
+// TestPartialsPath tests that partials are resolved from the directories of --partials-path in order
+func (s *PartialsLibraryTestSuite) TestPartialsPath() {
+	s.writeFile(s.extraDir, "_role.tmpl", `{{define "_role"}}Shadowed role{{end}}`)
+	partialsPath := strings.Join([]string{s.libDir, s.extraDir}, string(filepath.ListSeparator))
+
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	require.NoError(s.T(), app.Run(context.Background(), []string{app.Name, "--color", "never",
+		"--prompts", s.promptsDir, "--partials-path", partialsPath, "render", "review", "-a", "role=reviewer", "-a", "team=Core"}))
+	assert.Equal(s.T(), "You are a reviewer.\nLocal footer.\n-- Core", buf.String())
+
+	buf.Reset()
+	app = newApp()
+	app.Writer = &buf
+	require.NoError(s.T(), app.Run(context.Background(), []string{app.Name, "--color", "never",
+		"--prompts", s.promptsDir, "--partials-dir", s.extraDir, "--partials-path", s.libDir, "validate"}))
+	assert.Contains(s.T(), buf.String(), "⚠ "+filepath.Join(s.libDir, "_role.tmpl")+
+		" - Overridden by "+filepath.Join(s.extraDir, "_role.tmpl"), "--partials-dir is searched first")
+	assert.Contains(s.T(), buf.String(), "✓ "+filepath.Join(s.extraDir, "_signature.tmpl")+" - Valid")
+}
+
+// TestListAndValidateAttributePartials tests that list and validate show the directory each partial comes from
+func (s *PartialsLibraryTestSuite) TestListAndValidateAttributePartials() {
+	partialsDirs := []string{s.libDir, s.extraDir}
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), listTemplates(&buf, s.promptsDir, listOptions{verbose: true, partialsDirs: partialsDirs}))
+	output := removeANSIColors(buf.String())
+	assert.Contains(s.T(), output,
+		"Partials: _footer ("+s.promptsDir+"), _role ("+s.libDir+"), _signature ("+s.extraDir+")")
+	assert.NotContains(s.T(), output, "Ignored")
+
+	buf.Reset()
+	require.NoError(s.T(), validateTemplates(&buf, s.promptsDir, partialsDirs, "", validateOptions{}))
+	output = removeANSIColors(buf.String())
+	assert.Contains(s.T(), output, "✓ review.tmpl - Valid")
+	assert.Contains(s.T(), output, "⚠ "+filepath.Join(s.libDir, "_footer.tmpl")+
+		" - Overridden by "+filepath.Join(s.promptsDir, "_footer.tmpl"))
+	assert.Contains(s.T(), output, "✓ "+filepath.Join(s.libDir, "_role.tmpl")+" - Valid")
+	assert.Contains(s.T(), output, "✓ "+filepath.Join(s.extraDir, "_signature.tmpl")+" - Valid")
+}
+
+// TestServerWithLibraryPartials tests that library partials are not registered as prompts,
+// overrides are logged, and changes in library directories are reloaded
+func (s *PartialsLibraryTestSuite) TestServerWithLibraryPartials() {
+	var logs syncBuffer
+	promptsServer, err := NewPromptsServer(s.promptsDir,
+		WithPartialsDirs(s.libDir, s.extraDir), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+	assert.Contains(s.T(), strings.Join(logs.Lines(), "\n"),
+		`msg="Local partial overrides library partial" partial=_footer.tmpl library_dir=`+s.libDir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		promptsServer.startWatcher(ctx)
+	}()
+
+	listPrompts := []byte(`{"jsonrpc":"2.0","id":1,"method":"prompts/list"}`)
+	response, err := json.Marshal(promptsServer.mcpServer.HandleMessage(ctx, listPrompts))
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), string(response), `"name":"review"`)
+	assert.NotContains(s.T(), string(response), `"name":"_role"`)
+	assert.NotContains(s.T(), string(response), "Not a partial")
+
+	s.writeFile(s.libDir, "_role.tmpl", `{{define "_role"}}You are an expert {{.role}}.{{end}}`)
+
+	getPrompt := []byte(`{"jsonrpc":"2.0","id":2,"method":"prompts/get",` +
+		`"params":{"name":"review","arguments":{"role":"reviewer","team":"Platform"}}}`)
+	require.Eventually(s.T(), func() bool {
+		getResponse, marshalErr := json.Marshal(promptsServer.mcpServer.HandleMessage(ctx, getPrompt))
+		return marshalErr == nil && strings.Contains(string(getResponse), "You are an expert reviewer.")
+	}, 2*time.Second, 10*time.Millisecond, "server should reload the changed library partial")
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Kinds of prompt arguments, inferred from how the template uses them. They choose the input of the preview render form.
+const (
+	argumentKindText    = "text"
+	argumentKindBoolean = "boolean" // only used as a condition
+	argumentKindObject  = "object"  // has fields or is ranged over, entered as JSON
+)
+
+// previewMaxRequestSize bounds the body of a preview render request.
+const previewMaxRequestSize = 1 << 20
+
+//go:embed preview
+var previewFS embed.FS
+
+var previewTemplates = htmltemplate.Must(htmltemplate.ParseFS(previewFS, "preview/*.html"))
+
+// previewPrompt describes a prompt in the web preview.
+type previewPrompt struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Title       string            `json:"title,omitempty"`
+	Collection  string            `json:"collection,omitempty"`
+	Deprecated  string            `json:"deprecated,omitempty"`
+	Arguments   []previewArgument `json:"arguments"`
+	Tokens      int               `json:"estimated_tokens,omitempty"` // of the static text, 0 if it cannot be rendered
+	Source      string            `json:"source,omitempty"`           // template file content, only in prompt details
+}
+
+// previewArgument is an argument of a prompt with the kind of input it takes.
+type previewArgument struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+// previewRenderRequest is the body of a preview render request.
+type previewRenderRequest struct {
+	Arguments map[string]string `json:"arguments"`
+}
+
+// previewRenderResponse is the result of a preview render request.
+type previewRenderResponse struct {
+	Text  string `json:"text,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// errPreviewPromptNotFound is returned for prompts the server does not list.
+var errPreviewPromptNotFound = errors.New("prompt not found")
+
+// PreviewHandler returns the handler of the web preview, which lists the prompts of the server and renders them
+// through the MCP handlers, so previews match what clients get, including limits and safe mode.
+// Requests must name the host of listenAddr, localhost, or a loopback address, see previewGuard.
+func (ps *PromptsServer) PreviewHandler(listenAddr string) http.Handler {
+	static, err := fs.Sub(previewFS, "preview")
+	if err != nil {
+		panic(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+	mux.HandleFunc("GET /{$}", ps.handlePreviewIndex)
+	mux.HandleFunc("GET /prompts/{name}", ps.handlePreviewPromptPage)
+	mux.HandleFunc("GET /api/prompts", ps.handlePreviewListPrompts)
+	mux.HandleFunc("GET /api/prompts/{name}", ps.handlePreviewGetPrompt)
+	mux.HandleFunc("POST /api/prompts/{name}/render", ps.handlePreviewRender)
+	return previewGuard(mux, listenAddr)
+}
+
+// previewGuard rejects the requests that web pages of other sites can make through the browser of a user:
+// requests whose Host is not the preview's, as sent after DNS rebinding, and cross-origin requests other than
+// GET. The host of listenAddr, localhost, and loopback addresses are accepted, and any IP address if listenAddr
+// has an unspecified host, e.g. 0.0.0.0. Requests without an Origin header, e.g. from curl, are not cross-origin.
+func previewGuard(next http.Handler, listenAddr string) http.Handler {
+	listenHost, _, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		listenHost = listenAddr
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !previewHostAllowed(r.Host, listenHost) {
+			http.Error(w, fmt.Sprintf("host %q is not allowed", r.Host), http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if originURL, err := url.Parse(origin); err != nil || !strings.EqualFold(originURL.Host, r.Host) {
+				http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// previewHostAllowed reports whether the Host of a request names the preview listening on listenHost.
+func previewHostAllowed(host string, listenHost string) bool {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "" {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") || strings.EqualFold(host, listenHost) {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	listenIP := net.ParseIP(listenHost)
+	return ip.IsLoopback() || (listenIP != nil && listenIP.IsUnspecified())
+}
+
+// ServePreview serves the web preview on the listener until the context is done.
+// The listen address is the host and port the preview was configured with, which requests must name.
+func (ps *PromptsServer) ServePreview(ctx context.Context, listener net.Listener, listenAddr string) error {
+	srv := &http.Server{Handler: ps.PreviewHandler(listenAddr), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// previewListenAddr returns the address to listen on for the --preview-addr value, using the loopback
+// interface if the value has no host, and reports whether the address is bound to loopback only.
+func previewListenAddr(addr string) (string, bool, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid preview address %q: %w", addr, err)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if host == "localhost" {
+		return net.JoinHostPort(host, port), true, nil
+	}
+	ip := net.ParseIP(host)
+	return net.JoinHostPort(host, port), ip != nil && ip.IsLoopback(), nil
+}
+
+func (ps *PromptsServer) handlePreviewIndex(w http.ResponseWriter, r *http.Request) {
+	prompts, err := ps.previewPrompts(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query != "" {
+		prompts = slices.DeleteFunc(prompts, func(prompt previewPrompt) bool {
+			return !strings.Contains(strings.ToLower(prompt.Name+" "+prompt.Description), strings.ToLower(query))
+		})
+	}
+	ps.writePreviewPage(w, "index.html", map[string]any{"Prompts": prompts, "Query": query})
+}
+
+func (ps *PromptsServer) handlePreviewPromptPage(w http.ResponseWriter, r *http.Request) {
+	prompt, err := ps.previewPromptDetails(r.Context(), r.PathValue("name"))
+	if errors.Is(err, errPreviewPromptNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ps.writePreviewPage(w, "prompt.html", map[string]any{
+		"Prompt": prompt, "RenderURL": "/api/prompts/" + url.PathEscape(prompt.Name) + "/render",
+	})
+}
+
+func (ps *PromptsServer) handlePreviewListPrompts(w http.ResponseWriter, r *http.Request) {
+	prompts, err := ps.previewPrompts(r.Context())
+	if err != nil {
+		writePreviewJSON(w, http.StatusInternalServerError, previewRenderResponse{Error: err.Error()})
+		return
+	}
+	writePreviewJSON(w, http.StatusOK, map[string]any{"prompts": prompts})
+}
+
+func (ps *PromptsServer) handlePreviewGetPrompt(w http.ResponseWriter, r *http.Request) {
+	prompt, err := ps.previewPromptDetails(r.Context(), r.PathValue("name"))
+	switch {
+	case errors.Is(err, errPreviewPromptNotFound):
+		writePreviewJSON(w, http.StatusNotFound, previewRenderResponse{Error: err.Error()})
+	case err != nil:
+		writePreviewJSON(w, http.StatusInternalServerError, previewRenderResponse{Error: err.Error()})
+	default:
+		writePreviewJSON(w, http.StatusOK, prompt)
+	}
+}
+
+// handlePreviewRender renders a prompt like a prompts/get request of an MCP client.
+func (ps *PromptsServer) handlePreviewRender(w http.ResponseWriter, r *http.Request) {
+	var request previewRenderRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, previewMaxRequestSize)).Decode(&request); err != nil {
+		writePreviewJSON(w, http.StatusBadRequest, previewRenderResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	result, rpcErr := ps.handleMCPRequest(r.Context(), string(mcp.MethodPromptsGet), map[string]any{
+		"name": r.PathValue("name"), "arguments": request.Arguments,
+	})
+	if rpcErr != nil {
+		status := http.StatusUnprocessableEntity
+		if rpcErr.Code == mcp.INVALID_PARAMS {
+			status = http.StatusNotFound
+		}
+		writePreviewJSON(w, status, previewRenderResponse{Error: rpcErr.Message})
+		return
+	}
+	var texts []string
+	for _, message := range result.(mcp.GetPromptResult).Messages {
+		if content, ok := message.Content.(mcp.TextContent); ok {
+			texts = append(texts, content.Text)
+		}
+	}
+	writePreviewJSON(w, http.StatusOK, previewRenderResponse{Text: strings.Join(texts, "\n\n")})
+}
+
+// handleMCPRequest handles a JSON-RPC request as if an MCP client sent it.
+func (ps *PromptsServer) handleMCPRequest(ctx context.Context, method string, params any) (any, *mcp.JSONRPCErrorDetails) {
+	request, err := json.Marshal(map[string]any{"jsonrpc": mcp.JSONRPC_VERSION, "id": 1, "method": method, "params": params})
+	if err != nil {
+		return nil, &mcp.JSONRPCErrorDetails{Code: mcp.INTERNAL_ERROR, Message: err.Error()}
+	}
+	switch response := ps.mcpServer.HandleMessage(ctx, request).(type) {
+	case mcp.JSONRPCResponse:
+		return response.Result, nil
+	case mcp.JSONRPCError:
+		return nil, &response.Error
+	default:
+		return nil, &mcp.JSONRPCErrorDetails{Code: mcp.INTERNAL_ERROR, Message: fmt.Sprintf("unexpected response %T", response)}
+	}
+}
+
+// previewPrompts returns the prompts the server lists to MCP clients, in the same order and with their
+// arguments in the same order.
+func (ps *PromptsServer) previewPrompts(ctx context.Context) ([]previewPrompt, error) {
+	result, rpcErr := ps.handleMCPRequest(ctx, string(mcp.MethodPromptsList), map[string]any{})
+	if rpcErr != nil {
+		return nil, fmt.Errorf("list prompts: %s", rpcErr.Message)
+	}
+	listed := result.(mcp.ListPromptsResult).Prompts
+	prompts := make([]previewPrompt, 0, len(listed))
+	for _, prompt := range listed {
+		preview := previewPrompt{Name: prompt.Name, Description: prompt.Description, Arguments: []previewArgument{}}
+		if prompt.Meta != nil {
+			preview.Title, _ = prompt.Meta.AdditionalFields["title"].(string)
+			preview.Collection, _ = prompt.Meta.AdditionalFields["collection"].(string)
+			preview.Deprecated, _ = prompt.Meta.AdditionalFields["deprecated"].(string)
+		}
+		for _, arg := range prompt.Arguments {
+			preview.Arguments = append(preview.Arguments, previewArgument{Name: arg.Name, Kind: argumentKindText})
+		}
+		preview.Tokens, _ = ps.StaticTokenEstimate(prompt.Name)
+		prompts = append(prompts, preview)
+	}
+	return prompts, nil
+}
+
+// previewPromptDetails returns a listed prompt with its source and the kinds of its arguments.
+func (ps *PromptsServer) previewPromptDetails(ctx context.Context, name string) (*previewPrompt, error) {
+	prompts, err := ps.previewPrompts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(prompts, func(prompt previewPrompt) bool { return prompt.Name == name })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %q", errPreviewPromptNotFound, name)
+	}
+	prompt := prompts[i]
+
+	availableTemplates, err := getAvailableTemplates(ps.promptsDir)
+	if err != nil {
+		return nil, err
+	}
+	j := slices.IndexFunc(availableTemplates, func(templateName string) bool {
+		return ps.nameStyle.PromptName(templateName) == name
+	})
+	if j < 0 {
+		return nil, fmt.Errorf("%w: no template for %q", errPreviewPromptNotFound, name)
+	}
+	templateName := availableTemplates[j]
+	source, err := os.ReadFile(filepath.Join(ps.promptsDir, templateName))
+	if err != nil {
+		return nil, fmt.Errorf("read template: %w", err)
+	}
+	prompt.Source = string(source)
+
+	tmpl, err := ps.parser.ParseDir(ps.promptsDir)
+	if err != nil {
+		return nil, fmt.Errorf("parse all prompts: %w", err)
+	}
+	kinds := inferArgumentKinds(tmpl, templateName)
+	for k := range prompt.Arguments {
+		if kind, ok := kinds[prompt.Arguments[k].Name]; ok {
+			prompt.Arguments[k].Kind = kind
+		}
+	}
+	return &prompt, nil
+}
+
+func (ps *PromptsServer) writePreviewPage(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := previewTemplates.ExecuteTemplate(w, name, data); err != nil {
+		ps.logger.Error("Failed to write preview page", "page", name, "error", err)
+	}
+}
+
+func writePreviewJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// argumentUsage counts how a template uses an argument.
+type argumentUsage struct {
+	conditions int  // uses as a condition of if, possibly within not, and, or
+	values     int  // all other uses
+	object     bool // has fields accessed or is ranged over
+}
+
+// inferArgumentKinds returns the kinds of the arguments used by the template and the partials it calls, by lowercase name.
+func inferArgumentKinds(tmpl *template.Template, templateName string) map[string]string {
+	usages := make(map[string]*argumentUsage)
+	if t := lookupPartial(tmpl, templateName); t != nil {
+		collectArgumentUsage(tmpl, t.Root, false, usages, map[string]bool{templateName: true})
+	}
+	kinds := make(map[string]string, len(usages))
+	for name, usage := range usages {
+		switch {
+		case usage.object:
+			kinds[name] = argumentKindObject
+		case usage.conditions > 0 && usage.values == 0:
+			kinds[name] = argumentKindBoolean
+		default:
+			kinds[name] = argumentKindText
+		}
+	}
+	return kinds
+}
+
+// collectArgumentUsage records the uses of the fields within the node. A condition node is the pipeline of an if.
+func collectArgumentUsage(
+	tmpl *template.Template, node parse.Node, condition bool, usages map[string]*argumentUsage, visited map[string]bool,
+) {
+	usage := func(name string) *argumentUsage {
+		name = strings.ToLower(name)
+		if usages[name] == nil {
+			usages[name] = &argumentUsage{}
+		}
+		return usages[name]
+	}
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectArgumentUsage(tmpl, child, false, usages, visited)
+		}
+	case *parse.ActionNode:
+		collectArgumentUsage(tmpl, n.Pipe, false, usages, visited)
+	case *parse.IfNode:
+		collectArgumentUsage(tmpl, n.Pipe, true, usages, visited)
+		collectArgumentUsage(tmpl, n.List, false, usages, visited)
+		collectArgumentUsage(tmpl, n.ElseList, false, usages, visited)
+	case *parse.RangeNode:
+		if n.Pipe != nil && len(n.Pipe.Cmds) == 1 && len(n.Pipe.Cmds[0].Args) == 1 {
+			if field, ok := n.Pipe.Cmds[0].Args[0].(*parse.FieldNode); ok {
+				usage(field.Ident[0]).object = true
+			}
+		}
+		collectArgumentUsage(tmpl, n.Pipe, false, usages, visited)
+		collectArgumentUsage(tmpl, n.List, false, usages, visited)
+		collectArgumentUsage(tmpl, n.ElseList, false, usages, visited)
+	case *parse.WithNode:
+		collectArgumentUsage(tmpl, n.Pipe, false, usages, visited)
+		collectArgumentUsage(tmpl, n.List, false, usages, visited)
+		collectArgumentUsage(tmpl, n.ElseList, false, usages, visited)
+	case *parse.TemplateNode:
+		collectArgumentUsage(tmpl, n.Pipe, false, usages, visited)
+		if t := lookupPartial(tmpl, n.Name); t != nil && !visited[n.Name] {
+			visited[n.Name] = true
+			collectArgumentUsage(tmpl, t.Root, false, usages, visited)
+		}
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectArgumentUsage(tmpl, cmd, condition && len(n.Cmds) == 1, usages, visited)
+		}
+	case *parse.CommandNode:
+		args := n.Args
+		if ident, ok := args[0].(*parse.IdentifierNode); ok && condition && slices.Contains([]string{"not", "and", "or"}, ident.Ident) {
+			args = args[1:]
+		} else if len(args) > 1 {
+			condition = false
+		}
+		for _, arg := range args {
+			collectArgumentUsage(tmpl, arg, condition, usages, visited)
+		}
+	case *parse.FieldNode:
+		u := usage(n.Ident[0])
+		if len(n.Ident) > 1 {
+			u.object = true
+		}
+		if condition {
+			u.conditions++
+		} else {
+			u.values++
+		}
+	}
+}
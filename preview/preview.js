@@ -0,0 +1,32 @@
+// Renders the prompt with the form values through the render endpoint and shows the output.
+const form = document.getElementById("render");
+const result = document.getElementById("result");
+const output = document.getElementById("output");
+
+form.addEventListener("submit", async (event) => {
+  event.preventDefault();
+  const args = {};
+  for (const element of form.elements) {
+    if (!element.name) {
+      continue;
+    }
+    if (element.type === "checkbox") {
+      args[element.name] = element.checked ? "true" : "false";
+    } else if (element.value !== "") {
+      args[element.name] = element.value;
+    }
+  }
+  const response = await fetch(form.action, {
+    method: "POST",
+    headers: {"Content-Type": "application/json"},
+    body: JSON.stringify({arguments: args}),
+  });
+  const body = await response.json();
+  output.textContent = body.error ? "Error: " + body.error : body.text;
+  output.classList.toggle("error", Boolean(body.error));
+  result.hidden = false;
+});
+
+document.getElementById("copy").addEventListener("click", () => {
+  navigator.clipboard.writeText(output.textContent);
+});
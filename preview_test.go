@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type PreviewTestSuite struct {
+	suite.Suite
+	promptsDir    string
+	promptsServer *PromptsServer
+	httpServer    *httptest.Server
+}
+
+func TestPreviewTestSuite(t *testing.T) {
+	suite.Run(t, new(PreviewTestSuite))
+}
+
+func (s *PreviewTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("_owner.tmpl", `{{define "_owner"}}Owner: {{.owner.name}}{{end}}`)
+	s.writeFile("review.tmpl", "{{/* Review code */}}\n"+
+		"{{if not .strict}}Be gentle. {{end}}Review {{.target}}{{range .files}} {{.}}{{end}}\n{{template \"_owner\" .}}")
+	s.writeFile("greet.tmpl", "{{/* Greet someone */}}\nHello {{.name}}!")
+	s.startServer()
+}
+
+func (s *PreviewTestSuite) startServer(opts ...Option) {
+	var err error
+	s.promptsServer, err = NewPromptsServer(s.promptsDir, append([]Option{WithWatchMode(WatchModeOff, 0)}, opts...)...)
+	require.NoError(s.T(), err)
+	s.httpServer = httptest.NewServer(s.promptsServer.PreviewHandler(""))
+	s.T().Cleanup(func() {
+		s.httpServer.Close()
+		s.Require().NoError(s.promptsServer.Close())
+	})
+}
+
+func (s *PreviewTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+// request sends a request to the preview and returns the status and the body
+func (s *PreviewTestSuite) request(method, path, body string) (int, string) {
+	request, err := http.NewRequest(method, s.httpServer.URL+path, strings.NewReader(body))
+	require.NoError(s.T(), err)
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(s.T(), err)
+	defer func() { _ = response.Body.Close() }()
+	content, err := io.ReadAll(response.Body)
+	require.NoError(s.T(), err)
+	return response.StatusCode, string(content)
+}
+
+// TestListPrompts tests that the preview lists the prompts of the server and follows reloads
+func (s *PreviewTestSuite) TestListPrompts() {
+	status, body := s.request(http.MethodGet, "/api/prompts", "")
+	require.Equal(s.T(), http.StatusOK, status)
+	assert.JSONEq(s.T(), `{"prompts": [
+		{"name": "greet", "description": "Greet someone", "arguments": [{"name": "name", "kind": "text"}], "estimated_tokens": 2},
+		{"name": "review", "description": "Review code", "estimated_tokens": 7, "arguments": [
+			{"name": "files", "kind": "text"}, {"name": "owner", "kind": "text"},
+			{"name": "strict", "kind": "text"}, {"name": "target", "kind": "text"}
+		]}
+	]}`, body)
+
+	s.writeFile("summary.tmpl", "{{/* Summarize */}}\n{{.text}}")
+	require.NoError(s.T(), s.promptsServer.reloadPrompts())
+	_, body = s.request(http.MethodGet, "/api/prompts", "")
+	assert.Contains(s.T(), body, `"name":"summary"`)
+}
+
+// TestArgOrder tests that the preview keeps the order of the arguments the server lists
+func (s *PreviewTestSuite) TestArgOrder() {
+	s.startServer(WithArgOrder(ArgOrderTemplate))
+	request := []byte(`{"jsonrpc":"2.0","id":1,"method":"prompts/list"}`)
+	response, ok := s.promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok)
+	listed := response.Result.(mcp.ListPromptsResult).Prompts
+	i := slices.IndexFunc(listed, func(prompt mcp.Prompt) bool { return prompt.Name == "review" })
+	require.GreaterOrEqual(s.T(), i, 0)
+	expected := argNames(listed[i])
+	require.False(s.T(), slices.IsSorted(expected), "the template order must differ from the alphabetical one")
+
+	status, body := s.request(http.MethodGet, "/api/prompts/review", "")
+	require.Equal(s.T(), http.StatusOK, status)
+	var prompt previewPrompt
+	require.NoError(s.T(), json.Unmarshal([]byte(body), &prompt))
+	var names []string
+	for _, arg := range prompt.Arguments {
+		names = append(names, arg.Name)
+	}
+	assert.Equal(s.T(), expected, names)
+}
+
+// TestGetPrompt tests the details of a prompt with the argument kinds inferred from the template and its partials
+func (s *PreviewTestSuite) TestGetPrompt() {
+	status, body := s.request(http.MethodGet, "/api/prompts/review", "")
+	require.Equal(s.T(), http.StatusOK, status)
+	var prompt previewPrompt
+	require.NoError(s.T(), json.Unmarshal([]byte(body), &prompt))
+	assert.Equal(s.T(), []previewArgument{
+		{Name: "files", Kind: argumentKindObject},
+		{Name: "owner", Kind: argumentKindObject},
+		{Name: "strict", Kind: argumentKindBoolean},
+		{Name: "target", Kind: argumentKindText},
+	}, prompt.Arguments)
+	assert.True(s.T(), strings.HasPrefix(prompt.Source, "{{/* Review code */}}\n"), prompt.Source)
+
+	status, _ = s.request(http.MethodGet, "/api/prompts/unknown", "")
+	assert.Equal(s.T(), http.StatusNotFound, status)
+}
+
+// TestRender tests that the preview renders prompts like the MCP server, including its restrictions
+func (s *PreviewTestSuite) TestRender() {
+	status, body := s.request(http.MethodPost, "/api/prompts/review/render",
+		`{"arguments": {"strict": "true", "target": "main.go", "files": "[\"a.go\", \"b.go\"]", "owner": "{\"name\": \"Ann\"}"}}`)
+	require.Equal(s.T(), http.StatusOK, status)
+	assert.JSONEq(s.T(), `{"text": "Review main.go a.go b.go\nOwner: Ann"}`, body)
+
+	status, body = s.request(http.MethodPost, "/api/prompts/unknown/render", `{"arguments": {}}`)
+	assert.Equal(s.T(), http.StatusNotFound, status)
+	assert.Contains(s.T(), body, "not found")
+
+	status, _ = s.request(http.MethodPost, "/api/prompts/greet/render", `not json`)
+	assert.Equal(s.T(), http.StatusBadRequest, status)
+
+	s.startServer(WithStrictUnknownArgs(true))
+	status, body = s.request(http.MethodPost, "/api/prompts/greet/render", `{"arguments": {"name": "Ann", "age": "3"}}`)
+	assert.Equal(s.T(), http.StatusUnprocessableEntity, status)
+	assert.JSONEq(s.T(), `{"error": "unknown arguments for prompt \"greet\": age"}`, body)
+}
+
+// TestPages tests that the HTML pages and their assets are served
+func (s *PreviewTestSuite) TestPages() {
+	status, body := s.request(http.MethodGet, "/", "")
+	require.Equal(s.T(), http.StatusOK, status)
+	assert.Contains(s.T(), body, `<a href="/prompts/greet">greet</a>`)
+	assert.Contains(s.T(), body, `<a href="/prompts/review">review</a>`)
+
+	_, body = s.request(http.MethodGet, "/?q=greet", "")
+	assert.Contains(s.T(), body, `<a href="/prompts/greet">greet</a>`)
+	assert.NotContains(s.T(), body, `<a href="/prompts/review">review</a>`)
+
+	status, body = s.request(http.MethodGet, "/prompts/review", "")
+	require.Equal(s.T(), http.StatusOK, status)
+	assert.Contains(s.T(), body, `<form id="render" action="/api/prompts/review/render">`)
+	assert.Contains(s.T(), body, `<input type="checkbox" name="strict" value="true">`)
+	assert.Contains(s.T(), body, `<textarea name="files" rows="4" placeholder="JSON"></textarea>`)
+	assert.Contains(s.T(), body, `<input type="text" name="target">`)
+
+	status, _ = s.request(http.MethodGet, "/prompts/unknown", "")
+	assert.Equal(s.T(), http.StatusNotFound, status)
+	status, _ = s.request(http.MethodGet, "/static/preview.js", "")
+	assert.Equal(s.T(), http.StatusOK, status)
+}
+
+// TestHostAndOrigin tests that requests for other hosts and cross-origin renders are rejected
+func (s *PreviewTestSuite) TestHostAndOrigin() {
+	send := func(host, origin string) int {
+		request, err := http.NewRequest(http.MethodPost, s.httpServer.URL+"/api/prompts/greet/render",
+			strings.NewReader(`{"arguments": {"name": "Ann"}}`))
+		require.NoError(s.T(), err)
+		if host != "" {
+			request.Host = host
+		}
+		if origin != "" {
+			request.Header.Set("Origin", origin)
+		}
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(s.T(), err)
+		_ = response.Body.Close()
+		return response.StatusCode
+	}
+	for _, host := range []string{"", "localhost:8090", "[::1]:8090", "127.0.0.2"} {
+		assert.Equal(s.T(), http.StatusOK, send(host, ""), host)
+	}
+	assert.Equal(s.T(), http.StatusForbidden, send("rebound.example.com", ""))
+	assert.Equal(s.T(), http.StatusForbidden, send("192.168.1.5:8090", ""))
+
+	assert.Equal(s.T(), http.StatusOK, send("", s.httpServer.URL), "same origin")
+	assert.Equal(s.T(), http.StatusForbidden, send("", "https://attacker.example.com"))
+	assert.Equal(s.T(), http.StatusForbidden, send("", "null"))
+
+	for host, listenHost := range map[string]string{
+		"prompts.lan:8090": "prompts.lan", "PROMPTS.lan": "prompts.lan", "192.168.1.5:8090": "0.0.0.0", "[fe80::1]": "::",
+	} {
+		assert.True(s.T(), previewHostAllowed(host, listenHost), host)
+	}
+	for host, listenHost := range map[string]string{
+		"other.lan": "prompts.lan", "prompts.lan": "0.0.0.0", "192.168.1.5": "192.168.1.6", "": "",
+	} {
+		assert.False(s.T(), previewHostAllowed(host, listenHost), host)
+	}
+}
+
+// TestListenAddr tests that preview addresses without a host are bound to loopback
+func (s *PreviewTestSuite) TestListenAddr() {
+	for addr, expected := range map[string]struct {
+		addr     string
+		loopback bool
+	}{
+		":8090":          {"127.0.0.1:8090", true},
+		"127.0.0.1:8090": {"127.0.0.1:8090", true},
+		"localhost:8090": {"localhost:8090", true},
+		"[::1]:8090":     {"[::1]:8090", true},
+		"0.0.0.0:8090":   {"0.0.0.0:8090", false},
+	} {
+		listenAddr, loopback, err := previewListenAddr(addr)
+		require.NoError(s.T(), err, addr)
+		assert.Equal(s.T(), expected.addr, listenAddr, addr)
+		assert.Equal(s.T(), expected.loopback, loopback, addr)
+	}
+	_, _, err := previewListenAddr("8090")
+	assert.Error(s.T(), err)
+}
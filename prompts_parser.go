@@ -2,59 +2,389 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
 	"text/template"
 	"text/template/parse"
 )
 
 type PromptsParser struct {
+	// partialsDirs are shared directories whose partials are parsed before the prompts directory.
+	partialsDirs []string
+	// capturePanics makes template functions keep the stack of their panics (see templateFuncPanic).
+	capturePanics bool
+	// extractor controls how arguments are extracted from the templates.
+	extractor ExtractorOptions
+	// builtinDateName is the field of the built-in date, defaultBuiltinDateName if empty.
+	builtinDateName string
+	// filter selects the template files of the prompts directory that are parsed; nil parses all of them.
+	filter templateFilter
 }
 
+// ExtractorOptions control argument extraction.
+type ExtractorOptions struct {
+	// ResolveDictBindings makes partials called with a dict contribute the caller fields bound to their
+	// fields instead of their own field names. Fields bound to literals are not arguments at all.
+	ResolveDictBindings bool
+}
+
+// defaultExtractorOptions are the extractor options of the server and the CLI commands.
+var defaultExtractorOptions = ExtractorOptions{ResolveDictBindings: true}
+
 func (pp *PromptsParser) ParseDir(promptsDir string) (*template.Template, error) {
-	tmpl := template.New("base").Funcs(template.FuncMap{
-		"dict": dict,
-	})
-	var err error
-	tmpl, err = tmpl.ParseGlob(filepath.Join(promptsDir, "*"+templateExt))
+	tmpl, _, err := pp.parseDir(promptsDir, false)
+	return tmpl, err
+}
+
+// ParseDirSkippingUnknownFuncs parses the prompts directory like ParseDir, but skips the template files of the directory
+// that call undefined functions, e.g. helpers of a newer version of the server, instead of failing.
+// It returns the errors of the skipped files.
+func (pp *PromptsParser) ParseDirSkippingUnknownFuncs(promptsDir string) (*template.Template, []*unknownFuncError, error) {
+	return pp.parseDir(promptsDir, true)
+}
+
+func (pp *PromptsParser) parseDir(promptsDir string, skipUnknownFuncs bool) (*template.Template, []*unknownFuncError, error) {
+	funcs, err := pp.funcMap(promptsDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	pattern := filepath.Join(promptsDir, "*"+templateExt)
+	filePaths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse template glob %q: %w", pattern, err)
+	}
+	if len(filePaths) == 0 {
+		return nil, nil, fmt.Errorf("parse template glob %q: pattern matches no files", pattern)
+	}
+
+	libraryPartials, err := findLibraryPartials(promptsDir, pp.partialsDirs)
 	if err != nil {
-		return nil, fmt.Errorf("parse template glob %q: %w", filepath.Join(promptsDir, "*"+templateExt), err)
+		return nil, nil, err
+	}
+
+	tmpl := template.New("base").Funcs(funcs)
+	// Library partials are parsed first, so templates of the prompts directory redefine them.
+	for _, partial := range libraryPartials {
+		if partial.overridden && pp.includes(partial.fileName) || partial.shadowedBy != "" {
+			continue
+		}
+		if err = parseTemplateFile(tmpl, funcs, partial.path()); err != nil {
+			return nil, nil, err
+		}
+	}
+	var skipped []*unknownFuncError
+	var parseErrs []error // errors of every template file that does not parse, not only of the first one
+	for _, filePath := range filePaths {
+		if !pp.includes(filepath.Base(filePath)) {
+			continue
+		}
+		if err = parseTemplateFile(tmpl, funcs, filePath); err != nil {
+			var unknownFunc *unknownFuncError
+			if skipUnknownFuncs && errors.As(err, &unknownFunc) {
+				skipped = append(skipped, unknownFunc)
+				continue
+			}
+			parseErrs = append(parseErrs, err)
+		}
+	}
+	if len(parseErrs) > 0 {
+		return nil, nil, errors.Join(parseErrs...)
+	}
+	return tmpl, skipped, nil
+}
+
+// newerHelperFuncs are helpers of newer versions of the server. Templates calling them need an upgrade.
+var newerHelperFuncs = []string{"codefence"}
+
+var unknownFuncRegex = regexp.MustCompile(`function "([^"]+)" not defined`)
+
+// unknownFuncError is a template file calling a function that is not defined.
+type unknownFuncError struct {
+	file     string // base name of the template file
+	function string
+	err      error
+}
+
+func (e *unknownFuncError) Error() string {
+	return fmt.Sprintf("%v (%s)", e.err, e.Hint())
+}
+
+func (e *unknownFuncError) Unwrap() error {
+	return e.err
+}
+
+// Hint suggests how to serve the template.
+func (e *unknownFuncError) Hint() string {
+	if slices.Contains(newerHelperFuncs, e.function) {
+		return fmt.Sprintf("%q is a helper of a newer version of the server, upgrade it to serve %s", e.function, e.file)
+	}
+	return "the server may be outdated, or the function name is misspelled"
+}
+
+// includes reports whether the template file of the prompts directory passes the filter.
+func (pp *PromptsParser) includes(fileName string) bool {
+	return pp.filter == nil || pp.filter(fileName)
+}
+
+// parseTemplateFile parses the template file without its frontmatter into a new template named after the file.
+func parseTemplateFile(tmpl *template.Template, funcs template.FuncMap, filePath string) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("read template %q: %w", filePath, err)
+	}
+	_, body, err := splitFrontmatter(content)
+	if err != nil {
+		return fmt.Errorf("template %q: %w", filepath.Base(filePath), err)
+	}
+	if err = parseTemplateText(tmpl, funcs, filepath.Base(filePath), string(body)); err != nil {
+		err = fmt.Errorf("parse template %q: %w", filePath, err)
+		if match := unknownFuncRegex.FindStringSubmatch(err.Error()); match != nil {
+			return &unknownFuncError{file: filepath.Base(filePath), function: match[1], err: err}
+		}
+		return err
+	}
+	return nil
+}
+
+// textTemplateBuiltins are the names of the functions built into text/template, which parsing checks calls against.
+var textTemplateBuiltins = map[string]any{
+	"and": true, "call": true, "html": true, "index": true, "slice": true, "js": true, "len": true, "not": true,
+	"or": true, "print": true, "printf": true, "println": true, "urlquery": true,
+	"eq": true, "ge": true, "gt": true, "le": true, "lt": true, "ne": true,
+}
+
+// parseTemplateText parses the text into a new template of tmpl, like tmpl.New(name).Parse(text) does,
+// but keeps the comments in the parse tree so that argument docs can be collected from it.
+// Comments render to nothing, like they do without being kept.
+// The text is parsed with the delimiters of its delims directive, if any.
+func parseTemplateText(tmpl *template.Template, funcs template.FuncMap, name string, text string) error {
+	text, delims, err := withDelimsDirective(text)
+	if err != nil {
+		return err
+	}
+	tree := parse.New(name)
+	tree.Mode = parse.ParseComments
+	trees := make(map[string]*parse.Tree)
+	if _, err = tree.Parse(text, delims.left, delims.right, trees, funcs, textTemplateBuiltins); err != nil {
+		return err
+	}
+	for treeName, t := range trees {
+		if _, err := tmpl.AddParseTree(treeName, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseTemplates parses an in-memory set of templates keyed by file name (e.g. "review.tmpl", "_header.tmpl"),
+// without touching the filesystem. Names without the template extension get it appended.
+// Only built-in helpers are available: there are no schema sidecars or function aliases.
+func (pp *PromptsParser) ParseTemplates(templates map[string]string) (*template.Template, error) {
+	if len(templates) == 0 {
+		return nil, fmt.Errorf("no templates given")
+	}
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	funcs := builtinFuncs()
+	if pp.capturePanics {
+		funcs = capturePanicsInFuncs(funcs)
+	}
+	tmpl := template.New("base").Funcs(funcs)
+	for _, name := range names {
+		templateName := name
+		if !strings.HasSuffix(templateName, templateExt) {
+			templateName += templateExt
+		}
+		if templateName != filepath.Base(templateName) {
+			return nil, fmt.Errorf("invalid template name %q: must be a plain file name", name)
+		}
+		if tmpl.Lookup(templateName) != nil {
+			return nil, fmt.Errorf("template %q is given more than once", templateName)
+		}
+		_, body, err := splitFrontmatter([]byte(templates[name]))
+		if err != nil {
+			return nil, fmt.Errorf("template %q: %w", templateName, err)
+		}
+		if err = parseTemplateText(tmpl, funcs, templateName, string(body)); err != nil {
+			return nil, fmt.Errorf("parse template %q: %w", templateName, err)
+		}
 	}
 	return tmpl, nil
 }
 
+// defaultBuiltinDateName is the default field of the built-in date, see --builtin-date-name.
+const defaultBuiltinDateName = "date"
+
+// dateName returns the field of the built-in date.
+func (pp *PromptsParser) dateName() string {
+	if pp.builtinDateName == "" {
+		return defaultBuiltinDateName
+	}
+	return pp.builtinDateName
+}
+
+// builtinFields returns the fields set for every template, which are never reported as prompt arguments.
+func (pp *PromptsParser) builtinFields() []string {
+	return builtinFieldNames(pp.dateName())
+}
+
+// builtinFieldNames returns the fields set for every template with the built-in date in the given field.
+func builtinFieldNames(dateName string) []string {
+	return []string{dateName, sessionHistoryField, modelArgName, varsField}
+}
+
+// impureFields returns the built-in fields whose values differ between requests with the same arguments,
+// which makes the templates using them uncacheable. The deployment variables are part of the cache key instead.
+func (pp *PromptsParser) impureFields() []string {
+	return slices.DeleteFunc(pp.builtinFields(), func(name string) bool { return name == varsField })
+}
+
+// validateBuiltinDateName checks that the name can be used as the field of the built-in date.
+func validateBuiltinDateName(name string) error {
+	if !funcAliasNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid built-in date name %q", name)
+	}
+	if name == sessionHistoryField {
+		return fmt.Errorf("built-in date name %q is reserved for the session history", name)
+	}
+	if name == modelArgName {
+		return fmt.Errorf("built-in date name %q is reserved for the model of the request", name)
+	}
+	if name == varsField {
+		return fmt.Errorf("built-in date name %q is reserved for the deployment variables", name)
+	}
+	return nil
+}
+
+// builtinFuncs returns the helpers available to all templates.
+func builtinFuncs() template.FuncMap {
+	funcs := stringTransformFuncs()
+	funcs["dict"] = dict
+	funcs["merge"] = merge
+	funcs["set"] = set
+	funcs["unset"] = unset
+	funcs["lookup"] = lookup
+	funcs[summarizeFuncName] = truncateSummary
+	funcs[lastPromptFuncName] = makeLastPromptFunc(nil)
+	funcs[flagFuncName] = func(name string) bool { return false } // bound to the flags file by the server
+	funcs[modelIsFuncName] = makeModelIsFunc("")                  // bound to the model of the request
+	for name, fn := range unboundScratchFuncs() {
+		funcs[name] = fn
+	}
+	for name, fn := range collectionFuncs() {
+		funcs[name] = fn
+	}
+	for name, fn := range dateFuncs() {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// funcMap returns the functions available to templates: built-in helpers and aliases from the funcs config.
+func (pp *PromptsParser) funcMap(promptsDir string) (template.FuncMap, error) {
+	funcs := builtinFuncs()
+
+	schemaFunc, err := makeSchemaFunc(promptsDir)
+	if err != nil {
+		return nil, err
+	}
+	funcs[schemaFuncName] = schemaFunc
+
+	cfg, err := loadFuncsConfig(promptsDir)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := cfg.FuncMap(funcs)
+	if err != nil {
+		return nil, fmt.Errorf("register function aliases: %w", err)
+	}
+	for name, fn := range aliases {
+		funcs[name] = fn
+	}
+	if pp.capturePanics {
+		funcs = capturePanicsInFuncs(funcs)
+	}
+	return funcs, nil
+}
+
 func (pp *PromptsParser) ExtractPromptDescriptionFromFile(filePath string) (string, error) {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return "", fmt.Errorf("read file: %w", err)
 	}
-	content = bytes.TrimSpace(content)
+	return pp.ExtractPromptDescription(content)
+}
+
+// ExtractPromptDescription returns the description of a template file from its content.
+func (pp *PromptsParser) ExtractPromptDescription(content []byte) (string, error) {
+	_, body, err := splitFrontmatter(content)
+	if err != nil {
+		return "", err
+	}
+	return leadingCommentText(string(bytes.TrimSpace(body))), nil
+}
 
-	var firstLine string
-	if idx := bytes.IndexByte(content, '\n'); idx != -1 {
-		firstLine = string(content[:idx])
+// leadingCommentText returns the text of the comment that starts the template and ends its line,
+// e.g. {{/* Description */}} or {{- /* Description */ -}}. A comment spanning several lines
+// has its lines trimmed and joined with spaces; blank lines are dropped.
+func leadingCommentText(content string) string {
+	var body string
+	for _, opening := range []string{"{{/*", "{{- /*"} {
+		if rest, found := strings.CutPrefix(content, opening); found {
+			body = rest
+			break
+		}
+	}
+	end := strings.Index(body, "*/")
+	if end == -1 {
+		return ""
+	}
+	afterComment := body[end+len("*/"):]
+	if rest, found := strings.CutPrefix(afterComment, "}}"); found {
+		afterComment = rest
+	} else if rest, found = strings.CutPrefix(afterComment, " -}}"); found {
+		afterComment = rest
 	} else {
-		firstLine = string(content)
+		return ""
+	}
+	if restOfLine, _, _ := strings.Cut(afterComment, "\n"); strings.TrimSpace(restOfLine) != "" {
+		return ""
 	}
-	firstLine = strings.TrimSpace(firstLine)
 
-	for _, c := range [...][2]string{
-		{"{{/*", "*/}}"},
-		{"{{- /*", "*/}}"},
-		{"{{/*", "*/ -}}"},
-		{"{{- /*", "*/ -}}"},
-	} {
-		if strings.HasPrefix(firstLine, c[0]) && strings.HasSuffix(firstLine, c[1]) {
-			comment := firstLine
-			comment = strings.TrimPrefix(comment, c[0])
-			comment = strings.TrimSuffix(comment, c[1])
-			return strings.TrimSpace(comment), nil
+	var lines []string
+	for _, line := range strings.Split(body[:end], "\n") {
+		// Directives like "@icon 🧪" in the comment are not part of the description
+		if line = strings.TrimSpace(line); line != "" && !isCommentDirective(line) {
+			lines = append(lines, line)
 		}
 	}
+	// A leading argument doc is not the description of the prompt
+	if len(lines) > 0 && strings.HasPrefix(lines[0], argDocPrefix) {
+		return ""
+	}
+	return strings.Join(lines, " ")
+}
 
-	return "", nil
+// isCommentDirective reports whether a line of a comment is a directive other than an argument doc.
+func isCommentDirective(line string) bool {
+	directive, _, _ := strings.Cut(line, " ")
+	switch directive {
+	case iconDirective, delimsDirective, exampleDirective, assertContainsDirective, assertNotContainsDirective, assertMaxLinesDirective:
+		return true
+	}
+	return false
 }
 
 // ExtractPromptArgumentsFromTemplate analyzes template to find field references using template tree traversal,
@@ -62,39 +392,117 @@ func (pp *PromptsParser) ExtractPromptDescriptionFromFile(filePath string) (stri
 func (pp *PromptsParser) ExtractPromptArgumentsFromTemplate(
 	tmpl *template.Template, templateName string,
 ) ([]string, error) {
+	args, _, err := pp.analyzeTemplate(tmpl, templateName)
+	return args, err
+}
+
+// ExtractPromptPartialsFromTemplate returns the sorted names of all templates the given template
+// references directly or transitively.
+func (pp *PromptsParser) ExtractPromptPartialsFromTemplate(
+	tmpl *template.Template, templateName string,
+) ([]string, error) {
+	_, partials, err := pp.analyzeTemplate(tmpl, templateName)
+	return partials, err
+}
+
+// analyzeTemplate walks the template tree once and returns both its arguments and the partials it depends on.
+func (pp *PromptsParser) analyzeTemplate(
+	tmpl *template.Template, templateName string,
+) (args []string, partials []string, err error) {
 	targetTemplate := tmpl.Lookup(templateName)
 	if targetTemplate == nil {
 		if strings.HasSuffix(templateName, templateExt) {
-			return nil, fmt.Errorf("template %q not found", templateName)
+			return nil, nil, fmt.Errorf("template %q not found", templateName)
 		}
 		if targetTemplate = tmpl.Lookup(templateName + templateExt); targetTemplate == nil {
-			return nil, fmt.Errorf("template %q or %q not found", templateName, templateName+templateExt)
+			return nil, nil, fmt.Errorf("template %q or %q not found", templateName, templateName+templateExt)
 		}
 	}
 
-	argsMap := make(map[string]struct{})
-	builtInFields := map[string]struct{}{"date": {}}
-	processedTemplates := make(map[string]bool)
+	argsMap, processedTemplates, err := pp.walkTemplate(tmpl, targetTemplate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	args = argsMap.names()
+	partials = make([]string, 0, len(processedTemplates))
+	for partial := range processedTemplates {
+		partials = append(partials, partial)
+	}
+	sort.Strings(partials)
 
-	// Extract arguments from the target template and all referenced templates recursively
-	err := pp.walkNodes(targetTemplate.Root, argsMap, builtInFields, tmpl, processedTemplates, []string{})
+	return args, partials, nil
+}
+
+// ExtractArgumentOrigins returns the sorted names of the templates referencing each argument of the template:
+// the template itself or the partials it includes.
+func (pp *PromptsParser) ExtractArgumentOrigins(tmpl *template.Template, templateName string) (map[string][]string, error) {
+	targetTemplate := tmpl.Lookup(templateName)
+	if targetTemplate == nil || targetTemplate.Tree == nil {
+		return nil, fmt.Errorf("template %q not found", templateName)
+	}
+	argsMap, _, err := pp.walkTemplate(tmpl, targetTemplate)
 	if err != nil {
 		return nil, err
 	}
+	origins := make(map[string][]string, len(argsMap))
+	for arg, origin := range argsMap {
+		origins[arg] = slices.Sorted(maps.Keys(origin.templates))
+	}
+	return origins, nil
+}
+
+// argOrigins holds where each argument comes from, by argument name.
+type argOrigins map[string]*argOrigin
+
+// argOrigin holds the position of the first reference of an argument among the arguments
+// and the names of the templates referencing it.
+type argOrigin struct {
+	seq       int
+	templates map[string]struct{}
+}
 
-	args := make([]string, 0, len(argsMap))
-	for arg := range argsMap {
-		args = append(args, arg)
+// add records that the template references the argument.
+func (ao argOrigins) add(arg string, templateName string) {
+	origin := ao[arg]
+	if origin == nil {
+		origin = &argOrigin{seq: len(ao), templates: make(map[string]struct{})}
+		ao[arg] = origin
 	}
+	origin.templates[templateName] = struct{}{}
+}
+
+// names returns the argument names in the order of their first reference.
+func (ao argOrigins) names() []string {
+	names := slices.AppendSeq(make([]string, 0, len(ao)), maps.Keys(ao))
+	slices.SortFunc(names, func(a, b string) int { return ao[a].seq - ao[b].seq })
+	return names
+}
 
-	return args, nil
+// walkTemplate extracts the arguments of the target template and all referenced templates recursively.
+func (pp *PromptsParser) walkTemplate(
+	tmpl *template.Template, targetTemplate *template.Template,
+) (argOrigins, map[string]bool, error) {
+	argsMap := make(argOrigins)
+	builtInFields := make(map[string]struct{}, 2)
+	for _, name := range pp.builtinFields() {
+		builtInFields[name] = struct{}{}
+	}
+	processedTemplates := make(map[string]bool)
+	// The path starts with the target template, which is where its own arguments come from
+	path := []string{targetTemplate.Name()}
+	if err := pp.walkNodes(targetTemplate.Root, argsMap, builtInFields, tmpl, processedTemplates, path); err != nil {
+		return nil, nil, err
+	}
+	return argsMap, processedTemplates, nil
 }
 
 // walkNodes recursively walks the template parse tree to find variable references,
-// automatically resolving template calls to include variables from referenced templates
+// automatically resolving template calls to include variables from referenced templates.
+// The path holds the target template followed by the partials being walked, the last one referencing the node.
 func (pp *PromptsParser) walkNodes(
 	node parse.Node,
-	argsMap map[string]struct{},
+	argsMap argOrigins,
 	builtInFields map[string]struct{},
 	tmpl *template.Template,
 	processedTemplates map[string]bool,
@@ -159,25 +567,37 @@ func (pp *PromptsParser) walkNodes(
 		if len(n.Ident) > 0 {
 			fieldName := strings.ToLower(n.Ident[0])
 			if _, isBuiltIn := builtInFields[fieldName]; !isBuiltIn {
-				argsMap[fieldName] = struct{}{}
+				argsMap.add(fieldName, path[len(path)-1])
 			}
 		}
 	case *parse.VariableNode:
 		if len(n.Ident) > 0 {
 			fieldName := strings.ToLower(n.Ident[0])
+			// $.name refers to a field of the data the template was executed with
+			if fieldName == "$" && len(n.Ident) > 1 {
+				fieldName = strings.ToLower(n.Ident[1])
+			}
 			// Skip variable names that start with $ (template variables)
 			if !strings.HasPrefix(fieldName, "$") {
 				if _, isBuiltIn := builtInFields[fieldName]; !isBuiltIn {
-					argsMap[fieldName] = struct{}{}
+					argsMap.add(fieldName, path[len(path)-1])
 				}
 			}
 		}
+	case *parse.ChainNode:
+		// Fields of a parenthesized pipeline, e.g. (index .items 0).name, belong to its result, not to the data
+		return pp.walkNodes(n.Node, argsMap, builtInFields, tmpl, processedTemplates, path)
 	case *parse.TemplateNode:
 		templateName := n.Name
-		// Check for cycles
-		for _, ancestor := range path {
+		// Check for cycles among the partials
+		for _, ancestor := range path[1:] {
 			if ancestor == templateName {
-				return fmt.Errorf("cyclic partial reference detected: %s", strings.Join(append(path, templateName), " -> "))
+				return fmt.Errorf("cyclic partial reference detected: %s", strings.Join(append(path[1:], templateName), " -> "))
+			}
+		}
+		if pp.extractor.ResolveDictBindings {
+			if bindings, ok := dictBindings(n.Pipe); ok {
+				return pp.walkDictCall(n, bindings, argsMap, builtInFields, tmpl, processedTemplates, path)
 			}
 		}
 		if !processedTemplates[templateName] {
@@ -188,7 +608,7 @@ func (pp *PromptsParser) walkNodes(
 				referencedTemplate = tmpl.Lookup(templateName + templateExt)
 			}
 			if referencedTemplate == nil || referencedTemplate.Tree == nil {
-				return fmt.Errorf("referenced template %q not found in %q", templateName, tmpl.Name())
+				return newMissingPartialError(tmpl, n)
 			}
 			if err := pp.walkNodes(referencedTemplate.Root, argsMap, builtInFields, tmpl, processedTemplates, append(path, templateName)); err != nil {
 				return err
@@ -199,18 +619,212 @@ func (pp *PromptsParser) walkNodes(
 	return nil
 }
 
+// walkDictCall extracts the arguments of a template called with a dict. The callee is walked in its own scope:
+// its fields bound to caller expressions are replaced by the arguments of those expressions, fields bound to
+// literals are dropped, and unbound fields are kept as they are.
+// The callee is recorded in processedTemplates as false, so that a later call passing "." still walks it.
+func (pp *PromptsParser) walkDictCall(
+	n *parse.TemplateNode,
+	bindings map[string]parse.Node,
+	argsMap argOrigins,
+	builtInFields map[string]struct{},
+	tmpl *template.Template,
+	processedTemplates map[string]bool,
+	path []string,
+) error {
+	var referencedTemplate *template.Template
+	if referencedTemplate = tmpl.Lookup(n.Name); referencedTemplate == nil && !strings.HasSuffix(n.Name, templateExt) {
+		referencedTemplate = tmpl.Lookup(n.Name + templateExt)
+	}
+	if referencedTemplate == nil || referencedTemplate.Tree == nil {
+		return newMissingPartialError(tmpl, n)
+	}
+	calleeArgs := make(argOrigins)
+	calleeTemplates := map[string]bool{n.Name: true}
+	if err := pp.walkNodes(referencedTemplate.Root, calleeArgs, builtInFields, tmpl, calleeTemplates, append(path, n.Name)); err != nil {
+		return err
+	}
+	for name := range calleeTemplates {
+		if _, ok := processedTemplates[name]; !ok {
+			processedTemplates[name] = false
+		}
+	}
+	for _, arg := range calleeArgs.names() {
+		if _, bound := bindings[arg]; !bound {
+			for templateName := range calleeArgs[arg].templates {
+				argsMap.add(arg, templateName)
+			}
+		}
+	}
+	// Bound values are evaluated even if the callee does not use them, so their fields are arguments anyway.
+	values := slices.SortedFunc(maps.Values(bindings), func(a, b parse.Node) int { return int(a.Position() - b.Position()) })
+	for _, value := range values {
+		if err := pp.walkNodes(value, argsMap, builtInFields, tmpl, processedTemplates, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dictBindings returns the values of a pipeline that is a single dict call with string keys, by lowercase key.
+// It reports false for any other pipeline, e.g. ".", which keeps the caller's fields.
+func dictBindings(pipe *parse.PipeNode) (map[string]parse.Node, bool) {
+	if pipe == nil || len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 {
+		return nil, false
+	}
+	args := pipe.Cmds[0].Args
+	if ident, ok := args[0].(*parse.IdentifierNode); !ok || ident.Ident != "dict" || len(args)%2 != 1 {
+		return nil, false
+	}
+	bindings := make(map[string]parse.Node, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		key, ok := args[i].(*parse.StringNode)
+		if !ok {
+			return nil, false
+		}
+		bindings[strings.ToLower(key.Text)] = args[i+1]
+	}
+	return bindings, true
+}
+
+// CheckDictCalls statically reports dict calls in the template that would fail at execution time:
+// an odd number of arguments or a literal key that is not a string.
+func (pp *PromptsParser) CheckDictCalls(tmpl *template.Template, templateName string) error {
+	targetTemplate := tmpl.Lookup(templateName)
+	if targetTemplate == nil || targetTemplate.Tree == nil {
+		return fmt.Errorf("template %q not found", templateName)
+	}
+	return checkDictCalls(targetTemplate.Tree, targetTemplate.Root)
+}
+
+func checkDictCalls(tree *parse.Tree, node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkDictCalls(tree, child); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return checkDictCalls(tree, n.Pipe)
+	case *parse.TemplateNode:
+		return checkDictCalls(tree, n.Pipe)
+	case *parse.IfNode:
+		return checkDictCallsInBranch(tree, &n.BranchNode)
+	case *parse.RangeNode:
+		return checkDictCallsInBranch(tree, &n.BranchNode)
+	case *parse.WithNode:
+		return checkDictCallsInBranch(tree, &n.BranchNode)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := checkDictCalls(tree, cmd); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		if err := checkDictCall(tree, n); err != nil {
+			return err
+		}
+		for _, arg := range n.Args {
+			if err := checkDictCalls(tree, arg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkDictCallsInBranch(tree *parse.Tree, branch *parse.BranchNode) error {
+	for _, node := range []parse.Node{branch.Pipe, branch.List, branch.ElseList} {
+		if err := checkDictCalls(tree, node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkDictCall(tree *parse.Tree, cmd *parse.CommandNode) error {
+	if len(cmd.Args) == 0 {
+		return nil
+	}
+	if ident, ok := cmd.Args[0].(*parse.IdentifierNode); !ok || ident.Ident != "dict" {
+		return nil
+	}
+	location, _ := tree.ErrorContext(cmd)
+	values := cmd.Args[1:]
+	if len(values)%2 != 0 {
+		return fmt.Errorf("dict: odd number of arguments (%d) at %s", len(values), location)
+	}
+	for i := 0; i < len(values); i += 2 {
+		switch values[i].(type) {
+		case *parse.NumberNode, *parse.BoolNode, *parse.NilNode:
+			return fmt.Errorf("dict: key at position %d must be a string, got %s at %s", i+1, values[i], location)
+		}
+	}
+	return nil
+}
+
 // dict creates a map from key-value pairs for template usage
-func dict(values ...interface{}) map[string]interface{} {
+func dict(values ...interface{}) (map[string]interface{}, error) {
 	if len(values)%2 != 0 {
-		return nil
+		return nil, fmt.Errorf("odd number of arguments (%d)", len(values))
 	}
-	result := make(map[string]interface{})
+	result := make(map[string]interface{}, len(values)/2)
 	for i := 0; i < len(values); i += 2 {
 		key, ok := values[i].(string)
 		if !ok {
-			return nil
+			return nil, fmt.Errorf("key at position %d must be a string, got %T", i+1, values[i])
 		}
 		result[key] = values[i+1]
 	}
+	return result, nil
+}
+
+// merge combines maps into a new map; values of later maps win.
+func merge(maps ...map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, m := range maps {
+		for key, value := range m {
+			result[key] = value
+		}
+	}
 	return result
 }
+
+// set returns a copy of the map with the key set to the value.
+func set(m map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	result := merge(m)
+	result[key] = value
+	return result
+}
+
+// unset returns a copy of the map without the key.
+func unset(m map[string]interface{}, key string) map[string]interface{} {
+	result := merge(m)
+	delete(result, key)
+	return result
+}
+
+// lookup returns the value stored under key in a map, e.g. {{lookup .messages .locale "Hello"}} for localization.
+// Unlike index, a missing key or a missing map is not an error: the optional fallback (or an empty string) is returned.
+func lookup(collection interface{}, key interface{}, fallback ...interface{}) interface{} {
+	var defaultValue interface{} = ""
+	if len(fallback) > 0 {
+		defaultValue = fallback[0]
+	}
+	m := reflect.ValueOf(collection)
+	if m.Kind() != reflect.Map || m.Type().Key().Kind() != reflect.String {
+		return defaultValue
+	}
+	value := m.MapIndex(reflect.ValueOf(stringify(key)).Convert(m.Type().Key()))
+	if !value.IsValid() {
+		return defaultValue
+	}
+	return value.Interface()
+}
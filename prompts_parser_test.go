@@ -1,9 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -128,6 +133,22 @@ func (s *PromptsParserTestSuite) TestExtractTemplateArgumentsFromTemplate() {
 			description: "Template with range",
 			shouldError: false,
 		},
+		{
+			name:        "template with index by dynamic key",
+			content:     "{{/* Localized greeting */}}\n{{index .messages .locale}}",
+			partials:    map[string]string{},
+			expected:    []string{"messages", "locale"},
+			description: "Localized greeting",
+			shouldError: false,
+		},
+		{
+			name:        "template with lookup by dynamic key",
+			content:     "{{/* Localized greeting */}}\n{{lookup .messages .locale \"Hello\"}}, {{.name}}",
+			partials:    map[string]string{},
+			expected:    []string{"messages", "locale", "name"},
+			description: "Localized greeting",
+			shouldError: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -208,6 +229,26 @@ func (s *PromptsParserTestSuite) TestExtractPromptDescriptionFromFile() {
 			content:             "{{/* Comment */}}",
 			expectedDescription: "Comment",
 		},
+		{
+			name:                "multi-line comment",
+			content:             "{{/*\n  Review the staged changes\n  and suggest a commit message\n*/}}\nHello {{.name}}",
+			expectedDescription: "Review the staged changes and suggest a commit message",
+		},
+		{
+			name:                "multi-line comment with dashes and blank lines",
+			content:             "{{- /* Summarize the incident\n\n   for the on-call handover */ -}}\n{{.incident}}",
+			expectedDescription: "Summarize the incident for the on-call handover",
+		},
+		{
+			name:                "comment followed by text on the same line",
+			content:             "{{/* Not a description */}}Hello {{.name}}",
+			expectedDescription: "",
+		},
+		{
+			name:                "comment after text",
+			content:             "Hello {{/* Not a description */}}",
+			expectedDescription: "",
+		},
 	}
 
 	for _, tt := range tests {
@@ -260,9 +301,55 @@ func (s *PromptsParserTestSuite) TestParseDirErrorCases() {
 	assert.Error(s.T(), err, "ParseDir() expected error for invalid template syntax, but got none")
 }
 
+// TestUnknownFuncsAreSkipped tests that template files calling undefined functions are skipped by the server
+// and reported as errors by validate, while the other templates load
+func (s *PromptsParserTestSuite) TestUnknownFuncsAreSkipped() {
+	for name, content := range map[string]string{
+		"greet.tmpl":   "{{/* Greet */}}\nHello {{.name}}!",
+		"snippet.tmpl": "{{/* Snippet */}}\n{{codefence .code}}",
+		"_typo.tmpl":   `{{define "_typo"}}{{uper .name}}{{end}}`,
+		"shout.tmpl":   "{{/* Shout */}}\n{{template \"_typo\" .}}",
+		"summary.tmpl": "{{/* Summary */}}\nSummarize {{.text}}",
+	} {
+		require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, name), []byte(content), 0644))
+	}
+
+	_, err := s.parser.ParseDir(s.tempDir)
+	var unknownFunc *unknownFuncError
+	require.ErrorAs(s.T(), err, &unknownFunc, "ParseDir stays strict")
+
+	tmpl, skipped, err := s.parser.ParseDirSkippingUnknownFuncs(s.tempDir)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), skipped, 2)
+	assert.Equal(s.T(), []string{"_typo.tmpl", "snippet.tmpl"}, []string{skipped[0].file, skipped[1].file})
+	assert.Equal(s.T(), []string{"uper", "codefence"}, []string{skipped[0].function, skipped[1].function})
+	assert.Equal(s.T(), "the server may be outdated, or the function name is misspelled", skipped[0].Hint())
+	assert.Contains(s.T(), skipped[1].Error(), `"codefence" is a helper of a newer version of the server, upgrade it`)
+	assert.NotNil(s.T(), tmpl.Lookup("summary.tmpl"))
+
+	var logs bytes.Buffer
+	promptsServer, err := NewPromptsServer(s.tempDir, WithWatchMode(WatchModeOff, 0),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+	assert.Equal(s.T(), []string{"greet", "summary"}, slices.Sorted(maps.Keys(promptsServer.promptChecksums)))
+	assert.Contains(s.T(), logs.String(),
+		`msg="Skipping template file that calls an undefined function" file=snippet.tmpl function=codefence`)
+	assert.Contains(s.T(), logs.String(),
+		`msg="Skipping template file that includes a skipped template" file=shout.tmpl partial=_typo`)
+
+	var buf bytes.Buffer
+	err = validateTemplates(&buf, s.tempDir, nil, "", validateOptions{})
+	require.Error(s.T(), err)
+	output := removeANSIColors(buf.String())
+	assert.Contains(s.T(), output, `✗ snippet.tmpl - Error: parse template`)
+	assert.Contains(s.T(), output, `function "codefence" not defined (`)
+	assert.Contains(s.T(), output, "✓ summary.tmpl - Valid\n")
+}
+
 // TestWalkNodesNilHandling tests nil node handling in walkNodes
 func (s *PromptsParserTestSuite) TestWalkNodesNilHandling() {
-	argsMap := make(map[string]struct{})
+	argsMap := make(argOrigins)
 	builtInFields := map[string]struct{}{"date": {}}
 	processedTemplates := make(map[string]bool)
 
@@ -293,61 +380,288 @@ func (s *PromptsParserTestSuite) TestWalkNodesVariableHandling() {
 	assert.Equal(s.T(), expected, args, "ExtractPromptArgumentsFromTemplate() should only return template data arguments, not dollar variables")
 }
 
+// TestExtractLogicalOperatorArguments tests that arguments nested in and, or, and not calls are extracted
+// without the names of the functions
+func (s *PromptsParserTestSuite) TestExtractLogicalOperatorArguments() {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name:     "nested and or not",
+			content:  "{{if and (or .a .b) (not .c)}}yes{{end}}",
+			expected: []string{"a", "b", "c"},
+		},
+		{
+			name:     "deeply nested",
+			content:  "{{if or (and .a (not (or .b (and .c (not .d))))) (not (not .e))}}yes{{end}}",
+			expected: []string{"a", "b", "c", "d", "e"},
+		},
+		{
+			name:     "else if chain and body",
+			content:  "{{if not .a}}{{.x}}{{else if and .b (or .c .d)}}{{.y}}{{else}}{{.z}}{{end}}",
+			expected: []string{"a", "b", "c", "d", "x", "y", "z"},
+		},
+		{
+			name:     "operators with comparisons and pipelines",
+			content:  "{{if and (eq .kind \"bug\") (or (gt (len .items) 2) (.flag | not))}}yes{{end}}",
+			expected: []string{"flag", "items", "kind"},
+		},
+		{
+			name:     "operators in with range and variables",
+			content:  "{{with $ok := and .a (not .b)}}{{$ok}}{{end}}{{range $i := .list}}{{if or $i $.c}}{{$.d}}{{end}}{{end}}",
+			expected: []string{"a", "b", "c", "d", "list"},
+		},
+		{
+			name:     "fields of parenthesized pipelines",
+			content:  "{{if not (index .items 0).done}}{{(or .primary .fallback).name}}{{end}}",
+			expected: []string{"fallback", "items", "primary"},
+		},
+		{
+			name:     "fields named like the operators",
+			content:  "{{if and .and (or .or .not)}}yes{{end}}",
+			expected: []string{"and", "not", "or"},
+		},
+		{
+			name:     "built-in date",
+			content:  "{{if and .date (not .archived)}}{{.date}}{{end}}",
+			expected: []string{"archived"},
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			tmpl, err := s.parser.ParseTemplates(map[string]string{"test.tmpl": tt.content})
+			require.NoError(s.T(), err)
+			args, err := s.parser.ExtractPromptArgumentsFromTemplate(tmpl, "test.tmpl")
+			require.NoError(s.T(), err)
+			sort.Strings(args)
+			assert.Equal(s.T(), tt.expected, args)
+		})
+	}
+}
+
+// TestExtractArgumentOrigins tests that arguments are attributed to the templates referencing them,
+// through nested partials and dict calls
+func (s *PromptsParserTestSuite) TestExtractArgumentOrigins() {
+	parser := &PromptsParser{extractor: defaultExtractorOptions}
+	tmpl, err := parser.ParseTemplates(map[string]string{
+		"review.tmpl":  "{{template \"_header\" .}}{{.diff}} {{.team}}{{template \"_footer\" dict \"team\" .team \"tone\" \"calm\"}}",
+		"_header.tmpl": "{{define \"_header\"}}{{.title}} {{.team}}{{template \"_badge\" .}}{{end}}",
+		"_badge.tmpl":  "{{define \"_badge\"}}{{.badge}}{{end}}",
+		"_footer.tmpl": "{{define \"_footer\"}}{{.team}} {{.tone}} {{.signature}}{{end}}",
+	})
+	require.NoError(s.T(), err)
+
+	origins, err := parser.ExtractArgumentOrigins(tmpl, "review.tmpl")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), map[string][]string{
+		"badge":     {"_badge"},
+		"diff":      {"review.tmpl"},
+		"signature": {"_footer"},
+		"team":      {"_header", "review.tmpl"},
+		"title":     {"_header"},
+	}, origins)
+
+	args, err := parser.ExtractPromptArgumentsFromTemplate(tmpl, "review.tmpl")
+	require.NoError(s.T(), err)
+	assert.ElementsMatch(s.T(), slices.Collect(maps.Keys(origins)), args)
+
+	_, err = parser.ExtractArgumentOrigins(tmpl, "missing.tmpl")
+	assert.EqualError(s.T(), err, `template "missing.tmpl" not found`)
+}
+
+// TestDictBindings tests the arguments of partials called with a dict: fields bound to caller fields are taken
+// under the caller's name, fields bound to literals are dropped, and unbound fields are kept
+func (s *PromptsParserTestSuite) TestDictBindings() {
+	promptsDir := filepath.Join("testdata", "dict_bindings")
+	extract := func(parser *PromptsParser, name string) []string {
+		tmpl, err := parser.ParseDir(promptsDir)
+		require.NoError(s.T(), err)
+		args, err := parser.ExtractPromptArgumentsFromTemplate(tmpl, name)
+		require.NoError(s.T(), err)
+		sort.Strings(args)
+		return args
+	}
+
+	accurate := &PromptsParser{extractor: ExtractorOptions{ResolveDictBindings: true}}
+	assert.Equal(s.T(), []string{"owner", "project", "review_task"}, extract(accurate, "review"))
+	assert.Equal(s.T(), []string{"owner", "priority", "task"}, extract(accurate, "handover"),
+		"a call passing . must still take the fields of a partial called with a dict before")
+	assert.Equal(s.T(), []string{"owner", "priority", "project", "review_task", "task"}, extract(&PromptsParser{}, "review"),
+		"without the option the fields of the partial are taken as they are")
+
+	tmpl, err := accurate.ParseDir(promptsDir)
+	require.NoError(s.T(), err)
+	partials, err := accurate.ExtractPromptPartialsFromTemplate(tmpl, "review")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"_task_card"}, partials)
+}
+
 // TestDict tests the dict helper function
 func (s *PromptsParserTestSuite) TestDict() {
 	tests := []struct {
-		name     string
-		args     []string
-		expected map[string]interface{}
-		hasError bool
+		name        string
+		args        []interface{}
+		expected    map[string]interface{}
+		expectedErr string
 	}{
 		{
 			name:     "empty args",
-			args:     []string{},
+			args:     []interface{}{},
 			expected: map[string]interface{}{},
-			hasError: false,
 		},
 		{
 			name:     "single key-value pair",
-			args:     []string{"key", "value"},
+			args:     []interface{}{"key", "value"},
 			expected: map[string]interface{}{"key": "value"},
-			hasError: false,
 		},
 		{
 			name:     "multiple key-value pairs",
-			args:     []string{"key1", "value1", "key2", "value2"},
-			expected: map[string]interface{}{"key1": "value1", "key2": "value2"},
-			hasError: false,
+			args:     []interface{}{"key1", "value1", "key2", 2},
+			expected: map[string]interface{}{"key1": "value1", "key2": 2},
+		},
+		{
+			name:        "odd number of arguments",
+			args:        []interface{}{"key1", "value1", "key2"},
+			expectedErr: "odd number of arguments (3)",
 		},
 		{
-			name:     "odd number of arguments",
-			args:     []string{"key1", "value1", "key2"},
-			expected: nil,
-			hasError: true,
+			name:        "non-string key",
+			args:        []interface{}{"key1", "value1", 123, "value"},
+			expectedErr: "key at position 3 must be a string, got int",
 		},
 	}
 
 	for _, tt := range tests {
 		s.Run(tt.name, func() {
-			// Convert string slice to interface slice
-			args := make([]interface{}, len(tt.args))
-			for i, v := range tt.args {
-				args[i] = v
+			result, err := dict(tt.args...)
+			if tt.expectedErr != "" {
+				require.Error(s.T(), err)
+				assert.Contains(s.T(), err.Error(), tt.expectedErr)
+				assert.Nil(s.T(), result)
+				return
 			}
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), tt.expected, result, "dict() returned unexpected result")
+		})
+	}
+}
+
+// TestMapHelpers tests the merge, set, and unset helpers used to build data for partials
+func (s *PromptsParserTestSuite) TestMapHelpers() {
+	base := map[string]interface{}{"role": "reviewer", "task": "review"}
+
+	assert.Equal(s.T(), map[string]interface{}{"role": "author", "task": "review", "tone": "calm"},
+		merge(base, map[string]interface{}{"role": "author"}, nil, map[string]interface{}{"tone": "calm"}))
+	assert.Equal(s.T(), map[string]interface{}{}, merge())
+	assert.Equal(s.T(), map[string]interface{}{"role": "reviewer", "task": "fix"}, set(base, "task", "fix"))
+	assert.Equal(s.T(), map[string]interface{}{"task": "review"}, unset(base, "role"))
+	assert.Equal(s.T(), map[string]interface{}{"role": "reviewer", "task": "review"}, base, "helpers must not modify their input")
+
+	err := os.WriteFile(filepath.Join(s.tempDir, "_header.tmpl"), []byte(`{{define "_header"}}{{.role}}/{{.task}}/{{.tone}}{{end}}`), 0644)
+	require.NoError(s.T(), err)
+	err = os.WriteFile(filepath.Join(s.tempDir, "review.tmpl"), []byte(
+		`{{$base := dict "role" .role "task" "review"}}`+
+			`{{template "_header" set (unset $base "task") "tone" "calm"}} `+
+			`{{template "_header" merge $base (dict "tone" .tone)}}`), 0644)
+	require.NoError(s.T(), err)
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), renderTemplate(&buf, s.tempDir, nil, "review", map[string]string{"role": "dev", "tone": "strict"}, true))
+	assert.Equal(s.T(), "dev/<no value>/calm dev/review/strict", buf.String())
+}
+
+// TestCheckDictCalls tests the static detection of dict calls that would fail at execution time
+func (s *PromptsParserTestSuite) TestCheckDictCalls() {
+	tests := []struct {
+		name        string
+		content     string
+		expectedErr string
+	}{
+		{
+			name:    "valid calls",
+			content: `{{template "_p" dict "role" .role "task" .task}}{{with dict}}{{end}}{{(dict "a" (dict "b" 1)).a}}`,
+		},
+		{
+			name:        "odd number of arguments in template call",
+			content:     "Intro\n{{template \"_p\" dict \"role\" .role \"task\"}}",
+			expectedErr: "dict: odd number of arguments (3) at odd_number_of_arguments_in_template_call.tmpl:2:",
+		},
+		{
+			name:        "nested odd call inside if",
+			content:     `{{if .x}}{{template "_p" (dict "a" (dict "b"))}}{{end}}`,
+			expectedErr: "dict: odd number of arguments (1)",
+		},
+		{
+			name:        "numeric literal key",
+			content:     `{{template "_p" dict 1 "one"}}`,
+			expectedErr: "dict: key at position 1 must be a string, got 1",
+		},
+	}
 
-			result := dict(args...)
-			if tt.hasError {
-				assert.Nil(s.T(), result, "dict() expected nil result for error case")
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			testDir := filepath.Join(s.tempDir, strings.ReplaceAll(tt.name, " ", "_"))
+			require.NoError(s.T(), os.MkdirAll(testDir, 0755))
+			templateName := filepath.Base(testDir) + templateExt
+			require.NoError(s.T(), os.WriteFile(filepath.Join(testDir, "_p.tmpl"), []byte("{{.}}"), 0644))
+			require.NoError(s.T(), os.WriteFile(filepath.Join(testDir, templateName), []byte(tt.content), 0644))
+
+			tmpl, err := s.parser.ParseDir(testDir)
+			require.NoError(s.T(), err)
+			err = s.parser.CheckDictCalls(tmpl, templateName)
+			if tt.expectedErr == "" {
+				require.NoError(s.T(), err)
 				return
 			}
-			assert.NotNil(s.T(), result, "dict() unexpected nil result")
-			assert.Equal(s.T(), tt.expected, result, "dict() returned unexpected result")
+			require.Error(s.T(), err)
+			assert.Contains(s.T(), err.Error(), tt.expectedErr)
+
+			var buf bytes.Buffer
+			require.Error(s.T(), validateTemplates(&buf, testDir, nil, templateName, validateOptions{}))
+			assert.Contains(s.T(), buf.String(), tt.expectedErr)
 		})
 	}
+}
 
-	// Test non-string key
-	s.Run("non-string key", func() {
-		result := dict(123, "value")
-		assert.Nil(s.T(), result, "dict() expected nil result for non-string key")
-	})
+// TestLookup tests localized selection with the lookup helper for present and missing keys
+func (s *PromptsParserTestSuite) TestLookup() {
+	err := os.WriteFile(filepath.Join(s.tempDir, "greeting.tmpl"),
+		[]byte(`{{/* Localized greeting */}}`+"\n"+`{{lookup .messages .locale "Hello"}}, {{.name}}!`), 0644)
+	require.NoError(s.T(), err)
+
+	tests := []struct {
+		name     string
+		args     map[string]string
+		expected string
+	}{
+		{
+			name:     "present key",
+			args:     map[string]string{"messages": `{"en": "Hello", "de": "Hallo"}`, "locale": "de", "name": "Anna"},
+			expected: "Hallo, Anna!",
+		},
+		{
+			name:     "missing key",
+			args:     map[string]string{"messages": `{"en": "Hello", "de": "Hallo"}`, "locale": "fr", "name": "Anna"},
+			expected: "Hello, Anna!",
+		},
+		{
+			name:     "messages not a map",
+			args:     map[string]string{"messages": "Hi", "locale": "fr", "name": "Anna"},
+			expected: "Hello, Anna!",
+		},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			var buf bytes.Buffer
+			require.NoError(s.T(), renderTemplate(&buf, s.tempDir, nil, "greeting", tt.args, true))
+			assert.Equal(s.T(), tt.expected, buf.String())
+		})
+	}
+
+	assert.Equal(s.T(), "", lookup(nil, "en"))
+	assert.Equal(s.T(), "b", lookup(map[string]string{"a": "b"}, "a"))
+	assert.Equal(s.T(), 42, lookup(map[string]interface{}{}, "a", 42))
 }
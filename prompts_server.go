@@ -3,17 +3,22 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"text/template"
 	"time"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
@@ -23,79 +28,686 @@ type PromptsServer struct {
 	parser         *PromptsParser
 	promptsDir     string
 	enableJSONArgs bool
+	recovery       bool
+	accessLog      *AccessLog
+	events         *EventStream    // nil unless enabled
+	sessionHistory *sessionHistory // nil unless enabled
+	renderCache    *RenderCache    // nil unless enabled
+	contextValues  map[string]string
+	vars           map[string]string // deployment variables, set under the vars field of every prompt
+	stdinTimeout   time.Duration
+	nameStyle      NameStyle
 	logger         *slog.Logger
-	watcher        *fsnotify.Watcher
+	reporter       ServerReporter
+	watcher        fileWatcher
+
+	sensitivePattern *regexp.Regexp
+	sensitiveMu      sync.RWMutex
+	sensitive        map[string]sensitiveArgs // sensitive arguments by prompt name
+
+	tokenEstimator  TokenEstimator
+	staticMu        sync.RWMutex
+	staticEstimates *staticTokenEstimates // static text estimates of the registered prompts, replaced on reload
+	templateNames   map[string]string     // template file names of the registered prompts by prompt name
+
+	watchModeMu        sync.Mutex
+	watchMode          WatchMode
+	watchPollInterval  time.Duration
+	watchExtensions    []string // extra extensions of watched files, with a leading dot
+	watchSweepInterval time.Duration
+	watchStatsInterval time.Duration
+
+	promptOrderMu sync.RWMutex
+	promptOrder   map[string]int
+
+	hideDeprecated bool
+	deprecatedMu   sync.RWMutex
+	deprecated     map[string]string // deprecation notices by prompt name
+
+	incompatiblePrompts IncompatiblePrompts
+	minProtocolsMu      sync.RWMutex
+	minProtocols        map[string]string // minimum protocol versions by prompt name
+	protocolsMu         sync.RWMutex
+	protocols           map[string]string // negotiated protocol versions by session ID
+
+	closeOnce       sync.Once
+	reloadMu        sync.Mutex        // serializes reloads of the watcher and of Reload
+	promptChecksums map[string]string // template checksums of the registered prompts, only used by reloadPrompts
+	contentHash     string            // hash of the watched files at the last reload, empty if unknown; see reloadChangedPrompts
+
+	gitIndexPath string // index of the git worktree if only tracked templates are served, empty otherwise
+	trackedMu    sync.RWMutex
+	tracked      map[string]bool // tracked file names of the prompts directory, listed on every reload
+
+	flagsFile    string                       // feature flags file, empty if none
+	featureFlags atomic.Pointer[FeatureFlags] // values of the flags file, read on every reload
+
+	skippedMu sync.Mutex
+	skipped   []*unknownFuncError // template files left out for calling undefined functions
+
+	loadSummary atomic.Pointer[LoadSummary] // summary of the last successful load of the prompts
+
+	runtime      atomic.Pointer[runtimeSettings] // settings that config reloads replace, see runtime_config.go
+	flagSettings runtimeSettings                 // settings of the options, which the runtime config overrides
+	configPath   string                          // runtime config file, empty if none
+	configMu     sync.Mutex                      // serializes config reloads
+	logLevel     *slog.LevelVar                  // level of the logger that config reloads set, nil if fixed
+	safeMode     bool                            // the runtime config must keep the limits of safe mode
+
+	strictUnknownArgs bool
+	defaultModel      string // model of the requests without the _model argument, empty if unknown
+	maxAdvertisedArgs int
+	argOrder          ArgOrder
+	iconMode          IconMode
+	unusedArgsWarned  sync.Map // "<prompt>\x00<argument>" keys of the unused arguments already logged
+
+	enableOverrides bool
+	overridesMu     sync.Mutex
+	overrides       map[string]*templateOverride // template overrides by template file name, see template_overrides.go
+}
+
+// ArgsLimits bounds the size and shape of prompt arguments received from MCP clients.
+// A zero value for any field disables the corresponding limit.
+type ArgsLimits struct {
+	MaxKeyLength int // maximum length of a single argument name
+	MaxTotalSize int // maximum combined length of all argument names and values
+	MaxJSONDepth int // maximum nesting depth of an argument value parsed as JSON
+}
+
+// DefaultArgsLimits returns the limits used by the serve command unless overridden.
+func DefaultArgsLimits() ArgsLimits {
+	return ArgsLimits{
+		MaxKeyLength: 256,
+		MaxTotalSize: 1 << 20,
+		MaxJSONDepth: 32,
+	}
+}
+
+// RenderSettings controls how templates are rendered for MCP clients.
+// The zero value keeps the permissive defaults: environment variable fallback enabled and no output or time limits.
+type RenderSettings struct {
+	DisableEnvArgs  bool          // do not pre-bind arguments from environment variables
+	MaxOutputSize   int           // maximum size in bytes of the rendered output, 0 for unlimited
+	MaxRenderCost   int           // maximum estimated iterations of nested ranges over the arguments, 0 for unlimited
+	Timeout         time.Duration // maximum duration of a single render, 0 for unlimited
+	Provenance      bool          // attach the source template file and its hash to GetPrompt results
+	AllowSampling   bool          // let the summarize helper request sampling from capable clients
+	LineEndings     LineEndings   // line endings of the rendered output, the zero value keeps them
+	ExposeArgs      bool          // attach the arguments received from the client to GetPrompt results
+	ErrorsAsContent bool          // return render errors as GetPrompt results marked with isError instead of protocol errors
+}
+
+var errRenderOutputTooLarge = errors.New("rendered output exceeds size limit")
+
+// errNoClient is returned by ServeStdio when no client sends a message within the stdin timeout.
+var errNoClient = errors.New("no client connected")
+
+// ArgOrder selects the order in which the arguments of a prompt are advertised. Clients show them in that order.
+type ArgOrder string
+
+const (
+	ArgOrderAlphabetical ArgOrder = "alphabetical"
+	// ArgOrderTemplate orders the arguments by their first use in the template body,
+	// with the arguments of a partial at the place the partial is called.
+	ArgOrderTemplate ArgOrder = "template"
+)
+
+// ParseArgOrder parses an argument order as accepted by the --arg-order flag.
+func ParseArgOrder(s string) (ArgOrder, error) {
+	switch order := ArgOrder(strings.ToLower(strings.TrimSpace(s))); order {
+	case ArgOrderAlphabetical, ArgOrderTemplate:
+		return order, nil
+	}
+	return "", fmt.Errorf("unknown argument order %q (expected %s or %s)", s, ArgOrderAlphabetical, ArgOrderTemplate)
+}
+
+// defaultMaxAdvertisedArgs is the number of arguments advertised per prompt by the serve command unless overridden.
+const defaultMaxAdvertisedArgs = 50
+
+// Option configures a PromptsServer created by NewPromptsServer.
+type Option func(*promptsServerOptions)
+
+type promptsServerOptions struct {
+	enableJSONArgs  bool
+	argsLimits      ArgsLimits
+	renderSettings  RenderSettings
+	configPath      string
+	logLevel        *slog.LevelVar
+	safeMode        bool
+	partialsDirs    []string
+	recovery        bool
+	watchMode       WatchMode
+	pollInterval    time.Duration
+	watchExtensions []string
+	accessLog       *AccessLog
+	events          *EventStream
+	renderCache     *RenderCache
+	sessionHistory  int
+	hideDeprecated  bool
+	incompatible    IncompatiblePrompts
+	strictUnknown   bool
+	defaultModel    string
+	overrides       bool
+	maxAdvertised   int
+	argOrder        ArgOrder
+	iconMode        IconMode
+	contextValues   map[string]string
+	vars            map[string]string
+	stdinTimeout    time.Duration
+	nameStyle       NameStyle
+	sensitive       *regexp.Regexp
+	gitTrackedOnly  bool
+	requireEnv      bool
+	dateName        string
+	flagsFile       string
+	tokenEstimator  TokenEstimator
+	logger          *slog.Logger
+	reporter        ServerReporter
+}
+
+// WithJSONArgs enables or disables parsing of argument values as JSON (enabled by default).
+func WithJSONArgs(enabled bool) Option {
+	return func(opts *promptsServerOptions) {
+		opts.enableJSONArgs = enabled
+	}
+}
+
+// WithArgsLimits sets the limits for prompt arguments received from MCP clients (DefaultArgsLimits by default).
+func WithArgsLimits(limits ArgsLimits) Option {
+	return func(opts *promptsServerOptions) {
+		opts.argsLimits = limits
+	}
+}
+
+// WithRenderSettings sets how templates are rendered for MCP clients (the zero RenderSettings by default).
+func WithRenderSettings(settings RenderSettings) Option {
+	return func(opts *promptsServerOptions) {
+		opts.renderSettings = settings
+	}
+}
+
+// WithRuntimeConfig makes the settings of the runtime config file override the argument limits, the render limits,
+// and the level of the logger, which must be set with logLevel. ReloadConfig applies changes of the file.
+func WithRuntimeConfig(path string, logLevel *slog.LevelVar) Option {
+	return func(opts *promptsServerOptions) {
+		opts.configPath = path
+		opts.logLevel = logLevel
+	}
+}
+
+// WithSafeMode makes the runtime config keep the limits of safe mode: settings that would loosen them
+// fail the start or the reload, which keeps the current settings.
+func WithSafeMode(enabled bool) Option {
+	return func(opts *promptsServerOptions) {
+		opts.safeMode = enabled
+	}
+}
+
+// WithPartialsDirs adds shared directories whose partials ("_*.tmpl") are available to all prompts.
+// They are never registered as prompts, and partials of the prompts directory override them.
+func WithPartialsDirs(dirs ...string) Option {
+	return func(opts *promptsServerOptions) {
+		opts.partialsDirs = append(opts.partialsDirs, dirs...)
+	}
+}
+
+// WithRecovery enables or disables recovery from panics in request handlers (enabled by default).
+// With recovery disabled, a panic in a template function is re-raised with its full stack trace,
+// which stops the server; this is meant for debugging template functions.
+func WithRecovery(enabled bool) Option {
+	return func(opts *promptsServerOptions) {
+		opts.recovery = enabled
+	}
+}
+
+// WithWatchMode sets how changes of the prompts directory are detected (WatchModeAuto by default)
+// and the interval between scans when polling.
+func WithWatchMode(mode WatchMode, pollInterval time.Duration) Option {
+	return func(opts *promptsServerOptions) {
+		opts.watchMode = mode
+		opts.pollInterval = pollInterval
+	}
+}
+
+// WithWatchExtensions also reloads the prompts when files with the extensions (e.g. ".txt") change
+// in the watched directories, in addition to templates and their configuration files.
+func WithWatchExtensions(extensions ...string) Option {
+	return func(opts *promptsServerOptions) {
+		opts.watchExtensions = extensions
+	}
+}
+
+// WithAccessLog records every GetPrompt request in the access log (disabled by default).
+func WithAccessLog(accessLog *AccessLog) Option {
+	return func(opts *promptsServerOptions) {
+		opts.accessLog = accessLog
+	}
+}
+
+// WithEvents emits the change events of the server to the event stream (disabled by default).
+// The stream is not closed with the server.
+func WithEvents(events *EventStream) Option {
+	return func(opts *promptsServerOptions) {
+		opts.events = events
+	}
+}
+
+// WithRenderCache reuses prompts rendered with the same template and arguments from the render cache
+// (disabled by default). Prompts using the current date, the session history, sampling, or schemas are never cached.
+// The cache is not closed with the server.
+func WithRenderCache(renderCache *RenderCache) Option {
+	return func(opts *promptsServerOptions) {
+		opts.renderCache = renderCache
+	}
+}
+
+// WithSessionHistory keeps the last size rendered prompts of every MCP session for the templates rendered later
+// in the same session, as .session_history and through the lastPrompt helper (0, the default, disables it).
+func WithSessionHistory(size int) Option {
+	return func(opts *promptsServerOptions) {
+		opts.sessionHistory = size
+	}
+}
+
+// WithHideDeprecated leaves prompts with a deprecation notice out of prompt listings (disabled by default).
+// Hidden prompts can still be requested by name.
+func WithHideDeprecated(enabled bool) Option {
+	return func(opts *promptsServerOptions) {
+		opts.hideDeprecated = enabled
+	}
+}
+
+// WithIncompatiblePrompts sets how prompt listings treat prompts whose min_protocol is newer than the protocol
+// version negotiated by the session: IncompatiblePromptsHide (the default) or IncompatiblePromptsMark.
+// Either way, requests for such prompts fail with an error naming the required version.
+func WithIncompatiblePrompts(mode IncompatiblePrompts) Option {
+	return func(opts *promptsServerOptions) {
+		opts.incompatible = mode
+	}
+}
+
+// WithMaxAdvertisedArgs advertises at most max arguments per prompt, the first ones in the argument order,
+// and notes the number of the others in the listed description (0, the default, advertises all).
+// GetPrompt requests still accept all arguments of the template.
+func WithMaxAdvertisedArgs(max int) Option {
+	return func(opts *promptsServerOptions) {
+		opts.maxAdvertised = max
+	}
+}
+
+// WithArgOrder sets the order in which the arguments of the prompts are advertised (alphabetical by default).
+func WithArgOrder(order ArgOrder) Option {
+	return func(opts *promptsServerOptions) {
+		opts.argOrder = order
+	}
+}
+
+// WithIconMode sets where the icons of the prompts are shown (IconModeTitle by default).
+func WithIconMode(mode IconMode) Option {
+	return func(opts *promptsServerOptions) {
+		opts.iconMode = mode
+	}
+}
+
+// WithStrictUnknownArgs rejects GetPrompt requests with arguments the template does not use,
+// instead of logging a warning (disabled by default).
+func WithStrictUnknownArgs(enabled bool) Option {
+	return func(opts *promptsServerOptions) {
+		opts.strictUnknown = enabled
+	}
+}
+
+// WithDefaultModel sets the model that selects the model overrides and the modelIs helper of the templates
+// for requests without the _model argument (none by default, which renders the base templates).
+func WithDefaultModel(model string) Option {
+	return func(opts *promptsServerOptions) {
+		opts.defaultModel = model
+	}
+}
+
+// WithTemplateOverrides lets SetTemplateOverride serve template sources instead of the template files of prompts
+// for a while, for experiments (disabled by default).
+func WithTemplateOverrides(enabled bool) Option {
+	return func(opts *promptsServerOptions) {
+		opts.overrides = enabled
+	}
+}
+
+// WithContextValues makes the values available to every prompt, below the arguments of the client.
+// Template arguments with a context value are not listed as prompt arguments.
+func WithContextValues(values map[string]string) Option {
+	return func(opts *promptsServerOptions) {
+		opts.contextValues = values
+	}
+}
+
+// WithVars makes the deployment variables available to every prompt under the vars field, e.g. {{.vars.base_url}}.
+// The variables are not prompt arguments.
+func WithVars(vars map[string]string) Option {
+	return func(opts *promptsServerOptions) {
+		opts.vars = vars
+	}
+}
+
+// WithStdinTimeout makes ServeStdio fail with errNoClient if no message arrives on stdin within the timeout
+// (0, the default, waits forever).
+func WithStdinTimeout(timeout time.Duration) Option {
+	return func(opts *promptsServerOptions) {
+		opts.stdinTimeout = timeout
+	}
+}
+
+// WithNameStyle sets how prompt names are derived from the names of the template files (as-is by default).
+func WithNameStyle(style NameStyle) Option {
+	return func(opts *promptsServerOptions) {
+		opts.nameStyle = style
+	}
+}
+
+// WithBuiltinDateName sets the field of the built-in date, freeing "date" for a prompt argument.
+func WithBuiltinDateName(name string) Option {
+	return func(opts *promptsServerOptions) {
+		opts.dateName = name
+	}
+}
+
+// WithFeatureFlags sets the flags file whose values the flag helper of the templates returns.
+// The file is read on every reload and watched like the templates.
+func WithFeatureFlags(path string) Option {
+	return func(opts *promptsServerOptions) {
+		opts.flagsFile = path
+	}
+}
+
+// WithTokenEstimator sets the estimator of the prompt sizes logged and shown by the server.
+func WithTokenEstimator(estimator TokenEstimator) Option {
+	return func(opts *promptsServerOptions) {
+		opts.tokenEstimator = estimator
+	}
+}
+
+// WithSensitivePattern marks the arguments of every prompt whose names match the pattern as sensitive,
+// in addition to those marked in the frontmatter.
+func WithSensitivePattern(pattern *regexp.Regexp) Option {
+	return func(opts *promptsServerOptions) {
+		opts.sensitive = pattern
+	}
+}
+
+// WithLogger sets the logger of the server (logs are discarded by default).
+func WithLogger(logger *slog.Logger) Option {
+	return func(opts *promptsServerOptions) {
+		opts.logger = logger
+	}
+}
+
+// WithGitTrackedOnly serves only the templates tracked by git when the prompts directory is inside a git worktree.
+// Tracking changes are picked up on the next reload, which changes of the git index trigger.
+func WithGitTrackedOnly(enabled bool) Option {
+	return func(opts *promptsServerOptions) {
+		opts.gitTrackedOnly = enabled
+	}
+}
+
+// WithRequireEnv makes NewPromptsServer fail, listing them, if environment variables of arguments declared with env
+// in the frontmatter are unset. It cannot be combined with RenderSettings.DisableEnvArgs.
+func WithRequireEnv(enabled bool) Option {
+	return func(opts *promptsServerOptions) {
+		opts.requireEnv = enabled
+	}
+}
+
+// WithReporter sets the receiver of the lifecycle events of the server (they are logged by default).
+func WithReporter(reporter ServerReporter) Option {
+	return func(opts *promptsServerOptions) {
+		opts.reporter = reporter
+	}
+}
+
+// NewPromptsServerWithSettings creates a PromptsServer with the positional signature that predates options.
+//
+// Deprecated: use NewPromptsServer with WithJSONArgs and WithLogger.
+func NewPromptsServerWithSettings(promptsDir string, enableJSONArgs bool, logger *slog.Logger) (*PromptsServer, error) {
+	return NewPromptsServer(promptsDir, WithJSONArgs(enableJSONArgs), WithLogger(logger))
 }
 
 // NewPromptsServer creates a new PromptsServer instance that serves prompts from the specified directory.
-func NewPromptsServer(
-	promptsDir string, enableJSONArgs bool, logger *slog.Logger,
-) (promptsServer *PromptsServer, err error) {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return nil, fmt.Errorf("create file watcher: %w", err)
+func NewPromptsServer(promptsDir string, opts ...Option) (promptsServer *PromptsServer, err error) {
+	options := promptsServerOptions{
+		enableJSONArgs: true,
+		argsLimits:     DefaultArgsLimits(),
+		recovery:       true,
+		watchMode:      WatchModeAuto,
+		pollInterval:   defaultWatchPollInterval,
+		tokenEstimator: defaultTokenEstimator,
+		iconMode:       IconModeTitle,
+		logger:         slog.New(slog.DiscardHandler),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.logger == nil {
+		options.logger = slog.New(slog.DiscardHandler)
+	}
+	if options.tokenEstimator == nil {
+		options.tokenEstimator = defaultTokenEstimator
+	}
+	logger := options.logger
+	if options.reporter == nil {
+		options.reporter = logServerReporter{logger: logger}
 	}
-	defer func() {
-		if err != nil {
-			if closeErr := watcher.Close(); closeErr != nil {
-				logger.Error("Failed to close file watcher", "error", closeErr)
-			}
-		}
-	}()
 
-	if err = watcher.Add(promptsDir); err != nil {
-		return nil, fmt.Errorf("add prompts directory to watcher: %w", err)
+	if options.pollInterval <= 0 {
+		options.pollInterval = defaultWatchPollInterval
+	}
+	if options.requireEnv && options.renderSettings.DisableEnvArgs {
+		return nil, errors.New("required environment variables cannot be checked with environment arguments disabled")
+	}
+	if options.flagsFile != "" {
+		// Events of the watcher name cleaned paths
+		options.flagsFile = filepath.Clean(options.flagsFile)
+	}
+	// The independent initialization steps all run, so that every failure is reported at once
+	var initErrs []error
+	var gitIndex string
+	if options.gitTrackedOnly {
+		var indexErr error
+		if gitIndex, indexErr = gitIndexPath(promptsDir); errors.Is(indexErr, errNotGitWorktree) {
+			logger.Warn("Prompts directory is not inside a git worktree, serving untracked templates too", "dir", promptsDir)
+		} else if indexErr != nil {
+			initErrs = append(initErrs, fmt.Errorf("find git index: %w", indexErr))
+		}
+	}
+	var watcher fileWatcher
+	if options.watchMode == WatchModeAuto || options.watchMode == WatchModeFSNotify {
+		var watchErr error
+		if watcher, watchErr = newFSNotifyWatcher(promptsDir, options.partialsDirs, gitIndex, options.flagsFile); watchErr != nil {
+			initErrs = append(initErrs, fmt.Errorf("watch prompts directory: %w", watchErr))
+		}
 	}
 
 	srvHooks := &server.Hooks{}
 	srvHooks.AddBeforeGetPrompt(func(ctx context.Context, id any, message *mcp.GetPromptRequest) {
-		logger.Info("Received prompt request",
-			"id", id, "params_name", message.Params.Name, "params_args", message.Params.Arguments)
+		logger.Info("Received prompt request", "id", id, "params_name", message.Params.Name,
+			"params_args", promptsServer.sensitiveArgsOf(message.Params.Name).ScrubArgs(message.Params.Arguments))
+		if notice := promptsServer.deprecationNotice(message.Params.Name); notice != "" {
+			logger.Warn("Deprecated prompt requested", "id", id, "params_name", message.Params.Name, "deprecated", notice)
+		}
 	})
 	srvHooks.AddAfterGetPrompt(func(ctx context.Context, id any, message *mcp.GetPromptRequest, result *mcp.GetPromptResult) {
-		logger.Info("Processed prompt request",
-			"id", id, "params_name", message.Params.Name, "params_args", message.Params.Arguments)
+		logger.Info("Processed prompt request", "id", id, "params_name", message.Params.Name,
+			"params_args", promptsServer.sensitiveArgsOf(message.Params.Name).ScrubArgs(message.Params.Arguments),
+			"estimated_tokens", promptsServer.estimateResultTokens(result))
 
 	})
-	mcpServer := server.NewMCPServer(
-		"Prompts Engine MCP Server",
-		"1.0.0",
+	srvHooks.AddAfterInitialize(func(ctx context.Context, id any, message *mcp.InitializeRequest, result *mcp.InitializeResult) {
+		promptsServer.recordSessionProtocol(ctx, result.ProtocolVersion)
+	})
+	srvHooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		promptsServer.forgetSessionProtocol(session.SessionID())
+	})
+	srvHooks.AddAfterListPrompts(func(ctx context.Context, id any, message *mcp.ListPromptsRequest, result *mcp.ListPromptsResult) {
+		if promptsServer.hideDeprecated {
+			result.Prompts = promptsServer.withoutDeprecated(result.Prompts)
+		}
+		result.Prompts = promptsServer.withIncompatiblePrompts(ctx, result.Prompts)
+		promptsServer.sortListedPrompts(result.Prompts)
+	})
+	if options.sessionHistory > 0 {
+		srvHooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+			promptsServer.sessionHistory.Clear(session.SessionID())
+		})
+	}
+	serverOpts := []server.ServerOption{
 		server.WithLogging(),
-		server.WithRecovery(),
 		server.WithHooks(srvHooks),
 		server.WithPromptCapabilities(true),
-	)
+	}
+	if options.recovery {
+		serverOpts = append(serverOpts, server.WithRecovery())
+	}
+	mcpServer := server.NewMCPServer("Prompts Engine MCP Server", "1.0.0", serverOpts...)
+	if options.renderSettings.AllowSampling {
+		mcpServer.EnableSampling()
+	}
 
+	parser := &PromptsParser{
+		partialsDirs: options.partialsDirs, capturePanics: !options.recovery,
+		extractor: defaultExtractorOptions, builtinDateName: options.dateName,
+	}
 	promptsServer = &PromptsServer{
 		mcpServer:      mcpServer,
-		parser:         &PromptsParser{},
+		parser:         parser,
 		promptsDir:     promptsDir,
-		enableJSONArgs: enableJSONArgs,
+		enableJSONArgs: options.enableJSONArgs,
+		recovery:       options.recovery,
+		accessLog:      options.accessLog,
+		events:         options.events,
+		renderCache:    options.renderCache,
+		stdinTimeout:   options.stdinTimeout,
+		hideDeprecated: options.hideDeprecated,
+		protocols:      make(map[string]string),
+		contextValues:  options.contextValues,
+		vars:           options.vars,
+		nameStyle:      options.nameStyle,
+		tokenEstimator: options.tokenEstimator,
 		logger:         logger,
+		reporter:       options.reporter,
 		watcher:        watcher,
+
+		strictUnknownArgs:   options.strictUnknown,
+		defaultModel:        options.defaultModel,
+		enableOverrides:     options.overrides,
+		overrides:           make(map[string]*templateOverride),
+		incompatiblePrompts: options.incompatible,
+		maxAdvertisedArgs:   options.maxAdvertised,
+		argOrder:            options.argOrder,
+		iconMode:            options.iconMode,
+		sensitivePattern:    options.sensitive,
+
+		watchMode:          options.watchMode,
+		watchPollInterval:  options.pollInterval,
+		watchExtensions:    options.watchExtensions,
+		watchSweepInterval: defaultWatchSweepInterval,
+		watchStatsInterval: defaultWatchStatsInterval,
+
+		gitIndexPath: gitIndex,
+		flagsFile:    options.flagsFile,
+
+		flagSettings: runtimeSettings{argsLimits: options.argsLimits, renderSettings: options.renderSettings},
+		configPath:   options.configPath,
+		logLevel:     options.logLevel,
+		safeMode:     options.safeMode,
+	}
+	if options.logLevel != nil {
+		promptsServer.flagSettings.logLevel = options.logLevel.Level()
+	}
+	// On errors, the settings of the flags are kept, so that the prompts still load and report their own errors
+	settings, configErr := promptsServer.loadRuntimeConfig()
+	if configErr != nil {
+		initErrs = append(initErrs, fmt.Errorf("load runtime config: %w", configErr))
+	}
+	settings.version = 1
+	promptsServer.runtime.Store(&settings)
+	if options.logLevel != nil {
+		options.logLevel.Set(settings.logLevel)
+	}
+	if gitIndex != "" {
+		parser.filter = promptsServer.isTracked
+	}
+	if options.sessionHistory > 0 {
+		promptsServer.sessionHistory = newSessionHistory(options.sessionHistory)
 	}
 
-	if err = promptsServer.reloadPrompts(); err != nil {
-		return nil, fmt.Errorf("reload prompts: %w", err)
+	if reloadErr := promptsServer.reloadPrompts(); reloadErr != nil {
+		initErrs = append(initErrs, fmt.Errorf("reload prompts: %w", reloadErr))
+	} else if options.requireEnv {
+		// Required variables are those of the loaded prompts
+		if envErr := promptsServer.checkRequiredEnv(); envErr != nil {
+			initErrs = append(initErrs, envErr)
+		}
+	}
+	if len(initErrs) > 0 {
+		// The components that did initialize are released, and failures to release them are reported too
+		if watcher != nil {
+			if closeErr := watcher.Close(); closeErr != nil {
+				initErrs = append(initErrs, fmt.Errorf("close file watcher: %w", closeErr))
+			}
+		}
+		return nil, errors.Join(initErrs...)
 	}
+	promptsServer.emitEvent(Event{Type: EventServerStarted, Prompts: slices.Sorted(maps.Keys(promptsServer.promptChecksums))})
 
 	return promptsServer, nil
 }
 
+// Close waits for a reload in progress to finish, then releases the resources of the server.
+// It attempts to release all of them and returns the errors of every one that failed, joined.
+// The preview and control servers stop with the context of their Serve methods instead.
 func (ps *PromptsServer) Close() error {
+	ps.closeOnce.Do(func() { ps.emitEvent(Event{Type: EventShutdown}) })
+	ps.reloadMu.Lock()
+	defer ps.reloadMu.Unlock()
+
+	ps.stopTemplateOverrides()
+	var errs []error
 	if ps.watcher != nil {
 		if err := ps.watcher.Close(); err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("close file watcher: %w", err))
 		}
 		ps.watcher = nil
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 // ServeStdio starts the MCP server with stdio transport and file watching.
 func (ps *PromptsServer) ServeStdio(ctx context.Context, stdin io.Reader, stdout io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	var wg sync.WaitGroup
 
+	var stdinTimedOut atomic.Bool
+	if ps.stdinTimeout > 0 {
+		received := make(chan struct{})
+		stdin = &firstReadNotifier{r: stdin, received: received}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			timer := time.NewTimer(ps.stdinTimeout)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+				ps.reporter.Report(ServerEvent{Level: slog.LevelWarn, Message: "No client connected, stopping server",
+					Attrs: []any{"stdin_timeout", ps.stdinTimeout}})
+				stdinTimedOut.Store(true)
+				cancel()
+			case <-received:
+			case <-ctx.Done():
+			}
+		}()
+	}
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -106,164 +718,1009 @@ func (ps *PromptsServer) ServeStdio(ctx context.Context, stdin io.Reader, stdout
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		ps.logger.Info("Starting stdio server")
-		srvErrChan <- server.NewStdioServer(ps.mcpServer).Listen(ctx, stdin, stdout)
+		ps.reporter.Report(ServerEvent{Level: slog.LevelInfo, Message: "Starting stdio server"})
+		srvErrChan <- ps.listenStdio(ctx, stdin, stdout)
 	}()
 
 	var srvErr error
 	select {
 	case srvErr = <-srvErrChan:
 		if srvErr != nil {
-			ps.logger.Error("Stdio server error", "error", srvErr)
+			ps.reporter.Report(ServerEvent{Level: slog.LevelError, Message: "Stdio server error", Attrs: []any{"error", srvErr}})
 		}
 	case <-ctx.Done():
-		ps.logger.Info("Context cancelled, stopping server")
+		ps.reporter.Report(ServerEvent{Level: slog.LevelInfo, Message: "Context cancelled, stopping server"})
 	}
 
 	wg.Wait()
 
+	if stdinTimedOut.Load() {
+		return fmt.Errorf("%w within %s", errNoClient, ps.stdinTimeout)
+	}
 	return srvErr
 }
 
-func (ps *PromptsServer) loadServerPrompts() ([]server.ServerPrompt, error) {
-	tmpl, err := ps.parser.ParseDir(ps.promptsDir)
+// firstReadNotifier closes the received channel once the first data is read from r.
+type firstReadNotifier struct {
+	r        io.Reader
+	received chan struct{}
+	once     sync.Once
+}
+
+func (n *firstReadNotifier) Read(p []byte) (int, error) {
+	count, err := n.r.Read(p)
+	if count > 0 {
+		n.once.Do(func() { close(n.received) })
+	}
+	return count, err
+}
+
+// loadedPrompts is the result of loadServerPrompts.
+type loadedPrompts struct {
+	serverPrompts   []server.ServerPrompt
+	checksums       map[string]string        // SHA-256 checksums of the template files by prompt name
+	sensitive       map[string]sensitiveArgs // sensitive arguments by prompt name
+	staticEstimates *staticTokenEstimates
+	templateNames   map[string]string // template file names by prompt name
+	featureFlags    *FeatureFlags
+	skipped         []*unknownFuncError // template files calling undefined functions
+	warnings        []string            // warnings logged while loading, see LoadSummary
+}
+
+// loadServerPrompts parses the prompts directory and returns the prompts to register.
+func (ps *PromptsServer) loadServerPrompts(collections *PromptCollections) (*loadedPrompts, error) {
+	tmpl, skipped, err := ps.parser.ParseDirSkippingUnknownFuncs(ps.promptsDir)
 	if err != nil {
 		return nil, fmt.Errorf("parse all prompts: %w", err)
 	}
+	var warnings []string
+	warn := func(msg string, args ...any) {
+		ps.logger.Warn(msg, args...)
+		warnings = append(warnings, formatLoadWarning(msg, args...))
+	}
+	skippedFiles := make(map[string]bool, len(skipped))
+	for _, unknownFunc := range skipped {
+		skippedFiles[unknownFunc.file] = true
+		warn("Skipping template file that calls an undefined function",
+			"file", unknownFunc.file, "function", unknownFunc.function, "hint", unknownFunc.Hint())
+	}
 
 	files, err := os.ReadDir(ps.promptsDir)
 	if err != nil {
 		return nil, fmt.Errorf("read prompts directory: %w", err)
 	}
 
+	templateOverrides, err := ps.applyTemplateOverrides(tmpl, warn)
+	if err != nil {
+		return nil, err
+	}
+
+	featureFlags, err := loadFeatureFlags(ps.flagsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	modelOverrides := findModelOverrides(files)
+	for _, base := range slices.Sorted(maps.Keys(modelOverrides)) {
+		if !slices.ContainsFunc(files, func(file os.DirEntry) bool { return file.Name() == base }) {
+			warn("Ignoring model overrides without a base template",
+				"template", base, "models", modelPatterns(modelOverrides[base]))
+		}
+	}
+
+	var funcsConfigHash string
+	if ps.renderCache != nil {
+		funcsConfig, err := os.ReadFile(filepath.Join(ps.promptsDir, funcsFileName))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("read funcs config: %w", err)
+		}
+		funcsConfigHash = hashContent(funcsConfig)
+	}
+
 	var serverPrompts []server.ServerPrompt
+	checksums := make(map[string]string)
+	sensitive := make(map[string]sensitiveArgs)
+	templateNames := make(map[string]string) // template file names by prompt name
+
+	var fileErrs []error // errors of the template files, joined once all of them are loaded
 	for _, file := range files {
-		if !isTemplateFile(file) {
+		if !isTemplateFile(file) || !ps.parser.includes(file.Name()) {
 			continue
 		}
 
 		filePath := filepath.Join(ps.promptsDir, file.Name())
 
 		templateName := file.Name()
+		if skippedFiles[templateName] {
+			continue
+		}
 		if tmpl.Lookup(templateName) == nil {
-			return nil, fmt.Errorf("template %q not found", templateName)
+			fileErrs = append(fileErrs, fmt.Errorf("template %q not found", templateName))
+			continue
+		}
+
+		// The file is read once for its description, frontmatter, and checksum, so that reloads allocate less
+		var content []byte
+		if content, err = os.ReadFile(filePath); err != nil {
+			fileErrs = append(fileErrs, fmt.Errorf("read %q template file: %w", filePath, err))
+			continue
+		}
+		templateOverride := templateOverrides[templateName]
+		if templateOverride != nil {
+			content = templateOverride.source
 		}
 
 		var description string
-		if description, err = ps.parser.ExtractPromptDescriptionFromFile(filePath); err != nil {
-			return nil, fmt.Errorf("extract prompt description from %q template file: %w", filePath, err)
+		if description, err = ps.parser.ExtractPromptDescription(content); err != nil {
+			fileErrs = append(fileErrs, fmt.Errorf("extract prompt description from %q template file: %w", filePath, err))
+			continue
+		}
+
+		var frontmatter *PromptFrontmatter
+		if frontmatter, err = parseFrontmatter(content, ps.parser.dateName()); err != nil {
+			fileErrs = append(fileErrs, fmt.Errorf("load frontmatter of %q template file: %w", filePath, err))
+			continue
+		}
+
+		// Overrides skipped for undefined functions are left out, so the requests for their models get the base template
+		overrides := slices.DeleteFunc(slices.Clone(modelOverrides[templateName]), func(override modelOverride) bool {
+			return skippedFiles[override.templateName]
+		})
+		overridesContent := content
+		for _, override := range overrides {
+			var overrideContent []byte
+			if overrideContent, err = os.ReadFile(filepath.Join(ps.promptsDir, override.templateName)); err != nil {
+				break
+			}
+			overridesContent = append(slices.Clip(overridesContent), overrideContent...)
+		}
+		if err != nil {
+			fileErrs = append(fileErrs, fmt.Errorf("read model overrides of %q template file: %w", filePath, err))
+			continue
 		}
 
 		var args []string
-		if args, err = ps.parser.ExtractPromptArgumentsFromTemplate(tmpl, templateName); err != nil {
-			return nil, fmt.Errorf("extract prompt arguments from %q template file: %w", filePath, err)
+		if args, err = ps.parser.extractVariantArguments(tmpl, templateName, overrides); err != nil {
+			var missingPartial *missingPartialError
+			if errors.As(err, &missingPartial) && (skippedFiles[missingPartial.name] ||
+				skippedFiles[missingPartial.name+filepath.Ext(templateName)]) {
+				warn("Skipping template file that includes a skipped template",
+					"file", templateName, "partial", missingPartial.name)
+				continue
+			}
+			fileErrs = append(fileErrs, fmt.Errorf("extract prompt arguments from %q template file: %w", filePath, err))
+			continue
+		}
+		args = frontmatter.ExcludeConstants(args)
+
+		var inlineDocs map[string]string
+		if inlineDocs, err = ps.parser.ExtractArgumentDocs(tmpl, templateName); err != nil {
+			fileErrs = append(fileErrs, fmt.Errorf("extract argument docs from %q template file: %w", filePath, err))
+			continue
+		}
+		for _, override := range overrides {
+			var overrideDocs map[string]string
+			if overrideDocs, err = ps.parser.ExtractArgumentDocs(tmpl, override.templateName); err != nil {
+				break
+			}
+			for arg, doc := range overrideDocs {
+				if _, exists := inlineDocs[arg]; !exists {
+					inlineDocs[arg] = doc
+				}
+			}
+		}
+		if err != nil {
+			fileErrs = append(fileErrs, fmt.Errorf("extract argument docs from %q template file: %w", filePath, err))
+			continue
+		}
+		argDocs, _ := frontmatter.ArgumentDocs(inlineDocs)
+
+		var rangeNests []rangeNest
+		for _, variant := range variantTemplates(templateName, overrides) {
+			var variantNests []rangeNest
+			if variantNests, err = ps.parser.ExtractRangeNests(tmpl, variant); err != nil {
+				break
+			}
+			rangeNests = append(rangeNests, variantNests...)
+		}
+		if err != nil {
+			fileErrs = append(fileErrs, fmt.Errorf("extract range nests from %q template file: %w", filePath, err))
+			continue
 		}
 
 		envArgs := make(map[string]string)
 		var promptArgs []string
 		for _, arg := range args {
+			if _, exists := ps.contextValues[arg]; exists {
+				continue
+			}
+			if ps.renderSettings().DisableEnvArgs {
+				promptArgs = append(promptArgs, arg)
+				continue
+			}
 			// Convert arg to TITLE_CASE for env var
 			envVarName := strings.ToUpper(arg)
 			if envValue, exists := os.LookupEnv(envVarName); exists {
-				envArgs[arg] = envValue
+				envArgs[arg] = frontmatter.TransformArg(arg, envValue)
 			} else {
 				promptArgs = append(promptArgs, arg)
 			}
 		}
 
+		promptName := ps.nameStyle.PromptName(templateName)
+		if other, exists := templateNames[promptName]; exists {
+			fileErrs = append(fileErrs,
+				fmt.Errorf("templates %q and %q have the same prompt name %q", other, templateName, promptName))
+			continue
+		}
+		templateNames[promptName] = templateName
+		promptSensitive := sensitiveArgs{frontmatter: frontmatter, pattern: ps.sensitivePattern}
+		sensitive[promptName] = promptSensitive
+
+		var promptMeta *mcp.Meta
+		if title := collections.Title(promptName); title != "" {
+			collectionName, _, _ := collections.Lookup(promptName)
+			if description != "" {
+				description = title + " - " + description
+			} else {
+				description = title
+			}
+			promptMeta = &mcp.Meta{AdditionalFields: map[string]any{
+				"title":      title,
+				"collection": collectionName,
+			}}
+		}
+		var icon string
+		if icon, err = ps.parser.ExtractPromptIcon(tmpl, templateName); err != nil {
+			fileErrs = append(fileErrs, fmt.Errorf("extract icon from %q template file: %w", filePath, err))
+			continue
+		}
+		if icon == "" {
+			icon = collections.Icon(promptName)
+		}
+		if icon != "" {
+			switch ps.iconMode {
+			case IconModeTitle:
+				if promptMeta == nil {
+					promptMeta = &mcp.Meta{AdditionalFields: map[string]any{"title": promptName}}
+				}
+				title, _ := promptMeta.AdditionalFields["title"].(string)
+				promptMeta.AdditionalFields["title"] = withIcon(icon, title)
+			case IconModeDescription:
+				description = withIcon(icon, description)
+			}
+		}
+		if notice := frontmatter.DeprecationNotice(); notice != "" {
+			if promptMeta == nil {
+				promptMeta = &mcp.Meta{AdditionalFields: map[string]any{}}
+			}
+			promptMeta.AdditionalFields["deprecated"] = notice
+		}
+		if minProtocol := frontmatter.MinProtocolVersion(); minProtocol != "" {
+			if promptMeta == nil {
+				promptMeta = &mcp.Meta{AdditionalFields: map[string]any{}}
+			}
+			promptMeta.AdditionalFields["min_protocol"] = minProtocol
+		}
+
+		if ps.argOrder != ArgOrderTemplate {
+			slices.Sort(promptArgs)
+		}
+		advertisedArgs, listedDescription := promptArgs, description
+		if ps.maxAdvertisedArgs > 0 && len(promptArgs) > ps.maxAdvertisedArgs {
+			// Clients show every advertised argument, so only the first ones are advertised;
+			// requests can still set all of them
+			advertisedArgs = promptArgs[:ps.maxAdvertisedArgs]
+			listedDescription = strings.TrimSpace(fmt.Sprintf("%s (and %d more arguments)",
+				description, len(promptArgs)-ps.maxAdvertisedArgs))
+			warn("Prompt has too many arguments, advertising only the first ones",
+				"name", promptName, "args", len(promptArgs), "advertised", ps.maxAdvertisedArgs)
+		}
 		promptOpts := []mcp.PromptOption{
-			mcp.WithPromptDescription(description),
+			mcp.WithPromptDescription(listedDescription),
+		}
+		for _, promptArg := range advertisedArgs {
+			var argOpts []mcp.ArgumentOption
+			if doc := argDocs[promptArg]; doc != "" {
+				argOpts = append(argOpts, mcp.ArgumentDescription(doc))
+			}
+			promptOpts = append(promptOpts, mcp.WithArgument(promptArg, argOpts...))
 		}
-		for _, promptArg := range promptArgs {
-			promptOpts = append(promptOpts, mcp.WithArgument(promptArg))
+		prompt := mcp.NewPrompt(promptName, promptOpts...)
+		prompt.Meta = promptMeta
+
+		templateHash := hashContent(overridesContent)
+		checksums[promptName] = templateHash
+		var resultMeta *mcp.Meta
+		if ps.renderSettings().Provenance || templateOverride != nil {
+			provenance := map[string]any{
+				"template": templateName,
+				"sha256":   templateHash,
+			}
+			// Results of overrides are always marked, so they are not mistaken for those of the file
+			if templateOverride != nil {
+				provenance["override"] = map[string]any{"expires_at": templateOverride.expiresAt.Format(time.RFC3339)}
+			}
+			resultMeta = &mcp.Meta{AdditionalFields: map[string]any{"provenance": provenance}}
 		}
 
-		promptName := strings.TrimSuffix(file.Name(), templateExt)
+		var cacheKeyInput *renderCacheKeyInput
+		// Cached texts are stored on disk, so prompts with sensitive arguments are never cached. The variant of prompts
+		// with model overrides depends on the default model, which is not part of the key, so they are not cached either,
+		// nor are the texts of template overrides, which are temporary.
+		if ps.renderCache != nil && !promptSensitive.ContainsAny(args) && len(overrides) == 0 && templateOverride == nil {
+			var partials []string
+			if partials, err = ps.parser.ExtractPromptPartialsFromTemplate(tmpl, templateName); err != nil {
+				fileErrs = append(fileErrs, fmt.Errorf("extract prompt partials from %q template file: %w", filePath, err))
+				continue
+			}
+			if templateIsCacheable(tmpl, templateName, partials, ps.parser.impureFields()) {
+				cacheKeyInput = &renderCacheKeyInput{
+					ServerVersion: version,
+					TemplateHash:  templateHash,
+					Partials:      partialTrees(tmpl, partials),
+					FuncsConfig:   funcsConfigHash,
+					FeatureFlags:  featureFlags.values,
+					EnvArgs:       envArgs,
+					ContextValues: ps.contextValues,
+					Vars:          ps.vars,
+					JSONArgs:      ps.enableJSONArgs,
+					LineEndings:   ps.renderSettings().LineEndings,
+				}
+			}
+		}
 
 		serverPrompts = append(serverPrompts, server.ServerPrompt{
-			Prompt:  mcp.NewPrompt(promptName, promptOpts...),
-			Handler: ps.makeMCPHandler(tmpl, templateName, description, envArgs),
+			Prompt: prompt,
+			Handler: ps.makeMCPHandler(
+				tmpl, templateName, templateHash, description, args, envArgs, frontmatter, promptSensitive, resultMeta,
+				cacheKeyInput, rangeNests, overrides,
+			),
 		})
 
 		ps.logger.Info("Prompt will be registered",
 			"name", promptName,
 			"description", description,
 			"prompt_args", promptArgs,
-			"env_args", envArgs)
+			"env_args", promptSensitive.ScrubArgs(envArgs),
+			"static", len(args) == 0,
+			"model_overrides", modelPatterns(overrides),
+			"template_override", templateOverride != nil,
+			"cached", cacheKeyInput != nil)
+	}
+	if len(fileErrs) > 0 {
+		return nil, errors.Join(fileErrs...)
 	}
 
-	return serverPrompts, nil
+	return &loadedPrompts{
+		serverPrompts:   serverPrompts,
+		checksums:       checksums,
+		sensitive:       sensitive,
+		staticEstimates: newStaticTokenEstimates(tmpl, ps.promptsDir, ps.parser.dateName(), ps.tokenEstimator),
+		templateNames:   templateNames,
+		featureFlags:    featureFlags,
+		skipped:         skipped,
+		warnings:        warnings,
+	}, nil
+}
+
+// skippedTemplates returns the template files that the last load skipped for calling undefined functions.
+func (ps *PromptsServer) skippedTemplates() []*unknownFuncError {
+	ps.skippedMu.Lock()
+	defer ps.skippedMu.Unlock()
+	return ps.skipped
+}
+
+// Reload parses the prompts directory again and registers its prompts, like a detected change of the directory.
+// It is safe to call while the server is running.
+func (ps *PromptsServer) Reload() error {
+	return ps.reloadPrompts()
 }
 
 func (ps *PromptsServer) reloadPrompts() error {
-	newServerPrompts, err := ps.loadServerPrompts()
+	ps.reloadMu.Lock()
+	defer ps.reloadMu.Unlock()
+	return ps.reloadPromptsLocked(ps.watchedContentHash())
+}
+
+// reloadChangedPrompts reloads the prompts unless the contents of the watched files are the same as at the last
+// reload, e.g. after notifications of changed permissions or modification times only. It reports whether it reloaded.
+func (ps *PromptsServer) reloadChangedPrompts() (bool, error) {
+	ps.reloadMu.Lock()
+	defer ps.reloadMu.Unlock()
+	contentHash := ps.watchedContentHash()
+	if contentHash != "" && contentHash == ps.contentHash {
+		return false, nil
+	}
+	return true, ps.reloadPromptsLocked(contentHash)
+}
+
+// reloadPromptsLocked reloads the prompts and, on success, records the hash of the watched files they were loaded from.
+// The caller must hold reloadMu.
+func (ps *PromptsServer) reloadPromptsLocked(contentHash string) error {
+	before := ps.promptChecksums
+	if err := ps.loadPrompts(); err != nil {
+		if before != nil {
+			ps.emitEvent(Event{Type: EventReloadFailed, Errors: ps.templateFileErrors(err)})
+		}
+		return err
+	}
+	ps.contentHash = contentHash
+	if before != nil {
+		added, removed, changed := diffPromptChecksums(before, ps.promptChecksums)
+		ps.logger.Info("Prompts reloaded", "added", added, "removed", removed, "changed", changed)
+		ps.emitEvent(Event{Type: EventReloadSucceeded, Added: added, Removed: removed, Changed: changed})
+	}
+	return nil
+}
+
+// loadPrompts parses the templates and registers their prompts, replacing the registered ones.
+func (ps *PromptsServer) loadPrompts() error {
+	if ps.gitIndexPath != "" {
+		if err := ps.refreshTracked(); err != nil {
+			return fmt.Errorf("list git tracked files: %w", err)
+		}
+	}
+	collections, err := loadPromptCollections(ps.promptsDir)
+	if err != nil {
+		return fmt.Errorf("load prompt collections: %w", err)
+	}
+	collections = collections.WithNameStyle(ps.nameStyle)
+
+	loaded, err := ps.loadServerPrompts(collections)
 	if err != nil {
 		return fmt.Errorf("load server prompts: %w", err)
 	}
+	newServerPrompts := loaded.serverPrompts
+
+	libraryPartials, err := findLibraryPartials(ps.promptsDir, ps.parser.partialsDirs)
+	if err != nil {
+		return fmt.Errorf("find library partials: %w", err)
+	}
+	for _, partial := range libraryPartials {
+		if partial.overridden {
+			ps.logger.Info("Local partial overrides library partial", "partial", partial.fileName, "library_dir", partial.dir)
+		} else if partial.shadowedBy != "" {
+			ps.logger.Info("Library partial is shadowed by an earlier partials directory",
+				"partial", partial.fileName, "library_dir", partial.dir, "used_dir", partial.shadowedBy)
+		}
+	}
+
+	promptNames := make([]string, 0, len(newServerPrompts))
+	deprecated := make(map[string]string)
+	minProtocols := make(map[string]string)
+	for _, serverPrompt := range newServerPrompts {
+		promptNames = append(promptNames, serverPrompt.Prompt.Name)
+		if serverPrompt.Prompt.Meta != nil {
+			if notice, ok := serverPrompt.Prompt.Meta.AdditionalFields["deprecated"].(string); ok {
+				deprecated[serverPrompt.Prompt.Name] = notice
+			}
+			if minProtocol, ok := serverPrompt.Prompt.Meta.AdditionalFields["min_protocol"].(string); ok {
+				minProtocols[serverPrompt.Prompt.Name] = minProtocol
+			}
+		}
+	}
+	ps.promptOrderMu.Lock()
+	ps.promptOrder = collections.Order(promptNames)
+	ps.promptOrderMu.Unlock()
+	ps.deprecatedMu.Lock()
+	ps.deprecated = deprecated
+	ps.deprecatedMu.Unlock()
+	ps.minProtocolsMu.Lock()
+	ps.minProtocols = minProtocols
+	ps.minProtocolsMu.Unlock()
+	ps.sensitiveMu.Lock()
+	ps.sensitive = loaded.sensitive
+	ps.sensitiveMu.Unlock()
+	ps.staticMu.Lock()
+	ps.staticEstimates, ps.templateNames = loaded.staticEstimates, loaded.templateNames
+	ps.staticMu.Unlock()
+	ps.featureFlags.Store(loaded.featureFlags)
+	ps.skippedMu.Lock()
+	ps.skipped = loaded.skipped
+	ps.skippedMu.Unlock()
+	summary := &LoadSummary{Prompts: slices.Sorted(maps.Keys(loaded.templateNames)), Warnings: loaded.warnings}
+	for _, unknownFunc := range loaded.skipped {
+		summary.Skipped = append(summary.Skipped, unknownFunc.file)
+	}
+	ps.loadSummary.Store(summary)
+	// Forget the logged unused arguments of removed prompts, so that renamed prompts do not accumulate keys
+	ps.unusedArgsWarned.Range(func(key, _ any) bool {
+		promptName, _, _ := strings.Cut(key.(string), "\x00")
+		if _, registered := loaded.templateNames[promptName]; !registered {
+			ps.unusedArgsWarned.Delete(key)
+		}
+		return true
+	})
 
 	ps.mcpServer.SetPrompts(newServerPrompts...)
 	ps.logger.Info("Prompts registered", "count", len(newServerPrompts))
+	ps.promptChecksums = loaded.checksums
 
 	return nil
 }
 
+// refreshTracked lists the files tracked by git and logs the templates left out for not being tracked.
+func (ps *PromptsServer) refreshTracked() error {
+	tracked, err := gitTrackedFiles(ps.promptsDir)
+	if err != nil {
+		return err
+	}
+	ps.trackedMu.Lock()
+	ps.tracked = tracked
+	ps.trackedMu.Unlock()
+
+	untracked, err := untrackedTemplates(ps.promptsDir, tracked)
+	if err != nil {
+		return err
+	}
+	if len(untracked) > 0 {
+		ps.logger.Warn("Untracked templates are not served", "templates", untracked)
+	}
+	return nil
+}
+
+// isTracked reports whether the file of the prompts directory is tracked by git, as of the last reload.
+func (ps *PromptsServer) isTracked(fileName string) bool {
+	ps.trackedMu.RLock()
+	defer ps.trackedMu.RUnlock()
+	return ps.tracked[fileName]
+}
+
+// StaticTokenEstimate returns the estimated tokens of the static text of the prompt, i.e. rendered without
+// arguments. It is computed on first use and kept until the prompts are reloaded.
+func (ps *PromptsServer) StaticTokenEstimate(promptName string) (int, error) {
+	ps.staticMu.RLock()
+	estimates := ps.staticEstimates
+	templateName, ok := ps.templateNames[promptName]
+	ps.staticMu.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("prompt %q not found", promptName)
+	}
+	return estimates.Get(templateName)
+}
+
+// estimateResultTokens returns the estimated tokens of the text messages of the result.
+func (ps *PromptsServer) estimateResultTokens(result *mcp.GetPromptResult) int {
+	if result == nil {
+		return 0
+	}
+	tokens := 0
+	for _, message := range result.Messages {
+		if content, ok := message.Content.(mcp.TextContent); ok {
+			tokens += ps.tokenEstimator.EstimateTokens(content.Text)
+		}
+	}
+	return tokens
+}
+
+// sortListedPrompts orders prompts by collection and then by declaration order within the collection.
+func (ps *PromptsServer) sortListedPrompts(prompts []mcp.Prompt) {
+	ps.promptOrderMu.RLock()
+	defer ps.promptOrderMu.RUnlock()
+
+	sort.SliceStable(prompts, func(i, j int) bool {
+		oi, iok := ps.promptOrder[prompts[i].Name]
+		oj, jok := ps.promptOrder[prompts[j].Name]
+		if iok != jok {
+			return iok
+		}
+		return oi < oj
+	})
+}
+
+// diffPromptChecksums compares the template checksums of the prompts before and after a reload.
+// Entries are sorted by prompt name and include abbreviated checksums, e.g. "greeting@1a2b3c4d5e6f"
+// for added and removed prompts and "greeting@1a2b3c4d5e6f->9f8e7d6c5b4a" for changed ones.
+func diffPromptChecksums(before, after map[string]string) (added, removed, changed []string) {
+	added, removed, changed = []string{}, []string{}, []string{}
+	for _, name := range slices.Sorted(maps.Keys(after)) {
+		previous, existed := before[name]
+		switch {
+		case !existed:
+			added = append(added, name+"@"+shortChecksum(after[name]))
+		case previous != after[name]:
+			changed = append(changed, name+"@"+shortChecksum(previous)+"->"+shortChecksum(after[name]))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(before)) {
+		if _, exists := after[name]; !exists {
+			removed = append(removed, name+"@"+shortChecksum(before[name]))
+		}
+	}
+	return added, removed, changed
+}
+
+func shortChecksum(checksum string) string {
+	if len(checksum) > 12 {
+		return checksum[:12]
+	}
+	return checksum
+}
+
+// deprecationNotice returns the deprecation notice of the prompt, or an empty string if it is not deprecated.
+func (ps *PromptsServer) deprecationNotice(promptName string) string {
+	ps.deprecatedMu.RLock()
+	defer ps.deprecatedMu.RUnlock()
+	return ps.deprecated[promptName]
+}
+
+// sensitiveArgsOf returns the sensitive arguments of the prompt. For unknown prompts, only the pattern applies.
+func (ps *PromptsServer) sensitiveArgsOf(promptName string) sensitiveArgs {
+	ps.sensitiveMu.RLock()
+	defer ps.sensitiveMu.RUnlock()
+	if sa, ok := ps.sensitive[promptName]; ok {
+		return sa
+	}
+	return sensitiveArgs{pattern: ps.sensitivePattern}
+}
+
+// withoutDeprecated returns the prompts that are not deprecated.
+func (ps *PromptsServer) withoutDeprecated(prompts []mcp.Prompt) []mcp.Prompt {
+	ps.deprecatedMu.RLock()
+	defer ps.deprecatedMu.RUnlock()
+	return slices.DeleteFunc(prompts, func(prompt mcp.Prompt) bool {
+		_, isDeprecated := ps.deprecated[prompt.Name]
+		return isDeprecated
+	})
+}
+
 func (ps *PromptsServer) makeMCPHandler(
-	tmpl *template.Template, templateName string, description string, envArgs map[string]string,
+	tmpl *template.Template, templateName string, templateHash string, description string,
+	templateArgs []string, envArgs map[string]string, frontmatter *PromptFrontmatter, sensitive sensitiveArgs,
+	resultMeta *mcp.Meta, cacheKeyInput *renderCacheKeyInput, rangeNests []rangeNest, overrides []modelOverride,
 ) func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
-	return func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	render := func(ctx context.Context, args map[string]string) (string, error) {
 		data := make(map[string]interface{})
-		data["date"] = time.Now().Format("2006-01-02 15:04:05")
+		if frontmatter.IncludesDate() {
+			data[ps.parser.dateName()] = time.Now().Format(builtinDateLayout)
+		}
+		for key, value := range ps.contextValues {
+			data[key] = value
+		}
 		for arg, value := range envArgs {
 			data[arg] = value
 		}
-		parseMCPArgs(request.Params.Arguments, ps.enableJSONArgs, data)
+		if ps.sessionHistory != nil {
+			data[sessionHistoryField] = ps.sessionHistory.Entries(sessionIDFromContext(ctx))
+		}
+		parseMCPArgs(args, ps.enableJSONArgs, data)
+		frontmatter.MergeConstants(data)
+		// Deployment variables are set last, so that neither clients nor constants replace them
+		data[varsField] = ps.vars
+		// The model is never parsed as JSON, so that the modelIs helper matches it as the client sent it
+		model := args[modelArgName]
+		if model == "" {
+			model = ps.defaultModel
+		}
+		if model != "" {
+			data[modelArgName] = model
+		}
+		variant := selectModelVariant(templateName, overrides, model)
+		// The output size limit only stops nested ranges over large arguments after they ran for long,
+		// so their cost is estimated from the sizes of the arguments before executing
+		if limit := ps.renderSettings().MaxRenderCost; limit > 0 {
+			if err := checkRenderCost(rangeNests, data, limit); err != nil {
+				return "", fmt.Errorf("execute template %q: %w", variant, err)
+			}
+		}
 
-		var result strings.Builder
-		if err := tmpl.ExecuteTemplate(&result, templateName, data); err != nil {
-			return nil, fmt.Errorf("execute template %q: %w", templateName, err)
+		result, err := ps.executeTemplate(ctx, tmpl, variant, data)
+		if err != nil {
+			return "", fmt.Errorf("execute template %q: %w", variant, err)
+		}
+		return strings.TrimSpace(normalizeLineEndings(result, ps.renderSettings().LineEndings)), nil
+	}
+
+	// Static templates use no arguments, so requests without any skip resolving them
+	static := len(templateArgs) == 0
+	return func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		if minProtocol := ps.requiredProtocol(ctx, request.Params.Name); minProtocol != "" {
+			return nil, fmt.Errorf("prompt %q requires MCP protocol version %s or later, the session uses %s",
+				request.Params.Name, minProtocol, ps.sessionProtocol(ctx))
+		}
+		started := time.Now()
+		args := map[string]string{}
+		appliedArgs := args
+		if !static || len(request.Params.Arguments) > 0 {
+			var err error
+			if args, err = sanitizeMCPArgs(request.Params.Arguments, ps.enableJSONArgs, ps.argsLimits(), ps.logger); err != nil {
+				return nil, fmt.Errorf("invalid arguments for prompt %q: %w", request.Params.Name, err)
+			}
+			if unused := unusedArgNames(args, templateArgs, ps.parser.builtinFields()); len(unused) > 0 {
+				if ps.strictUnknownArgs {
+					return nil, fmt.Errorf("unknown arguments for prompt %q: %s", request.Params.Name, strings.Join(unused, ", "))
+				}
+				ps.warnUnusedArgs(request.Params.Name, unused)
+			}
+			appliedArgs = frontmatter.TransformArgs(args)
+			if err = frontmatter.ValidateArgs(appliedArgs); err != nil {
+				return nil, fmt.Errorf("invalid arguments for prompt %q: %w", request.Params.Name, err)
+			}
+		}
+		text, err := ps.renderWithCache(ctx, render, appliedArgs, cacheKeyInput)
+		if ps.accessLog != nil {
+			ps.recordAccess(request.Params.Name, templateHash, sensitive.FingerprintArgs(args), text, started, err)
+		}
+		if err != nil {
+			if ps.renderSettings().ErrorsAsContent {
+				return errorPromptResult(description, resultMeta, err), nil
+			}
+			return nil, err
+		}
+		if ps.sessionHistory != nil {
+			if sessionID := sessionIDFromContext(ctx); sessionID != "" {
+				ps.sessionHistory.Record(sessionID, request.Params.Name, sensitive.ScrubText(text, args, appliedArgs, envArgs))
+			}
 		}
 
-		return mcp.NewGetPromptResult(
+		promptResult := mcp.NewGetPromptResult(
 			description,
 			[]mcp.PromptMessage{
 				mcp.NewPromptMessage(
 					mcp.RoleUser,
-					mcp.NewTextContent(strings.TrimSpace(result.String())),
+					mcp.NewTextContent(text),
 				),
 			},
-		), nil
+		)
+		promptResult.Meta = resultMeta
+		if ps.renderSettings().ExposeArgs {
+			promptResult.Meta = withAppliedArgs(resultMeta, sensitive.ScrubArgs(appliedArgs))
+		}
+		return promptResult, nil
+	}
+}
+
+// renderWithCache returns the prompt from the render cache if it is there, and renders and caches it otherwise.
+// Prompts without a cache key input are always rendered. Failures of the cache are logged but do not fail the request.
+func (ps *PromptsServer) renderWithCache(
+	ctx context.Context, render func(context.Context, map[string]string) (string, error), args map[string]string,
+	cacheKeyInput *renderCacheKeyInput,
+) (string, error) {
+	if ps.renderCache == nil || cacheKeyInput == nil {
+		return render(ctx, args)
+	}
+	input := *cacheKeyInput
+	// The output limit changes with config reloads, and cached texts must have been rendered within it
+	input.MaxOutputSize = ps.renderSettings().MaxOutputSize
+	key := renderCacheKey(input, args)
+	if text, ok := ps.renderCache.Get(key); ok {
+		return text, nil
 	}
+	text, err := render(ctx, args)
+	if err != nil {
+		return "", err
+	}
+	if err = ps.renderCache.Put(key, text); err != nil {
+		ps.logger.Warn("Failed to write render cache", "error", err)
+	}
+	return text, nil
 }
 
-// startWatcher monitors file system changes and reloads prompts
-func (ps *PromptsServer) startWatcher(ctx context.Context) {
-	ps.logger.Info("Started watching prompts directory for changes", "dir", ps.promptsDir)
+// unusedArgNames returns the sorted names of the arguments that are neither template arguments nor built-in fields.
+func unusedArgNames(args map[string]string, templateArgs []string, builtinFields []string) []string {
+	var unused []string
+	for name := range args {
+		if !slices.Contains(templateArgs, name) && !slices.Contains(builtinFields, name) {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
 
-	for {
-		select {
-		case event, ok := <-ps.watcher.Events:
-			if !ok {
-				return
-			}
-			if !strings.HasSuffix(event.Name, templateExt) {
-				continue
-			}
-			ps.logger.Info("Prompt template file changed", "file", event.Name, "operation", event.Op.String())
-			if err := ps.reloadPrompts(); err != nil {
-				ps.logger.Error("Failed to reload prompts", "error", err)
-			}
+// warnUnusedArgs logs the unused arguments of a prompt request. Each argument is logged once per prompt
+// for the lifetime of the server, so a client repeating the same arguments does not flood the log.
+func (ps *PromptsServer) warnUnusedArgs(promptName string, unused []string) {
+	var newlyUnused []string
+	for _, name := range unused {
+		if _, warned := ps.unusedArgsWarned.LoadOrStore(promptName+"\x00"+name, struct{}{}); !warned {
+			newlyUnused = append(newlyUnused, name)
+		}
+	}
+	if len(newlyUnused) > 0 {
+		ps.logger.Warn("Prompt request has arguments the template does not use",
+			"prompt", promptName, "unused_args", newlyUnused)
+	}
+}
 
-		case err, ok := <-ps.watcher.Errors:
-			if !ok {
-				return
+// withAppliedArgs returns a copy of the result metadata with the applied arguments added.
+// Values pre-bound from environment variables are never included, as they may hold secrets.
+func withAppliedArgs(meta *mcp.Meta, args map[string]string) *mcp.Meta {
+	fields := make(map[string]any, 2)
+	if meta != nil {
+		maps.Copy(fields, meta.AdditionalFields)
+	}
+	if args == nil {
+		args = map[string]string{}
+	}
+	fields["arguments"] = args
+	return &mcp.Meta{AdditionalFields: fields}
+}
+
+// errorPromptResult returns the render error as the text of a GetPrompt result, marked with isError in its _meta
+// for clients that show a failed prompt to the user rather than handling a protocol error.
+func errorPromptResult(description string, meta *mcp.Meta, err error) *mcp.GetPromptResult {
+	fields := map[string]any{"isError": true}
+	if meta != nil {
+		maps.Copy(fields, meta.AdditionalFields)
+		fields["isError"] = true
+	}
+	result := mcp.NewGetPromptResult(description, []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("Error: "+err.Error())),
+	})
+	result.Meta = &mcp.Meta{AdditionalFields: fields}
+	return result
+}
+
+// recordAccess writes the access log entry of a GetPrompt request; failures are logged but do not fail the request.
+func (ps *PromptsServer) recordAccess(
+	promptName string, templateHash string, args map[string]string, text string, started time.Time, renderErr error,
+) {
+	entry := AccessLogEntry{
+		Time:            started.UTC(),
+		Prompt:          promptName,
+		TemplateHash:    templateHash,
+		ServerVersion:   version,
+		ArgsFingerprint: ps.accessLog.Fingerprint(args),
+		DurationMs:      float64(time.Since(started).Microseconds()) / 1000,
+	}
+	if renderErr != nil {
+		entry.Error = renderErr.Error()
+	} else {
+		entry.OutputHash = hashContent([]byte(text))
+	}
+	if err := ps.accessLog.Record(entry); err != nil {
+		ps.logger.Error("Failed to write access log entry", "prompt", promptName, "error", err)
+	}
+}
+
+// executeTemplate renders the template enforcing the configured output size limit and timeout.
+// text/template cannot be interrupted, so on timeout the render is abandoned and stops at its next write.
+func (ps *PromptsServer) executeTemplate(
+	ctx context.Context, tmpl *template.Template, templateName string, data map[string]interface{},
+) (string, error) {
+	settings := ps.renderSettings()
+	if settings.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, settings.Timeout)
+		defer cancel()
+	}
+
+	// The scratchpad belongs to the current render, and the summarize and lastPrompt helpers need the session
+	// of the current request, so they are bound per request on a clone, like the flags of the last reload
+	// and the model of the request
+	requestFuncs := makeScratchFuncs()
+	requestFuncs[flagFuncName] = ps.featureFlags.Load().templateFunc(ps.logger)
+	model, _ := data[modelArgName].(string)
+	requestFuncs[modelIsFuncName] = makeModelIsFunc(model)
+	if settings.AllowSampling {
+		requestFuncs[summarizeFuncName] = makeSamplingSummarizeFunc(ctx, ps.mcpServer, ps.logger)
+	}
+	if ps.sessionHistory != nil {
+		requestFuncs[lastPromptFuncName] = makeLastPromptFunc(ps.sessionHistory.Entries(sessionIDFromContext(ctx)))
+	}
+	requestTmpl, err := tmpl.Clone()
+	if err != nil {
+		return "", fmt.Errorf("clone template: %w", err)
+	}
+	if !ps.recovery {
+		requestFuncs = capturePanicsInFuncs(requestFuncs)
+	}
+	tmpl = requestTmpl.Funcs(requestFuncs)
+
+	out := &limitedWriter{ctx: ctx, limit: settings.MaxOutputSize}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- tmpl.ExecuteTemplate(out, templateName, data)
+	}()
+
+	select {
+	case err := <-errChan:
+		if err == nil {
+			return out.buf.String(), nil
+		}
+		var funcPanic *templateFuncPanic
+		if !ps.recovery && errors.As(err, &funcPanic) {
+			panic(funcPanic)
+		}
+		if errors.Is(err, errRenderOutputTooLarge) {
+			return "", fmt.Errorf("%w of %d bytes", errRenderOutputTooLarge, settings.MaxOutputSize)
+		}
+		if ctx.Err() == nil {
+			return "", err
+		}
+	case <-ctx.Done():
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && settings.Timeout > 0 {
+		return "", fmt.Errorf("render timed out after %s", settings.Timeout)
+	}
+	return "", ctx.Err()
+}
+
+// limitedWriter accumulates rendered output and fails once the size limit is exceeded or the context is done.
+type limitedWriter struct {
+	ctx   context.Context
+	limit int
+	buf   strings.Builder
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if w.limit > 0 && w.buf.Len()+len(p) > w.limit {
+		return 0, errRenderOutputTooLarge
+	}
+	return w.buf.Write(p)
+}
+
+// sanitizeMCPArgs validates raw MCP arguments against the configured limits before they reach the template data.
+// Argument names are trimmed, empty names are dropped, and names that collide after trimming are rejected.
+func sanitizeMCPArgs(
+	args map[string]string, enableJSONArgs bool, limits ArgsLimits, logger *slog.Logger,
+) (map[string]string, error) {
+	totalSize := 0
+	for key, value := range args {
+		totalSize += len(key) + len(value)
+	}
+	if limits.MaxTotalSize > 0 && totalSize > limits.MaxTotalSize {
+		return nil, fmt.Errorf("arguments payload size %d exceeds limit of %d bytes", totalSize, limits.MaxTotalSize)
+	}
+
+	result := make(map[string]string, len(args))
+	for key, value := range args {
+		normalizedKey := strings.TrimSpace(key)
+		if normalizedKey == "" {
+			logger.Warn("Dropping argument with empty name", "value_size", len(value))
+			continue
+		}
+		if limits.MaxKeyLength > 0 && len(normalizedKey) > limits.MaxKeyLength {
+			return nil, fmt.Errorf("argument name of %d bytes exceeds limit of %d bytes",
+				len(normalizedKey), limits.MaxKeyLength)
+		}
+		if _, exists := result[normalizedKey]; exists {
+			return nil, fmt.Errorf("duplicate argument %q after normalization", normalizedKey)
+		}
+		if enableJSONArgs && limits.MaxJSONDepth > 0 {
+			if depth := jsonNestingDepth(value); depth > limits.MaxJSONDepth {
+				return nil, fmt.Errorf("argument %q JSON nesting depth exceeds limit of %d", normalizedKey, limits.MaxJSONDepth)
 			}
-			ps.logger.Error("File watcher error", "error", err)
+		}
+		result[normalizedKey] = value
+	}
+	return result, nil
+}
 
-		case <-ctx.Done():
-			ps.logger.Info("Stopping prompts watcher due to context cancellation")
-			return
+// jsonNestingDepth returns the maximum nesting depth of arrays and objects in s without decoding it,
+// so that pathological inputs are rejected before json.Unmarshal allocates them.
+func jsonNestingDepth(s string) int {
+	depth, maxDepth := 0, 0
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '[', '{':
+			depth++
+			maxDepth = max(maxDepth, depth)
+		case ']', '}':
+			depth--
 		}
 	}
+	return maxDepth
 }
 
 // parseMCPArgs attempts to parse each argument value as JSON when enableJSONArgs is true.
@@ -283,5 +1740,6 @@ func parseMCPArgs(args map[string]string, enableJSONArgs bool, data map[string]i
 }
 
 func isTemplateFile(file os.DirEntry) bool {
-	return file.Type().IsRegular() && strings.HasSuffix(file.Name(), templateExt) && !strings.HasPrefix(file.Name(), "_")
+	return file.Type().IsRegular() && strings.HasSuffix(file.Name(), templateExt) && !strings.HasPrefix(file.Name(), "_") &&
+		!isModelOverrideFile(file.Name())
 }
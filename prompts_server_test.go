@@ -3,11 +3,19 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"text/template"
 	"time"
 
 	"github.com/mark3labs/mcp-go/client"
@@ -211,6 +219,90 @@ func (s *PromptsServerTestSuite) TestServeStdio() {
 	}
 }
 
+// TestNewPromptsServerOptions tests constructing the server with various option combinations
+func (s *PromptsServerTestSuite) TestNewPromptsServerOptions() {
+	err := os.WriteFile(filepath.Join(s.tempDir, "greeting.tmpl"), []byte("{{/* Greeting */}}\nHello {{.name}}"), 0644)
+	require.NoError(s.T(), err)
+
+	customLimits := ArgsLimits{MaxKeyLength: 8, MaxTotalSize: 64, MaxJSONDepth: 2}
+	customRender := RenderSettings{DisableEnvArgs: true, Timeout: time.Second}
+
+	tests := []struct {
+		name                   string
+		makeServer             func() (*PromptsServer, error)
+		expectedEnableJSONArgs bool
+		expectedArgsLimits     ArgsLimits
+		expectedRenderSettings RenderSettings
+	}{
+		{
+			name: "defaults",
+			makeServer: func() (*PromptsServer, error) {
+				return NewPromptsServer(s.tempDir)
+			},
+			expectedEnableJSONArgs: true,
+			expectedArgsLimits:     DefaultArgsLimits(),
+		},
+		{
+			name: "JSON args disabled",
+			makeServer: func() (*PromptsServer, error) {
+				return NewPromptsServer(s.tempDir, WithJSONArgs(false), WithLogger(s.logger))
+			},
+			expectedEnableJSONArgs: false,
+			expectedArgsLimits:     DefaultArgsLimits(),
+		},
+		{
+			name: "all options",
+			makeServer: func() (*PromptsServer, error) {
+				return NewPromptsServer(s.tempDir,
+					WithJSONArgs(false), WithArgsLimits(customLimits), WithRenderSettings(customRender), WithLogger(s.logger))
+			},
+			expectedEnableJSONArgs: false,
+			expectedArgsLimits:     customLimits,
+			expectedRenderSettings: customRender,
+		},
+		{
+			name: "later option wins",
+			makeServer: func() (*PromptsServer, error) {
+				return NewPromptsServer(s.tempDir, WithArgsLimits(customLimits), WithArgsLimits(ArgsLimits{}))
+			},
+			expectedEnableJSONArgs: true,
+			expectedArgsLimits:     ArgsLimits{},
+		},
+		{
+			name: "nil logger",
+			makeServer: func() (*PromptsServer, error) {
+				return NewPromptsServer(s.tempDir, WithLogger(nil))
+			},
+			expectedEnableJSONArgs: true,
+			expectedArgsLimits:     DefaultArgsLimits(),
+		},
+		{
+			name: "compatibility shim",
+			makeServer: func() (*PromptsServer, error) {
+				return NewPromptsServerWithSettings(s.tempDir, false, s.logger)
+			},
+			expectedEnableJSONArgs: false,
+			expectedArgsLimits:     DefaultArgsLimits(),
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			promptsServer, err := tt.makeServer()
+			require.NoError(s.T(), err)
+			defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+			assert.Equal(s.T(), tt.expectedEnableJSONArgs, promptsServer.enableJSONArgs)
+			assert.Equal(s.T(), tt.expectedArgsLimits, promptsServer.argsLimits())
+			assert.Equal(s.T(), tt.expectedRenderSettings, promptsServer.renderSettings())
+			assert.NotNil(s.T(), promptsServer.logger)
+		})
+	}
+
+	_, err = NewPromptsServer(filepath.Join(s.tempDir, "nonexistent"))
+	require.Error(s.T(), err)
+}
+
 // TestParseMCPArgs tests parseMCPArgs function functionality
 func (s *PromptsServerTestSuite) TestParseMCPArgs() {
 	tests := []struct {
@@ -340,6 +432,109 @@ func (s *PromptsServerTestSuite) TestParseMCPArgs() {
 	}
 }
 
+// TestGetPromptMalformedArguments tests that malformed argument payloads produce clean errors instead of panics or stalls
+func (s *PromptsServerTestSuite) TestGetPromptMalformedArguments() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	deeplyNested := strings.Repeat("[", 40) + strings.Repeat("]", 40)
+	allowedNested := strings.Repeat("[", 32) + strings.Repeat("]", 32)
+
+	tests := []struct {
+		name            string
+		arguments       map[string]string
+		expectedContent string
+		expectedErr     string
+	}{
+		{
+			name:            "empty key is dropped",
+			arguments:       map[string]string{"": "ignored", "name": "Alice"},
+			expectedContent: "Hello Alice!\nHave a great day!",
+		},
+		{
+			name:            "whitespace-only key is dropped",
+			arguments:       map[string]string{"   ": "ignored", "name": "Alice"},
+			expectedContent: "Hello Alice!\nHave a great day!",
+		},
+		{
+			name:            "key with surrounding whitespace is normalized",
+			arguments:       map[string]string{" name ": "Bob"},
+			expectedContent: "Hello Bob!\nHave a great day!",
+		},
+		{
+			name:        "duplicate keys after normalization",
+			arguments:   map[string]string{"name": "Alice", " name": "Bob"},
+			expectedErr: "duplicate argument",
+		},
+		{
+			name:        "extremely long key",
+			arguments:   map[string]string{strings.Repeat("k", 300): "value"},
+			expectedErr: "argument name of 300 bytes exceeds limit",
+		},
+		{
+			name:        "deeply nested JSON value",
+			arguments:   map[string]string{"name": deeplyNested},
+			expectedErr: "JSON nesting depth exceeds limit",
+		},
+		{
+			name:            "nested JSON value within limit",
+			arguments:       map[string]string{"name": allowedNested},
+			expectedContent: "Hello " + allowedNested + "!\nHave a great day!",
+		},
+		{
+			name:            "brackets inside JSON strings do not count as nesting",
+			arguments:       map[string]string{"name": `"` + strings.Repeat("[", 40) + `"`},
+			expectedContent: "Hello " + strings.Repeat("[", 40) + "!\nHave a great day!",
+		},
+		{
+			name:        "oversized payload",
+			arguments:   map[string]string{"name": strings.Repeat("x", 2<<20)},
+			expectedErr: "arguments payload size",
+		},
+	}
+
+	_, mcpClient, promptsClose := s.makePromptsServerAndClient(ctx, "./testdata", true)
+	defer promptsClose()
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			var getReq mcp.GetPromptRequest
+			getReq.Params.Name = "greeting"
+			getReq.Params.Arguments = tt.arguments
+			getResult, err := mcpClient.GetPrompt(ctx, getReq)
+
+			if tt.expectedErr != "" {
+				require.Error(s.T(), err, "expected error for malformed arguments")
+				assert.Contains(s.T(), err.Error(), tt.expectedErr)
+				return
+			}
+			require.NoError(s.T(), err, "GetPrompt failed")
+			require.Len(s.T(), getResult.Messages, 1, "Expected exactly 1 message")
+			content, ok := getResult.Messages[0].Content.(mcp.TextContent)
+			require.True(s.T(), ok, "Expected TextContent")
+			assert.Equal(s.T(), tt.expectedContent, content.Text)
+		})
+	}
+
+	// The server must remain responsive after rejecting malformed payloads
+	_, err := mcpClient.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	require.NoError(s.T(), err, "ListPrompts failed after malformed requests")
+}
+
+// TestSanitizeMCPArgsDisabledLimits tests that zero limits disable the corresponding checks
+func (s *PromptsServerTestSuite) TestSanitizeMCPArgsDisabledLimits() {
+	args := map[string]string{
+		strings.Repeat("k", 1000): strings.Repeat("[", 100) + strings.Repeat("]", 100),
+	}
+	result, err := sanitizeMCPArgs(args, true, ArgsLimits{}, s.logger)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), args, result)
+
+	// JSON depth is not checked when JSON parsing is disabled
+	_, err = sanitizeMCPArgs(args, false, ArgsLimits{MaxJSONDepth: 1}, s.logger)
+	require.NoError(s.T(), err)
+}
+
 // TestReloadPromptsNewPromptAdded tests reloadPrompts method with new prompts via ServeStdio
 func (s *PromptsServerTestSuite) TestReloadPromptsNewPromptAdded() {
 	ctx := context.Background()
@@ -458,6 +653,37 @@ Greetings {{.name}}!`
 	require.NoError(s.T(), err, "Should be able to call remaining prompt")
 }
 
+// TestReloadPromptsDiffLogged tests that a reload logs which prompts were added, removed, and changed
+func (s *PromptsServerTestSuite) TestReloadPromptsDiffLogged() {
+	writePrompt := func(name, content string) string {
+		require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, name+templateExt), []byte(content), 0644))
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))[:12]
+	}
+	keptSum := writePrompt("kept", "{{/* Kept */}}\nKept")
+	removedSum := writePrompt("obsolete", "{{/* Obsolete */}}\nObsolete")
+	oldSum := writePrompt("edited", "{{/* Edited */}}\nOld")
+
+	var logBuf bytes.Buffer
+	promptsServer, err := NewPromptsServer(s.tempDir,
+		WithWatchMode(WatchModeOff, 0), WithLogger(slog.New(slog.NewTextHandler(&logBuf, nil))))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+	assert.NotContains(s.T(), logBuf.String(), "Prompts reloaded", "the initial load is not a reload")
+
+	addedSum := writePrompt("fresh", "{{/* Fresh */}}\nFresh")
+	newSum := writePrompt("edited", "{{/* Edited */}}\nNew")
+	require.NoError(s.T(), os.Remove(filepath.Join(s.tempDir, "obsolete"+templateExt)))
+	require.NoError(s.T(), promptsServer.reloadPrompts())
+	assert.Contains(s.T(), logBuf.String(), fmt.Sprintf(
+		`msg="Prompts reloaded" added=[fresh@%s] removed=[obsolete@%s] changed=[edited@%s->%s]`,
+		addedSum, removedSum, oldSum, newSum))
+	assert.NotContains(s.T(), logBuf.String(), "kept@"+keptSum)
+
+	logBuf.Reset()
+	require.NoError(s.T(), promptsServer.reloadPrompts())
+	assert.Contains(s.T(), logBuf.String(), `msg="Prompts reloaded" added=[] removed=[] changed=[]`)
+}
+
 // TestReloadPromptsArgumentAdded tests reloadPrompts method with argument changes via ServeStdio
 func (s *PromptsServerTestSuite) TestReloadPromptsArgumentAdded() {
 	ctx := context.Background()
@@ -617,14 +843,1087 @@ Hello {{.name}}!`
 	assert.Equal(s.T(), "Updated description with more details", getResult.Description, "GetPrompt should return updated description")
 }
 
+// TestReloadPromptsSchemaChanged tests that editing a schema sidecar reloads the prompt inlining it
+func (s *PromptsServerTestSuite) TestReloadPromptsSchemaChanged() {
+	ctx := context.Background()
+
+	err := os.WriteFile(filepath.Join(s.tempDir, "json_reply.tmpl"),
+		[]byte("{{/* JSON reply */}}\nReply as JSON:\n{{schema \"json_reply\"}}"), 0644)
+	require.NoError(s.T(), err, "Failed to write prompt file")
+	schemaFile := filepath.Join(s.tempDir, "json_reply.schema.json")
+	err = os.WriteFile(schemaFile, []byte(`{"type":"object","properties":{"title":{"type":"string"}}}`), 0644)
+	require.NoError(s.T(), err, "Failed to write schema file")
+
+	_, mcpClient, promptsClose := s.makePromptsServerAndClient(ctx, s.tempDir, true)
+	defer promptsClose()
+
+	getText := func() string {
+		getReq := mcp.GetPromptRequest{}
+		getReq.Params.Name = "json_reply"
+		getResult, err := mcpClient.GetPrompt(ctx, getReq)
+		require.NoError(s.T(), err, "GetPrompt failed")
+		require.Len(s.T(), getResult.Messages, 1, "Expected exactly 1 message")
+		content, ok := getResult.Messages[0].Content.(mcp.TextContent)
+		require.True(s.T(), ok, "Expected TextContent")
+		return content.Text
+	}
+	assert.Contains(s.T(), getText(), `"title"`)
+
+	err = os.WriteFile(schemaFile, []byte(`{"type":"object","properties":{"headline":{"type":"string"}}}`), 0644)
+	require.NoError(s.T(), err, "Failed to update schema file")
+
+	// Give the client-server communication time to process the changes
+	time.Sleep(100 * time.Millisecond)
+
+	text := getText()
+	assert.Contains(s.T(), text, `"headline"`)
+	assert.NotContains(s.T(), text, `"title"`)
+}
+
+// TestFrontmatterDataConstants tests that frontmatter constants render and are not exposed as MCP arguments
+func (s *PromptsServerTestSuite) TestFrontmatterDataConstants() {
+	ctx := context.Background()
+
+	err := os.WriteFile(filepath.Join(s.tempDir, "policy.tmpl"), []byte("---\ndata:\n  company: Acme\n  max: 5\n---\n"+
+		"{{/* Policy reminder */}}\n{{.company}} allows at most {{.max}} items for {{.name}}."), 0644)
+	require.NoError(s.T(), err)
+
+	_, mcpClient, promptsClose := s.makePromptsServerAndClient(ctx, s.tempDir, true)
+	defer promptsClose()
+
+	listResult, err := mcpClient.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	require.NoError(s.T(), err, "ListPrompts failed")
+	require.Len(s.T(), listResult.Prompts, 1)
+	assert.Equal(s.T(), "Policy reminder", listResult.Prompts[0].Description)
+	require.Len(s.T(), listResult.Prompts[0].Arguments, 1, "constants must not be exposed as arguments")
+	assert.Equal(s.T(), "name", listResult.Prompts[0].Arguments[0].Name)
+
+	getReq := mcp.GetPromptRequest{}
+	getReq.Params.Name = "policy"
+	getReq.Params.Arguments = map[string]string{"name": "Bob", "max": "100"}
+	getResult, err := mcpClient.GetPrompt(ctx, getReq)
+	require.NoError(s.T(), err, "GetPrompt failed")
+	require.Len(s.T(), getResult.Messages, 1, "Expected exactly 1 message")
+	content, ok := getResult.Messages[0].Content.(mcp.TextContent)
+	require.True(s.T(), ok, "Expected TextContent")
+	assert.Equal(s.T(), "Acme allows at most 5 items for Bob.", content.Text)
+}
+
+// TestArgumentTransforms tests that declared transforms normalize argument values before rendering
+func (s *PromptsServerTestSuite) TestArgumentTransforms() {
+	ctx := context.Background()
+
+	err := os.WriteFile(filepath.Join(s.tempDir, "ticket.tmpl"), []byte("---\narguments:\n"+
+		"  project:\n    transform: [trim, upper]\n  title:\n    transform: [collapse_spaces]\n---\n"+
+		"{{/* Ticket */}}\n[{{.project}}] {{.title}} ({{.labels}})"), 0644)
+	require.NoError(s.T(), err)
+
+	_, mcpClient, promptsClose := s.makePromptsServerAndClient(ctx, s.tempDir, true)
+	defer promptsClose()
+
+	getReq := mcp.GetPromptRequest{}
+	getReq.Params.Name = "ticket"
+	getReq.Params.Arguments = map[string]string{"project": "  core ", "title": "Fix   the\n login", "labels": " bug "}
+	getResult, err := mcpClient.GetPrompt(ctx, getReq)
+	require.NoError(s.T(), err, "GetPrompt failed")
+	require.Len(s.T(), getResult.Messages, 1, "Expected exactly 1 message")
+	content, ok := getResult.Messages[0].Content.(mcp.TextContent)
+	require.True(s.T(), ok, "Expected TextContent")
+	assert.Equal(s.T(), "[CORE] Fix the login ( bug )", content.Text)
+}
+
+// TestArgumentPatterns tests that values not matching the declared pattern fail the request
+func (s *PromptsServerTestSuite) TestArgumentPatterns() {
+	ctx := context.Background()
+
+	err := os.WriteFile(filepath.Join(s.tempDir, "notify.tmpl"), []byte("---\narguments:\n"+
+		"  email:\n    pattern: \"^[^@]+@[^@]+$\"\n---\n{{/* Notify */}}\nNotify {{.email}}"), 0644)
+	require.NoError(s.T(), err)
+
+	_, mcpClient, promptsClose := s.makePromptsServerAndClient(ctx, s.tempDir, true)
+	defer promptsClose()
+
+	getReq := mcp.GetPromptRequest{}
+	getReq.Params.Name = "notify"
+	getReq.Params.Arguments = map[string]string{"email": "bob@example.com"}
+	getResult, err := mcpClient.GetPrompt(ctx, getReq)
+	require.NoError(s.T(), err, "GetPrompt failed")
+	assert.Equal(s.T(), "Notify bob@example.com", getResult.Messages[0].Content.(mcp.TextContent).Text)
+
+	getReq.Params.Arguments = map[string]string{"email": "bob"}
+	_, err = mcpClient.GetPrompt(ctx, getReq)
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(),
+		`invalid arguments for prompt "notify": argument "email" does not match the pattern "^[^@]+@[^@]+$"`)
+}
+
+// TestDeprecatedPrompts tests the warning on requests of deprecated prompts and hiding them from listings
+func (s *PromptsServerTestSuite) TestDeprecatedPrompts() {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, "code_review.tmpl"),
+		[]byte("{{/* Review code */}}\nReview {{.diff}}"), 0644))
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, "review.tmpl"),
+		[]byte("---\ndeprecated: use code_review instead\n---\n{{/* Review */}}\nReview {{.diff}}"), 0644))
+
+	handle := func(promptsServer *PromptsServer, method string, params map[string]any) any {
+		request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+		require.NoError(s.T(), err)
+		response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+		require.True(s.T(), ok, "%s must succeed", method)
+		return response.Result
+	}
+	listedNames := func(promptsServer *PromptsServer) []string {
+		var names []string
+		for _, prompt := range handle(promptsServer, "prompts/list", map[string]any{}).(mcp.ListPromptsResult).Prompts {
+			names = append(names, prompt.Name)
+		}
+		return names
+	}
+
+	for _, hideDeprecated := range []bool{false, true} {
+		s.Run(fmt.Sprintf("hide deprecated %v", hideDeprecated), func() {
+			var logBuf bytes.Buffer
+			promptsServer, err := NewPromptsServer(s.tempDir, WithHideDeprecated(hideDeprecated),
+				WithWatchMode(WatchModeOff, 0), WithLogger(slog.New(slog.NewTextHandler(&logBuf, nil))))
+			require.NoError(s.T(), err)
+			defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+			if hideDeprecated {
+				assert.Equal(s.T(), []string{"code_review"}, listedNames(promptsServer))
+			} else {
+				assert.Equal(s.T(), []string{"code_review", "review"}, listedNames(promptsServer))
+			}
+
+			handle(promptsServer, "prompts/get", map[string]any{"name": "code_review", "arguments": map[string]any{"diff": "x"}})
+			assert.NotContains(s.T(), logBuf.String(), "Deprecated prompt requested")
+
+			result := handle(promptsServer, "prompts/get", map[string]any{"name": "review", "arguments": map[string]any{"diff": "x"}})
+			assert.Equal(s.T(), "Review x", result.(mcp.GetPromptResult).Messages[0].Content.(mcp.TextContent).Text,
+				"deprecated prompts can still be requested")
+			assert.Contains(s.T(), logBuf.String(),
+				`level=WARN msg="Deprecated prompt requested" id=1 params_name=review deprecated="use code_review instead"`)
+		})
+	}
+}
+
+// TestUnusedArgs tests the warning about arguments the template does not use and the strict mode rejecting them
+func (s *PromptsServerTestSuite) TestUnusedArgs() {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, "greet.tmpl"),
+		[]byte("{{/* Greet */}}\nHello {{.name}} on {{.date}}"), 0644))
+
+	assert.Equal(s.T(), []string{"nmae", "tone"},
+		unusedArgNames(map[string]string{"tone": "", "name": "", "nmae": "", "date": ""}, []string{"name"},
+			builtinFieldNames(defaultBuiltinDateName)))
+	assert.Empty(s.T(), unusedArgNames(map[string]string{"date": "", sessionHistoryField: ""}, nil, builtinFieldNames(defaultBuiltinDateName)),
+		"built-in fields must never be reported")
+
+	getPrompt := func(promptsServer *PromptsServer, args map[string]any) mcp.JSONRPCMessage {
+		request, err := json.Marshal(map[string]any{
+			"jsonrpc": "2.0", "id": 1, "method": "prompts/get", "params": map[string]any{"name": "greet", "arguments": args},
+		})
+		require.NoError(s.T(), err)
+		return promptsServer.mcpServer.HandleMessage(context.Background(), request)
+	}
+
+	s.Run("warning", func() {
+		var logBuf bytes.Buffer
+		promptsServer, err := NewPromptsServer(s.tempDir,
+			WithWatchMode(WatchModeOff, 0), WithLogger(slog.New(slog.NewTextHandler(&logBuf, nil))))
+		require.NoError(s.T(), err)
+		defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+		_, ok := getPrompt(promptsServer, map[string]any{"name": "Bob", "date": "today"}).(mcp.JSONRPCResponse)
+		require.True(s.T(), ok)
+		assert.NotContains(s.T(), logBuf.String(), "does not use")
+
+		_, ok = getPrompt(promptsServer, map[string]any{"name": "Bob", "tone": "warm"}).(mcp.JSONRPCResponse)
+		require.True(s.T(), ok, "unused arguments must not fail the request by default")
+		assert.Contains(s.T(), logBuf.String(),
+			`level=WARN msg="Prompt request has arguments the template does not use" prompt=greet unused_args=[tone]`)
+
+		getPrompt(promptsServer, map[string]any{"name": "Bob", "tone": "x", "nmae": "Bob"})
+		assert.Contains(s.T(), logBuf.String(), "unused_args=[nmae]", "only the newly seen names are logged")
+		getPrompt(promptsServer, map[string]any{"tone": "x", "nmae": "Bob"})
+		assert.Equal(s.T(), 2, strings.Count(logBuf.String(), "does not use"), "each name is logged once per prompt")
+
+		require.NoError(s.T(), os.Rename(filepath.Join(s.tempDir, "greet.tmpl"), filepath.Join(s.tempDir, "hello.tmpl")))
+		require.NoError(s.T(), promptsServer.Reload())
+		promptsServer.unusedArgsWarned.Range(func(key, _ any) bool {
+			s.Failf("logged unused arguments of removed prompts must be forgotten", "key %q", key)
+			return true
+		})
+		require.NoError(s.T(), os.Rename(filepath.Join(s.tempDir, "hello.tmpl"), filepath.Join(s.tempDir, "greet.tmpl")))
+	})
+
+	s.Run("strict", func() {
+		promptsServer, err := NewPromptsServer(s.tempDir, WithStrictUnknownArgs(true), WithWatchMode(WatchModeOff, 0),
+			WithLogger(s.logger))
+		require.NoError(s.T(), err)
+		defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+		_, ok := getPrompt(promptsServer, map[string]any{"name": "Bob", "date": "today"}).(mcp.JSONRPCResponse)
+		require.True(s.T(), ok, "built-in fields must not be rejected")
+
+		errResponse, ok := getPrompt(promptsServer, map[string]any{"name": "Bob", "tone": "x", "nmae": "Bob"}).(mcp.JSONRPCError)
+		require.True(s.T(), ok)
+		assert.Contains(s.T(), errResponse.Error.Message, `unknown arguments for prompt "greet": nmae, tone`)
+	})
+}
+
+// TestStaticPrompts tests that templates without arguments are flagged as static and render without arguments
+func (s *PromptsServerTestSuite) TestStaticPrompts() {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, "static.tmpl"),
+		[]byte("{{/* Static */}}\nAlways the same text"), 0644))
+
+	var logBuf bytes.Buffer
+	promptsServer, err := NewPromptsServer(s.tempDir, WithStrictUnknownArgs(true), WithWatchMode(WatchModeOff, 0),
+		WithLogger(slog.New(slog.NewTextHandler(&logBuf, nil))))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+	assert.Contains(s.T(), logBuf.String(), `msg="Prompt will be registered" name=static description=Static prompt_args=[] env_args=map[] static=true`)
+
+	getPrompt := func(args map[string]any) mcp.JSONRPCMessage {
+		request, err := json.Marshal(map[string]any{
+			"jsonrpc": "2.0", "id": 1, "method": "prompts/get", "params": map[string]any{"name": "static", "arguments": args},
+		})
+		require.NoError(s.T(), err)
+		return promptsServer.mcpServer.HandleMessage(context.Background(), request)
+	}
+	response, ok := getPrompt(nil).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), "Always the same text",
+		response.Result.(mcp.GetPromptResult).Messages[0].Content.(mcp.TextContent).Text)
+
+	errResponse, ok := getPrompt(map[string]any{"tone": "warm"}).(mcp.JSONRPCError)
+	require.True(s.T(), ok, "arguments given to static prompts are still checked")
+	assert.Contains(s.T(), errResponse.Error.Message, `unknown arguments for prompt "static": tone`)
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), listTemplates(&buf, s.tempDir, listOptions{verbose: true}))
+	assert.Contains(s.T(), removeANSIColors(buf.String()), "static.tmpl\n  Description: Static\n  Variables: no arguments (static)\n")
+}
+
+// TestMaxAdvertisedArgs tests that prompts with many arguments advertise only the first ones but accept all of them
+func (s *PromptsServerTestSuite) TestMaxAdvertisedArgs() {
+	var content strings.Builder
+	content.WriteString("{{/* Wide */}}\n")
+	for i := range 300 {
+		fmt.Fprintf(&content, "{{.arg_%03d}}", i)
+	}
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, "wide.tmpl"), []byte(content.String()), 0644))
+
+	handle := func(promptsServer *PromptsServer, method string, params map[string]any) any {
+		request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+		require.NoError(s.T(), err)
+		response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+		require.True(s.T(), ok, "%s must succeed", method)
+		return response.Result
+	}
+
+	for _, maxArgs := range []int{0, 50} {
+		s.Run(fmt.Sprintf("max %d", maxArgs), func() {
+			promptsServer, err := NewPromptsServer(s.tempDir, WithMaxAdvertisedArgs(maxArgs), WithStrictUnknownArgs(true),
+				WithWatchMode(WatchModeOff, 0), WithLogger(s.logger))
+			require.NoError(s.T(), err)
+			defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+			prompts := handle(promptsServer, "prompts/list", map[string]any{}).(mcp.ListPromptsResult).Prompts
+			require.Len(s.T(), prompts, 1)
+			if maxArgs == 0 {
+				assert.Len(s.T(), prompts[0].Arguments, 300)
+				assert.Equal(s.T(), "Wide", prompts[0].Description)
+			} else {
+				require.Len(s.T(), prompts[0].Arguments, 50)
+				assert.Equal(s.T(), "arg_000", prompts[0].Arguments[0].Name)
+				assert.Equal(s.T(), "arg_049", prompts[0].Arguments[49].Name)
+				assert.Equal(s.T(), "Wide (and 250 more arguments)", prompts[0].Description)
+			}
+
+			result := handle(promptsServer, "prompts/get", map[string]any{
+				"name": "wide", "arguments": map[string]any{"arg_000": "first ", "arg_299": "last"},
+			}).(mcp.GetPromptResult)
+			assert.Equal(s.T(), "Wide", result.Description)
+			text := result.Messages[0].Content.(mcp.TextContent).Text
+			assert.True(s.T(), strings.HasPrefix(text, "first <no value>"), text)
+			assert.True(s.T(), strings.HasSuffix(text, "<no value>last"), "arguments that are not advertised are accepted")
+		})
+	}
+}
+
+// TestArgOrder tests that arguments are advertised alphabetically by default or in the order of their first use
+func (s *PromptsServerTestSuite) TestArgOrder() {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, "_scope.tmpl"),
+		[]byte(`{{define "_scope"}}in {{.repo}} at {{.branch}}{{end}}`), 0644))
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, "review.tmpl"), []byte("{{/* Review */}}\n"+
+		`Review {{.title}} {{template "_scope" .}}{{if .strict}} strictly{{end}} for {{.author}}, not {{.title}}`), 0644))
+
+	for _, tc := range []struct {
+		order    ArgOrder
+		expected []string
+	}{
+		{order: "", expected: []string{"author", "branch", "repo", "strict", "title"}},
+		{order: ArgOrderAlphabetical, expected: []string{"author", "branch", "repo", "strict", "title"}},
+		{order: ArgOrderTemplate, expected: []string{"title", "repo", "branch", "strict", "author"}},
+	} {
+		promptsServer, err := NewPromptsServer(s.tempDir, WithArgOrder(tc.order),
+			WithWatchMode(WatchModeOff, 0), WithLogger(s.logger))
+		require.NoError(s.T(), err)
+		request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "prompts/list"})
+		require.NoError(s.T(), err)
+		response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+		require.True(s.T(), ok)
+		var names []string
+		for _, arg := range response.Result.(mcp.ListPromptsResult).Prompts[0].Arguments {
+			names = append(names, arg.Name)
+		}
+		assert.Equal(s.T(), tc.expected, names, "order %q", tc.order)
+		require.NoError(s.T(), promptsServer.Close())
+	}
+
+	order, err := ParseArgOrder(" Template ")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), ArgOrderTemplate, order)
+	_, err = ParseArgOrder("declaration")
+	assert.EqualError(s.T(), err, `unknown argument order "declaration" (expected alphabetical or template)`)
+}
+
+// TestBuiltinDateName tests that renaming the built-in date frees "date" for a prompt argument
+func (s *PromptsServerTestSuite) TestBuiltinDateName() {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, "report.tmpl"),
+		[]byte("{{/* Report */}}\nReport for {{.date}}, generated at {{.now_date}}"), 0644))
+
+	promptsServer, err := NewPromptsServer(s.tempDir, WithBuiltinDateName("now_date"), WithStrictUnknownArgs(true),
+		WithWatchMode(WatchModeOff, 0), WithLogger(s.logger))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	handle := func(method string, params map[string]any) any {
+		request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+		require.NoError(s.T(), err)
+		response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+		require.True(s.T(), ok, "%s must succeed", method)
+		return response.Result
+	}
+
+	prompts := handle("prompts/list", map[string]any{}).(mcp.ListPromptsResult).Prompts
+	require.Len(s.T(), prompts, 1)
+	require.Len(s.T(), prompts[0].Arguments, 1)
+	assert.Equal(s.T(), "date", prompts[0].Arguments[0].Name)
+
+	result := handle("prompts/get", map[string]any{"name": "report", "arguments": map[string]any{"date": "2026-01-31"}})
+	assert.Regexp(s.T(), `^Report for 2026-01-31, generated at \d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}$`,
+		result.(mcp.GetPromptResult).Messages[0].Content.(mcp.TextContent).Text)
+
+	assert.NoError(s.T(), validateBuiltinDateName("now_date"))
+	assert.Error(s.T(), validateBuiltinDateName("now-date"))
+	assert.Error(s.T(), validateBuiltinDateName(sessionHistoryField))
+}
+
+// TestContextValues tests that context values render in every prompt and are not listed as prompt arguments
+func (s *PromptsServerTestSuite) TestContextValues() {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, "deploy.tmpl"),
+		[]byte("{{/* Deploy */}}\nDeploy {{.service}} to {{.environment}}"), 0644))
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, "status.tmpl"),
+		[]byte("{{/* Status */}}\nStatus of {{.environment}}"), 0644))
+
+	promptsServer, err := NewPromptsServer(s.tempDir, WithContextValues(map[string]string{"environment": "staging"}),
+		WithWatchMode(WatchModeOff, 0), WithLogger(s.logger))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	handle := func(method string, params map[string]any) any {
+		request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+		require.NoError(s.T(), err)
+		response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+		require.True(s.T(), ok, "%s must succeed", method)
+		return response.Result
+	}
+	promptText := func(name string, args map[string]any) string {
+		result := handle("prompts/get", map[string]any{"name": name, "arguments": args})
+		return result.(mcp.GetPromptResult).Messages[0].Content.(mcp.TextContent).Text
+	}
+
+	promptArgs := make(map[string][]string)
+	for _, prompt := range handle("prompts/list", map[string]any{}).(mcp.ListPromptsResult).Prompts {
+		promptArgs[prompt.Name] = []string{}
+		for _, arg := range prompt.Arguments {
+			promptArgs[prompt.Name] = append(promptArgs[prompt.Name], arg.Name)
+		}
+	}
+	assert.Equal(s.T(), map[string][]string{"deploy": {"service"}, "status": {}}, promptArgs)
+
+	assert.Equal(s.T(), "Deploy api to staging", promptText("deploy", map[string]any{"service": "api"}))
+	assert.Equal(s.T(), "Status of staging", promptText("status", nil))
+	assert.Equal(s.T(), "Status of production", promptText("status", map[string]any{"environment": "production"}),
+		"client arguments take precedence over context values")
+}
+
+// TestStdinTimeout tests that the server stops when no client sends a message within the stdin timeout
+func (s *PromptsServerTestSuite) TestStdinTimeout() {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, "greet.tmpl"), []byte("{{/* Greet */}}\nHello"), 0644))
+	promptsServer, err := NewPromptsServer(s.tempDir, WithStdinTimeout(100*time.Millisecond), WithLogger(s.logger))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	stdinReader, stdinWriter := io.Pipe()
+	defer func() { _ = stdinWriter.Close() }()
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- promptsServer.ServeStdio(context.Background(), stdinReader, io.Discard)
+	}()
+	select {
+	case err = <-errChan:
+		require.ErrorIs(s.T(), err, errNoClient)
+		assert.Contains(s.T(), err.Error(), "no client connected within 100ms")
+	case <-time.After(5 * time.Second):
+		s.T().Fatal("server did not stop after the stdin timeout")
+	}
+
+	// A client sending its first message in time keeps the server running
+	ctx, cancel := context.WithCancel(context.Background())
+	stdinReader, stdinWriter = io.Pipe()
+	go func() {
+		errChan <- promptsServer.ServeStdio(ctx, stdinReader, io.Discard)
+	}()
+	_, err = stdinWriter.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n"))
+	require.NoError(s.T(), err)
+	select {
+	case err = <-errChan:
+		s.T().Fatalf("server stopped despite the client message: %v", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+	cancel()
+	select {
+	case err = <-errChan:
+		assert.NotErrorIs(s.T(), err, errNoClient)
+	case <-time.After(5 * time.Second):
+		s.T().Fatal("server did not stop after the context was canceled")
+	}
+}
+
+// TestDictMisuseError tests that a misused dict call fails the prompt request with a clear message
+func (s *PromptsServerTestSuite) TestDictMisuseError() {
+	ctx := context.Background()
+
+	files := map[string]string{
+		"_header.tmpl": `{{define "_header"}}{{.role}} doing {{.task}}{{end}}`,
+		"broken.tmpl":  "{{/* Broken partial data */}}\n{{template \"_header\" dict \"role\" .role \"task\"}}",
+	}
+	for name, content := range files {
+		require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, name), []byte(content), 0644))
+	}
+
+	_, mcpClient, promptsClose := s.makePromptsServerAndClient(ctx, s.tempDir, true)
+	defer promptsClose()
+
+	getReq := mcp.GetPromptRequest{}
+	getReq.Params.Name = "broken"
+	getReq.Params.Arguments = map[string]string{"role": "reviewer"}
+	_, err := mcpClient.GetPrompt(ctx, getReq)
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "broken.tmpl:2:")
+	assert.Contains(s.T(), err.Error(), "odd number of arguments (3)")
+}
+
+// TestRecoveryOption tests that a panicking template function fails the request with recovery enabled
+// and surfaces as a panic with the stack of the function when recovery is disabled
+func (s *PromptsServerTestSuite) TestRecoveryOption() {
+	ctx := context.Background()
+	err := os.WriteFile(filepath.Join(s.tempDir, "greeting.tmpl"), []byte("{{/* Greeting */}}\nHello {{.name}}"), 0644)
+	require.NoError(s.T(), err)
+
+	makeHandler := func(recovery bool) func(context.Context, mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		promptsServer, err := NewPromptsServer(s.tempDir, WithRecovery(recovery), WithLogger(s.logger))
+		require.NoError(s.T(), err)
+		s.T().Cleanup(func() { s.Require().NoError(promptsServer.Close()) })
+		assert.Equal(s.T(), !recovery, promptsServer.parser.capturePanics)
+
+		funcs := template.FuncMap{"boom": func(msg string) string { panic(msg) }}
+		if promptsServer.parser.capturePanics {
+			funcs = capturePanicsInFuncs(funcs)
+		}
+		tmpl := template.Must(template.New("boom.tmpl").Funcs(funcs).Parse(`{{boom "template exploded"}}`))
+		return promptsServer.makeMCPHandler(tmpl, "boom.tmpl", "", "Boom", nil, nil, nil, sensitiveArgs{}, nil, nil, nil, nil)
+	}
+	req := mcp.GetPromptRequest{}
+	req.Params.Name = "boom"
+
+	_, err = makeHandler(true)(ctx, req)
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "error calling boom: template exploded")
+	assert.NotContains(s.T(), err.Error(), "goroutine")
+
+	handler := makeHandler(false)
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		_, _ = handler(ctx, req)
+	}()
+	funcPanic, ok := recovered.(*templateFuncPanic)
+	require.True(s.T(), ok, "expected a *templateFuncPanic, got %v", recovered)
+	assert.Contains(s.T(), funcPanic.Error(), `panic in template function "boom": template exploded`)
+	assert.Contains(s.T(), funcPanic.Error(), "goroutine")
+	assert.Contains(s.T(), funcPanic.Error(), "TestRecoveryOption")
+}
+
+// TestRenderSettingsRestrictions tests each render restriction individually against the unsafe fixture directory
+func (s *PromptsServerTestSuite) TestRenderSettingsRestrictions() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s.T().Setenv("AWS_SECRET_ACCESS_KEY", "super-secret")
+
+	getPrompt := func(mcpClient *client.Client, name string, args map[string]string) (*mcp.GetPromptResult, error) {
+		var getReq mcp.GetPromptRequest
+		getReq.Params.Name = name
+		getReq.Params.Arguments = args
+		return mcpClient.GetPrompt(ctx, getReq)
+	}
+	promptText := func(result *mcp.GetPromptResult) string {
+		require.Len(s.T(), result.Messages, 1, "Expected exactly 1 message")
+		content, ok := result.Messages[0].Content.(mcp.TextContent)
+		require.True(s.T(), ok, "Expected TextContent")
+		return content.Text
+	}
+	findPrompt := func(mcpClient *client.Client, name string) mcp.Prompt {
+		listResult, err := mcpClient.ListPrompts(ctx, mcp.ListPromptsRequest{})
+		require.NoError(s.T(), err, "ListPrompts failed")
+		for _, prompt := range listResult.Prompts {
+			if prompt.Name == name {
+				return prompt
+			}
+		}
+		s.T().Fatalf("prompt %q not found", name)
+		return mcp.Prompt{}
+	}
+
+	s.Run("environment variable fallback enabled by default", func() {
+		_, mcpClient, promptsClose := s.makePromptsServerAndClient(ctx, "./testdata/unsafe", true)
+		defer promptsClose()
+
+		assert.Empty(s.T(), findPrompt(mcpClient, "env_secret").Arguments)
+		result, err := getPrompt(mcpClient, "env_secret", nil)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "Key: super-secret", promptText(result))
+		assert.Nil(s.T(), result.Meta, "provenance must be disabled by default")
+	})
+
+	s.Run("environment variable fallback disabled", func() {
+		_, mcpClient, promptsClose := s.makePromptsServerAndClientWithSettings(ctx, "./testdata/unsafe", true,
+			DefaultArgsLimits(), RenderSettings{DisableEnvArgs: true})
+		defer promptsClose()
+
+		prompt := findPrompt(mcpClient, "env_secret")
+		require.Len(s.T(), prompt.Arguments, 1)
+		assert.Equal(s.T(), "aws_secret_access_key", prompt.Arguments[0].Name)
+		result, err := getPrompt(mcpClient, "env_secret", nil)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "Key: <no value>", promptText(result))
+	})
+
+	s.Run("output size limit", func() {
+		_, mcpClient, promptsClose := s.makePromptsServerAndClientWithSettings(ctx, "./testdata/unsafe", true,
+			DefaultArgsLimits(), RenderSettings{MaxOutputSize: 1024})
+		defer promptsClose()
+
+		_, err := getPrompt(mcpClient, "huge_output", nil)
+		require.Error(s.T(), err)
+		assert.Contains(s.T(), err.Error(), "rendered output exceeds size limit of 1024 bytes")
+
+		result, err := getPrompt(mcpClient, "greeting", map[string]string{"name": "Alice"})
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "Hello Alice!", promptText(result))
+	})
+
+	s.Run("render timeout", func() {
+		_, mcpClient, promptsClose := s.makePromptsServerAndClientWithSettings(ctx, "./testdata/unsafe", true,
+			DefaultArgsLimits(), RenderSettings{Timeout: 100 * time.Millisecond})
+		defer promptsClose()
+
+		_, err := getPrompt(mcpClient, "slow_output", nil)
+		require.Error(s.T(), err)
+		assert.Contains(s.T(), err.Error(), "render timed out after 100ms")
+
+		result, err := getPrompt(mcpClient, "greeting", map[string]string{"name": "Alice"})
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "Hello Alice!", promptText(result))
+	})
+
+	s.Run("argument sanitization", func() {
+		_, mcpClient, promptsClose := s.makePromptsServerAndClientWithSettings(ctx, "./testdata/unsafe", true,
+			ArgsLimits{MaxTotalSize: 16}, RenderSettings{})
+		defer promptsClose()
+
+		_, err := getPrompt(mcpClient, "greeting", map[string]string{"name": strings.Repeat("x", 32)})
+		require.Error(s.T(), err)
+		assert.Contains(s.T(), err.Error(), "arguments payload size")
+	})
+
+	s.Run("provenance metadata", func() {
+		_, mcpClient, promptsClose := s.makePromptsServerAndClientWithSettings(ctx, "./testdata/unsafe", true,
+			DefaultArgsLimits(), RenderSettings{Provenance: true})
+		defer promptsClose()
+
+		content, err := os.ReadFile("./testdata/unsafe/greeting.tmpl")
+		require.NoError(s.T(), err)
+
+		result, err := getPrompt(mcpClient, "greeting", map[string]string{"name": "Alice"})
+		require.NoError(s.T(), err)
+		require.NotNil(s.T(), result.Meta)
+		assert.Equal(s.T(), map[string]any{
+			"template": "greeting.tmpl",
+			"sha256":   fmt.Sprintf("%x", sha256.Sum256(content)),
+		}, result.Meta.AdditionalFields["provenance"])
+	})
+
+	s.Run("exposed arguments", func() {
+		_, mcpClient, promptsClose := s.makePromptsServerAndClientWithSettings(ctx, "./testdata/unsafe", true,
+			DefaultArgsLimits(), RenderSettings{ExposeArgs: true, Provenance: true})
+		defer promptsClose()
+
+		result, err := getPrompt(mcpClient, "greeting", map[string]string{"name": "Alice"})
+		require.NoError(s.T(), err)
+		require.NotNil(s.T(), result.Meta)
+		assert.Equal(s.T(), map[string]any{"name": "Alice"}, result.Meta.AdditionalFields["arguments"])
+		assert.Contains(s.T(), result.Meta.AdditionalFields, "provenance")
+
+		result, err = getPrompt(mcpClient, "greeting", nil)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), map[string]any{}, result.Meta.AdditionalFields["arguments"],
+			"arguments of a previous request must not leak")
+	})
+
+	s.Run("errors as content", func() {
+		_, mcpClient, promptsClose := s.makePromptsServerAndClientWithSettings(ctx, "./testdata/unsafe", true,
+			DefaultArgsLimits(), RenderSettings{MaxOutputSize: 64, ErrorsAsContent: true, Provenance: true})
+		defer promptsClose()
+
+		result, err := getPrompt(mcpClient, "huge_output", nil)
+		require.NoError(s.T(), err)
+		assert.True(s.T(), strings.HasPrefix(promptText(result), "Error: "), promptText(result))
+		assert.Contains(s.T(), promptText(result), "rendered output exceeds size limit")
+		require.NotNil(s.T(), result.Meta)
+		assert.Equal(s.T(), true, result.Meta.AdditionalFields["isError"])
+		assert.Contains(s.T(), result.Meta.AdditionalFields, "provenance")
+
+		result, err = getPrompt(mcpClient, "greeting", map[string]string{"name": "Alice"})
+		require.NoError(s.T(), err)
+		assert.NotContains(s.T(), result.Meta.AdditionalFields, "isError")
+
+		_, err = getPrompt(mcpClient, "greeting", map[string]string{"name": strings.Repeat("[", 40)})
+		require.Error(s.T(), err, "invalid arguments remain protocol errors")
+	})
+
+	s.Run("arguments are not exposed by default", func() {
+		_, mcpClient, promptsClose := s.makePromptsServerAndClientWithSettings(ctx, "./testdata/unsafe", true,
+			DefaultArgsLimits(), RenderSettings{})
+		defer promptsClose()
+
+		result, err := getPrompt(mcpClient, "greeting", map[string]string{"name": "Alice"})
+		require.NoError(s.T(), err)
+		assert.Nil(s.T(), result.Meta)
+	})
+
+	s.Run("safe mode composes all restrictions", func() {
+		argsLimits, renderSettings, _, err := applySafeMode(ArgsLimits{}, RenderSettings{},
+			func(string) bool { return false })
+		require.NoError(s.T(), err)
+		_, mcpClient, promptsClose := s.makePromptsServerAndClientWithSettings(ctx, "./testdata/unsafe", true,
+			argsLimits, renderSettings)
+		defer promptsClose()
+
+		result, err := getPrompt(mcpClient, "env_secret", nil)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "Key: <no value>", promptText(result))
+		assert.Contains(s.T(), result.Meta.AdditionalFields, "provenance")
+
+		_, err = getPrompt(mcpClient, "huge_output", nil)
+		require.Error(s.T(), err)
+		assert.Contains(s.T(), err.Error(), "rendered output exceeds size limit")
+
+		_, err = getPrompt(mcpClient, "greeting", map[string]string{"name": strings.Repeat("[", 40)})
+		require.Error(s.T(), err)
+		assert.Contains(s.T(), err.Error(), "JSON nesting depth exceeds limit")
+	})
+}
+
+// TestReloadsKeepHeapFlat tests that repeated reloads do not retain the prompts of earlier generations
+func (s *PromptsServerTestSuite) TestReloadsKeepHeapFlat() {
+	start, end := measureReloadHeap(s.T(), 50, 100)
+	assert.Less(s.T(), int64(end)-int64(start), int64(256<<10),
+		"heap grew from %d to %d bytes over the reloads", start, end)
+}
+
+// measureReloadHeap serves a synthetic directory of templates, modifying and reloading it the given number of times,
+// and returns the heap in use after a GC before and after the reloads. The first reloads warm up and are not measured.
+func measureReloadHeap(tb testing.TB, templates int, reloads int) (start uint64, end uint64) {
+	tb.Helper()
+	dir := tb.TempDir()
+	writeGeneration := func(generation int) {
+		for i := range templates {
+			body := fmt.Sprintf("{{/* Prompt %d, generation %d */}}\n{{.branch}} {{if .urgent}}{{.reason}}{{end}}\n"+
+				"{{range .items}}- {{.}}\n{{end}}", i, generation)
+			require.NoError(tb, os.WriteFile(filepath.Join(dir, fmt.Sprintf("prompt_%03d.tmpl", i)), []byte(body), 0644))
+		}
+	}
+	writeGeneration(0)
+	promptsServer, err := NewPromptsServer(dir, WithWatchMode(WatchModeOff, 0), WithLogger(slog.New(slog.DiscardHandler)))
+	require.NoError(tb, err)
+	defer func() { require.NoError(tb, promptsServer.Close()) }()
+
+	heapInUse := func() uint64 {
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return stats.HeapAlloc
+	}
+	for generation := 1; generation <= 3; generation++ {
+		writeGeneration(generation)
+		require.NoError(tb, promptsServer.Reload())
+	}
+	start = heapInUse()
+	for i := range reloads {
+		if i%10 == 0 {
+			writeGeneration(i)
+		}
+		require.NoError(tb, promptsServer.Reload())
+	}
+	return start, heapInUse()
+}
+
+// BenchmarkReloadHeap reloads a directory of 400 templates 1000 times and reports the heap in use before and after.
+func BenchmarkReloadHeap(b *testing.B) {
+	for range b.N {
+		start, end := measureReloadHeap(b, 400, 1000)
+		b.ReportMetric(float64(start), "heap-start-B")
+		b.ReportMetric(float64(end), "heap-end-B")
+	}
+}
+
+// mockSamplingHandler answers sampling requests with a fixed summary and records the received requests
+type mockSamplingHandler struct {
+	mu       sync.Mutex
+	requests []mcp.CreateMessageRequest
+	err      error
+}
+
+func (h *mockSamplingHandler) CreateMessage(
+	ctx context.Context, request mcp.CreateMessageRequest,
+) (*mcp.CreateMessageResult, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.requests = append(h.requests, request)
+	if h.err != nil {
+		return nil, h.err
+	}
+	return &mcp.CreateMessageResult{
+		SamplingMessage: mcp.SamplingMessage{Role: mcp.RoleAssistant, Content: mcp.NewTextContent("A short summary.")},
+		Model:           "mock-model",
+	}, nil
+}
+
+// TestSummarizeHelper tests the summarize helper with client sampling and its truncation fallback
+func (s *PromptsServerTestSuite) TestSummarizeHelper() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := os.WriteFile(filepath.Join(s.tempDir, "summary.tmpl"),
+		[]byte("{{/* Summary prompt */}}\nSummary: {{summarize .long_text 5}}"), 0644)
+	require.NoError(s.T(), err)
+	longText := "The quick brown fox jumps over the lazy dog while the cat watches."
+
+	getSummary := func(mcpClient *client.Client) string {
+		var getReq mcp.GetPromptRequest
+		getReq.Params.Name = "summary"
+		getReq.Params.Arguments = map[string]string{"long_text": longText}
+		getResult, err := mcpClient.GetPrompt(ctx, getReq)
+		require.NoError(s.T(), err, "GetPrompt failed")
+		require.Len(s.T(), getResult.Messages, 1, "Expected exactly 1 message")
+		content, ok := getResult.Messages[0].Content.(mcp.TextContent)
+		require.True(s.T(), ok, "Expected TextContent")
+		return content.Text
+	}
+	const truncated = "Summary: The quick brown fox…"
+
+	s.Run("sampling allowed and supported by client", func() {
+		handler := &mockSamplingHandler{}
+		_, mcpClient, promptsClose := s.makePromptsServerAndClientWithSettings(ctx, s.tempDir, true,
+			DefaultArgsLimits(), RenderSettings{AllowSampling: true}, client.WithSamplingHandler(handler))
+		defer promptsClose()
+
+		assert.Equal(s.T(), "Summary: A short summary.", getSummary(mcpClient))
+		require.Len(s.T(), handler.requests, 1)
+		assert.Equal(s.T(), 5, handler.requests[0].MaxTokens)
+		assert.Equal(s.T(), map[string]interface{}{"type": "text", "text": longText},
+			handler.requests[0].Messages[0].Content)
+	})
+
+	s.Run("sampling fails", func() {
+		handler := &mockSamplingHandler{err: fmt.Errorf("model unavailable")}
+		_, mcpClient, promptsClose := s.makePromptsServerAndClientWithSettings(ctx, s.tempDir, true,
+			DefaultArgsLimits(), RenderSettings{AllowSampling: true}, client.WithSamplingHandler(handler))
+		defer promptsClose()
+
+		assert.Equal(s.T(), truncated, getSummary(mcpClient))
+		assert.Len(s.T(), handler.requests, 1)
+	})
+
+	s.Run("client without sampling capability", func() {
+		_, mcpClient, promptsClose := s.makePromptsServerAndClientWithSettings(ctx, s.tempDir, true,
+			DefaultArgsLimits(), RenderSettings{AllowSampling: true})
+		defer promptsClose()
+
+		assert.Equal(s.T(), truncated, getSummary(mcpClient))
+	})
+
+	s.Run("sampling not allowed", func() {
+		handler := &mockSamplingHandler{}
+		_, mcpClient, promptsClose := s.makePromptsServerAndClientWithSettings(ctx, s.tempDir, true,
+			DefaultArgsLimits(), RenderSettings{}, client.WithSamplingHandler(handler))
+		defer promptsClose()
+
+		assert.Equal(s.T(), truncated, getSummary(mcpClient))
+		assert.Empty(s.T(), handler.requests)
+	})
+}
+
+// TestConcurrentStdioPrompts tests that the responses of prompts handled concurrently for sampling are written whole,
+// and that the dispatch does not wait for a slot when all are taken. Run it with -race.
+func (s *PromptsServerTestSuite) TestConcurrentStdioPrompts() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, "echo.tmpl"),
+		[]byte("{{/* Echo prompt */}}\n{{.text}} {{summarize .text 1}}"), 0644))
+	promptsServer, err := NewPromptsServer(s.tempDir, WithJSONArgs(false),
+		WithRenderSettings(RenderSettings{AllowSampling: true}), WithLogger(s.logger))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+	errChan := make(chan error, 1)
+	go func() {
+		// Writes are split into chunks, like writes to a file larger than the pipe buffer, so unsynchronized
+		// messages would interleave
+		errChan <- promptsServer.ServeStdio(ctx, serverReader, &chunkedWriter{w: serverWriter, size: 4096})
+	}()
+	transp := transport.NewIO(clientReader, clientWriter, io.NopCloser(&bytes.Buffer{}))
+	require.NoError(s.T(), transp.Start(ctx))
+	defer func() { s.NoError(transp.Close()) }()
+	mcpClient := client.NewClient(transp, client.WithSamplingHandler(&mockSamplingHandler{}))
+	require.NoError(s.T(), mcpClient.Start(ctx))
+	var initReq mcp.InitializeRequest
+	initReq.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	_, err = mcpClient.Initialize(ctx, initReq)
+	require.NoError(s.T(), err)
+
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			text := strings.Repeat(string(rune('a'+i)), 256<<10)
+			var getReq mcp.GetPromptRequest
+			getReq.Params.Name = "echo"
+			getReq.Params.Arguments = map[string]string{"text": text}
+			getResult, err := mcpClient.GetPrompt(ctx, getReq)
+			if !s.NoError(err) || !s.Len(getResult.Messages, 1) {
+				return
+			}
+			content, ok := getResult.Messages[0].Content.(mcp.TextContent)
+			s.True(ok)
+			s.Equal(text+" A short summary.", content.Text)
+		}()
+	}
+	wg.Wait()
+	cancel()
+	s.NoError(<-errChan)
+
+	requests := &stdioPromptRequests{slots: make(chan struct{}, 1)}
+	release := make(chan struct{})
+	require.True(s.T(), requests.start(func() { <-release }))
+	assert.False(s.T(), requests.start(func() {}), "all slots are taken")
+	close(release)
+	requests.wait()
+	assert.False(s.T(), requests.start(func() {}), "no requests start after wait")
+}
+
+// chunkedWriter writes to the underlying writer in chunks of at most size bytes
+type chunkedWriter struct {
+	w    io.Writer
+	size int
+}
+
+func (w *chunkedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		n, err := w.w.Write(p[written:min(written+w.size, len(p))])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		runtime.Gosched()
+	}
+	return written, nil
+}
+
+// TestPromptCollections tests ordering and titles of prompts defined in the collections manifest
+func (s *PromptsServerTestSuite) TestPromptCollections() {
+	ctx := context.Background()
+
+	files := map[string]string{
+		"git_commit.tmpl":   "{{/* Commit changes */}}\nCommit {{.type}}",
+		"git_pr.tmpl":       "{{/* Describe PR */}}\nPR",
+		"apology.tmpl":      "{{/* Apologize */}}\nSorry",
+		"greeting.tmpl":     "{{/* Greeting */}}\nHello {{.name}}!",
+		"review_style.tmpl": "Review",
+		collectionsFileName: `collections:
+  - name: Reviews
+    prompts: [review_style]
+  - name: Git
+    prompts:
+      - name: git_pr
+        label: PR description
+      - name: git_commit
+        label: Commit message
+`,
+	}
+	for name, content := range files {
+		require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, name), []byte(content), 0644))
+	}
+
+	_, mcpClient, promptsClose := s.makePromptsServerAndClient(ctx, s.tempDir, true)
+	defer promptsClose()
+
+	listResult, err := mcpClient.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	require.NoError(s.T(), err, "ListPrompts failed")
+	var names, descriptions []string
+	for _, prompt := range listResult.Prompts {
+		names = append(names, prompt.Name)
+		descriptions = append(descriptions, prompt.Description)
+	}
+	assert.Equal(s.T(), []string{"review_style", "git_pr", "git_commit", "apology", "greeting"}, names)
+	assert.Equal(s.T(), []string{
+		"Reviews: review_style",
+		"Git: PR description - Describe PR",
+		"Git: Commit message - Commit changes",
+		"Apologize",
+		"Greeting",
+	}, descriptions)
+	require.NotNil(s.T(), listResult.Prompts[2].Meta)
+	assert.Equal(s.T(), map[string]any{"title": "Git: Commit message", "collection": "Git"},
+		listResult.Prompts[2].Meta.AdditionalFields)
+	assert.Nil(s.T(), listResult.Prompts[3].Meta)
+
+	var getReq mcp.GetPromptRequest
+	getReq.Params.Name = "git_commit"
+	getReq.Params.Arguments = map[string]string{"type": "fix"}
+	getResult, err := mcpClient.GetPrompt(ctx, getReq)
+	require.NoError(s.T(), err, "GetPrompt failed")
+	assert.Equal(s.T(), "Git: Commit message - Commit changes", getResult.Description)
+
+	// Changing the manifest triggers a reload
+	err = os.WriteFile(filepath.Join(s.tempDir, collectionsFileName),
+		[]byte("collections:\n  - name: Greetings\n    prompts: [greeting]\n"), 0644)
+	require.NoError(s.T(), err)
+	time.Sleep(100 * time.Millisecond)
+
+	listResult, err = mcpClient.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	require.NoError(s.T(), err, "ListPrompts failed after manifest change")
+	names = nil
+	for _, prompt := range listResult.Prompts {
+		names = append(names, prompt.Name)
+	}
+	assert.Equal(s.T(), []string{"greeting", "apology", "git_commit", "git_pr", "review_style"}, names)
+	assert.Equal(s.T(), "Greetings: greeting - Greeting", listResult.Prompts[0].Description)
+}
+
+// TestNewPromptsServerJoinsErrors tests that every failure of the initialization is reported, each with
+// the component it comes from, and that the file watcher is closed when the server fails to initialize
+func (s *PromptsServerTestSuite) TestNewPromptsServerJoinsErrors() {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, "broken.tmpl"), []byte("{{/* Broken */}}\n{{.name"), 0644))
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, "stray.tmpl"), []byte("{{/* Stray */}}\n{{end}}"), 0644))
+	configPath := filepath.Join(s.tempDir, "config.yaml")
+	require.NoError(s.T(), os.WriteFile(configPath, []byte("log_level: [\n"), 0644))
+
+	inotifyFDs := countInotifyFDs(s.T())
+	promptsServer, err := NewPromptsServer(s.tempDir,
+		WithLogger(s.logger),
+		WithWatchMode(WatchModeFSNotify, 0),
+		WithRuntimeConfig(configPath, nil),
+		WithFeatureFlags(filepath.Join(s.tempDir, "missing", "flags.yaml")),
+	)
+	require.Error(s.T(), err)
+	assert.Nil(s.T(), promptsServer)
+
+	lines := errorLines(err)
+	require.Len(s.T(), lines, 4, strings.Join(lines, "\n"))
+	assert.True(s.T(), strings.HasPrefix(lines[0], "watch prompts directory: add flags file directory to watcher: "), lines[0])
+	assert.True(s.T(), strings.HasPrefix(lines[1], "load runtime config: "), lines[1])
+	assert.True(s.T(), strings.HasPrefix(lines[2], "reload prompts: "), lines[2])
+	assert.Contains(s.T(), lines[2], "broken.tmpl")
+	assert.True(s.T(), strings.HasPrefix(lines[3], "reload prompts: "), lines[3])
+	assert.Contains(s.T(), lines[3], "stray.tmpl")
+
+	require.NoError(s.T(), os.Remove(configPath))
+	require.NoError(s.T(), os.Remove(filepath.Join(s.tempDir, "stray.tmpl")))
+	_, err = NewPromptsServer(s.tempDir, WithLogger(s.logger), WithWatchMode(WatchModeFSNotify, 0))
+	require.Error(s.T(), err)
+	assert.Equal(s.T(), inotifyFDs, countInotifyFDs(s.T()), "the watchers of the failed servers must be closed")
+}
+
+// countInotifyFDs returns the number of inotify instances open in the process, skipping the test without procfs
+func countInotifyFDs(t *testing.T) int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("procfs is not available")
+	}
+	count := 0
+	for _, entry := range entries {
+		if target, err := os.Readlink(filepath.Join("/proc/self/fd", entry.Name())); err == nil && target == "anon_inode:inotify" {
+			count++
+		}
+	}
+	return count
+}
+
+// closeFailingWatcher is a fileWatcher that fails to close
+type closeFailingWatcher struct {
+	*fakeWatcher
+}
+
+func (w closeFailingWatcher) Close() error { return errors.New("bad file descriptor") }
+
+// TestCloseJoinsErrors tests that Close reports the failures to release the resources of the server
+func (s *PromptsServerTestSuite) TestCloseJoinsErrors() {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, "greeting.tmpl"), []byte("Hello {{.name}}"), 0644))
+	promptsServer, err := NewPromptsServer(s.tempDir, WithLogger(s.logger), WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	promptsServer.watcher = closeFailingWatcher{newFakeWatcher()}
+
+	assert.EqualError(s.T(), promptsServer.Close(), "close file watcher: bad file descriptor")
+	assert.NoError(s.T(), promptsServer.Close(), "resources must be released once")
+}
+
 func (s *PromptsServerTestSuite) makePromptsServerAndClient(
 	ctx context.Context, promptsDir string, enableJSONArgs bool,
+) (*PromptsServer, *client.Client, func()) {
+	return s.makePromptsServerAndClientWithSettings(ctx, promptsDir, enableJSONArgs, DefaultArgsLimits(), RenderSettings{})
+}
+
+func (s *PromptsServerTestSuite) makePromptsServerAndClientWithSettings(
+	ctx context.Context, promptsDir string, enableJSONArgs bool, argsLimits ArgsLimits, renderSettings RenderSettings,
+	clientOpts ...client.ClientOption,
 ) (*PromptsServer, *client.Client, func()) {
 	var ctxCancel context.CancelFunc
 	ctx, ctxCancel = context.WithCancel(ctx)
 
 	// Create prompts server that will watch the temp directory
-	promptsServer, err := NewPromptsServer(promptsDir, enableJSONArgs, s.logger)
+	promptsServer, err := NewPromptsServer(promptsDir,
+		WithJSONArgs(enableJSONArgs),
+		WithArgsLimits(argsLimits),
+		WithRenderSettings(renderSettings),
+		WithLogger(s.logger),
+	)
 	require.NoError(s.T(), err, "Failed to create prompts server")
 
 	// Set up pipes for client-server communication
@@ -643,7 +1942,10 @@ func (s *PromptsServerTestSuite) makePromptsServerAndClient(
 	err = transp.Start(ctx)
 	require.NoError(s.T(), err, "Failed to start transport")
 
-	mcpClient := client.NewClient(transp)
+	// Starting the client registers handlers for server-initiated requests such as sampling
+	mcpClient := client.NewClient(transp, clientOpts...)
+	err = mcpClient.Start(ctx)
+	require.NoError(s.T(), err, "Failed to start client")
 
 	// Initialize the client
 	var initReq mcp.InitializeRequest
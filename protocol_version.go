@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// protocolVersionLayout is the layout of MCP protocol versions, which are the dates of the specification revisions.
+const protocolVersionLayout = "2006-01-02"
+
+// IncompatiblePrompts selects how prompt listings treat prompts requiring a newer protocol version than the session.
+type IncompatiblePrompts string
+
+const (
+	IncompatiblePromptsHide IncompatiblePrompts = "hide" // leave them out of prompt listings
+	IncompatiblePromptsMark IncompatiblePrompts = "mark" // list them with the required version in the description
+)
+
+// ParseIncompatiblePrompts parses a mode as accepted by the --incompatible-prompts flag.
+func ParseIncompatiblePrompts(s string) (IncompatiblePrompts, error) {
+	switch mode := IncompatiblePrompts(strings.ToLower(strings.TrimSpace(s))); mode {
+	case IncompatiblePromptsHide, IncompatiblePromptsMark:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown incompatible prompts mode %q (expected hide or mark)", s)
+}
+
+// parseProtocolVersion parses an MCP protocol version such as "2025-03-26".
+func parseProtocolVersion(version string) (time.Time, error) {
+	t, err := time.Parse(protocolVersionLayout, version)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid protocol version %q (expected a date such as %q)",
+			version, mcp.LATEST_PROTOCOL_VERSION)
+	}
+	return t, nil
+}
+
+// compareProtocolVersions returns -1, 0, or +1 depending on whether version a is older than, the same as,
+// or newer than version b.
+func compareProtocolVersions(a, b string) (int, error) {
+	ta, err := parseProtocolVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	tb, err := parseProtocolVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	return ta.Compare(tb), nil
+}
+
+// recordSessionProtocol keeps the protocol version negotiated by the session of the context.
+func (ps *PromptsServer) recordSessionProtocol(ctx context.Context, version string) {
+	sessionID := sessionIDFromContext(ctx)
+	if sessionID == "" {
+		return
+	}
+	ps.protocolsMu.Lock()
+	defer ps.protocolsMu.Unlock()
+	ps.protocols[sessionID] = version
+}
+
+// forgetSessionProtocol drops the protocol version of a session that ended.
+func (ps *PromptsServer) forgetSessionProtocol(sessionID string) {
+	ps.protocolsMu.Lock()
+	defer ps.protocolsMu.Unlock()
+	delete(ps.protocols, sessionID)
+}
+
+// sessionProtocol returns the protocol version negotiated by the session of the context,
+// or an empty string if the request has no session or the session did not initialize.
+func (ps *PromptsServer) sessionProtocol(ctx context.Context) string {
+	ps.protocolsMu.RLock()
+	defer ps.protocolsMu.RUnlock()
+	return ps.protocols[sessionIDFromContext(ctx)]
+}
+
+// requiredProtocol returns the minimum protocol version of the prompt if the session of the context negotiated
+// an older one, or an empty string if the prompt is compatible. Sessions of unknown versions are compatible.
+func (ps *PromptsServer) requiredProtocol(ctx context.Context, promptName string) string {
+	ps.minProtocolsMu.RLock()
+	minProtocol := ps.minProtocols[promptName]
+	ps.minProtocolsMu.RUnlock()
+	sessionProtocol := ps.sessionProtocol(ctx)
+	if minProtocol == "" || sessionProtocol == "" {
+		return ""
+	}
+	// Minimum versions are validated on load and negotiated versions are known to the SDK
+	if cmp, err := compareProtocolVersions(sessionProtocol, minProtocol); err != nil || cmp >= 0 {
+		return ""
+	}
+	return minProtocol
+}
+
+// withIncompatiblePrompts hides or marks the prompts requiring a newer protocol version than the session of the context.
+func (ps *PromptsServer) withIncompatiblePrompts(ctx context.Context, prompts []mcp.Prompt) []mcp.Prompt {
+	compatible := prompts[:0]
+	for _, prompt := range prompts {
+		if minProtocol := ps.requiredProtocol(ctx, prompt.Name); minProtocol != "" {
+			if ps.incompatiblePrompts != IncompatiblePromptsMark {
+				continue
+			}
+			prompt.Description = strings.TrimSpace(
+				fmt.Sprintf("%s (requires MCP protocol version %s or later)", prompt.Description, minProtocol))
+		}
+		compatible = append(compatible, prompt)
+	}
+	return compatible
+}
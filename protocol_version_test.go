@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ProtocolVersionTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestProtocolVersionTestSuite(t *testing.T) {
+	suite.Run(t, new(ProtocolVersionTestSuite))
+}
+
+func (s *ProtocolVersionTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("basic.tmpl", "{{/* Basic */}}\nHello")
+	s.writeFile("modern.tmpl", "---\nmin_protocol: 2025-06-18\n---\n{{/* Modern */}}\nHello")
+}
+
+func (s *ProtocolVersionTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+// newSession registers an in-process session, initializes it with the protocol version,
+// and returns the context of its requests
+func (s *ProtocolVersionTestSuite) newSession(promptsServer *PromptsServer, id, protocolVersion string) context.Context {
+	session := server.NewInProcessSession(id, nil)
+	require.NoError(s.T(), promptsServer.mcpServer.RegisterSession(context.Background(), session))
+	ctx := promptsServer.mcpServer.WithContext(context.Background(), session)
+	s.request(ctx, promptsServer, "initialize", map[string]any{
+		"protocolVersion": protocolVersion,
+		"clientInfo":      map[string]any{"name": id, "version": "1.0.0"},
+		"capabilities":    map[string]any{},
+	})
+	return ctx
+}
+
+// request sends a request to the server and returns the JSON response
+func (s *ProtocolVersionTestSuite) request(
+	ctx context.Context, promptsServer *PromptsServer, method string, params map[string]any,
+) []byte {
+	message, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	require.NoError(s.T(), err)
+	response, err := json.Marshal(promptsServer.mcpServer.HandleMessage(ctx, message))
+	require.NoError(s.T(), err)
+	return response
+}
+
+// listPrompts returns the descriptions of the prompts listed to the session by prompt name
+func (s *ProtocolVersionTestSuite) listPrompts(ctx context.Context, promptsServer *PromptsServer) map[string]string {
+	var decoded struct {
+		Result struct {
+			Prompts []struct {
+				Name        string `json:"name"`
+				Description string `json:"description"`
+			} `json:"prompts"`
+		} `json:"result"`
+	}
+	require.NoError(s.T(), json.Unmarshal(s.request(ctx, promptsServer, "prompts/list", map[string]any{}), &decoded))
+	prompts := make(map[string]string)
+	for _, prompt := range decoded.Result.Prompts {
+		prompts[prompt.Name] = prompt.Description
+	}
+	return prompts
+}
+
+// getPromptError returns the error message of a GetPrompt request, or an empty string if it succeeds
+func (s *ProtocolVersionTestSuite) getPromptError(ctx context.Context, promptsServer *PromptsServer, name string) string {
+	var decoded struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	require.NoError(s.T(), json.Unmarshal(s.request(ctx, promptsServer, "prompts/get", map[string]any{"name": name}), &decoded))
+	return decoded.Error.Message
+}
+
+// TestCompareProtocolVersions tests the comparison of date-based protocol versions
+func (s *ProtocolVersionTestSuite) TestCompareProtocolVersions() {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "2024-11-05", b: "2025-03-26", expected: -1},
+		{a: "2025-06-18", b: "2025-03-26", expected: 1},
+		{a: "2025-03-26", b: "2025-03-26", expected: 0},
+		{a: "2025-12-01", b: "2025-06-18", expected: 1},
+	}
+	for _, tt := range tests {
+		cmp, err := compareProtocolVersions(tt.a, tt.b)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), tt.expected, cmp, "%s vs %s", tt.a, tt.b)
+	}
+
+	for _, version := range []string{"", "2025", "2025-13-01", "v2025-03-26", "latest"} {
+		_, err := compareProtocolVersions(version, "2025-03-26")
+		assert.ErrorContains(s.T(), err, fmt.Sprintf("invalid protocol version %q", version))
+	}
+}
+
+// TestParseIncompatiblePrompts tests the values of the --incompatible-prompts flag
+func (s *ProtocolVersionTestSuite) TestParseIncompatiblePrompts() {
+	mode, err := ParseIncompatiblePrompts(" Mark ")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), IncompatiblePromptsMark, mode)
+	_, err = ParseIncompatiblePrompts("drop")
+	assert.EqualError(s.T(), err, `unknown incompatible prompts mode "drop" (expected hide or mark)`)
+}
+
+// TestSessionsOfDifferentVersions tests that each session lists and gets the prompts of its protocol version
+func (s *ProtocolVersionTestSuite) TestSessionsOfDifferentVersions() {
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	oldSession := s.newSession(promptsServer, "old", "2024-11-05")
+	newSession := s.newSession(promptsServer, "new", "2025-06-18")
+
+	assert.Equal(s.T(), map[string]string{"basic": "Basic"}, s.listPrompts(oldSession, promptsServer))
+	assert.Equal(s.T(), map[string]string{"basic": "Basic", "modern": "Modern"}, s.listPrompts(newSession, promptsServer))
+	assert.Equal(s.T(), map[string]string{"basic": "Basic", "modern": "Modern"},
+		s.listPrompts(context.Background(), promptsServer), "requests without a session are not filtered")
+
+	assert.Equal(s.T(), `prompt "modern" requires MCP protocol version 2025-06-18 or later, the session uses 2024-11-05`,
+		s.getPromptError(oldSession, promptsServer, "modern"))
+	assert.Empty(s.T(), s.getPromptError(oldSession, promptsServer, "basic"))
+	assert.Empty(s.T(), s.getPromptError(newSession, promptsServer, "modern"))
+
+	// The version of an ended session is forgotten
+	promptsServer.mcpServer.UnregisterSession(context.Background(), "old")
+	assert.Empty(s.T(), promptsServer.sessionProtocol(oldSession))
+}
+
+// TestMarkIncompatiblePrompts tests that marked prompts are listed with the required version but still rejected
+func (s *ProtocolVersionTestSuite) TestMarkIncompatiblePrompts() {
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithWatchMode(WatchModeOff, 0),
+		WithIncompatiblePrompts(IncompatiblePromptsMark))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	oldSession := s.newSession(promptsServer, "old", "2025-03-26")
+	assert.Equal(s.T(), map[string]string{
+		"basic":  "Basic",
+		"modern": "Modern (requires MCP protocol version 2025-06-18 or later)",
+	}, s.listPrompts(oldSession, promptsServer))
+	assert.Contains(s.T(), s.getPromptError(oldSession, promptsServer, "modern"), "requires MCP protocol version 2025-06-18")
+}
+
+// TestInvalidMinProtocol tests that unparseable versions fail loading the prompt and are reported by validate
+func (s *ProtocolVersionTestSuite) TestInvalidMinProtocol() {
+	s.writeFile("modern.tmpl", "---\nmin_protocol: June 2025\n---\n{{/* Modern */}}\nHello")
+
+	_, err := NewPromptsServer(s.promptsDir, WithWatchMode(WatchModeOff, 0))
+	assert.ErrorContains(s.T(), err, `min_protocol: invalid protocol version "June 2025"`)
+
+	var buf bytes.Buffer
+	err = validateTemplates(&buf, s.promptsDir, nil, "", validateOptions{})
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), removeANSIColors(buf.String()), `modern.tmpl - Error: frontmatter: min_protocol: invalid protocol version "June 2025"`)
+}
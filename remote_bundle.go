@@ -0,0 +1,205 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// bundleFormat is the archive format of a remote prompts bundle.
+type bundleFormat string
+
+const (
+	bundleFormatNone bundleFormat = ""
+	bundleFormatTar  bundleFormat = "tar" // optionally gzip-compressed
+	bundleFormatZip  bundleFormat = "zip"
+)
+
+// maxRemoteBundleSize bounds the size of a bundle and the total size of the files extracted from it.
+const maxRemoteBundleSize = 50 << 20
+
+// bundleFormatOf returns the bundle format of the URL from the extension of its path,
+// or bundleFormatNone if the URL is not of a bundle.
+func bundleFormatOf(bundleURL *url.URL) bundleFormat {
+	urlPath := strings.ToLower(bundleURL.Path)
+	switch {
+	case strings.HasSuffix(urlPath, ".zip"):
+		return bundleFormatZip
+	case strings.HasSuffix(urlPath, ".tar"), strings.HasSuffix(urlPath, ".tar.gz"), strings.HasSuffix(urlPath, ".tgz"):
+		return bundleFormatTar
+	}
+	return bundleFormatNone
+}
+
+// bundleEntry is a regular file read from a bundle.
+type bundleEntry struct {
+	name    string
+	content []byte
+}
+
+// extractBundle returns the contents of the files of a bundle by file name.
+// The files must be at the top of the bundle or in a single top-level directory, as archives of
+// a prompts directory have them. Directories, links, and hidden files, e.g. of macOS, are skipped.
+func extractBundle(content []byte, format bundleFormat) (map[string][]byte, error) {
+	var entries []bundleEntry
+	var err error
+	switch format {
+	case bundleFormatTar:
+		entries, err = readTarBundle(content)
+	case bundleFormatZip:
+		entries, err = readZipBundle(content)
+	default:
+		return nil, fmt.Errorf("unknown bundle format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte, len(entries))
+	topDir := ""
+	for _, entry := range entries {
+		name := cleanBundlePath(entry.name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, fmt.Errorf("invalid bundle path %q: must be inside the bundle", entry.name)
+		}
+		if skippedBundlePath(entry.name) {
+			continue
+		}
+		dir, base := path.Split(name)
+		dir = strings.TrimSuffix(dir, "/")
+		if strings.Contains(dir, "/") {
+			return nil, fmt.Errorf("invalid bundle path %q: files must be in a single top-level directory", entry.name)
+		}
+		if len(files) > 0 && dir != topDir {
+			return nil, fmt.Errorf("invalid bundle path %q: files must be in a single top-level directory", entry.name)
+		}
+		topDir = dir
+		if _, exists := files[base]; exists {
+			return nil, fmt.Errorf("duplicate bundle path %q", entry.name)
+		}
+		files[base] = entry.content
+	}
+	return files, nil
+}
+
+// cleanBundlePath returns the cleaned path of a bundle entry.
+func cleanBundlePath(name string) string {
+	return path.Clean(strings.TrimPrefix(name, "./"))
+}
+
+// skippedBundlePath reports whether the entry is a hidden file or in a hidden directory, e.g. of macOS,
+// and is skipped without being read. Paths outside the bundle are not skipped, so that they are rejected.
+func skippedBundlePath(name string) bool {
+	name = cleanBundlePath(name)
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return false
+	}
+	dir, base := path.Split(name)
+	dir = strings.TrimSuffix(dir, "/")
+	return strings.HasPrefix(base, ".") || strings.HasPrefix(dir, ".") || strings.HasPrefix(dir, "__MACOSX")
+}
+
+// bundleReader reads the entries of a bundle, failing as soon as their total size exceeds maxRemoteBundleSize,
+// so a small compressed bundle cannot make the server hold more than that in memory.
+type bundleReader struct {
+	entries []bundleEntry
+	total   int
+}
+
+func (b *bundleReader) read(r io.Reader, name string) error {
+	content, err := readBundleFile(r, name)
+	if err != nil {
+		return err
+	}
+	if b.total += len(content); b.total > maxRemoteBundleSize {
+		return fmt.Errorf("files exceed %d bytes", maxRemoteBundleSize)
+	}
+	b.entries = append(b.entries, bundleEntry{name: name, content: content})
+	return nil
+}
+
+// readTarBundle returns the regular files of a tar archive, which is decompressed first if it is gzipped.
+func readTarBundle(content []byte) ([]bundleEntry, error) {
+	var archive io.Reader = bytes.NewReader(content)
+	// Skipped entries are not kept, but still have to be decompressed to get past them.
+	decompressed := &io.LimitedReader{N: maxRemoteBundleSize + 1}
+	if bytes.HasPrefix(content, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(archive)
+		if err != nil {
+			return nil, fmt.Errorf("decompress: %w", err)
+		}
+		defer func() { _ = gz.Close() }()
+		decompressed.R = gz
+		archive = decompressed
+	}
+
+	var bundle bundleReader
+	reader := tar.NewReader(archive)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return bundle.entries, nil
+		}
+		if err != nil {
+			return nil, tarBundleError(fmt.Errorf("read tar: %w", err), decompressed)
+		}
+		if header.Typeflag != tar.TypeReg || skippedBundlePath(header.Name) {
+			continue
+		}
+		if err = bundle.read(reader, header.Name); err != nil {
+			return nil, tarBundleError(err, decompressed)
+		}
+	}
+}
+
+// tarBundleError returns the error of reading a tar bundle, or that the bundle is too large
+// if the error is the decompressed data being cut off at the limit.
+func tarBundleError(err error, decompressed *io.LimitedReader) error {
+	if decompressed.R != nil && decompressed.N == 0 {
+		return fmt.Errorf("bundle exceeds %d bytes decompressed", maxRemoteBundleSize)
+	}
+	return err
+}
+
+// readZipBundle returns the regular files of a zip archive.
+func readZipBundle(content []byte) ([]bundleEntry, error) {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("read zip: %w", err)
+	}
+	var bundle bundleReader
+	for _, file := range reader.File {
+		if !file.Mode().IsRegular() || skippedBundlePath(file.Name) {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("read zip: %w", err)
+		}
+		err = bundle.read(rc, file.Name)
+		_ = rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bundle.entries, nil
+}
+
+// readBundleFile reads a file of a bundle, failing for files larger than remote files may be.
+func readBundleFile(r io.Reader, name string) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, maxRemoteFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	if len(content) > maxRemoteFileSize {
+		return nil, fmt.Errorf("file %s exceeds %d bytes", name, maxRemoteFileSize)
+	}
+	return content, nil
+}
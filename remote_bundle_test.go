@@ -0,0 +1,236 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// bundleFile is a file of a bundle built by a test.
+type bundleFile struct {
+	name    string
+	content string
+}
+
+func makeTarBundle(t *testing.T, compress bool, files ...bundleFile) []byte {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	for _, file := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(file.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "prompts/link.tmpl", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink}))
+	require.NoError(t, tw.Close())
+	if gz != nil {
+		require.NoError(t, gz.Close())
+	}
+	return buf.Bytes()
+}
+
+func makeZipBundle(t *testing.T, files ...bundleFile) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := zw.Create(file.name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(file.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// bundleServer serves a bundle that can be replaced or broken during a test.
+type bundleServer struct {
+	mu      sync.Mutex
+	bundle  []byte
+	failing bool
+}
+
+func (b *bundleServer) set(bundle []byte, failing bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bundle, b.failing = bundle, failing
+}
+
+func (b *bundleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failing {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = w.Write(b.bundle)
+}
+
+type RemoteBundleTestSuite struct {
+	suite.Suite
+	bundles  *bundleServer
+	server   *httptest.Server
+	cacheDir string
+}
+
+func TestRemoteBundleTestSuite(t *testing.T) {
+	suite.Run(t, new(RemoteBundleTestSuite))
+}
+
+func (s *RemoteBundleTestSuite) SetupTest() {
+	s.bundles = &bundleServer{}
+	s.server = httptest.NewServer(s.bundles)
+	s.cacheDir = s.T().TempDir()
+}
+
+func (s *RemoteBundleTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *RemoteBundleTestSuite) readCached(name string) string {
+	content, err := os.ReadFile(filepath.Join(s.cacheDir, name))
+	require.NoError(s.T(), err)
+	return string(content)
+}
+
+// TestServeBundle tests that the prompts of tar and zip bundles load, and that refreshes apply changes
+// or keep the last good prompts when the bundle cannot be fetched
+func (s *RemoteBundleTestSuite) TestServeBundle() {
+	files := []bundleFile{
+		{name: "prompts/greeting.tmpl", content: "{{/* Greeting */}}\nHello {{.name}}!{{template \"_footer\" .}}"},
+		{name: "prompts/_footer.tmpl", content: " Bye"},
+		{name: "prompts/.DS_Store", content: "junk"},
+	}
+	for _, tc := range []struct {
+		path   string
+		bundle []byte
+	}{
+		{path: "/prompts.tar.gz", bundle: makeTarBundle(s.T(), true, files...)},
+		{path: "/prompts.tar", bundle: makeTarBundle(s.T(), false, files...)},
+		{path: "/prompts.zip", bundle: makeZipBundle(s.T(), files...)},
+	} {
+		s.Run(tc.path, func() {
+			cacheDir := s.T().TempDir()
+			s.bundles.set(tc.bundle, false)
+			remote, err := NewRemotePrompts(s.server.URL+tc.path, "", cacheDir, slog.New(slog.DiscardHandler))
+			require.NoError(s.T(), err)
+			changed, err := remote.Sync(context.Background())
+			require.NoError(s.T(), err)
+			assert.True(s.T(), changed)
+
+			entries, err := os.ReadDir(cacheDir)
+			require.NoError(s.T(), err)
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			assert.Equal(s.T(), []string{"_footer.tmpl", "greeting.tmpl"}, names, "links and hidden files are skipped")
+
+			promptsServer, err := NewPromptsServer(cacheDir, WithWatchMode(WatchModeOff, 0))
+			require.NoError(s.T(), err)
+			defer func() { s.Require().NoError(promptsServer.Close()) }()
+			assert.Equal(s.T(), []string{"greeting"}, promptsServer.LoadSummary().Prompts)
+		})
+	}
+
+	remote, err := NewRemotePrompts(s.server.URL+"/bundles/prompts.tgz", "", s.cacheDir, slog.New(slog.DiscardHandler))
+	require.NoError(s.T(), err)
+	s.bundles.set(makeTarBundle(s.T(), true, files...), false)
+	_, err = remote.Sync(context.Background())
+	require.NoError(s.T(), err)
+
+	changed, err := remote.Sync(context.Background())
+	require.NoError(s.T(), err)
+	assert.False(s.T(), changed, "an unchanged bundle must not rewrite files")
+
+	s.bundles.set(nil, true)
+	_, err = remote.Sync(context.Background())
+	require.ErrorContains(s.T(), err, "fetch bundle: unexpected status 503 Service Unavailable")
+	assert.Equal(s.T(), " Bye", s.readCached("_footer.tmpl"), "a failed refresh must keep the last good prompts")
+
+	s.bundles.set(makeTarBundle(s.T(), true, bundleFile{name: "greeting.tmpl", content: "Hi"}), false)
+	changed, err = remote.Sync(context.Background())
+	require.NoError(s.T(), err)
+	assert.True(s.T(), changed)
+	assert.Equal(s.T(), "Hi", s.readCached("greeting.tmpl"))
+	assert.NoFileExists(s.T(), filepath.Join(s.cacheDir, "_footer.tmpl"), "files removed from the bundle must be removed")
+}
+
+// TestInvalidBundles tests that bundles with files outside a single directory are rejected
+func (s *RemoteBundleTestSuite) TestInvalidBundles() {
+	for _, tc := range []struct {
+		files    []bundleFile
+		expected string
+	}{
+		{files: []bundleFile{{name: "../evil.tmpl"}}, expected: `invalid bundle path "../evil.tmpl": must be inside the bundle`},
+		{files: []bundleFile{{name: "/etc/evil.tmpl"}}, expected: `invalid bundle path "/etc/evil.tmpl": must be inside the bundle`},
+		{
+			files:    []bundleFile{{name: "prompts/nested/a.tmpl"}},
+			expected: `invalid bundle path "prompts/nested/a.tmpl": files must be in a single top-level directory`,
+		},
+		{
+			files:    []bundleFile{{name: "a/a.tmpl"}, {name: "b/b.tmpl"}},
+			expected: `invalid bundle path "b/b.tmpl": files must be in a single top-level directory`,
+		},
+		{files: []bundleFile{{name: "a.tmpl"}, {name: "./a.tmpl"}}, expected: `duplicate bundle path "./a.tmpl"`},
+	} {
+		_, err := extractBundle(makeZipBundle(s.T(), tc.files...), bundleFormatZip)
+		assert.EqualError(s.T(), err, tc.expected)
+	}
+
+	_, err := extractBundle([]byte("not a zip"), bundleFormatZip)
+	assert.ErrorContains(s.T(), err, "read zip")
+
+	for rawURL, expected := range map[string]bundleFormat{
+		"https://example.com/prompts.tar.gz":        bundleFormatTar,
+		"https://example.com/prompts.TGZ":           bundleFormatTar,
+		"https://example.com/prompts.zip?rev=3":     bundleFormatZip,
+		"https://example.com/prompts/manifest.json": bundleFormatNone,
+	} {
+		parsed, err := url.Parse(rawURL)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), expected, bundleFormatOf(parsed), rawURL)
+	}
+}
+
+// TestOversizedBundles tests that bundles whose files are each small enough but together too large are rejected
+// while they are read, also when the files would be skipped
+func (s *RemoteBundleTestSuite) TestOversizedBundles() {
+	var files, skipped []bundleFile
+	for i := range 6 {
+		content := strings.Repeat("x", maxRemoteFileSize-1)
+		files = append(files, bundleFile{name: fmt.Sprintf("prompts/p%d.tmpl", i), content: content})
+		skipped = append(skipped, bundleFile{name: fmt.Sprintf("__MACOSX/prompts/._p%d.tmpl", i), content: content})
+	}
+
+	_, err := readZipBundle(makeZipBundle(s.T(), files...))
+	assert.EqualError(s.T(), err, fmt.Sprintf("files exceed %d bytes", maxRemoteBundleSize))
+	_, err = readTarBundle(makeTarBundle(s.T(), true, files...))
+	assert.EqualError(s.T(), err, fmt.Sprintf("bundle exceeds %d bytes decompressed", maxRemoteBundleSize))
+
+	entries, err := readZipBundle(makeZipBundle(s.T(), append(skipped, bundleFile{name: "prompts/a.tmpl", content: "A"})...))
+	require.NoError(s.T(), err)
+	require.Len(s.T(), entries, 1, "skipped files must not be read")
+	assert.Equal(s.T(), "prompts/a.tmpl", entries[0].name)
+}
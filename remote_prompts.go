@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// promptsTokenEnvVar is the environment variable holding the bearer token for fetching remote prompts.
+const promptsTokenEnvVar = "MCP_PROMPTS_TOKEN"
+
+// maxRemoteFileSize bounds the size of the manifest and of every file fetched from it.
+const maxRemoteFileSize = 10 << 20
+
+// RemoteManifest lists the files of a prompts directory published over HTTP.
+// File paths are resolved relative to the manifest URL.
+type RemoteManifest struct {
+	Files []RemoteManifestFile `json:"files"`
+}
+
+// RemoteManifestFile is a single file of a remote prompts directory.
+type RemoteManifestFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// RemotePrompts mirrors a read-only prompts directory published over HTTP into a local cache directory,
+// either as a manifest of files or as a tar or zip bundle.
+// The server serves the cache directory like any local one, so the file watcher reloads prompts
+// whenever a sync rewrites cached files.
+type RemotePrompts struct {
+	promptsURL   *url.URL
+	bundleFormat bundleFormat // format of the bundle at promptsURL, bundleFormatNone for a manifest
+	token        string
+	cacheDir     string
+	client       *http.Client
+	logger       *slog.Logger
+	hashes       map[string]string
+}
+
+// NewRemotePrompts creates a mirror of the prompts at promptsURL: a tar (optionally gzip-compressed) or zip bundle
+// if the URL path ends with .tar, .tar.gz, .tgz, or .zip, and a manifest listing the files otherwise.
+// If token is not empty, it is sent as a bearer token with every request.
+func NewRemotePrompts(promptsURL string, token string, cacheDir string, logger *slog.Logger) (*RemotePrompts, error) {
+	parsedURL, err := url.Parse(promptsURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse prompts URL: %w", err)
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return nil, fmt.Errorf("prompts URL %q must use http or https", promptsURL)
+	}
+	return &RemotePrompts{
+		promptsURL:   parsedURL,
+		bundleFormat: bundleFormatOf(parsedURL),
+		token:        token,
+		cacheDir:     cacheDir,
+		client:       &http.Client{Timeout: 30 * time.Second},
+		logger:       logger,
+		hashes:       make(map[string]string),
+	}, nil
+}
+
+// remoteUpdate holds the remote prompts fetched by a sync.
+type remoteUpdate struct {
+	listed  map[string]struct{} // names of all remote files
+	content map[string][]byte   // contents of the new and changed files
+	hashes  map[string]string   // SHA-256 hashes of the new and changed files
+}
+
+func newRemoteUpdate() *remoteUpdate {
+	return &remoteUpdate{listed: make(map[string]struct{}), content: make(map[string][]byte), hashes: make(map[string]string)}
+}
+
+// Sync fetches the manifest or the bundle and updates the cache directory to match it.
+// Files are only written after all changed files were fetched and verified,
+// so a failed sync leaves the cached prompts untouched. It reports whether any file changed.
+func (rp *RemotePrompts) Sync(ctx context.Context) (changed bool, err error) {
+	var update *remoteUpdate
+	if rp.bundleFormat != bundleFormatNone {
+		update, err = rp.fetchBundle(ctx)
+	} else {
+		update, err = rp.fetchManifest(ctx)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for path, content := range update.content {
+		if err = writeFileAtomic(filepath.Join(rp.cacheDir, path), content); err != nil {
+			return false, fmt.Errorf("cache %s: %w", path, err)
+		}
+		rp.hashes[path] = update.hashes[path]
+	}
+	for path := range rp.hashes {
+		if _, ok := update.listed[path]; ok {
+			continue
+		}
+		if err = os.Remove(filepath.Join(rp.cacheDir, path)); err != nil && !os.IsNotExist(err) {
+			return false, fmt.Errorf("remove %s: %w", path, err)
+		}
+		delete(rp.hashes, path)
+		changed = true
+	}
+	return changed || len(update.content) > 0, nil
+}
+
+// fetchManifest fetches the manifest and the files whose hashes differ from the cached ones.
+func (rp *RemotePrompts) fetchManifest(ctx context.Context) (*remoteUpdate, error) {
+	manifestContent, err := rp.fetch(ctx, rp.promptsURL, maxRemoteFileSize)
+	if err != nil {
+		return nil, fmt.Errorf("fetch manifest: %w", err)
+	}
+	var manifest RemoteManifest
+	if err = json.Unmarshal(manifestContent, &manifest); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+
+	update := newRemoteUpdate()
+	for _, file := range manifest.Files {
+		if file.Path == "" || file.Path != filepath.Base(file.Path) || strings.HasPrefix(file.Path, ".") {
+			return nil, fmt.Errorf("invalid manifest path %q: must be a plain file name", file.Path)
+		}
+		if _, exists := update.listed[file.Path]; exists {
+			return nil, fmt.Errorf("duplicate manifest path %q", file.Path)
+		}
+		update.listed[file.Path] = struct{}{}
+		hash := strings.ToLower(file.SHA256)
+		if rp.hashes[file.Path] == hash {
+			continue
+		}
+		var content []byte
+		if content, err = rp.fetch(ctx, rp.promptsURL.ResolveReference(&url.URL{Path: file.Path}), maxRemoteFileSize); err != nil {
+			return nil, fmt.Errorf("fetch %s: %w", file.Path, err)
+		}
+		if sum := sha256.Sum256(content); hex.EncodeToString(sum[:]) != hash {
+			return nil, fmt.Errorf("fetch %s: content does not match the manifest hash", file.Path)
+		}
+		update.content[file.Path] = content
+		update.hashes[file.Path] = hash
+	}
+	return update, nil
+}
+
+// fetchBundle fetches the bundle and extracts it. Unlike a manifest, a bundle is always fetched whole,
+// and the files whose hashes differ from the cached ones are picked from it.
+func (rp *RemotePrompts) fetchBundle(ctx context.Context) (*remoteUpdate, error) {
+	bundle, err := rp.fetch(ctx, rp.promptsURL, maxRemoteBundleSize)
+	if err != nil {
+		return nil, fmt.Errorf("fetch bundle: %w", err)
+	}
+	files, err := extractBundle(bundle, rp.bundleFormat)
+	if err != nil {
+		return nil, fmt.Errorf("extract bundle: %w", err)
+	}
+
+	update := newRemoteUpdate()
+	for name, content := range files {
+		update.listed[name] = struct{}{}
+		sum := sha256.Sum256(content)
+		if hash := hex.EncodeToString(sum[:]); rp.hashes[name] != hash {
+			update.content[name] = content
+			update.hashes[name] = hash
+		}
+	}
+	return update, nil
+}
+
+// Poll syncs the cache directory at the given interval until the context is cancelled.
+// Failed refreshes are logged and the previously cached prompts keep being served.
+func (rp *RemotePrompts) Poll(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := rp.Sync(ctx)
+			if err != nil {
+				rp.logger.Warn("Failed to refresh remote prompts, serving cached prompts",
+					"url", rp.promptsURL.String(), "error", err)
+				continue
+			}
+			if changed {
+				rp.logger.Info("Remote prompts changed", "url", rp.promptsURL.String())
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (rp *RemotePrompts) fetch(ctx context.Context, fileURL *url.URL, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	if rp.token != "" {
+		req.Header.Set("Authorization", "Bearer "+rp.token)
+	}
+	resp, err := rp.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if int64(len(content)) > limit {
+		return nil, fmt.Errorf("response exceeds %d bytes", limit)
+	}
+	return content, nil
+}
+
+// writeFileAtomic replaces the file with the given content via a temporary file in the same directory,
+// so the file watcher never observes a partially written template.
+func writeFileAtomic(path string, content []byte) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmpFile.Write(content); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFile.Name())
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return err
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
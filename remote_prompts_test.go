@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// remotePromptsFixture serves a manifest and its files over HTTP and can be changed or broken during a test.
+type remotePromptsFixture struct {
+	mu      sync.Mutex
+	files   map[string]string
+	failing bool
+	token   string
+}
+
+func (f *remotePromptsFixture) setFile(name, content string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[name] = content
+}
+
+func (f *remotePromptsFixture) setFailing(failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing = failing
+}
+
+func (f *remotePromptsFixture) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if f.token != "" && r.Header.Get("Authorization") != "Bearer "+f.token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path == "/prompts/manifest.json" {
+		var manifest RemoteManifest
+		for name, content := range f.files {
+			sum := sha256.Sum256([]byte(content))
+			manifest.Files = append(manifest.Files, RemoteManifestFile{Path: name, SHA256: hex.EncodeToString(sum[:])})
+		}
+		_ = json.NewEncoder(w).Encode(manifest)
+		return
+	}
+	content, ok := f.files[filepath.Base(r.URL.Path)]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	_, _ = w.Write([]byte(content))
+}
+
+type RemotePromptsTestSuite struct {
+	suite.Suite
+	fixture  *remotePromptsFixture
+	server   *httptest.Server
+	cacheDir string
+	logger   *slog.Logger
+}
+
+func TestRemotePromptsTestSuite(t *testing.T) {
+	suite.Run(t, new(RemotePromptsTestSuite))
+}
+
+func (s *RemotePromptsTestSuite) SetupTest() {
+	s.fixture = &remotePromptsFixture{
+		files: map[string]string{
+			"greeting.tmpl": "{{/* Greeting */}}\nHello {{.name}}!",
+			"_footer.tmpl":  "Bye",
+		},
+		token: "secret",
+	}
+	s.server = httptest.NewServer(s.fixture)
+	s.cacheDir = s.T().TempDir()
+	s.logger = slog.New(slog.DiscardHandler)
+}
+
+func (s *RemotePromptsTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *RemotePromptsTestSuite) newRemotePrompts(token string) *RemotePrompts {
+	remote, err := NewRemotePrompts(s.server.URL+"/prompts/manifest.json", token, s.cacheDir, s.logger)
+	require.NoError(s.T(), err)
+	return remote
+}
+
+func (s *RemotePromptsTestSuite) readCached(name string) string {
+	content, err := os.ReadFile(filepath.Join(s.cacheDir, name))
+	require.NoError(s.T(), err)
+	return string(content)
+}
+
+// TestInitialLoad tests that the first sync mirrors every manifest file into the cache directory
+func (s *RemotePromptsTestSuite) TestInitialLoad() {
+	remote := s.newRemotePrompts("secret")
+	changed, err := remote.Sync(context.Background())
+	require.NoError(s.T(), err)
+	assert.True(s.T(), changed)
+	assert.Equal(s.T(), "{{/* Greeting */}}\nHello {{.name}}!", s.readCached("greeting.tmpl"))
+	assert.Equal(s.T(), "Bye", s.readCached("_footer.tmpl"))
+
+	changed, err = remote.Sync(context.Background())
+	require.NoError(s.T(), err)
+	assert.False(s.T(), changed, "unchanged manifest must not rewrite files")
+
+	_, err = s.newRemotePrompts("wrong").Sync(context.Background())
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "401 Unauthorized")
+}
+
+// TestChangedFileOnPoll tests that polling picks up changed and removed files and the server reloads them
+func (s *RemotePromptsTestSuite) TestChangedFileOnPoll() {
+	remote := s.newRemotePrompts("secret")
+	_, err := remote.Sync(context.Background())
+	require.NoError(s.T(), err)
+
+	promptsServer, err := NewPromptsServer(s.cacheDir, WithLogger(s.logger))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		promptsServer.startWatcher(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		remote.Poll(ctx, 10*time.Millisecond)
+	}()
+
+	s.fixture.setFile("greeting.tmpl", "{{/* Greeting */}}\nHi {{.name}} from {{.team}}!")
+	s.fixture.mu.Lock()
+	delete(s.fixture.files, "_footer.tmpl")
+	s.fixture.mu.Unlock()
+
+	require.Eventually(s.T(), func() bool {
+		_, statErr := os.Stat(filepath.Join(s.cacheDir, "_footer.tmpl"))
+		return os.IsNotExist(statErr)
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(s.T(), "{{/* Greeting */}}\nHi {{.name}} from {{.team}}!", s.readCached("greeting.tmpl"))
+
+	getPrompt := []byte(`{"jsonrpc":"2.0","id":1,"method":"prompts/get",` +
+		`"params":{"name":"greeting","arguments":{"name":"Ann","team":"Docs"}}}`)
+	require.Eventually(s.T(), func() bool {
+		response, marshalErr := json.Marshal(promptsServer.mcpServer.HandleMessage(ctx, getPrompt))
+		return marshalErr == nil && strings.Contains(string(response), "Hi Ann from Docs!")
+	}, 2*time.Second, 10*time.Millisecond, "server should reload the changed prompt")
+}
+
+// TestFailedRefresh tests that a failed refresh keeps the cached prompts
+func (s *RemotePromptsTestSuite) TestFailedRefresh() {
+	remote := s.newRemotePrompts("secret")
+	_, err := remote.Sync(context.Background())
+	require.NoError(s.T(), err)
+
+	s.fixture.setFailing(true)
+	_, err = remote.Sync(context.Background())
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "fetch manifest")
+	assert.Equal(s.T(), "{{/* Greeting */}}\nHello {{.name}}!", s.readCached("greeting.tmpl"))
+
+	s.fixture.setFailing(false)
+	s.fixture.setFile("greeting.tmpl", "{{/* Greeting */}}\nHi!")
+	changed, err := remote.Sync(context.Background())
+	require.NoError(s.T(), err)
+	assert.True(s.T(), changed)
+	assert.Equal(s.T(), "{{/* Greeting */}}\nHi!", s.readCached("greeting.tmpl"))
+}
+
+// TestInvalidManifest tests that unsafe paths and hash mismatches are rejected without touching the cache
+func (s *RemotePromptsTestSuite) TestInvalidManifest() {
+	tests := []struct {
+		name        string
+		manifest    RemoteManifest
+		expectedErr string
+	}{
+		{
+			name:        "path traversal",
+			manifest:    RemoteManifest{Files: []RemoteManifestFile{{Path: "../evil.tmpl", SHA256: "00"}}},
+			expectedErr: `invalid manifest path "../evil.tmpl"`,
+		},
+		{
+			name:        "hidden file",
+			manifest:    RemoteManifest{Files: []RemoteManifestFile{{Path: ".env", SHA256: "00"}}},
+			expectedErr: `invalid manifest path ".env"`,
+		},
+		{
+			name:        "hash mismatch",
+			manifest:    RemoteManifest{Files: []RemoteManifestFile{{Path: "greeting.tmpl", SHA256: "00"}}},
+			expectedErr: "fetch greeting.tmpl: content does not match the manifest hash",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/manifest.json" {
+					_ = json.NewEncoder(w).Encode(tt.manifest)
+					return
+				}
+				_, _ = w.Write([]byte("content"))
+			}))
+			defer server.Close()
+
+			remote, err := NewRemotePrompts(server.URL+"/manifest.json", "", s.cacheDir, s.logger)
+			require.NoError(s.T(), err)
+			_, err = remote.Sync(context.Background())
+			require.Error(s.T(), err)
+			assert.Contains(s.T(), err.Error(), tt.expectedErr)
+
+			entries, err := os.ReadDir(s.cacheDir)
+			require.NoError(s.T(), err)
+			assert.Empty(s.T(), entries)
+		})
+	}
+
+	_, err := NewRemotePrompts("file:///etc/prompts/manifest.json", "", s.cacheDir, s.logger)
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "must use http or https")
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// defaultRenderCacheMaxSize is the default size cap of the render cache in bytes.
+const defaultRenderCacheMaxSize = 64 << 20
+
+// Files of the render cache directory.
+const (
+	renderCacheEntriesDir = "entries"
+	renderCacheStatsFile  = "stats.json"
+)
+
+// impureFuncNames make a template uncacheable, like the built-in fields: their values differ between requests
+// with the same arguments.
+var impureFuncNames = []string{summarizeFuncName, lastPromptFuncName, schemaFuncName, modelIsFuncName, agoFuncName}
+
+// RenderCacheStats describes the content and the use of the render cache.
+type RenderCacheStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"-"`
+	Size    int64 `json:"-"` // total size of the entries in bytes
+}
+
+// RenderCache is a render cache persisted in a directory, so rendered prompts survive server restarts.
+// Entries are files named after their key; since keys are content hashes, entries of changed templates
+// are never read again and are evicted, least recently used first, once the cache exceeds its size cap.
+// It is safe for concurrent use.
+type RenderCache struct {
+	dir     string
+	maxSize int64
+
+	mu     sync.Mutex
+	hits   int64 // hits and misses not yet added to the stats file
+	misses int64
+}
+
+// OpenRenderCache opens the render cache in the directory, creating it if needed.
+func OpenRenderCache(dir string, maxSize int64) (*RenderCache, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("render cache size must be positive, got %d", maxSize)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, renderCacheEntriesDir), 0755); err != nil {
+		return nil, fmt.Errorf("create render cache directory: %w", err)
+	}
+	return &RenderCache{dir: dir, maxSize: maxSize}, nil
+}
+
+// Get returns the text cached under the key and marks the entry as recently used.
+func (rc *RenderCache) Get(key string) (string, bool) {
+	path := rc.entryPath(key)
+	content, err := os.ReadFile(path)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if err != nil {
+		rc.misses++
+		return "", false
+	}
+	rc.hits++
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return string(content), true
+}
+
+// Put stores the text under the key and evicts the least recently used entries above the size cap.
+// A text larger than the whole cache is not stored.
+func (rc *RenderCache) Put(key string, text string) error {
+	if int64(len(text)) > rc.maxSize {
+		return nil
+	}
+	if err := writeFileAtomic(rc.entryPath(key), []byte(text)); err != nil {
+		return fmt.Errorf("write render cache entry: %w", err)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if err := rc.evict(); err != nil {
+		return err
+	}
+	return rc.flushStats()
+}
+
+// Stats returns the number and size of the entries and the hits and misses recorded since the cache was cleared.
+func (rc *RenderCache) Stats() (RenderCacheStats, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	stats, err := rc.loadStats()
+	if err != nil {
+		return RenderCacheStats{}, err
+	}
+	stats.Hits += rc.hits
+	stats.Misses += rc.misses
+	entries, err := rc.listEntries()
+	if err != nil {
+		return RenderCacheStats{}, err
+	}
+	stats.Entries = len(entries)
+	for _, entry := range entries {
+		stats.Size += entry.size
+	}
+	return stats, nil
+}
+
+// Clear removes all entries and resets the stats.
+func (rc *RenderCache) Clear() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	entries, err := rc.listEntries()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err = os.Remove(entry.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove render cache entry: %w", err)
+		}
+	}
+	if err = os.Remove(filepath.Join(rc.dir, renderCacheStatsFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove render cache stats: %w", err)
+	}
+	rc.hits, rc.misses = 0, 0
+	return nil
+}
+
+// Close writes the hits and misses recorded since the last write to the stats file.
+func (rc *RenderCache) Close() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.flushStats()
+}
+
+func (rc *RenderCache) entryPath(key string) string {
+	return filepath.Join(rc.dir, renderCacheEntriesDir, key)
+}
+
+type renderCacheEntry struct {
+	path   string
+	size   int64
+	usedAt time.Time
+	name   string
+}
+
+// listEntries returns the entries of the cache; temporary files of entries being written are skipped.
+func (rc *RenderCache) listEntries() ([]renderCacheEntry, error) {
+	files, err := os.ReadDir(filepath.Join(rc.dir, renderCacheEntriesDir))
+	if err != nil {
+		return nil, fmt.Errorf("read render cache directory: %w", err)
+	}
+	entries := make([]renderCacheEntry, 0, len(files))
+	for _, file := range files {
+		if !file.Type().IsRegular() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue // removed by another process meanwhile
+		}
+		entries = append(entries, renderCacheEntry{
+			path: rc.entryPath(file.Name()), size: info.Size(), usedAt: info.ModTime(), name: file.Name(),
+		})
+	}
+	return entries, nil
+}
+
+// evict removes the least recently used entries until the cache fits its size cap.
+func (rc *RenderCache) evict() error {
+	entries, err := rc.listEntries()
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, entry := range entries {
+		size += entry.size
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].usedAt.Equal(entries[j].usedAt) {
+			return entries[i].usedAt.Before(entries[j].usedAt)
+		}
+		return entries[i].name < entries[j].name
+	})
+	for _, entry := range entries {
+		if size <= rc.maxSize {
+			break
+		}
+		if err = os.Remove(entry.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("evict render cache entry: %w", err)
+		}
+		size -= entry.size
+	}
+	return nil
+}
+
+func (rc *RenderCache) loadStats() (RenderCacheStats, error) {
+	var stats RenderCacheStats
+	content, err := os.ReadFile(filepath.Join(rc.dir, renderCacheStatsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, fmt.Errorf("read render cache stats: %w", err)
+	}
+	if err = json.Unmarshal(content, &stats); err != nil {
+		return stats, fmt.Errorf("parse render cache stats: %w", err)
+	}
+	return stats, nil
+}
+
+// flushStats adds the pending hits and misses to the stats file, which may be shared by several servers.
+func (rc *RenderCache) flushStats() error {
+	if rc.hits == 0 && rc.misses == 0 {
+		return nil
+	}
+	stats, err := rc.loadStats()
+	if err != nil {
+		return err
+	}
+	stats.Hits += rc.hits
+	stats.Misses += rc.misses
+	content, err := json.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("marshal render cache stats: %w", err)
+	}
+	if err = writeFileAtomic(filepath.Join(rc.dir, renderCacheStatsFile), content); err != nil {
+		return fmt.Errorf("write render cache stats: %w", err)
+	}
+	rc.hits, rc.misses = 0, 0
+	return nil
+}
+
+// renderCacheKeyInput is everything a rendered prompt depends on, hashed into its cache key.
+type renderCacheKeyInput struct {
+	ServerVersion string            `json:"server_version"`
+	TemplateHash  string            `json:"template_hash"` // template file, including its frontmatter
+	Partials      map[string]string `json:"partials"`      // parse trees of the partials, by name
+	FuncsConfig   string            `json:"funcs_config"`  // hash of the function aliases config
+	FeatureFlags  map[string]bool   `json:"feature_flags,omitempty"`
+	EnvArgs       map[string]string `json:"env_args"`
+	ContextValues map[string]string `json:"context_values"`
+	Vars          map[string]string `json:"vars,omitempty"`
+	JSONArgs      bool              `json:"json_args"`
+	LineEndings   LineEndings       `json:"line_endings"`
+	MaxOutputSize int               `json:"max_output_size"`
+	Args          map[string]string `json:"args,omitempty"`
+}
+
+// renderCacheKey returns the cache key of the prompt rendered with the arguments; see renderCacheKeyInput.
+func renderCacheKey(input renderCacheKeyInput, args map[string]string) string {
+	input.Args = args
+	content, _ := json.Marshal(input) // maps of strings always marshal, with keys in sorted order
+	return hashContent(content)
+}
+
+// templateIsCacheable reports whether the template and the partials it references render the same text
+// for the same arguments, i.e. they use none of the built-in fields and impure helpers.
+func templateIsCacheable(tmpl *template.Template, templateName string, partials []string, builtinFields []string) bool {
+	for _, name := range append([]string{templateName}, partials...) {
+		t := lookupPartial(tmpl, name)
+		if t == nil || !nodeIsPure(t.Root, builtinFields, impureFuncNames) {
+			return false
+		}
+	}
+	return true
+}
+
+// partialTrees returns the parse trees of the partials as text, by partial name.
+func partialTrees(tmpl *template.Template, partials []string) map[string]string {
+	trees := make(map[string]string, len(partials))
+	for _, name := range partials {
+		if t := lookupPartial(tmpl, name); t != nil {
+			trees[name] = t.Root.String()
+		}
+	}
+	return trees
+}
+
+// lookupPartial returns the template referenced by name, with or without the template extension.
+func lookupPartial(tmpl *template.Template, name string) *template.Template {
+	t := tmpl.Lookup(name)
+	if t == nil && !strings.HasSuffix(name, templateExt) {
+		t = tmpl.Lookup(name + templateExt)
+	}
+	if t == nil || t.Tree == nil {
+		return nil
+	}
+	return t
+}
+
+// nodeIsPure reports whether the node uses none of the fields and functions.
+func nodeIsPure(node parse.Node, impureFields []string, impureFuncs []string) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return true
+		}
+		for _, child := range n.Nodes {
+			if !nodeIsPure(child, impureFields, impureFuncs) {
+				return false
+			}
+		}
+	case *parse.ActionNode:
+		return nodeIsPure(n.Pipe, impureFields, impureFuncs)
+	case *parse.IfNode:
+		return nodeIsPure(n.Pipe, impureFields, impureFuncs) && nodeIsPure(n.List, impureFields, impureFuncs) && nodeIsPure(n.ElseList, impureFields, impureFuncs)
+	case *parse.RangeNode:
+		return nodeIsPure(n.Pipe, impureFields, impureFuncs) && nodeIsPure(n.List, impureFields, impureFuncs) && nodeIsPure(n.ElseList, impureFields, impureFuncs)
+	case *parse.WithNode:
+		return nodeIsPure(n.Pipe, impureFields, impureFuncs) && nodeIsPure(n.List, impureFields, impureFuncs) && nodeIsPure(n.ElseList, impureFields, impureFuncs)
+	case *parse.TemplateNode:
+		return nodeIsPure(n.Pipe, impureFields, impureFuncs)
+	case *parse.PipeNode:
+		if n == nil {
+			return true
+		}
+		for _, cmd := range n.Cmds {
+			if !nodeIsPure(cmd, impureFields, impureFuncs) {
+				return false
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if !nodeIsPure(arg, impureFields, impureFuncs) {
+				return false
+			}
+		}
+	case *parse.ChainNode:
+		return nodeIsPure(n.Node, impureFields, impureFuncs)
+	case *parse.FieldNode:
+		return len(n.Ident) == 0 || !containsFold(impureFields, n.Ident[0])
+	case *parse.VariableNode:
+		// $.date refers to a field of the data, unlike other variables
+		return len(n.Ident) < 2 || n.Ident[0] != "$" || !containsFold(impureFields, n.Ident[1])
+	case *parse.IdentifierNode:
+		for _, name := range impureFuncs {
+			if n.Ident == name {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func containsFold(names []string, name string) bool {
+	for _, candidate := range names {
+		if strings.EqualFold(candidate, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RenderCacheTestSuite struct {
+	suite.Suite
+	promptsDir string
+	cacheDir   string
+}
+
+func TestRenderCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(RenderCacheTestSuite))
+}
+
+func (s *RenderCacheTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.cacheDir = s.T().TempDir()
+	s.writeFile("_body.tmpl", `{{define "_body.tmpl"}}Summary of {{.topic}}{{end}}`)
+	s.writeFile("report.tmpl", "{{/* Report */}}\n{{template \"_body.tmpl\" .}}")
+}
+
+func (s *RenderCacheTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+// getPrompt starts a server over the cache, renders the prompt once, and stops the server
+func (s *RenderCacheTestSuite) getPrompt(renderCache *RenderCache, name string, args map[string]string) string {
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithRenderCache(renderCache), WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	request, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0", "id": 1, "method": "prompts/get", "params": map[string]any{"name": name, "arguments": args},
+	})
+	require.NoError(s.T(), err)
+	response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok, "prompt %q must render", name)
+	return response.Result.(mcp.GetPromptResult).Messages[0].Content.(mcp.TextContent).Text
+}
+
+func (s *RenderCacheTestSuite) entryFiles() []string {
+	files, err := filepath.Glob(filepath.Join(s.cacheDir, renderCacheEntriesDir, "*"))
+	require.NoError(s.T(), err)
+	return files
+}
+
+// TestReuseAcrossRestarts tests that a server reuses the prompts rendered by an earlier server over the same directory
+func (s *RenderCacheTestSuite) TestReuseAcrossRestarts() {
+	args := map[string]string{"topic": "caching"}
+	firstCache, err := OpenRenderCache(s.cacheDir, defaultRenderCacheMaxSize)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Summary of caching", s.getPrompt(firstCache, "report", args))
+	require.NoError(s.T(), firstCache.Close())
+
+	entries := s.entryFiles()
+	require.Len(s.T(), entries, 1)
+	// A text the template cannot produce proves that the next server reads the entry instead of rendering
+	require.NoError(s.T(), os.WriteFile(entries[0], []byte("Cached summary"), 0644))
+
+	secondCache, err := OpenRenderCache(s.cacheDir, defaultRenderCacheMaxSize)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Cached summary", s.getPrompt(secondCache, "report", args))
+	assert.Equal(s.T(), "Summary of tests", s.getPrompt(secondCache, "report", map[string]string{"topic": "tests"}))
+
+	s.writeFile("_body.tmpl", `{{define "_body.tmpl"}}Short summary of {{.topic}}{{end}}`)
+	assert.Equal(s.T(), "Short summary of caching", s.getPrompt(secondCache, "report", args),
+		"a changed partial must change the key")
+	require.NoError(s.T(), secondCache.Close())
+
+	stats, err := secondCache.Stats()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), RenderCacheStats{Hits: 1, Misses: 3, Entries: 3, Size: stats.Size}, stats)
+}
+
+// TestEviction tests that the least recently used entries are evicted above the size cap
+func (s *RenderCacheTestSuite) TestEviction() {
+	renderCache, err := OpenRenderCache(s.cacheDir, 10)
+	require.NoError(s.T(), err)
+
+	require.NoError(s.T(), renderCache.Put("a", "aaaa"))
+	require.NoError(s.T(), renderCache.Put("b", "bbbb"))
+	past := time.Now().Add(-time.Hour)
+	require.NoError(s.T(), os.Chtimes(renderCache.entryPath("a"), past, past))
+	require.NoError(s.T(), os.Chtimes(renderCache.entryPath("b"), past.Add(time.Minute), past.Add(time.Minute)))
+	_, ok := renderCache.Get("a")
+	require.True(s.T(), ok, "reading an entry makes it recently used")
+
+	require.NoError(s.T(), renderCache.Put("c", "cccc"))
+	_, ok = renderCache.Get("b")
+	assert.False(s.T(), ok, "the least recently used entry must be evicted")
+	for _, key := range []string{"a", "c"} {
+		_, ok = renderCache.Get(key)
+		assert.True(s.T(), ok, key)
+	}
+
+	require.NoError(s.T(), renderCache.Put("d", "larger than the cache"))
+	_, ok = renderCache.Get("d")
+	assert.False(s.T(), ok, "a text larger than the cache must not be stored")
+	stats, err := renderCache.Stats()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), RenderCacheStats{Hits: 3, Misses: 2, Entries: 2, Size: 8}, stats)
+
+	require.NoError(s.T(), renderCache.Clear())
+	stats, err = renderCache.Stats()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), RenderCacheStats{}, stats)
+}
+
+// TestImpureTemplatesBypass tests that prompts using impure fields or helpers, directly or in partials, are never cached
+func (s *RenderCacheTestSuite) TestImpureTemplatesBypass() {
+	s.writeFile("_dated.tmpl", `{{define "_dated.tmpl"}}As of {{$.date}}{{end}}`)
+	s.writeFile("dated.tmpl", "{{/* Dated */}}\nToday is {{.date}}")
+	s.writeFile("dated_partial.tmpl", "{{/* Dated partial */}}\n{{template \"_dated.tmpl\" .}}")
+	s.writeFile("summary.tmpl", "{{/* Summary */}}\n{{summarize .text 10}}")
+	s.writeFile("followup.tmpl", "{{/* Follow-up */}}\n{{lastPrompt \"report\"}}")
+	s.writeFile("age.tmpl", "{{/* Age */}}\nCreated {{ago .text}}")
+
+	renderCache, err := OpenRenderCache(s.cacheDir, defaultRenderCacheMaxSize)
+	require.NoError(s.T(), err)
+	for _, name := range []string{"dated", "dated_partial", "summary", "followup", "age"} {
+		s.getPrompt(renderCache, name, map[string]string{"text": "some text"})
+		assert.Empty(s.T(), s.entryFiles(), name)
+	}
+	s.getPrompt(renderCache, "report", map[string]string{"topic": "caching"})
+	assert.Len(s.T(), s.entryFiles(), 1)
+}
+
+// TestCacheCommands tests the cache stats and cache clear commands
+func (s *RenderCacheTestSuite) TestCacheCommands() {
+	renderCache, err := OpenRenderCache(s.cacheDir, defaultRenderCacheMaxSize)
+	require.NoError(s.T(), err)
+	s.getPrompt(renderCache, "report", map[string]string{"topic": "caching"})
+	s.getPrompt(renderCache, "report", map[string]string{"topic": "caching"})
+	require.NoError(s.T(), renderCache.Close())
+
+	run := func(subcommand string) string {
+		var buf bytes.Buffer
+		app := newApp()
+		app.Writer = &buf
+		require.NoError(s.T(), app.Run(context.Background(),
+			[]string{app.Name, "--color", "never", "cache", "--render-cache-dir", s.cacheDir, subcommand}))
+		return buf.String()
+	}
+	assert.Equal(s.T(), "Entries:  1 (18 bytes)\nHits:     1\nMisses:   1\nHit rate: 50.0%\n", run("stats"))
+	assert.Equal(s.T(), "✓ Render cache cleared\n", run("clear"))
+	assert.Equal(s.T(), "Entries:  0 (0 bytes)\nHits:     0\nMisses:   0\nHit rate: 0.0%\n", run("stats"))
+}
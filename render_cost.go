@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+var errRenderCostTooHigh = errors.New("estimated render cost exceeds limit")
+
+// rangeElem is the step of a rangePath into every element of a list or map.
+const rangeElem = "[]"
+
+// rangePath is the path in the template data of the values a range action iterates over, starting with
+// an argument name, e.g. "items[].tags" for {{range .items}}{{range .tags}}...{{end}}{{end}}.
+type rangePath []string
+
+func (p rangePath) String() string {
+	var b strings.Builder
+	for i, step := range p {
+		if i > 0 && step != rangeElem {
+			b.WriteByte('.')
+		}
+		b.WriteString(step)
+	}
+	return b.String()
+}
+
+// rangeNest is a chain of nested range actions, from the outermost to the innermost one.
+// Its body runs once per combination of the elements iterated by each range.
+type rangeNest []rangePath
+
+// ExtractRangeNests returns the chains of nested range actions over the data of the template and of the partials
+// it calls, so that the cost of a render can be estimated from the arguments before executing it.
+// Ranges over values the analysis cannot follow, e.g. results of functions, are left out of the chains.
+func (pp *PromptsParser) ExtractRangeNests(tmpl *template.Template, templateName string) ([]rangeNest, error) {
+	targetTemplate := lookupPartial(tmpl, templateName)
+	if targetTemplate == nil {
+		return nil, fmt.Errorf("template %q not found", templateName)
+	}
+	w := &rangeWalker{tmpl: tmpl, seen: make(map[string]bool), calls: []string{targetTemplate.Name()}}
+	root := rangePath{}
+	w.walk(targetTemplate.Root, rangeScope{dot: root, vars: map[string]rangePath{"$": root}}, nil)
+	return w.nests, nil
+}
+
+// rangeScope holds the paths of dot and of the variables where a node executes; nil paths are unknown.
+type rangeScope struct {
+	dot  rangePath
+	vars map[string]rangePath
+}
+
+func (s rangeScope) with(dot rangePath) rangeScope {
+	return rangeScope{dot: dot, vars: maps.Clone(s.vars)}
+}
+
+// rangeWalker collects the range nests of a template, following calls of partials.
+type rangeWalker struct {
+	tmpl  *template.Template
+	nests []rangeNest
+	seen  map[string]bool // nests already collected, by their text
+	calls []string        // templates being walked, to stop at recursive calls
+}
+
+func (w *rangeWalker) walk(node parse.Node, scope rangeScope, chain rangeNest) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			w.walk(child, scope, chain)
+		}
+	case *parse.ActionNode:
+		// Variables declared by actions are visible until the end of the enclosing block
+		if len(n.Pipe.Decl) == 1 {
+			scope.vars[n.Pipe.Decl[0].Ident[0]] = resolveRangePath(n.Pipe, scope)
+		}
+	case *parse.IfNode:
+		w.walk(n.List, scope.with(scope.dot), chain)
+		w.walk(n.ElseList, scope.with(scope.dot), chain)
+	case *parse.WithNode:
+		path := resolveRangePath(n.Pipe, scope)
+		body := scope.with(path)
+		if len(n.Pipe.Decl) == 1 {
+			body.vars[n.Pipe.Decl[0].Ident[0]] = path
+		}
+		w.walk(n.List, body, chain)
+		w.walk(n.ElseList, scope.with(scope.dot), chain)
+	case *parse.RangeNode:
+		body := scope.with(nil)
+		for _, decl := range n.Pipe.Decl {
+			body.vars[decl.Ident[0]] = nil // the index, or the element of a range that cannot be followed
+		}
+		bodyChain := chain
+		if path := resolveRangePath(n.Pipe, scope); len(path) > 0 {
+			elem := joinRangePath(path, rangeElem)
+			body.dot = elem
+			if len(n.Pipe.Decl) > 0 {
+				body.vars[n.Pipe.Decl[len(n.Pipe.Decl)-1].Ident[0]] = elem
+			}
+			bodyChain = append(slices.Clip(chain), path)
+			w.add(bodyChain)
+		}
+		w.walk(n.List, body, bodyChain)
+		w.walk(n.ElseList, scope.with(scope.dot), chain)
+	case *parse.TemplateNode:
+		partial := lookupPartial(w.tmpl, n.Name)
+		if partial == nil || slices.Contains(w.calls, partial.Name()) {
+			return
+		}
+		path := resolveRangePath(n.Pipe, scope)
+		w.calls = append(w.calls, partial.Name())
+		w.walk(partial.Root, rangeScope{dot: path, vars: map[string]rangePath{"$": path}}, chain)
+		w.calls = w.calls[:len(w.calls)-1]
+	}
+}
+
+func (w *rangeWalker) add(nest rangeNest) {
+	key := fmt.Sprint(nest)
+	if w.seen[key] {
+		return
+	}
+	w.seen[key] = true
+	w.nests = append(w.nests, slices.Clone(nest))
+}
+
+// resolveRangePath returns the path of the value of a pipeline that is a plain reference to dot, a field,
+// or a variable, and nil for other pipelines.
+func resolveRangePath(pipe *parse.PipeNode, scope rangeScope) rangePath {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return nil
+	}
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.DotNode:
+		return scope.dot
+	case *parse.FieldNode:
+		if scope.dot != nil {
+			return joinRangePath(scope.dot, arg.Ident...)
+		}
+	case *parse.VariableNode:
+		if base := scope.vars[arg.Ident[0]]; base != nil {
+			return joinRangePath(base, arg.Ident[1:]...)
+		}
+	}
+	return nil
+}
+
+// joinRangePath returns a new path of the steps appended to the base.
+func joinRangePath(base rangePath, steps ...string) rangePath {
+	return append(append(make(rangePath, 0, len(base)+len(steps)), base...), steps...)
+}
+
+// checkRenderCost estimates the number of iterations of the innermost body of every range nest from the data.
+// If any exceeds the limit, it fails naming the deepest such nest and the sizes of its ranges.
+func checkRenderCost(nests []rangeNest, data map[string]interface{}, limit int) error {
+	var worst []string
+	for _, nest := range nests {
+		cost, exceeded := 1, false
+		sizes := make([]string, 0, len(nest))
+		for _, path := range nest {
+			size := rangeSize(reflect.ValueOf(data), path)
+			sizes = append(sizes, fmt.Sprintf("%s (%d)", path, size))
+			if size > 0 && cost > limit/size {
+				exceeded = true
+			}
+			cost *= size
+		}
+		if (exceeded || cost > limit) && len(sizes) > len(worst) {
+			worst = sizes
+		}
+	}
+	if worst != nil {
+		return fmt.Errorf("%w of %d iterations: nested ranges over %s", errRenderCostTooHigh, limit,
+			strings.Join(worst, " > "))
+	}
+	return nil
+}
+
+// rangeSize returns the number of iterations of a range over the value at the path: the length of a list or map,
+// or the value of an integer. For rangeElem steps it returns the largest size among the elements.
+// Values that are missing or cannot be ranged over have size 0, as their render fails or iterates nothing.
+func rangeSize(value reflect.Value, path rangePath) int {
+	for value.Kind() == reflect.Interface || value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return 0
+		}
+		value = value.Elem()
+	}
+	if len(path) == 0 {
+		switch value.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return value.Len()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return int(max(value.Int(), 0))
+		}
+		return 0
+	}
+	switch step := path[0]; {
+	case step == rangeElem && (value.Kind() == reflect.Slice || value.Kind() == reflect.Array):
+		largest := 0
+		for i := range value.Len() {
+			largest = max(largest, rangeSize(value.Index(i), path[1:]))
+		}
+		return largest
+	case step == rangeElem && value.Kind() == reflect.Map:
+		largest := 0
+		for iter := value.MapRange(); iter.Next(); {
+			largest = max(largest, rangeSize(iter.Value(), path[1:]))
+		}
+		return largest
+	case value.Kind() == reflect.Map && value.Type().Key().Kind() == reflect.String:
+		if elem := value.MapIndex(reflect.ValueOf(step).Convert(value.Type().Key())); elem.IsValid() {
+			return rangeSize(elem, path[1:])
+		}
+	}
+	return 0
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RenderCostTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestRenderCostTestSuite(t *testing.T) {
+	suite.Run(t, new(RenderCostTestSuite))
+}
+
+func (s *RenderCostTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("bomb.tmpl", "{{/* Bomb */}}\n{{range .items}}{{range $.items}}{{range $.items}}x{{end}}{{end}}{{end}}")
+	s.writeFile("list.tmpl", "{{/* List */}}\n{{range .items}}- {{.}}\n{{end}}")
+}
+
+func (s *RenderCostTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+func (s *RenderCostTestSuite) getPrompt(promptsServer *PromptsServer, name string, args map[string]string) mcp.JSONRPCMessage {
+	request, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0", "id": 1, "method": "prompts/get", "params": map[string]any{"name": name, "arguments": args},
+	})
+	require.NoError(s.T(), err)
+	return promptsServer.mcpServer.HandleMessage(context.Background(), request)
+}
+
+// TestExtractRangeNests tests the chains of nested ranges found in templates and the partials they call
+func (s *RenderCostTestSuite) TestExtractRangeNests() {
+	for _, tc := range []struct {
+		template string
+		expected []string
+	}{
+		{template: `{{range .items}}{{.}}{{end}}`, expected: []string{"[items]"}},
+		{template: `{{range .items}}{{range .tags}}{{.}}{{end}}{{end}}`, expected: []string{"[items]", "[items items[].tags]"}},
+		{
+			template: `{{range $i, $item := .items}}{{range $item.tags}}{{range $.users}}{{end}}{{end}}{{end}}`,
+			expected: []string{"[items]", "[items items[].tags]", "[items items[].tags users]"},
+		},
+		{template: `{{$list := .items}}{{with .config}}{{range $list}}{{range .modes}}{{end}}{{end}}{{end}}`,
+			expected: []string{"[items]", "[items items[].modes]"}},
+		{template: `{{with .config}}{{range .modes}}{{end}}{{end}}`, expected: []string{"[config.modes]"}},
+		{template: `{{range until 5}}{{range .items}}{{end}}{{end}}`, expected: nil},
+		{template: `{{range .items}}{{template "_rows" $}}{{end}}`, expected: []string{"[items]", "[items rows]"}},
+		{template: `{{template "_rows" .}}{{template "_rows" dict "rows" .items}}`, expected: []string{"[rows]"}},
+	} {
+		parser := &PromptsParser{}
+		tmpl, err := parser.ParseTemplates(map[string]string{
+			"prompt": tc.template,
+			"_rows":  `{{range .rows}}{{.}}{{end}}{{template "_rows" .}}`,
+		})
+		require.NoError(s.T(), err, tc.template)
+		nests, err := parser.ExtractRangeNests(tmpl, "prompt")
+		require.NoError(s.T(), err, tc.template)
+		var actual []string
+		for _, nest := range nests {
+			actual = append(actual, fmt.Sprint(nest))
+		}
+		assert.Equal(s.T(), tc.expected, actual, tc.template)
+	}
+}
+
+// TestRejectsNestedRangeOverLargeArgument tests that a combinatorial render is rejected before it runs,
+// naming the argument and the nesting
+func (s *RenderCostTestSuite) TestRejectsNestedRangeOverLargeArgument() {
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithWatchMode(WatchModeOff, 0),
+		WithRenderSettings(RenderSettings{MaxRenderCost: 1_000_000}))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	items := "[" + strings.TrimSuffix(strings.Repeat("1,", 10000), ",") + "]"
+	started := time.Now()
+	response, ok := s.getPrompt(promptsServer, "bomb", map[string]string{"items": items}).(mcp.JSONRPCError)
+	require.True(s.T(), ok)
+	assert.Less(s.T(), time.Since(started), 5*time.Second)
+	assert.Equal(s.T(), `execute template "bomb.tmpl": estimated render cost exceeds limit of 1000000 iterations: `+
+		`nested ranges over items (10000) > items (10000) > items (10000)`, response.Error.Message)
+
+	_, ok = s.getPrompt(promptsServer, "bomb", map[string]string{"items": "[1,2,3]"}).(mcp.JSONRPCResponse)
+	assert.True(s.T(), ok, "small arguments must still render")
+}
+
+// TestSingleRangePasses tests that a legitimate range over a large argument renders within the limit
+func (s *RenderCostTestSuite) TestSingleRangePasses() {
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithWatchMode(WatchModeOff, 0),
+		WithRenderSettings(RenderSettings{MaxRenderCost: 100_000}))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	items := "[" + strings.TrimSuffix(strings.Repeat(`"a",`, 10000), ",") + "]"
+	response, ok := s.getPrompt(promptsServer, "list", map[string]string{"items": items}).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok)
+	text := response.Result.(mcp.GetPromptResult).Messages[0].Content.(mcp.TextContent).Text
+	assert.Equal(s.T(), 10000, strings.Count(text, "- a"))
+}
+
+// TestRangeSize tests the sizes of values ranged over, with the largest element for nested paths
+func (s *RenderCostTestSuite) TestRangeSize() {
+	var data map[string]interface{}
+	require.NoError(s.T(), json.Unmarshal([]byte(`{"items": [{"tags": [1, 2]}, {"tags": [1, 2, 3]}, {}], "n": 4}`), &data))
+	data["count"] = 7
+
+	cost := func(nest ...rangePath) error { return checkRenderCost([]rangeNest{nest}, data, 5) }
+	assert.NoError(s.T(), cost(rangePath{"items"}))
+	assert.NoError(s.T(), cost(rangePath{"missing"}, rangePath{"count"}))
+	assert.EqualError(s.T(), cost(rangePath{"items"}, rangePath{"items", rangeElem, "tags"}),
+		"estimated render cost exceeds limit of 5 iterations: nested ranges over items (3) > items[].tags (3)")
+	assert.EqualError(s.T(), cost(rangePath{"count"}),
+		"estimated render cost exceeds limit of 5 iterations: nested ranges over count (7)")
+	assert.NoError(s.T(), cost(rangePath{"n"}), "JSON numbers cannot be ranged over")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// mcpEnvelopeOptions configures the server that renders a prompt for render --mcp.
+type mcpEnvelopeOptions struct {
+	partialsDirs   []string
+	enableJSONArgs bool
+	dateName       string
+	lineEndings    LineEndings
+	nameStyle      NameStyle
+	vars           map[string]string
+}
+
+// writeMCPEnvelope renders the template through a prompts/get request to an in-process server and writes
+// the JSON-RPC response on a single line, exactly as the server sends it to clients.
+// Errors of the request are written as the JSON-RPC error envelope and returned.
+func writeMCPEnvelope(
+	ctx context.Context, w io.Writer, promptsDir string, templateName string,
+	cliArgs map[string]string, fileArgs map[string]interface{}, opts mcpEnvelopeOptions,
+) error {
+	args, err := envelopeArgs(cliArgs, fileArgs)
+	if err != nil {
+		return err
+	}
+
+	promptsServer, err := NewPromptsServer(promptsDir,
+		WithJSONArgs(opts.enableJSONArgs),
+		WithArgsLimits(ArgsLimits{}),
+		WithRenderSettings(RenderSettings{LineEndings: opts.lineEndings}),
+		WithPartialsDirs(opts.partialsDirs...),
+		WithWatchMode(WatchModeOff, 0),
+		WithBuiltinDateName(opts.dateName),
+		WithNameStyle(opts.nameStyle),
+		WithVars(opts.vars),
+	)
+	if err != nil {
+		return fmt.Errorf("load prompts: %w", err)
+	}
+	defer func() { _ = promptsServer.Close() }()
+
+	request, err := json.Marshal(map[string]any{
+		"jsonrpc": mcp.JSONRPC_VERSION,
+		"id":      1,
+		"method":  string(mcp.MethodPromptsGet),
+		"params":  map[string]any{"name": opts.nameStyle.PromptName(templateName), "arguments": args},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal prompt request: %w", err)
+	}
+	response := promptsServer.mcpServer.HandleMessage(ctx, request)
+	if err = json.NewEncoder(w).Encode(response); err != nil {
+		return err
+	}
+	if rpcErr, isError := response.(mcp.JSONRPCError); isError {
+		return fmt.Errorf("%s", rpcErr.Error.Message)
+	}
+	return nil
+}
+
+// envelopeArgs returns the arguments as a client sends them, all as strings: text from files as is,
+// and JSON from files encoded again. Clients cannot mark values as text, so the server parses any of them
+// holding JSON unless JSON arguments are disabled.
+func envelopeArgs(cliArgs map[string]string, fileArgs map[string]interface{}) (map[string]string, error) {
+	args := maps.Clone(cliArgs)
+	if args == nil {
+		args = make(map[string]string, len(fileArgs))
+	}
+	for _, name := range slices.Sorted(maps.Keys(fileArgs)) {
+		if text, ok := fileArgs[name].(string); ok {
+			args[name] = text
+			continue
+		}
+		encoded, err := json.Marshal(fileArgs[name])
+		if err != nil {
+			return nil, fmt.Errorf("encode argument %q: %w", name, err)
+		}
+		args[name] = string(encoded)
+	}
+	return args, nil
+}
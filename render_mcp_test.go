@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RenderMCPTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestRenderMCPTestSuite(t *testing.T) {
+	suite.Run(t, new(RenderMCPTestSuite))
+}
+
+func (s *RenderMCPTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, "Greet_User.tmpl"),
+		[]byte("{{/* Greet a user */}}\nHello {{.name}}! {{range .tags}}#{{.}} {{end}}"), 0644))
+}
+
+func (s *RenderMCPTestSuite) render(args ...string) (string, error) {
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	app.ErrWriter = &bytes.Buffer{}
+	err := app.Run(context.Background(), append([]string{app.Name, "--name-style", "kebab", "render", s.promptsDir}, args...))
+	return buf.String(), err
+}
+
+// TestEnvelope tests that the output is the JSON-RPC response of the server, with roles and content types
+func (s *RenderMCPTestSuite) TestEnvelope() {
+	tagsFile := filepath.Join(s.T().TempDir(), "tags.json")
+	require.NoError(s.T(), os.WriteFile(tagsFile, []byte(`["go", "mcp"]`), 0644))
+	output, err := s.render("greet-user", "--mcp", "--arg", "name=Alice", "--arg-file-json", "tags="+tagsFile)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 1, bytes.Count([]byte(output), []byte("\n")), "the envelope must be a single line")
+
+	var envelope struct {
+		JSONRPC string `json:"jsonrpc"`
+		ID      int    `json:"id"`
+		Result  struct {
+			Description string `json:"description"`
+			Messages    []struct {
+				Role    string `json:"role"`
+				Content struct {
+					Type string `json:"type"`
+					Text string `json:"text"`
+				} `json:"content"`
+			} `json:"messages"`
+		} `json:"result"`
+	}
+	require.NoError(s.T(), json.Unmarshal([]byte(output), &envelope))
+	assert.Equal(s.T(), "2.0", envelope.JSONRPC)
+	assert.Equal(s.T(), 1, envelope.ID)
+	assert.Equal(s.T(), "Greet a user", envelope.Result.Description)
+	require.Len(s.T(), envelope.Result.Messages, 1)
+	assert.Equal(s.T(), "user", envelope.Result.Messages[0].Role)
+	assert.Equal(s.T(), "text", envelope.Result.Messages[0].Content.Type)
+	assert.Equal(s.T(), "Hello Alice! #go #mcp", envelope.Result.Messages[0].Content.Text)
+}
+
+// TestErrorEnvelope tests that failed requests print the JSON-RPC error and fail the command
+func (s *RenderMCPTestSuite) TestErrorEnvelope() {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, "broken.tmpl"),
+		[]byte("{{/* Broken */}}\n{{index .items 5}}"), 0644))
+	output, err := s.render("broken", "--mcp", "--arg", "items=[1]")
+	require.Error(s.T(), err)
+
+	var envelope struct {
+		ID    int `json:"id"`
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	require.NoError(s.T(), json.Unmarshal([]byte(output), &envelope))
+	assert.Equal(s.T(), 1, envelope.ID)
+	assert.NotZero(s.T(), envelope.Error.Code)
+	assert.Contains(s.T(), envelope.Error.Message, "index out of range")
+
+	_, err = s.render("greet-user", "--mcp", "--show-whitespace")
+	require.EqualError(s.T(), err, "--mcp cannot be combined with --show-whitespace")
+}
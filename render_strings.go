@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RenderTemplateFromStrings renders one template of an in-memory set of templates and partials
+// (see PromptsParser.ParseTemplates) without touching the filesystem, e.g. for prompts supplied at runtime.
+// Arguments are transformed, validated, and parsed like MCP prompt arguments, and frontmatter data constants
+// and builtins apply as for template files.
+// Environment variables are never used as argument fallbacks.
+func RenderTemplateFromStrings(
+	templates map[string]string, templateName string, args map[string]string, enableJSONArgs bool,
+) (string, error) {
+	templateName = strings.TrimSpace(templateName)
+	if templateName == "" {
+		return "", fmt.Errorf("template name is required")
+	}
+	if !strings.HasSuffix(templateName, templateExt) {
+		templateName += templateExt
+	}
+	if strings.HasPrefix(templateName, "_") {
+		return "", fmt.Errorf("template %q is a partial and cannot be rendered on its own", templateName)
+	}
+
+	parser := &PromptsParser{}
+	tmpl, err := parser.ParseTemplates(templates)
+	if err != nil {
+		return "", err
+	}
+	if tmpl.Lookup(templateName) == nil {
+		return "", fmt.Errorf("template %q not found", templateName)
+	}
+
+	// Walking the tree reports references to missing partials and cycles before executing
+	if _, err = parser.ExtractPromptArgumentsFromTemplate(tmpl, templateName); err != nil {
+		return "", fmt.Errorf("extract template arguments: %w", err)
+	}
+
+	content, ok := templates[templateName]
+	if !ok {
+		content = templates[strings.TrimSuffix(templateName, templateExt)]
+	}
+	frontmatter, err := parseFrontmatter([]byte(content), parser.dateName())
+	if err != nil {
+		return "", fmt.Errorf("load frontmatter: %w", err)
+	}
+
+	data := make(map[string]interface{})
+	if frontmatter.IncludesDate() {
+		data[parser.dateName()] = time.Now().Format(builtinDateLayout)
+	}
+	appliedArgs := frontmatter.TransformArgs(args)
+	if err = frontmatter.ValidateArgs(appliedArgs); err != nil {
+		return "", err
+	}
+	parseMCPArgs(appliedArgs, enableJSONArgs, data)
+	frontmatter.MergeConstants(data)
+
+	if tmpl, err = bindScratchpad(tmpl); err != nil {
+		return "", err
+	}
+	var result bytes.Buffer
+	if err = tmpl.ExecuteTemplate(&result, templateName, data); err != nil {
+		return "", fmt.Errorf("execute template: %w", err)
+	}
+	return strings.TrimSpace(result.String()), nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRenderTemplateFromStrings tests rendering from an in-memory set of templates with a partial
+func TestRenderTemplateFromStrings(t *testing.T) {
+	templates := map[string]string{
+		"_header.tmpl": `{{define "_header"}}You are a {{.role}} at {{.company}}.{{end}}`,
+		"review": "---\ndata:\n  company: Acme\n---\n{{/* Review code */}}\n{{template \"_header\" .}}\n" +
+			"Focus on:{{range .areas}} {{upper .}}{{end}}",
+	}
+
+	output, err := RenderTemplateFromStrings(templates, "review",
+		map[string]string{"role": "reviewer", "areas": `["tests", "docs"]`, "company": "Evil"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "You are a reviewer at Acme.\nFocus on: TESTS DOCS", output)
+
+	output, err = RenderTemplateFromStrings(templates, "review.tmpl", nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, "You are a <no value> at Acme.\nFocus on:", output)
+
+	dateTemplates := map[string]string{
+		"dated.tmpl":   "{{if .date}}Dated{{else}}Undated{{end}}",
+		"undated.tmpl": "---\nbuiltins:\n  date: false\n---\n{{if .date}}Dated{{else}}Undated{{end}}",
+	}
+	output, err = RenderTemplateFromStrings(dateTemplates, "dated", nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, "Dated", output)
+	output, err = RenderTemplateFromStrings(dateTemplates, "undated", nil, true)
+	require.NoError(t, err)
+	assert.Equal(t, "Undated", output, "the frontmatter turns the built-in date off")
+
+	ticketTemplates := map[string]string{
+		"ticket.tmpl": "---\narguments:\n  id:\n    transform: [trim, upper]\n    pattern: \"^[A-Z]+-[0-9]+$\"\n---\nTicket {{.id}}",
+	}
+	output, err = RenderTemplateFromStrings(ticketTemplates, "ticket", map[string]string{"id": " abc-12 "}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "Ticket ABC-12", output, "values are validated after the transforms")
+	_, err = RenderTemplateFromStrings(ticketTemplates, "ticket", map[string]string{"id": "12"}, true)
+	assert.ErrorContains(t, err, `argument "id" does not match the pattern`)
+
+	tests := []struct {
+		name         string
+		templates    map[string]string
+		templateName string
+		expectedErr  string
+	}{
+		{
+			name:         "missing partial",
+			templates:    map[string]string{"review.tmpl": `{{template "_missing" .}}`},
+			templateName: "review",
+			expectedErr:  `referenced template "_missing" not found`,
+		},
+		{
+			name:         "partial rendered on its own",
+			templates:    templates,
+			templateName: "_header",
+			expectedErr:  "is a partial",
+		},
+		{
+			name:         "template not found",
+			templates:    templates,
+			templateName: "summary",
+			expectedErr:  `template "summary.tmpl" not found`,
+		},
+		{
+			name:         "same template with and without extension",
+			templates:    map[string]string{"review": "a", "review.tmpl": "b"},
+			templateName: "review",
+			expectedErr:  "is given more than once",
+		},
+		{
+			name:         "path in template name",
+			templates:    map[string]string{"../review.tmpl": "a"},
+			templateName: "review",
+			expectedErr:  "must be a plain file name",
+		},
+		{
+			name:         "syntax error",
+			templates:    map[string]string{"review.tmpl": "{{.name}"},
+			templateName: "review",
+			expectedErr:  `parse template "review.tmpl"`,
+		},
+		{
+			name:         "no templates",
+			templateName: "review",
+			expectedErr:  "no templates given",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := RenderTemplateFromStrings(tt.templates, tt.templateName, nil, true)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// missingRequiredEnv returns the unset environment variables of the arguments declared with env in the prompts
+// of the directory, mapped to the template files declaring them. Arguments set with context values are satisfied.
+func (ps *PromptsServer) missingRequiredEnv() (map[string][]string, error) {
+	files, err := os.ReadDir(ps.promptsDir)
+	if err != nil {
+		return nil, fmt.Errorf("read prompts directory: %w", err)
+	}
+	missing := make(map[string][]string)
+	for _, file := range files {
+		if !isTemplateFile(file) || !ps.parser.includes(file.Name()) {
+			continue
+		}
+		frontmatter, err := loadPromptFrontmatter(filepath.Join(ps.promptsDir, file.Name()), ps.parser.dateName())
+		if err != nil {
+			return nil, fmt.Errorf("load frontmatter of %q template file: %w", file.Name(), err)
+		}
+		for _, arg := range frontmatter.EnvArgs() {
+			if _, exists := ps.contextValues[arg]; exists {
+				continue
+			}
+			envVarName := strings.ToUpper(arg)
+			if _, exists := os.LookupEnv(envVarName); !exists {
+				missing[envVarName] = append(missing[envVarName], file.Name())
+			}
+		}
+	}
+	return missing, nil
+}
+
+// checkRequiredEnv fails if an environment variable required by a prompt is unset, listing all of them.
+func (ps *PromptsServer) checkRequiredEnv() error {
+	missing, err := ps.missingRequiredEnv()
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	var vars []string
+	for _, envVarName := range slices.Sorted(maps.Keys(missing)) {
+		vars = append(vars, fmt.Sprintf("%s (%s)", envVarName, strings.Join(missing[envVarName], ", ")))
+	}
+	return fmt.Errorf("missing required environment variables: %s", strings.Join(vars, "; "))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RequiredEnvTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestRequiredEnvTestSuite(t *testing.T) {
+	suite.Run(t, new(RequiredEnvTestSuite))
+}
+
+func (s *RequiredEnvTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("deploy.tmpl", "---\narguments:\n  mpe_region:\n    env: true\n  mpe_api_token:\n    env: true\n---\n"+
+		"{{/* Deploy */}}\nDeploy {{.service}} to {{.mpe_region}} with {{.mpe_api_token}}")
+	s.writeFile("release.tmpl", "---\narguments:\n  mpe_region:\n    env: true\n---\n{{/* Release */}}\nRelease in {{.mpe_region}}")
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello {{.name}}")
+}
+
+func (s *RequiredEnvTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+// TestStartupFailsWithMissingEnv tests that the server refuses to start listing every unset variable
+func (s *RequiredEnvTestSuite) TestStartupFailsWithMissingEnv() {
+	_, err := NewPromptsServer(s.promptsDir, WithRequireEnv(true), WithWatchMode(WatchModeOff, 0))
+	assert.EqualError(s.T(), err,
+		"missing required environment variables: MPE_API_TOKEN (deploy.tmpl); MPE_REGION (deploy.tmpl, release.tmpl)")
+
+	s.T().Setenv("MPE_REGION", "eu-west-1")
+	_, err = NewPromptsServer(s.promptsDir, WithRequireEnv(true), WithWatchMode(WatchModeOff, 0))
+	assert.EqualError(s.T(), err, "missing required environment variables: MPE_API_TOKEN (deploy.tmpl)")
+
+	// Without the option, the prompts are served and the unset arguments are left to clients
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	s.Require().NoError(promptsServer.Close())
+}
+
+// TestStartupSucceedsWithEnv tests that variables set in the environment or given as context values satisfy the check
+func (s *RequiredEnvTestSuite) TestStartupSucceedsWithEnv() {
+	s.T().Setenv("MPE_REGION", "eu-west-1")
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithRequireEnv(true), WithWatchMode(WatchModeOff, 0),
+		WithContextValues(map[string]string{"mpe_api_token": "secret"}))
+	require.NoError(s.T(), err)
+	s.Require().NoError(promptsServer.Close())
+}
+
+// TestDisabledEnvArgs tests that the check cannot be combined with disabled environment arguments
+func (s *RequiredEnvTestSuite) TestDisabledEnvArgs() {
+	_, err := NewPromptsServer(s.promptsDir, WithRequireEnv(true), WithWatchMode(WatchModeOff, 0),
+		WithRenderSettings(RenderSettings{DisableEnvArgs: true}))
+	assert.EqualError(s.T(), err, "required environment variables cannot be checked with environment arguments disabled")
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RuntimeConfig is the file of the serve --config flag. It holds the settings that can change while the server
+// runs: reloading it applies them to the following requests without restarting the MCP session.
+// Settings the file does not set keep the values of the flags.
+type RuntimeConfig struct {
+	LogLevel        *string        `yaml:"log_level"` // debug, info, warn, or error
+	MaxArgKeyLength *int           `yaml:"max_arg_key_length"`
+	MaxArgsSize     *int           `yaml:"max_args_size"`
+	MaxJSONDepth    *int           `yaml:"max_json_depth"`
+	MaxOutputSize   *int           `yaml:"max_output_size"`
+	MaxRenderCost   *int           `yaml:"max_render_cost"`
+	RenderTimeout   *time.Duration `yaml:"render_timeout"`
+	// PromptsDir cannot change without a restart. A reload that changes it applies the other settings
+	// and logs that the prompts directory is kept.
+	PromptsDir *string `yaml:"prompts_dir"`
+}
+
+// runtimeSettings are the settings of the server that config reloads replace as a whole.
+type runtimeSettings struct {
+	argsLimits     ArgsLimits
+	renderSettings RenderSettings
+	logLevel       slog.Level
+	version        uint64 // 1 for the settings the server started with, increased by every reload
+}
+
+// loadRuntimeConfig reads the runtime config file. Unknown keys are errors, so that typos do not go unnoticed.
+func loadRuntimeConfig(path string) (*RuntimeConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	var cfg RuntimeConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse config %q: %w", path, err)
+	}
+	for _, limit := range []struct {
+		name  string
+		value *int
+	}{
+		{"max_arg_key_length", cfg.MaxArgKeyLength}, {"max_args_size", cfg.MaxArgsSize},
+		{"max_json_depth", cfg.MaxJSONDepth}, {"max_output_size", cfg.MaxOutputSize},
+		{"max_render_cost", cfg.MaxRenderCost},
+	} {
+		if limit.value != nil && *limit.value < 0 {
+			return nil, fmt.Errorf("config %q: %s must not be negative", path, limit.name)
+		}
+	}
+	if cfg.RenderTimeout != nil && *cfg.RenderTimeout < 0 {
+		return nil, fmt.Errorf("config %q: render_timeout must not be negative", path)
+	}
+	if cfg.LogLevel != nil {
+		if _, err = parseLogLevel(*cfg.LogLevel); err != nil {
+			return nil, fmt.Errorf("config %q: %w", path, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// parseLogLevel parses a log level of the runtime config.
+func parseLogLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (expected debug, info, warn, or error)", s)
+}
+
+// apply returns the settings with the values set by the config.
+func (cfg *RuntimeConfig) apply(settings runtimeSettings) runtimeSettings {
+	if cfg.LogLevel != nil {
+		settings.logLevel, _ = parseLogLevel(*cfg.LogLevel)
+	}
+	if cfg.MaxArgKeyLength != nil {
+		settings.argsLimits.MaxKeyLength = *cfg.MaxArgKeyLength
+	}
+	if cfg.MaxArgsSize != nil {
+		settings.argsLimits.MaxTotalSize = *cfg.MaxArgsSize
+	}
+	if cfg.MaxJSONDepth != nil {
+		settings.argsLimits.MaxJSONDepth = *cfg.MaxJSONDepth
+	}
+	if cfg.MaxOutputSize != nil {
+		settings.renderSettings.MaxOutputSize = *cfg.MaxOutputSize
+	}
+	if cfg.MaxRenderCost != nil {
+		settings.renderSettings.MaxRenderCost = *cfg.MaxRenderCost
+	}
+	if cfg.RenderTimeout != nil {
+		settings.renderSettings.Timeout = *cfg.RenderTimeout
+	}
+	return settings
+}
+
+// argsLimits returns the current limits of the arguments of GetPrompt requests.
+func (ps *PromptsServer) argsLimits() ArgsLimits {
+	return ps.runtime.Load().argsLimits
+}
+
+// renderSettings returns the current render settings. Callers reading several fields for one request
+// should keep the returned value, so that a concurrent reload does not mix old and new settings.
+func (ps *PromptsServer) renderSettings() RenderSettings {
+	return ps.runtime.Load().renderSettings
+}
+
+// ConfigVersion returns the version of the runtime settings: 1 as started, increased by every config reload.
+func (ps *PromptsServer) ConfigVersion() uint64 {
+	return ps.runtime.Load().version
+}
+
+// loadRuntimeConfig applies the runtime config file on top of the settings of the flags.
+// In safe mode, a config loosening its limits is an error. Changes of settings that cannot be applied while the server runs are logged and ignored.
+func (ps *PromptsServer) loadRuntimeConfig() (runtimeSettings, error) {
+	settings := ps.flagSettings
+	if ps.configPath == "" {
+		return settings, nil
+	}
+	cfg, err := loadRuntimeConfig(ps.configPath)
+	if err != nil {
+		return settings, err
+	}
+	if ps.safeMode {
+		if err = checkSafeModeConfig(cfg); err != nil {
+			return settings, fmt.Errorf("config %q: %w", ps.configPath, err)
+		}
+	}
+	if cfg.PromptsDir != nil && filepath.Clean(*cfg.PromptsDir) != filepath.Clean(ps.promptsDir) {
+		ps.logger.Warn("The prompts directory cannot be changed without a restart, keeping it",
+			"prompts_dir", ps.promptsDir, "config_prompts_dir", *cfg.PromptsDir)
+	}
+	return cfg.apply(settings), nil
+}
+
+// ReloadConfig re-reads the runtime config file and atomically applies it to the following requests.
+// On errors, the current settings are kept. It returns the new config version.
+func (ps *PromptsServer) ReloadConfig() (uint64, error) {
+	if ps.configPath == "" {
+		return 0, errors.New("the server was started without --config")
+	}
+	ps.configMu.Lock()
+	defer ps.configMu.Unlock()
+
+	settings, err := ps.loadRuntimeConfig()
+	if err != nil {
+		return 0, err
+	}
+	previous := ps.runtime.Load()
+	settings.version = previous.version + 1
+	ps.runtime.Store(&settings)
+	if ps.logLevel != nil {
+		ps.logLevel.Set(settings.logLevel)
+	}
+	ps.logger.Info("Config reloaded", "version", settings.version, "log_level", settings.logLevel,
+		"args_limits", fmt.Sprintf("%+v", settings.argsLimits),
+		"max_output_size", settings.renderSettings.MaxOutputSize, "max_render_cost", settings.renderSettings.MaxRenderCost,
+		"render_timeout", settings.renderSettings.Timeout)
+	return settings.version, nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RuntimeConfigTestSuite struct {
+	suite.Suite
+	promptsDir string
+	configPath string
+}
+
+func TestRuntimeConfigTestSuite(t *testing.T) {
+	suite.Run(t, new(RuntimeConfigTestSuite))
+}
+
+func (s *RuntimeConfigTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, "greet.tmpl"),
+		[]byte("{{/* Greet */}}\nHello {{.name}}!"), 0644))
+	s.configPath = filepath.Join(s.T().TempDir(), "config.yaml")
+}
+
+func (s *RuntimeConfigTestSuite) writeConfig(content string) {
+	require.NoError(s.T(), os.WriteFile(s.configPath, []byte(content), 0644))
+}
+
+// serve starts a server with the config and a client connected to it over stdio until the test ends
+func (s *RuntimeConfigTestSuite) serve(
+	logger *slog.Logger, logLevel *slog.LevelVar, opts ...Option,
+) (*PromptsServer, *client.Client) {
+	ctx, cancel := context.WithCancel(context.Background())
+	promptsServer, err := NewPromptsServer(s.promptsDir, append([]Option{
+		WithWatchMode(WatchModeOff, 0),
+		WithRuntimeConfig(s.configPath, logLevel),
+		WithLogger(logger),
+	}, opts...)...)
+	require.NoError(s.T(), err)
+
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- promptsServer.ServeStdio(ctx, serverReader, serverWriter)
+	}()
+	transp := transport.NewIO(clientReader, clientWriter, io.NopCloser(&bytes.Buffer{}))
+	require.NoError(s.T(), transp.Start(ctx))
+	mcpClient := client.NewClient(transp)
+	require.NoError(s.T(), mcpClient.Start(ctx))
+	var initReq mcp.InitializeRequest
+	initReq.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	_, err = mcpClient.Initialize(ctx, initReq)
+	require.NoError(s.T(), err)
+
+	s.T().Cleanup(func() {
+		cancel()
+		s.NoError(<-errChan)
+		s.NoError(transp.Close())
+		s.NoError(promptsServer.Close())
+	})
+	return promptsServer, mcpClient
+}
+
+func (s *RuntimeConfigTestSuite) greet(mcpClient *client.Client, name string) error {
+	var req mcp.GetPromptRequest
+	req.Params.Name = "greet"
+	req.Params.Arguments = map[string]string{"name": name}
+	_, err := mcpClient.GetPrompt(context.Background(), req)
+	return err
+}
+
+// TestReloadAppliesToSameSession tests that reloaded limits apply to the next request of the connected client
+func (s *RuntimeConfigTestSuite) TestReloadAppliesToSameSession() {
+	s.writeConfig("max_output_size: 1000\n")
+	promptsServer, mcpClient := s.serve(slog.New(slog.DiscardHandler), nil)
+	assert.Equal(s.T(), uint64(1), promptsServer.ConfigVersion())
+	require.NoError(s.T(), s.greet(mcpClient, "Ada"))
+
+	s.writeConfig("max_output_size: 5\nmax_args_size: 100\n")
+	version, err := promptsServer.ReloadConfig()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), uint64(2), version)
+	assert.Equal(s.T(), 5, promptsServer.renderSettings().MaxOutputSize)
+	assert.Equal(s.T(), 100, promptsServer.argsLimits().MaxTotalSize)
+	assert.ErrorContains(s.T(), s.greet(mcpClient, "Ada"), "exceeds")
+
+	s.writeConfig("")
+	version, err = promptsServer.ReloadConfig()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), uint64(3), version)
+	assert.Equal(s.T(), DefaultArgsLimits(), promptsServer.argsLimits(), "removed settings return to their flags")
+	require.NoError(s.T(), s.greet(mcpClient, "Ada"))
+}
+
+// TestInvalidConfigKeepsSettings tests that a failed reload keeps the current settings and version
+func (s *RuntimeConfigTestSuite) TestInvalidConfigKeepsSettings() {
+	s.writeConfig("max_output_size: 1000\n")
+	promptsServer, mcpClient := s.serve(slog.New(slog.DiscardHandler), nil)
+
+	for _, tc := range []struct {
+		config string
+		err    string
+	}{
+		{config: "max_output_size: [1]\n", err: "parse config"},
+		{config: "max_output_sise: 5\n", err: "field max_output_sise not found"},
+		{config: "max_args_size: -1\n", err: "max_args_size must not be negative"},
+		{config: "log_level: verbose\n", err: `unknown log level "verbose"`},
+	} {
+		s.writeConfig(tc.config)
+		_, err := promptsServer.ReloadConfig()
+		assert.ErrorContains(s.T(), err, tc.err, tc.config)
+	}
+	assert.Equal(s.T(), uint64(1), promptsServer.ConfigVersion())
+	assert.Equal(s.T(), 1000, promptsServer.renderSettings().MaxOutputSize)
+	require.NoError(s.T(), s.greet(mcpClient, "Ada"))
+
+	_, err := NewPromptsServer(s.promptsDir, WithRuntimeConfig(filepath.Join(s.T().TempDir(), "none.yaml"), nil))
+	assert.ErrorContains(s.T(), err, "read config", "an unreadable config fails the start")
+	_, err = NewPromptsServer(s.promptsDir)
+	require.NoError(s.T(), err)
+}
+
+// TestSafeModeKeepsLimits tests that in safe mode a config may tighten the limits but not loosen them,
+// neither at the start nor on reloads
+func (s *RuntimeConfigTestSuite) TestSafeModeKeepsLimits() {
+	safeOpts := []Option{WithArgsLimits(DefaultArgsLimits()), WithRenderSettings(SafeRenderSettings()), WithSafeMode(true)}
+	s.writeConfig("max_output_size: 1000\n")
+	promptsServer, mcpClient := s.serve(slog.New(slog.DiscardHandler), nil, safeOpts...)
+
+	for _, tc := range []struct {
+		config string
+		err    string
+	}{
+		{config: "max_output_size: 0\n", err: "max_output_size: 0 would weaken safe mode"},
+		{config: "max_args_size: 1048577\n", err: "max_args_size: 1048577 would weaken safe mode"},
+		{config: "max_render_cost: 0\n", err: "max_render_cost: 0 would weaken safe mode"},
+		{config: "render_timeout: 0s\n", err: "render_timeout: 0s would weaken safe mode"},
+		{config: "render_timeout: 1m\n", err: "render_timeout: 1m0s would weaken safe mode"},
+	} {
+		s.writeConfig(tc.config)
+		_, err := promptsServer.ReloadConfig()
+		assert.ErrorContains(s.T(), err, tc.err, tc.config)
+	}
+	assert.Equal(s.T(), uint64(1), promptsServer.ConfigVersion())
+	assert.Equal(s.T(), 1000, promptsServer.renderSettings().MaxOutputSize)
+	assert.Equal(s.T(), SafeRenderSettings().Timeout, promptsServer.renderSettings().Timeout)
+	require.NoError(s.T(), s.greet(mcpClient, "Ada"))
+
+	s.writeConfig("max_output_size: 5\n")
+	_, err := promptsServer.ReloadConfig()
+	require.NoError(s.T(), err)
+	assert.ErrorContains(s.T(), s.greet(mcpClient, "Ada"), "exceeds")
+
+	s.writeConfig("max_output_size: 0\n")
+	_, err = NewPromptsServer(s.promptsDir, append(safeOpts, WithRuntimeConfig(s.configPath, nil))...)
+	assert.ErrorContains(s.T(), err, "would weaken safe mode", "a loosening config fails the start")
+}
+
+// TestLogLevelAndPromptsDir tests the reload of the log level and that a changed prompts directory is ignored
+func (s *RuntimeConfigTestSuite) TestLogLevelAndPromptsDir() {
+	s.writeConfig("log_level: warn\n")
+	var logs bytes.Buffer
+	logLevel := new(slog.LevelVar)
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: logLevel}))
+	promptsServer, _ := s.serve(logger, logLevel)
+	assert.Equal(s.T(), slog.LevelWarn, logLevel.Level())
+
+	s.writeConfig("log_level: debug\nprompts_dir: /elsewhere\n")
+	_, err := promptsServer.ReloadConfig()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), slog.LevelDebug, logLevel.Level())
+	assert.Contains(s.T(), logs.String(), "The prompts directory cannot be changed without a restart")
+	assert.Contains(s.T(), logs.String(), "Config reloaded")
+	assert.Equal(s.T(), s.promptsDir, promptsServer.promptsDir)
+
+	withoutConfig, err := NewPromptsServer(s.promptsDir, WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(withoutConfig.Close()) }()
+	_, err = withoutConfig.ReloadConfig()
+	assert.EqualError(s.T(), err, "the server was started without --config")
+}
+
+// TestLoadRuntimeConfig tests the parsing of the config file
+func (s *RuntimeConfigTestSuite) TestLoadRuntimeConfig() {
+	s.writeConfig(strings.Join([]string{
+		"log_level: ERROR", "max_arg_key_length: 10", "max_args_size: 200", "max_json_depth: 3",
+		"max_output_size: 300", "render_timeout: 2s", "",
+	}, "\n"))
+	cfg, err := loadRuntimeConfig(s.configPath)
+	require.NoError(s.T(), err)
+	settings := cfg.apply(runtimeSettings{logLevel: slog.LevelInfo})
+	assert.Equal(s.T(), slog.LevelError, settings.logLevel)
+	assert.Equal(s.T(), ArgsLimits{MaxKeyLength: 10, MaxTotalSize: 200, MaxJSONDepth: 3}, settings.argsLimits)
+	assert.Equal(s.T(), RenderSettings{MaxOutputSize: 300, Timeout: 2 * time.Second}, settings.renderSettings)
+}
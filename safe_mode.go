@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Serve command flags that safe mode constrains.
+const (
+	flagMaxArgKeyLength = "max-arg-key-length"
+	flagMaxArgsSize     = "max-args-size"
+	flagMaxJSONDepth    = "max-json-depth"
+	flagMaxOutputSize   = "max-output-size"
+	flagMaxRenderCost   = "max-render-cost"
+	flagRenderTimeout   = "render-timeout"
+	flagAllowSampling   = "allow-sampling"
+)
+
+// SafeRenderSettings returns the conservative render settings enforced by safe mode.
+func SafeRenderSettings() RenderSettings {
+	return RenderSettings{
+		DisableEnvArgs: true,
+		MaxOutputSize:  256 << 10,
+		MaxRenderCost:  1_000_000,
+		Timeout:        5 * time.Second,
+		Provenance:     true,
+	}
+}
+
+// applySafeMode composes the strictest settings for serving untrusted prompt directories.
+// Safe mode is purely additive: explicitly set flags may tighten the safe defaults, but any attempt
+// to loosen or disable them is an error. It returns the effective settings and a description of
+// every relaxation that safe mode turned off.
+func applySafeMode(
+	argsLimits ArgsLimits, renderSettings RenderSettings, isSet func(flagName string) bool,
+) (ArgsLimits, RenderSettings, []string, error) {
+	safeArgs := DefaultArgsLimits()
+	safeRender := SafeRenderSettings()
+
+	limits := []struct {
+		flagName  string
+		value     *int
+		safeValue int
+	}{
+		{flagMaxArgKeyLength, &argsLimits.MaxKeyLength, safeArgs.MaxKeyLength},
+		{flagMaxArgsSize, &argsLimits.MaxTotalSize, safeArgs.MaxTotalSize},
+		{flagMaxJSONDepth, &argsLimits.MaxJSONDepth, safeArgs.MaxJSONDepth},
+		{flagMaxOutputSize, &renderSettings.MaxOutputSize, safeRender.MaxOutputSize},
+		{flagMaxRenderCost, &renderSettings.MaxRenderCost, safeRender.MaxRenderCost},
+	}
+	for _, limit := range limits {
+		if !isSet(limit.flagName) {
+			*limit.value = limit.safeValue
+			continue
+		}
+		if *limit.value <= 0 || *limit.value > limit.safeValue {
+			return ArgsLimits{}, RenderSettings{}, nil, fmt.Errorf(
+				"--%s=%d would weaken safe mode (must be between 1 and %d)", limit.flagName, *limit.value, limit.safeValue)
+		}
+	}
+
+	if !isSet(flagRenderTimeout) {
+		renderSettings.Timeout = safeRender.Timeout
+	} else if renderSettings.Timeout <= 0 || renderSettings.Timeout > safeRender.Timeout {
+		return ArgsLimits{}, RenderSettings{}, nil, fmt.Errorf(
+			"--%s=%s would weaken safe mode (must be positive and at most %s)",
+			flagRenderTimeout, renderSettings.Timeout, safeRender.Timeout)
+	}
+
+	if renderSettings.AllowSampling {
+		return ArgsLimits{}, RenderSettings{}, nil, fmt.Errorf("--%s cannot be combined with safe mode", flagAllowSampling)
+	}
+
+	renderSettings.DisableEnvArgs = true
+	renderSettings.Provenance = true
+
+	disabled := []string{
+		"environment variable fallback for prompt arguments",
+		"client sampling from template helpers",
+		fmt.Sprintf("argument names longer than %d bytes", argsLimits.MaxKeyLength),
+		fmt.Sprintf("argument payloads larger than %d bytes", argsLimits.MaxTotalSize),
+		fmt.Sprintf("JSON argument nesting deeper than %d levels", argsLimits.MaxJSONDepth),
+		fmt.Sprintf("rendered output larger than %d bytes", renderSettings.MaxOutputSize),
+		fmt.Sprintf("nested ranges over arguments estimated at more than %d iterations", renderSettings.MaxRenderCost),
+		fmt.Sprintf("renders running longer than %s", renderSettings.Timeout),
+	}
+	return argsLimits, renderSettings, disabled, nil
+}
+
+// checkSafeModeConfig checks that the runtime config keeps the limits of safe mode: like the flags, its settings
+// may tighten them but not loosen or disable them.
+func checkSafeModeConfig(cfg *RuntimeConfig) error {
+	safeArgs := DefaultArgsLimits()
+	safeRender := SafeRenderSettings()
+	for _, limit := range []struct {
+		name      string
+		value     *int
+		safeValue int
+	}{
+		{"max_arg_key_length", cfg.MaxArgKeyLength, safeArgs.MaxKeyLength},
+		{"max_args_size", cfg.MaxArgsSize, safeArgs.MaxTotalSize},
+		{"max_json_depth", cfg.MaxJSONDepth, safeArgs.MaxJSONDepth},
+		{"max_output_size", cfg.MaxOutputSize, safeRender.MaxOutputSize},
+		{"max_render_cost", cfg.MaxRenderCost, safeRender.MaxRenderCost},
+	} {
+		if limit.value != nil && (*limit.value <= 0 || *limit.value > limit.safeValue) {
+			return fmt.Errorf("%s: %d would weaken safe mode (must be between 1 and %d)", limit.name, *limit.value, limit.safeValue)
+		}
+	}
+	if cfg.RenderTimeout != nil && (*cfg.RenderTimeout <= 0 || *cfg.RenderTimeout > safeRender.Timeout) {
+		return fmt.Errorf("render_timeout: %s would weaken safe mode (must be positive and at most %s)",
+			*cfg.RenderTimeout, safeRender.Timeout)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type SafeModeTestSuite struct {
+	suite.Suite
+}
+
+func TestSafeModeTestSuite(t *testing.T) {
+	suite.Run(t, new(SafeModeTestSuite))
+}
+
+// TestApplySafeMode tests composition of safe mode settings and rejection of weakening flags
+func (s *SafeModeTestSuite) TestApplySafeMode() {
+	tests := []struct {
+		name             string
+		argsLimits       ArgsLimits
+		renderSettings   RenderSettings
+		setFlags         []string
+		expectedArgs     ArgsLimits
+		expectedSettings RenderSettings
+		expectedErr      string
+	}{
+		{
+			name:             "defaults replaced by safe values",
+			argsLimits:       ArgsLimits{},
+			renderSettings:   RenderSettings{},
+			expectedArgs:     DefaultArgsLimits(),
+			expectedSettings: SafeRenderSettings(),
+		},
+		{
+			name:           "explicit tighter limits are kept",
+			argsLimits:     ArgsLimits{MaxKeyLength: 64, MaxTotalSize: 4096, MaxJSONDepth: 4},
+			renderSettings: RenderSettings{MaxOutputSize: 1024, MaxRenderCost: 1000, Timeout: time.Second},
+			setFlags: []string{
+				flagMaxArgKeyLength, flagMaxArgsSize, flagMaxJSONDepth, flagMaxOutputSize, flagMaxRenderCost, flagRenderTimeout,
+			},
+			expectedArgs: ArgsLimits{MaxKeyLength: 64, MaxTotalSize: 4096, MaxJSONDepth: 4},
+			expectedSettings: RenderSettings{
+				DisableEnvArgs: true, MaxOutputSize: 1024, MaxRenderCost: 1000, Timeout: time.Second, Provenance: true,
+			},
+		},
+		{
+			name:        "disabling a limit is rejected",
+			argsLimits:  ArgsLimits{MaxTotalSize: 0},
+			setFlags:    []string{flagMaxArgsSize},
+			expectedErr: "--max-args-size=0 would weaken safe mode",
+		},
+		{
+			name:        "loosening a limit is rejected",
+			argsLimits:  ArgsLimits{MaxJSONDepth: 100},
+			setFlags:    []string{flagMaxJSONDepth},
+			expectedErr: "--max-json-depth=100 would weaken safe mode",
+		},
+		{
+			name:           "disabling output limit is rejected",
+			renderSettings: RenderSettings{MaxOutputSize: 0},
+			setFlags:       []string{flagMaxOutputSize},
+			expectedErr:    "--max-output-size=0 would weaken safe mode",
+		},
+		{
+			name:           "disabling render cost limit is rejected",
+			renderSettings: RenderSettings{MaxRenderCost: 0},
+			setFlags:       []string{flagMaxRenderCost},
+			expectedErr:    "--max-render-cost=0 would weaken safe mode",
+		},
+		{
+			name:           "allowing sampling is rejected",
+			renderSettings: RenderSettings{AllowSampling: true},
+			setFlags:       []string{flagAllowSampling},
+			expectedErr:    "--allow-sampling cannot be combined with safe mode",
+		},
+		{
+			name:           "loosening render timeout is rejected",
+			renderSettings: RenderSettings{Timeout: time.Minute},
+			setFlags:       []string{flagRenderTimeout},
+			expectedErr:    "--render-timeout=1m0s would weaken safe mode",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			isSet := func(flagName string) bool { return slices.Contains(tt.setFlags, flagName) }
+			argsLimits, renderSettings, disabled, err := applySafeMode(tt.argsLimits, tt.renderSettings, isSet)
+			if tt.expectedErr != "" {
+				require.Error(s.T(), err)
+				assert.Contains(s.T(), err.Error(), tt.expectedErr)
+				return
+			}
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), tt.expectedArgs, argsLimits)
+			assert.Equal(s.T(), tt.expectedSettings, renderSettings)
+			assert.Contains(s.T(), disabled, "environment variable fallback for prompt arguments")
+		})
+	}
+}
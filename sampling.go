@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const summarizeFuncName = "summarize"
+
+// truncateSummary is the fallback of the summarize helper used when sampling is unavailable:
+// it shortens text to roughly maxTokens tokens using the chars/4 heuristic.
+func truncateSummary(value interface{}, maxTokens int) string {
+	text := strings.TrimSpace(stringify(value))
+	maxChars := maxTokens * 4
+	if maxChars <= 0 {
+		return ""
+	}
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:maxChars])) + "…"
+}
+
+// makeSamplingSummarizeFunc returns a summarize helper bound to the session of the current request.
+// If the client advertised the sampling capability, the text is summarized by the client's model
+// with a bounded token limit; otherwise, or if sampling fails, the text is truncated.
+func makeSamplingSummarizeFunc(
+	ctx context.Context, mcpServer *server.MCPServer, logger *slog.Logger,
+) func(value interface{}, maxTokens int) string {
+	return func(value interface{}, maxTokens int) string {
+		if !clientSupportsSampling(ctx) {
+			logger.Info("Client does not support sampling, truncating text for summarize")
+			return truncateSummary(value, maxTokens)
+		}
+
+		text := strings.TrimSpace(stringify(value))
+		result, err := mcpServer.RequestSampling(ctx, mcp.CreateMessageRequest{
+			CreateMessageParams: mcp.CreateMessageParams{
+				Messages: []mcp.SamplingMessage{{
+					Role:    mcp.RoleUser,
+					Content: mcp.NewTextContent(text),
+				}},
+				SystemPrompt: fmt.Sprintf(
+					"Summarize the user's text in at most %d tokens. Reply with the summary only.", maxTokens),
+				MaxTokens: maxTokens,
+			},
+		})
+		if err != nil {
+			logger.Warn("Sampling request failed, truncating text for summarize", "error", err)
+			return truncateSummary(value, maxTokens)
+		}
+		summary, ok := samplingResultText(result.Content)
+		if !ok {
+			logger.Warn("Sampling returned non-text content, truncating text for summarize")
+			return truncateSummary(value, maxTokens)
+		}
+		return strings.TrimSpace(summary)
+	}
+}
+
+// samplingResultText extracts the text of a sampling result.
+// Results received over the wire carry their content as a generic JSON object.
+func samplingResultText(content interface{}) (string, bool) {
+	if contentMap, ok := content.(map[string]interface{}); ok {
+		parsed, err := mcp.ParseContent(contentMap)
+		if err != nil {
+			return "", false
+		}
+		content = parsed
+	}
+	textContent, ok := mcp.AsTextContent(content)
+	if !ok {
+		return "", false
+	}
+	return textContent.Text, true
+}
+
+// clientSupportsSampling reports whether the client of the current session advertised the sampling capability.
+func clientSupportsSampling(ctx context.Context) bool {
+	session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithClientInfo)
+	if !ok {
+		return false
+	}
+	return session.GetClientCapabilities().Sampling != nil
+}
+
+// maxConcurrentStdioPrompts bounds the prompt requests handled concurrently over stdio when sampling is allowed.
+const maxConcurrentStdioPrompts = 32
+
+// listenStdio serves the MCP protocol over stdio. When sampling is allowed, prompt requests are
+// dispatched concurrently: the stdio transport handles prompts/get synchronously, so a prompt handler
+// waiting for a sampling response would otherwise block the very loop that has to read that response.
+// It returns once the prompt requests in flight are answered.
+func (ps *PromptsServer) listenStdio(ctx context.Context, stdin io.Reader, stdout io.Writer) error {
+	stdioServer := server.NewStdioServer(ps.mcpServer)
+	if !ps.renderSettings().AllowSampling {
+		return stdioServer.Listen(ctx, stdin, stdout)
+	}
+
+	sessionCtxChan := make(chan context.Context, 1)
+	server.WithStdioContextFunc(func(sessionCtx context.Context) context.Context {
+		sessionCtxChan <- sessionCtx
+		return sessionCtx
+	})(stdioServer)
+
+	// The responses of the prompt handlers, and the responses, notifications, and sampling requests
+	// of the stdio server, are written whole, one at a time
+	stdout = &lockedWriter{w: stdout}
+	requests := &stdioPromptRequests{slots: make(chan struct{}, maxConcurrentStdioPrompts)}
+	pipeReader, pipeWriter := io.Pipe()
+	go ps.dispatchStdioInput(ctx, stdin, pipeWriter, stdout, sessionCtxChan, requests)
+	err := stdioServer.Listen(ctx, pipeReader, stdout)
+	requests.wait()
+	return err
+}
+
+// dispatchStdioInput handles prompts/get requests from stdin in their own goroutines
+// and forwards every other message to the stdio server.
+func (ps *PromptsServer) dispatchStdioInput(
+	ctx context.Context, stdin io.Reader, forward *io.PipeWriter, stdout io.Writer,
+	sessionCtxChan <-chan context.Context, requests *stdioPromptRequests,
+) {
+	var sessionCtx context.Context
+	select {
+	case sessionCtx = <-sessionCtxChan:
+	case <-ctx.Done():
+		_ = forward.CloseWithError(ctx.Err())
+		return
+	}
+
+	reader := bufio.NewReader(stdin)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var message struct {
+				ID     mcp.RequestId `json:"id"`
+				Method string        `json:"method"`
+			}
+			if json.Unmarshal(line, &message) == nil && message.Method == string(mcp.MethodPromptsGet) {
+				if !requests.start(func() { ps.handleStdioPromptRequest(sessionCtx, line, stdout) }) {
+					ps.writeStdioResponse(stdout, mcp.NewJSONRPCError(message.ID, mcp.INTERNAL_ERROR,
+						fmt.Sprintf("too many concurrent prompt requests (at most %d)", maxConcurrentStdioPrompts), nil))
+				}
+			} else if _, writeErr := forward.Write(line); writeErr != nil {
+				return
+			}
+		}
+		if err != nil {
+			_ = forward.CloseWithError(err)
+			return
+		}
+	}
+}
+
+func (ps *PromptsServer) handleStdioPromptRequest(ctx context.Context, message []byte, stdout io.Writer) {
+	if response := ps.mcpServer.HandleMessage(ctx, message); response != nil {
+		ps.writeStdioResponse(stdout, response)
+	}
+}
+
+func (ps *PromptsServer) writeStdioResponse(stdout io.Writer, response mcp.JSONRPCMessage) {
+	responseBytes, err := json.Marshal(response)
+	if err != nil {
+		ps.logger.Error("Failed to marshal prompt response", "error", err)
+		return
+	}
+	if _, err = stdout.Write(append(responseBytes, '\n')); err != nil {
+		ps.logger.Error("Failed to write prompt response", "error", err)
+	}
+}
+
+// stdioPromptRequests runs the prompt requests handled concurrently over stdio, at most as many as it has slots.
+type stdioPromptRequests struct {
+	mu     sync.Mutex
+	closed bool
+	slots  chan struct{}
+	wg     sync.WaitGroup
+}
+
+// start runs handle in its own goroutine. It returns false if all slots are taken or the requests were closed.
+// The dispatch loop must not wait for a slot: the running requests may wait for sampling responses it reads.
+func (r *stdioPromptRequests) start(handle func()) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return false
+	}
+	select {
+	case r.slots <- struct{}{}:
+	default:
+		return false
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer func() { <-r.slots }()
+		handle()
+	}()
+	return true
+}
+
+// wait stops starting requests and waits for the running ones.
+func (r *stdioPromptRequests) wait() {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+// lockedWriter serializes the writes to the underlying writer, so that concurrent messages do not interleave.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	schemaFileExt  = ".schema.json"
+	schemaFuncName = "schema"
+)
+
+// schemaTypes are the type names allowed by JSON Schema.
+var schemaTypes = []string{"array", "boolean", "integer", "null", "number", "object", "string"}
+
+// PromptSchema is the optional "<name>.schema.json" sidecar of a template describing the JSON reply
+// the prompt asks the model for.
+type PromptSchema struct {
+	content map[string]interface{}
+	pretty  string
+}
+
+// schemaFilePath returns the path of the schema sidecar of the given template.
+func schemaFilePath(promptsDir string, templateName string) string {
+	return filepath.Join(promptsDir, strings.TrimSuffix(templateName, templateExt)+schemaFileExt)
+}
+
+// isSchemaFile reports whether the file name is a schema sidecar.
+func isSchemaFile(fileName string) bool {
+	return strings.HasSuffix(fileName, schemaFileExt)
+}
+
+// loadPromptSchema reads and validates the schema sidecar of the given template.
+// It returns nil without an error if the template has no sidecar.
+func loadPromptSchema(promptsDir string, templateName string) (*PromptSchema, error) {
+	content, err := os.ReadFile(schemaFilePath(promptsDir, templateName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read schema: %w", err)
+	}
+	var schema map[string]interface{}
+	if err = json.Unmarshal(content, &schema); err != nil {
+		return nil, fmt.Errorf("parse schema: %w", err)
+	}
+	if schema == nil {
+		return nil, fmt.Errorf("invalid schema: must be a JSON object")
+	}
+	if err = validateSchemaNode(schema, "#"); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	var pretty bytes.Buffer
+	if err = json.Indent(&pretty, bytes.TrimSpace(content), "", "  "); err != nil {
+		return nil, fmt.Errorf("format schema: %w", err)
+	}
+	return &PromptSchema{content: schema, pretty: pretty.String()}, nil
+}
+
+// validateSchemaNode performs a basic structural check of a JSON Schema node:
+// keywords that hold subschemas or names must have the expected JSON types.
+func validateSchemaNode(node map[string]interface{}, path string) error {
+	if typ, ok := node["type"]; ok {
+		var typeNames []interface{}
+		switch t := typ.(type) {
+		case string:
+			typeNames = []interface{}{t}
+		case []interface{}:
+			typeNames = t
+		default:
+			return fmt.Errorf("%s/type must be a string or an array of strings", path)
+		}
+		for _, typeName := range typeNames {
+			if name, isString := typeName.(string); !isString || !slices.Contains(schemaTypes, name) {
+				return fmt.Errorf("%s/type has unknown type %v", path, typeName)
+			}
+		}
+	}
+
+	if properties, ok := node["properties"]; ok {
+		propertiesMap, isObject := properties.(map[string]interface{})
+		if !isObject {
+			return fmt.Errorf("%s/properties must be an object", path)
+		}
+		for name, property := range propertiesMap {
+			if err := validateSubschema(property, path+"/properties/"+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if required, ok := node["required"]; ok {
+		names, isArray := required.([]interface{})
+		if !isArray {
+			return fmt.Errorf("%s/required must be an array of strings", path)
+		}
+		for _, name := range names {
+			if _, isString := name.(string); !isString {
+				return fmt.Errorf("%s/required must be an array of strings", path)
+			}
+		}
+	}
+
+	if items, ok := node["items"]; ok {
+		if itemsList, isArray := items.([]interface{}); isArray {
+			for i, item := range itemsList {
+				if err := validateSubschema(item, fmt.Sprintf("%s/items/%d", path, i)); err != nil {
+					return err
+				}
+			}
+		} else if err := validateSubschema(items, path+"/items"); err != nil {
+			return err
+		}
+	}
+
+	if enum, ok := node["enum"]; ok {
+		if _, isArray := enum.([]interface{}); !isArray {
+			return fmt.Errorf("%s/enum must be an array", path)
+		}
+	}
+	return nil
+}
+
+func validateSubschema(value interface{}, path string) error {
+	switch v := value.(type) {
+	case bool:
+		return nil
+	case map[string]interface{}:
+		return validateSchemaNode(v, path)
+	default:
+		return fmt.Errorf("%s must be a schema object", path)
+	}
+}
+
+// PropertyNames returns the sorted names of all properties declared anywhere in the schema.
+func (s *PromptSchema) PropertyNames() []string {
+	if s == nil {
+		return nil
+	}
+	namesMap := make(map[string]struct{})
+	collectSchemaPropertyNames(s.content, namesMap)
+	names := make([]string, 0, len(namesMap))
+	for name := range namesMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func collectSchemaPropertyNames(value interface{}, names map[string]struct{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if properties, ok := v["properties"].(map[string]interface{}); ok {
+			for name := range properties {
+				names[name] = struct{}{}
+			}
+		}
+		for _, child := range v {
+			collectSchemaPropertyNames(child, names)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectSchemaPropertyNames(child, names)
+		}
+	}
+}
+
+// MissingProperties returns the schema property names that do not appear in the rendered output.
+func (s *PromptSchema) MissingProperties(output string) []string {
+	var missing []string
+	for _, name := range s.PropertyNames() {
+		if !strings.Contains(output, name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// makeSchemaFunc returns the schema helper that inlines the pretty-printed schema sidecar of a template.
+// Sidecars are read once, when the prompts directory is parsed.
+func makeSchemaFunc(promptsDir string) (func(templateName string) (string, error), error) {
+	files, err := os.ReadDir(promptsDir)
+	if err != nil {
+		return nil, fmt.Errorf("read prompts directory: %w", err)
+	}
+	schemas := make(map[string]string)
+	schemaErrs := make(map[string]error)
+	for _, file := range files {
+		if file.IsDir() || !isSchemaFile(file.Name()) {
+			continue
+		}
+		templateName := strings.TrimSuffix(file.Name(), schemaFileExt)
+		schema, loadErr := loadPromptSchema(promptsDir, templateName)
+		if loadErr != nil {
+			schemaErrs[templateName] = loadErr
+			continue
+		}
+		if schema == nil {
+			continue
+		}
+		schemas[templateName] = schema.pretty
+	}
+
+	return func(templateName string) (string, error) {
+		templateName = strings.TrimSuffix(templateName, templateExt)
+		if schemaErr, ok := schemaErrs[templateName]; ok {
+			return "", fmt.Errorf("schema of %q: %w", templateName, schemaErr)
+		}
+		schema, ok := schemas[templateName]
+		if !ok {
+			return "", fmt.Errorf("no schema file %s", templateName+schemaFileExt)
+		}
+		return schema, nil
+	}, nil
+}
+
+// checkRenderedSchema verifies that the rendered output of a template mentions every property of its schema sidecar.
+func checkRenderedSchema(promptsDir string, templateName string, output string) error {
+	schema, err := loadPromptSchema(promptsDir, templateName)
+	if err != nil {
+		return err
+	}
+	if schema == nil {
+		return fmt.Errorf("no schema file %s", filepath.Base(schemaFilePath(promptsDir, templateName)))
+	}
+	if missing := schema.MissingProperties(output); len(missing) > 0 {
+		return fmt.Errorf("rendered output does not mention schema properties: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type SchemaTestSuite struct {
+	suite.Suite
+	tempDir string
+}
+
+func TestSchemaTestSuite(t *testing.T) {
+	suite.Run(t, new(SchemaTestSuite))
+}
+
+func (s *SchemaTestSuite) SetupTest() {
+	s.tempDir = s.T().TempDir()
+}
+
+func (s *SchemaTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.tempDir, name), []byte(content), 0644))
+}
+
+// TestSchemaHelperInlinesSidecar tests that the schema helper inlines the pretty-printed sidecar
+func (s *SchemaTestSuite) TestSchemaHelperInlinesSidecar() {
+	s.writeFile("review.tmpl", "{{/* Review */}}\nReply with JSON matching:\n{{schema \"review\"}}")
+	s.writeFile("review.schema.json", `{"type":"object","properties":{"verdict":{"type":"string"}},"required":["verdict"]}`)
+
+	var buf bytes.Buffer
+	err := renderTemplate(&buf, s.tempDir, nil, "review", nil, true)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), `Reply with JSON matching:
+{
+  "type": "object",
+  "properties": {
+    "verdict": {
+      "type": "string"
+    }
+  },
+  "required": [
+    "verdict"
+  ]
+}`, buf.String())
+
+	s.Run("missing sidecar", func() {
+		s.writeFile("other.tmpl", `{{schema "other"}}`)
+		err := renderTemplate(&bytes.Buffer{}, s.tempDir, nil, "other", nil, true)
+		require.Error(s.T(), err)
+		assert.Contains(s.T(), err.Error(), "no schema file other.schema.json")
+	})
+}
+
+// TestRenderCheckSchema tests that render --check-schema detects schema properties missing from the output
+func (s *SchemaTestSuite) TestRenderCheckSchema() {
+	s.writeFile("review.tmpl", "{{/* Review */}}\nReturn \"verdict\" and \"comments\".")
+	s.writeFile("review.schema.json", `{
+		"type": "object",
+		"properties": {
+			"verdict": {"type": "string"},
+			"comments": {"type": "array", "items": {"type": "object", "properties": {"line": {"type": "integer"}}}}
+		}
+	}`)
+
+	runRender := func() (string, error) {
+		var buf bytes.Buffer
+		app := newApp()
+		app.Writer = &buf
+		err := app.Run(context.Background(), []string{app.Name, "render", s.tempDir, "review", "--check-schema"})
+		return buf.String(), err
+	}
+
+	_, err := runRender()
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "rendered output does not mention schema properties: line")
+
+	s.writeFile("review.tmpl", "{{/* Review */}}\nReturn \"verdict\" and \"comments\" with a \"line\" each.")
+	output, err := runRender()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), `Return "verdict" and "comments" with a "line" each.`, output)
+
+	s.Run("no sidecar", func() {
+		s.writeFile("plain.tmpl", "Hello")
+		err := checkRenderedSchema(s.tempDir, "plain.tmpl", "Hello")
+		require.Error(s.T(), err)
+		assert.Contains(s.T(), err.Error(), "no schema file plain.schema.json")
+	})
+}
+
+// TestInvalidSchema tests the structural checks of schema sidecars
+func (s *SchemaTestSuite) TestInvalidSchema() {
+	tests := []struct {
+		name        string
+		content     string
+		expectedErr string
+	}{
+		{
+			name:        "invalid JSON",
+			content:     `{"type": "object",`,
+			expectedErr: "parse schema",
+		},
+		{
+			name:        "not an object",
+			content:     `["type"]`,
+			expectedErr: "parse schema",
+		},
+		{
+			name:        "unknown type",
+			content:     `{"type": "strnig"}`,
+			expectedErr: "#/type has unknown type strnig",
+		},
+		{
+			name:        "properties not an object",
+			content:     `{"properties": ["a"]}`,
+			expectedErr: "#/properties must be an object",
+		},
+		{
+			name:        "nested property not a schema",
+			content:     `{"properties": {"a": {"properties": {"b": "string"}}}}`,
+			expectedErr: "#/properties/a/properties/b must be a schema object",
+		},
+		{
+			name:        "required not strings",
+			content:     `{"required": [1]}`,
+			expectedErr: "#/required must be an array of strings",
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.writeFile("reply.tmpl", "{{/* Reply */}}\nReply")
+			s.writeFile("reply.schema.json", tt.content)
+			_, err := loadPromptSchema(s.tempDir, "reply.tmpl")
+			require.Error(s.T(), err)
+			assert.Contains(s.T(), err.Error(), tt.expectedErr)
+
+			var buf bytes.Buffer
+			err = validateTemplates(&buf, s.tempDir, nil, "reply", validateOptions{})
+			require.Error(s.T(), err)
+			assert.Contains(s.T(), buf.String(), "reply.schema.json")
+			assert.Contains(s.T(), buf.String(), tt.expectedErr)
+		})
+	}
+}
+
+// TestListVerboseShowsSchema tests that list --verbose indicates which templates have a schema sidecar
+func (s *SchemaTestSuite) TestListVerboseShowsSchema() {
+	s.writeFile("review.tmpl", "{{/* Review */}}\n{{schema \"review\"}}")
+	s.writeFile("review.schema.json", `{"type": "object"}`)
+	s.writeFile("plain.tmpl", "{{/* Plain */}}\nHello")
+
+	var buf bytes.Buffer
+	err := listTemplates(&buf, s.tempDir, listOptions{verbose: true})
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), buf.String(), "Schema: review.schema.json")
+	assert.Equal(s.T(), 1, bytes.Count(buf.Bytes(), []byte("Schema:")))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"text/template"
+)
+
+// Names of the scratchpad helpers, which share values between a template and its partials during one render.
+const (
+	scratchSetFuncName = "scratchSet"
+	scratchGetFuncName = "scratchGet"
+)
+
+// errScratchpadUnbound is returned by the scratchpad helpers of a template that was not bound to a render.
+var errScratchpadUnbound = errors.New("scratchpad is not available outside of a render")
+
+// unboundScratchFuncs returns the placeholders of the scratchpad helpers, registered so that templates parse.
+// Every render replaces them with makeScratchFuncs.
+func unboundScratchFuncs() template.FuncMap {
+	return template.FuncMap{
+		scratchSetFuncName: func(string, interface{}) (string, error) { return "", errScratchpadUnbound },
+		scratchGetFuncName: func(string) (interface{}, error) { return nil, errScratchpadUnbound },
+	}
+}
+
+// makeScratchFuncs returns the scratchpad helpers bound to a new, empty scratchpad.
+// scratchSet stores a value and renders nothing; scratchGet returns the stored value, or nil if there is none.
+// A scratchpad belongs to a single render, which executes sequentially, so it needs no locking.
+func makeScratchFuncs() template.FuncMap {
+	values := make(map[string]interface{})
+	return template.FuncMap{
+		scratchSetFuncName: func(key string, value interface{}) string {
+			values[key] = value
+			return ""
+		},
+		scratchGetFuncName: func(key string) interface{} {
+			return values[key]
+		},
+	}
+}
+
+// bindScratchpad returns a clone of the template whose scratchpad helpers use a new scratchpad.
+func bindScratchpad(tmpl *template.Template) (*template.Template, error) {
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("clone template: %w", err)
+	}
+	return clone.Funcs(makeScratchFuncs()), nil
+}
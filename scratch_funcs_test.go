@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ScratchFuncsTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestScratchFuncsTestSuite(t *testing.T) {
+	suite.Run(t, new(ScratchFuncsTestSuite))
+}
+
+func (s *ScratchFuncsTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("_branch.tmpl", `{{define "_branch"}}Branch: feature/{{scratchGet "slug"}}{{end}}`)
+	s.writeFile("task.tmpl", "{{/* Task */}}\n{{scratchSet \"slug\" (slugify .title)}}"+
+		"Title: {{.title}}\n{{template \"_branch\" .}}\nID: {{scratchGet \"slug\"}}")
+}
+
+func (s *ScratchFuncsTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+// getPromptText renders the prompt through the MCP server and returns the text of its message
+func (s *ScratchFuncsTestSuite) getPromptText(promptsServer *PromptsServer, name string, args map[string]string) string {
+	request, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0", "id": 1, "method": "prompts/get",
+		"params": map[string]any{"name": name, "arguments": args},
+	})
+	require.NoError(s.T(), err)
+	response, err := json.Marshal(promptsServer.mcpServer.HandleMessage(context.Background(), request))
+	require.NoError(s.T(), err)
+	var decoded struct {
+		Result struct {
+			Messages []struct {
+				Content struct {
+					Text string `json:"text"`
+				} `json:"content"`
+			} `json:"messages"`
+		} `json:"result"`
+	}
+	require.NoError(s.T(), json.Unmarshal(response, &decoded))
+	require.Len(s.T(), decoded.Result.Messages, 1, "response: %s", response)
+	return decoded.Result.Messages[0].Content.Text
+}
+
+// TestSharedWithPartials tests that values stored by the root template are visible in partials
+func (s *ScratchFuncsTestSuite) TestSharedWithPartials() {
+	var buf bytes.Buffer
+	err := renderTemplate(&buf, s.promptsDir, nil, "task", map[string]string{"title": "Fix: Login bug!"}, true)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Title: Fix: Login bug!\nBranch: feature/fix-login-bug\nID: fix-login-bug", buf.String())
+
+	text, err := RenderTemplateFromStrings(map[string]string{
+		"_branch.tmpl": `{{define "_branch"}}{{scratchSet "branch" (print "feature/" (slugify .title))}}{{end}}`,
+		"task.tmpl":    `{{template "_branch" .}}{{scratchGet "branch"}}`,
+	}, "task", map[string]string{"title": "Add Search"}, true)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "feature/add-search", text, "values stored by partials are visible to the caller")
+}
+
+// TestRangeBody tests that the scratchpad is usable and writable inside range bodies
+func (s *ScratchFuncsTestSuite) TestRangeBody() {
+	s.writeFile("steps.tmpl", "{{/* Steps */}}\n{{scratchSet \"slug\" (slugify .title)}}"+
+		"{{range $step := list \"plan\" \"build\"}}{{scratchGet \"slug\"}}/{{$step}} "+
+		"{{scratchSet \"last\" $step}}{{end}}last={{scratchGet \"last\"}}")
+
+	var buf bytes.Buffer
+	err := renderTemplate(&buf, s.promptsDir, nil, "steps", map[string]string{"title": "New API"}, true)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "new-api/plan new-api/build last=build", buf.String())
+}
+
+// TestKeysAreNotArguments tests that the extractor does not report scratchpad keys as prompt arguments
+func (s *ScratchFuncsTestSuite) TestKeysAreNotArguments() {
+	parser := &PromptsParser{}
+	tmpl, err := parser.ParseDir(s.promptsDir)
+	require.NoError(s.T(), err)
+	args, err := parser.ExtractPromptArgumentsFromTemplate(tmpl, "task.tmpl")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"title"}, args)
+}
+
+// TestUnboundScratchpad tests that a template executed without a bound scratchpad fails instead of sharing one
+func (s *ScratchFuncsTestSuite) TestUnboundScratchpad() {
+	parser := &PromptsParser{}
+	tmpl, err := parser.ParseDir(s.promptsDir)
+	require.NoError(s.T(), err)
+	err = tmpl.ExecuteTemplate(&bytes.Buffer{}, "task.tmpl", map[string]interface{}{"title": "x"})
+	assert.ErrorIs(s.T(), err, errScratchpadUnbound)
+}
+
+// TestConcurrentRequestsAreIsolated tests that concurrent requests never see each other's scratchpad
+func (s *ScratchFuncsTestSuite) TestConcurrentRequestsAreIsolated() {
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	const requests = 20
+	texts := make([]string, requests)
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			texts[i] = s.getPromptText(promptsServer, "task", map[string]string{"title": fmt.Sprintf("Task %d", i)})
+		}()
+	}
+	wg.Wait()
+
+	for i, text := range texts {
+		assert.Equal(s.T(), fmt.Sprintf("Title: Task %d\nBranch: feature/task-%d\nID: task-%d", i, i, i), text)
+	}
+}
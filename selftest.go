@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Self-test steps, in the order they run.
+const (
+	selfTestStepStart      = "start"
+	selfTestStepTemplate   = "load_template" // failed for each template file the server skipped
+	selfTestStepInitialize = "initialize"
+	selfTestStepList       = "list_prompts"
+	selfTestStepGet        = "get_prompt"
+)
+
+// SelfTestStep is the result of a single step of the self-test.
+type SelfTestStep struct {
+	Step       string  `json:"step"`
+	Prompt     string  `json:"prompt,omitempty"`    // requested prompt of a get_prompt step
+	Template   string  `json:"template,omitempty"`  // skipped template file of a load_template step
+	Arguments  string  `json:"arguments,omitempty"` // "synthetic" or the fixture file of the arguments
+	Passed     bool    `json:"passed"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// SelfTestReport is the result of the self-test.
+type SelfTestReport struct {
+	Passed bool           `json:"passed"`
+	Steps  []SelfTestStep `json:"steps"`
+}
+
+// Failed returns the number of failed steps.
+func (r *SelfTestReport) Failed() int {
+	failed := 0
+	for _, step := range r.Steps {
+		if !step.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// selfTestOptions configures runSelfTest.
+type selfTestOptions struct {
+	promptName string    // restrict the get_prompt phase to this prompt
+	serverOpts []Option  // options of the server, as the serve command would create it
+	nameStyle  NameStyle // name style of the server, to find the fixtures of the prompts
+}
+
+// runSelfTest serves the prompts directory in-process, connects an MCP client over a pipe,
+// and reports the result of initialize, ListPrompts, and a GetPrompt of every listed prompt.
+// Steps that cannot run because an earlier step failed are not reported.
+func runSelfTest(ctx context.Context, promptsDir string, opts selfTestOptions) *SelfTestReport {
+	report := &SelfTestReport{}
+	record := func(step SelfTestStep, started time.Time, err error) {
+		step.DurationMs = float64(time.Since(started).Microseconds()) / 1000
+		step.Passed = err == nil
+		if err != nil {
+			step.Error = err.Error()
+		}
+		report.Steps = append(report.Steps, step)
+	}
+	defer func() { report.Passed = report.Failed() == 0 }()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	started := time.Now()
+	promptsServer, err := NewPromptsServer(promptsDir, append(opts.serverOpts, WithWatchMode(WatchModeOff, 0))...)
+	record(SelfTestStep{Step: selfTestStepStart}, started, err)
+	if err != nil {
+		return report
+	}
+	defer func() { _ = promptsServer.Close() }()
+	for _, unknownFunc := range promptsServer.skippedTemplates() {
+		record(SelfTestStep{Step: selfTestStepTemplate, Template: unknownFunc.file}, started, unknownFunc)
+	}
+
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- promptsServer.ServeStdio(ctx, serverReader, serverWriter)
+	}()
+	defer func() {
+		cancel()
+		_ = clientWriter.Close()
+		_ = serverWriter.Close()
+		<-serveErr
+	}()
+
+	started = time.Now()
+	mcpClient, err := startSelfTestClient(ctx, clientReader, clientWriter)
+	record(SelfTestStep{Step: selfTestStepInitialize}, started, err)
+	if err != nil {
+		return report
+	}
+	defer func() { _ = mcpClient.Close() }()
+
+	started = time.Now()
+	listResult, err := mcpClient.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	if err == nil && len(listResult.Prompts) == 0 {
+		err = errors.New("the server lists no prompts")
+	}
+	record(SelfTestStep{Step: selfTestStepList}, started, err)
+	if err != nil {
+		return report
+	}
+
+	prompts := listResult.Prompts
+	if opts.promptName != "" {
+		prompts = nil
+		for _, prompt := range listResult.Prompts {
+			if prompt.Name == opts.promptName {
+				prompts = append(prompts, prompt)
+			}
+		}
+		if len(prompts) == 0 {
+			record(SelfTestStep{Step: selfTestStepGet, Prompt: opts.promptName}, time.Now(),
+				fmt.Errorf("prompt %q is not listed by the server", opts.promptName))
+			return report
+		}
+	}
+	sort.Slice(prompts, func(i, j int) bool { return prompts[i].Name < prompts[j].Name })
+
+	for _, prompt := range prompts {
+		step := SelfTestStep{Step: selfTestStepGet, Prompt: prompt.Name}
+		started = time.Now()
+		var args map[string]string
+		if args, step.Arguments, err = selfTestArgs(promptsDir, opts.nameStyle, prompt); err == nil {
+			err = getSelfTestPrompt(ctx, mcpClient, prompt.Name, args)
+		}
+		record(step, started, err)
+	}
+	return report
+}
+
+func startSelfTestClient(ctx context.Context, r io.Reader, w io.WriteCloser) (*client.Client, error) {
+	transp := transport.NewIO(r, w, io.NopCloser(nil))
+	if err := transp.Start(ctx); err != nil {
+		return nil, fmt.Errorf("start transport: %w", err)
+	}
+	mcpClient := client.NewClient(transp)
+	if err := mcpClient.Start(ctx); err != nil {
+		return nil, fmt.Errorf("start client: %w", err)
+	}
+	var initReq mcp.InitializeRequest
+	initReq.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initReq.Params.ClientInfo = mcp.Implementation{Name: "mcp-prompt-engine-selftest", Version: version}
+	if _, err := mcpClient.Initialize(ctx, initReq); err != nil {
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+	return mcpClient, nil
+}
+
+func getSelfTestPrompt(ctx context.Context, mcpClient *client.Client, promptName string, args map[string]string) error {
+	var getReq mcp.GetPromptRequest
+	getReq.Params.Name = promptName
+	getReq.Params.Arguments = args
+	result, err := mcpClient.GetPrompt(ctx, getReq)
+	if err != nil {
+		return err
+	}
+	if len(result.Messages) == 0 {
+		return errors.New("the result has no messages")
+	}
+	return nil
+}
+
+// selfTestArgs returns the arguments the self-test requests the prompt with: those of its first fixture case
+// if it has any (see the test command), or synthetic values otherwise.
+func selfTestArgs(promptsDir string, nameStyle NameStyle, prompt mcp.Prompt) (map[string]string, string, error) {
+	templateName, err := resolveTemplateName(promptsDir, prompt.Name, nameStyle)
+	if err != nil {
+		return nil, "", err
+	}
+	argsFiles, err := filepath.Glob(filepath.Join(defaultFixturesDir(promptsDir, templateName), "*"+fixtureArgsSuffix))
+	if err != nil {
+		return nil, "", fmt.Errorf("find fixtures: %w", err)
+	}
+	if len(argsFiles) > 0 {
+		sort.Strings(argsFiles)
+		args, err := loadFixtureArgs(argsFiles[0])
+		return args, filepath.Base(argsFiles[0]), err
+	}
+	argNames := make([]string, 0, len(prompt.Arguments))
+	for _, arg := range prompt.Arguments {
+		argNames = append(argNames, arg.Name)
+	}
+	return syntheticArgs(argNames), "synthetic", nil
+}
+
+// syntheticArgs returns a placeholder value for every argument, e.g. "example name" for name.
+// The values are plain strings, so they are passed as is even with JSON argument parsing.
+func syntheticArgs(argNames []string) map[string]string {
+	args := make(map[string]string, len(argNames))
+	for _, name := range argNames {
+		args[name] = "example " + name
+	}
+	return args
+}
+
+// printSelfTestReport writes the report as a line per step.
+func printSelfTestReport(w io.Writer, report *SelfTestReport) {
+	for _, step := range report.Steps {
+		name := step.Step
+		if step.Prompt != "" {
+			name += " " + templateText(step.Prompt)
+		}
+		if step.Template != "" {
+			name += " " + templateText(step.Template)
+		}
+		timing := fmt.Sprintf("(%.1fms)", step.DurationMs)
+		if step.Arguments != "" {
+			timing = fmt.Sprintf("(%.1fms, arguments: %s)", step.DurationMs, step.Arguments)
+		}
+		if step.Passed {
+			mustFprintf(w, "%s %s - %s %s\n", successIcon(), name, successText(localize("status.passed")), timing)
+		} else {
+			mustFprintf(w, "%s %s - %s %s\n", errorIcon(), name, errorText(localize("status.failed", step.Error)), timing)
+		}
+	}
+}
+
+// printSelfTestReportJSON writes the report as an indented JSON document.
+func printSelfTestReportJSON(w io.Writer, report *SelfTestReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("encode self-test report: %w", err)
+	}
+	return nil
+}
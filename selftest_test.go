@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type SelfTestTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestSelfTestTestSuite(t *testing.T) {
+	suite.Run(t, new(SelfTestTestSuite))
+}
+
+func (s *SelfTestTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("greeting.tmpl", "{{/* Greet */}}\nHello {{.name}}!")
+	s.writeFile("digest.tmpl", "{{/* Digest */}}\n{{range .items}}- {{.}}\n{{end}}")
+	s.writeFile(filepath.Join("fixtures", "digest", "basic"+fixtureArgsSuffix), `{"items": ["a", "b"]}`)
+}
+
+func (s *SelfTestTestSuite) writeFile(name, content string) {
+	path := filepath.Join(s.promptsDir, name)
+	require.NoError(s.T(), os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(s.T(), os.WriteFile(path, []byte(content), 0644))
+}
+
+func (s *SelfTestTestSuite) runSelfTest(args ...string) (string, error) {
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	err := app.Run(context.Background(), append([]string{app.Name, "--color", "never", "selftest"}, append(args, s.promptsDir)...))
+	return buf.String(), err
+}
+
+// TestSyntheticArgs tests the placeholder values of prompt arguments
+func (s *SelfTestTestSuite) TestSyntheticArgs() {
+	assert.Equal(s.T(), map[string]string{"name": "example name", "items": "example items"},
+		syntheticArgs([]string{"name", "items"}))
+	assert.Empty(s.T(), syntheticArgs(nil))
+}
+
+// TestAllStepsPass tests the report of a healthy prompts directory
+func (s *SelfTestTestSuite) TestAllStepsPass() {
+	output, err := s.runSelfTest()
+	require.NoError(s.T(), err)
+	lines := removeANSIColors(output)
+	assert.Regexp(s.T(), `^✓ start - Passed \([0-9.]+ms\)\n`+
+		`✓ initialize - Passed \([0-9.]+ms\)\n`+
+		`✓ list_prompts - Passed \([0-9.]+ms\)\n`+
+		`✓ get_prompt digest - Passed \([0-9.]+ms, arguments: basic\.args\.json\)\n`+
+		`✓ get_prompt greeting - Passed \([0-9.]+ms, arguments: synthetic\)\n$`, lines)
+}
+
+// TestBrokenPrompt tests that a prompt failing to render fails the self-test while the others still run
+func (s *SelfTestTestSuite) TestBrokenPrompt() {
+	s.writeFile("broken.tmpl", "{{/* Broken */}}\n{{call .handler}}")
+
+	output, err := s.runSelfTest()
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "1 of 6 self-test steps failed")
+	assert.Contains(s.T(), output, "✗ get_prompt broken - Failed:")
+	assert.Contains(s.T(), output, "✓ get_prompt greeting - Passed")
+
+	output, err = s.runSelfTest("--prompt", "greeting")
+	require.NoError(s.T(), err, "the broken prompt is not requested")
+	assert.NotContains(s.T(), output, "broken")
+
+	_, err = s.runSelfTest("--prompt", "missing")
+	require.Error(s.T(), err)
+}
+
+// TestInvalidTemplate tests that prompts that cannot be loaded fail the first step
+func (s *SelfTestTestSuite) TestInvalidTemplate() {
+	s.writeFile("invalid.tmpl", "{{/* Invalid */}}\n{{if .name}}")
+
+	output, err := s.runSelfTest()
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "1 of 1 self-test steps failed")
+	assert.Contains(s.T(), output, "✗ start - Failed:")
+	assert.NotContains(s.T(), output, "initialize")
+}
+
+// TestUnknownFunction tests that a template calling a helper of a newer server fails with an upgrade hint
+func (s *SelfTestTestSuite) TestUnknownFunction() {
+	s.writeFile("snippet.tmpl", "{{/* Snippet */}}\n{{codefence .code}}")
+
+	output, err := s.runSelfTest()
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "1 of 6 self-test steps failed")
+	assert.Contains(s.T(), output, "✗ load_template snippet.tmpl - Failed:")
+	assert.Contains(s.T(), output, `"codefence" is a helper of a newer version of the server, upgrade it to serve snippet.tmpl`)
+	assert.Contains(s.T(), output, "✓ get_prompt greeting - Passed")
+}
+
+// TestJSONReport tests the machine-readable report
+func (s *SelfTestTestSuite) TestJSONReport() {
+	output, err := s.runSelfTest("--json", "--prompt", "greeting", "--disable-env-args")
+	require.NoError(s.T(), err)
+
+	var report SelfTestReport
+	require.NoError(s.T(), json.Unmarshal([]byte(output), &report))
+	assert.True(s.T(), report.Passed)
+	require.Len(s.T(), report.Steps, 4)
+	step := report.Steps[3]
+	assert.Equal(s.T(), selfTestStepGet, step.Step)
+	assert.Equal(s.T(), "greeting", step.Prompt)
+	assert.Equal(s.T(), "synthetic", step.Arguments)
+	assert.True(s.T(), step.Passed)
+	assert.Empty(s.T(), step.Error)
+
+	s.writeFile("broken.tmpl", "{{/* Broken */}}\n{{call .handler}}")
+	output, err = s.runSelfTest("--json", "--prompt", "broken")
+	require.Error(s.T(), err)
+	require.NoError(s.T(), json.Unmarshal([]byte(output), &report))
+	assert.False(s.T(), report.Passed)
+	assert.Contains(s.T(), report.Steps[3].Error, "execute template")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"maps"
+	"regexp"
+	"strings"
+)
+
+// redactedValue replaces the values of sensitive arguments in logs and stored texts.
+const redactedValue = "[REDACTED]"
+
+// sensitiveArgs identifies the sensitive arguments of a prompt: those marked as sensitive in its frontmatter
+// and those whose names match the --sensitive-pattern. Their values are rendered normally, but every sink
+// that logs or stores arguments or rendered texts scrubs them first.
+type sensitiveArgs struct {
+	frontmatter *PromptFrontmatter
+	pattern     *regexp.Regexp // nil if no pattern is configured
+}
+
+// Contains reports whether the argument is sensitive.
+func (sa sensitiveArgs) Contains(name string) bool {
+	if sa.frontmatter != nil && sa.frontmatter.Arguments[name].Sensitive {
+		return true
+	}
+	return sa.pattern != nil && sa.pattern.MatchString(name)
+}
+
+// ContainsAny reports whether any of the arguments is sensitive.
+func (sa sensitiveArgs) ContainsAny(names []string) bool {
+	for _, name := range names {
+		if sa.Contains(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScrubArgs returns a copy of the arguments with the values of sensitive arguments redacted.
+// The given map is not modified.
+func (sa sensitiveArgs) ScrubArgs(args map[string]string) map[string]string {
+	if args == nil {
+		return nil
+	}
+	scrubbed := maps.Clone(args)
+	for name := range scrubbed {
+		if sa.Contains(name) {
+			scrubbed[name] = redactedValue
+		}
+	}
+	return scrubbed
+}
+
+// ScrubData returns a copy of the template data with the values of sensitive arguments redacted.
+func (sa sensitiveArgs) ScrubData(data map[string]interface{}) map[string]interface{} {
+	scrubbed := maps.Clone(data)
+	for name := range scrubbed {
+		if sa.Contains(name) {
+			scrubbed[name] = redactedValue
+		}
+	}
+	return scrubbed
+}
+
+// ScrubText returns the text with every occurrence of a value of a sensitive argument redacted.
+func (sa sensitiveArgs) ScrubText(text string, args ...map[string]string) string {
+	var oldNew []string
+	for _, values := range args {
+		for name, value := range values {
+			if value != "" && sa.Contains(name) {
+				oldNew = append(oldNew, value, redactedValue)
+			}
+		}
+	}
+	if len(oldNew) == 0 {
+		return text
+	}
+	return strings.NewReplacer(oldNew...).Replace(text)
+}
+
+// FingerprintArgs returns the arguments included in access log fingerprints: sensitive arguments are left out
+// unless their frontmatter explicitly includes them.
+func (sa sensitiveArgs) FingerprintArgs(args map[string]string) map[string]string {
+	if args == nil {
+		return nil
+	}
+	included := maps.Clone(args)
+	for name := range included {
+		if sa.Contains(name) && (sa.frontmatter == nil || !sa.frontmatter.Arguments[name].Fingerprint) {
+			delete(included, name)
+		}
+	}
+	return included
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type SensitiveArgsTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestSensitiveArgsTestSuite(t *testing.T) {
+	suite.Run(t, new(SensitiveArgsTestSuite))
+}
+
+func (s *SensitiveArgsTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("incident.tmpl", "---\narguments:\n  api_token:\n    sensitive: true\n---\n"+
+		"{{/* Draft an incident report */}}\nIncident {{.title}}: rotate token {{.api_token}}, database password {{.db_password}}")
+}
+
+func (s *SensitiveArgsTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+// TestScrub tests redacting, detecting, and fingerprinting sensitive arguments
+func (s *SensitiveArgsTestSuite) TestScrub() {
+	fm, err := parseFrontmatter([]byte("---\narguments:\n  api_token:\n    sensitive: true\n"+
+		"  signing_key:\n    sensitive: true\n    fingerprint: true\n---\n"), defaultBuiltinDateName)
+	require.NoError(s.T(), err)
+	sa := sensitiveArgs{frontmatter: fm, pattern: regexp.MustCompile(`(?i)password`)}
+	args := map[string]string{"api_token": "tok-123", "signing_key": "key-456", "db_password": "hunter2", "title": "Outage"}
+
+	assert.True(s.T(), sa.ContainsAny([]string{"title", "DB_PASSWORD"}))
+	assert.False(s.T(), sa.ContainsAny([]string{"title"}))
+	assert.Equal(s.T(), map[string]string{
+		"api_token": redactedValue, "signing_key": redactedValue, "db_password": redactedValue, "title": "Outage",
+	}, sa.ScrubArgs(args))
+	assert.Equal(s.T(), "tok-123", args["api_token"], "the given arguments must not be modified")
+	assert.Equal(s.T(), "Outage: [REDACTED] and [REDACTED]", sa.ScrubText("Outage: tok-123 and hunter2", args))
+	assert.Equal(s.T(), map[string]string{"signing_key": "key-456", "title": "Outage"}, sa.FingerprintArgs(args))
+
+	_, err = parseFrontmatter([]byte("---\narguments:\n  title:\n    fingerprint: true\n---\n"), defaultBuiltinDateName)
+	assert.EqualError(s.T(), err, `argument "title" sets fingerprint but is not sensitive`)
+}
+
+// TestServerSinks tests that sensitive values are rendered but never logged, stored, or fingerprinted
+func (s *SensitiveArgsTestSuite) TestServerSinks() {
+	var logs, accessLog bytes.Buffer
+	cacheDir := s.T().TempDir()
+	renderCache, err := OpenRenderCache(cacheDir, defaultRenderCacheMaxSize)
+	require.NoError(s.T(), err)
+	defer func() { _ = renderCache.Close() }()
+	key := []byte("0123456789abcdef")
+	promptsServer, err := NewPromptsServer(s.promptsDir,
+		WithWatchMode(WatchModeOff, 0),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		WithAccessLog(NewAccessLog(&accessLog, key)),
+		WithRenderCache(renderCache),
+		WithSessionHistory(5),
+		WithRenderSettings(RenderSettings{ExposeArgs: true}),
+		WithSensitivePattern(regexp.MustCompile(`password`)),
+	)
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	session := server.NewInProcessSession("session", nil)
+	require.NoError(s.T(), promptsServer.mcpServer.RegisterSession(context.Background(), session))
+	ctx := promptsServer.mcpServer.WithContext(context.Background(), session)
+	args := map[string]string{"title": "Outage", "api_token": "tok-secret-123", "db_password": "hunter2-secret"}
+	request, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0", "id": 1, "method": "prompts/get", "params": map[string]any{"name": "incident", "arguments": args},
+	})
+	require.NoError(s.T(), err)
+	response, ok := promptsServer.mcpServer.HandleMessage(ctx, request).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok)
+	result := response.Result.(mcp.GetPromptResult)
+
+	assert.Equal(s.T(), "Incident Outage: rotate token tok-secret-123, database password hunter2-secret",
+		result.Messages[0].Content.(mcp.TextContent).Text, "sensitive values are rendered normally")
+	assert.Equal(s.T(), map[string]string{"title": "Outage", "api_token": redactedValue, "db_password": redactedValue},
+		result.Meta.AdditionalFields["arguments"])
+
+	for sink, content := range map[string]string{
+		"server log": logs.String(),
+		"access log": accessLog.String(),
+		"session history": func() string {
+			history, _ := json.Marshal(promptsServer.sessionHistory.Entries("session"))
+			return string(history)
+		}(),
+	} {
+		assert.NotContains(s.T(), content, "tok-secret-123", sink)
+		assert.NotContains(s.T(), content, "hunter2-secret", sink)
+	}
+	assert.Contains(s.T(), logs.String(), "api_token:[REDACTED]")
+	assert.Equal(s.T(), []RenderedPrompt{{Name: "incident",
+		Text: "Incident Outage: rotate token [REDACTED], database password [REDACTED]"}},
+		promptsServer.sessionHistory.Entries("session"))
+
+	var entry AccessLogEntry
+	require.NoError(s.T(), json.Unmarshal(accessLog.Bytes(), &entry))
+	assert.Equal(s.T(), fingerprintArgs(key, map[string]string{"title": "Outage"}), entry.ArgsFingerprint)
+
+	entries, err := filepath.Glob(filepath.Join(cacheDir, renderCacheEntriesDir, "*"))
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), entries, "prompts with sensitive arguments must not be cached")
+}
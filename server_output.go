@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// ServerEvent is a lifecycle event of the server, e.g. its start or the address of the web preview.
+type ServerEvent struct {
+	Level   slog.Level
+	Message string
+	Attrs   []any // alternating keys and values, as taken by slog.Logger
+}
+
+// ServerReporter receives the lifecycle events of the server.
+// Embedders can implement it to capture them; by default, they are written to the logger of the server.
+type ServerReporter interface {
+	Report(event ServerEvent)
+}
+
+// logServerReporter writes lifecycle events to a logger.
+type logServerReporter struct {
+	logger *slog.Logger
+}
+
+func (r logServerReporter) Report(event ServerEvent) {
+	r.logger.Log(context.Background(), event.Level, event.Message, event.Attrs...)
+}
+
+// serverLogConfig configures the logger of the serve command.
+type serverLogConfig struct {
+	file        string        // log to the file instead of the writer, if set
+	dedupWindow time.Duration // collapse repeated records within the window, if positive
+	quiet       bool          // discard records written to the writer
+	debug       bool          // include debug records
+	// level, if set, is set to the initial level and controls the level of the logger, so that it can change later
+	level *slog.LevelVar
+}
+
+// newServerLogger creates the logger of the serve command, writing to w unless the config says otherwise.
+// The returned function releases the log file and must be called once the server is stopped.
+func newServerLogger(w io.Writer, cfg serverLogConfig) (*slog.Logger, func(), error) {
+	closers := []func(){}
+	closeAll := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	logWriter := w
+	if cfg.quiet {
+		logWriter = io.Discard
+	}
+	if cfg.file != "" {
+		file, err := os.OpenFile(cfg.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open log file: %w", err)
+		}
+		closers = append(closers, func() { _ = file.Close() })
+		logWriter = file
+	}
+	logLevel := slog.LevelInfo
+	if cfg.debug {
+		logLevel = slog.LevelDebug
+	}
+	var leveler slog.Leveler = logLevel
+	if cfg.level != nil {
+		cfg.level.Set(logLevel)
+		leveler = cfg.level
+	}
+	var logHandler slog.Handler = slog.NewTextHandler(logWriter, &slog.HandlerOptions{Level: leveler})
+	if cfg.dedupWindow > 0 {
+		dedupHandler := NewLogDedupHandler(logHandler, cfg.dedupWindow, defaultReloadErrorsPerMinute)
+		closers = append(closers, dedupHandler.Close)
+		logHandler = dedupHandler
+	}
+	return slog.New(logHandler), closeAll, nil
+}
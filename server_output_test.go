@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ServerOutputTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestServerOutputTestSuite(t *testing.T) {
+	suite.Run(t, new(ServerOutputTestSuite))
+}
+
+func (s *ServerOutputTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, "greet.tmpl"), []byte("{{/* Greet */}}\nHello"), 0644))
+}
+
+// recordingReporter keeps the messages of the reported events
+type recordingReporter struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *recordingReporter) Report(event ServerEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, event.Level.String()+" "+event.Message)
+}
+
+func (r *recordingReporter) Messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.messages...)
+}
+
+// TestRunStdioMCPServerReportsLifecycle tests that startup and shutdown events go to the injected reporter,
+// not to the logger
+func (s *ServerOutputTestSuite) TestRunStdioMCPServerReportsLifecycle() {
+	var logBuf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logBuf, nil))
+	reporter := &recordingReporter{}
+
+	stdinReader, stdinWriter := io.Pipe()
+	defer func() { _ = stdinWriter.Close() }()
+	err := runStdioMCPServer(logger, reporter, stdinReader, io.Discard, serveConfig{
+		promptsDir:       s.promptsDir,
+		safeModeDisabled: []string{"exec"},
+		previewAddr:      "127.0.0.1:0",
+		options:          []Option{WithWatchMode(WatchModeOff, 0), WithStdinTimeout(100 * time.Millisecond)},
+	})
+	require.ErrorIs(s.T(), err, errNoClient)
+
+	messages := reporter.Messages()
+	require.GreaterOrEqual(s.T(), len(messages), 6)
+	assert.Equal(s.T(), []string{
+		"WARN Safe mode is active",
+		"WARN Panic recovery is disabled, a panicking template function stops the server",
+		"INFO Prompts loaded",
+		"INFO Serving web preview",
+	}, messages[:4])
+	assert.Contains(s.T(), messages[4:], "INFO Starting stdio server")
+	assert.Contains(s.T(), messages[4:], "WARN No client connected, stopping server")
+
+	assert.Contains(s.T(), logBuf.String(), "Prompts registered")
+	assert.NotContains(s.T(), logBuf.String(), "Starting stdio server")
+}
+
+// TestDefaultReporterLogs tests that lifecycle events are logged unless a reporter is configured
+func (s *ServerOutputTestSuite) TestDefaultReporterLogs() {
+	var logBuf bytes.Buffer
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithWatchMode(WatchModeOff, 0),
+		WithStdinTimeout(50*time.Millisecond), WithLogger(slog.New(slog.NewTextHandler(&logBuf, nil))))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	stdinReader, stdinWriter := io.Pipe()
+	defer func() { _ = stdinWriter.Close() }()
+	require.ErrorIs(s.T(), promptsServer.ServeStdio(s.T().Context(), stdinReader, io.Discard), errNoClient)
+	assert.Contains(s.T(), logBuf.String(), `level=INFO msg="Starting stdio server"`)
+	assert.Contains(s.T(), logBuf.String(), `level=WARN msg="No client connected, stopping server" stdin_timeout=50ms`)
+}
+
+// TestNewServerLogger tests the writer, level, and file of the serve logger
+func (s *ServerOutputTestSuite) TestNewServerLogger() {
+	var buf bytes.Buffer
+	logger, closeLogger, err := newServerLogger(&buf, serverLogConfig{})
+	require.NoError(s.T(), err)
+	logger.Debug("hidden")
+	logger.Info("shown")
+	closeLogger()
+	assert.NotContains(s.T(), buf.String(), "hidden")
+	assert.Contains(s.T(), buf.String(), "msg=shown")
+
+	buf.Reset()
+	logger, closeLogger, err = newServerLogger(&buf, serverLogConfig{debug: true, quiet: true})
+	require.NoError(s.T(), err)
+	logger.Debug("debug")
+	closeLogger()
+	assert.Empty(s.T(), buf.String(), "quiet discards records written to the writer")
+
+	logFile := filepath.Join(s.T().TempDir(), "server.log")
+	logger, closeLogger, err = newServerLogger(&buf, serverLogConfig{file: logFile, debug: true, quiet: true})
+	require.NoError(s.T(), err)
+	logger.Debug("debug")
+	closeLogger()
+	content, err := os.ReadFile(logFile)
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), string(content), "msg=debug")
+	assert.Empty(s.T(), buf.String())
+
+	_, _, err = newServerLogger(&buf, serverLogConfig{file: filepath.Join(s.T().TempDir(), "missing", "server.log")})
+	assert.ErrorContains(s.T(), err, "open log file")
+}
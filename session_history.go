@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	lastPromptFuncName  = "lastPrompt"
+	sessionHistoryField = "session_history"
+
+	// maxSessionHistoryTextSize bounds the text kept for each rendered prompt in the session history.
+	maxSessionHistoryTextSize = 16 << 10
+)
+
+// RenderedPrompt is an entry of the session history, available to templates as .session_history.
+type RenderedPrompt struct {
+	Name string
+	Text string // rendered text, truncated to maxSessionHistoryTextSize bytes
+}
+
+// sessionHistory keeps the last rendered prompts of every MCP session. It is safe for concurrent use.
+type sessionHistory struct {
+	mu       sync.Mutex
+	limit    int
+	sessions map[string][]RenderedPrompt
+}
+
+func newSessionHistory(limit int) *sessionHistory {
+	return &sessionHistory{limit: limit, sessions: make(map[string][]RenderedPrompt)}
+}
+
+// Record adds the rendered prompt to the history of the session, dropping the oldest entry once the limit is reached.
+func (h *sessionHistory) Record(sessionID string, name string, text string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries := append(h.sessions[sessionID], RenderedPrompt{Name: name, Text: truncateUTF8(text, maxSessionHistoryTextSize)})
+	if len(entries) > h.limit {
+		entries = append([]RenderedPrompt(nil), entries[len(entries)-h.limit:]...)
+	}
+	h.sessions[sessionID] = entries
+}
+
+// Entries returns a copy of the history of the session, oldest first.
+func (h *sessionHistory) Entries(sessionID string) []RenderedPrompt {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]RenderedPrompt{}, h.sessions[sessionID]...)
+}
+
+// Clear forgets the history of the session.
+func (h *sessionHistory) Clear(sessionID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.sessions, sessionID)
+}
+
+// makeLastPromptFunc returns the lastPrompt helper for the given history:
+// it returns the text of the most recent render of the named prompt, or an empty string if there is none.
+func makeLastPromptFunc(entries []RenderedPrompt) func(name string) string {
+	return func(name string) string {
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i].Name == name {
+				return entries[i].Text
+			}
+		}
+		return ""
+	}
+}
+
+// sessionIDFromContext returns the ID of the MCP session of the request, or an empty string outside of a session.
+func sessionIDFromContext(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
+
+// truncateUTF8 cuts the text to at most maxSize bytes without splitting a character.
+func truncateUTF8(text string, maxSize int) string {
+	if len(text) <= maxSize {
+		return text
+	}
+	for maxSize > 0 && !utf8.RuneStart(text[maxSize]) {
+		maxSize--
+	}
+	return text[:maxSize]
+}
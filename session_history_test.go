@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type SessionHistoryTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestSessionHistoryTestSuite(t *testing.T) {
+	suite.Run(t, new(SessionHistoryTestSuite))
+}
+
+func (s *SessionHistoryTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	for name, content := range map[string]string{
+		"plan.tmpl":   "{{/* Plan the change */}}\nPlan: {{.task}}",
+		"review.tmpl": "{{/* Review the change */}}\nPrevious plan: {{lastPrompt \"plan\"}}\nHistory:{{range $p := .session_history}} {{$p.Name}}{{end}}",
+	} {
+		require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+	}
+}
+
+// getPrompt renders the prompt within the session and returns its text
+func (s *SessionHistoryTestSuite) getPrompt(
+	ctx context.Context, promptsServer *PromptsServer, name string, args map[string]string,
+) string {
+	request, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0", "id": 1, "method": "prompts/get", "params": map[string]any{"name": name, "arguments": args},
+	})
+	require.NoError(s.T(), err)
+	response, ok := promptsServer.mcpServer.HandleMessage(ctx, request).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok, "prompt %q must render", name)
+	result, ok := response.Result.(mcp.GetPromptResult)
+	require.True(s.T(), ok)
+	return result.Messages[0].Content.(mcp.TextContent).Text
+}
+
+// newSession registers an in-process session and returns the context of its requests
+func (s *SessionHistoryTestSuite) newSession(promptsServer *PromptsServer, id string) context.Context {
+	session := server.NewInProcessSession(id, nil)
+	require.NoError(s.T(), promptsServer.mcpServer.RegisterSession(context.Background(), session))
+	return promptsServer.mcpServer.WithContext(context.Background(), session)
+}
+
+// TestRecord tests that the history is bounded, size-capped, and kept per session
+func (s *SessionHistoryTestSuite) TestRecord() {
+	history := newSessionHistory(2)
+	history.Record("a", "plan", "first")
+	history.Record("a", "plan", "second")
+	history.Record("a", "review", "third")
+	history.Record("b", "plan", "other")
+
+	entries := history.Entries("a")
+	assert.Equal(s.T(), []RenderedPrompt{{Name: "plan", Text: "second"}, {Name: "review", Text: "third"}}, entries)
+	assert.Equal(s.T(), "second", makeLastPromptFunc(entries)("plan"))
+	assert.Empty(s.T(), makeLastPromptFunc(entries)("summary"))
+	assert.Equal(s.T(), []RenderedPrompt{{Name: "plan", Text: "other"}}, history.Entries("b"))
+	assert.Equal(s.T(), []RenderedPrompt{}, history.Entries("c"))
+
+	history.Clear("a")
+	assert.Empty(s.T(), history.Entries("a"))
+	assert.Len(s.T(), history.Entries("b"), 1)
+
+	history.Record("b", "long", strings.Repeat("é", maxSessionHistoryTextSize))
+	text := history.Entries("b")[1].Text
+	assert.Len(s.T(), text, maxSessionHistoryTextSize)
+	assert.True(s.T(), strings.HasSuffix(text, "é"), "a character must not be split")
+}
+
+// TestSessionHistory tests that a prompt sees the prompts rendered earlier in its own session only
+func (s *SessionHistoryTestSuite) TestSessionHistory() {
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithSessionHistory(5), WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	first := s.newSession(promptsServer, "first")
+	parallel := s.newSession(promptsServer, "parallel")
+
+	assert.Equal(s.T(), "Plan: Add caching", s.getPrompt(first, promptsServer, "plan", map[string]string{"task": "Add caching"}))
+	assert.Equal(s.T(), "Previous plan: Plan: Add caching\nHistory: plan", s.getPrompt(first, promptsServer, "review", nil))
+	assert.Equal(s.T(), "Previous plan: \nHistory:", s.getPrompt(parallel, promptsServer, "review", nil))
+	assert.Equal(s.T(), "Previous plan: Plan: Add caching\nHistory: plan review",
+		s.getPrompt(first, promptsServer, "review", nil))
+
+	promptsServer.mcpServer.UnregisterSession(context.Background(), "first")
+	assert.Empty(s.T(), promptsServer.sessionHistory.Entries("first"), "history must be dropped when the session ends")
+	assert.Len(s.T(), promptsServer.sessionHistory.Entries("parallel"), 1)
+}
+
+// TestSessionHistoryDisabled tests that nothing is kept by default and session_history is not a prompt argument
+func (s *SessionHistoryTestSuite) TestSessionHistoryDisabled() {
+	promptsServer, err := NewPromptsServer(s.promptsDir, WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+	assert.Nil(s.T(), promptsServer.sessionHistory)
+
+	session := s.newSession(promptsServer, "first")
+	s.getPrompt(session, promptsServer, "plan", map[string]string{"task": "Add caching"})
+	assert.Equal(s.T(), "Previous plan: \nHistory:", s.getPrompt(session, promptsServer, "review", nil))
+
+	parser := &PromptsParser{}
+	tmpl, err := parser.ParseDir(s.promptsDir)
+	require.NoError(s.T(), err)
+	args, err := parser.ExtractPromptArgumentsFromTemplate(tmpl, "review.tmpl")
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), args)
+}
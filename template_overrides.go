@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// maxTemplateOverrideTTL bounds how long a template override is served, so a forgotten override does not live
+// for the lifetime of the server.
+const maxTemplateOverrideTTL = 24 * time.Hour
+
+// errTemplateOverridesDisabled is returned when overrides are set on a server started without them.
+var errTemplateOverridesDisabled = errors.New("template overrides are disabled, start the server with --enable-overrides")
+
+// templateOverride is a template source served for a prompt instead of its template file, for a while.
+// Overrides live in memory only.
+type templateOverride struct {
+	source    []byte
+	fileHash  string // hash of the template file when the override was set; the override is dropped if it changes
+	expiresAt time.Time
+	timer     *time.Timer // removes the override when it expires
+}
+
+// SetTemplateOverride serves the template source for the prompt instead of its template file until the TTL expires,
+// the override is deleted, or the template file changes. The source is parsed against the current partials and
+// checked like a template file; a broken source is rejected and the served prompt stays as it is.
+// Setting an override replaces the previous one of the prompt. It returns when the override expires.
+func (ps *PromptsServer) SetTemplateOverride(promptName string, source string, ttl time.Duration) (time.Time, error) {
+	if !ps.enableOverrides {
+		return time.Time{}, errTemplateOverridesDisabled
+	}
+	if ttl <= 0 || ttl > maxTemplateOverrideTTL {
+		return time.Time{}, fmt.Errorf("ttl must be positive and at most %s", maxTemplateOverrideTTL)
+	}
+	ps.reloadMu.Lock()
+	defer ps.reloadMu.Unlock()
+
+	templateName, err := ps.overriddenTemplateName(promptName)
+	if err != nil {
+		return time.Time{}, err
+	}
+	fileContent, err := os.ReadFile(filepath.Join(ps.promptsDir, templateName))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("read template file: %w", err)
+	}
+	if err = ps.checkTemplateOverride(templateName, []byte(source)); err != nil {
+		return time.Time{}, fmt.Errorf("invalid override of prompt %q: %w", promptName, err)
+	}
+
+	override := &templateOverride{source: []byte(source), fileHash: hashContent(fileContent), expiresAt: time.Now().Add(ttl)}
+	override.timer = time.AfterFunc(ttl, func() { ps.expireTemplateOverride(templateName, override) })
+	ps.overridesMu.Lock()
+	previous := ps.overrides[templateName]
+	ps.overrides[templateName] = override
+	ps.overridesMu.Unlock()
+
+	if err = ps.reloadPromptsLocked(ps.watchedContentHash()); err != nil {
+		// The previous override, if any, is served again; its timer still runs
+		ps.removeTemplateOverride(templateName, override)
+		if previous != nil {
+			ps.overridesMu.Lock()
+			ps.overrides[templateName] = previous
+			ps.overridesMu.Unlock()
+		}
+		return time.Time{}, fmt.Errorf("reload prompts: %w", err)
+	}
+	if previous != nil {
+		previous.timer.Stop()
+	}
+	ps.logger.Warn("Template override set, the prompt is served from it instead of its file",
+		"prompt", promptName, "template", templateName, "expires_at", override.expiresAt.Format(time.RFC3339))
+	return override.expiresAt, nil
+}
+
+// DeleteTemplateOverride serves the prompt from its template file again.
+func (ps *PromptsServer) DeleteTemplateOverride(promptName string) error {
+	if !ps.enableOverrides {
+		return errTemplateOverridesDisabled
+	}
+	ps.reloadMu.Lock()
+	defer ps.reloadMu.Unlock()
+
+	templateName, err := ps.overriddenTemplateName(promptName)
+	if err != nil {
+		return err
+	}
+	ps.overridesMu.Lock()
+	override := ps.overrides[templateName]
+	ps.overridesMu.Unlock()
+	if override == nil {
+		return fmt.Errorf("prompt %q has no template override", promptName)
+	}
+	ps.removeTemplateOverride(templateName, override)
+	ps.logger.Info("Template override deleted, the prompt is served from its file again", "prompt", promptName)
+	return ps.reloadPromptsLocked(ps.watchedContentHash())
+}
+
+// overriddenTemplateName returns the template file of the registered prompt.
+func (ps *PromptsServer) overriddenTemplateName(promptName string) (string, error) {
+	ps.staticMu.RLock()
+	defer ps.staticMu.RUnlock()
+	templateName, ok := ps.templateNames[promptName]
+	if !ok {
+		return "", fmt.Errorf("prompt %q not found", promptName)
+	}
+	return templateName, nil
+}
+
+// expireTemplateOverride removes the override once its TTL expired, unless it was replaced or removed meanwhile.
+func (ps *PromptsServer) expireTemplateOverride(templateName string, override *templateOverride) {
+	ps.reloadMu.Lock()
+	defer ps.reloadMu.Unlock()
+	ps.overridesMu.Lock()
+	current := ps.overrides[templateName]
+	ps.overridesMu.Unlock()
+	if current != override {
+		return
+	}
+	ps.removeTemplateOverride(templateName, override)
+	ps.logger.Info("Template override expired, the prompt is served from its file again", "template", templateName)
+	if err := ps.reloadPromptsLocked(ps.watchedContentHash()); err != nil {
+		ps.logger.Error("Failed to reload prompts after a template override expired", "error", err)
+	}
+}
+
+// removeTemplateOverride removes the override of the template if it is the current one.
+func (ps *PromptsServer) removeTemplateOverride(templateName string, override *templateOverride) {
+	ps.overridesMu.Lock()
+	defer ps.overridesMu.Unlock()
+	override.timer.Stop()
+	if ps.overrides[templateName] == override {
+		delete(ps.overrides, templateName)
+	}
+}
+
+// stopTemplateOverrides stops the expiry timers of all overrides, which are dropped.
+func (ps *PromptsServer) stopTemplateOverrides() {
+	ps.overridesMu.Lock()
+	defer ps.overridesMu.Unlock()
+	for templateName, override := range ps.overrides {
+		override.timer.Stop()
+		delete(ps.overrides, templateName)
+	}
+}
+
+// checkTemplateOverride checks the override source like the template file it replaces: its frontmatter and
+// description must be valid, and its template must parse against the current partials and resolve its arguments.
+// Overrides cannot define templates, which would replace partials for every prompt.
+func (ps *PromptsServer) checkTemplateOverride(templateName string, source []byte) error {
+	if _, err := parseFrontmatter(source, ps.parser.dateName()); err != nil {
+		return fmt.Errorf("frontmatter: %w", err)
+	}
+	if _, err := ps.parser.ExtractPromptDescription(source); err != nil {
+		return fmt.Errorf("description: %w", err)
+	}
+	_, body, err := splitFrontmatter(source)
+	if err != nil {
+		return err
+	}
+	text, delims, err := withDelimsDirective(string(body))
+	if err != nil {
+		return err
+	}
+	trees := make(map[string]*parse.Tree)
+	tree := parse.New(templateName)
+	tree.Mode = parse.SkipFuncCheck
+	if _, err = tree.Parse(text, delims.left, delims.right, trees); err != nil {
+		return err
+	}
+	if len(trees) > 1 {
+		return errors.New("overrides cannot define templates")
+	}
+
+	tmpl, _, err := ps.parser.ParseDirSkippingUnknownFuncs(ps.promptsDir)
+	if err != nil {
+		return fmt.Errorf("parse all prompts: %w", err)
+	}
+	funcs, err := ps.parser.funcMap(ps.promptsDir)
+	if err != nil {
+		return err
+	}
+	if err = parseTemplateText(tmpl, funcs, templateName, string(body)); err != nil {
+		return err
+	}
+	_, err = ps.parser.ExtractPromptArgumentsFromTemplate(tmpl, templateName)
+	return err
+}
+
+// applyTemplateOverrides replaces the parse trees of the overridden templates with those of their overrides and
+// returns the applied overrides by template file name. The file wins: overrides of template files that changed
+// since the override was set are dropped, as are overrides that no longer parse, e.g. after a partial changed.
+// Dropped overrides are reported to warn.
+func (ps *PromptsServer) applyTemplateOverrides(
+	tmpl *template.Template, warn func(msg string, args ...any),
+) (map[string]*templateOverride, error) {
+	ps.overridesMu.Lock()
+	defer ps.overridesMu.Unlock()
+	if len(ps.overrides) == 0 {
+		return nil, nil
+	}
+	funcs, err := ps.parser.funcMap(ps.promptsDir)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[string]*templateOverride, len(ps.overrides))
+	for templateName, override := range ps.overrides {
+		drop := func(reason string, args ...any) {
+			override.timer.Stop()
+			delete(ps.overrides, templateName)
+			warn(reason, append([]any{"template", templateName}, args...)...)
+		}
+		fileContent, err := os.ReadFile(filepath.Join(ps.promptsDir, templateName))
+		if err != nil || hashContent(fileContent) != override.fileHash {
+			drop("Dropping template override, its template file changed")
+			continue
+		}
+		_, body, _ := splitFrontmatter(override.source)
+		if err = parseTemplateText(tmpl, funcs, templateName, string(body)); err != nil {
+			drop("Dropping template override that no longer parses", "error", err)
+			continue
+		}
+		applied[templateName] = override
+	}
+	return applied, nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const overriddenGreeting = "{{/* Greet loudly */}}\nHELLO {{.name}} OF {{.team}}{{template \"_footer.tmpl\" .}}"
+
+type TemplateOverridesTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestTemplateOverridesTestSuite(t *testing.T) {
+	suite.Run(t, new(TemplateOverridesTestSuite))
+}
+
+func (s *TemplateOverridesTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("_footer.tmpl", "{{define \"_footer.tmpl\"}}!{{end}}")
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello {{.name}}{{template \"_footer.tmpl\" .}}")
+}
+
+func (s *TemplateOverridesTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+func (s *TemplateOverridesTestSuite) newServer(opts ...Option) *PromptsServer {
+	promptsServer, err := NewPromptsServer(s.promptsDir, append([]Option{WithWatchMode(WatchModeOff, 0)}, opts...)...)
+	require.NoError(s.T(), err)
+	s.T().Cleanup(func() { s.Require().NoError(promptsServer.Close()) })
+	return promptsServer
+}
+
+func (s *TemplateOverridesTestSuite) getPrompt(promptsServer *PromptsServer) mcp.GetPromptResult {
+	request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "prompts/get",
+		"params": map[string]any{"name": "greet", "arguments": map[string]any{"name": "Ann", "team": "ops"}}})
+	require.NoError(s.T(), err)
+	response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok)
+	return response.Result.(mcp.GetPromptResult)
+}
+
+func (s *TemplateOverridesTestSuite) text(promptsServer *PromptsServer) string {
+	return s.getPrompt(promptsServer).Messages[0].Content.(mcp.TextContent).Text
+}
+
+func (s *TemplateOverridesTestSuite) listPrompt(promptsServer *PromptsServer) mcp.Prompt {
+	request := []byte(`{"jsonrpc":"2.0","id":1,"method":"prompts/list"}`)
+	response, ok := promptsServer.mcpServer.HandleMessage(context.Background(), request).(mcp.JSONRPCResponse)
+	require.True(s.T(), ok)
+	prompts := response.Result.(mcp.ListPromptsResult).Prompts
+	require.Len(s.T(), prompts, 1)
+	return prompts[0]
+}
+
+func argNames(prompt mcp.Prompt) []string {
+	var names []string
+	for _, arg := range prompt.Arguments {
+		names = append(names, arg.Name)
+	}
+	return names
+}
+
+// TestServeOverride tests that the override is served, listed with its arguments, and marked in the provenance
+// of the results, until it is deleted
+func (s *TemplateOverridesTestSuite) TestServeOverride() {
+	promptsServer := s.newServer(WithTemplateOverrides(true))
+	assert.Equal(s.T(), "Hello Ann!", s.text(promptsServer))
+	assert.Nil(s.T(), s.getPrompt(promptsServer).Meta, "results of files have no provenance unless enabled")
+
+	expiresAt, err := promptsServer.SetTemplateOverride("greet", overriddenGreeting, time.Hour)
+	require.NoError(s.T(), err)
+	assert.WithinDuration(s.T(), time.Now().Add(time.Hour), expiresAt, time.Minute)
+
+	assert.Equal(s.T(), "HELLO Ann OF ops!", s.text(promptsServer))
+	prompt := s.listPrompt(promptsServer)
+	assert.Equal(s.T(), "Greet loudly", prompt.Description)
+	assert.Equal(s.T(), []string{"name", "team"}, argNames(prompt))
+	meta := s.getPrompt(promptsServer).Meta
+	require.NotNil(s.T(), meta)
+	provenance := meta.AdditionalFields["provenance"].(map[string]any)
+	assert.Equal(s.T(), "greet.tmpl", provenance["template"])
+	assert.Equal(s.T(), hashContent([]byte(overriddenGreeting)), provenance["sha256"])
+	assert.Equal(s.T(), map[string]any{"expires_at": expiresAt.Format(time.RFC3339)}, provenance["override"])
+
+	require.NoError(s.T(), promptsServer.DeleteTemplateOverride("greet"))
+	assert.Equal(s.T(), "Hello Ann!", s.text(promptsServer))
+	assert.Equal(s.T(), []string{"name"}, argNames(s.listPrompt(promptsServer)))
+	assert.ErrorContains(s.T(), promptsServer.DeleteTemplateOverride("greet"), `prompt "greet" has no template override`)
+}
+
+// TestExpiry tests that the prompt is served from its file again once the TTL expired
+func (s *TemplateOverridesTestSuite) TestExpiry() {
+	promptsServer := s.newServer(WithTemplateOverrides(true))
+	_, err := promptsServer.SetTemplateOverride("greet", overriddenGreeting, 50*time.Millisecond)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "HELLO Ann OF ops!", s.text(promptsServer))
+
+	assert.Eventually(s.T(), func() bool { return s.text(promptsServer) == "Hello Ann!" }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(s.T(), []string{"name"}, argNames(s.listPrompt(promptsServer)))
+}
+
+// TestRejectBrokenOverrides tests that overrides which do not parse or check are rejected
+// and leave the served prompt as it is
+func (s *TemplateOverridesTestSuite) TestRejectBrokenOverrides() {
+	promptsServer := s.newServer(WithTemplateOverrides(true))
+	_, err := promptsServer.SetTemplateOverride("greet", overriddenGreeting, time.Hour)
+	require.NoError(s.T(), err)
+
+	for _, tc := range []struct {
+		source   string
+		expected string
+	}{
+		{source: "Hello {{if .name}}", expected: "unexpected EOF"},
+		{source: "Hello {{.name | shout}}", expected: `function "shout" not defined`},
+		{source: `Hello {{template "_header.tmpl" .}}`, expected: "_header.tmpl"},
+		{source: `{{define "_footer.tmpl"}}?{{end}}Hello`, expected: "overrides cannot define templates"},
+		{source: "---\narguments: [\n---\nHello", expected: "frontmatter"},
+	} {
+		_, err = promptsServer.SetTemplateOverride("greet", tc.source, time.Hour)
+		assert.ErrorContains(s.T(), err, tc.expected, tc.source)
+		assert.Equal(s.T(), "HELLO Ann OF ops!", s.text(promptsServer), "the previous override is still served")
+	}
+
+	_, err = promptsServer.SetTemplateOverride("missing", "Hi", time.Hour)
+	assert.ErrorContains(s.T(), err, `prompt "missing" not found`)
+	for _, ttl := range []time.Duration{0, -time.Second, 25 * time.Hour} {
+		_, err = promptsServer.SetTemplateOverride("greet", "Hi", ttl)
+		assert.ErrorContains(s.T(), err, "ttl must be positive", ttl)
+	}
+	assert.Equal(s.T(), "HELLO Ann OF ops!", s.text(promptsServer))
+}
+
+// TestFileWins tests that an override is dropped when the file of its prompt changes
+func (s *TemplateOverridesTestSuite) TestFileWins() {
+	promptsServer := s.newServer(WithTemplateOverrides(true))
+	_, err := promptsServer.SetTemplateOverride("greet", overriddenGreeting, time.Hour)
+	require.NoError(s.T(), err)
+
+	s.writeFile("_footer.tmpl", "{{define \"_footer.tmpl\"}}?{{end}}")
+	require.NoError(s.T(), promptsServer.Reload())
+	assert.Equal(s.T(), "HELLO Ann OF ops?", s.text(promptsServer), "the override is kept when partials change")
+
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHi {{.name}}")
+	require.NoError(s.T(), promptsServer.Reload())
+	assert.Equal(s.T(), "Hi Ann", s.text(promptsServer))
+	assert.Contains(s.T(), promptsServer.LoadSummary().Warnings,
+		"Dropping template override, its template file changed template=greet.tmpl")
+	assert.ErrorContains(s.T(), promptsServer.DeleteTemplateOverride("greet"), "has no template override")
+}
+
+// TestDisabled tests that overrides need WithTemplateOverrides
+func (s *TemplateOverridesTestSuite) TestDisabled() {
+	promptsServer := s.newServer()
+	_, err := promptsServer.SetTemplateOverride("greet", overriddenGreeting, time.Hour)
+	assert.ErrorIs(s.T(), err, errTemplateOverridesDisabled)
+	assert.ErrorIs(s.T(), promptsServer.DeleteTemplateOverride("greet"), errTemplateOverridesDisabled)
+	assert.Equal(s.T(), "Hello Ann!", s.text(promptsServer))
+}
+
+// TestOverrideCommand tests the override command against the control socket of a running server
+func (s *TemplateOverridesTestSuite) TestOverrideCommand() {
+	socketDir, err := os.MkdirTemp("", "mpe-override-")
+	require.NoError(s.T(), err)
+	s.T().Cleanup(func() { _ = os.RemoveAll(socketDir) })
+	socketPath := filepath.Join(socketDir, "control.sock")
+
+	promptsServer := s.newServer(WithTemplateOverrides(true))
+	listener, err := listenPrivateSocket(socketPath)
+	require.NoError(s.T(), err)
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.NoError(promptsServer.ServeControl(ctx, listener))
+	}()
+	s.T().Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+
+	overrideFile := filepath.Join(s.T().TempDir(), "greet.tmpl")
+	require.NoError(s.T(), os.WriteFile(overrideFile, []byte(overriddenGreeting), 0644))
+	controlTests := ControlTestSuite{}
+	controlTests.SetT(s.T())
+	out, err := controlTests.run("override", "--control-socket", socketPath, "set", "--ttl", "5m", "greet", overrideFile)
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), out, "Prompt 'greet' is served from the override until ")
+	assert.Equal(s.T(), "HELLO Ann OF ops!", s.text(promptsServer))
+
+	require.NoError(s.T(), os.WriteFile(overrideFile, []byte("Hello {{if .name}}"), 0644))
+	_, err = controlTests.run("override", "--control-socket", socketPath, "set", "greet", overrideFile)
+	assert.ErrorContains(s.T(), err, "failed to override prompt 'greet'")
+	assert.ErrorContains(s.T(), err, "unexpected EOF")
+
+	out, err = controlTests.run("override", "--control-socket", socketPath, "delete", "greet")
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), out, "Prompt 'greet' is served from its file again")
+	assert.Equal(s.T(), "Hello Ann!", s.text(promptsServer))
+
+	_, err = controlTests.run("override", "--control-socket", socketPath, "delete", "greet")
+	assert.ErrorContains(s.T(), err, "has no template override")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// TokenEstimator estimates how many tokens a text takes up in the context of a model.
+// The default estimator counts characters; a real tokenizer can implement the interface instead.
+type TokenEstimator interface {
+	EstimateTokens(text string) int
+}
+
+// charsTokenEstimator estimates one token per four characters, a rough average for English text.
+type charsTokenEstimator struct{}
+
+func (charsTokenEstimator) EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// defaultTokenEstimator is the estimator used unless another one is configured.
+var defaultTokenEstimator TokenEstimator = charsTokenEstimator{}
+
+// staticTokenEstimates estimates the size of the static text of templates, i.e. the text they render
+// without arguments. Estimates are computed on first use and kept, as they only change with the templates:
+// a new instance must be created whenever the templates are parsed again. It is safe for concurrent use.
+type staticTokenEstimates struct {
+	tmpl       *template.Template
+	promptsDir string
+	dateName   string
+	estimator  TokenEstimator
+
+	mu        sync.Mutex
+	estimates map[string]staticTokenEstimate // by template name
+}
+
+type staticTokenEstimate struct {
+	tokens int
+	err    error
+}
+
+func newStaticTokenEstimates(
+	tmpl *template.Template, promptsDir string, dateName string, estimator TokenEstimator,
+) *staticTokenEstimates {
+	if estimator == nil {
+		estimator = defaultTokenEstimator
+	}
+	return &staticTokenEstimates{
+		tmpl:       tmpl,
+		promptsDir: promptsDir,
+		dateName:   dateName,
+		estimator:  estimator,
+		estimates:  make(map[string]staticTokenEstimate),
+	}
+}
+
+// Get returns the estimated tokens of the static text of the template.
+// Fields without a value render as nothing; frontmatter data constants and the built-in date are included.
+func (e *staticTokenEstimates) Get(templateName string) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if estimate, ok := e.estimates[templateName]; ok {
+		return estimate.tokens, estimate.err
+	}
+	var estimate staticTokenEstimate
+	estimate.tokens, estimate.err = e.estimate(templateName)
+	e.estimates[templateName] = estimate
+	return estimate.tokens, estimate.err
+}
+
+func (e *staticTokenEstimates) estimate(templateName string) (int, error) {
+	frontmatter, err := loadPromptFrontmatter(filepath.Join(e.promptsDir, templateName), e.dateName)
+	if err != nil {
+		return 0, err
+	}
+	data := map[string]interface{}{e.dateName: time.Now().Format(builtinDateLayout)}
+	frontmatter.MergeConstants(data)
+	tmpl, err := bindScratchpad(e.tmpl)
+	if err != nil {
+		return 0, err
+	}
+	var buf bytes.Buffer
+	if err = tmpl.ExecuteTemplate(&buf, templateName, data); err != nil {
+		return 0, err
+	}
+	return e.estimator.EstimateTokens(strings.TrimSpace(strings.ReplaceAll(buf.String(), "<no value>", ""))), nil
+}
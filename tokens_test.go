@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TokensTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestTokensTestSuite(t *testing.T) {
+	suite.Run(t, new(TokensTestSuite))
+}
+
+func (s *TokensTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+}
+
+func (s *TokensTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+// runeTokenEstimator counts one token per character, so tests can tell it apart from the default estimator
+type runeTokenEstimator struct{}
+
+func (runeTokenEstimator) EstimateTokens(text string) int {
+	return utf8.RuneCountInString(text)
+}
+
+// TestCharsTokenEstimator pins the default heuristic of one token per four characters, rounded up
+func (s *TokensTestSuite) TestCharsTokenEstimator() {
+	tests := []struct {
+		text     string
+		expected int
+	}{
+		{text: "", expected: 0},
+		{text: "a", expected: 1},
+		{text: "abcd", expected: 1},
+		{text: "abcde", expected: 2},
+		{text: "Größe", expected: 2},
+		{text: "Hello !\nHave a great day!", expected: 7},
+	}
+	for _, tt := range tests {
+		assert.Equal(s.T(), tt.expected, defaultTokenEstimator.EstimateTokens(tt.text), "text %q", tt.text)
+	}
+}
+
+// TestStaticTokenEstimate tests that static estimates include partials and constants and are kept until reload
+func (s *TokensTestSuite) TestStaticTokenEstimate() {
+	s.writeFile("_footer.tmpl", `{{define "_footer"}}Thanks!{{end}}`)
+	s.writeFile("review.tmpl", "---\ndata:\n  team: core\n---\n{{/* Review */}}\nReview {{.diff}} for {{.team}}\n{{template \"_footer\" .}}")
+
+	promptsServer, err := NewPromptsServer(s.promptsDir,
+		WithTokenEstimator(runeTokenEstimator{}), WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	tokens, err := promptsServer.StaticTokenEstimate("review")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), utf8.RuneCountInString("Review  for core\nThanks!"), tokens)
+
+	_, err = promptsServer.StaticTokenEstimate("missing")
+	assert.EqualError(s.T(), err, `prompt "missing" not found`)
+
+	// The estimate is cached until the prompts are reloaded
+	s.writeFile("review.tmpl", "{{/* Review */}}\nReview {{.diff}}")
+	tokens, err = promptsServer.StaticTokenEstimate("review")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), utf8.RuneCountInString("Review  for core\nThanks!"), tokens)
+
+	require.NoError(s.T(), promptsServer.reloadPrompts())
+	tokens, err = promptsServer.StaticTokenEstimate("review")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), utf8.RuneCountInString("Review"), tokens)
+}
+
+// TestPromptRequestLogsEstimatedTokens tests that the server logs the estimate of the rendered prompt
+func (s *TokensTestSuite) TestPromptRequestLogsEstimatedTokens() {
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello {{.name}}!")
+
+	var logBuf bytes.Buffer
+	promptsServer, err := NewPromptsServer(s.promptsDir,
+		WithLogger(slog.New(slog.NewTextHandler(&logBuf, nil))), WithWatchMode(WatchModeOff, 0))
+	require.NoError(s.T(), err)
+	defer func() { s.Require().NoError(promptsServer.Close()) }()
+
+	request, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0", "id": 1, "method": "prompts/get",
+		"params": map[string]any{"name": "greet", "arguments": map[string]string{"name": "Alice"}},
+	})
+	require.NoError(s.T(), err)
+	promptsServer.mcpServer.HandleMessage(context.Background(), request)
+
+	// "Hello Alice!" has 12 characters
+	assert.Contains(s.T(), logBuf.String(), "estimated_tokens=3")
+}
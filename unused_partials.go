@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/template"
+)
+
+// findUnusedPartials returns the sorted file names of the partials in the prompts directory that no prompt
+// references, directly or through other partials. A partial file is referenced if any template it defines is.
+// Prompts whose partials cannot be determined, e.g. because of a missing partial, are skipped.
+func findUnusedPartials(
+	parser *PromptsParser, tmpl *template.Template, promptsDir string, promptNames []string,
+) ([]string, error) {
+	files, err := os.ReadDir(promptsDir)
+	if err != nil {
+		return nil, fmt.Errorf("read prompts directory: %w", err)
+	}
+	referencedFiles := make(map[string]bool)
+	for _, name := range promptNames {
+		_, partials, analyzeErr := parser.analyzeTemplate(tmpl, name)
+		if analyzeErr != nil {
+			continue
+		}
+		for _, partial := range partials {
+			if t := lookupPartial(tmpl, partial); t != nil {
+				referencedFiles[t.Tree.ParseName] = true
+			}
+		}
+	}
+
+	var unused []string
+	for _, file := range files {
+		if isPartialFile(file) && !referencedFiles[file.Name()] {
+			unused = append(unused, file.Name())
+		}
+	}
+	sort.Strings(unused)
+	return unused, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type UnusedPartialsTestSuite struct {
+	suite.Suite
+	promptsDir string
+}
+
+func TestUnusedPartialsTestSuite(t *testing.T) {
+	suite.Run(t, new(UnusedPartialsTestSuite))
+}
+
+func (s *UnusedPartialsTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.writeFile("_header.tmpl", `{{define "_header"}}Header {{template "_signature.tmpl" .}}{{end}}`)
+	s.writeFile("_signature.tmpl", `Signed by {{.author}}`)
+	s.writeFile("_old_footer.tmpl", `{{define "_old_footer"}}Footer{{end}}`)
+	s.writeFile("review.tmpl", "{{/* Review */}}\n{{template \"_header\" .}}\nReview {{.path}}")
+}
+
+func (s *UnusedPartialsTestSuite) writeFile(name, content string) {
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, name), []byte(content), 0644))
+}
+
+func (s *UnusedPartialsTestSuite) validate(args ...string) (string, error) {
+	var buf bytes.Buffer
+	app := newApp()
+	app.Writer = &buf
+	err := app.Run(context.Background(),
+		append([]string{app.Name, "--color", "never", "--prompts", s.promptsDir, "validate"}, args...))
+	return removeANSIColors(buf.String()), err
+}
+
+// TestFindUnusedPartials tests that partials referenced directly or through other partials are used
+func (s *UnusedPartialsTestSuite) TestFindUnusedPartials() {
+	parser := &PromptsParser{}
+	tmpl, err := parser.ParseDir(s.promptsDir)
+	require.NoError(s.T(), err)
+	unused, err := findUnusedPartials(parser, tmpl, s.promptsDir, []string{"review.tmpl"})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"_old_footer.tmpl"}, unused)
+
+	unused, err = findUnusedPartials(parser, tmpl, s.promptsDir, nil)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"_header.tmpl", "_old_footer.tmpl", "_signature.tmpl"}, unused)
+}
+
+// TestValidateStrictPartials tests that validate fails on unused partials only with --strict-partials
+func (s *UnusedPartialsTestSuite) TestValidateStrictPartials() {
+	output, err := s.validate()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "✓ review.tmpl - Valid\n", output)
+
+	output, err = s.validate("--strict-partials")
+	require.Error(s.T(), err)
+	assert.Equal(s.T(), "✓ review.tmpl - Valid\n✗ _old_footer.tmpl - Error: not referenced by any prompt\n", output)
+
+	s.writeFile("footer.tmpl", "{{/* Footer */}}\n{{template \"_old_footer\" .}}")
+	output, err = s.validate("--strict-partials")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "✓ footer.tmpl - Valid\n✓ review.tmpl - Valid\n", output)
+}
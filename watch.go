@@ -0,0 +1,470 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchMode selects how the server detects changes of the prompts and partials directories.
+type WatchMode string
+
+const (
+	WatchModeAuto     WatchMode = "auto"     // file system notifications, switching to polling if none are delivered
+	WatchModeFSNotify WatchMode = "fsnotify" // file system notifications only
+	WatchModePoll     WatchMode = "poll"     // periodic scans of the directories
+	WatchModeOff      WatchMode = "off"      // no hot-reload
+)
+
+const (
+	defaultWatchPollInterval = 2 * time.Second
+	// defaultWatchSweepInterval is how often the auto mode checks whether files changed without notifications.
+	defaultWatchSweepInterval = 30 * time.Second
+	// defaultWatchStatsInterval is how often the number of watches and the rate of notifications are logged.
+	defaultWatchStatsInterval = time.Minute
+)
+
+// ParseWatchMode parses a watch mode name as accepted by the --watch-mode flag.
+func ParseWatchMode(s string) (WatchMode, error) {
+	switch mode := WatchMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case WatchModeAuto, WatchModeFSNotify, WatchModePoll, WatchModeOff:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown watch mode %q (expected auto, fsnotify, poll, or off)", s)
+}
+
+// ParseWatchExtensions parses the file extensions of the --watch-extensions flag, with or without a leading dot.
+func ParseWatchExtensions(extensions []string) ([]string, error) {
+	parsed := make([]string, 0, len(extensions))
+	for _, ext := range extensions {
+		trimmed := strings.TrimPrefix(strings.TrimSpace(ext), ".")
+		if trimmed == "" || strings.ContainsAny(trimmed, `/\*?`) {
+			return nil, fmt.Errorf("invalid watch extension %q (expected e.g. txt or .md)", ext)
+		}
+		parsed = append(parsed, "."+trimmed)
+	}
+	return parsed, nil
+}
+
+// fileWatcher delivers file system notifications; it is an interface so tests can inject a fake.
+type fileWatcher interface {
+	Events() <-chan fsnotify.Event
+	Errors() <-chan error
+	// Rewatch watches the directory again, after it was replaced by another directory at the same path.
+	Rewatch(dir string) error
+	// WatchList returns the watched paths.
+	WatchList() []string
+	Close() error
+}
+
+type fsnotifyWatcher struct {
+	watcher *fsnotify.Watcher
+}
+
+// newFSNotifyWatcher creates a watcher of the prompts directory and the partials directories.
+// Their parent directories are watched too, so the watcher notices when a directory is replaced at its path,
+// e.g. by renaming another directory over it or by flipping a symlink, which notifications of the directory
+// itself do not report. Given a git index or a feature flags file, its directory is watched as well.
+func newFSNotifyWatcher(promptsDir string, partialsDirs []string, gitIndex string, flagsFile string) (_ fileWatcher, err error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("create file watcher: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, watcher.Close())
+		}
+	}()
+
+	if err = watcher.Add(promptsDir); err != nil {
+		return nil, fmt.Errorf("add prompts directory to watcher: %w", err)
+	}
+	for _, dir := range partialsDirs {
+		if err = watcher.Add(dir); err != nil {
+			return nil, fmt.Errorf("add partials directory to watcher: %w", err)
+		}
+	}
+	for _, dir := range append([]string{promptsDir}, partialsDirs...) {
+		if parent := filepath.Dir(filepath.Clean(dir)); parent != filepath.Clean(dir) {
+			if err = watcher.Add(parent); err != nil {
+				return nil, fmt.Errorf("add parent directory to watcher: %w", err)
+			}
+		}
+	}
+	if gitIndex != "" {
+		if err = watcher.Add(filepath.Dir(gitIndex)); err != nil {
+			return nil, fmt.Errorf("add git directory to watcher: %w", err)
+		}
+	}
+	if flagsFile != "" {
+		if err = watcher.Add(filepath.Dir(flagsFile)); err != nil {
+			return nil, fmt.Errorf("add flags file directory to watcher: %w", err)
+		}
+	}
+	return &fsnotifyWatcher{watcher: watcher}, nil
+}
+
+func (w *fsnotifyWatcher) Events() <-chan fsnotify.Event { return w.watcher.Events }
+func (w *fsnotifyWatcher) Errors() <-chan error          { return w.watcher.Errors }
+func (w *fsnotifyWatcher) WatchList() []string           { return w.watcher.WatchList() }
+func (w *fsnotifyWatcher) Close() error                  { return w.watcher.Close() }
+
+func (w *fsnotifyWatcher) Rewatch(dir string) error {
+	// The watch follows the inode of the replaced directory, if it still exists
+	_ = w.watcher.Remove(dir)
+	return w.watcher.Add(dir)
+}
+
+// isWatchedFile reports whether a change of the file may affect the served prompts.
+// Files with the extra extensions are watched too, e.g. for files that templates embed.
+func isWatchedFile(path string, extensions []string) bool {
+	base := filepath.Base(path)
+	if strings.HasSuffix(base, templateExt) || isSchemaFile(base) || base == collectionsFileName || base == funcsFileName {
+		return true
+	}
+	for _, ext := range extensions {
+		if strings.HasSuffix(base, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// dirPoller detects changes of watched files by comparing fingerprints of the directories.
+// A fingerprint covers names, sizes, and modification times, so a scan is a single ReadDir pass
+// per directory plus a stat of each watched file; file contents are never read.
+// Files outside the directories, such as the git index, can be added to the fingerprint.
+type dirPoller struct {
+	dirs        []string
+	extensions  []string // extra extensions of watched files, see isWatchedFile
+	files       []string
+	fingerprint [sha256.Size]byte
+}
+
+func newDirPoller(dirs []string, extensions []string, files ...string) (*dirPoller, error) {
+	p := &dirPoller{dirs: dirs, extensions: extensions, files: files}
+	fingerprint, err := fingerprintDirs(dirs, extensions, files)
+	if err != nil {
+		return nil, err
+	}
+	p.fingerprint = fingerprint
+	return p, nil
+}
+
+// Changed rescans the directories and reports whether any watched file changed since the previous scan.
+func (p *dirPoller) Changed() (bool, error) {
+	fingerprint, err := fingerprintDirs(p.dirs, p.extensions, p.files)
+	if err != nil {
+		return false, err
+	}
+	if fingerprint == p.fingerprint {
+		return false, nil
+	}
+	p.fingerprint = fingerprint
+	return true, nil
+}
+
+func fingerprintDirs(dirs []string, extensions []string, files []string) ([sha256.Size]byte, error) {
+	hash := sha256.New()
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir) // sorted by name
+		if err != nil {
+			return [sha256.Size]byte{}, fmt.Errorf("read directory: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !isWatchedFile(entry.Name(), extensions) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue // removed since ReadDir
+				}
+				return [sha256.Size]byte{}, fmt.Errorf("stat file: %w", err)
+			}
+			_, _ = fmt.Fprintf(hash, "%s\x00%s\x00%d\x00%d\n",
+				dir, entry.Name(), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return [sha256.Size]byte{}, fmt.Errorf("stat file: %w", err)
+		}
+		_, _ = fmt.Fprintf(hash, "%s\x00%d\x00%d\n", file, info.Size(), info.ModTime().UnixNano())
+	}
+	var fingerprint [sha256.Size]byte
+	copy(fingerprint[:], hash.Sum(nil))
+	return fingerprint, nil
+}
+
+// hashWatchedContent returns a hash of the names and contents of the watched files of the directories and of the files.
+// Unlike a fingerprint, it does not change when only the metadata of files change, e.g. on touch or chmod.
+func hashWatchedContent(dirs []string, extensions []string, files []string) (string, error) {
+	hash := sha256.New()
+	writeFile := func(path string) error {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // removed since ReadDir
+			}
+			return fmt.Errorf("read file: %w", err)
+		}
+		_, _ = fmt.Fprintf(hash, "%s\x00%d\x00", path, len(content))
+		_, _ = hash.Write(content)
+		return nil
+	}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir) // sorted by name
+		if err != nil {
+			return "", fmt.Errorf("read directory: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !isWatchedFile(entry.Name(), extensions) {
+				continue
+			}
+			if err = writeFile(filepath.Join(dir, entry.Name())); err != nil {
+				return "", err
+			}
+		}
+	}
+	for _, file := range files {
+		if err := writeFile(file); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// watchedContentHash returns the hash of the contents of the watched files, or an empty string
+// if the prompts are not watched or the files cannot be read, so that reloads are never skipped then.
+func (ps *PromptsServer) watchedContentHash() string {
+	if ps.WatchMode() == WatchModeOff {
+		return ""
+	}
+	contentHash, err := hashWatchedContent(ps.watchedDirs(), ps.watchExtensions, ps.watchedFiles())
+	if err != nil {
+		ps.logger.Debug("Failed to hash watched files", "error", err)
+		return ""
+	}
+	return contentHash
+}
+
+// WatchMode returns the watch mode in effect, which differs from the configured one
+// if the auto mode has switched to polling.
+func (ps *PromptsServer) WatchMode() WatchMode {
+	ps.watchModeMu.Lock()
+	defer ps.watchModeMu.Unlock()
+	return ps.watchMode
+}
+
+func (ps *PromptsServer) setWatchMode(mode WatchMode) {
+	ps.watchModeMu.Lock()
+	defer ps.watchModeMu.Unlock()
+	ps.watchMode = mode
+}
+
+func (ps *PromptsServer) watchedDirs() []string {
+	return append([]string{ps.promptsDir}, ps.parser.partialsDirs...)
+}
+
+// watchedFiles returns the files outside the watched directories whose changes trigger a reload.
+func (ps *PromptsServer) watchedFiles() []string {
+	var files []string
+	if ps.gitIndexPath != "" {
+		files = append(files, ps.gitIndexPath)
+	}
+	if ps.flagsFile != "" {
+		files = append(files, ps.flagsFile)
+	}
+	return files
+}
+
+// startWatcher monitors file system changes and reloads prompts
+func (ps *PromptsServer) startWatcher(ctx context.Context) {
+	mode := ps.WatchMode()
+	if mode == WatchModeOff {
+		ps.logger.Info("Hot-reload of prompts is disabled", "watch_mode", mode)
+		return
+	}
+
+	// The first scan is taken before logging the start, so changes made after it are detected
+	var poller *dirPoller
+	if mode == WatchModeAuto || mode == WatchModePoll {
+		var err error
+		if poller, err = newDirPoller(ps.watchedDirs(), ps.watchExtensions, ps.watchedFiles()...); err != nil {
+			ps.logger.Error("Failed to scan prompts directory", "error", err)
+		}
+	}
+	ps.logger.Info("Started watching prompts directory for changes", "dir", ps.promptsDir, "watch_mode", mode)
+
+	if mode != WatchModePoll {
+		if !ps.watchNotifications(ctx, poller) {
+			return
+		}
+		ps.setWatchMode(WatchModePoll)
+		ps.logger.Warn("Files changed without file system notifications, switching to polling",
+			"dir", ps.promptsDir, "watch_mode", WatchModePoll, "poll_interval", ps.watchPollInterval)
+		ps.reloadAfterChange()
+	}
+	ps.pollChanges(ctx, poller)
+}
+
+// watchNotifications reloads prompts on file system notifications until the context is canceled.
+// Given a poller, it also sweeps the directories periodically and returns true, to switch to polling,
+// when files changed but no notification arrived since the previous sweep.
+func (ps *PromptsServer) watchNotifications(ctx context.Context, poller *dirPoller) bool {
+	var sweep <-chan time.Time
+	if poller != nil {
+		ticker := time.NewTicker(ps.watchSweepInterval)
+		defer ticker.Stop()
+		sweep = ticker.C
+	}
+	statsTicker := time.NewTicker(ps.watchStatsInterval)
+	defer statsTicker.Stop()
+
+	// Event names are clean paths, as the watcher cleans the paths it watches
+	dirs := make(map[string]bool)
+	for _, dir := range ps.watchedDirs() {
+		dirs[filepath.Clean(dir)] = true
+	}
+
+	notified := false
+	var events, ignoredEvents int
+	for {
+		select {
+		case event, ok := <-ps.watcher.Events():
+			if !ok {
+				return false
+			}
+			events++
+			// Events of unrelated files are dropped first and without allocations,
+			// as directories shared with other files may deliver many of them
+			if dirs[event.Name] {
+				if event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+					notified = true
+					ps.rewatchDir(event.Name, event)
+				}
+				continue
+			}
+			// Git replaces its index on every git add and git rm
+			if event.Name == ps.gitIndexPath && (event.Has(fsnotify.Create) || event.Has(fsnotify.Write)) {
+				notified = true
+				ps.logger.Info("Git index changed", "file", event.Name, "operation", event.Op.String())
+				ps.reloadAfterChange()
+				continue
+			}
+			if event.Name == ps.flagsFile && (event.Has(fsnotify.Create) || event.Has(fsnotify.Write)) {
+				notified = true
+				ps.logger.Info("Feature flags file changed", "file", event.Name, "operation", event.Op.String())
+				ps.reloadAfterChange()
+				continue
+			}
+			// Events of parent directories are only of interest for the watched directories themselves
+			if !isWatchedFile(event.Name, ps.watchExtensions) || !dirs[filepath.Dir(event.Name)] {
+				ignoredEvents++
+				continue
+			}
+			notified = true
+			ps.logger.Info("Prompt template file changed", "file", event.Name, "operation", event.Op.String())
+			ps.reloadAfterChange()
+
+		case err, ok := <-ps.watcher.Errors():
+			if !ok {
+				return false
+			}
+			ps.logger.Error("File watcher error", "error", err)
+
+		case <-statsTicker.C:
+			ps.logger.Debug("File watcher activity",
+				"watches", len(ps.watcher.WatchList()),
+				"events_per_minute", int(float64(events)*float64(time.Minute)/float64(ps.watchStatsInterval)),
+				"ignored_events", ignoredEvents)
+			events, ignoredEvents = 0, 0
+
+		case <-sweep:
+			changed, err := poller.Changed()
+			if err != nil {
+				ps.logger.Error("Failed to scan prompts directory", "error", err)
+				continue
+			}
+			if changed && !notified {
+				return true
+			}
+			notified = false
+
+		case <-ctx.Done():
+			ps.logger.Info("Stopping prompts watcher due to context cancellation")
+			return false
+		}
+	}
+}
+
+// rewatchDir watches a directory again after it was replaced at its path and reloads the prompts.
+// A removed directory is watched again once it is created.
+func (ps *PromptsServer) rewatchDir(dir string, event fsnotify.Event) {
+	if err := ps.watcher.Rewatch(dir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			ps.logger.Warn("Watched directory was removed, waiting for it to be created",
+				"dir", dir, "operation", event.Op.String())
+			return
+		}
+		ps.logger.Error("Failed to watch replaced directory", "dir", dir, "error", err)
+		return
+	}
+	ps.logger.Info("Watched directory was replaced, watching the new one", "dir", dir, "operation", event.Op.String())
+	ps.reloadAfterChange()
+}
+
+// pollChanges reloads prompts when a periodic scan detects changes, until the context is canceled.
+// Without a poller, it retries the first scan on every tick.
+func (ps *PromptsServer) pollChanges(ctx context.Context, poller *dirPoller) {
+	ticker := time.NewTicker(ps.watchPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if poller == nil {
+				var err error
+				if poller, err = newDirPoller(ps.watchedDirs(), ps.watchExtensions, ps.watchedFiles()...); err != nil {
+					ps.logger.Error("Failed to scan prompts directory", "error", err)
+				}
+				continue
+			}
+			changed, err := poller.Changed()
+			if err != nil {
+				ps.logger.Error("Failed to scan prompts directory", "error", err)
+				continue
+			}
+			if changed {
+				ps.logger.Info("Prompts directory changed", "dir", ps.promptsDir, "watch_mode", WatchModePoll)
+				ps.reloadAfterChange()
+			}
+
+		case <-ctx.Done():
+			ps.logger.Info("Stopping prompts watcher due to context cancellation")
+			return
+		}
+	}
+}
+
+// reloadAfterChange reloads the prompts after a detected change, unless the contents of the watched files
+// are unchanged, as file system notifications and polling report changes of metadata too.
+func (ps *PromptsServer) reloadAfterChange() {
+	reloaded, err := ps.reloadChangedPrompts()
+	if err != nil {
+		ps.logger.Error("Failed to reload prompts", "error", err, logSourceKey, logSourceReload)
+	} else if !reloaded {
+		ps.logger.Debug("Contents of the watched files are unchanged, skipping reload", "dir", ps.promptsDir)
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type WatchTestSuite struct {
+	suite.Suite
+	promptsDir string
+	mtime      time.Time
+}
+
+func TestWatchTestSuite(t *testing.T) {
+	suite.Run(t, new(WatchTestSuite))
+}
+
+func (s *WatchTestSuite) SetupTest() {
+	s.promptsDir = s.T().TempDir()
+	s.mtime = time.Now().Add(-time.Hour).Truncate(time.Second)
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello v1")
+}
+
+// writeFile writes a file with a modification time a second later than the previous write,
+// so changes are detected even on filesystems with coarse timestamps
+func (s *WatchTestSuite) writeFile(name, content string) {
+	path := filepath.Join(s.promptsDir, name)
+	require.NoError(s.T(), os.WriteFile(path, []byte(content), 0644))
+	s.mtime = s.mtime.Add(time.Second)
+	require.NoError(s.T(), os.Chtimes(path, s.mtime, s.mtime))
+}
+
+// fakeWatcher is a fileWatcher that delivers only the events sent by the test
+type fakeWatcher struct {
+	events chan fsnotify.Event
+	errors chan error
+}
+
+func newFakeWatcher() *fakeWatcher {
+	return &fakeWatcher{events: make(chan fsnotify.Event), errors: make(chan error)}
+}
+
+func (w *fakeWatcher) Events() <-chan fsnotify.Event { return w.events }
+func (w *fakeWatcher) Errors() <-chan error          { return w.errors }
+func (w *fakeWatcher) Rewatch(dir string) error      { return nil }
+func (w *fakeWatcher) WatchList() []string           { return []string{"fake"} }
+func (w *fakeWatcher) Close() error                  { return nil }
+
+// newServer creates a server with a fake watcher, or the file system watcher if none is given,
+// and short intervals, and starts watching for changes
+func (s *WatchTestSuite) newServer(mode WatchMode, watcher *fakeWatcher, logs *syncBuffer, opts ...Option) *PromptsServer {
+	promptsServer, err := NewPromptsServer(s.promptsDir, append([]Option{
+		WithWatchMode(mode, 10*time.Millisecond),
+		WithLogger(slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	}, opts...)...)
+	require.NoError(s.T(), err)
+	if watcher != nil && promptsServer.watcher != nil {
+		require.NoError(s.T(), promptsServer.watcher.Close())
+		promptsServer.watcher = watcher
+	}
+	promptsServer.watchSweepInterval = 50 * time.Millisecond
+	promptsServer.watchStatsInterval = 50 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		promptsServer.startWatcher(ctx)
+	}()
+	s.T().Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+	// Changes made before the watcher logs its start are not detected
+	require.Eventually(s.T(), func() bool {
+		return strings.Contains(strings.Join(logs.Lines(), "\n"), "watch_mode="+string(mode))
+	}, time.Second, time.Millisecond)
+	return promptsServer
+}
+
+func (s *WatchTestSuite) renderGreet(promptsServer *PromptsServer) string {
+	getPrompt := []byte(`{"jsonrpc":"2.0","id":1,"method":"prompts/get","params":{"name":"greet"}}`)
+	response, err := json.Marshal(promptsServer.mcpServer.HandleMessage(context.Background(), getPrompt))
+	require.NoError(s.T(), err)
+	return string(response)
+}
+
+// TestDirPoller tests that the poller detects changed, added, and removed files by their metadata only
+func (s *WatchTestSuite) TestDirPoller() {
+	partialsDir := s.T().TempDir()
+	poller, err := newDirPoller([]string{s.promptsDir, partialsDir}, nil)
+	require.NoError(s.T(), err)
+
+	changed, err := poller.Changed()
+	require.NoError(s.T(), err)
+	assert.False(s.T(), changed, "nothing changed")
+
+	path := filepath.Join(s.promptsDir, "greet.tmpl")
+	mtime := s.mtime.Add(time.Minute)
+	require.NoError(s.T(), os.Chtimes(path, mtime, mtime))
+	changed, err = poller.Changed()
+	require.NoError(s.T(), err)
+	assert.True(s.T(), changed, "modification time changed")
+
+	changed, err = poller.Changed()
+	require.NoError(s.T(), err)
+	assert.False(s.T(), changed, "changes are reported once")
+
+	require.NoError(s.T(), os.WriteFile(path, []byte("{{/* Greet */}}\nHello v2"), 0644))
+	require.NoError(s.T(), os.Chtimes(path, mtime, mtime))
+	changed, err = poller.Changed()
+	require.NoError(s.T(), err)
+	assert.False(s.T(), changed, "contents are not read if size and modification time are the same")
+
+	require.NoError(s.T(), os.WriteFile(path, []byte("{{/* Greet */}}\nHello, world"), 0644))
+	require.NoError(s.T(), os.Chtimes(path, mtime, mtime))
+	changed, err = poller.Changed()
+	require.NoError(s.T(), err)
+	assert.True(s.T(), changed, "size changed")
+
+	require.NoError(s.T(), os.WriteFile(filepath.Join(s.promptsDir, "notes.txt"), []byte("notes"), 0644))
+	require.NoError(s.T(), os.Mkdir(filepath.Join(s.promptsDir, "drafts.tmpl"), 0755))
+	changed, err = poller.Changed()
+	require.NoError(s.T(), err)
+	assert.False(s.T(), changed, "files that do not affect prompts are ignored")
+
+	require.NoError(s.T(), os.WriteFile(filepath.Join(partialsDir, "_header.tmpl"), []byte("header"), 0644))
+	changed, err = poller.Changed()
+	require.NoError(s.T(), err)
+	assert.True(s.T(), changed, "file added to another directory")
+
+	require.NoError(s.T(), os.Remove(filepath.Join(partialsDir, "_header.tmpl")))
+	changed, err = poller.Changed()
+	require.NoError(s.T(), err)
+	assert.True(s.T(), changed, "file removed")
+
+	require.NoError(s.T(), os.RemoveAll(partialsDir))
+	_, err = poller.Changed()
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "read directory")
+}
+
+// TestAutoSwitchesToPolling tests that the auto mode switches to polling when files change without notifications
+func (s *WatchTestSuite) TestAutoSwitchesToPolling() {
+	var logs syncBuffer
+	promptsServer := s.newServer(WatchModeAuto, newFakeWatcher(), &logs)
+	assert.Equal(s.T(), WatchModeAuto, promptsServer.WatchMode())
+
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello v2")
+	require.Eventually(s.T(), func() bool {
+		return promptsServer.WatchMode() == WatchModePoll && strings.Contains(s.renderGreet(promptsServer), "Hello v2")
+	}, 2*time.Second, 10*time.Millisecond, "server should switch to polling and reload the changed template")
+
+	output := strings.Join(logs.Lines(), "\n")
+	assert.Contains(s.T(), output, `msg="Started watching prompts directory for changes" dir=`+s.promptsDir+" watch_mode=auto")
+	assert.Contains(s.T(), output, `msg="Files changed without file system notifications, switching to polling"`)
+
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello v3")
+	require.Eventually(s.T(), func() bool {
+		return strings.Contains(s.renderGreet(promptsServer), "Hello v3")
+	}, 2*time.Second, 10*time.Millisecond, "server should reload changes found by polling")
+}
+
+// TestAutoKeepsNotifications tests that the auto mode keeps using notifications while they are delivered
+func (s *WatchTestSuite) TestAutoKeepsNotifications() {
+	var logs syncBuffer
+	watcher := newFakeWatcher()
+	promptsServer := s.newServer(WatchModeAuto, watcher, &logs)
+
+	for _, content := range []string{"Hello v2", "Hello v3"} {
+		s.writeFile("greet.tmpl", "{{/* Greet */}}\n"+content)
+		watcher.events <- fsnotify.Event{Name: filepath.Join(s.promptsDir, "greet.tmpl"), Op: fsnotify.Write}
+		require.Eventually(s.T(), func() bool {
+			return strings.Contains(s.renderGreet(promptsServer), content)
+		}, 2*time.Second, 10*time.Millisecond, "server should reload on notifications")
+		time.Sleep(2 * promptsServer.watchSweepInterval)
+	}
+	assert.Equal(s.T(), WatchModeAuto, promptsServer.WatchMode())
+	assert.NotContains(s.T(), strings.Join(logs.Lines(), "\n"), "switching to polling")
+}
+
+// TestOtherModes tests that the fsnotify mode never switches, the poll mode reloads without a watcher,
+// and the off mode does not reload
+func (s *WatchTestSuite) TestOtherModes() {
+	var logs syncBuffer
+	promptsServer := s.newServer(WatchModeFSNotify, newFakeWatcher(), &logs)
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello v2")
+	time.Sleep(3 * promptsServer.watchSweepInterval)
+	assert.Equal(s.T(), WatchModeFSNotify, promptsServer.WatchMode())
+	assert.Contains(s.T(), s.renderGreet(promptsServer), "Hello v1")
+
+	promptsServer = s.newServer(WatchModePoll, nil, &logs)
+	assert.Nil(s.T(), promptsServer.watcher)
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello v3")
+	require.Eventually(s.T(), func() bool {
+		return strings.Contains(s.renderGreet(promptsServer), "Hello v3")
+	}, 2*time.Second, 10*time.Millisecond, "server should reload changes found by polling")
+	assert.Contains(s.T(), strings.Join(logs.Lines(), "\n"), `msg="Prompts directory changed" dir=`+s.promptsDir)
+
+	promptsServer = s.newServer(WatchModeOff, nil, &logs)
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello v4")
+	time.Sleep(50 * time.Millisecond)
+	assert.Contains(s.T(), s.renderGreet(promptsServer), "Hello v3")
+	assert.Contains(s.T(), strings.Join(logs.Lines(), "\n"), `msg="Hot-reload of prompts is disabled" watch_mode=off`)
+
+	_, err := ParseWatchMode("inotify")
+	require.Error(s.T(), err)
+	mode, err := ParseWatchMode(" Poll ")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), WatchModePoll, mode)
+}
+
+// TestDirectorySwap tests that the file system watcher follows the prompts directory when it is replaced at its path
+func (s *WatchTestSuite) TestDirectorySwap() {
+	releasesDir := s.T().TempDir()
+	newRelease := func(name, content string) string {
+		dir := filepath.Join(releasesDir, name)
+		require.NoError(s.T(), os.Mkdir(dir, 0755))
+		require.NoError(s.T(), os.WriteFile(filepath.Join(dir, "greet.tmpl"), []byte("{{/* Greet */}}\n"+content), 0644))
+		return dir
+	}
+	newRelease("v1", "Hello v1")
+	s.promptsDir = filepath.Join(releasesDir, "current")
+	require.NoError(s.T(), os.Symlink("v1", s.promptsDir))
+
+	var logs syncBuffer
+	promptsServer := s.newServer(WatchModeFSNotify, nil, &logs)
+	assert.Contains(s.T(), s.renderGreet(promptsServer), "Hello v1")
+
+	// Deployments flip the symlink atomically by renaming a new symlink over it
+	newRelease("v2", "Hello v2")
+	require.NoError(s.T(), os.Symlink("v2", filepath.Join(releasesDir, "next")))
+	require.NoError(s.T(), os.Rename(filepath.Join(releasesDir, "next"), s.promptsDir))
+	require.Eventually(s.T(), func() bool {
+		return strings.Contains(s.renderGreet(promptsServer), "Hello v2")
+	}, 2*time.Second, 10*time.Millisecond, "server should reload after the symlink flip")
+	assert.Contains(s.T(), strings.Join(logs.Lines(), "\n"), `msg="Watched directory was replaced, watching the new one"`)
+
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello v2.1")
+	require.Eventually(s.T(), func() bool {
+		return strings.Contains(s.renderGreet(promptsServer), "Hello v2.1")
+	}, 2*time.Second, 10*time.Millisecond, "server should watch the new directory")
+
+	// Replace the symlink by a directory renamed into its place
+	require.NoError(s.T(), os.Remove(s.promptsDir))
+	require.NoError(s.T(), os.Rename(newRelease("v3", "Hello v3"), s.promptsDir))
+	require.Eventually(s.T(), func() bool {
+		return strings.Contains(s.renderGreet(promptsServer), "Hello v3")
+	}, 2*time.Second, 10*time.Millisecond, "server should reload after the directory is renamed into place")
+
+	s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello v3.1")
+	require.Eventually(s.T(), func() bool {
+		return strings.Contains(s.renderGreet(promptsServer), "Hello v3.1")
+	}, 2*time.Second, 10*time.Millisecond, "server should watch the renamed directory")
+}
+
+// TestUnrelatedEvents tests that events of unrelated files are dropped quickly, without reloads,
+// and that the watcher activity is logged
+func (s *WatchTestSuite) TestUnrelatedEvents() {
+	var logs syncBuffer
+	watcher := newFakeWatcher()
+	promptsServer := s.newServer(WatchModeFSNotify, watcher, &logs)
+
+	const count = 5000
+	unrelated := []string{
+		filepath.Join(s.promptsDir, "notes.md"),
+		filepath.Join(s.promptsDir, "node_modules"),
+		filepath.Join(filepath.Dir(s.promptsDir), "other.tmpl"), // a template beside the watched directory
+	}
+	started := time.Now()
+	for i := range count {
+		watcher.events <- fsnotify.Event{Name: unrelated[i%len(unrelated)], Op: fsnotify.Write}
+	}
+	elapsed := time.Since(started)
+	assert.Less(s.T(), elapsed, 2*time.Second, "dropping %d events must be cheap", count)
+	assert.Contains(s.T(), s.renderGreet(promptsServer), "Hello v1")
+
+	require.Eventually(s.T(), func() bool {
+		return strings.Contains(strings.Join(logs.Lines(), "\n"), `msg="File watcher activity" watches=1`)
+	}, 2*time.Second, 10*time.Millisecond)
+	output := strings.Join(logs.Lines(), "\n")
+	assert.NotContains(s.T(), output, "Prompt template file changed")
+	assert.Equal(s.T(), 1, strings.Count(output, `msg="Prompts registered"`), "only the initial load must register prompts")
+	assert.Regexp(s.T(), `ignored_events=[1-9]`, output)
+}
+
+// TestWatchExtensions tests that changes of files with the extra extensions reload the prompts,
+// with notifications and with polling
+func (s *WatchTestSuite) TestWatchExtensions() {
+	s.writeFile("notes.txt", "v1")
+	for _, mode := range []WatchMode{WatchModeFSNotify, WatchModePoll} {
+		s.Run(string(mode), func() {
+			var logs syncBuffer
+			s.newServer(mode, nil, &logs, WithWatchExtensions(".txt"))
+
+			s.writeFile("notes.txt", "v2 "+string(mode))
+			require.Eventually(s.T(), func() bool {
+				return strings.Contains(strings.Join(logs.Lines(), "\n"), `msg="Prompts reloaded"`)
+			}, 2*time.Second, 10*time.Millisecond, "server should reload after the text file changed")
+			if mode == WatchModeFSNotify {
+				assert.Contains(s.T(), strings.Join(logs.Lines(), "\n"),
+					`msg="Prompt template file changed" file=`+filepath.Join(s.promptsDir, "notes.txt"))
+			}
+		})
+	}
+
+	poller, err := newDirPoller([]string{s.promptsDir}, nil)
+	require.NoError(s.T(), err)
+	s.writeFile("notes.txt", "v3")
+	changed, err := poller.Changed()
+	require.NoError(s.T(), err)
+	assert.False(s.T(), changed, "text files are not watched by default")
+
+	extensions, err := ParseWatchExtensions([]string{"txt", " .md "})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{".txt", ".md"}, extensions)
+	for _, invalid := range []string{"", ".", "*.txt", "a/b"} {
+		_, err = ParseWatchExtensions([]string{invalid})
+		assert.ErrorContains(s.T(), err, "invalid watch extension", invalid)
+	}
+}
+
+// TestTouchDoesNotReload tests that changes of modification times and permissions only are detected
+// but do not reload the prompts, with notifications and with polling
+func (s *WatchTestSuite) TestTouchDoesNotReload() {
+	path := filepath.Join(s.promptsDir, "greet.tmpl")
+	for _, mode := range []WatchMode{WatchModeFSNotify, WatchModePoll} {
+		s.Run(string(mode), func() {
+			var logs syncBuffer
+			promptsServer := s.newServer(mode, nil, &logs)
+
+			s.mtime = s.mtime.Add(time.Minute)
+			require.NoError(s.T(), os.Chtimes(path, s.mtime, s.mtime))
+			require.NoError(s.T(), os.Chmod(path, 0600))
+			require.Eventually(s.T(), func() bool {
+				return strings.Contains(strings.Join(logs.Lines(), "\n"), "skipping reload")
+			}, 2*time.Second, 10*time.Millisecond, "server should notice the touched file")
+			output := strings.Join(logs.Lines(), "\n")
+			assert.NotContains(s.T(), output, `msg="Prompts reloaded"`)
+			assert.Equal(s.T(), 1, strings.Count(output, `msg="Prompts registered"`), "only the initial load must register prompts")
+
+			s.writeFile("greet.tmpl", "{{/* Greet */}}\nHello "+string(mode))
+			require.Eventually(s.T(), func() bool {
+				return strings.Contains(s.renderGreet(promptsServer), "Hello "+string(mode))
+			}, 2*time.Second, 10*time.Millisecond, "server should reload changed contents")
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Markers used to make invisible characters visible.
+const (
+	trailingSpaceMarker = "·"
+	tabMarker           = "→"
+	carriageReturnMark  = "␍"
+	endOfLineMarker     = "¶"
+	blankLineGutter     = "░ "
+	textLineGutter      = "  "
+)
+
+// whitespaceLine describes the invisible characters of a single line.
+type whitespaceLine struct {
+	text          string // line content without the line ending
+	crlf          bool   // line ends with "\r\n"
+	blank         bool   // line is empty or contains only whitespace
+	trailingSpace bool   // line has whitespace after its last visible character
+}
+
+// whitespaceReport is the result of a whitespace analysis of a text.
+type whitespaceReport struct {
+	Lines                   []whitespaceLine
+	BlankLines              int
+	LongestBlankRun         int
+	TrailingWhitespaceLines int
+	CRLFLines               int
+}
+
+// analyzeWhitespace splits text into lines and records blank lines, trailing whitespace, and Windows line endings.
+// A trailing line ending does not start a new line.
+func analyzeWhitespace(text string) whitespaceReport {
+	var report whitespaceReport
+	if text == "" {
+		return report
+	}
+
+	blankRun := 0
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n") {
+		line = strings.TrimSuffix(line, "\n")
+		info := whitespaceLine{text: line}
+		if strings.HasSuffix(line, "\r") {
+			info.crlf = true
+			info.text = strings.TrimSuffix(line, "\r")
+			report.CRLFLines++
+		}
+		trimmed := strings.TrimRight(info.text, " \t\r\v\f")
+		info.blank = trimmed == ""
+		info.trailingSpace = trimmed != info.text
+
+		if info.blank {
+			report.BlankLines++
+			blankRun++
+			report.LongestBlankRun = max(report.LongestBlankRun, blankRun)
+		} else {
+			blankRun = 0
+		}
+		if info.trailingSpace {
+			report.TrailingWhitespaceLines++
+		}
+		report.Lines = append(report.Lines, info)
+	}
+	return report
+}
+
+// writeVisibleWhitespace prints text with markers for tabs, trailing spaces, carriage returns, and line ends,
+// flags whitespace-only lines in a gutter, and appends a summary.
+func writeVisibleWhitespace(w io.Writer, text string) {
+	report := analyzeWhitespace(text)
+	for _, line := range report.Lines {
+		gutter := textLineGutter
+		if line.blank {
+			gutter = blankLineGutter
+		}
+		trimmed := strings.TrimRight(line.text, " \t")
+		trailing := line.text[len(trimmed):]
+		visible := strings.ReplaceAll(trimmed, "\t", tabMarker) +
+			strings.NewReplacer(" ", trailingSpaceMarker, "\t", tabMarker).Replace(trailing)
+		if line.crlf {
+			visible += carriageReturnMark
+		}
+		mustFprintf(w, "%s%s%s\n", gutter, visible, endOfLineMarker)
+	}
+	mustFprintf(w, "\n%s\n", formatWhitespaceSummary(report))
+}
+
+// formatWhitespaceSummary describes the whitespace statistics of a report in a single line.
+func formatWhitespaceSummary(report whitespaceReport) string {
+	summary := fmt.Sprintf("%s: %s, %s, longest blank run: %d, %s",
+		infoText("Whitespace"),
+		pluralize(len(report.Lines), "line", "lines"),
+		pluralize(report.BlankLines, "blank line", "blank lines"),
+		report.LongestBlankRun,
+		pluralize(report.TrailingWhitespaceLines, "line", "lines")+" with trailing whitespace")
+	if report.CRLFLines > 0 {
+		summary += ", " + pluralize(report.CRLFLines, "line", "lines") + " with Windows line endings"
+	}
+	return summary
+}
+
+// whitespaceIssues lists the copy-paste issues of a template source: trailing whitespace and Windows line endings.
+func whitespaceIssues(source string) []string {
+	report := analyzeWhitespace(source)
+	var trailingLines []string
+	for i, line := range report.Lines {
+		if line.trailingSpace {
+			trailingLines = append(trailingLines, fmt.Sprint(i+1))
+		}
+	}
+	var issues []string
+	if len(trailingLines) > 0 {
+		noun := "line"
+		if len(trailingLines) > 1 {
+			noun = "lines"
+		}
+		issues = append(issues, fmt.Sprintf("trailing whitespace on %s %s", noun, strings.Join(trailingLines, ", ")))
+	}
+	if report.CRLFLines > 0 {
+		issues = append(issues, "Windows line endings on "+pluralize(report.CRLFLines, "line", "lines"))
+	}
+	return issues
+}
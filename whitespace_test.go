@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type WhitespaceTestSuite struct {
+	suite.Suite
+}
+
+func TestWhitespaceTestSuite(t *testing.T) {
+	suite.Run(t, new(WhitespaceTestSuite))
+}
+
+// TestAnalyzeWhitespace tests the whitespace statistics over crafted strings
+func (s *WhitespaceTestSuite) TestAnalyzeWhitespace() {
+	tests := []struct {
+		name            string
+		text            string
+		lines           int
+		blankLines      int
+		longestBlankRun int
+		trailingLines   int
+		crlfLines       int
+	}{
+		{
+			name: "empty",
+			text: "",
+		},
+		{
+			name:  "clean text with final newline",
+			text:  "Hello\nWorld\n",
+			lines: 2,
+		},
+		{
+			name:            "blank runs",
+			text:            "A\n\n\n\n\nB\n\nC",
+			lines:           8,
+			blankLines:      5,
+			longestBlankRun: 4,
+		},
+		{
+			name:            "whitespace-only lines count as blank",
+			text:            "A\n  \n\t\nB",
+			lines:           4,
+			blankLines:      2,
+			longestBlankRun: 2,
+			trailingLines:   2,
+		},
+		{
+			name:          "tab and space mix at line end",
+			text:          "A \t\n\tB\nC\t ",
+			lines:         3,
+			trailingLines: 2,
+		},
+		{
+			name:            "CRLF line endings",
+			text:            "A\r\n\r\nB \r\n",
+			lines:           3,
+			blankLines:      1,
+			longestBlankRun: 1,
+			trailingLines:   1,
+			crlfLines:       3,
+		},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			report := analyzeWhitespace(tt.text)
+			assert.Len(s.T(), report.Lines, tt.lines)
+			assert.Equal(s.T(), tt.blankLines, report.BlankLines)
+			assert.Equal(s.T(), tt.longestBlankRun, report.LongestBlankRun)
+			assert.Equal(s.T(), tt.trailingLines, report.TrailingWhitespaceLines)
+			assert.Equal(s.T(), tt.crlfLines, report.CRLFLines)
+		})
+	}
+}
+
+// TestWriteVisibleWhitespace tests the markers and the gutter of the visualized output
+func (s *WhitespaceTestSuite) TestWriteVisibleWhitespace() {
+	var buf bytes.Buffer
+	writeVisibleWhitespace(&buf, "Hello world  \n\tindented\t\n \n\nWindows\r\n")
+
+	expected := "  Hello world··¶\n" +
+		"  →indented→¶\n" +
+		"░ ·¶\n" +
+		"░ ¶\n" +
+		"  Windows␍¶\n" +
+		"\n"
+	output := buf.String()
+	require.True(s.T(), len(output) > len(expected))
+	assert.Equal(s.T(), expected, output[:len(expected)])
+	assert.Contains(s.T(), output,
+		"5 lines, 2 blank lines, longest blank run: 2, 3 lines with trailing whitespace, 1 line with Windows line endings")
+}
+
+// TestValidateWhitespace tests that validate --whitespace reports trailing whitespace and Windows line endings
+func (s *WhitespaceTestSuite) TestValidateWhitespace() {
+	tempDir := s.T().TempDir()
+	files := map[string]string{
+		"clean.tmpl":    "{{/* Clean */}}\nHello {{.name}}\n",
+		"trailing.tmpl": "{{/* Trailing */}}\nHello \n\tWorld\t\n",
+		"windows.tmpl":  "{{/* Windows */}}\r\nHello\r\n",
+	}
+	for name, content := range files {
+		require.NoError(s.T(), os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(s.T(), validateTemplates(&buf, tempDir, nil, "", validateOptions{}))
+
+	buf.Reset()
+	err := validateTemplates(&buf, tempDir, nil, "", validateOptions{checkWhitespace: true})
+	require.Error(s.T(), err)
+	output := buf.String()
+	assert.Contains(s.T(), output, "clean.tmpl - Valid")
+	assert.Contains(s.T(), output, "trailing.tmpl - Error: trailing whitespace on lines 2, 3")
+	assert.Contains(s.T(), output, "windows.tmpl - Error: Windows line endings on 2 lines")
+}